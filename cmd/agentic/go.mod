@@ -0,0 +1,64 @@
+module github.com/sakibmulla/agentic-ai/cmd/agentic
+
+go 1.24.4
+
+require (
+	chatbot v0.0.0
+	day-06-error-handling v0.0.0
+	day04 v0.0.0
+	day05 v0.0.0
+	github.com/joho/godotenv v1.5.1
+	github.com/sakibmulla/agentic-ai v0.0.0
+	github.com/sakibmulla/agentic-ai/settings v0.0.0
+	github.com/spf13/cobra v1.10.1
+)
+
+require (
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/inconshreveable/mousetrap v1.1.0 // indirect
+	github.com/lib/pq v1.10.9 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/ncruces/go-strftime v0.1.9 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
+	github.com/sakibmulla/agentic-ai/costs v0.0.0 // indirect
+	github.com/sakibmulla/agentic-ai/endpoint v0.0.0 // indirect
+	github.com/sakibmulla/agentic-ai/guardrails v0.0.0 // indirect
+	github.com/sakibmulla/agentic-ai/logging v0.0.0 // indirect
+	github.com/sakibmulla/agentic-ai/persist v0.0.0 // indirect
+	github.com/sakibmulla/agentic-ai/tokenizer v0.0.0 // indirect
+	github.com/sakibmulla/agentic-ai/tools v0.0.0 // indirect
+	github.com/sashabaranov/go-openai v1.40.5 // indirect
+	github.com/spf13/pflag v1.0.9 // indirect
+	golang.org/x/sys v0.22.0 // indirect
+	modernc.org/libc v1.55.3 // indirect
+	modernc.org/mathutil v1.6.0 // indirect
+	modernc.org/memory v1.8.0 // indirect
+	modernc.org/sqlite v1.34.5 // indirect
+)
+
+replace chatbot => ../../day-07-chatbot-project
+
+replace day-06-error-handling => ../../day-06-error-handling
+
+replace day04 => ../../day-04-prompt-engineering
+
+replace day05 => ../../day-05-context-memory
+
+replace github.com/sakibmulla/agentic-ai => ../..
+
+replace github.com/sakibmulla/agentic-ai/costs => ../../costs
+
+replace github.com/sakibmulla/agentic-ai/endpoint => ../../endpoint
+
+replace github.com/sakibmulla/agentic-ai/guardrails => ../../guardrails
+
+replace github.com/sakibmulla/agentic-ai/logging => ../../logging
+
+replace github.com/sakibmulla/agentic-ai/persist => ../../persist
+
+replace github.com/sakibmulla/agentic-ai/settings => ../../settings
+
+replace github.com/sakibmulla/agentic-ai/tokenizer => ../../tokenizer
+
+replace github.com/sakibmulla/agentic-ai/tools => ../../tools
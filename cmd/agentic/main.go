@@ -0,0 +1,133 @@
+// Command agentic runs the course's programs from one binary:
+//
+//	agentic [flags] chat|prompts|memory|vectors|resilient [args...]
+//
+// Each subcommand is a day's program, and args are what that program takes
+// when run on its own, e.g. "agentic chat doctor" or "agentic vectors
+// serve-search docs/*.md". The flags come before the subcommand and are
+// shared: they load .env and the config file once and pick the provider
+// and log level for every day, which then doesn't load them again.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/joho/godotenv"
+	"github.com/sakibmulla/agentic-ai/day-08-vector-embeddings/vectors"
+	"github.com/sakibmulla/agentic-ai/settings"
+	"github.com/spf13/cobra"
+
+	"chatbot/cli"
+	"day-06-error-handling/resilient"
+	"day04/prompts"
+	"day05/memory"
+)
+
+// program is a day's program run as a subcommand
+type program struct {
+	name    string
+	summary string
+	run     func(args []string)
+}
+
+// programs run with the environment loaded; see each package's Run
+var programs = []program{
+	{"chat", "Run the day 7 chatbot, or its serve, doctor, compare-models, encrypt-history and config commands", cli.Run},
+	{"prompts", "Run the day 4 prompt engineering demos", prompts.Run},
+	{"memory", "Run the day 5 context and memory demos", memory.Run},
+	{"vectors", "Run the day 8 vector embeddings demo, or serve-search", vectors.Run},
+	{"resilient", "Run the day 6 resilient agent", resilient.Run},
+}
+
+func main() {
+	if err := newRootCommand(programs, os.Stderr).Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// newRootCommand returns the agentic command with a subcommand per program
+func newRootCommand(programs []program, stderr io.Writer) *cobra.Command {
+	var dir, envFile string
+	// Flags naming a variable override it, as the environment overrides .env
+	variables := map[string]*string{}
+
+	root := &cobra.Command{
+		Use:   "agentic [flags] <command> [args...]",
+		Short: "Run the course's programs from one binary",
+		// Flags after the subcommand are the program's, so stop at it
+		TraverseChildren: true,
+		SilenceUsage:     true,
+		// Traversing skips cobra's check for unknown subcommands
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				return fmt.Errorf("unknown command %q for %q", args[0], cmd.CommandPath())
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if !cmd.HasParent() {
+				return nil // only showing help
+			}
+			if dir != "" {
+				if err := os.Chdir(dir); err != nil {
+					return err
+				}
+			}
+			for name, value := range variables {
+				if *value != "" {
+					os.Setenv(name, *value)
+				}
+			}
+			return loadConfig(envFile, cmd.Flags().Changed("env"))
+		},
+	}
+	root.SetOut(stderr)
+	root.SetErr(stderr)
+	root.CompletionOptions.DisableDefaultCmd = true
+
+	flags := root.PersistentFlags()
+	flags.StringVarP(&dir, "dir", "C", "", "run in `dir`, where the day reads its data, .env and config.toml")
+	flags.StringVar(&envFile, "env", ".env", "load environment variables from `file` where they aren't set")
+	for _, flag := range []struct{ name, variable, usage string }{
+		{"config", "CONFIG_FILE", "read settings from `file` instead of config.toml (CONFIG_FILE)"},
+		{"profile", "CONFIG_PROFILE", "use the config file's `profile` (CONFIG_PROFILE)"},
+		{"provider", "LLM_PROVIDER", "the `provider` to call: openai or ollama (LLM_PROVIDER)"},
+		{"log-level", "LOG_LEVEL", "log `level`: debug, info, warn or error (LOG_LEVEL)"},
+		{"log-format", "LOG_FORMAT", "log `format`: text or json (LOG_FORMAT)"},
+	} {
+		variables[flag.variable] = flags.String(flag.name, "", flag.usage)
+	}
+
+	for _, p := range programs {
+		run := p.run
+		root.AddCommand(&cobra.Command{
+			Use:   p.name + " [args...]",
+			Short: p.summary,
+			// The program parses its own arguments, -h included
+			DisableFlagParsing: true,
+			Run: func(cmd *cobra.Command, args []string) {
+				run(args)
+			},
+		})
+	}
+	return root
+}
+
+// loadConfig loads envFile, then the config file, as each day does on its
+// own. A missing .env is only an error when it was named explicitly.
+func loadConfig(envFile string, required bool) error {
+	if err := godotenv.Load(envFile); err != nil && (required || !errors.Is(err, fs.ErrNotExist)) {
+		return fmt.Errorf("failed to load %s: %w", envFile, err)
+	}
+	if err := settings.Load(); err != nil {
+		return fmt.Errorf("invalid config file: %w", err)
+	}
+	return nil
+}
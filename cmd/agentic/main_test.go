@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// recorder is a program table whose programs record their arguments
+func recorder(got *[]string) []program {
+	record := func(name string) func([]string) {
+		return func(args []string) {
+			*got = append([]string{name}, args...)
+		}
+	}
+	return []program{
+		{"chat", "chat", record("chat")},
+		{"vectors", "vectors", record("vectors")},
+	}
+}
+
+// execute runs the agentic command with args, returning its error and output
+func execute(programs []program, args ...string) (error, string) {
+	var stderr bytes.Buffer
+	root := newRootCommand(programs, &stderr)
+	root.SetArgs(args)
+	err := root.Execute()
+	return err, stderr.String()
+}
+
+func TestRunDispatches(t *testing.T) {
+	t.Chdir(t.TempDir())
+	var got []string
+	if err, output := execute(recorder(&got), "vectors", "serve-search", "-x", "docs.md"); err != nil {
+		t.Fatalf("Expected success, got %v: %s", err, output)
+	}
+	// Everything after the command is the program's, flags included
+	if strings.Join(got, " ") != "vectors serve-search -x docs.md" {
+		t.Errorf("Unexpected arguments %q", got)
+	}
+
+	got = nil
+	if err, _ := execute(recorder(&got), "chat", "--provider", "x", "-h"); err != nil || strings.Join(got, " ") != "chat --provider x -h" {
+		t.Errorf("Expected the program's flags passed through, got %q, %v", got, err)
+	}
+}
+
+func TestRunSharedFlags(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(t.TempDir())
+	if err := os.WriteFile(filepath.Join(dir, "custom.env"), []byte("LLM_PROVIDER=openai\nAGENTIC_TEST_VALUE=from-file\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// The default .env isn't read when another is named
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte("AGENTIC_TEST_DEFAULT=from-default\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"LLM_PROVIDER", "LOG_LEVEL", "AGENTIC_TEST_VALUE", "AGENTIC_TEST_DEFAULT"} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+
+	var got []string
+	err, output := execute(recorder(&got), "-C", dir, "--env", "custom.env", "--provider", "ollama", "--log-level", "debug", "chat", "doctor")
+	if err != nil {
+		t.Fatalf("Expected success, got %v: %s", err, output)
+	}
+	if strings.Join(got, " ") != "chat doctor" {
+		t.Errorf("Unexpected arguments %q", got)
+	}
+	if wd, _ := os.Getwd(); filepath.Base(wd) != filepath.Base(dir) {
+		t.Errorf("Expected to run in %s, got %s", dir, wd)
+	}
+	// Flags override .env, which fills in the rest
+	if os.Getenv("LLM_PROVIDER") != "ollama" || os.Getenv("LOG_LEVEL") != "debug" || os.Getenv("AGENTIC_TEST_VALUE") != "from-file" {
+		t.Errorf("Unexpected environment: provider %q, level %q, value %q",
+			os.Getenv("LLM_PROVIDER"), os.Getenv("LOG_LEVEL"), os.Getenv("AGENTIC_TEST_VALUE"))
+	}
+	if value := os.Getenv("AGENTIC_TEST_DEFAULT"); value != "" {
+		t.Errorf("Expected the default .env skipped, got %q", value)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	t.Chdir(t.TempDir())
+	tests := []struct {
+		name   string
+		args   []string
+		err    bool
+		output string
+	}{
+		{"no command", nil, false, "Available Commands:\n  chat"},
+		{"unknown command", []string{"serve"}, true, `unknown command "serve"`},
+		{"unknown flag", []string{"--verbose", "chat"}, true, "unknown flag: --verbose"},
+		{"help", []string{"-h"}, false, "--provider provider"},
+		// Only a .env named explicitly has to exist
+		{"missing env file", []string{"--env", "missing.env", "chat"}, true, "failed to load missing.env"},
+		{"missing dir", []string{"-C", "missing", "chat"}, true, "missing"},
+		{"missing profile", []string{"--profile", "prod", "chat"}, true, "invalid config file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CONFIG_PROFILE", "")
+			var got []string
+			if err, output := execute(recorder(&got), tt.args...); (err != nil) != tt.err || !strings.Contains(output, tt.output) {
+				t.Errorf("Expected error %v with %q, got %v: %s", tt.err, tt.output, err, output)
+			}
+			if got != nil {
+				t.Errorf("Expected no program run, got %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package costs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrBudgetExceeded is returned for a call a budget has no room left for
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// Period is how long a budget lasts
+type Period int
+
+// Budget periods
+const (
+	Daily Period = iota
+	Monthly
+)
+
+func (p Period) String() string {
+	if p == Monthly {
+		return "monthly"
+	}
+	return "daily"
+}
+
+// Limit is a budget in USD per day and per month; zero means no limit
+type Limit struct {
+	Daily   float64 `json:"daily_usd,omitempty"`
+	Monthly float64 `json:"monthly_usd,omitempty"`
+}
+
+// Budget bounds spend overall and for each user, session and template
+type Budget struct {
+	Total       Limit `json:"total"`
+	PerUser     Limit `json:"per_user"`
+	PerSession  Limit `json:"per_session"`
+	PerTemplate Limit `json:"per_template"`
+	// DowngradeAt is the share of a limit (e.g. 0.8) past which chat
+	// requests are sent to their model's entry in Downgrades instead. Zero
+	// never downgrades.
+	DowngradeAt float64 `json:"downgrade_at,omitempty"`
+	// Downgrades maps chat models to cheaper ones, e.g. gpt-4o to
+	// gpt-4o-mini. Embedding models are never downgraded: vectors from
+	// different models can't be compared.
+	Downgrades map[string]string `json:"downgrades,omitempty"`
+}
+
+// ParseBudget parses a budget such as
+// "daily=5,monthly=100,user_daily=0.5,downgrade_at=0.8". Limits are
+// daily and monthly, overall or prefixed with user_, session_ or
+// template_. An empty spec has no limits.
+func ParseBudget(spec string) (Budget, error) {
+	var budget Budget
+	limits := map[string]*Limit{
+		"":          &budget.Total,
+		"user_":     &budget.PerUser,
+		"session_":  &budget.PerSession,
+		"template_": &budget.PerTemplate,
+	}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, raw, ok := strings.Cut(part, "=")
+		if !ok {
+			return Budget{}, fmt.Errorf("invalid budget %q: expected name=value", part)
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil || value < 0 {
+			return Budget{}, fmt.Errorf("invalid budget %q: expected a non-negative number", part)
+		}
+
+		name = strings.TrimSpace(name)
+		if name == "downgrade_at" {
+			if value > 1 {
+				return Budget{}, fmt.Errorf("invalid budget %q: downgrade_at is a share of the limit, 0-1", part)
+			}
+			budget.DowngradeAt = value
+			continue
+		}
+		scope, period := "", name
+		if i := strings.LastIndex(name, "_"); i >= 0 {
+			scope, period = name[:i+1], name[i+1:]
+		}
+		limit, ok := limits[scope]
+		switch {
+		case ok && period == "daily":
+			limit.Daily = value
+		case ok && period == "monthly":
+			limit.Monthly = value
+		default:
+			return Budget{}, fmt.Errorf("unknown budget %q", name)
+		}
+	}
+	return budget, nil
+}
+
+// ParseDowngrades parses model downgrades such as
+// "gpt-4=gpt-3.5-turbo,gpt-4o=gpt-4o-mini"
+func ParseDowngrades(spec string) (map[string]string, error) {
+	downgrades := make(map[string]string)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		from, to, ok := strings.Cut(part, "=")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("invalid downgrade %q: expected model=cheaper-model", part)
+		}
+		downgrades[from] = to
+	}
+	return downgrades, nil
+}
+
+// BudgetError says which budget a call would exceed
+type BudgetError struct {
+	// Scope is "total", "user", "session" or "template"; Key names the
+	// user, session or template
+	Scope  string
+	Key    string
+	Period Period
+	Spent  float64
+	Limit  float64
+}
+
+func (e *BudgetError) Error() string {
+	who := e.Scope
+	if e.Key != "" {
+		who += " " + e.Key
+	}
+	return fmt.Sprintf("%s: %s %s budget of $%.2f spent ($%.4f)", ErrBudgetExceeded, who, e.Period, e.Limit, e.Spent)
+}
+
+func (e *BudgetError) Unwrap() error {
+	return ErrBudgetExceeded
+}
+
+// Limiter keeps calls within a budget and records what they cost
+type Limiter struct {
+	tracker *Tracker
+	budget  Budget
+}
+
+// NewLimiter creates a limiter that enforces budget against tracker's ledger
+func NewLimiter(tracker *Tracker, budget Budget) *Limiter {
+	return &Limiter{tracker: tracker, budget: budget}
+}
+
+// FromEnv creates a limiter configured by the environment: BUDGET (see
+// ParseBudget), BUDGET_DOWNGRADES (see ParseDowngrades) and
+// COST_LEDGER_PATH, where spend is kept across runs. Without them calls
+// are only priced, in memory. PRICING_PATH names a pricing file to load
+// first (see LoadPrices).
+func FromEnv() (*Limiter, error) {
+	if path := os.Getenv("PRICING_PATH"); path != "" {
+		if err := LoadPrices(path); err != nil {
+			return nil, err
+		}
+	}
+	budget, err := ParseBudget(os.Getenv("BUDGET"))
+	if err != nil {
+		return nil, err
+	}
+	if budget.Downgrades, err = ParseDowngrades(os.Getenv("BUDGET_DOWNGRADES")); err != nil {
+		return nil, err
+	}
+	tracker, err := NewTracker(os.Getenv("COST_LEDGER_PATH"))
+	if err != nil {
+		return nil, err
+	}
+	return NewLimiter(tracker, budget), nil
+}
+
+// Tracker returns the ledger the limiter records to
+func (l *Limiter) Tracker() *Tracker {
+	return l.tracker
+}
+
+// Budget returns the limiter's budget
+func (l *Limiter) Budget() Budget {
+	return l.budget
+}
+
+// Check returns a *BudgetError when a budget that applies to ctx's
+// attribution has been spent
+func (l *Limiter) Check(ctx context.Context) error {
+	_, err := l.usage(ctx)
+	return err
+}
+
+// Admit checks the budgets that apply to ctx's attribution and returns the
+// model a chat request should use: model itself, or its downgrade once a
+// budget is DowngradeAt spent
+func (l *Limiter) Admit(ctx context.Context, model string) (string, error) {
+	used, err := l.usage(ctx)
+	if err != nil {
+		return "", err
+	}
+	if cheaper := l.budget.Downgrades[model]; cheaper != "" && l.budget.DowngradeAt > 0 && used >= l.budget.DowngradeAt {
+		return cheaper, nil
+	}
+	return model, nil
+}
+
+// Record prices a call and adds it to the ledger under ctx's attribution,
+// returning its cost. The call is logged with ctx, so it carries the
+// request's correlation ID.
+func (l *Limiter) Record(ctx context.Context, model string, promptTokens, completionTokens int) (float64, error) {
+	usage := Usage{
+		Model:            model,
+		Attribution:      AttributionFrom(ctx),
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		Cost:             Cost(model, promptTokens, completionTokens),
+	}
+	slog.InfoContext(ctx, "model usage",
+		"model", model,
+		"prompt_tokens", promptTokens,
+		"completion_tokens", completionTokens,
+		"cost_usd", usage.Cost,
+		"user", usage.User,
+		"session", usage.Session,
+		"template", usage.Template,
+	)
+	return usage.Cost, l.tracker.Record(usage)
+}
+
+// usage returns the largest share of a budget that applies to ctx's
+// attribution, or a *BudgetError for one that is spent
+func (l *Limiter) usage(ctx context.Context) (float64, error) {
+	a := AttributionFrom(ctx)
+	checks := []struct {
+		scope, key string
+		limit      Limit
+	}{
+		{"total", "", l.budget.Total},
+		{"user", a.User, l.budget.PerUser},
+		{"session", a.Session, l.budget.PerSession},
+		{"template", a.Template, l.budget.PerTemplate},
+	}
+
+	l.tracker.mu.Lock()
+	defer l.tracker.mu.Unlock()
+
+	var most float64
+	for _, check := range checks {
+		if check.scope != "total" && check.key == "" {
+			continue
+		}
+		for _, period := range []Period{Daily, Monthly} {
+			limit := check.limit.Daily
+			if period == Monthly {
+				limit = check.limit.Monthly
+			}
+			if limit <= 0 {
+				continue
+			}
+			spent := l.tracker.spent(period, check.scope, check.key)
+			if spent >= limit {
+				return 0, &BudgetError{Scope: check.scope, Key: check.key, Period: period, Spent: spent, Limit: limit}
+			}
+			most = max(most, spent/limit)
+		}
+	}
+	return most, nil
+}
@@ -0,0 +1,258 @@
+package costs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestCost(t *testing.T) {
+	cases := []struct {
+		model              string
+		prompt, completion int
+		want               float64
+	}{
+		{"gpt-4", 1000, 1000, 0.09},
+		{"gpt-4-0613", 1000, 0, 0.03},
+		{"gpt-4o-mini-2024-07-18", 2000, 1000, 0.0009},
+		{"text-embedding-ada-002", 10000, 0, 0.001},
+		{"llama3", 1000, 1000, 0},
+	}
+	for _, c := range cases {
+		if got := Cost(c.model, c.prompt, c.completion); !near(got, c.want) {
+			t.Errorf("Cost(%s) = %f, want %f", c.model, got, c.want)
+		}
+	}
+	if _, ok := Lookup("gpt-4xl"); ok {
+		t.Error("Expected a model that only shares a prefix to be unknown")
+	}
+}
+
+func TestLoadPrices(t *testing.T) {
+	saved := make(map[string]Price, len(Prices))
+	for model, price := range Prices {
+		saved[model] = price
+	}
+	t.Cleanup(func() { Prices = saved })
+
+	path := filepath.Join(t.TempDir(), "pricing.json")
+	os.WriteFile(path, []byte(`{
+		"gpt-4o": {"prompt_per_1k": 0.0025, "completion_per_1k": 0.01},
+		"mistral-large": {"prompt_per_1k": 0.002, "completion_per_1k": 0.006}
+	}`), 0644)
+	if err := LoadPrices(path); err != nil {
+		t.Fatalf("Failed to load prices: %v", err)
+	}
+	if got := Cost("gpt-4o-2024-08-06", 1000, 1000); !near(got, 0.0125) {
+		t.Errorf("Expected the file's gpt-4o price, got %f", got)
+	}
+	if got := Cost("mistral-large", 1000, 0); !near(got, 0.002) {
+		t.Errorf("Expected a model added by the file, got %f", got)
+	}
+	if got := Cost("gpt-4", 1000, 0); !near(got, 0.03) {
+		t.Errorf("Expected models the file leaves out to keep their price, got %f", got)
+	}
+
+	os.WriteFile(path, []byte(`{"gpt-4": {"prompt_per_1k": -1}}`), 0644)
+	if err := LoadPrices(path); err == nil {
+		t.Error("Expected a negative price to be rejected")
+	}
+	if err := LoadPrices(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Expected a missing pricing file to be reported")
+	}
+}
+
+func TestParseBudget(t *testing.T) {
+	budget, err := ParseBudget("daily=5, monthly=100,user_daily=0.5,session_monthly=2,template_daily=1,downgrade_at=0.8")
+	if err != nil {
+		t.Fatalf("Failed to parse budget: %v", err)
+	}
+	want := Budget{
+		Total:       Limit{Daily: 5, Monthly: 100},
+		PerUser:     Limit{Daily: 0.5},
+		PerSession:  Limit{Monthly: 2},
+		PerTemplate: Limit{Daily: 1},
+		DowngradeAt: 0.8,
+	}
+	if fmt.Sprint(budget) != fmt.Sprint(want) {
+		t.Errorf("Expected %+v, got %+v", want, budget)
+	}
+	for _, spec := range []string{"weekly=5", "user_yearly=1", "daily=-1", "daily", "downgrade_at=2"} {
+		if _, err := ParseBudget(spec); err == nil {
+			t.Errorf("Expected %q to be rejected", spec)
+		}
+	}
+
+	downgrades, err := ParseDowngrades("gpt-4=gpt-3.5-turbo, gpt-4o=gpt-4o-mini")
+	if err != nil || len(downgrades) != 2 || downgrades["gpt-4o"] != "gpt-4o-mini" {
+		t.Errorf("Unexpected downgrades %v, %v", downgrades, err)
+	}
+	if _, err := ParseDowngrades("gpt-4"); err == nil {
+		t.Error("Expected a downgrade without a target to be rejected")
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "costs.json")
+	tracker, err := NewTracker(path)
+	if err != nil {
+		t.Fatalf("Failed to create tracker: %v", err)
+	}
+	now := time.Date(2026, 3, 30, 12, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+	limiter := NewLimiter(tracker, Budget{
+		PerSession:  Limit{Daily: 0.1},
+		Total:       Limit{Monthly: 0.25},
+		DowngradeAt: 0.5,
+		Downgrades:  map[string]string{"gpt-4": "gpt-3.5-turbo"},
+	})
+	alice := WithAttribution(context.Background(), Attribution{User: "alice", Session: "a1"})
+	alice = WithAttribution(alice, Attribution{Template: "summary"})
+	bob := WithAttribution(context.Background(), Attribution{User: "bob", Session: "b1"})
+
+	if model, err := limiter.Admit(alice, "gpt-4"); err != nil || model != "gpt-4" {
+		t.Errorf("Expected gpt-4 within budget, got %s, %v", model, err)
+	}
+	// $0.06: past half of the session's daily budget
+	if cost, err := limiter.Record(alice, "gpt-4", 1000, 500); err != nil || !near(cost, 0.06) {
+		t.Fatalf("Expected to record $0.06, got %f, %v", cost, err)
+	}
+	if model, _ := limiter.Admit(alice, "gpt-4"); model != "gpt-3.5-turbo" {
+		t.Errorf("Expected a downgrade past half the budget, got %s", model)
+	}
+	if model, _ := limiter.Admit(bob, "gpt-4"); model != "gpt-4" {
+		t.Errorf("Expected bob's session to be unaffected, got %s", model)
+	}
+
+	limiter.Record(alice, "gpt-4", 1000, 500)
+	var budgetErr *BudgetError
+	if _, err := limiter.Admit(alice, "gpt-4"); !errors.As(err, &budgetErr) || !errors.Is(err, ErrBudgetExceeded) ||
+		budgetErr.Scope != "session" || budgetErr.Key != "a1" || budgetErr.Period != Daily {
+		t.Errorf("Expected alice's session budget to be spent, got %v", err)
+	}
+
+	// Budgets reset with the period; the monthly one carries until the month ends
+	now = now.Add(time.Hour)
+	limiter.Record(bob, "gpt-4", 2000, 500)
+	now = now.Add(12 * time.Hour)
+	if err := limiter.Check(alice); err != nil {
+		t.Errorf("Expected a new day's session budget, got %v", err)
+	}
+	limiter.Record(bob, "gpt-4", 2000, 0)
+	if err := limiter.Check(bob); !errors.As(err, &budgetErr) || budgetErr.Scope != "total" || budgetErr.Period != Monthly {
+		t.Errorf("Expected the monthly total to be spent, got %v", err)
+	}
+	now = now.Add(24 * time.Hour)
+	if err := limiter.Check(bob); err != nil {
+		t.Errorf("Expected a new month's budget, got %v", err)
+	}
+
+	reloaded, err := NewTracker(path)
+	if err != nil {
+		t.Fatalf("Failed to reload the ledger: %v", err)
+	}
+	reloaded.now = func() time.Time { return time.Date(2026, 3, 30, 0, 0, 0, 0, time.UTC) }
+	month := reloaded.ThisMonth()
+	if month.Period != "2026-03" || month.Total.Requests != 4 || !near(month.Total.Cost, 0.27) ||
+		!near(month.Users["bob"].Cost, 0.15) || month.Templates["summary"].Requests != 2 || month.Models["gpt-4"].PromptTokens != 6000 {
+		t.Errorf("Unexpected monthly spend after reload: %+v", month)
+	}
+	if day := reloaded.Today(); day.Period != "2026-03-30" || day.Total.Requests != 3 || day.Sessions["a1"].Requests != 2 {
+		t.Errorf("Unexpected daily spend after reload: %+v", day)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	var models []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model    string          `json:"model"`
+			Stream   bool            `json:"stream"`
+			Messages json.RawMessage `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		models = append(models, req.Model)
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/embeddings"):
+			fmt.Fprint(w, `{"data":[{"embedding":[1,0]}],"usage":{"prompt_tokens":1000,"total_tokens":1000}}`)
+		case req.Stream:
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\n")
+			fmt.Fprint(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":500,\"completion_tokens\":500}}\n\n")
+			fmt.Fprint(w, "data: [DONE]\n\n")
+		case len(req.Messages) == 0:
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":{"message":"messages required"}}`)
+		default:
+			fmt.Fprint(w, `{"choices":[{"message":{"content":"hi"}}],"usage":{"prompt_tokens":1000,"completion_tokens":1000}}`)
+		}
+	}))
+	defer api.Close()
+
+	tracker, _ := NewTracker("")
+	limiter := NewLimiter(tracker, Budget{
+		PerUser:     Limit{Daily: 0.2},
+		DowngradeAt: 0.4,
+		Downgrades:  map[string]string{"gpt-4": "gpt-3.5-turbo", "text-embedding-3-large": "text-embedding-3-small"},
+	})
+	client := limiter.HTTPClient()
+	ctx := WithAttribution(context.Background(), Attribution{User: "alice"})
+	post := func(path, body string) (*http.Response, error) {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodPost, api.URL+path, strings.NewReader(body))
+		resp, err := client.Do(req)
+		if err == nil {
+			io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		return resp, err
+	}
+
+	if _, err := post("/v1/chat/completions", `{"model":"gpt-4","messages":[{"role":"user","content":"hi"}]}`); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if _, err := post("/v1/embeddings", `{"model":"text-embedding-3-large","input":["hi"]}`); err != nil {
+		t.Fatalf("Embedding failed: %v", err)
+	}
+	// $0.09 is past 40% of the budget: gpt-4 becomes gpt-3.5-turbo, but
+	// embeddings keep their model
+	if _, err := post("/v1/chat/completions", `{"model":"gpt-4","stream":true,"messages":[{"role":"user","content":"hi"}]}`); err != nil {
+		t.Fatalf("Streamed chat failed: %v", err)
+	}
+	post("/v1/embeddings", `{"model":"text-embedding-3-large","input":["hi"]}`)
+	if resp, _ := post("/v1/chat/completions", `{"model":"gpt-4"}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected the API's error to pass through, got %d", resp.StatusCode)
+	}
+	if fmt.Sprint(models) != "[gpt-4 text-embedding-3-large gpt-3.5-turbo text-embedding-3-large gpt-3.5-turbo]" {
+		t.Errorf("Unexpected models sent: %v", models)
+	}
+
+	day := tracker.Today()
+	if day.Total.Requests != 4 || day.Models["gpt-3.5-turbo"].PromptTokens != 500 || day.Models["text-embedding-3-large"].Requests != 2 || day.Users["alice"].Requests != 4 {
+		t.Errorf("Unexpected spend: %+v", day)
+	}
+
+	limiter.Record(ctx, "gpt-4", 4000, 0)
+	if _, err := post("/v1/chat/completions", `{"model":"gpt-4","messages":[]}`); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("Expected the spent budget to refuse the chat, got %v", err)
+	}
+	if _, err := post("/v1/embeddings", `{"model":"text-embedding-3-large","input":["hi"]}`); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("Expected the spent budget to refuse the embedding, got %v", err)
+	}
+	if len(models) != 5 {
+		t.Errorf("Expected refused calls not to reach the API, got %v", models)
+	}
+}
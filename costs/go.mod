@@ -0,0 +1,7 @@
+module github.com/sakibmulla/agentic-ai/costs
+
+go 1.21
+
+require github.com/sakibmulla/agentic-ai/persist v0.0.0
+
+replace github.com/sakibmulla/agentic-ai/persist => ../persist
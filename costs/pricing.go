@@ -0,0 +1,96 @@
+// Package costs prices model calls, keeps a ledger of what they cost by
+// user, session and prompt template, and enforces daily and monthly
+// budgets. Its Transport plugs into the HTTP client of an OpenAI client, so
+// every chat completion and embedding request is budgeted and priced
+// without changing the code that makes it.
+package costs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Price is a model's USD price per 1K tokens. Embedding models only have a
+// prompt price.
+type Price struct {
+	PromptPer1K     float64 `json:"prompt_per_1k"`
+	CompletionPer1K float64 `json:"completion_per_1k"`
+}
+
+// pricesMu guards Prices once LoadPrices may replace entries
+var pricesMu sync.RWMutex
+
+// Prices holds list prices for the models used in this course. Prices
+// change over time; treat these as estimates, and keep them current with a
+// pricing file (see LoadPrices).
+var Prices = map[string]Price{
+	"gpt-3.5-turbo":          {PromptPer1K: 0.0015, CompletionPer1K: 0.002},
+	"gpt-4":                  {PromptPer1K: 0.03, CompletionPer1K: 0.06},
+	"gpt-4-turbo":            {PromptPer1K: 0.01, CompletionPer1K: 0.03},
+	"gpt-4o":                 {PromptPer1K: 0.005, CompletionPer1K: 0.015},
+	"gpt-4o-mini":            {PromptPer1K: 0.00015, CompletionPer1K: 0.0006},
+	"text-embedding-ada-002": {PromptPer1K: 0.0001},
+	"text-embedding-3-small": {PromptPer1K: 0.00002},
+	"text-embedding-3-large": {PromptPer1K: 0.00013},
+}
+
+// Lookup returns a model's price, matching dated variants (e.g.
+// gpt-4-0613) by their longest known prefix
+func Lookup(model string) (Price, bool) {
+	pricesMu.RLock()
+	defer pricesMu.RUnlock()
+	if price, ok := Prices[model]; ok {
+		return price, true
+	}
+
+	best := ""
+	for name := range Prices {
+		if strings.HasPrefix(model, name+"-") && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return Price{}, false
+	}
+	return Prices[best], true
+}
+
+// Cost returns the USD cost of a call to model. Models without a price,
+// such as ones served locally, cost nothing.
+func Cost(model string, promptTokens, completionTokens int) float64 {
+	price, _ := Lookup(model)
+	return float64(promptTokens)/1000*price.PromptPer1K + float64(completionTokens)/1000*price.CompletionPer1K
+}
+
+// LoadPrices reads a JSON pricing file mapping model names to prices,
+//
+//	{"gpt-4o": {"prompt_per_1k": 0.0025, "completion_per_1k": 0.01}}
+//
+// and adds them to Prices, replacing the built-in price of any model it
+// names. Prices can then follow the provider's price list without a
+// rebuild.
+func LoadPrices(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read pricing file: %w", err)
+	}
+	var prices map[string]Price
+	if err := json.Unmarshal(data, &prices); err != nil {
+		return fmt.Errorf("invalid pricing file %s: %w", path, err)
+	}
+	for model, price := range prices {
+		if price.PromptPer1K < 0 || price.CompletionPer1K < 0 {
+			return fmt.Errorf("invalid price for %s in %s: prices can't be negative", model, path)
+		}
+	}
+
+	pricesMu.Lock()
+	defer pricesMu.Unlock()
+	for model, price := range prices {
+		Prices[model] = price
+	}
+	return nil
+}
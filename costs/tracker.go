@@ -0,0 +1,277 @@
+package costs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sakibmulla/agentic-ai/persist"
+)
+
+// keepDays is how many days of daily spend the ledger keeps
+const keepDays = 62
+
+// ledgerFormat versions the ledger file
+var ledgerFormat = persist.NewFormat("cost_ledger", 1)
+
+// Attribution says whom a call is spent on
+type Attribution struct {
+	User     string `json:"user,omitempty"`
+	Session  string `json:"session,omitempty"`
+	Template string `json:"template,omitempty"`
+}
+
+type attributionKey struct{}
+
+// WithAttribution returns a context whose calls are spent on a. Fields left
+// empty keep the attribution already in ctx.
+func WithAttribution(ctx context.Context, a Attribution) context.Context {
+	current := AttributionFrom(ctx)
+	if a.User == "" {
+		a.User = current.User
+	}
+	if a.Session == "" {
+		a.Session = current.Session
+	}
+	if a.Template == "" {
+		a.Template = current.Template
+	}
+	return context.WithValue(ctx, attributionKey{}, a)
+}
+
+// AttributionFrom returns whom calls made with ctx are spent on
+func AttributionFrom(ctx context.Context) Attribution {
+	a, _ := ctx.Value(attributionKey{}).(Attribution)
+	return a
+}
+
+// Usage is one priced call
+type Usage struct {
+	Model string
+	Attribution
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64
+}
+
+// Totals sums the usage of some calls
+type Totals struct {
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Cost             float64 `json:"cost_usd"`
+}
+
+func (t *Totals) add(u Usage) {
+	t.Requests++
+	t.PromptTokens += u.PromptTokens
+	t.CompletionTokens += u.CompletionTokens
+	t.Cost += u.Cost
+}
+
+// Spend is the usage in one day or month, by model, user, session and
+// template
+type Spend struct {
+	Period    string             `json:"period"`
+	Total     Totals             `json:"total"`
+	Models    map[string]*Totals `json:"models,omitempty"`
+	Users     map[string]*Totals `json:"users,omitempty"`
+	Sessions  map[string]*Totals `json:"sessions,omitempty"`
+	Templates map[string]*Totals `json:"templates,omitempty"`
+}
+
+func (s *Spend) add(u Usage) {
+	s.Total.add(u)
+	addTo(&s.Models, u.Model, u)
+	addTo(&s.Users, u.User, u)
+	addTo(&s.Sessions, u.Session, u)
+	addTo(&s.Templates, u.Template, u)
+}
+
+// addTo adds u to the totals for key, unless key is empty
+func addTo(totals *map[string]*Totals, key string, u Usage) {
+	if key == "" {
+		return
+	}
+	if *totals == nil {
+		*totals = make(map[string]*Totals)
+	}
+	if (*totals)[key] == nil {
+		(*totals)[key] = &Totals{}
+	}
+	(*totals)[key].add(u)
+}
+
+// copy returns a deep copy of s, safe to hand out
+func (s *Spend) copy() Spend {
+	copied := Spend{Period: s.Period, Total: s.Total}
+	for _, m := range []struct {
+		from map[string]*Totals
+		to   *map[string]*Totals
+	}{
+		{s.Models, &copied.Models},
+		{s.Users, &copied.Users},
+		{s.Sessions, &copied.Sessions},
+		{s.Templates, &copied.Templates},
+	} {
+		if m.from == nil {
+			continue
+		}
+		*m.to = make(map[string]*Totals, len(m.from))
+		for key, totals := range m.from {
+			t := *totals
+			(*m.to)[key] = &t
+		}
+	}
+	return copied
+}
+
+// ledger is the persisted spend, keyed by day (2006-01-02) and month (2006-01)
+type ledger struct {
+	Days   map[string]*Spend `json:"days"`
+	Months map[string]*Spend `json:"months"`
+}
+
+// Tracker keeps a ledger of spend by day and month, optionally persisted
+// to a file so budgets hold across restarts
+type Tracker struct {
+	path string
+	now  func() time.Time
+
+	mu   sync.Mutex
+	data ledger
+}
+
+// NewTracker creates a tracker, loading the ledger saved at path. An empty
+// path keeps the ledger in memory only.
+func NewTracker(path string) (*Tracker, error) {
+	t := &Tracker{
+		path: path,
+		now:  time.Now,
+		data: ledger{Days: make(map[string]*Spend), Months: make(map[string]*Spend)},
+	}
+	if path == "" {
+		return t, nil
+	}
+
+	var saved ledger
+	err := ledgerFormat.ReadFile(path, &saved)
+	if errors.Is(err, os.ErrNotExist) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cost ledger: %w", err)
+	}
+	for day, spend := range saved.Days {
+		t.data.Days[day] = spend
+	}
+	for month, spend := range saved.Months {
+		t.data.Months[month] = spend
+	}
+	return t, nil
+}
+
+// Record adds a call to today's and this month's spend, pricing it when
+// its cost isn't given
+func (t *Tracker) Record(u Usage) error {
+	if u.Cost == 0 {
+		u.Cost = Cost(u.Model, u.PromptTokens, u.CompletionTokens)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	day, month := now.Format("2006-01-02"), now.Format("2006-01")
+	if t.data.Days[day] == nil {
+		t.data.Days[day] = &Spend{Period: day}
+		t.prune(now)
+	}
+	if t.data.Months[month] == nil {
+		t.data.Months[month] = &Spend{Period: month}
+	}
+	t.data.Days[day].add(u)
+	t.data.Months[month].add(u)
+	return t.save()
+}
+
+// Today returns today's spend
+func (t *Tracker) Today() Spend {
+	return t.spend(Daily)
+}
+
+// ThisMonth returns this month's spend
+func (t *Tracker) ThisMonth() Spend {
+	return t.spend(Monthly)
+}
+
+// spend returns the current day's or month's spend
+func (t *Tracker) spend(period Period) Spend {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key, periods := t.current(period)
+	if spend := periods[key]; spend != nil {
+		return spend.copy()
+	}
+	return Spend{Period: key}
+}
+
+// current returns the key of the current day or month and the ledger's
+// spend for that period. Callers must hold t.mu.
+func (t *Tracker) current(period Period) (string, map[string]*Spend) {
+	if period == Monthly {
+		return t.now().Format("2006-01"), t.data.Months
+	}
+	return t.now().Format("2006-01-02"), t.data.Days
+}
+
+// spent returns what a scope has spent in the current day or month: all
+// calls for "total", otherwise those of one user, session or template.
+// Callers must hold t.mu.
+func (t *Tracker) spent(period Period, scope, key string) float64 {
+	current, periods := t.current(period)
+	spend := periods[current]
+	if spend == nil {
+		return 0
+	}
+	var totals map[string]*Totals
+	switch scope {
+	case "total":
+		return spend.Total.Cost
+	case "user":
+		totals = spend.Users
+	case "session":
+		totals = spend.Sessions
+	case "template":
+		totals = spend.Templates
+	}
+	if totals[key] == nil {
+		return 0
+	}
+	return totals[key].Cost
+}
+
+// prune drops days older than keepDays. Callers must hold t.mu.
+func (t *Tracker) prune(now time.Time) {
+	oldest := now.AddDate(0, 0, -keepDays).Format("2006-01-02")
+	for day := range t.data.Days {
+		if day < oldest {
+			delete(t.data.Days, day)
+		}
+	}
+}
+
+// save persists the ledger. Callers must hold t.mu.
+func (t *Tracker) save() error {
+	if t.path == "" {
+		return nil
+	}
+	if err := ledgerFormat.WriteFile(t.path, t.data, 0644); err != nil {
+		return fmt.Errorf("failed to write cost ledger: %w", err)
+	}
+	return nil
+}
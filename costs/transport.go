@@ -0,0 +1,195 @@
+package costs
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// Transport budgets and prices the OpenAI API calls made through it. Chat
+// completions may be downgraded to a cheaper model, and are refused with
+// ErrBudgetExceeded once a budget is spent, as are embeddings. Other
+// requests pass straight through. Calls are attributed with WithAttribution
+// on the request's context.
+type Transport struct {
+	limiter *Limiter
+	base    http.RoundTripper
+}
+
+// NewTransport creates a transport that sends requests through base, or
+// http.DefaultTransport when base is nil
+func NewTransport(limiter *Limiter, base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{limiter: limiter, base: base}
+}
+
+// HTTPClient returns an HTTP client whose calls go through the limiter, for
+// an OpenAI client's config:
+//
+//	config := openai.DefaultConfig(apiKey)
+//	config.HTTPClient = limiter.HTTPClient()
+//	client := openai.NewClientWithConfig(config)
+func (l *Limiter) HTTPClient() *http.Client {
+	return &http.Client{Transport: NewTransport(l, nil)}
+}
+
+// apiRequest is the part of a chat or embedding request the transport reads
+type apiRequest struct {
+	Model  string `json:"model"`
+	Stream bool   `json:"stream"`
+}
+
+// apiUsage is the usage an API response reports
+type apiUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// RoundTrip budgets the request, sends it and records what it cost
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	chat := strings.HasSuffix(req.URL.Path, "/chat/completions")
+	if !chat && !strings.HasSuffix(req.URL.Path, "/embeddings") || req.Body == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	var call apiRequest
+	if err := json.Unmarshal(body, &call); err != nil {
+		return t.base.RoundTrip(withBody(req, body))
+	}
+
+	ctx := req.Context()
+	if chat {
+		model, err := t.limiter.Admit(ctx, call.Model)
+		if err != nil {
+			return nil, err
+		}
+		if model != call.Model {
+			if body, err = setModel(body, model); err != nil {
+				return nil, err
+			}
+			call.Model = model
+		}
+	} else if err := t.limiter.Check(ctx); err != nil {
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(withBody(req, body))
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	record := func(usage apiUsage) {
+		if _, err := t.limiter.Record(ctx, call.Model, usage.PromptTokens, usage.CompletionTokens); err != nil {
+			slog.WarnContext(ctx, "failed to record model usage", "model", call.Model, "error", err)
+		}
+	}
+
+	if call.Stream {
+		resp.Body = &streamBody{ReadCloser: resp.Body, record: record}
+		return resp, nil
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	var reply struct {
+		Usage apiUsage `json:"usage"`
+	}
+	if json.Unmarshal(data, &reply) == nil {
+		record(reply.Usage)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	resp.ContentLength = int64(len(data))
+	return resp, nil
+}
+
+// withBody returns a copy of req sending body
+func withBody(req *http.Request, body []byte) *http.Request {
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return req
+}
+
+// setModel rewrites the model a request body asks for, leaving the rest as sent
+func setModel(body []byte, model string) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(model)
+	if err != nil {
+		return nil, err
+	}
+	fields["model"] = encoded
+	return json.Marshal(fields)
+}
+
+// streamBody records a streamed completion once it has been read. The API
+// only reports a stream's usage when asked to with stream_options; without
+// it the call is counted with no tokens.
+type streamBody struct {
+	io.ReadCloser
+	record func(apiUsage)
+
+	pending  []byte
+	usage    apiUsage
+	recorded bool
+}
+
+func (s *streamBody) Read(p []byte) (int, error) {
+	n, err := s.ReadCloser.Read(p)
+	s.scan(p[:n])
+	if err == io.EOF {
+		s.finish()
+	}
+	return n, err
+}
+
+func (s *streamBody) Close() error {
+	s.finish()
+	return s.ReadCloser.Close()
+}
+
+// scan looks for usage in the complete server-sent events in data
+func (s *streamBody) scan(data []byte) {
+	s.pending = append(s.pending, data...)
+	i := bytes.LastIndexByte(s.pending, '\n')
+	if i < 0 {
+		return
+	}
+	for _, line := range bytes.Split(s.pending[:i], []byte("\n")) {
+		line, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data: "))
+		if !ok || !bytes.Contains(line, []byte(`"usage"`)) {
+			continue
+		}
+		var chunk struct {
+			Usage *apiUsage `json:"usage"`
+		}
+		if json.Unmarshal(line, &chunk) == nil && chunk.Usage != nil {
+			s.usage = *chunk.Usage
+		}
+	}
+	s.pending = append(s.pending[:0], s.pending[i+1:]...)
+}
+
+// finish records the stream once
+func (s *streamBody) finish() {
+	if !s.recorded {
+		s.recorded = true
+		s.record(s.usage)
+	}
+}
@@ -4,11 +4,15 @@ import (
 	"bufio"
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"strings"
 
 	"github.com/joho/godotenv"
+	"github.com/sakibmulla/agentic-ai/costs"
+	"github.com/sakibmulla/agentic-ai/endpoint"
+	"github.com/sakibmulla/agentic-ai/logging"
+	"github.com/sakibmulla/agentic-ai/settings"
 	"github.com/sashabaranov/go-openai"
 )
 
@@ -18,11 +22,13 @@ type AIClient struct {
 	model  string
 }
 
-// NewAIClient creates a new AI client instance
-func NewAIClient(apiKey string) *AIClient {
-	client := openai.NewClient(apiKey)
+// NewAIClient creates a new AI client instance that calls api, with calls
+// budgeted and priced by limiter
+func NewAIClient(apiKey string, api endpoint.Endpoint, limiter *costs.Limiter) *AIClient {
+	config := api.Config(apiKey)
+	config.HTTPClient = api.HTTPClient(limiter.HTTPClient())
 	return &AIClient{
-		client: client,
+		client: openai.NewClientWithConfig(config),
 		model:  openai.GPT3Dot5Turbo, // Using GPT-3.5-turbo for cost efficiency
 	}
 }
@@ -66,26 +72,57 @@ func (ai *AIClient) ValidateSetup(ctx context.Context) error {
 	return nil
 }
 
+// configKeys lists the settings this day reads, for `config show` and
+// `config validate`
+var configKeys = settings.Common
+
 func main() {
-	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using system environment variables")
+	// Load environment variables, then the config file's CONFIG_PROFILE,
+	// which doesn't replace them
+	envErr := godotenv.Load()
+	settingsErr := settings.Load()
+	// LOG_LEVEL and LOG_FORMAT may come from .env or the config file
+	logging.Setup(slog.LevelWarn)
+	if envErr != nil {
+		slog.Info("no .env file found, using system environment variables")
+	}
+	if settingsErr != nil {
+		logging.Fatal("invalid config file", "error", settingsErr)
+	}
+	// "config show" and "config validate" report the settings instead of running
+	if run, err := configKeys.Start(os.Args[1:], os.Stdout); err != nil {
+		logging.Fatal("invalid configuration", "error", err)
+	} else if !run {
+		return
+	}
+
+	// OPENAI_BASE_URL and OPENAI_API_TYPE point the client at Azure OpenAI
+	// or an OpenAI-compatible server such as vLLM
+	api, err := endpoint.FromEnv()
+	if err != nil {
+		logging.Fatal("invalid endpoint", "error", err)
 	}
 
 	// Get OpenAI API key
 	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		log.Fatal("OPENAI_API_KEY environment variable is required")
+	if apiKey == "" && api.RequiresKey() {
+		logging.Fatal("OPENAI_API_KEY environment variable is required")
 	}
 
 	// Create AI client
-	aiClient := NewAIClient(apiKey)
+	// Budgets, the cost ledger and prices come from BUDGET, BUDGET_DOWNGRADES,
+	// COST_LEDGER_PATH and PRICING_PATH
+	limiter, err := costs.FromEnv()
+	if err != nil {
+		logging.Fatal("invalid budget", "error", err)
+	}
+	aiClient := NewAIClient(apiKey, api, limiter)
 
 	// Validate setup
 	ctx := context.Background()
 	fmt.Println("🔍 Validating setup...")
 	if err := aiClient.ValidateSetup(ctx); err != nil {
-		log.Fatalf("Setup validation failed: %v", err)
+		logging.Fatal("setup validation failed", "error", err)
 	}
 	fmt.Println("✅ Setup validated successfully!")
 
@@ -124,6 +161,6 @@ func main() {
 	}
 
 	if err := scanner.Err(); err != nil {
-		log.Printf("Error reading input: %v", err)
+		slog.Error("failed to read input", "error", err)
 	}
 }
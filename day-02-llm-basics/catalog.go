@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sakibmulla/agentic-ai/endpoint"
+	"github.com/sakibmulla/agentic-ai/persist"
+	"github.com/sashabaranov/go-openai"
+)
+
+// Capabilities are what a model supports
+type Capabilities struct {
+	// ContextLength is how many tokens the prompt and answer share
+	ContextLength int `json:"context_length"`
+	// MaxOutputTokens is the longest answer the model writes
+	MaxOutputTokens int  `json:"max_output_tokens"`
+	FunctionCalling bool `json:"function_calling"`
+	Vision          bool `json:"vision"`
+	JSONMode        bool `json:"json_mode"`
+}
+
+// knownCapabilities annotates the models OpenAI lists, which its model list
+// doesn't describe. Dated variants (e.g. gpt-4o-2024-08-06) match their
+// longest listed prefix.
+var knownCapabilities = map[string]Capabilities{
+	"gpt-3.5-turbo":              {ContextLength: 16385, MaxOutputTokens: 4096, FunctionCalling: true, JSONMode: true},
+	"gpt-3.5-turbo-16k":          {ContextLength: 16385, MaxOutputTokens: 4096, FunctionCalling: true},
+	"gpt-4":                      {ContextLength: 8192, MaxOutputTokens: 8192, FunctionCalling: true},
+	"gpt-4-32k":                  {ContextLength: 32768, MaxOutputTokens: 8192, FunctionCalling: true},
+	"gpt-4-1106-preview":         {ContextLength: 128000, MaxOutputTokens: 4096, FunctionCalling: true, JSONMode: true},
+	"gpt-4-0125-preview":         {ContextLength: 128000, MaxOutputTokens: 4096, FunctionCalling: true, JSONMode: true},
+	"gpt-4-turbo-preview":        {ContextLength: 128000, MaxOutputTokens: 4096, FunctionCalling: true, JSONMode: true},
+	"gpt-4-turbo":                {ContextLength: 128000, MaxOutputTokens: 4096, FunctionCalling: true, Vision: true, JSONMode: true},
+	"gpt-4o":                     {ContextLength: 128000, MaxOutputTokens: 16384, FunctionCalling: true, Vision: true, JSONMode: true},
+	"gpt-4o-mini":                {ContextLength: 128000, MaxOutputTokens: 16384, FunctionCalling: true, Vision: true, JSONMode: true},
+	"gpt-4o-search-preview":      {ContextLength: 128000, MaxOutputTokens: 16384},
+	"gpt-4o-mini-search-preview": {ContextLength: 128000, MaxOutputTokens: 16384},
+	"gpt-4.1":                    {ContextLength: 1047576, MaxOutputTokens: 32768, FunctionCalling: true, Vision: true, JSONMode: true},
+	"gpt-4.1-mini":               {ContextLength: 1047576, MaxOutputTokens: 32768, FunctionCalling: true, Vision: true, JSONMode: true},
+	"gpt-4.1-nano":               {ContextLength: 1047576, MaxOutputTokens: 32768, FunctionCalling: true, Vision: true, JSONMode: true},
+	"o1":                         {ContextLength: 200000, MaxOutputTokens: 100000, FunctionCalling: true, Vision: true, JSONMode: true},
+	"o1-mini":                    {ContextLength: 128000, MaxOutputTokens: 65536},
+	"o3-mini":                    {ContextLength: 200000, MaxOutputTokens: 100000, FunctionCalling: true, JSONMode: true},
+}
+
+// defaultCapabilities are assumed for chat models knownCapabilities doesn't
+// list, such as local ones: a modest window, and tools, which most current
+// chat models take
+var defaultCapabilities = Capabilities{ContextLength: 8192, MaxOutputTokens: 2048, FunctionCalling: true}
+
+// nonChatMarkers pick out the models in a provider's list that don't chat,
+// such as embedding, speech and image models, and completion-only ones like
+// gpt-3.5-turbo-instruct. Search models such as gpt-4o-search-preview do
+// chat; only the legacy text-search embedding models don't.
+var nonChatMarkers = []string{"embed", "whisper", "tts", "dall-e", "moderation", "davinci", "babbage", "transcribe", "image", "realtime", "audio", "instruct", "text-search"}
+
+// capabilitiesFor returns what model supports, and whether it is known
+// rather than assumed
+func capabilitiesFor(model string) (Capabilities, bool) {
+	if capabilities, ok := knownCapabilities[model]; ok {
+		return capabilities, true
+	}
+	best := ""
+	for name := range knownCapabilities {
+		if strings.HasPrefix(model, name+"-") && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return defaultCapabilities, false
+	}
+	return knownCapabilities[best], true
+}
+
+// isChatModel reports whether a listed model chats
+func isChatModel(model string) bool {
+	for _, marker := range nonChatMarkers {
+		if strings.Contains(model, marker) {
+			return false
+		}
+	}
+	return true
+}
+
+// CatalogModel is a chat model an endpoint serves
+type CatalogModel struct {
+	ID      string `json:"id"`
+	OwnedBy string `json:"owned_by,omitempty"`
+	Capabilities
+	// Known says the capabilities come from knownCapabilities rather than
+	// defaultCapabilities
+	Known bool `json:"known"`
+}
+
+// Where a catalog's models came from
+const (
+	SourceProvider = "provider"
+	SourceCache    = "cache"
+	SourceBuiltin  = "built-in"
+)
+
+// catalogFormat versions the cached catalog
+var catalogFormat = persist.NewFormat("model_catalog", 1)
+
+// Catalog lists the chat models an endpoint serves, with their capabilities
+type Catalog struct {
+	Endpoint  string         `json:"endpoint"`
+	FetchedAt time.Time      `json:"fetched_at"`
+	Models    []CatalogModel `json:"models"`
+	// Source is SourceProvider, SourceCache or SourceBuiltin. A built-in
+	// catalog lists the known models without asking the endpoint, so it
+	// can't rule a model out.
+	Source string `json:"-"`
+}
+
+// DefaultCatalogTTL is how long a cached catalog is used before the
+// provider is asked again
+const DefaultCatalogTTL = 24 * time.Hour
+
+// catalogTimeout bounds the call listing the provider's models
+const catalogTimeout = 10 * time.Second
+
+// catalogPath is where the catalog is cached: $MODEL_CATALOG_PATH, or
+// agentic-ai/model_catalog.json under the user's cache directory
+func catalogPath() string {
+	if path := os.Getenv("MODEL_CATALOG_PATH"); path != "" {
+		return path
+	}
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(cache, "agentic-ai", "model_catalog.json")
+}
+
+// catalogTTLFromEnv reads MODEL_CATALOG_TTL_HOURS, defaulting to
+// DefaultCatalogTTL; 0 asks the provider every run
+func catalogTTLFromEnv() (time.Duration, error) {
+	text := os.Getenv("MODEL_CATALOG_TTL_HOURS")
+	if text == "" {
+		return DefaultCatalogTTL, nil
+	}
+	hours, err := strconv.Atoi(text)
+	if err != nil || hours < 0 {
+		return 0, fmt.Errorf("MODEL_CATALOG_TTL_HOURS must be a whole number of hours, got %q", text)
+	}
+	return time.Duration(hours) * time.Hour, nil
+}
+
+// BuiltinCatalog lists the models in knownCapabilities
+func BuiltinCatalog(endpointName string) *Catalog {
+	catalog := &Catalog{Endpoint: endpointName, Source: SourceBuiltin}
+	for id, capabilities := range knownCapabilities {
+		catalog.Models = append(catalog.Models, CatalogModel{ID: id, Capabilities: capabilities, Known: true})
+	}
+	catalog.sort()
+	return catalog
+}
+
+// LoadCatalog returns the chat models api serves, from the catalog cached
+// at path while it is younger than ttl, or else by listing the provider's
+// models and caching the result. An empty path caches nothing. Azure
+// deployments can't be listed this way, so Azure gets the built-in catalog.
+// When the list can't be fetched, a stale cached catalog is used, or else
+// the built-in one, and the error is returned with it.
+func LoadCatalog(ctx context.Context, client *openai.Client, api endpoint.Endpoint, path string, ttl time.Duration) (*Catalog, error) {
+	name := api.String()
+	if api.IsAzure() {
+		return BuiltinCatalog(name), nil
+	}
+
+	var cached *Catalog
+	if path != "" {
+		var saved Catalog
+		err := catalogFormat.ReadFile(path, &saved)
+		switch {
+		case err == nil && saved.Endpoint == name:
+			saved.Source = SourceCache
+			if time.Since(saved.FetchedAt) < ttl {
+				return &saved, nil
+			}
+			cached = &saved
+		case err != nil && !errors.Is(err, os.ErrNotExist):
+			slog.Warn("ignoring unreadable model catalog cache", "path", path, "error", err)
+		}
+	}
+
+	listCtx, cancel := context.WithTimeout(ctx, catalogTimeout)
+	defer cancel()
+	list, err := client.ListModels(listCtx)
+	if err != nil {
+		err = fmt.Errorf("failed to list models from %s: %w", name, err)
+		if cached != nil {
+			return cached, err
+		}
+		return BuiltinCatalog(name), err
+	}
+
+	catalog := &Catalog{Endpoint: name, FetchedAt: time.Now(), Source: SourceProvider}
+	for _, model := range list.Models {
+		if !isChatModel(model.ID) {
+			continue
+		}
+		capabilities, known := capabilitiesFor(model.ID)
+		catalog.Models = append(catalog.Models, CatalogModel{ID: model.ID, OwnedBy: model.OwnedBy, Capabilities: capabilities, Known: known})
+	}
+	catalog.sort()
+	if path != "" {
+		if err := catalogFormat.WriteFile(path, catalog, 0644); err != nil {
+			slog.Warn("failed to cache the model catalog", "path", path, "error", err)
+		}
+	}
+	return catalog, nil
+}
+
+func (c *Catalog) sort() {
+	sort.Slice(c.Models, func(i, j int) bool { return c.Models[i].ID < c.Models[j].ID })
+}
+
+// Lookup returns the listed model named id. A name without a tag also
+// matches its :latest tag, as Ollama lists them.
+func (c *Catalog) Lookup(id string) (CatalogModel, bool) {
+	for _, model := range c.Models {
+		if model.ID == id || !strings.Contains(id, ":") && model.ID == id+":latest" {
+			return model, true
+		}
+	}
+	return CatalogModel{}, false
+}
+
+// Resolve returns the configuration for chatting with model, refusing one
+// the endpoint doesn't serve. A built-in catalog can't tell, so it allows
+// any model, with assumed capabilities for one it doesn't know.
+func (c *Catalog) Resolve(model string) (ModelConfig, error) {
+	if !isChatModel(model) {
+		return ModelConfig{}, fmt.Errorf("%s is not a chat model", model)
+	}
+	listed, ok := c.Lookup(model)
+	if !ok {
+		if c.Source != SourceBuiltin {
+			return ModelConfig{}, fmt.Errorf("%s doesn't serve %s; choose one of: %s", c.Endpoint, model, strings.Join(c.IDs(), ", "))
+		}
+		capabilities, _ := capabilitiesFor(model)
+		return ModelConfig{Name: model, Capabilities: capabilities}, nil
+	}
+	return ModelConfig{Name: model, Capabilities: listed.Capabilities}, nil
+}
+
+// IDs returns the names of the listed models
+func (c *Catalog) IDs() []string {
+	ids := make([]string, len(c.Models))
+	for i, model := range c.Models {
+		ids[i] = model.ID
+	}
+	return ids
+}
+
+// Describe summarizes capabilities, e.g. "128K context, 16K output; tools,
+// vision, JSON"
+func (c Capabilities) Describe() string {
+	text := fmt.Sprintf("%s context, %s output", formatTokens(c.ContextLength), formatTokens(c.MaxOutputTokens))
+	var features []string
+	if c.FunctionCalling {
+		features = append(features, "tools")
+	}
+	if c.Vision {
+		features = append(features, "vision")
+	}
+	if c.JSONMode {
+		features = append(features, "JSON")
+	}
+	if len(features) > 0 {
+		text += "; " + strings.Join(features, ", ")
+	}
+	return text
+}
+
+// Describe summarizes the model's capabilities, noting when they are
+// assumed
+func (m CatalogModel) Describe() string {
+	if !m.Known {
+		return m.Capabilities.Describe() + " (assumed)"
+	}
+	return m.Capabilities.Describe()
+}
+
+// formatTokens writes a token count in thousands, e.g. 128K
+func formatTokens(n int) string {
+	if n < 1000 {
+		return strconv.Itoa(n)
+	}
+	return strconv.Itoa(n/1000) + "K"
+}
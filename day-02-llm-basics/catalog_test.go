@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sakibmulla/agentic-ai/endpoint"
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestCapabilitiesFor(t *testing.T) {
+	tests := []struct {
+		model         string
+		contextLength int
+		tools         bool
+		known         bool
+	}{
+		{"gpt-4o", 128000, true, true},
+		{"gpt-4o-2024-08-06", 128000, true, true},
+		{"gpt-4o-mini-2024-07-18", 128000, true, true},
+		{"gpt-4-0613", 8192, true, true},
+		{"gpt-4-turbo-2024-04-09", 128000, true, true},
+		{"gpt-3.5-turbo-0125", 16385, true, true},
+		{"gpt-4o-search-preview-2025-03-11", 128000, false, true},
+		{"o1-mini-2024-09-12", 128000, false, true},
+		// A shared prefix without a dash isn't a dated variant
+		{"gpt-4oo", 8192, true, false},
+		{"llama3.1:latest", 8192, true, false},
+	}
+	for _, tt := range tests {
+		capabilities, known := capabilitiesFor(tt.model)
+		if capabilities.ContextLength != tt.contextLength || capabilities.FunctionCalling != tt.tools || known != tt.known {
+			t.Errorf("capabilitiesFor(%q) = %+v, %v; expected %d context, tools %v, known %v",
+				tt.model, capabilities, known, tt.contextLength, tt.tools, tt.known)
+		}
+	}
+}
+
+func TestIsChatModel(t *testing.T) {
+	tests := []struct {
+		model string
+		chat  bool
+	}{
+		{"gpt-4o", true},
+		{"gpt-4o-search-preview", true},
+		{"gpt-4o-mini-search-preview-2025-03-11", true},
+		{"o3-mini", true},
+		{"llama3.1:latest", true},
+		{"gpt-3.5-turbo-instruct", false},
+		{"gpt-3.5-turbo-instruct-0914", false},
+		{"text-embedding-3-small", false},
+		{"nomic-embed-text", false},
+		{"text-search-ada-doc-001", false},
+		{"whisper-1", false},
+		{"tts-1-hd", false},
+		{"dall-e-3", false},
+		{"gpt-image-1", false},
+		{"omni-moderation-latest", false},
+		{"davinci-002", false},
+		{"gpt-4o-realtime-preview", false},
+		{"gpt-4o-audio-preview", false},
+		{"gpt-4o-transcribe", false},
+	}
+	for _, tt := range tests {
+		if got := isChatModel(tt.model); got != tt.chat {
+			t.Errorf("isChatModel(%q) = %v, expected %v", tt.model, got, tt.chat)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	provider := &Catalog{Endpoint: "api.openai.com", Source: SourceProvider, Models: []CatalogModel{
+		{ID: "gpt-4o", Capabilities: knownCapabilities["gpt-4o"], Known: true},
+		{ID: "llama3.1:latest", Capabilities: defaultCapabilities},
+	}}
+	builtin := BuiltinCatalog("api.openai.com")
+
+	tests := []struct {
+		name    string
+		catalog *Catalog
+		model   string
+		context int
+		err     string
+	}{
+		{"listed", provider, "gpt-4o", 128000, ""},
+		{"latest tag", provider, "llama3.1", 8192, ""},
+		{"not served", provider, "gpt-4.1", 0, "doesn't serve gpt-4.1; choose one of: gpt-4o, llama3.1:latest"},
+		{"not chat", provider, "text-embedding-3-small", 0, "not a chat model"},
+		{"built-in known", builtin, "gpt-4o-2024-08-06", 128000, ""},
+		{"built-in unknown", builtin, "mistral", 8192, ""},
+		{"built-in not chat", builtin, "gpt-3.5-turbo-instruct", 0, "not a chat model"},
+	}
+	for _, tt := range tests {
+		config, err := tt.catalog.Resolve(tt.model)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.err, err)
+			}
+			continue
+		}
+		if err != nil || config.Name != tt.model || config.ContextLength != tt.context {
+			t.Errorf("%s: Resolve(%q) = %+v, %v; expected %d context", tt.name, tt.model, config, err, tt.context)
+		}
+	}
+}
+
+// newModelServer serves a model list, or fails while *failing is set, and
+// counts the requests it gets
+func newModelServer(t *testing.T, failing *bool) (*openai.Client, endpoint.Endpoint, *int) {
+	t.Helper()
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if *failing {
+			http.Error(w, `{"error":{"message":"unavailable"}}`, http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"object":"list","data":[
+			{"id":"gpt-4o-2024-08-06","owned_by":"system"},
+			{"id":"gpt-4o-search-preview","owned_by":"system"},
+			{"id":"gpt-3.5-turbo-instruct","owned_by":"system"},
+			{"id":"text-embedding-3-small","owned_by":"system"},
+			{"id":"llama3.1:latest","owned_by":"library"}]}`)
+	}))
+	t.Cleanup(server.Close)
+	api := endpoint.Endpoint{BaseURL: server.URL + "/v1"}
+	return openai.NewClientWithConfig(api.Config("test-key")), api, &requests
+}
+
+func TestLoadCatalog(t *testing.T) {
+	ctx := context.Background()
+	failing := false
+	client, api, requests := newModelServer(t, &failing)
+	path := filepath.Join(t.TempDir(), "model_catalog.json")
+
+	catalog, err := LoadCatalog(ctx, client, api, path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"gpt-4o-2024-08-06", "gpt-4o-search-preview", "llama3.1:latest"}
+	if catalog.Source != SourceProvider || strings.Join(catalog.IDs(), ",") != strings.Join(want, ",") {
+		t.Fatalf("Expected the provider's chat models %v, got %s %v", want, catalog.Source, catalog.IDs())
+	}
+	if model, _ := catalog.Lookup("gpt-4o-2024-08-06"); !model.Known || model.ContextLength != 128000 || model.OwnedBy != "system" {
+		t.Errorf("Expected a dated model annotated from its prefix, got %+v", model)
+	}
+
+	// Within the TTL the cache answers without asking the provider
+	catalog, err = LoadCatalog(ctx, client, api, path, time.Hour)
+	if err != nil || catalog.Source != SourceCache || len(catalog.Models) != 3 || *requests != 1 {
+		t.Fatalf("Expected the cached catalog and 1 request, got %v, %v and %d requests", catalog, err, *requests)
+	}
+
+	// Past the TTL the provider is asked again
+	if catalog, err = LoadCatalog(ctx, client, api, path, 0); err != nil || catalog.Source != SourceProvider || *requests != 2 {
+		t.Fatalf("Expected a fresh listing, got %v, %v and %d requests", catalog, err, *requests)
+	}
+
+	// A cache for another endpoint isn't used
+	other := endpoint.Endpoint{BaseURL: "http://localhost:1/v1"}
+	if catalog, err = LoadCatalog(ctx, openai.NewClientWithConfig(other.Config("test-key")), other, path, time.Hour); err == nil || catalog.Source != SourceBuiltin {
+		t.Errorf("Expected the built-in catalog for an unreachable endpoint, got %v, %v", catalog, err)
+	}
+}
+
+func TestLoadCatalogFallback(t *testing.T) {
+	ctx := context.Background()
+	failing := false
+	client, api, _ := newModelServer(t, &failing)
+	path := filepath.Join(t.TempDir(), "model_catalog.json")
+	if _, err := LoadCatalog(ctx, client, api, path, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	// A stale cache is used when the provider can't be reached
+	failing = true
+	catalog, err := LoadCatalog(ctx, client, api, path, 0)
+	if err == nil || catalog.Source != SourceCache || len(catalog.Models) != 3 {
+		t.Errorf("Expected the stale cache with an error, got %v, %v", catalog, err)
+	}
+
+	// Without a cache the built-in catalog is used
+	catalog, err = LoadCatalog(ctx, client, api, "", time.Hour)
+	if err == nil || catalog.Source != SourceBuiltin || len(catalog.Models) != len(knownCapabilities) {
+		t.Errorf("Expected the built-in catalog with an error, got %v, %v", catalog, err)
+	}
+
+	// Azure deployments can't be listed, so the provider isn't asked
+	azure := endpoint.Endpoint{Type: endpoint.TypeAzure, BaseURL: "https://example.openai.azure.com"}
+	if catalog, err = LoadCatalog(ctx, client, azure, path, time.Hour); err != nil || catalog.Source != SourceBuiltin {
+		t.Errorf("Expected the built-in catalog for Azure, got %v, %v", catalog, err)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sakibmulla/agentic-ai/tokenizer"
+	"github.com/sakibmulla/agentic-ai/tools"
+	"github.com/sashabaranov/go-openai"
+)
+
+// toolCallOverhead is how many tokens a tool call adds beyond its name and
+// arguments, for its ID and delimiters
+const toolCallOverhead = 3
+
+// renderTools writes tool definitions the way OpenAI shows them to the
+// model, as TypeScript in the system prompt, so their tokens can be
+// counted:
+//
+//	namespace functions {
+//
+//	// Analyze text and provide statistics like word count, ...
+//	type analyze_text = (_: {
+//	// The text to analyze
+//	text: string,
+//	}) => any;
+//
+//	} // namespace functions
+func renderTools(definitions []tools.Definition) string {
+	var b strings.Builder
+	b.WriteString("# Tools\n\n## functions\n\nnamespace functions {\n\n")
+	for _, definition := range definitions {
+		if definition.Description != "" {
+			fmt.Fprintf(&b, "// %s\n", definition.Description)
+		}
+		fmt.Fprintf(&b, "type %s = (_: {\n", definition.Name)
+		required := make(map[string]bool)
+		for _, name := range definition.Parameters.Required {
+			required[name] = true
+		}
+		names := make([]string, 0, len(definition.Parameters.Properties))
+		for name := range definition.Parameters.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property := definition.Parameters.Properties[name]
+			if property.Description != "" {
+				fmt.Fprintf(&b, "// %s\n", property.Description)
+			}
+			optional := "?"
+			if required[name] {
+				optional = ""
+			}
+			fmt.Fprintf(&b, "%s%s: %s,\n", name, optional, typeScript(property))
+		}
+		b.WriteString("}) => any;\n\n")
+	}
+	b.WriteString("} // namespace functions")
+	return b.String()
+}
+
+// typeScript returns a parameter's type as renderTools writes it
+func typeScript(schema tools.Schema) string {
+	switch {
+	case len(schema.Enum) > 0:
+		return `"` + strings.Join(schema.Enum, `" | "`) + `"`
+	case schema.Type == tools.Array && schema.Items != nil:
+		return typeScript(*schema.Items) + "[]"
+	case schema.Type == tools.Integer:
+		return "number"
+	case schema.Type == "":
+		return "any"
+	}
+	return string(schema.Type)
+}
+
+// countTools returns the prompt tokens tool definitions add to every
+// request that offers them
+func (c *AdvancedLLMClient) countTools(definitions []tools.Definition) int {
+	if len(definitions) == 0 {
+		return 0
+	}
+	return c.CountTokens(renderTools(definitions)) + tokenizer.MessageOverhead
+}
+
+// countToolCalls returns the tokens a message's tool calls take up
+func (c *AdvancedLLMClient) countToolCalls(calls []openai.ToolCall) int {
+	tokens := 0
+	for _, call := range calls {
+		tokens += c.CountTokens(call.Function.Name) + c.CountTokens(call.Function.Arguments) + toolCallOverhead
+	}
+	return tokens
+}
+
+// countMessages returns the prompt tokens messages take up, including the
+// reply's priming, and how many of them are tool calls and tool results
+func (c *AdvancedLLMClient) countMessages(messages []openai.ChatCompletionMessage) (total, tool int) {
+	for _, message := range messages {
+		tokens := c.CountTokens(message.Content) + tokenizer.MessageOverhead
+		if message.Name != "" {
+			tokens += c.CountTokens(message.Name)
+		}
+		calls := c.countToolCalls(message.ToolCalls)
+		total += tokens + calls
+		tool += calls
+		if message.Role == openai.ChatMessageRoleTool {
+			tool += tokens
+		}
+	}
+	// The reply is primed with its own role header
+	return total + tokenizer.MessageOverhead, tool
+}
+
+// streamEstimate counts the tokens of one streamed round with the
+// tokenizer, for when the API doesn't report them
+type streamEstimate struct {
+	promptTokens int
+	// toolTokens are the tokens spent on tool definitions, calls and
+	// results, whichever way the round is priced
+	toolTokens int
+}
+
+// estimateRound counts the prompt of a round that sends messages and
+// offers definitions
+func (c *AdvancedLLMClient) estimateRound(messages []openai.ChatCompletionMessage, definitions []tools.Definition) streamEstimate {
+	prompt, tool := c.countMessages(messages)
+	definitionTokens := c.countTools(definitions)
+	return streamEstimate{promptTokens: prompt + definitionTokens, toolTokens: tool + definitionTokens}
+}
+
+// ToolOverhead returns the prompt tokens the registered tools add to every
+// streamed request, none when the model can't call them
+func (c *AdvancedLLMClient) ToolOverhead() int {
+	if !c.config.FunctionCalling {
+		return 0
+	}
+	return c.countTools(c.tools.Definitions())
+}
@@ -3,13 +3,22 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/sakibmulla/agentic-ai/costs"
+	"github.com/sakibmulla/agentic-ai/endpoint"
+	"github.com/sakibmulla/agentic-ai/logging"
+	"github.com/sakibmulla/agentic-ai/settings"
+	"github.com/sakibmulla/agentic-ai/tokenizer"
+	"github.com/sakibmulla/agentic-ai/tools"
 	"github.com/sashabaranov/go-openai"
 )
 
@@ -21,44 +30,45 @@ const (
 	// We'll add more providers in future days
 )
 
-// ModelConfig holds model-specific configuration
+// ModelConfig holds model-specific configuration, from the model catalog;
+// prices come from the costs package
 type ModelConfig struct {
-	Name         string
-	MaxTokens    int
-	TokenCost    float64 // Cost per 1000 tokens
-	ContextLimit int
+	Name string
+	Capabilities
 }
 
-// PredefinedModels contains configuration for common models
-var PredefinedModels = map[string]ModelConfig{
-	"gpt-3.5-turbo": {
-		Name:         "gpt-3.5-turbo",
-		MaxTokens:    4096,
-		TokenCost:    0.002, // $0.002 per 1K tokens
-		ContextLimit: 4096,
-	},
-	"gpt-4": {
-		Name:         "gpt-4",
-		MaxTokens:    8192,
-		TokenCost:    0.03, // $0.03 per 1K tokens
-		ContextLimit: 8192,
-	},
-	"gpt-4-turbo": {
-		Name:         "gpt-4-turbo-preview",
-		MaxTokens:    4096,
-		TokenCost:    0.01, // $0.01 per 1K tokens
-		ContextLimit: 128000,
-	},
-}
+// defaultModel is offered when the endpoint serves it
+const defaultModel = "gpt-3.5-turbo"
+
+// ErrContextExceeded is returned for a prompt that leaves the model no room
+// to answer
+var ErrContextExceeded = errors.New("prompt exceeds the context window")
 
-// Usage tracks API usage statistics
+// Usage tracks API usage statistics. The totals cover chat and embedding
+// calls; the fields after StartTime break them down.
 type Usage struct {
 	TotalTokens   int
 	TotalRequests int
 	TotalCost     float64
 	StartTime     time.Time
+	// PromptTokens and CompletionTokens are the chat calls' tokens
+	PromptTokens     int
+	CompletionTokens int
+	// EmbeddingRequests and EmbeddingTokens are the embedding calls'
+	EmbeddingRequests int
+	EmbeddingTokens   int
+	// ToolTokens is the part of the chat tokens spent on tool definitions,
+	// calls and results, counted with the tokenizer
+	ToolTokens int
+	// EstimatedRequests counts streamed calls the API reported no usage
+	// for, whose tokens were counted with the tokenizer instead
+	EstimatedRequests int
 }
 
+// defaultEmbeddingModel is what Embed asks for; Ollama serves its own
+// embedding model in its place
+const defaultEmbeddingModel = openai.SmallEmbedding3
+
 // AdvancedLLMClient provides enhanced LLM capabilities
 type AdvancedLLMClient struct {
 	client    *openai.Client
@@ -66,24 +76,39 @@ type AdvancedLLMClient struct {
 	usage     *Usage
 	retryMax  int
 	retryWait time.Duration
+	// tools may be called by the model in streamed answers
+	tools *tools.Registry
+	// usageMu guards usage, which concurrent streams update
+	usageMu sync.Mutex
+	// tokens counts tokens with the model's encoding
+	tokens tokenizer.TokenCounter
+	// embedModel is the model Embed uses
+	embedModel openai.EmbeddingModel
 }
 
-// NewAdvancedLLMClient creates a new advanced LLM client
-func NewAdvancedLLMClient(apiKey string, modelName string) *AdvancedLLMClient {
-	config, exists := PredefinedModels[modelName]
-	if !exists {
-		log.Printf("Unknown model %s, using default gpt-3.5-turbo", modelName)
-		config = PredefinedModels["gpt-3.5-turbo"]
+// NewAdvancedLLMClient creates a new advanced LLM client that calls api
+// with the model config describes, as resolved by a Catalog. Its calls are
+// budgeted and priced by limiter, when given.
+func NewAdvancedLLMClient(apiKey string, api endpoint.Endpoint, config ModelConfig, limiter *costs.Limiter) *AdvancedLLMClient {
+	clientConfig := api.Config(apiKey)
+	// Ollama's requests are sent with its local models, limiter or not
+	httpClient := &http.Client{}
+	if limiter != nil {
+		httpClient = limiter.HTTPClient()
 	}
+	clientConfig.HTTPClient = api.HTTPClient(httpClient)
 
 	return &AdvancedLLMClient{
-		client: openai.NewClient(apiKey),
+		client: openai.NewClientWithConfig(clientConfig),
 		config: config,
 		usage: &Usage{
 			StartTime: time.Now(),
 		},
-		retryMax:  3,
-		retryWait: time.Second,
+		retryMax:   3,
+		retryWait:  time.Second,
+		tools:      tools.NewRegistry(),
+		tokens:     tokenizer.ForModel(config.Name),
+		embedModel: defaultEmbeddingModel,
 	}
 }
 
@@ -105,7 +130,7 @@ func (c *AdvancedLLMClient) ChatWithRetry(ctx context.Context, message string, s
 		lastErr = err
 
 		// Don't retry on certain errors
-		if strings.Contains(err.Error(), "invalid_request_error") {
+		if strings.Contains(err.Error(), "invalid_request_error") || errors.Is(err, costs.ErrBudgetExceeded) || errors.Is(err, ErrContextExceeded) {
 			break
 		}
 	}
@@ -113,25 +138,36 @@ func (c *AdvancedLLMClient) ChatWithRetry(ctx context.Context, message string, s
 	return "", fmt.Errorf("failed after %d retries: %w", c.retryMax, lastErr)
 }
 
-// chat performs the actual API call
-func (c *AdvancedLLMClient) chat(ctx context.Context, message string, systemPrompt string) (string, error) {
+// newMessages starts a conversation with the system prompt and message
+func (c *AdvancedLLMClient) newMessages(message string, systemPrompt string) []openai.ChatCompletionMessage {
 	if systemPrompt == "" {
 		systemPrompt = "You are a helpful AI assistant specializing in agentic AI and Go programming."
 	}
 
-	req := openai.ChatCompletionRequest{
-		Model: c.config.Name,
-		Messages: []openai.ChatCompletionMessage{
-			{
-				Role:    openai.ChatMessageRoleSystem,
-				Content: systemPrompt,
-			},
-			{
-				Role:    openai.ChatMessageRoleUser,
-				Content: message,
-			},
+	return []openai.ChatCompletionMessage{
+		{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: systemPrompt,
 		},
-		MaxTokens:   c.config.MaxTokens,
+		{
+			Role:    openai.ChatMessageRoleUser,
+			Content: message,
+		},
+	}
+}
+
+// chat performs the actual API call
+func (c *AdvancedLLMClient) chat(ctx context.Context, message string, systemPrompt string) (string, error) {
+	messages := c.newMessages(message, systemPrompt)
+	promptTokens, _ := c.countMessages(messages)
+	maxTokens, err := c.maxTokens(promptTokens)
+	if err != nil {
+		return "", err
+	}
+	req := openai.ChatCompletionRequest{
+		Model:       c.config.Name,
+		Messages:    messages,
+		MaxTokens:   maxTokens,
 		Temperature: 0.7,
 	}
 
@@ -145,109 +181,248 @@ func (c *AdvancedLLMClient) chat(ctx context.Context, message string, systemProm
 	}
 
 	// Update usage statistics
-	c.updateUsage(resp.Usage)
+	c.updateUsage(resp.Model, resp.Usage, 0, false)
 
 	return resp.Choices[0].Message.Content, nil
 }
 
-// ChatStream handles streaming responses
+// ChatStream prints a streamed answer as it arrives, along with the tools
+// the model calls along the way
 func (c *AdvancedLLMClient) ChatStream(ctx context.Context, message string, systemPrompt string) error {
-	if systemPrompt == "" {
-		systemPrompt = "You are a helpful AI assistant specializing in agentic AI and Go programming."
+	fmt.Print("AI: ")
+	for event := range c.StreamEvents(ctx, message, systemPrompt) {
+		switch event.Type {
+		case EventContentDelta:
+			fmt.Print(event.Content)
+		case EventToolCallStarted:
+			fmt.Printf("\n🔧 Calling %s...", event.ToolCall.Function.Name)
+		case EventToolResult:
+			if event.Err != nil {
+				fmt.Printf("\n❌ %s failed: %v\n", event.ToolCall.Function.Name, event.Err)
+			} else {
+				fmt.Printf("\n✅ %s%s → %s\n", event.ToolCall.Function.Name, event.ToolCall.Function.Arguments, event.Result)
+			}
+		case EventDone:
+			fmt.Println()
+		case EventError:
+			fmt.Println()
+			return event.Err
+		}
 	}
 
-	req := openai.ChatCompletionRequest{
-		Model: c.config.Name,
-		Messages: []openai.ChatCompletionMessage{
-			{
-				Role:    openai.ChatMessageRoleSystem,
-				Content: systemPrompt,
-			},
-			{
-				Role:    openai.ChatMessageRoleUser,
-				Content: message,
-			},
-		},
-		MaxTokens:   c.config.MaxTokens,
-		Temperature: 0.7,
-		Stream:      true,
-	}
+	return ctx.Err()
+}
 
-	stream, err := c.client.CreateChatCompletionStream(ctx, req)
+// Embed returns the embeddings of inputs, adding the call to the usage
+// statistics. Servers that don't report usage have the inputs counted with
+// the embedding model's encoding.
+func (c *AdvancedLLMClient) Embed(ctx context.Context, inputs []string) ([][]float32, error) {
+	resp, err := c.client.CreateEmbeddings(ctx, openai.EmbeddingRequest{Input: inputs, Model: c.embedModel})
 	if err != nil {
-		return fmt.Errorf("failed to create stream: %w", err)
+		return nil, fmt.Errorf("embedding call failed: %w", err)
+	}
+	if len(resp.Data) != len(inputs) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(inputs), len(resp.Data))
 	}
-	defer stream.Close()
 
-	fmt.Print("AI: ")
-	for {
-		response, err := stream.Recv()
-		if err != nil {
-			if err.Error() == "EOF" {
-				break
-			}
-			return fmt.Errorf("stream error: %w", err)
+	model := string(resp.Model)
+	if model == "" {
+		model = string(c.embedModel)
+	}
+	tokens, estimated := resp.Usage.PromptTokens, false
+	if tokens == 0 {
+		encoding := tokenizer.ForModel(model)
+		for _, input := range inputs {
+			tokens += encoding.Count(input)
 		}
+		estimated = true
+	}
+	c.usageMu.Lock()
+	c.usage.TotalTokens += tokens
+	c.usage.TotalRequests++
+	c.usage.TotalCost += costs.Cost(model, tokens, 0)
+	c.usage.EmbeddingRequests++
+	c.usage.EmbeddingTokens += tokens
+	if estimated {
+		c.usage.EstimatedRequests++
+	}
+	c.usageMu.Unlock()
 
-		if len(response.Choices) > 0 {
-			delta := response.Choices[0].Delta.Content
-			fmt.Print(delta)
+	vectors := make([][]float32, len(resp.Data))
+	for _, data := range resp.Data {
+		if data.Index < 0 || data.Index >= len(vectors) {
+			return nil, fmt.Errorf("unexpected embedding index %d", data.Index)
 		}
+		vectors[data.Index] = data.Embedding
 	}
-	fmt.Println()
-
-	return nil
+	return vectors, nil
 }
 
-// updateUsage updates usage statistics
-func (c *AdvancedLLMClient) updateUsage(usage openai.Usage) {
-	c.usage.TotalTokens += usage.TotalTokens
+// updateUsage updates usage statistics with a chat call answered by model,
+// which may be a cheaper one than configured when a budget runs low.
+// toolTokens of its tokens went to tools; estimated says they were counted
+// with the tokenizer rather than reported.
+func (c *AdvancedLLMClient) updateUsage(model string, usage openai.Usage, toolTokens int, estimated bool) {
+	if model == "" {
+		model = c.config.Name
+	}
+	c.usageMu.Lock()
+	defer c.usageMu.Unlock()
+	c.usage.TotalTokens += usage.PromptTokens + usage.CompletionTokens
 	c.usage.TotalRequests++
-	c.usage.TotalCost += float64(usage.TotalTokens) * c.config.TokenCost / 1000
+	c.usage.TotalCost += costs.Cost(model, usage.PromptTokens, usage.CompletionTokens)
+	c.usage.PromptTokens += usage.PromptTokens
+	c.usage.CompletionTokens += usage.CompletionTokens
+	c.usage.ToolTokens += min(toolTokens, usage.PromptTokens+usage.CompletionTokens)
+	if estimated {
+		c.usage.EstimatedRequests++
+	}
 }
 
 // GetUsageStats returns current usage statistics
 func (c *AdvancedLLMClient) GetUsageStats() Usage {
+	c.usageMu.Lock()
+	defer c.usageMu.Unlock()
 	return *c.usage
 }
 
-// EstimateCost estimates the cost for a given number of tokens
+// EstimateCost estimates the cost of sending a given number of prompt tokens
 func (c *AdvancedLLMClient) EstimateCost(tokens int) float64 {
-	return float64(tokens) * c.config.TokenCost / 1000
+	return costs.Cost(c.config.Name, tokens, 0)
+}
+
+// maxTokens returns how long an answer may be after a prompt of
+// promptTokens: the model's output limit, or what is left of its context
+// window when that is less
+func (c *AdvancedLLMClient) maxTokens(promptTokens int) (int, error) {
+	room := c.config.ContextLength - promptTokens
+	if room <= 0 {
+		return 0, fmt.Errorf("%w: about %d prompt tokens leave no room to answer in %s's %d-token window", ErrContextExceeded, promptTokens, c.config.Name, c.config.ContextLength)
+	}
+	return min(c.config.MaxOutputTokens, room), nil
 }
 
+// CountTokens counts the tokens text takes up with the model's encoding
+func (c *AdvancedLLMClient) CountTokens(text string) int {
+	return c.tokens.Count(text)
+}
+
+// EstimatePromptCost counts the prompt tokens sending message would use,
+// including the system prompt and per-message overhead, and what they cost
+func (c *AdvancedLLMClient) EstimatePromptCost(message, systemPrompt string) (int, float64) {
+	// The reply is primed with its own role header
+	tokens := tokenizer.MessageOverhead + c.CountTokens(message) + tokenizer.MessageOverhead
+	if systemPrompt != "" {
+		tokens += c.CountTokens(systemPrompt) + tokenizer.MessageOverhead
+	}
+	return tokens, c.EstimateCost(tokens)
+}
+
+// configKeys lists the settings this day reads, for `config show` and
+// `config validate`
+var configKeys = settings.Common.With(
+	settings.Key{Name: "STREAM_ADDR", Kind: settings.String},
+	settings.Key{Name: "MODEL_CATALOG_PATH", Kind: settings.String},
+	settings.Key{Name: "MODEL_CATALOG_TTL_HOURS", Kind: settings.Int},
+)
+
 func main() {
-	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using system environment variables")
+	// Load environment variables, then the config file's CONFIG_PROFILE,
+	// which doesn't replace them
+	envErr := godotenv.Load()
+	settingsErr := settings.Load()
+	// LOG_LEVEL and LOG_FORMAT may come from .env or the config file
+	logging.Setup(slog.LevelWarn)
+	if envErr != nil {
+		slog.Info("no .env file found, using system environment variables")
+	}
+	if settingsErr != nil {
+		logging.Fatal("invalid config file", "error", settingsErr)
+	}
+	// "config show" and "config validate" report the settings instead of running
+	if run, err := configKeys.Start(os.Args[1:], os.Stdout); err != nil {
+		logging.Fatal("invalid configuration", "error", err)
+	} else if !run {
+		return
+	}
+
+	// OPENAI_BASE_URL and OPENAI_API_TYPE point the client at Azure OpenAI
+	// or an OpenAI-compatible server such as vLLM
+	api, err := endpoint.FromEnv()
+	if err != nil {
+		logging.Fatal("invalid endpoint", "error", err)
 	}
 
 	// Get OpenAI API key
 	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		log.Fatal("OPENAI_API_KEY environment variable is required")
+	if apiKey == "" && api.RequiresKey() {
+		logging.Fatal("OPENAI_API_KEY environment variable is required")
+	}
+
+	// Budgets, the cost ledger and prices come from BUDGET, BUDGET_DOWNGRADES,
+	// COST_LEDGER_PATH and PRICING_PATH
+	limiter, err := costs.FromEnv()
+	if err != nil {
+		logging.Fatal("invalid budget", "error", err)
+	}
+
+	// The models on offer come from the endpoint's model list, cached at
+	// MODEL_CATALOG_PATH for MODEL_CATALOG_TTL_HOURS
+	ctx := context.Background()
+	ttl, err := catalogTTLFromEnv()
+	if err != nil {
+		logging.Fatal("invalid model catalog", "error", err)
+	}
+	catalog, err := LoadCatalog(ctx, openai.NewClientWithConfig(api.Config(apiKey)), api, catalogPath(), ttl)
+	if err != nil {
+		slog.Warn("using a model catalog that may be out of date", "source", catalog.Source, "error", err)
 	}
 
-	// Create advanced LLM client
-	fmt.Println("Available models:")
-	for name, config := range PredefinedModels {
-		fmt.Printf("- %s (Cost: $%.4f per 1K tokens)\n", name, config.TokenCost)
+	fmt.Printf("Available models (%s, from the %s):\n", api, catalog.Source)
+	for _, model := range catalog.Models {
+		price, _ := costs.Lookup(model.ID)
+		fmt.Printf("- %s (%s; $%.4f/$%.4f per 1K prompt/completion tokens)\n", model.ID, model.Describe(), price.PromptPer1K, price.CompletionPer1K)
 	}
 
-	fmt.Print("\nSelect model (default: gpt-3.5-turbo): ")
+	// Ollama serves whichever model OLLAMA_MODEL names
+	suggested := defaultModel
+	if api.IsOllama() {
+		suggested = api.ChatModel
+	} else if _, ok := catalog.Lookup(defaultModel); !ok && len(catalog.Models) > 0 {
+		suggested = catalog.Models[0].ID
+	}
+	fmt.Printf("\nSelect model (default: %s): ", suggested)
 	scanner := bufio.NewScanner(os.Stdin)
 	scanner.Scan()
 	modelName := strings.TrimSpace(scanner.Text())
 	if modelName == "" {
-		modelName = "gpt-3.5-turbo"
+		modelName = suggested
+	}
+	config, err := catalog.Resolve(modelName)
+	if err != nil {
+		logging.Fatal("invalid model", "error", err)
 	}
 
-	client := NewAdvancedLLMClient(apiKey, modelName)
-	ctx := context.Background()
+	client := NewAdvancedLLMClient(apiKey, api, config, limiter)
+	client.Tools().MustRegister(tools.Calculator(), tools.TextAnalysis())
 
-	fmt.Printf("\n🤖 Advanced LLM Client using %s\n", client.config.Name)
-	fmt.Println("Features: Retry logic, usage tracking, streaming")
-	fmt.Println("Commands: 'stream <message>' for streaming, 'stats' for usage, 'quit' to exit")
+	// Optionally serve resumable streams over SSE and WebSocket
+	if addr := os.Getenv("STREAM_ADDR"); addr != "" {
+		hub := NewStreamHub(client, defaultResumeTTL)
+		go func() {
+			fmt.Printf("Streaming on http://%s/stream (SSE) and ws://%s/ws\n", addr, addr)
+			if err := http.ListenAndServe(addr, hub.StreamHandler()); err != nil {
+				slog.Error("streaming server stopped", "error", err)
+			}
+		}()
+	}
+
+	fmt.Printf("\n🤖 Advanced LLM Client using %s (%s)\n", client.config.Name, config.Describe())
+	fmt.Println("Features: Retry logic, usage tracking, streaming with tool calls")
+	fmt.Println("Commands: 'stream <message>' for streaming, 'estimate <message>' for prompt cost, 'embed <text>' for an embedding, 'stats' for usage, 'quit' to exit")
+	if enc := tokenizer.ForModel(client.config.Name); !enc.Exact() {
+		fmt.Printf("⚠️  No %s.tiktoken in %s; token counts are estimates\n", enc.Name(), tokenizer.DataDir())
+	}
 	fmt.Println()
 
 	for {
@@ -269,12 +444,38 @@ func main() {
 			stats := client.GetUsageStats()
 			fmt.Printf("📊 Usage Statistics:\n")
 			fmt.Printf("   Requests: %d\n", stats.TotalRequests)
-			fmt.Printf("   Tokens: %d\n", stats.TotalTokens)
+			fmt.Printf("   Tokens: %d (%d prompt, %d completion, %d embedding)\n", stats.TotalTokens, stats.PromptTokens, stats.CompletionTokens, stats.EmbeddingTokens)
+			fmt.Printf("   Tool Overhead: %d tokens\n", stats.ToolTokens)
+			if stats.EstimatedRequests > 0 {
+				fmt.Printf("   Counted Locally: %d requests reported no usage\n", stats.EstimatedRequests)
+			}
 			fmt.Printf("   Estimated Cost: $%.4f\n", stats.TotalCost)
+			today := limiter.Tracker().Today()
+			fmt.Printf("   Spent Today: $%.4f over %d requests\n", today.Total.Cost, today.Total.Requests)
 			fmt.Printf("   Session Time: %v\n", time.Since(stats.StartTime).Round(time.Second))
 			continue
 		}
 
+		if strings.HasPrefix(strings.ToLower(input), "estimate ") {
+			tokens, cost := client.EstimatePromptCost(input[9:], "")
+			fmt.Printf("🧮 Prompt: %d tokens, about $%.6f before the answer\n", tokens, cost)
+			if overhead := client.ToolOverhead(); overhead > 0 {
+				fmt.Printf("   Streamed, the tool definitions add %d tokens ($%.6f) to every round\n", overhead, client.EstimateCost(overhead))
+			}
+			fmt.Println()
+			continue
+		}
+
+		if strings.HasPrefix(strings.ToLower(input), "embed ") {
+			vectors, err := client.Embed(ctx, []string{input[6:]})
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			fmt.Printf("📐 %d dimensions, starting %v\n\n", len(vectors[0]), vectors[0][:min(3, len(vectors[0]))])
+			continue
+		}
+
 		if strings.HasPrefix(strings.ToLower(input), "stream ") {
 			message := input[7:] // Remove "stream " prefix
 			if err := client.ChatStream(ctx, message, ""); err != nil {
@@ -295,7 +496,7 @@ func main() {
 	stats := client.GetUsageStats()
 	fmt.Printf("\n📊 Final Session Statistics:\n")
 	fmt.Printf("   Total Requests: %d\n", stats.TotalRequests)
-	fmt.Printf("   Total Tokens: %d\n", stats.TotalTokens)
+	fmt.Printf("   Total Tokens: %d (%d on tools)\n", stats.TotalTokens, stats.ToolTokens)
 	fmt.Printf("   Total Cost: $%.4f\n", stats.TotalCost)
 	fmt.Printf("   Session Duration: %v\n", time.Since(stats.StartTime).Round(time.Second))
 	fmt.Println("👋 Thanks for using the Advanced LLM Client!")
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultResumeTTL is how long a stream's events are kept for
+	// reconnecting clients after it ends, or after its last client left
+	defaultResumeTTL = 2 * time.Minute
+	// defaultHeartbeat is how often an idle connection is sent a keepalive,
+	// so proxies don't close it while the model is thinking
+	defaultHeartbeat = 15 * time.Second
+)
+
+// ErrUnknownStream is returned for a resume token that is unknown or expired
+var ErrUnknownStream = errors.New("unknown or expired resume token")
+
+// resumableStream buffers one answer's events so clients can replay them
+type resumableStream struct {
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	events []StreamEvent
+	done   bool
+	// changed is closed, and replaced, whenever an event arrives
+	changed chan struct{}
+	// clients is how many connections are following the stream
+	clients int
+	// idleSince is when the stream ended or its last client left
+	idleSince time.Time
+}
+
+// add records an event and wakes the stream's followers
+func (s *resumableStream) add(event StreamEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// finish marks the stream ended. A stream cut short without EventDone or
+// EventError, because it was abandoned, ends with an error event.
+func (s *resumableStream) finish(err error) {
+	s.mu.Lock()
+	ended := len(s.events) > 0 && (s.events[len(s.events)-1].Type == EventDone || s.events[len(s.events)-1].Type == EventError)
+	s.mu.Unlock()
+	if !ended {
+		s.add(StreamEvent{Type: EventError, Err: err})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.done = true
+	s.idleSince = time.Now()
+}
+
+// expired reports whether nobody has needed the stream for ttl
+func (s *resumableStream) expired(now time.Time, ttl time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.clients == 0 && now.Sub(s.idleSince) > ttl
+}
+
+// StreamHub runs streamed answers apart from the connections reading them.
+// Each stream has a resume token; a client that loses its connection can
+// reconnect with it, get the events it missed from a short-lived buffer,
+// and continue live.
+type StreamHub struct {
+	// Heartbeat is how often connections are sent a keepalive: an SSE
+	// comment or a WebSocket ping. Zero disables them.
+	Heartbeat time.Duration
+
+	client *AdvancedLLMClient
+	ttl    time.Duration
+
+	mu      sync.Mutex
+	streams map[string]*resumableStream
+}
+
+// NewStreamHub creates a hub streaming answers from client. Buffers are
+// dropped ttl after their stream ends or is left without clients; an
+// unfinished stream nobody came back for is cancelled.
+func NewStreamHub(client *AdvancedLLMClient, ttl time.Duration) *StreamHub {
+	return &StreamHub{
+		Heartbeat: defaultHeartbeat,
+		client:    client,
+		ttl:       ttl,
+		streams:   make(map[string]*resumableStream),
+	}
+}
+
+// Start begins streaming the answer to message and returns its resume token
+func (h *StreamHub) Start(message, systemPrompt string) (string, error) {
+	token, err := newResumeToken()
+	if err != nil {
+		return "", err
+	}
+
+	// The stream outlives any one connection, so it isn't tied to one
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &resumableStream{cancel: cancel, changed: make(chan struct{}), idleSince: time.Now()}
+
+	h.sweep()
+	h.mu.Lock()
+	h.streams[token] = stream
+	h.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		for event := range h.client.StreamEvents(ctx, message, systemPrompt) {
+			stream.add(event)
+		}
+		stream.finish(fmt.Errorf("stream abandoned: %w", ctx.Err()))
+		time.AfterFunc(h.ttl, h.sweep)
+	}()
+	return token, nil
+}
+
+// Follow passes send every event of the stream numbered above after, then
+// live events as they arrive, until the stream ends or ctx is done. Events
+// are numbered from 1. It returns ErrUnknownStream for an unknown or
+// expired token, and send's error if it fails.
+func (h *StreamHub) Follow(ctx context.Context, token string, after int, send func(seq int, event StreamEvent) error) error {
+	stream := h.lookup(token)
+	if stream == nil {
+		return ErrUnknownStream
+	}
+
+	stream.mu.Lock()
+	stream.clients++
+	stream.mu.Unlock()
+	defer func() {
+		stream.mu.Lock()
+		stream.clients--
+		if stream.clients == 0 {
+			stream.idleSince = time.Now()
+		}
+		stream.mu.Unlock()
+		time.AfterFunc(h.ttl, h.sweep)
+	}()
+
+	next := after + 1
+	if next < 1 {
+		next = 1
+	}
+	for {
+		stream.mu.Lock()
+		// Events are only ever appended, so the tail can be read unlocked
+		var pending []StreamEvent
+		if next <= len(stream.events) {
+			pending = stream.events[next-1:]
+		}
+		done, changed := stream.done, stream.changed
+		stream.mu.Unlock()
+
+		for _, event := range pending {
+			if err := send(next, event); err != nil {
+				return err
+			}
+			next++
+		}
+		if len(pending) > 0 {
+			continue
+		}
+		if done {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// lookup returns the stream with token, or nil
+func (h *StreamHub) lookup(token string) *resumableStream {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.streams[token]
+}
+
+// sweep drops expired buffers, cancelling streams nobody came back for
+func (h *StreamHub) sweep() {
+	now := time.Now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for token, stream := range h.streams {
+		if stream.expired(now, h.ttl) {
+			stream.cancel()
+			delete(h.streams, token)
+		}
+	}
+}
+
+// newResumeToken returns a random, unguessable token
+func newResumeToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate resume token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// addStream registers a stream holding events with hub, as Start does
+// without a model behind it
+func addStream(hub *StreamHub, token string, events ...StreamEvent) *resumableStream {
+	stream := &resumableStream{cancel: func() {}, changed: make(chan struct{}), idleSince: time.Now()}
+	for _, event := range events {
+		stream.add(event)
+	}
+	hub.mu.Lock()
+	hub.streams[token] = stream
+	hub.mu.Unlock()
+	return stream
+}
+
+// follow collects what Follow sends
+func follow(hub *StreamHub, token string, after int) ([]int, []StreamEvent, error) {
+	var seqs []int
+	var events []StreamEvent
+	err := hub.Follow(context.Background(), token, after, func(seq int, event StreamEvent) error {
+		seqs = append(seqs, seq)
+		events = append(events, event)
+		return nil
+	})
+	return seqs, events, err
+}
+
+func TestFollowReplaysAfter(t *testing.T) {
+	hub := NewStreamHub(nil, time.Minute)
+	stream := addStream(hub, "token",
+		StreamEvent{Type: EventContentDelta, Content: "one "},
+		StreamEvent{Type: EventContentDelta, Content: "two "},
+		StreamEvent{Type: EventContentDelta, Content: "three"},
+		StreamEvent{Type: EventDone, Content: "one two three"})
+	stream.finish(nil)
+
+	seqs, events, err := follow(hub, "token", 2)
+	if err != nil || len(seqs) != 2 || seqs[0] != 3 || seqs[1] != 4 || events[0].Content != "three" || events[1].Type != EventDone {
+		t.Errorf("Expected events 3 and 4, got %v %+v %v", seqs, events, err)
+	}
+	if seqs, _, _ := follow(hub, "token", -5); len(seqs) != 4 || seqs[0] != 1 {
+		t.Errorf("Expected every event from 1, got %v", seqs)
+	}
+	if seqs, _, err := follow(hub, "token", 4); err != nil || len(seqs) != 0 {
+		t.Errorf("Expected nothing after the last event, got %v %v", seqs, err)
+	}
+	if _, _, err := follow(hub, "missing", 0); !errors.Is(err, ErrUnknownStream) {
+		t.Errorf("Expected ErrUnknownStream, got %v", err)
+	}
+}
+
+func TestFollowLive(t *testing.T) {
+	hub := NewStreamHub(nil, time.Minute)
+	stream := addStream(hub, "token", StreamEvent{Type: EventContentDelta, Content: "one"})
+
+	type result struct {
+		seqs []int
+		err  error
+	}
+	results := make(chan result)
+	go func() {
+		seqs, _, err := follow(hub, "token", 0)
+		results <- result{seqs, err}
+	}()
+
+	stream.add(StreamEvent{Type: EventContentDelta, Content: "two"})
+	// A stream that ends without EventDone gets an error event
+	stream.finish(errors.New("abandoned"))
+
+	select {
+	case r := <-results:
+		if r.err != nil || len(r.seqs) != 3 || r.seqs[2] != 3 {
+			t.Errorf("Expected the buffered event, then the live ones, got %v %v", r.seqs, r.err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Follow didn't return when the stream ended")
+	}
+	if last := stream.events[len(stream.events)-1]; last.Type != EventError || last.Err.Error() != "abandoned" {
+		t.Errorf("Expected an error event ending the stream, got %+v", last)
+	}
+
+	// A follower stops when its context is done, and send's error ends it
+	unfinished := addStream(hub, "unfinished", StreamEvent{Type: EventContentDelta})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := hub.Follow(ctx, "unfinished", 1, func(int, StreamEvent) error { return nil }); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	sendErr := errors.New("client gone")
+	if err := hub.Follow(context.Background(), "unfinished", 0, func(int, StreamEvent) error { return sendErr }); err != sendErr {
+		t.Errorf("Expected send's error, got %v", err)
+	}
+	if unfinished.clients != 0 {
+		t.Errorf("Expected followers counted out, got %d", unfinished.clients)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	ttl := time.Minute
+	hub := NewStreamHub(nil, ttl)
+	long := time.Now().Add(-2 * ttl)
+
+	expired := addStream(hub, "expired")
+	expired.idleSince = long
+	cancelled := false
+	expired.cancel = func() { cancelled = true }
+
+	recent := addStream(hub, "recent")
+	recent.idleSince = time.Now().Add(-ttl / 2)
+
+	followed := addStream(hub, "followed")
+	followed.idleSince = long
+	followed.clients = 1
+
+	hub.sweep()
+	if hub.lookup("expired") != nil || !cancelled {
+		t.Error("Expected the idle stream dropped and cancelled")
+	}
+	if hub.lookup("recent") == nil {
+		t.Error("Expected a stream idle for less than the TTL kept")
+	}
+	if hub.lookup("followed") == nil {
+		t.Error("Expected a stream with a client kept however old")
+	}
+
+	followed.clients = 0
+	hub.sweep()
+	if hub.lookup("followed") != nil {
+		t.Error("Expected the stream dropped once its client left")
+	}
+}
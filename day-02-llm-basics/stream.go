@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/sakibmulla/agentic-ai/tools"
+	"github.com/sashabaranov/go-openai"
+)
+
+// maxToolRounds bounds how many times one streamed answer may stop to run tools
+const maxToolRounds = 5
+
+// StreamEventType identifies what a StreamEvent reports
+type StreamEventType string
+
+const (
+	// EventContentDelta carries the next piece of the answer in Content
+	EventContentDelta StreamEventType = "content_delta"
+	// EventToolCallStarted is sent as soon as the model names a tool to
+	// call; its arguments may still be streaming
+	EventToolCallStarted StreamEventType = "tool_call_started"
+	// EventToolResult carries a finished tool call, with its full arguments,
+	// and its Result or Err
+	EventToolResult StreamEventType = "tool_result"
+	// EventDone ends a successful stream; Content holds the answer streamed
+	// after the last tool call
+	EventDone StreamEventType = "done"
+	// EventError ends a failed stream
+	EventError StreamEventType = "error"
+)
+
+// StreamEvent is one step of a streamed answer
+type StreamEvent struct {
+	Type     StreamEventType
+	Content  string
+	ToolCall *openai.ToolCall
+	Result   string
+	Err      error
+}
+
+// Tools returns the registry of tools the model may call while streaming
+func (c *AdvancedLLMClient) Tools() *tools.Registry {
+	return c.tools
+}
+
+// StreamEvents streams the answer to message as events. When the model asks
+// for tools mid-stream, they are run and the answer continues in a new
+// stream. The channel is closed after EventDone or EventError, or when ctx
+// is cancelled.
+func (c *AdvancedLLMClient) StreamEvents(ctx context.Context, message string, systemPrompt string) <-chan StreamEvent {
+	events := make(chan StreamEvent)
+	go func() {
+		defer close(events)
+		send := func(event StreamEvent) bool {
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		content, err := c.streamWithTools(ctx, c.newMessages(message, systemPrompt), send)
+		if err != nil {
+			send(StreamEvent{Type: EventError, Err: err})
+			return
+		}
+		send(StreamEvent{Type: EventDone, Content: content})
+	}()
+	return events
+}
+
+// streamWithTools streams rounds of the conversation until one ends without
+// tool calls, and returns that round's content
+func (c *AdvancedLLMClient) streamWithTools(ctx context.Context, messages []openai.ChatCompletionMessage, send func(StreamEvent) bool) (string, error) {
+	// Models without function calling answer without tools
+	var definitions []tools.Definition
+	if c.config.FunctionCalling {
+		definitions = c.tools.Definitions()
+	}
+	var available []openai.Tool
+	for _, definition := range definitions {
+		available = append(available, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        definition.Name,
+				Description: definition.Description,
+				Parameters:  definition.Parameters,
+			},
+		})
+	}
+
+	for round := 0; round <= maxToolRounds; round++ {
+		reply, err := c.streamRound(ctx, messages, available, c.estimateRound(messages, definitions), send)
+		if err != nil {
+			return "", err
+		}
+		if len(reply.ToolCalls) == 0 {
+			return reply.Content, nil
+		}
+
+		messages = append(messages, reply)
+		for i := range reply.ToolCalls {
+			call := reply.ToolCalls[i]
+			result, err := c.tools.Call(ctx, call.Function.Name, call.Function.Arguments)
+			if !send(StreamEvent{Type: EventToolResult, ToolCall: &call, Result: result, Err: err}) {
+				return "", ctx.Err()
+			}
+			if err != nil {
+				result = fmt.Sprintf("Error: %v", err)
+			}
+			messages = append(messages, openai.ChatCompletionMessage{
+				Role:       openai.ChatMessageRoleTool,
+				Name:       call.Function.Name,
+				ToolCallID: call.ID,
+				Content:    result,
+			})
+		}
+	}
+	return "", fmt.Errorf("no answer after %d tool rounds", maxToolRounds)
+}
+
+// streamRound makes one streaming request, forwarding content deltas and
+// assembling the tool calls the model makes from their fragments. Its usage
+// is recorded once the stream ends: as reported in the final chunk, or
+// counted from estimate and the reply when the API sends none, such as
+// when the stream breaks off.
+func (c *AdvancedLLMClient) streamRound(ctx context.Context, messages []openai.ChatCompletionMessage, available []openai.Tool, estimate streamEstimate, send func(StreamEvent) bool) (openai.ChatCompletionMessage, error) {
+	reply := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant}
+	maxTokens, err := c.maxTokens(estimate.promptTokens)
+	if err != nil {
+		return reply, err
+	}
+
+	stream, err := c.client.CreateChatCompletionStream(ctx, openai.ChatCompletionRequest{
+		Model:         c.config.Name,
+		Messages:      messages,
+		Tools:         available,
+		MaxTokens:     maxTokens,
+		Temperature:   0.7,
+		Stream:        true,
+		StreamOptions: &openai.StreamOptions{IncludeUsage: true},
+	})
+	if err != nil {
+		return reply, fmt.Errorf("failed to create stream: %w", err)
+	}
+	defer stream.Close()
+
+	model := c.config.Name
+	var reported *openai.Usage
+	defer func() {
+		toolTokens := estimate.toolTokens + c.countToolCalls(reply.ToolCalls)
+		if reported != nil {
+			c.updateUsage(model, *reported, toolTokens, false)
+			return
+		}
+		completion := c.CountTokens(reply.Content) + c.countToolCalls(reply.ToolCalls)
+		c.updateUsage(model, openai.Usage{PromptTokens: estimate.promptTokens, CompletionTokens: completion}, toolTokens, true)
+	}()
+
+	for {
+		response, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return reply, nil
+		}
+		if err != nil {
+			return reply, fmt.Errorf("stream error: %w", err)
+		}
+
+		if response.Model != "" {
+			model = response.Model
+		}
+		// Usage arrives in a final chunk without choices
+		if response.Usage != nil {
+			reported = response.Usage
+		}
+		if len(response.Choices) == 0 {
+			continue
+		}
+
+		delta := response.Choices[0].Delta
+		if delta.Content != "" {
+			reply.Content += delta.Content
+			if !send(StreamEvent{Type: EventContentDelta, Content: delta.Content}) {
+				return reply, ctx.Err()
+			}
+		}
+
+		// A call's ID and name come in its first fragment, the arguments
+		// in pieces after it
+		for _, fragment := range delta.ToolCalls {
+			index := len(reply.ToolCalls)
+			if fragment.Index != nil {
+				index = *fragment.Index
+			}
+			for len(reply.ToolCalls) <= index {
+				reply.ToolCalls = append(reply.ToolCalls, openai.ToolCall{Type: openai.ToolTypeFunction})
+			}
+
+			call := &reply.ToolCalls[index]
+			if fragment.ID != "" {
+				call.ID = fragment.ID
+			}
+			call.Function.Arguments += fragment.Function.Arguments
+			if fragment.Function.Name != "" && call.Function.Name == "" {
+				call.Function.Name = fragment.Function.Name
+				started := *call
+				if !send(StreamEvent{Type: EventToolCallStarted, ToolCall: &started}) {
+					return reply, ctx.Err()
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// wireEvent is a StreamEvent as sent to HTTP clients. Seq numbers events
+// from 1; a reconnecting client passes the last one it saw.
+type wireEvent struct {
+	Seq         int              `json:"seq,omitempty"`
+	Type        string           `json:"type"`
+	ResumeToken string           `json:"resume_token,omitempty"`
+	Content     string           `json:"content,omitempty"`
+	ToolCall    *openai.ToolCall `json:"tool_call,omitempty"`
+	Result      string           `json:"result,omitempty"`
+	Error       string           `json:"error,omitempty"`
+}
+
+// newWireEvent converts the seq'th event of a stream
+func newWireEvent(seq int, event StreamEvent) wireEvent {
+	wire := wireEvent{
+		Seq:      seq,
+		Type:     string(event.Type),
+		Content:  event.Content,
+		ToolCall: event.ToolCall,
+		Result:   event.Result,
+	}
+	if event.Err != nil {
+		wire.Error = event.Err.Error()
+	}
+	return wire
+}
+
+// StreamHandler serves streamed answers over Server-Sent Events at /stream
+// and WebSocket at /ws, or either at /chat, chosen per request. Each starts
+// a stream with ?message= (and an optional &system=), or resumes one with
+// ?resume=<token>&after=<seq>; SSE clients may send Last-Event-ID instead
+// of after. The first message on either transport is a "session" event
+// carrying the resume token.
+func (h *StreamHub) StreamHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/chat", h.serveChat)
+	mux.HandleFunc("/stream", h.serveSSE)
+	mux.HandleFunc("/ws", h.serveWebSocket)
+	return mux
+}
+
+// serveChat streams over the transport the request asks for with
+// ?transport=sse or ?transport=ws. Without one, a WebSocket handshake gets
+// WebSocket and anything else SSE, for clients that can't use WebSockets.
+func (h *StreamHub) serveChat(w http.ResponseWriter, r *http.Request) {
+	switch transport := r.URL.Query().Get("transport"); transport {
+	case "ws":
+		h.serveWebSocket(w, r)
+	case "sse":
+		h.serveSSE(w, r)
+	case "":
+		if checkWebSocketHandshake(r) == nil {
+			h.serveWebSocket(w, r)
+		} else {
+			h.serveSSE(w, r)
+		}
+	default:
+		http.Error(w, fmt.Sprintf("unknown transport %q; use sse or ws", transport), http.StatusBadRequest)
+	}
+}
+
+// keepAlive calls beat every interval until stop is closed or beat fails.
+// It returns once it has stopped, so nothing is written after the caller
+// is done with the connection.
+func keepAlive(interval time.Duration, stop <-chan struct{}, beat func() error) (wait func()) {
+	finished := make(chan struct{})
+	if interval <= 0 {
+		close(finished)
+		return func() { <-finished }
+	}
+
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if beat() != nil {
+					return
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return func() { <-finished }
+}
+
+// streamRequest starts or resumes the stream a request asks for, returning
+// its token and the last event the client has seen
+func (h *StreamHub) streamRequest(r *http.Request) (string, int, error) {
+	query := r.URL.Query()
+	if token := query.Get("resume"); token != "" {
+		after := query.Get("after")
+		if after == "" {
+			after = r.Header.Get("Last-Event-ID")
+		}
+		if after == "" {
+			return token, 0, nil
+		}
+		seq, err := strconv.Atoi(after)
+		if err != nil || seq < 0 {
+			return "", 0, fmt.Errorf("invalid event number %q", after)
+		}
+		return token, seq, nil
+	}
+
+	message := query.Get("message")
+	if message == "" {
+		return "", 0, fmt.Errorf("message or resume is required")
+	}
+	token, err := h.Start(message, query.Get("system"))
+	return token, 0, err
+}
+
+// serveSSE streams events as Server-Sent Events, each with its seq as id
+func (h *StreamHub) serveSSE(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	token, after, err := h.streamRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if h.lookup(token) == nil {
+		http.Error(w, ErrUnknownStream.Error(), http.StatusNotFound)
+		return
+	}
+
+	// Events and heartbeats are written from different goroutines
+	var writeMu sync.Mutex
+	send := func(id string, wire wireEvent) error {
+		data, err := json.Marshal(wire)
+		if err != nil {
+			return err
+		}
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		if id != "" {
+			fmt.Fprintf(w, "id: %s\n", id)
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", wire.Type, data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Resume-Token", token)
+	if err := send("", wireEvent{Type: "session", ResumeToken: token}); err != nil {
+		return
+	}
+
+	// SSE comments are ignored by clients but keep the connection busy
+	stop := make(chan struct{})
+	wait := keepAlive(h.Heartbeat, stop, func() error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	})
+	defer wait()
+	defer close(stop)
+
+	h.Follow(r.Context(), token, after, func(seq int, event StreamEvent) error {
+		return send(strconv.Itoa(seq), newWireEvent(seq, event))
+	})
+}
+
+// serveWebSocket streams events as JSON text messages, closing normally
+// once the stream ends
+func (h *StreamHub) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	// Check before starting a stream nobody could read
+	if err := checkWebSocketHandshake(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	token, after, err := h.streamRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ws, err := upgradeWebSocket(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// A hijacked request's context isn't cancelled when the client leaves
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-ws.gone
+		cancel()
+	}()
+
+	send := func(wire wireEvent) error {
+		data, err := json.Marshal(wire)
+		if err != nil {
+			return err
+		}
+		return ws.WriteText(data)
+	}
+	if err := send(wireEvent{Type: "session", ResumeToken: token}); err != nil {
+		ws.Close(1011)
+		return
+	}
+
+	// Pings keep the connection open, and a failed one ends the stream
+	stop := make(chan struct{})
+	wait := keepAlive(h.Heartbeat, stop, func() error {
+		if err := ws.Ping(); err != nil {
+			cancel()
+			return err
+		}
+		return nil
+	})
+	defer wait()
+	defer close(stop)
+
+	err = h.Follow(ctx, token, after, func(seq int, event StreamEvent) error {
+		return send(newWireEvent(seq, event))
+	})
+	switch {
+	case err == nil:
+		ws.Close(1000)
+	case errors.Is(err, ErrUnknownStream):
+		send(wireEvent{Type: string(EventError), Error: err.Error()})
+		ws.Close(1008)
+	default:
+		ws.Close(1011)
+	}
+}
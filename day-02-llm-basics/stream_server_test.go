@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKeepAlive(t *testing.T) {
+	// Without an interval nothing beats, and wait returns at once
+	wait := keepAlive(0, make(chan struct{}), func() error {
+		t.Error("Expected no heartbeat")
+		return nil
+	})
+	wait()
+
+	var beats atomic.Int32
+	stop := make(chan struct{})
+	wait = keepAlive(time.Millisecond, stop, func() error {
+		beats.Add(1)
+		return nil
+	})
+	for beats.Load() < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	close(stop)
+	wait()
+	stopped := beats.Load()
+	time.Sleep(10 * time.Millisecond)
+	if beats.Load() != stopped {
+		t.Error("Expected no heartbeats after wait returned")
+	}
+
+	// A failed heartbeat stops them without stop being closed
+	var failed atomic.Int32
+	keepAlive(time.Millisecond, make(chan struct{}), func() error {
+		failed.Add(1)
+		return errors.New("connection closed")
+	})()
+	if failed.Load() != 1 {
+		t.Errorf("Expected one failed heartbeat, got %d", failed.Load())
+	}
+}
+
+// newStreamServer serves hub, which holds a finished two-event stream
+// "done" and an unfinished one "live"
+func newStreamServer(t *testing.T) (*httptest.Server, *resumableStream) {
+	t.Helper()
+	hub := NewStreamHub(nil, time.Minute)
+	hub.Heartbeat = 5 * time.Millisecond
+	done := addStream(hub, "done",
+		StreamEvent{Type: EventContentDelta, Content: "hello"},
+		StreamEvent{Type: EventDone, Content: "hello"})
+	done.finish(nil)
+	live := addStream(hub, "live", StreamEvent{Type: EventContentDelta, Content: "thinking"})
+
+	server := httptest.NewServer(hub.StreamHandler())
+	t.Cleanup(server.Close)
+	return server, live
+}
+
+func TestChatOverSSE(t *testing.T) {
+	server, live := newStreamServer(t)
+
+	resp, err := http.Get(server.URL + "/chat?resume=done&after=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	text := string(body)
+	if resp.Header.Get("Content-Type") != "text/event-stream" || resp.Header.Get("X-Resume-Token") != "done" {
+		t.Errorf("Expected an SSE stream with its resume token, got %v", resp.Header)
+	}
+	if !strings.HasPrefix(text, "event: session\n") || strings.Contains(text, "id: 1\n") || !strings.Contains(text, "id: 2\nevent: done\n") {
+		t.Errorf("Expected the session event, then only event 2, got %q", text)
+	}
+
+	// Last-Event-ID resumes as after does
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/stream?resume=done", nil)
+	req.Header.Set("Last-Event-ID", "2")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if strings.Contains(string(body), "id: ") {
+		t.Errorf("Expected no events after Last-Event-ID 2, got %q", body)
+	}
+
+	// An idle stream is kept alive with comments until it ends
+	resp, err = http.Get(server.URL + "/chat?transport=sse&resume=live&after=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Expected a keepalive, got %v", err)
+		}
+		if line == ": keepalive\n" {
+			break
+		}
+	}
+	live.add(StreamEvent{Type: EventDone})
+	live.finish(nil)
+	rest, _ := io.ReadAll(reader)
+	if !strings.Contains(string(rest), "id: 2\nevent: done\n") {
+		t.Errorf("Expected the live event after the keepalive, got %q", rest)
+	}
+}
+
+func TestChatOverWebSocket(t *testing.T) {
+	server, _ := newStreamServer(t)
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprint(conn, "GET /chat?resume=done HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n")
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil || resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected a WebSocket upgrade, got %v %v", resp, err)
+	}
+
+	var types []string
+	for {
+		opcode, payload, err := readServerFrame(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if opcode == wsOpClose {
+			if code := binary.BigEndian.Uint16(payload); code != 1000 {
+				t.Errorf("Expected a normal close, got %d", code)
+			}
+			break
+		}
+		if opcode != wsOpText {
+			continue
+		}
+		var wire wireEvent
+		if err := json.Unmarshal(payload, &wire); err != nil {
+			t.Fatal(err)
+		}
+		types = append(types, fmt.Sprint(wire.Type, wire.Seq))
+	}
+	if strings.Join(types, ",") != "session0,content_delta1,done2" {
+		t.Errorf("Expected the session, then every event, got %v", types)
+	}
+}
+
+func TestChatTransportErrors(t *testing.T) {
+	server, _ := newStreamServer(t)
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/chat?transport=grpc&resume=done", http.StatusBadRequest},
+		{"/chat?transport=ws&resume=done", http.StatusBadRequest},
+		{"/chat?resume=missing", http.StatusNotFound},
+		{"/chat?resume=done&after=-1", http.StatusBadRequest},
+		{"/chat", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(server.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: expected %d, got %d", tt.path, tt.status, resp.StatusCode)
+		}
+	}
+}
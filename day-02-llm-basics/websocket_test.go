@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// maskedFrame encodes a client frame, which RFC 6455 requires be masked
+func maskedFrame(opcode byte, payload []byte, mask [4]byte) []byte {
+	frame := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, 0x80|byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	return frame
+}
+
+// readServerFrame decodes an unmasked server frame
+func readServerFrame(r io.Reader) (byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return 0, nil, err
+	}
+	if head[0]&0x80 == 0 || head[1]&0x80 != 0 {
+		return 0, nil, fmt.Errorf("expected a final, unmasked frame, got header %x", head)
+	}
+	length := uint64(head[1])
+	switch length {
+	case 126:
+		var ext [2]byte
+		io.ReadFull(r, ext[:])
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		io.ReadFull(r, ext[:])
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	payload := make([]byte, length)
+	_, err := io.ReadFull(r, payload)
+	return head[0] & 0x0F, payload, err
+}
+
+// pipeConn returns a wsConn over one end of an in-memory connection, and
+// the other end
+func pipeConn(t *testing.T) (*wsConn, net.Conn) {
+	t.Helper()
+	server, client := net.Pipe()
+	t.Cleanup(func() { server.Close(); client.Close() })
+	rw := bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server))
+	return &wsConn{conn: server, rw: rw, gone: make(chan struct{})}, client
+}
+
+func TestWebSocketAccept(t *testing.T) {
+	// The example handshake from RFC 6455 section 1.3
+	if got := websocketAccept("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Expected the RFC 6455 accept key, got %s", got)
+	}
+}
+
+func TestCheckWebSocketHandshake(t *testing.T) {
+	request := func(headers map[string]string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+		for name, value := range headers {
+			r.Header.Set(name, value)
+		}
+		return r
+	}
+	valid := map[string]string{"Connection": "keep-alive, Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Key": "x", "Sec-WebSocket-Version": "13"}
+	if err := checkWebSocketHandshake(request(valid)); err != nil {
+		t.Errorf("Expected a valid handshake, got %v", err)
+	}
+	if err := checkWebSocketHandshake(request(map[string]string{"Connection": "keep-alive"})); err == nil {
+		t.Error("Expected a plain request rejected")
+	}
+	valid["Sec-WebSocket-Version"] = "8"
+	if err := checkWebSocketHandshake(request(valid)); err == nil || !strings.Contains(err.Error(), "version") {
+		t.Errorf("Expected an old version rejected, got %v", err)
+	}
+}
+
+func TestWriteFrame(t *testing.T) {
+	for _, size := range []int{0, 125, 126, 0xFFFF, 0x10000} {
+		ws, client := pipeConn(t)
+		payload := bytes.Repeat([]byte{'a'}, size)
+		go ws.WriteText(payload)
+
+		// The header's length form depends on the size
+		headerSize := 2
+		switch {
+		case size > 0xFFFF:
+			headerSize += 8
+		case size > 125:
+			headerSize += 2
+		}
+		header := make([]byte, headerSize)
+		if _, err := io.ReadFull(client, header); err != nil {
+			t.Fatal(err)
+		}
+		if header[0] != 0x80|wsOpText || header[1]&0x80 != 0 {
+			t.Errorf("%d bytes: expected a final, unmasked text frame, got header %x", size, header)
+		}
+		got := make([]byte, size)
+		if _, err := io.ReadFull(client, got); err != nil || !bytes.Equal(got, payload) {
+			t.Errorf("%d bytes: payload didn't round-trip: %v", size, err)
+		}
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	mask := [4]byte{0x12, 0x34, 0x56, 0x78}
+	tests := []struct {
+		name    string
+		frame   []byte
+		opcode  byte
+		payload string
+		err     string
+	}{
+		{"short", maskedFrame(wsOpPing, []byte("hello"), mask), wsOpPing, "hello", ""},
+		{"16-bit length", maskedFrame(wsOpText, bytes.Repeat([]byte{'b'}, 300), mask), wsOpText, strings.Repeat("b", 300), ""},
+		{"unmasked", []byte{0x80 | wsOpText, 2, 'h', 'i'}, 0, "", "not masked"},
+		{"too large", maskedFrame(wsOpText, make([]byte, wsMaxClientFrame+1), mask), 0, "", "too large"},
+	}
+	for _, tt := range tests {
+		ws, client := pipeConn(t)
+		go client.Write(tt.frame)
+		opcode, payload, err := ws.readFrame()
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.err, err)
+			}
+			continue
+		}
+		if err != nil || opcode != tt.opcode || string(payload) != tt.payload {
+			t.Errorf("%s: got opcode %x, %d bytes, %v", tt.name, opcode, len(payload), err)
+		}
+	}
+}
+
+func TestUpgradeWebSocket(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgradeWebSocket(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ws.WriteText([]byte("hello"))
+		<-ws.gone
+	}))
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprint(conn, "GET / HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n")
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Expected 101 with the accept key, got %d %v", resp.StatusCode, resp.Header)
+	}
+
+	if opcode, payload, err := readServerFrame(reader); err != nil || opcode != wsOpText || string(payload) != "hello" {
+		t.Fatalf("Expected the text message, got %x %q %v", opcode, payload, err)
+	}
+
+	// Pings are answered with the same payload, and closes echoed
+	mask := [4]byte{1, 2, 3, 4}
+	conn.Write(maskedFrame(wsOpPing, []byte("are you there"), mask))
+	if opcode, payload, err := readServerFrame(reader); err != nil || opcode != wsOpPong || string(payload) != "are you there" {
+		t.Errorf("Expected a pong, got %x %q %v", opcode, payload, err)
+	}
+	conn.Write(maskedFrame(wsOpClose, []byte{0x03, 0xE8}, mask))
+	if opcode, payload, err := readServerFrame(reader); err != nil || opcode != wsOpClose || binary.BigEndian.Uint16(payload) != 1000 {
+		t.Errorf("Expected the close echoed, got %x %v %v", opcode, payload, err)
+	}
+}
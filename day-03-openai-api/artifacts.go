@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sakibmulla/agentic-ai/tools"
+)
+
+const (
+	// artifactThreshold is the result size above which a tool result is
+	// stored as an artifact and only a digest enters the conversation
+	artifactThreshold = 600
+	// artifactPreview bounds the excerpt included in a digest
+	artifactPreview = 240
+	// artifactChunk is the default amount get_artifact returns per call
+	artifactChunk = 2000
+	// maxArtifacts bounds the store; the oldest artifacts are evicted first
+	maxArtifacts = 100
+)
+
+// Artifact is a full tool result kept outside the conversation
+type Artifact struct {
+	ID        string                 `json:"id"`
+	Tool      string                 `json:"tool"`
+	Args      map[string]interface{} `json:"args"`
+	Content   string                 `json:"content"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// ArtifactStore keeps large tool results referenced by ID from the conversation
+type ArtifactStore struct {
+	artifacts map[string]*Artifact
+	nextID    int
+	mu        sync.Mutex
+}
+
+// NewArtifactStore creates an empty artifact store
+func NewArtifactStore() *ArtifactStore {
+	return &ArtifactStore{artifacts: make(map[string]*Artifact)}
+}
+
+// Put stores a tool result and returns its artifact
+func (s *ArtifactStore) Put(tool string, args map[string]interface{}, content string) *Artifact {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	artifact := &Artifact{
+		ID:        fmt.Sprintf("art-%d", s.nextID),
+		Tool:      tool,
+		Args:      args,
+		Content:   content,
+		CreatedAt: time.Now(),
+	}
+	s.artifacts[artifact.ID] = artifact
+
+	for len(s.artifacts) > maxArtifacts {
+		delete(s.artifacts, s.oldest())
+	}
+	return artifact
+}
+
+// Get retrieves an artifact by ID
+func (s *ArtifactStore) Get(id string) (*Artifact, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	artifact, ok := s.artifacts[id]
+	if !ok {
+		return nil, fmt.Errorf("artifact '%s' not found", id)
+	}
+	return artifact, nil
+}
+
+// List returns all artifacts, oldest first
+func (s *ArtifactStore) List() []*Artifact {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]*Artifact, 0, len(s.artifacts))
+	for _, artifact := range s.artifacts {
+		list = append(list, artifact)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+	return list
+}
+
+// Clear removes every artifact
+func (s *ArtifactStore) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.artifacts = make(map[string]*Artifact)
+}
+
+// oldest returns the ID of the oldest artifact. Callers must hold s.mu.
+func (s *ArtifactStore) oldest() string {
+	var id string
+	var created time.Time
+	for _, artifact := range s.artifacts {
+		if id == "" || artifact.CreatedAt.Before(created) {
+			id, created = artifact.ID, artifact.CreatedAt
+		}
+	}
+	return id
+}
+
+// Digest is the short stand-in for an artifact that enters the LLM context
+func (a *Artifact) Digest() string {
+	lines := strings.Count(a.Content, "\n") + 1
+	return fmt.Sprintf("[artifact %s: %s result, %d chars, %d lines]\n%s\n[Call get_artifact with id %q (and offset/length) to read the full result.]",
+		a.ID, a.Tool, len(a.Content), lines, clip(strings.TrimSpace(a.Content), artifactPreview), a.ID)
+}
+
+// contextContent returns what a tool result contributes to the conversation:
+// small results verbatim, large ones as a stored artifact's digest
+func (a *AgentWithTools) contextContent(tool string, args map[string]interface{}, result string) (string, string) {
+	if len(result) <= artifactThreshold || tool == "get_artifact" {
+		return result, ""
+	}
+	artifact := a.artifacts.Put(tool, args, result)
+	return artifact.Digest(), artifact.ID
+}
+
+// registerArtifactTool adds get_artifact, which reads stored tool results on demand
+func (a *AgentWithTools) registerArtifactTool() {
+	a.tools.MustRegister(tools.Tool{
+		Name:        "get_artifact",
+		Description: "Read the full result of an earlier tool call that was stored as an artifact. Long artifacts are returned in chunks; pass offset to continue.",
+		Parameters: tools.Schema{
+			Type: tools.Object,
+			Properties: map[string]tools.Schema{
+				"id": {
+					Type:        tools.String,
+					Description: "Artifact ID, e.g. art-3",
+				},
+				"offset": {
+					Type:        tools.Integer,
+					Description: "Character offset to start reading from (default 0)",
+				},
+				"length": {
+					Type:        tools.Integer,
+					Description: fmt.Sprintf("Number of characters to read (default %d)", artifactChunk),
+				},
+			},
+			Required: []string{"id"},
+		},
+		Handler: a.handleGetArtifact,
+	})
+}
+
+// handleGetArtifact implements the get_artifact tool
+func (a *AgentWithTools) handleGetArtifact(ctx context.Context, args map[string]interface{}) (string, error) {
+	id, _ := args["id"].(string)
+	artifact, err := a.artifacts.Get(id)
+	if err != nil {
+		return "", err
+	}
+
+	offset, length := 0, artifactChunk
+	if v, ok := args["offset"].(float64); ok && v > 0 {
+		offset = int(v)
+	}
+	if v, ok := args["length"].(float64); ok && v > 0 && int(v) < artifactChunk {
+		length = int(v)
+	}
+	if offset >= len(artifact.Content) {
+		return "", fmt.Errorf("offset %d is past the end of artifact %s (%d chars)", offset, id, len(artifact.Content))
+	}
+
+	end := offset + length
+	if end > len(artifact.Content) {
+		end = len(artifact.Content)
+	}
+	chunk := artifact.Content[offset:end]
+	if end < len(artifact.Content) {
+		chunk += fmt.Sprintf("\n[%d more chars; continue with offset %d]", len(artifact.Content)-end, end)
+	}
+	return chunk, nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/sakibmulla/agentic-ai/tools"
+)
+
+// contextWindowTokens is the context size of agentModel
+const contextWindowTokens = 16385
+
+// ToolCapability describes one registered tool and its parameter schema
+type ToolCapability struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Parameters  tools.Schema `json:"parameters"`
+}
+
+// MemoryCapabilities describes what the agent remembers
+type MemoryCapabilities struct {
+	ConversationHistory bool `json:"conversation_history"`
+	Persistent          bool `json:"persistent"`
+	ToolCallTraces      bool `json:"tool_call_traces"`
+	// ToolArtifacts is true when large tool results are kept out of the
+	// context and readable with get_artifact
+	ToolArtifacts bool `json:"tool_artifacts"`
+	// ConversationEnv lists the names (never the values) of the variables set for tools
+	ConversationEnv []string `json:"conversation_env"`
+}
+
+// CapabilityLimits describes the limits a client should respect
+type CapabilityLimits struct {
+	ContextWindowTokens int     `json:"context_window_tokens"`
+	Temperature         float32 `json:"temperature"`
+	// MaxToolCallsPerTurn is 0 when the agent loops until the model stops calling tools
+	MaxToolCallsPerTurn int  `json:"max_tool_calls_per_turn"`
+	ParallelToolCalls   bool `json:"parallel_tool_calls"`
+}
+
+// Capabilities is a self-description of an agent instance, so client UIs and
+// orchestrating agents can discover what it can do
+type Capabilities struct {
+	Model   string             `json:"model"`
+	Persona string             `json:"persona"`
+	Tools   []ToolCapability   `json:"tools"`
+	Memory  MemoryCapabilities `json:"memory"`
+	Limits  CapabilityLimits   `json:"limits"`
+}
+
+// DescribeCapabilities returns the agent's model, persona, tools, memory features and limits
+func (a *AgentWithTools) DescribeCapabilities() Capabilities {
+	registered := a.tools.Tools()
+	capabilities := make([]ToolCapability, len(registered))
+	for i, tool := range registered {
+		capabilities[i] = ToolCapability{
+			Name:        tool.Name,
+			Description: tool.Description,
+			Parameters:  tool.Parameters,
+		}
+	}
+
+	return Capabilities{
+		Model:   agentModel,
+		Persona: agentSystemPrompt,
+		Tools:   capabilities,
+		Memory: MemoryCapabilities{
+			ConversationHistory: true,
+			Persistent:          true,
+			ToolCallTraces:      true,
+			ToolArtifacts:       true,
+			ConversationEnv:     a.env.Keys(),
+		},
+		Limits: CapabilityLimits{
+			ContextWindowTokens: contextWindowTokens,
+			Temperature:         agentTemperature,
+			MaxToolCallsPerTurn: 0,
+			ParallelToolCalls:   true,
+		},
+	}
+}
+
+// registerCapabilitiesTool adds describe_capabilities, letting the model (or an
+// orchestrating agent talking to it) ask what this agent can do
+func (a *AgentWithTools) registerCapabilitiesTool() {
+	a.tools.MustRegister(tools.Tool{
+		Name:        "describe_capabilities",
+		Description: "Describe this agent: its model, persona, available tools with their parameter schemas, memory features and limits",
+		Parameters: tools.Schema{
+			Type:       tools.Object,
+			Properties: map[string]tools.Schema{},
+		},
+		Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			data, err := json.Marshal(a.DescribeCapabilities())
+			if err != nil {
+				return "", err
+			}
+			return string(data), nil
+		},
+	})
+}
+
+// CapabilitiesHandler serves DescribeCapabilities as JSON on GET /capabilities.
+// Tools must all be registered before the handler starts serving.
+func (a *AgentWithTools) CapabilitiesHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/capabilities", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(a.DescribeCapabilities())
+	})
+	return mux
+}
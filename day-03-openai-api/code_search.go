@@ -0,0 +1,419 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sakibmulla/agentic-ai/tools"
+)
+
+// Symbol is a top-level declaration found in the indexed module
+type Symbol struct {
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Package   string `json:"package"`
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Signature string `json:"signature"`
+	Doc       string `json:"doc"`
+}
+
+// Reference is a call site of a symbol
+type Reference struct {
+	Symbol  string `json:"symbol"`
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Caller  string `json:"caller"`
+	Context string `json:"context"`
+}
+
+// SymbolIndex maps symbol names to their definitions and call sites in a Go module
+type SymbolIndex struct {
+	root       string
+	symbols    map[string][]Symbol
+	references map[string][]Reference
+	fileCount  int
+	indexedAt  time.Time
+}
+
+// BuildSymbolIndex parses every Go file under root (skipping vendor, testdata
+// and hidden directories) and records declarations and call sites
+func BuildSymbolIndex(root string) (*SymbolIndex, error) {
+	index := &SymbolIndex{
+		root:       root,
+		symbols:    make(map[string][]Symbol),
+		references: make(map[string][]Reference),
+	}
+
+	fset := token.NewFileSet()
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			name := info.Name()
+			if path != root && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		file, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+		if err != nil {
+			// Skip files that don't parse rather than failing the whole index
+			return nil
+		}
+
+		rel, _ := filepath.Rel(root, path)
+		index.indexFile(fset, rel, file, strings.Split(string(src), "\n"))
+		index.fileCount++
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to index %s: %w", root, err)
+	}
+
+	index.indexedAt = time.Now()
+	return index, nil
+}
+
+// indexFile records the declarations and call sites of a parsed file
+func (si *SymbolIndex) indexFile(fset *token.FileSet, path string, file *ast.File, lines []string) {
+	pkg := file.Name.Name
+
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			name := d.Name.Name
+			kind := "func"
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				kind = "method"
+				name = recvTypeName(d.Recv.List[0].Type) + "." + name
+			}
+
+			header := *d
+			header.Body = nil
+			header.Doc = nil
+
+			si.addSymbol(Symbol{
+				Name:      name,
+				Kind:      kind,
+				Package:   pkg,
+				File:      path,
+				Line:      fset.Position(d.Pos()).Line,
+				Signature: nodeString(fset, &header),
+				Doc:       strings.TrimSpace(d.Doc.Text()),
+			})
+
+			si.indexCalls(fset, path, name, d.Body, lines)
+
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					doc := d.Doc
+					if s.Doc != nil {
+						doc = s.Doc
+					}
+					si.addSymbol(Symbol{
+						Name:      s.Name.Name,
+						Kind:      "type",
+						Package:   pkg,
+						File:      path,
+						Line:      fset.Position(s.Pos()).Line,
+						Signature: "type " + firstLine(nodeString(fset, s)),
+						Doc:       strings.TrimSpace(doc.Text()),
+					})
+				case *ast.ValueSpec:
+					for _, ident := range s.Names {
+						si.addSymbol(Symbol{
+							Name:      ident.Name,
+							Kind:      d.Tok.String(),
+							Package:   pkg,
+							File:      path,
+							Line:      fset.Position(ident.Pos()).Line,
+							Signature: d.Tok.String() + " " + ident.Name,
+							Doc:       strings.TrimSpace(d.Doc.Text()),
+						})
+					}
+				}
+			}
+		}
+	}
+}
+
+// indexCalls records every call expression inside body
+func (si *SymbolIndex) indexCalls(fset *token.FileSet, path, caller string, body *ast.BlockStmt, lines []string) {
+	if body == nil {
+		return
+	}
+
+	ast.Inspect(body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+
+		var name string
+		switch fn := call.Fun.(type) {
+		case *ast.Ident:
+			name = fn.Name
+		case *ast.SelectorExpr:
+			name = fn.Sel.Name
+		default:
+			return true
+		}
+
+		line := fset.Position(call.Pos()).Line
+		context := ""
+		if line-1 < len(lines) {
+			context = strings.TrimSpace(lines[line-1])
+		}
+
+		si.references[name] = append(si.references[name], Reference{
+			Symbol:  name,
+			File:    path,
+			Line:    line,
+			Caller:  caller,
+			Context: context,
+		})
+		return true
+	})
+}
+
+// addSymbol indexes a symbol by its full name and, for methods, its bare method name
+func (si *SymbolIndex) addSymbol(symbol Symbol) {
+	si.symbols[symbol.Name] = append(si.symbols[symbol.Name], symbol)
+	if dot := strings.LastIndex(symbol.Name, "."); dot >= 0 {
+		bare := symbol.Name[dot+1:]
+		si.symbols[bare] = append(si.symbols[bare], symbol)
+	}
+}
+
+// Definitions returns the declarations of name ("Func", "Type" or "Type.Method")
+func (si *SymbolIndex) Definitions(name string) []Symbol {
+	return si.symbols[name]
+}
+
+// References returns the call sites of name. For "Type.Method" only the
+// method name can be matched, since the index has no type information.
+func (si *SymbolIndex) References(name string) []Reference {
+	if dot := strings.LastIndex(name, "."); dot >= 0 {
+		name = name[dot+1:]
+	}
+	return si.references[name]
+}
+
+// Search finds symbols whose name or doc comment contains query
+func (si *SymbolIndex) Search(query string, limit int) []Symbol {
+	query = strings.ToLower(query)
+	seen := make(map[string]bool)
+
+	var matches []Symbol
+	for _, symbols := range si.symbols {
+		for _, symbol := range symbols {
+			key := fmt.Sprintf("%s:%d", symbol.File, symbol.Line)
+			if seen[key] {
+				continue
+			}
+			if strings.Contains(strings.ToLower(symbol.Name), query) ||
+				strings.Contains(strings.ToLower(symbol.Doc), query) {
+				seen[key] = true
+				matches = append(matches, symbol)
+			}
+		}
+	}
+
+	// Name matches first, then alphabetical
+	sort.Slice(matches, func(i, j int) bool {
+		iName := strings.Contains(strings.ToLower(matches[i].Name), query)
+		jName := strings.Contains(strings.ToLower(matches[j].Name), query)
+		if iName != jName {
+			return iName
+		}
+		return matches[i].Name < matches[j].Name
+	})
+
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// recvTypeName returns the type name of a method receiver
+func recvTypeName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return recvTypeName(t.X)
+	case *ast.IndexExpr:
+		return recvTypeName(t.X)
+	case *ast.IndexListExpr:
+		return recvTypeName(t.X)
+	case *ast.Ident:
+		return t.Name
+	default:
+		return ""
+	}
+}
+
+// nodeString renders an AST node as Go source
+func nodeString(fset *token.FileSet, node interface{}) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, node); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+// firstLine returns the first line of s
+func firstLine(s string) string {
+	if i := strings.Index(s, "\n"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// codeSearchTool lazily builds a symbol index the first time each root is
+// searched. The root is the PROJECT_DIR conversation variable when set.
+type codeSearchTool struct {
+	root    string
+	indexes map[string]*SymbolIndex
+	mu      sync.Mutex
+}
+
+// registerCodeSearchTool adds the code_search tool, indexing the module at root
+func (a *AgentWithTools) registerCodeSearchTool(root string) {
+	cs := &codeSearchTool{root: root, indexes: make(map[string]*SymbolIndex)}
+
+	a.tools.MustRegister(tools.Tool{
+		Name:        "code_search",
+		Description: "Look up Go symbols in the local codebase: find definitions (with signature and doc), call sites, or search symbols by keyword. Prefer this over reading whole files.",
+		Parameters: tools.Schema{
+			Type: tools.Object,
+			Properties: map[string]tools.Schema{
+				"action": {
+					Type:        tools.String,
+					Description: "definition: show where a symbol is declared; references: list call sites; search: find symbols by name or doc keyword",
+					Enum:        []string{"definition", "references", "search"},
+				},
+				"symbol": {
+					Type:        tools.String,
+					Description: "Symbol name (Func, Type or Type.Method) or search keyword",
+				},
+				"limit": {
+					Type:        tools.Number,
+					Description: "Maximum number of results (default 10)",
+				},
+			},
+			Required: []string{"action", "symbol"},
+		},
+		Handler: cs.handle,
+	})
+}
+
+// indexFor returns the index of root, building it on first use
+func (cs *codeSearchTool) indexFor(root string) (*SymbolIndex, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if index, ok := cs.indexes[root]; ok {
+		return index, nil
+	}
+	index, err := BuildSymbolIndex(root)
+	if err != nil {
+		return nil, err
+	}
+	cs.indexes[root] = index
+	return index, nil
+}
+
+// handle implements the code_search tool
+func (cs *codeSearchTool) handle(ctx context.Context, args map[string]interface{}) (string, error) {
+	root := cs.root
+	if dir, ok := EnvFromContext(ctx, "PROJECT_DIR"); ok {
+		root = dir
+	}
+	index, err := cs.indexFor(root)
+	if err != nil {
+		return "", err
+	}
+
+	action, _ := args["action"].(string)
+	symbol, _ := args["symbol"].(string)
+	if symbol == "" {
+		return "", fmt.Errorf("symbol parameter is required")
+	}
+
+	limit := 10
+	if l, ok := args["limit"].(float64); ok && l > 0 {
+		limit = int(l)
+	}
+
+	var builder strings.Builder
+	switch action {
+	case "definition":
+		defs := index.Definitions(symbol)
+		if len(defs) == 0 {
+			return fmt.Sprintf("No definition found for %s", symbol), nil
+		}
+		for i, def := range defs {
+			if i >= limit {
+				break
+			}
+			builder.WriteString(fmt.Sprintf("%s %s (package %s) at %s:%d\n%s\n", def.Kind, def.Name, def.Package, def.File, def.Line, def.Signature))
+			if def.Doc != "" {
+				builder.WriteString("Doc: " + def.Doc + "\n")
+			}
+			builder.WriteString("\n")
+		}
+
+	case "references":
+		refs := index.References(symbol)
+		if len(refs) == 0 {
+			return fmt.Sprintf("No call sites found for %s", symbol), nil
+		}
+		builder.WriteString(fmt.Sprintf("%d call sites of %s:\n", len(refs), symbol))
+		for i, ref := range refs {
+			if i >= limit {
+				builder.WriteString(fmt.Sprintf("... and %d more\n", len(refs)-limit))
+				break
+			}
+			builder.WriteString(fmt.Sprintf("- %s:%d in %s: %s\n", ref.File, ref.Line, ref.Caller, ref.Context))
+		}
+
+	case "search":
+		matches := index.Search(symbol, limit)
+		if len(matches) == 0 {
+			return fmt.Sprintf("No symbols matching %s", symbol), nil
+		}
+		for _, match := range matches {
+			builder.WriteString(fmt.Sprintf("- %s %s (%s:%d)\n", match.Kind, match.Name, match.File, match.Line))
+		}
+
+	default:
+		return "", fmt.Errorf("unknown action: %s", action)
+	}
+
+	return builder.String(), nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeModule writes files, by path relative to a temp dir, and returns the dir
+func writeModule(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, src := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// shopModule is a small module with a type, a method, a func calling both,
+// and files the index skips
+var shopModule = map[string]string{
+	"shop/cart.go": `package shop
+
+// Cart holds the items being bought
+type Cart struct {
+	Items []string
+}
+
+// Total sums the prices of the cart's items
+func (c *Cart) Total(prices map[string]int) int {
+	total := 0
+	for _, item := range c.Items {
+		total += prices[item]
+	}
+	return total
+}
+
+// MaxItems bounds a cart
+const MaxItems = 50
+`,
+	"shop/checkout.go": `package shop
+
+// Checkout charges for a cart
+func Checkout(c *Cart) int {
+	return c.Total(nil)
+}
+`,
+	"main.go": `package main
+
+import "example.com/shop"
+
+func main() {
+	cart := &shop.Cart{}
+	shop.Checkout(cart)
+}
+`,
+	"broken.go":           "package main\nfunc {",
+	"vendor/dep/dep.go":   "package dep\n\nfunc Vendored() {}\n",
+	"testdata/fixture.go": "package fixture\n\nfunc Fixture() {}\n",
+	".cache/hidden.go":    "package hidden\n\nfunc Hidden() {}\n",
+}
+
+func TestBuildSymbolIndex(t *testing.T) {
+	index, err := BuildSymbolIndex(writeModule(t, shopModule))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index.fileCount != 3 {
+		t.Errorf("Expected the 3 parsable files outside skipped directories, got %d", index.fileCount)
+	}
+	for _, skipped := range []string{"Vendored", "Fixture", "Hidden"} {
+		if defs := index.Definitions(skipped); len(defs) != 0 {
+			t.Errorf("Expected %s skipped, got %+v", skipped, defs)
+		}
+	}
+
+	defs := index.Definitions("Cart.Total")
+	if len(defs) != 1 {
+		t.Fatalf("Expected one definition of Cart.Total, got %+v", defs)
+	}
+	total := defs[0]
+	if total.Kind != "method" || total.Package != "shop" || total.File != filepath.Join("shop", "cart.go") || total.Line != 9 ||
+		total.Signature != "func (c *Cart) Total(prices map[string]int) int" || total.Doc != "Total sums the prices of the cart's items" {
+		t.Errorf("Unexpected definition %+v", total)
+	}
+	// Methods are found by their bare name too
+	if defs := index.Definitions("Total"); len(defs) != 1 || defs[0] != total {
+		t.Errorf("Expected Total to find the method, got %+v", defs)
+	}
+	if defs := index.Definitions("Cart"); len(defs) != 1 || defs[0].Kind != "type" || defs[0].Signature != "type Cart struct {" {
+		t.Errorf("Unexpected type definition %+v", defs)
+	}
+	if defs := index.Definitions("MaxItems"); len(defs) != 1 || defs[0].Kind != "const" || defs[0].Doc != "MaxItems bounds a cart" {
+		t.Errorf("Unexpected const definition %+v", defs)
+	}
+
+	refs := index.References("Cart.Total")
+	if len(refs) != 1 || refs[0].Caller != "Checkout" || refs[0].Line != 5 || refs[0].Context != "return c.Total(nil)" {
+		t.Errorf("Unexpected references %+v", refs)
+	}
+	if refs := index.References("Checkout"); len(refs) != 1 || refs[0].Caller != "main" || refs[0].File != "main.go" {
+		t.Errorf("Expected Checkout called from main, got %+v", refs)
+	}
+}
+
+func TestSymbolIndexSearch(t *testing.T) {
+	index, err := BuildSymbolIndex(writeModule(t, shopModule))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Name matches come before doc matches, each alphabetical, and methods appear once
+	var names []string
+	for _, symbol := range index.Search("cart", 0) {
+		names = append(names, symbol.Name)
+	}
+	if got := strings.Join(names, ","); got != "Cart,Cart.Total,Checkout,MaxItems" {
+		t.Errorf("Unexpected search results %s", got)
+	}
+	if matches := index.Search("CART", 2); len(matches) != 2 {
+		t.Errorf("Expected a case-insensitive search limited to 2, got %d", len(matches))
+	}
+}
+
+func TestCodeSearchTool(t *testing.T) {
+	cs := &codeSearchTool{root: writeModule(t, shopModule), indexes: make(map[string]*SymbolIndex)}
+	ctx := context.Background()
+
+	out, err := cs.handle(ctx, map[string]interface{}{"action": "definition", "symbol": "Checkout"})
+	if err != nil || !strings.Contains(out, "func Checkout (package shop) at shop/checkout.go:4") || !strings.Contains(out, "Doc: Checkout charges for a cart") {
+		t.Errorf("Unexpected definition output %q, %v", out, err)
+	}
+	out, err = cs.handle(ctx, map[string]interface{}{"action": "references", "symbol": "Total"})
+	if err != nil || !strings.Contains(out, "1 call sites of Total") || !strings.Contains(out, "in Checkout: return c.Total(nil)") {
+		t.Errorf("Unexpected references output %q, %v", out, err)
+	}
+	if out, _ := cs.handle(ctx, map[string]interface{}{"action": "definition", "symbol": "Missing"}); out != "No definition found for Missing" {
+		t.Errorf("Unexpected output %q", out)
+	}
+	if _, err := cs.handle(ctx, map[string]interface{}{"action": "rename", "symbol": "Cart"}); err == nil {
+		t.Error("Expected an unknown action rejected")
+	}
+
+	// PROJECT_DIR switches the indexed root, and each root is indexed once
+	env := NewToolEnv()
+	env.Set("PROJECT_DIR", writeModule(t, map[string]string{"other.go": "package other\n\nfunc Elsewhere() {}\n"}))
+	out, err = cs.handle(withToolEnv(ctx, env), map[string]interface{}{"action": "search", "symbol": "elsewhere"})
+	if err != nil || !strings.Contains(out, "func Elsewhere (other.go:3)") {
+		t.Errorf("Expected the PROJECT_DIR module searched, got %q, %v", out, err)
+	}
+	if len(cs.indexes) != 2 {
+		t.Errorf("Expected an index per root, got %d", len(cs.indexes))
+	}
+}
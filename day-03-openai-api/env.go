@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// minRedactLength keeps very short values (like "1") from being redacted
+// everywhere they happen to appear
+const minRedactLength = 3
+
+// envKeyPattern is the allowed form of a variable name
+var envKeyPattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// ToolEnv holds conversation-scoped variables such as the project directory
+// or an API base URL. Tool handlers read them from their context; the model
+// only ever sees $NAME placeholders in place of the values.
+type ToolEnv struct {
+	vars map[string]string
+}
+
+// NewToolEnv creates an empty environment
+func NewToolEnv() *ToolEnv {
+	return &ToolEnv{vars: make(map[string]string)}
+}
+
+// Set assigns a variable
+func (e *ToolEnv) Set(key, value string) error {
+	if !envKeyPattern.MatchString(key) {
+		return fmt.Errorf("invalid variable name %q: use upper case letters, digits and _", key)
+	}
+	if value == "" {
+		return fmt.Errorf("value for %s is empty; use 'env unset %s' to remove it", key, key)
+	}
+	e.vars[key] = value
+	return nil
+}
+
+// Unset removes a variable, reporting whether it was set
+func (e *ToolEnv) Unset(key string) bool {
+	_, ok := e.vars[key]
+	delete(e.vars, key)
+	return ok
+}
+
+// Keys returns the variable names in sorted order
+func (e *ToolEnv) Keys() []string {
+	keys := make([]string, 0, len(e.vars))
+	for key := range e.vars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Get returns a variable's value
+func (e *ToolEnv) Get(key string) (string, bool) {
+	value, ok := e.vars[key]
+	return value, ok
+}
+
+// Snapshot returns a copy of the variables
+func (e *ToolEnv) Snapshot() map[string]string {
+	vars := make(map[string]string, len(e.vars))
+	for key, value := range e.vars {
+		vars[key] = value
+	}
+	return vars
+}
+
+// Redact replaces every variable value in text with its $NAME placeholder.
+// Longer values are replaced first so one value inside another is handled.
+func (e *ToolEnv) Redact(text string) string {
+	keys := e.Keys()
+	sort.SliceStable(keys, func(i, j int) bool {
+		return len(e.vars[keys[i]]) > len(e.vars[keys[j]])
+	})
+	for _, key := range keys {
+		if value := e.vars[key]; len(value) >= minRedactLength {
+			text = strings.ReplaceAll(text, value, "$"+key)
+		}
+	}
+	return text
+}
+
+// RedactMessages returns a copy of messages with variable values redacted,
+// including those in tool call arguments
+func (e *ToolEnv) RedactMessages(messages []openai.ChatCompletionMessage) []openai.ChatCompletionMessage {
+	if len(e.vars) == 0 {
+		return messages
+	}
+	redacted := make([]openai.ChatCompletionMessage, len(messages))
+	for i, msg := range messages {
+		msg.Content = e.Redact(msg.Content)
+		if msg.FunctionCall != nil {
+			call := *msg.FunctionCall
+			call.Arguments = e.Redact(call.Arguments)
+			msg.FunctionCall = &call
+		}
+		if len(msg.ToolCalls) > 0 {
+			calls := make([]openai.ToolCall, len(msg.ToolCalls))
+			for j, call := range msg.ToolCalls {
+				call.Function.Arguments = e.Redact(call.Function.Arguments)
+				calls[j] = call
+			}
+			msg.ToolCalls = calls
+		}
+		redacted[i] = msg
+	}
+	return redacted
+}
+
+// Expand substitutes $NAME and ${NAME} placeholders in the string arguments
+// of a tool call, so the model can pass values it has only seen redacted.
+// Unknown names are left as they are.
+func (e *ToolEnv) Expand(args map[string]interface{}) map[string]interface{} {
+	if len(e.vars) == 0 {
+		return args
+	}
+	expanded := make(map[string]interface{}, len(args))
+	for name, arg := range args {
+		if s, ok := arg.(string); ok {
+			arg = os.Expand(s, func(key string) string {
+				if value, ok := e.vars[key]; ok {
+					return value
+				}
+				return "$" + key
+			})
+		}
+		expanded[name] = arg
+	}
+	return expanded
+}
+
+// toolEnvKey is the context key for a tool call's environment
+type toolEnvKey struct{}
+
+// withToolEnv attaches a copy of the conversation's variables to ctx
+func withToolEnv(ctx context.Context, env *ToolEnv) context.Context {
+	return context.WithValue(ctx, toolEnvKey{}, env.Snapshot())
+}
+
+// EnvFromContext returns a conversation variable inside a tool handler
+func EnvFromContext(ctx context.Context, key string) (string, bool) {
+	vars, _ := ctx.Value(toolEnvKey{}).(map[string]string)
+	value, ok := vars[key]
+	return value, ok
+}
+
+// savedConversation is the on-disk form of a conversation and its variables.
+// Values are stored in clear text, like the rest of the conversation.
+type savedConversation struct {
+	Messages []openai.ChatCompletionMessage `json:"messages"`
+	Env      map[string]string              `json:"env,omitempty"`
+	SavedAt  time.Time                      `json:"saved_at"`
+	Usage    TokenUsage                     `json:"usage"`
+	// ReportCard is the latest /wrapup report, if one was made
+	ReportCard *ReportCard `json:"report_card,omitempty"`
+}
+
+// conversationPath returns the file a named conversation is saved to
+func conversationPath(dir, name string) (string, error) {
+	if name == "" || filepath.Base(name) != name || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid conversation name %q", name)
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// SaveConversation writes the conversation and its variables to dir/name.json.
+// Tool-heavy turns are saved as an action summary; their raw tool calls and
+// results go to dir/traces/name.json so the saved history stays small.
+func (a *AgentWithTools) SaveConversation(dir, name string) (*SaveReport, error) {
+	path, err := conversationPath(dir, name)
+	if err != nil {
+		return nil, err
+	}
+	messages, archived := compactConversation(a.conversation)
+	report := &SaveReport{Path: path, Summarized: len(archived)}
+
+	// Turns summarized by an earlier save are still summarized in a loaded conversation
+	for turn, raw := range a.archivedTraces {
+		if _, ok := archived[turn]; !ok {
+			archived[turn] = raw
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create conversation directory: %w", err)
+	}
+
+	// Write the archive first, so a saved summary never points at a missing trace
+	if len(archived) > 0 {
+		report.TracePath = tracePath(dir, name)
+		if err := writeTraceArchive(report.TracePath, name, archived); err != nil {
+			return nil, err
+		}
+	}
+
+	// Variables may be credentials-adjacent (hosts, paths), so keep the file private
+	err = conversationFormat.WriteFile(path, savedConversation{
+		Messages:   messages,
+		Env:        a.env.Snapshot(),
+		SavedAt:    time.Now(),
+		Usage:      a.usage,
+		ReportCard: a.reportCard,
+	}, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write conversation: %w", err)
+	}
+	a.savedAs = name
+	return report, nil
+}
+
+// LoadConversation restores a saved conversation, its variables and the
+// archived traces of its summarized turns. Artifacts are not saved, so the
+// store starts empty.
+func (a *AgentWithTools) LoadConversation(dir, name string) error {
+	path, err := conversationPath(dir, name)
+	if err != nil {
+		return err
+	}
+	// Conversations saved by an earlier release are upgraded in place
+	var saved savedConversation
+	if err := conversationFormat.ReadFile(path, &saved); err != nil {
+		return fmt.Errorf("failed to load conversation: %w", err)
+	}
+	env := NewToolEnv()
+	for key, value := range saved.Env {
+		if err := env.Set(key, value); err != nil {
+			return fmt.Errorf("invalid variable in saved conversation: %w", err)
+		}
+	}
+	archived, err := readTraceArchive(tracePath(dir, name))
+	if err != nil {
+		return err
+	}
+
+	a.artifacts.Clear()
+	a.conversation = saved.Messages
+	a.env = env
+	a.archivedTraces = archived
+	a.usage = saved.Usage
+	a.reportCard = saved.ReportCard
+	a.savedAs = name
+	return nil
+}
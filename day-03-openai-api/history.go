@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// A turn is tool-heavy, and summarized when saved, once it makes this many
+// tool calls or its calls and results add up to this many characters
+const (
+	heavyTurnCalls = 3
+	heavyTurnChars = 2000
+	// summaryResultLen bounds each result in an action summary
+	summaryResultLen = 120
+)
+
+// actionSummaryPrefix starts the message that replaces a summarized turn's tool calls
+const actionSummaryPrefix = "Tool activity summary"
+
+// traceArchive is the on-disk form of the raw tool traces removed from a
+// saved conversation, keyed by turn number (the turn's user message, from 1)
+type traceArchive struct {
+	Conversation string                                 `json:"conversation"`
+	SavedAt      time.Time                              `json:"saved_at"`
+	Turns        map[int][]openai.ChatCompletionMessage `json:"turns"`
+}
+
+// SaveReport describes what SaveConversation wrote
+type SaveReport struct {
+	Path string
+	// Summarized is how many tool-heavy turns were replaced with an action summary
+	Summarized int
+	// TracePath is the archive of raw tool traces, empty if there is none
+	TracePath string
+}
+
+// tracePath returns the archive file for a named conversation's raw traces
+func tracePath(dir, name string) string {
+	return filepath.Join(dir, "traces", name+".json")
+}
+
+// compactConversation replaces the tool calls and results of every tool-heavy
+// turn with one action summary message. It returns the compacted messages
+// and the raw messages removed from each summarized turn.
+func compactConversation(messages []openai.ChatCompletionMessage) ([]openai.ChatCompletionMessage, map[int][]openai.ChatCompletionMessage) {
+	compacted := make([]openai.ChatCompletionMessage, 0, len(messages))
+	archived := make(map[int][]openai.ChatCompletionMessage)
+
+	turn := 0
+	start := 0
+	flush := func(end int) {
+		segment := messages[start:end]
+		if turn == 0 || !isToolHeavy(segment) {
+			compacted = append(compacted, segment...)
+			return
+		}
+		var tools []openai.ChatCompletionMessage
+		var summaryAt int
+		for _, msg := range segment {
+			switch {
+			case isToolMessage(msg):
+				if len(tools) == 0 {
+					summaryAt = len(compacted)
+					compacted = append(compacted, openai.ChatCompletionMessage{})
+				}
+				tools = append(tools, msg)
+			default:
+				compacted = append(compacted, msg)
+			}
+		}
+		compacted[summaryAt] = openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: summarizeTools(fmt.Sprintf("%s for turn %d (raw trace archived)", actionSummaryPrefix, turn), tools),
+		}
+		archived[turn] = tools
+	}
+
+	for i, msg := range messages {
+		if msg.Role == openai.ChatMessageRoleUser {
+			flush(i)
+			turn++
+			start = i
+		}
+	}
+	flush(len(messages))
+	return compacted, archived
+}
+
+// isToolMessage reports whether msg is a tool call or result
+func isToolMessage(msg openai.ChatCompletionMessage) bool {
+	return len(msg.ToolCalls) > 0 || msg.Role == openai.ChatMessageRoleTool
+}
+
+// isToolHeavy reports whether a turn's tool traces are worth summarizing
+func isToolHeavy(turn []openai.ChatCompletionMessage) bool {
+	calls, size := 0, 0
+	for _, msg := range turn {
+		for _, call := range msg.ToolCalls {
+			calls++
+			size += len(call.Function.Arguments)
+		}
+		if msg.Role == openai.ChatMessageRoleTool {
+			size += len(msg.Content)
+		}
+	}
+	return calls >= heavyTurnCalls || (calls > 0 && size >= heavyTurnChars)
+}
+
+// summarizeTools renders a turn's tool calls under header, one line each:
+// the tool, its arguments and the start of its result
+func summarizeTools(header string, tools []openai.ChatCompletionMessage) string {
+	var builder strings.Builder
+	builder.WriteString(header + ":\n")
+
+	// Tool results name the call they answer
+	results := make(map[string]string)
+	for _, msg := range tools {
+		if msg.Role == openai.ChatMessageRoleTool {
+			results[msg.ToolCallID] = msg.Content
+		}
+	}
+
+	for _, msg := range tools {
+		for _, call := range msg.ToolCalls {
+			result := "(no result)"
+			if content, ok := results[call.ID]; ok {
+				result = oneLine(content)
+			}
+			builder.WriteString(fmt.Sprintf("- %s → %s\n", summarizeCall(call.Function), result))
+		}
+	}
+	return strings.TrimRight(builder.String(), "\n")
+}
+
+// summarizeCall renders a call as name(key=value, ...)
+func summarizeCall(call openai.FunctionCall) string {
+	var args map[string]interface{}
+	if json.Unmarshal([]byte(call.Arguments), &args) != nil {
+		return call.Name + "(…)"
+	}
+	return call.Name + "(" + summarizeArgs(args) + ")"
+}
+
+// summarizeArgs renders arguments as key=value pairs in sorted order
+func summarizeArgs(args map[string]interface{}) string {
+	keys := make([]string, 0, len(args))
+	for key := range args {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = key + "=" + clip(formatArg(args[key]), collapsedArgLen)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// oneLine collapses a result to a single clipped line
+func oneLine(content string) string {
+	return clip(strings.Join(strings.Fields(content), " "), summaryResultLen)
+}
+
+// writeTraceArchive writes a conversation's raw traces to path
+func writeTraceArchive(path, name string, turns map[int][]openai.ChatCompletionMessage) error {
+	err := traceArchiveFormat.WriteFile(path, traceArchive{
+		Conversation: name,
+		SavedAt:      time.Now(),
+		Turns:        turns,
+	}, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write trace archive: %w", err)
+	}
+	return nil
+}
+
+// readTraceArchive loads a conversation's archived traces; a missing
+// archive is not an error
+func readTraceArchive(path string) (map[int][]openai.ChatCompletionMessage, error) {
+	var archive traceArchive
+	err := traceArchiveFormat.ReadFile(path, &archive)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load trace archive: %w", err)
+	}
+	return archive.Turns, nil
+}
+
+// ArchivedTrace returns the raw tool messages of a summarized turn of the
+// loaded conversation
+func (a *AgentWithTools) ArchivedTrace(turn int) ([]openai.ChatCompletionMessage, bool) {
+	messages, ok := a.archivedTraces[turn]
+	return messages, ok
+}
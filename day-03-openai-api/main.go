@@ -5,43 +5,75 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
-	"math"
+	"log/slog"
+	"net/http"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/sakibmulla/agentic-ai/costs"
+	"github.com/sakibmulla/agentic-ai/endpoint"
+	"github.com/sakibmulla/agentic-ai/logging"
+	"github.com/sakibmulla/agentic-ai/settings"
+	"github.com/sakibmulla/agentic-ai/tools"
 	"github.com/sashabaranov/go-openai"
-	"github.com/sashabaranov/go-openai/jsonschema"
 )
 
-// Tool represents a function that the agent can call
-type Tool struct {
-	Definition openai.FunctionDefinition
-	Handler    func(args map[string]interface{}) (string, error)
-}
+// agentModel is the chat model the agent uses
+const agentModel = openai.GPT3Dot5Turbo
+
+// agentTemperature is the sampling temperature for every request
+const agentTemperature = 0.7
+
+// agentSystemPrompt is the persona the agent starts every conversation with
+const agentSystemPrompt = "You are a helpful AI assistant with access to various tools. Use the available tools when needed to provide accurate and helpful responses."
 
 // AgentWithTools represents an AI agent that can use tools
 type AgentWithTools struct {
-	client       *openai.Client
-	tools        map[string]Tool
+	client *openai.Client
+	// tools are run with ctx carrying the conversation's variables (see EnvFromContext)
+	tools        *tools.Registry
 	conversation []openai.ChatCompletionMessage
+	artifacts    *ArtifactStore
+	env          *ToolEnv
+	// archivedTraces holds the raw tool messages of a loaded conversation's summarized turns
+	archivedTraces map[int][]openai.ChatCompletionMessage
+	// usage counts the tokens spent on the conversation
+	usage      TokenUsage
+	reportCard *ReportCard
+	// savedAs is the name the conversation was last saved or loaded as
+	savedAs string
+
+	// OnToolCall, when set, is invoked after each tool call completes
+	OnToolCall func(trace ToolCallTrace)
+	lastCalls  []ToolCallTrace
 }
 
-// NewAgentWithTools creates a new agent with tool capabilities
-func NewAgentWithTools(apiKey string) *AgentWithTools {
+// NewAgentWithTools creates a new agent with tool capabilities that calls
+// api. Its calls are budgeted and priced by limiter, when given.
+func NewAgentWithTools(apiKey string, api endpoint.Endpoint, limiter *costs.Limiter) *AgentWithTools {
+	clientConfig := api.Config(apiKey)
+	// Ollama's requests are sent with its local models, limiter or not
+	httpClient := &http.Client{}
+	if limiter != nil {
+		httpClient = limiter.HTTPClient()
+	}
+	clientConfig.HTTPClient = api.HTTPClient(httpClient)
+
 	agent := &AgentWithTools{
-		client:       openai.NewClient(apiKey),
-		tools:        make(map[string]Tool),
+		client:       openai.NewClientWithConfig(clientConfig),
+		tools:        tools.NewRegistry(),
 		conversation: []openai.ChatCompletionMessage{},
+		artifacts:    NewArtifactStore(),
+		env:          NewToolEnv(),
 	}
 
 	// Add system message
 	agent.conversation = append(agent.conversation, openai.ChatCompletionMessage{
 		Role:    openai.ChatMessageRoleSystem,
-		Content: "You are a helpful AI assistant with access to various tools. Use the available tools when needed to provide accurate and helpful responses.",
+		Content: agentSystemPrompt,
 	})
 
 	// Register built-in tools
@@ -52,156 +84,102 @@ func NewAgentWithTools(apiKey string) *AgentWithTools {
 
 // registerBuiltinTools adds the default tools to the agent
 func (a *AgentWithTools) registerBuiltinTools() {
-	// Calculator tool
-	a.RegisterTool("calculator", Tool{
-		Definition: openai.FunctionDefinition{
-			Name:        "calculator",
-			Description: "Perform mathematical calculations including basic arithmetic, trigonometry, and advanced math functions",
-			Parameters: jsonschema.Definition{
-				Type: jsonschema.Object,
-				Properties: map[string]jsonschema.Definition{
-					"operation": {
-						Type:        jsonschema.String,
-						Description: "The mathematical operation to perform (add, subtract, multiply, divide, power, sqrt, sin, cos, tan, log)",
-					},
-					"a": {
-						Type:        jsonschema.Number,
-						Description: "First number",
-					},
-					"b": {
-						Type:        jsonschema.Number,
-						Description: "Second number (optional for single-operand operations)",
-					},
-				},
-				Required: []string{"operation", "a"},
-			},
-		},
-		Handler: a.handleCalculator,
-	})
+	// Shared calculator and text analyzer
+	a.tools.MustRegister(tools.Calculator(), tools.TextAnalysis())
 
 	// Current time tool
-	a.RegisterTool("get_current_time", Tool{
-		Definition: openai.FunctionDefinition{
-			Name:        "get_current_time",
-			Description: "Get the current date and time",
-			Parameters: jsonschema.Definition{
-				Type: jsonschema.Object,
-				Properties: map[string]jsonschema.Definition{
-					"format": {
-						Type:        jsonschema.String,
-						Description: "Time format preference (default, iso, unix)",
-						Enum:        []string{"default", "iso", "unix"},
-					},
+	a.tools.MustRegister(tools.Tool{
+		Name:        "get_current_time",
+		Description: "Get the current date and time",
+		Parameters: tools.Schema{
+			Type: tools.Object,
+			Properties: map[string]tools.Schema{
+				"format": {
+					Type:        tools.String,
+					Description: "Time format preference (default, iso, unix)",
+					Enum:        []string{"default", "iso", "unix"},
 				},
 			},
 		},
 		Handler: a.handleCurrentTime,
 	})
 
-	// Text analyzer tool
-	a.RegisterTool("analyze_text", Tool{
-		Definition: openai.FunctionDefinition{
-			Name:        "analyze_text",
-			Description: "Analyze text and provide statistics like word count, character count, and reading time",
-			Parameters: jsonschema.Definition{
-				Type: jsonschema.Object,
-				Properties: map[string]jsonschema.Definition{
-					"text": {
-						Type:        jsonschema.String,
-						Description: "The text to analyze",
-					},
-				},
-				Required: []string{"text"},
-			},
-		},
-		Handler: a.handleTextAnalysis,
-	})
-}
+	// Code search tool over the local Go module
+	codeRoot := os.Getenv("CODE_SEARCH_ROOT")
+	if codeRoot == "" {
+		codeRoot = "."
+	}
+	a.registerCodeSearchTool(codeRoot)
 
-// RegisterTool adds a new tool to the agent
-func (a *AgentWithTools) RegisterTool(name string, tool Tool) {
-	a.tools[name] = tool
-}
+	// fetch_url, and web_search when WEB_SEARCH_PROVIDER is set
+	if web, err := tools.WebFromEnv(); err != nil {
+		slog.Warn("web tools disabled", "error", err)
+	} else {
+		a.tools.MustRegister(web...)
+	}
 
-// handleCalculator implements the calculator tool
-func (a *AgentWithTools) handleCalculator(args map[string]interface{}) (string, error) {
-	operation, ok := args["operation"].(string)
-	if !ok {
-		return "", fmt.Errorf("operation must be a string")
+	// read_file, list_dir and write_file, confined to FILE_TOOLS_ROOTS
+	if files, err := tools.FilesFromEnv(); err != nil {
+		slog.Warn("file tools disabled", "error", err)
+	} else {
+		a.tools.MustRegister(files...)
 	}
 
-	aVal, ok := args["a"].(float64)
-	if !ok {
-		return "", fmt.Errorf("parameter 'a' must be a number")
+	// execute_code, when EXECUTE_CODE picks a sandbox runtime
+	if execTools, err := tools.ExecFromEnv(); err != nil {
+		slog.Warn("code execution disabled", "error", err)
+	} else {
+		a.tools.MustRegister(execTools...)
 	}
 
-	var result float64
+	// describe_schema and run_query, when SQL_TOOLS_DSN names a database
+	if sqlTools, err := tools.SQLFromEnv(); err != nil {
+		slog.Warn("SQL tools disabled", "error", err)
+	} else {
+		a.tools.MustRegister(sqlTools...)
+	}
 
-	switch operation {
-	case "add":
-		bVal, ok := args["b"].(float64)
-		if !ok {
-			return "", fmt.Errorf("parameter 'b' required for addition")
-		}
-		result = aVal + bVal
-	case "subtract":
-		bVal, ok := args["b"].(float64)
-		if !ok {
-			return "", fmt.Errorf("parameter 'b' required for subtraction")
-		}
-		result = aVal - bVal
-	case "multiply":
-		bVal, ok := args["b"].(float64)
-		if !ok {
-			return "", fmt.Errorf("parameter 'b' required for multiplication")
-		}
-		result = aVal * bVal
-	case "divide":
-		bVal, ok := args["b"].(float64)
-		if !ok {
-			return "", fmt.Errorf("parameter 'b' required for division")
-		}
-		if bVal == 0 {
-			return "", fmt.Errorf("division by zero")
-		}
-		result = aVal / bVal
-	case "power":
-		bVal, ok := args["b"].(float64)
-		if !ok {
-			return "", fmt.Errorf("parameter 'b' required for power operation")
-		}
-		result = math.Pow(aVal, bVal)
-	case "sqrt":
-		if aVal < 0 {
-			return "", fmt.Errorf("cannot take square root of negative number")
-		}
-		result = math.Sqrt(aVal)
-	case "sin":
-		result = math.Sin(aVal)
-	case "cos":
-		result = math.Cos(aVal)
-	case "tan":
-		result = math.Tan(aVal)
-	case "log":
-		if aVal <= 0 {
-			return "", fmt.Errorf("logarithm requires positive number")
-		}
-		result = math.Log(aVal)
-	default:
-		return "", fmt.Errorf("unknown operation: %s", operation)
+	// Knowledge base search over day 8's vector store (go run . serve-search there)
+	if endpoint := os.Getenv("DOCUMENT_SEARCH_URL"); endpoint != "" {
+		a.tools.MustRegister(tools.SearchDocuments(tools.RemoteDocuments(endpoint)))
 	}
 
-	return fmt.Sprintf("%.6f", result), nil
+	// Charts from aggregated numbers
+	a.registerPlotTool()
+
+	// On-demand access to large tool results kept out of the conversation
+	a.registerArtifactTool()
+
+	// Self-description tool
+	a.registerCapabilitiesTool()
+}
+
+// RegisterTool adds a new tool to the agent
+func (a *AgentWithTools) RegisterTool(tool tools.Tool) error {
+	return a.tools.Register(tool)
+}
+
+// Tools returns the agent's tool registry
+func (a *AgentWithTools) Tools() *tools.Registry {
+	return a.tools
 }
 
 // handleCurrentTime implements the current time tool
-func (a *AgentWithTools) handleCurrentTime(args map[string]interface{}) (string, error) {
+func (a *AgentWithTools) handleCurrentTime(ctx context.Context, args map[string]interface{}) (string, error) {
 	format := "default"
 	if f, ok := args["format"].(string); ok {
 		format = f
 	}
 
+	// A TZ conversation variable (e.g. Europe/Berlin) selects the time zone
 	now := time.Now()
+	if tz, ok := EnvFromContext(ctx, "TZ"); ok {
+		location, err := time.LoadLocation(tz)
+		if err != nil {
+			return "", fmt.Errorf("invalid TZ variable: %w", err)
+		}
+		now = now.In(location)
+	}
 
 	switch format {
 	case "iso":
@@ -213,32 +191,8 @@ func (a *AgentWithTools) handleCurrentTime(args map[string]interface{}) (string,
 	}
 }
 
-// handleTextAnalysis implements the text analysis tool
-func (a *AgentWithTools) handleTextAnalysis(args map[string]interface{}) (string, error) {
-	text, ok := args["text"].(string)
-	if !ok {
-		return "", fmt.Errorf("text parameter must be a string")
-	}
-
-	words := strings.Fields(text)
-	chars := len(text)
-	charsNoSpaces := len(strings.ReplaceAll(text, " ", ""))
-	lines := len(strings.Split(text, "\n"))
-
-	// Estimate reading time (average 200 words per minute)
-	readingTime := float64(len(words)) / 200.0
-
-	analysis := fmt.Sprintf(`Text Analysis Results:
-- Characters: %d (including spaces), %d (excluding spaces)
-- Words: %d
-- Lines: %d
-- Estimated reading time: %.1f minutes`,
-		chars, charsNoSpaces, len(words), lines, readingTime)
-
-	return analysis, nil
-}
-
-// Chat processes a user message and handles any function calls
+// Chat processes a user message and handles any tool calls. Independent
+// tool calls the model makes in one turn run concurrently.
 func (a *AgentWithTools) Chat(ctx context.Context, message string) (string, error) {
 	// Add user message to conversation
 	a.conversation = append(a.conversation, openai.ChatCompletionMessage{
@@ -246,18 +200,28 @@ func (a *AgentWithTools) Chat(ctx context.Context, message string) (string, erro
 		Content: message,
 	})
 
-	// Convert tools to OpenAI function definitions
-	var functions []openai.FunctionDefinition
-	for _, tool := range a.tools {
-		functions = append(functions, tool.Definition)
+	a.lastCalls = nil
+
+	// Convert registered tools to OpenAI tool definitions
+	var available []openai.Tool
+	for _, definition := range a.tools.Definitions() {
+		available = append(available, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        definition.Name,
+				Description: definition.Description,
+				Parameters:  definition.Parameters,
+			},
+		})
 	}
 
 	for {
+		// Conversation variables never reach the model, only their $NAME placeholders
 		req := openai.ChatCompletionRequest{
-			Model:       openai.GPT3Dot5Turbo,
-			Messages:    a.conversation,
-			Functions:   functions,
-			Temperature: 0.7,
+			Model:       agentModel,
+			Messages:    a.env.RedactMessages(a.conversation),
+			Tools:       available,
+			Temperature: agentTemperature,
 		}
 
 		resp, err := a.client.CreateChatCompletion(ctx, req)
@@ -265,6 +229,7 @@ func (a *AgentWithTools) Chat(ctx context.Context, message string) (string, erro
 			return "", fmt.Errorf("API call failed: %w", err)
 		}
 
+		a.usage.add(resp.Usage)
 		if len(resp.Choices) == 0 {
 			return "", fmt.Errorf("no response choices returned")
 		}
@@ -274,86 +239,215 @@ func (a *AgentWithTools) Chat(ctx context.Context, message string) (string, erro
 		// Add assistant's response to conversation
 		a.conversation = append(a.conversation, choice.Message)
 
-		// Check if the model wants to call a function
-		if choice.Message.FunctionCall != nil {
-			funcCall := choice.Message.FunctionCall
-
-			fmt.Printf("🔧 Calling tool: %s\n", funcCall.Name)
-
-			// Parse function arguments
-			var args map[string]interface{}
-			if err := json.Unmarshal([]byte(funcCall.Arguments), &args); err != nil {
-				return "", fmt.Errorf("failed to parse function arguments: %w", err)
-			}
-
-			// Execute the function
-			tool, exists := a.tools[funcCall.Name]
-			if !exists {
-				return "", fmt.Errorf("unknown function: %s", funcCall.Name)
-			}
-
-			result, err := tool.Handler(args)
-			if err != nil {
-				result = fmt.Sprintf("Error: %v", err)
-			}
-
-			// Add function result to conversation
-			a.conversation = append(a.conversation, openai.ChatCompletionMessage{
-				Role:    openai.ChatMessageRoleFunction,
-				Name:    funcCall.Name,
-				Content: result,
-			})
-
-			// Continue the loop to get the model's response to the function result
-			continue
+		// No tool calls, return the response
+		if len(choice.Message.ToolCalls) == 0 {
+			return choice.Message.Content, nil
 		}
 
-		// No function call, return the response
-		return choice.Message.Content, nil
+		// Every call gets a tool message, in call order, before the model
+		// is asked to continue
+		a.conversation = append(a.conversation, a.runToolCalls(ctx, choice.Message.ToolCalls)...)
 	}
 }
 
+// LastToolCalls returns the tool calls made while answering the last message
+func (a *AgentWithTools) LastToolCalls() []ToolCallTrace {
+	return a.lastCalls
+}
+
 // GetConversationHistory returns the current conversation
 func (a *AgentWithTools) GetConversationHistory() []openai.ChatCompletionMessage {
 	return a.conversation
 }
 
-// ClearConversation resets the conversation history
+// Artifacts returns the store of large tool results
+func (a *AgentWithTools) Artifacts() *ArtifactStore {
+	return a.artifacts
+}
+
+// Env returns the conversation-scoped variables passed to tools
+func (a *AgentWithTools) Env() *ToolEnv {
+	return a.env
+}
+
+// SavedAs returns the name the conversation was last saved or loaded as
+func (a *AgentWithTools) SavedAs() string {
+	return a.savedAs
+}
+
+// ClearConversation resets the conversation history, its variables and the artifacts it referenced
 func (a *AgentWithTools) ClearConversation() {
+	a.artifacts.Clear()
+	a.env = NewToolEnv()
+	a.archivedTraces = nil
+	a.usage = TokenUsage{}
+	a.reportCard = nil
+	a.savedAs = ""
 	a.conversation = []openai.ChatCompletionMessage{
 		{
 			Role:    openai.ChatMessageRoleSystem,
-			Content: "You are a helpful AI assistant with access to various tools. Use the available tools when needed to provide accurate and helpful responses.",
+			Content: agentSystemPrompt,
 		},
 	}
 }
 
+// handleEnvCommand implements /env, /env set KEY VALUE and /env unset KEY
+func handleEnvCommand(agent *AgentWithTools, args []string) error {
+	env := agent.Env()
+	switch {
+	case len(args) == 0:
+		keys := env.Keys()
+		if len(keys) == 0 {
+			fmt.Println("No conversation variables set.")
+		}
+		for _, key := range keys {
+			value, _ := env.Get(key)
+			fmt.Printf("🔧 %s=%s\n", key, value)
+		}
+		return nil
+
+	case args[0] == "set" && len(args) >= 3:
+		if err := env.Set(args[1], strings.Join(args[2:], " ")); err != nil {
+			return err
+		}
+		fmt.Printf("🔧 %s set for this conversation (the model sees $%s)\n", args[1], args[1])
+		return nil
+
+	case args[0] == "unset" && len(args) == 2:
+		if !env.Unset(args[1]) {
+			return fmt.Errorf("%s is not set", args[1])
+		}
+		fmt.Printf("🔧 %s removed\n", args[1])
+		return nil
+
+	default:
+		return fmt.Errorf("usage: /env, /env set KEY VALUE, /env unset KEY")
+	}
+}
+
+// configKeys lists the settings this day reads, for `config show` and
+// `config validate`
+var configKeys = settings.Common.With(
+	settings.Key{Name: "CONVERSATION_DIR", Kind: settings.String},
+	settings.Key{Name: "CAPABILITIES_ADDR", Kind: settings.String},
+	settings.Key{Name: "PLANNER_MAX_ITERATIONS", Kind: settings.Int},
+	settings.Key{Name: "DOCUMENT_SEARCH_URL", Kind: settings.String},
+	settings.Key{Name: "CODE_SEARCH_ROOT", Kind: settings.String},
+	settings.Key{Name: "WEB_SEARCH_PROVIDER", Kind: settings.String},
+	settings.Key{Name: "BING_API_KEY", Kind: settings.String, Secret: true},
+	settings.Key{Name: "SERPAPI_API_KEY", Kind: settings.String, Secret: true},
+	settings.Key{Name: "FETCH_ALLOW_DOMAINS", Kind: settings.String},
+	settings.Key{Name: "FETCH_DENY_DOMAINS", Kind: settings.String},
+	settings.Key{Name: "FETCH_MAX_BYTES", Kind: settings.Int},
+	settings.Key{Name: "FETCH_TIMEOUT_SECONDS", Kind: settings.Int},
+	settings.Key{Name: "FILE_TOOLS_ROOTS", Kind: settings.String},
+	settings.Key{Name: "FILE_TOOLS_READ_ONLY", Kind: settings.Bool},
+	settings.Key{Name: "FILE_TOOLS_MAX_BYTES", Kind: settings.Int},
+	settings.Key{Name: "EXECUTE_CODE", Kind: settings.String},
+	settings.Key{Name: "EXECUTE_CODE_TIMEOUT_SECONDS", Kind: settings.Int},
+	settings.Key{Name: "EXECUTE_CODE_MEMORY_MB", Kind: settings.Int},
+	settings.Key{Name: "SQL_TOOLS_DRIVER", Kind: settings.String},
+	settings.Key{Name: "SQL_TOOLS_DSN", Kind: settings.String, Secret: true},
+	settings.Key{Name: "SQL_TOOLS_READ_ONLY", Kind: settings.Bool},
+	settings.Key{Name: "SQL_TOOLS_MAX_ROWS", Kind: settings.Int},
+	settings.Key{Name: "SQL_TOOLS_TIMEOUT_SECONDS", Kind: settings.Int},
+)
+
 func main() {
-	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using system environment variables")
+	// Load environment variables, then the config file's CONFIG_PROFILE,
+	// which doesn't replace them
+	envErr := godotenv.Load()
+	settingsErr := settings.Load()
+	// LOG_LEVEL and LOG_FORMAT may come from .env or the config file
+	logging.Setup(slog.LevelWarn)
+	if envErr != nil {
+		slog.Info("no .env file found, using system environment variables")
+	}
+	if settingsErr != nil {
+		logging.Fatal("invalid config file", "error", settingsErr)
+	}
+	// "config show" and "config validate" report the settings instead of running
+	if run, err := configKeys.Start(os.Args[1:], os.Stdout); err != nil {
+		logging.Fatal("invalid configuration", "error", err)
+	} else if !run {
+		return
+	}
+
+	// OPENAI_BASE_URL and OPENAI_API_TYPE point the client at Azure OpenAI
+	// or an OpenAI-compatible server such as vLLM
+	api, err := endpoint.FromEnv()
+	if err != nil {
+		logging.Fatal("invalid endpoint", "error", err)
 	}
 
 	// Get OpenAI API key
 	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		log.Fatal("OPENAI_API_KEY environment variable is required")
+	if apiKey == "" && api.RequiresKey() {
+		logging.Fatal("OPENAI_API_KEY environment variable is required")
+	}
+
+	// Budgets, the cost ledger and prices come from BUDGET, BUDGET_DOWNGRADES,
+	// COST_LEDGER_PATH and PRICING_PATH
+	limiter, err := costs.FromEnv()
+	if err != nil {
+		logging.Fatal("invalid budget", "error", err)
 	}
 
 	// Create agent with tools
-	agent := NewAgentWithTools(apiKey)
+	agent := NewAgentWithTools(apiKey, api, limiter)
+	agent.OnToolCall = func(trace ToolCallTrace) {
+		fmt.Print(trace.Render(false))
+	}
+
+	conversationDir := os.Getenv("CONVERSATION_DIR")
+	if conversationDir == "" {
+		conversationDir = "./conversations"
+	}
+
+	// Optionally serve the capability description and saved report cards
+	// for client UIs and orchestrators
+	if addr := os.Getenv("CAPABILITIES_ADDR"); addr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/capabilities", agent.CapabilitiesHandler())
+		mux.Handle("/conversations/", ReportCardHandler(conversationDir))
+		go func() {
+			fmt.Printf("🔎 Capabilities endpoint on http://%s/capabilities, report cards on /conversations/<name>/report\n", addr)
+			if err := http.ListenAndServe(addr, mux); err != nil {
+				slog.Error("capabilities server stopped", "error", err)
+			}
+		}()
+	}
 
 	fmt.Println("🤖 Function-Calling Agent Ready!")
 	fmt.Println("\nAvailable tools:")
-	for name, tool := range agent.tools {
-		fmt.Printf("- %s: %s\n", name, tool.Definition.Description)
+	for _, tool := range agent.Tools().Tools() {
+		fmt.Printf("- %s: %s\n", tool.Name, tool.Description)
 	}
 	fmt.Println("\nTry asking me to:")
 	fmt.Println("- Calculate something: 'What is 15 * 23?'")
 	fmt.Println("- Get current time: 'What time is it?'")
 	fmt.Println("- Analyze text: 'Analyze this text: Hello world'")
 	fmt.Println("- Complex tasks: 'Calculate the area of a circle with radius 5'")
-	fmt.Println("\nCommands: 'clear' to reset conversation, 'quit' to exit")
+	fmt.Println("- Explore code: 'Where is RegisterTool defined and who calls it?'")
+	fmt.Println("- Chart data: 'Plot monthly sales 12, 15, 9, 20 for Jan to Apr as a bar chart'")
+	if _, ok := agent.Tools().Get("web_search"); ok {
+		fmt.Println("- Current events: 'What's new in the latest Go release?'")
+	}
+	if _, ok := agent.Tools().Get("read_file"); ok {
+		fmt.Println("- Work on files: 'Read go.mod and list the packages under cmd/'")
+	}
+	if _, ok := agent.Tools().Get("execute_code"); ok {
+		fmt.Println("- Run code: 'Write a Python function for the nth prime and test it on 1000'")
+	}
+	if _, ok := agent.Tools().Get("run_query"); ok {
+		fmt.Println("- Query data: 'Which five customers spent the most last quarter?'")
+	}
+	if _, ok := agent.Tools().Get("search_documents"); ok {
+		fmt.Println("- Look things up: 'What does the knowledge base say about deep learning?'")
+	}
+	fmt.Println("\nCommands: 'clear' to reset conversation, 'calls' to expand the last tool calls, 'artifacts' to list stored tool results, 'artifact <id>' to print one, 'capabilities' to describe this agent,")
+	fmt.Println("'/env set KEY VALUE' to give tools a conversation variable (/env lists, /env unset KEY removes), 'save <name>'/'load <name>' to keep a conversation, 'trace <turn>' to show a summarized turn's raw tool calls,")
+	fmt.Println("'/plan <goal>' to work toward a goal step by step with the tools, '/wrapup' for a report card on the conversation, 'quit' to exit")
 
 	scanner := bufio.NewScanner(os.Stdin)
 	ctx := context.Background()
@@ -380,6 +474,136 @@ func main() {
 			continue
 		}
 
+		if input == "/env" || strings.HasPrefix(input, "/env ") {
+			if err := handleEnvCommand(agent, strings.Fields(input)[1:]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
+			continue
+		}
+
+		if strings.HasPrefix(strings.ToLower(input), "save ") {
+			name := strings.TrimSpace(input[len("save "):])
+			report, err := agent.SaveConversation(conversationDir, name)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			fmt.Printf("💾 Conversation saved as '%s'\n", name)
+			if report.Summarized > 0 {
+				fmt.Printf("🗜️ %d tool-heavy turn(s) summarized; raw traces archived in %s\n", report.Summarized, report.TracePath)
+			}
+			continue
+		}
+
+		if strings.HasPrefix(strings.ToLower(input), "load ") {
+			name := strings.TrimSpace(input[len("load "):])
+			if err := agent.LoadConversation(conversationDir, name); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			fmt.Printf("📂 Conversation '%s' loaded with %d variable(s)\n", name, len(agent.Env().Keys()))
+			continue
+		}
+
+		if strings.HasPrefix(input, "/plan ") {
+			planner := agent.Planner(plannerConfigFromEnv())
+			planner.OnStep = func(step PlanStep) {
+				fmt.Print(step.Render())
+			}
+			result, err := planner.Run(ctx, strings.TrimSpace(input[len("/plan "):]))
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			status := "✅"
+			if !result.Completed {
+				status = "⏱️ (step budget used up)"
+			}
+			fmt.Printf("🗺️  Plan: %s\n", strings.Join(result.Plans[0], " → "))
+			fmt.Printf("AI %s: %s\n", status, result.Answer)
+			fmt.Printf("📊 %d step(s), %d plan revision(s), %d tokens\n\n", len(result.Steps), len(result.Plans)-1, result.Usage.TotalTokens)
+			continue
+		}
+
+		if input == "/wrapup" {
+			card, err := agent.WrapUp(ctx)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			card.Print()
+			if name := agent.SavedAs(); name != "" {
+				if _, err := agent.SaveConversation(conversationDir, name); err != nil {
+					fmt.Printf("Error: %v\n", err)
+					continue
+				}
+				fmt.Printf("💾 Report card saved with '%s'\n", name)
+			} else {
+				fmt.Println("Use 'save <name>' to keep the report card with the conversation.")
+			}
+			continue
+		}
+
+		if strings.ToLower(input) == "capabilities" {
+			data, _ := json.MarshalIndent(agent.DescribeCapabilities(), "", "  ")
+			fmt.Println(string(data))
+			continue
+		}
+
+		if strings.ToLower(input) == "artifacts" {
+			artifacts := agent.Artifacts().List()
+			if len(artifacts) == 0 {
+				fmt.Println("No stored tool results.")
+			}
+			for _, artifact := range artifacts {
+				fmt.Printf("📦 %s  %s (%d chars)  %s\n", artifact.ID, artifact.Tool, len(artifact.Content), artifact.CreatedAt.Format("15:04:05"))
+			}
+			continue
+		}
+
+		if strings.HasPrefix(strings.ToLower(input), "artifact ") {
+			artifact, err := agent.Artifacts().Get(strings.TrimSpace(input[len("artifact "):]))
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			fmt.Println(artifact.Content)
+			continue
+		}
+
+		if strings.HasPrefix(strings.ToLower(input), "trace ") {
+			turn, err := strconv.Atoi(strings.TrimSpace(input[len("trace "):]))
+			if err != nil {
+				fmt.Println("Usage: trace <turn>")
+				continue
+			}
+			messages, ok := agent.ArchivedTrace(turn)
+			if !ok {
+				fmt.Printf("No archived trace for turn %d.\n", turn)
+				continue
+			}
+			for _, msg := range messages {
+				for _, call := range msg.ToolCalls {
+					fmt.Printf("🔧 %s %s\n", call.Function.Name, call.Function.Arguments)
+				}
+				if len(msg.ToolCalls) == 0 {
+					fmt.Printf("→ %s\n", msg.Content)
+				}
+			}
+			continue
+		}
+
+		if strings.ToLower(input) == "calls" {
+			calls := agent.LastToolCalls()
+			if len(calls) == 0 {
+				fmt.Println("No tool calls in the last turn.")
+			}
+			for _, call := range calls {
+				fmt.Print(call.Render(true))
+			}
+			continue
+		}
+
 		response, err := agent.Chat(ctx, input)
 		if err != nil {
 			fmt.Printf("Error: %v\n", err)
@@ -390,6 +614,6 @@ func main() {
 	}
 
 	if err := scanner.Err(); err != nil {
-		log.Printf("Error reading input: %v", err)
+		slog.Error("failed to read input", "error", err)
 	}
 }
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/sakibmulla/agentic-ai/persist"
+	"github.com/sashabaranov/go-openai"
+)
+
+// Saved conversations and trace archives are versioned. Version 2 stores
+// tool calls as the tools API does; version 1 files, written before the
+// agent moved to the tools API, hold function calls and results instead.
+var (
+	conversationFormat = persist.NewFormat("conversation", 2).Migrate(1, migrateConversationV1)
+	traceArchiveFormat = persist.NewFormat("trace-archive", 2).Migrate(1, migrateTraceArchiveV1)
+)
+
+// migrateConversationV1 converts a conversation's function messages to tool messages
+func migrateConversationV1(data json.RawMessage) (json.RawMessage, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	var messages []openai.ChatCompletionMessage
+	if err := json.Unmarshal(doc["messages"], &messages); err != nil {
+		return nil, fmt.Errorf("failed to parse messages: %w", err)
+	}
+	converted, err := json.Marshal(toToolMessages(messages))
+	if err != nil {
+		return nil, err
+	}
+	doc["messages"] = converted
+	return json.Marshal(doc)
+}
+
+// migrateTraceArchiveV1 converts every archived turn's function messages to
+// tool messages
+func migrateTraceArchiveV1(data json.RawMessage) (json.RawMessage, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	var turns map[int][]openai.ChatCompletionMessage
+	if err := json.Unmarshal(doc["turns"], &turns); err != nil {
+		return nil, fmt.Errorf("failed to parse turns: %w", err)
+	}
+	for turn, messages := range turns {
+		turns[turn] = toToolMessages(messages)
+	}
+	converted, err := json.Marshal(turns)
+	if err != nil {
+		return nil, err
+	}
+	doc["turns"] = converted
+	return json.Marshal(doc)
+}
+
+// toToolMessages rewrites each function call as a single tool call and the
+// function result after it as that call's tool result. Call IDs are made up
+// from the message index; a result with no call before it is left as is.
+func toToolMessages(messages []openai.ChatCompletionMessage) []openai.ChatCompletionMessage {
+	converted := make([]openai.ChatCompletionMessage, len(messages))
+	pending := ""
+	for i, msg := range messages {
+		switch {
+		case msg.FunctionCall != nil:
+			pending = fmt.Sprintf("call_v1_%d", i)
+			msg.ToolCalls = []openai.ToolCall{{ID: pending, Type: openai.ToolTypeFunction, Function: *msg.FunctionCall}}
+			msg.FunctionCall = nil
+
+		case msg.Role == openai.ChatMessageRoleFunction && pending != "":
+			msg.Role = openai.ChatMessageRoleTool
+			msg.ToolCallID = pending
+			pending = ""
+		}
+		converted[i] = msg
+	}
+	return converted
+}
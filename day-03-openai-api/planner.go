@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sakibmulla/agentic-ai/tools"
+	"github.com/sashabaranov/go-openai"
+)
+
+// maxObservation bounds a tool result as the planner's model sees it
+const maxObservation = 1500
+
+// PlannerConfig controls a PlannerAgent
+type PlannerConfig struct {
+	// MaxIterations bounds the think/act/observe steps taken for one goal
+	MaxIterations int
+	Model         string
+	Temperature   float32
+}
+
+// DefaultPlannerConfig returns sensible defaults for the planner
+func DefaultPlannerConfig() PlannerConfig {
+	return PlannerConfig{
+		MaxIterations: 10,
+		Model:         agentModel,
+		Temperature:   0.2,
+	}
+}
+
+// PlanStep is one think/act/observe iteration of the loop
+type PlanStep struct {
+	Iteration int    `json:"iteration"`
+	Thought   string `json:"thought"`
+	// Action is the tool call the step made, if any
+	Action      *ToolCallTrace `json:"action,omitempty"`
+	Observation string         `json:"observation,omitempty"`
+	// Plan is set when the step revised the plan
+	Plan []string `json:"plan,omitempty"`
+}
+
+// PlanResult is the outcome of working toward a goal
+type PlanResult struct {
+	Goal string `json:"goal"`
+	// Plans holds the initial plan and every revision, in order
+	Plans  [][]string `json:"plans"`
+	Steps  []PlanStep `json:"steps"`
+	Answer string     `json:"answer"`
+	// Completed is false when the iteration budget ran out first
+	Completed bool       `json:"completed"`
+	Usage     TokenUsage `json:"usage"`
+}
+
+// PlannerAgent works toward a goal in a ReAct loop: it breaks the goal into
+// a plan, then repeatedly thinks, calls a tool from the registry and
+// observes the result, revising the plan when an observation calls for it,
+// until it can answer or runs out of iterations
+type PlannerAgent struct {
+	client *openai.Client
+	tools  *tools.Registry
+	env    *ToolEnv
+	config PlannerConfig
+
+	// OnStep, when set, is invoked after each step completes
+	OnStep func(step PlanStep)
+}
+
+// NewPlannerAgent creates a planner that calls the tools in registry. Tools
+// see the variables in env, and the model only their placeholders.
+func NewPlannerAgent(client *openai.Client, registry *tools.Registry, env *ToolEnv, config PlannerConfig) *PlannerAgent {
+	if env == nil {
+		env = NewToolEnv()
+	}
+	if config.MaxIterations <= 0 {
+		config.MaxIterations = DefaultPlannerConfig().MaxIterations
+	}
+	if config.Model == "" {
+		config.Model = agentModel
+	}
+	return &PlannerAgent{client: client, tools: registry, env: env, config: config}
+}
+
+// Planner returns a planner sharing the agent's client, tools and conversation variables
+func (a *AgentWithTools) Planner(config PlannerConfig) *PlannerAgent {
+	return NewPlannerAgent(a.client, a.tools, a.env, config)
+}
+
+// plannerReply is the JSON the model answers every planner request with
+type plannerReply struct {
+	Thought string   `json:"thought"`
+	Plan    []string `json:"plan,omitempty"`
+	Action  *struct {
+		Tool string                 `json:"tool"`
+		Args map[string]interface{} `json:"args"`
+	} `json:"action,omitempty"`
+	FinalAnswer string `json:"final_answer,omitempty"`
+}
+
+// replyObjectPattern finds the JSON object in a reply that wraps it in prose
+var replyObjectPattern = regexp.MustCompile(`(?s)\{.*\}`)
+
+// Run works toward goal. An error is returned only when the model can't be
+// reached; tool failures and malformed replies are observed like any other
+// result and cost an iteration.
+func (p *PlannerAgent) Run(ctx context.Context, goal string) (*PlanResult, error) {
+	result := &PlanResult{Goal: goal}
+	messages := []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: p.systemPrompt()},
+		{Role: openai.ChatMessageRoleUser, Content: "Goal: " + goal + "\n\nFirst break the goal into a short plan. Reply with {\"thought\": \"...\", \"plan\": [\"step\", ...]} and no action yet."},
+	}
+
+	reply, content, err := p.next(ctx, messages, result)
+	if err != nil {
+		return nil, err
+	}
+	messages = append(messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: content})
+	plan := []string{goal}
+	if reply != nil && len(reply.Plan) > 0 {
+		plan = reply.Plan
+	}
+	result.Plans = append(result.Plans, plan)
+	messages = append(messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: "Now work through the plan, one step per reply."})
+
+	toolCtx := withToolEnv(ctx, p.env)
+	for iteration := 1; iteration <= p.config.MaxIterations; iteration++ {
+		reply, content, err := p.next(ctx, messages, result)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: content})
+
+		step := PlanStep{Iteration: iteration}
+		switch {
+		case reply == nil:
+			step.Observation = "Error: reply with a single JSON object as described"
+		default:
+			step.Thought = reply.Thought
+			if len(reply.Plan) > 0 {
+				step.Plan = reply.Plan
+				result.Plans = append(result.Plans, reply.Plan)
+			}
+			switch {
+			case reply.FinalAnswer != "" && reply.Action == nil:
+				result.Steps = append(result.Steps, step)
+				p.notify(step)
+				result.Answer = reply.FinalAnswer
+				result.Completed = true
+				return result, nil
+			case reply.Action != nil && reply.FinalAnswer == "":
+				step.Action, step.Observation = p.act(toolCtx, reply.Action.Tool, reply.Action.Args)
+			default:
+				step.Observation = "Error: give exactly one of \"action\" and \"final_answer\""
+			}
+		}
+
+		result.Steps = append(result.Steps, step)
+		p.notify(step)
+		messages = append(messages, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleUser,
+			Content: fmt.Sprintf("Observation: %s\n\n%d of %d steps used.", step.Observation, iteration, p.config.MaxIterations),
+		})
+	}
+
+	// Out of budget: ask for the best answer the observations support
+	messages = append(messages, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: "You have no steps left. Reply with {\"thought\": \"...\", \"final_answer\": \"...\"} using only what you have observed, and say what is still missing.",
+	})
+	reply, content, err = p.next(ctx, messages, result)
+	if err != nil {
+		return nil, err
+	}
+	result.Answer = content
+	if reply != nil && reply.FinalAnswer != "" {
+		result.Answer = reply.FinalAnswer
+	}
+	return result, nil
+}
+
+// next asks the model for its next reply. A reply that isn't the expected
+// JSON is returned as content with a nil plannerReply.
+func (p *PlannerAgent) next(ctx context.Context, messages []openai.ChatCompletionMessage, result *PlanResult) (*plannerReply, string, error) {
+	resp, err := p.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model:          p.config.Model,
+		Messages:       p.env.RedactMessages(messages),
+		Temperature:    p.config.Temperature,
+		ResponseFormat: &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject},
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("API call failed: %w", err)
+	}
+	result.Usage.add(resp.Usage)
+	if len(resp.Choices) == 0 {
+		return nil, "", fmt.Errorf("no response choices returned")
+	}
+
+	content := resp.Choices[0].Message.Content
+	var reply plannerReply
+	if err := json.Unmarshal([]byte(replyObjectPattern.FindString(content)), &reply); err != nil {
+		return nil, content, nil
+	}
+	return &reply, content, nil
+}
+
+// act runs one tool call and returns its trace and what the model observes
+func (p *PlannerAgent) act(ctx context.Context, name string, args map[string]interface{}) (*ToolCallTrace, string) {
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	start := time.Now()
+	output, err := p.tools.Invoke(ctx, name, p.env.Expand(args))
+	trace := newToolCallTrace(name, args, time.Since(start), output, err)
+	if err != nil {
+		return &trace, fmt.Sprintf("Error: %v", err)
+	}
+	return &trace, clip(output, maxObservation)
+}
+
+// notify reports a finished step to OnStep
+func (p *PlannerAgent) notify(step PlanStep) {
+	if p.OnStep != nil {
+		p.OnStep(step)
+	}
+}
+
+// systemPrompt describes the reply format and the available tools
+func (p *PlannerAgent) systemPrompt() string {
+	var sb strings.Builder
+	sb.WriteString(`You are a planning agent. You reach a goal by planning, then taking one step at a time: think, call one tool, and read its observation before the next step.
+
+Reply to every message with a single JSON object:
+- "thought": your reasoning for this step
+- "action": {"tool": "<name>", "args": {...}} to call a tool, or
+- "final_answer": the answer to the goal, once the observations support it
+- "plan": optional, a revised list of remaining steps when an observation shows the plan won't work
+
+Never give both "action" and "final_answer". Don't invent observations; call a tool instead.
+
+Tools:
+`)
+	for _, definition := range p.tools.Definitions() {
+		parameters, _ := json.Marshal(definition.Parameters)
+		fmt.Fprintf(&sb, "- %s: %s\n  args: %s\n", definition.Name, definition.Description, parameters)
+	}
+	return sb.String()
+}
+
+// Render formats a step for the terminal
+func (s PlanStep) Render() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "💭 %d. %s\n", s.Iteration, s.Thought)
+	if len(s.Plan) > 0 {
+		fmt.Fprintf(&sb, "🗺️  Revised plan: %s\n", strings.Join(s.Plan, " → "))
+	}
+	if s.Action != nil {
+		sb.WriteString(s.Action.Render(false))
+	} else if s.Observation != "" {
+		fmt.Fprintf(&sb, "👀 %s\n", oneLine(s.Observation))
+	}
+	return sb.String()
+}
+
+// plannerConfigFromEnv applies PLANNER_MAX_ITERATIONS to the defaults
+func plannerConfigFromEnv() PlannerConfig {
+	config := DefaultPlannerConfig()
+	if n, err := strconv.Atoi(os.Getenv("PLANNER_MAX_ITERATIONS")); err == nil && n > 0 {
+		config.MaxIterations = n
+	}
+	return config
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sakibmulla/agentic-ai/endpoint"
+	"github.com/sakibmulla/agentic-ai/tools"
+	"github.com/sashabaranov/go-openai"
+)
+
+// newScriptedClient returns a client whose chat completions are replies in
+// order, each costing 10 tokens, and the requests it received. Once the
+// script runs out the last reply repeats.
+func newScriptedClient(t *testing.T, replies ...string) (*openai.Client, *[]openai.ChatCompletionRequest) {
+	t.Helper()
+	var requests []openai.ChatCompletionRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("bad request: %v", err)
+		}
+		reply := replies[min(len(requests), len(replies)-1)]
+		requests = append(requests, req)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: reply}}},
+			Usage:   openai.Usage{PromptTokens: 8, CompletionTokens: 2, TotalTokens: 10},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return openai.NewClientWithConfig(endpoint.Endpoint{BaseURL: server.URL + "/v1"}.Config("test-key")), &requests
+}
+
+// lookupRegistry holds a "lookup" tool answering from facts, which fails
+// for unknown keys
+func lookupRegistry(facts map[string]string) *tools.Registry {
+	registry := tools.NewRegistry()
+	registry.MustRegister(tools.Tool{
+		Name:        "lookup",
+		Description: "Look up a fact",
+		Parameters: tools.Schema{
+			Type:       tools.Object,
+			Properties: map[string]tools.Schema{"key": {Type: tools.String}},
+			Required:   []string{"key"},
+		},
+		Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			fact, ok := facts[args["key"].(string)]
+			if !ok {
+				return "", errors.New("no such fact")
+			}
+			return fact, nil
+		},
+	})
+	return registry
+}
+
+func TestPlannerReActLoop(t *testing.T) {
+	client, requests := newScriptedClient(t,
+		`{"thought": "Find the capital, then its population", "plan": ["find capital", "find population"]}`,
+		`{"thought": "Look up the capital", "action": {"tool": "lookup", "args": {"key": "capital"}}}`,
+		`Sure! {"thought": "Try the city directly", "action": {"tool": "lookup", "args": {"key": "$CITY"}}}`,
+		`not json`,
+		`{"thought": "Both at once", "action": {"tool": "lookup", "args": {"key": "x"}}, "final_answer": "?"}`,
+		`{"thought": "Population needs another source", "plan": ["ask population"], "action": {"tool": "lookup", "args": {"key": "paris population"}}}`,
+		`{"thought": "Done", "final_answer": "Paris, 2.1 million"}`,
+	)
+	env := NewToolEnv()
+	env.Set("CITY", "paris")
+	registry := lookupRegistry(map[string]string{"capital": "paris", "paris population": "2.1 million"})
+
+	var notified []int
+	planner := NewPlannerAgent(client, registry, env, DefaultPlannerConfig())
+	planner.OnStep = func(step PlanStep) { notified = append(notified, step.Iteration) }
+
+	result, err := planner.Run(context.Background(), "How many people live in the capital?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Completed || result.Answer != "Paris, 2.1 million" {
+		t.Errorf("Expected the final answer, got %+v", result)
+	}
+	if len(result.Plans) != 2 || result.Plans[0][1] != "find population" || result.Plans[1][0] != "ask population" {
+		t.Errorf("Expected the initial plan and its revision, got %v", result.Plans)
+	}
+	if len(result.Steps) != 6 || len(notified) != 6 || notified[5] != 6 {
+		t.Fatalf("Expected six steps, each notified, got %d and %v", len(result.Steps), notified)
+	}
+
+	// Each step records its action and what was observed
+	capital := result.Steps[0]
+	if capital.Action == nil || capital.Action.Name != "lookup" || capital.Observation != "paris" {
+		t.Errorf("Expected the lookup traced, got %+v", capital)
+	}
+	if failed := result.Steps[1]; failed.Action == nil || failed.Action.Error == "" || failed.Observation != "Error: no such fact" {
+		t.Errorf("Expected the expanded $CITY lookup to fail and be observed, got %+v", failed)
+	}
+	if malformed := result.Steps[2]; malformed.Action != nil || !strings.Contains(malformed.Observation, "single JSON object") {
+		t.Errorf("Expected a malformed reply observed as an error, got %+v", malformed)
+	}
+	if both := result.Steps[3]; both.Action != nil || !strings.Contains(both.Observation, "exactly one") {
+		t.Errorf("Expected an action with an answer rejected, got %+v", both)
+	}
+	if revised := result.Steps[4]; len(revised.Plan) != 1 || revised.Observation != "2.1 million" {
+		t.Errorf("Expected the revision recorded on its step, got %+v", revised)
+	}
+
+	// Observations go back to the model with the budget used; variables stay redacted
+	if len(*requests) != 7 || result.Usage.TotalTokens != 70 {
+		t.Errorf("Expected 7 requests and their usage counted, got %d and %+v", len(*requests), result.Usage)
+	}
+	last := (*requests)[6].Messages
+	observation := last[len(last)-1].Content
+	if !strings.HasPrefix(observation, "Observation: 2.1 million") || !strings.Contains(observation, "5 of 10 steps used") {
+		t.Errorf("Unexpected observation message %q", observation)
+	}
+	for _, message := range last {
+		if strings.Contains(message.Content, "paris") {
+			t.Errorf("Expected the variable's value redacted, got %q", message.Content)
+		}
+	}
+}
+
+func TestPlannerIterationBudget(t *testing.T) {
+	client, requests := newScriptedClient(t,
+		`{"thought": "Plan", "plan": ["look it up"]}`,
+		`{"thought": "Keep looking", "action": {"tool": "lookup", "args": {"key": "capital"}}}`,
+	)
+	planner := NewPlannerAgent(client, lookupRegistry(map[string]string{"capital": "paris"}), nil, PlannerConfig{MaxIterations: 3})
+
+	result, err := planner.Run(context.Background(), "Loop forever")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Completed || len(result.Steps) != 3 {
+		t.Errorf("Expected the budget of 3 steps to run out, got %d steps, completed %v", len(result.Steps), result.Completed)
+	}
+	// The plan, three steps and a last request for the best answer
+	if len(*requests) != 5 {
+		t.Fatalf("Expected 5 requests, got %d", len(*requests))
+	}
+	final := (*requests)[4].Messages
+	if !strings.Contains(final[len(final)-1].Content, "no steps left") {
+		t.Errorf("Expected the last request to ask for an answer, got %q", final[len(final)-1].Content)
+	}
+	// A last reply that isn't an answer is returned as it is
+	if !strings.Contains(result.Answer, "Keep looking") {
+		t.Errorf("Expected the raw reply as the answer, got %q", result.Answer)
+	}
+	if (*requests)[0].Model != agentModel {
+		t.Errorf("Expected the default model, got %s", (*requests)[0].Model)
+	}
+}
+
+func TestPlannerModelError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"message":"unavailable"}}`, http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	client := openai.NewClientWithConfig(endpoint.Endpoint{BaseURL: server.URL + "/v1"}.Config("test-key"))
+
+	planner := NewPlannerAgent(client, tools.NewRegistry(), nil, DefaultPlannerConfig())
+	if _, err := planner.Run(context.Background(), "anything"); err == nil || !strings.Contains(err.Error(), "API call failed") {
+		t.Errorf("Expected the API error returned, got %v", err)
+	}
+}
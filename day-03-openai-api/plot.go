@@ -0,0 +1,422 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sakibmulla/agentic-ai/tools"
+)
+
+// Chart limits and layout, in pixels
+const (
+	maxChartPoints = 500
+	defaultBins    = 10
+	maxBins        = 100
+
+	chartWidth  = 640
+	chartHeight = 400
+	marginLeft  = 70
+	marginRight = 20
+	marginTop   = 40
+	marginBot   = 60
+	yTicks      = 5
+)
+
+// Chart colors
+var (
+	chartInk  = color.RGBA{0x33, 0x33, 0x33, 0xff}
+	chartGrid = color.RGBA{0xdd, 0xdd, 0xdd, 0xff}
+	chartFill = color.RGBA{0x4c, 0x78, 0xa8, 0xff}
+)
+
+// ChartSpec describes a chart to render. Line and bar charts plot Values
+// against Labels; a histogram bins the raw Values.
+type ChartSpec struct {
+	Kind   string // line, bar or histogram
+	Title  string
+	XLabel string
+	YLabel string
+	Labels []string
+	Values []float64
+	Bins   int
+}
+
+// registerPlotTool adds the plot tool
+func (a *AgentWithTools) registerPlotTool() {
+	a.tools.MustRegister(tools.Tool{
+		Name:        "plot",
+		Description: "Render a line, bar or histogram chart from already aggregated numbers to a PNG or SVG file and return its path. Mention the path in your answer so the user can open the chart.",
+		Parameters: tools.Schema{
+			Type: tools.Object,
+			Properties: map[string]tools.Schema{
+				"kind": {
+					Type:        tools.String,
+					Description: "line or bar: values[i] is plotted at labels[i]; histogram: values are raw observations to bin",
+					Enum:        []string{"line", "bar", "histogram"},
+				},
+				"values": {
+					Type:        tools.Array,
+					Items:       &tools.Schema{Type: tools.Number},
+					Description: "The numbers to plot (at most 500)",
+				},
+				"labels": {
+					Type:        tools.Array,
+					Items:       &tools.Schema{Type: tools.String},
+					Description: "X axis label for each value (line and bar; defaults to 1, 2, 3...)",
+				},
+				"title":   {Type: tools.String, Description: "Chart title"},
+				"x_label": {Type: tools.String, Description: "X axis title"},
+				"y_label": {Type: tools.String, Description: "Y axis title"},
+				"bins": {
+					Type:        tools.Number,
+					Description: "Number of histogram bins (default 10)",
+				},
+				"format": {
+					Type:        tools.String,
+					Description: "svg (default, includes text) or png (shapes only, no text)",
+					Enum:        []string{"svg", "png"},
+				},
+			},
+			Required: []string{"kind", "values"},
+		},
+		Handler: handlePlot,
+	})
+}
+
+// handlePlot renders the chart into PLOT_DIR (a conversation variable,
+// default ./plots) and returns the file path
+func handlePlot(ctx context.Context, args map[string]interface{}) (string, error) {
+	spec, err := chartSpecFromArgs(args)
+	if err != nil {
+		return "", err
+	}
+	format, _ := args["format"].(string)
+	if format == "" {
+		format = "svg"
+	}
+
+	var data []byte
+	switch format {
+	case "svg":
+		data = spec.SVG()
+	case "png":
+		data, err = spec.PNG()
+		if err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("unsupported format %q: use svg or png", format)
+	}
+
+	dir, ok := EnvFromContext(ctx, "PLOT_DIR")
+	if !ok {
+		dir = "./plots"
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create plot directory: %w", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.%s", chartSlug(spec), time.Now().Format("20060102-150405.000"), format))
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write chart: %w", err)
+	}
+
+	return fmt.Sprintf("Saved %s chart with %d value(s) to %s", spec.Kind, len(spec.Values), path), nil
+}
+
+// chartSpecFromArgs validates the tool arguments
+func chartSpecFromArgs(args map[string]interface{}) (ChartSpec, error) {
+	spec := ChartSpec{}
+	spec.Kind, _ = args["kind"].(string)
+	spec.Title, _ = args["title"].(string)
+	spec.XLabel, _ = args["x_label"].(string)
+	spec.YLabel, _ = args["y_label"].(string)
+	if bins, ok := args["bins"].(float64); ok {
+		spec.Bins = int(bins)
+	}
+
+	switch spec.Kind {
+	case "line", "bar", "histogram":
+	default:
+		return spec, fmt.Errorf("unsupported chart kind %q: use line, bar or histogram", spec.Kind)
+	}
+
+	values, _ := args["values"].([]interface{})
+	if len(values) == 0 {
+		return spec, fmt.Errorf("values must be a non-empty array of numbers")
+	}
+	if len(values) > maxChartPoints {
+		return spec, fmt.Errorf("too many values (%d): aggregate to at most %d first", len(values), maxChartPoints)
+	}
+	for i, value := range values {
+		number, ok := value.(float64)
+		if !ok || math.IsNaN(number) || math.IsInf(number, 0) {
+			return spec, fmt.Errorf("values[%d] is not a number", i)
+		}
+		spec.Values = append(spec.Values, number)
+	}
+
+	labels, _ := args["labels"].([]interface{})
+	if len(labels) > 0 && spec.Kind != "histogram" {
+		if len(labels) != len(spec.Values) {
+			return spec, fmt.Errorf("got %d labels for %d values", len(labels), len(spec.Values))
+		}
+		for _, label := range labels {
+			spec.Labels = append(spec.Labels, fmt.Sprint(label))
+		}
+	}
+	return spec, nil
+}
+
+// series returns what to draw: a label and height per bar or point. A
+// histogram becomes one bar per bin, labelled with the bin's lower edge.
+func (s ChartSpec) series() ([]string, []float64) {
+	if s.Kind != "histogram" {
+		labels := s.Labels
+		if len(labels) == 0 {
+			for i := range s.Values {
+				labels = append(labels, fmt.Sprint(i+1))
+			}
+		}
+		return labels, s.Values
+	}
+
+	bins := s.Bins
+	if bins <= 0 {
+		bins = defaultBins
+	}
+	if bins > maxBins {
+		bins = maxBins
+	}
+	low, high := minMax(s.Values)
+	width := (high - low) / float64(bins)
+	if width == 0 {
+		width = 1
+	}
+
+	labels := make([]string, bins)
+	counts := make([]float64, bins)
+	for i := range labels {
+		labels[i] = formatTick(low + float64(i)*width)
+	}
+	for _, value := range s.Values {
+		bin := int((value - low) / width)
+		if bin >= bins {
+			bin = bins - 1 // the maximum belongs to the last bin
+		}
+		counts[bin]++
+	}
+	return labels, counts
+}
+
+// yRange returns the y axis bounds, always including zero so bars have a baseline
+func yRange(values []float64) (float64, float64) {
+	low, high := minMax(values)
+	low, high = math.Min(low, 0), math.Max(high, 0)
+	if low == high {
+		high = low + 1
+	}
+	return low, high
+}
+
+// minMax returns the smallest and largest value
+func minMax(values []float64) (float64, float64) {
+	low, high := values[0], values[0]
+	for _, value := range values[1:] {
+		low, high = math.Min(low, value), math.Max(high, value)
+	}
+	return low, high
+}
+
+// plotArea maps data coordinates to pixels
+type plotArea struct {
+	points     int
+	low, high  float64
+	bars       bool
+	plotWidth  float64
+	plotHeight float64
+}
+
+// newPlotArea fits points and the value range low..high into the chart margins
+func newPlotArea(points int, low, high float64, bars bool) plotArea {
+	return plotArea{
+		points:     points,
+		low:        low,
+		high:       high,
+		bars:       bars,
+		plotWidth:  chartWidth - marginLeft - marginRight,
+		plotHeight: chartHeight - marginTop - marginBot,
+	}
+}
+
+// x returns the center of point i: bars sit in equal slots, line points
+// span the full width
+func (p plotArea) x(i int) float64 {
+	if p.bars || p.points == 1 {
+		slot := p.plotWidth / float64(p.points)
+		return marginLeft + slot*(float64(i)+0.5)
+	}
+	return marginLeft + p.plotWidth*float64(i)/float64(p.points-1)
+}
+
+// y returns the pixel row of value
+func (p plotArea) y(value float64) float64 {
+	return marginTop + p.plotHeight*(p.high-value)/(p.high-p.low)
+}
+
+// barWidth leaves a gap between bars; histogram bars touch
+func (p plotArea) barWidth(kind string) float64 {
+	slot := p.plotWidth / float64(p.points)
+	if kind == "histogram" {
+		return slot
+	}
+	return slot * 0.8
+}
+
+// SVG renders the chart as an SVG document
+func (s ChartSpec) SVG() []byte {
+	labels, values := s.series()
+	low, high := yRange(values)
+	area := newPlotArea(len(values), low, high, s.Kind != "line")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="11">`+"\n",
+		chartWidth, chartHeight, chartWidth, chartHeight)
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	if s.Title != "" {
+		fmt.Fprintf(&b, `<text x="%d" y="24" text-anchor="middle" font-size="15">%s</text>`+"\n", chartWidth/2, html.EscapeString(s.Title))
+	}
+
+	// Gridlines and y tick labels
+	for i := 0; i <= yTicks; i++ {
+		value := low + (high-low)*float64(i)/yTicks
+		y := area.y(value)
+		fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="%s"/>`+"\n", marginLeft, y, chartWidth-marginRight, y, hexColor(chartGrid))
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="end">%s</text>`+"\n", marginLeft-6, y+4, formatTick(value))
+	}
+
+	// Data
+	if s.Kind == "line" {
+		points := make([]string, len(values))
+		for i, value := range values {
+			points[i] = fmt.Sprintf("%.1f,%.1f", area.x(i), area.y(value))
+		}
+		fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2"/>`+"\n", strings.Join(points, " "), hexColor(chartFill))
+	} else {
+		width := area.barWidth(s.Kind)
+		for i, value := range values {
+			top, bottom := area.y(math.Max(value, 0)), area.y(math.Min(value, 0))
+			fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`+"\n", area.x(i)-width/2, top, width, bottom-top, hexColor(chartFill))
+		}
+	}
+
+	// Axes and x labels, thinned so they don't overlap
+	fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s"/>`+"\n", marginLeft, marginTop, marginLeft, chartHeight-marginBot, hexColor(chartInk))
+	fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="%s"/>`+"\n", marginLeft, area.y(0), chartWidth-marginRight, area.y(0), hexColor(chartInk))
+	step := int(math.Ceil(float64(len(labels)) / 12))
+	for i := 0; i < len(labels); i += step {
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`+"\n", area.x(i), chartHeight-marginBot+16, html.EscapeString(clip(labels[i], 14)))
+	}
+	if s.XLabel != "" {
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle">%s</text>`+"\n", marginLeft+int(area.plotWidth)/2, chartHeight-14, html.EscapeString(s.XLabel))
+	}
+	if s.YLabel != "" {
+		fmt.Fprintf(&b, `<text transform="translate(16 %d) rotate(-90)" text-anchor="middle">%s</text>`+"\n", marginTop+int(area.plotHeight)/2, html.EscapeString(s.YLabel))
+	}
+	b.WriteString("</svg>\n")
+	return []byte(b.String())
+}
+
+// PNG renders the chart's shapes (axes, gridlines, bars or line) as a PNG.
+// There is no font renderer in the standard library, so it has no text.
+func (s ChartSpec) PNG() ([]byte, error) {
+	_, values := s.series()
+	low, high := yRange(values)
+	area := newPlotArea(len(values), low, high, s.Kind != "line")
+
+	img := image.NewRGBA(image.Rect(0, 0, chartWidth, chartHeight))
+	fillRect(img, 0, 0, chartWidth, chartHeight, color.White)
+	for i := 0; i <= yTicks; i++ {
+		y := int(area.y(low + (high-low)*float64(i)/yTicks))
+		fillRect(img, marginLeft, y, chartWidth-marginRight, y+1, chartGrid)
+	}
+
+	if s.Kind == "line" {
+		for i := 1; i < len(values); i++ {
+			drawLine(img, area.x(i-1), area.y(values[i-1]), area.x(i), area.y(values[i]), chartFill)
+		}
+	} else {
+		width := area.barWidth(s.Kind)
+		for i, value := range values {
+			x := area.x(i) - width/2
+			fillRect(img, int(x), int(area.y(math.Max(value, 0))), int(x+width), int(area.y(math.Min(value, 0))), chartFill)
+		}
+	}
+
+	fillRect(img, marginLeft, marginTop, marginLeft+1, chartHeight-marginBot, chartInk)
+	zero := int(area.y(0))
+	fillRect(img, marginLeft, zero, chartWidth-marginRight, zero+1, chartInk)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode PNG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// fillRect paints the rectangle [x0,x1)×[y0,y1)
+func fillRect(img *image.RGBA, x0, y0, x1, y1 int, c color.Color) {
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			img.Set(x, y, c)
+		}
+	}
+}
+
+// drawLine draws a 2px line by stepping along its longer axis
+func drawLine(img *image.RGBA, x0, y0, x1, y1 float64, c color.Color) {
+	steps := int(math.Max(math.Abs(x1-x0), math.Abs(y1-y0))) + 1
+	for i := 0; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		x, y := int(x0+(x1-x0)*t), int(y0+(y1-y0)*t)
+		fillRect(img, x, y, x+2, y+2, c)
+	}
+}
+
+// hexColor formats c for SVG
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// formatTick prints an axis value without needless decimals
+func formatTick(value float64) string {
+	if value == math.Trunc(value) && math.Abs(value) < 1e9 {
+		return fmt.Sprintf("%.0f", value)
+	}
+	return fmt.Sprintf("%.3g", value)
+}
+
+// slugPattern matches runs of characters not allowed in a chart file name
+var slugPattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+// chartSlug names a chart file after its title, or its kind
+func chartSlug(spec ChartSpec) string {
+	slug := strings.Trim(slugPattern.ReplaceAllString(strings.ToLower(spec.Title), "-"), "-")
+	if slug == "" {
+		return spec.Kind
+	}
+	if len(slug) > 40 {
+		slug = strings.TrimRight(slug[:40], "-")
+	}
+	return slug
+}
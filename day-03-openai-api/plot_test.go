@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestYRange(t *testing.T) {
+	tests := []struct {
+		values    []float64
+		low, high float64
+	}{
+		{[]float64{3, 7, 5}, 0, 7},
+		{[]float64{-4, 2}, -4, 2},
+		{[]float64{-8, -2}, -8, 0},
+		// A single point, or only zeros, still gets a range to scale by
+		{[]float64{5}, 0, 5},
+		{[]float64{0, 0}, 0, 1},
+	}
+	for _, tt := range tests {
+		if low, high := yRange(tt.values); low != tt.low || high != tt.high {
+			t.Errorf("yRange(%v) = %v, %v; expected %v, %v", tt.values, low, high, tt.low, tt.high)
+		}
+	}
+}
+
+func TestPlotAreaScaling(t *testing.T) {
+	area := newPlotArea(3, -10, 10, false)
+	bottom := float64(chartHeight - marginBot)
+	if area.y(10) != marginTop || area.y(-10) != bottom || area.y(0) != (marginTop+bottom)/2 {
+		t.Errorf("Expected the range to span the plot height, got %v %v %v", area.y(10), area.y(0), area.y(-10))
+	}
+	// Line points span the full width
+	right := float64(chartWidth - marginRight)
+	if area.x(0) != marginLeft || area.x(2) != right || area.x(1) != (marginLeft+right)/2 {
+		t.Errorf("Expected line points from edge to edge, got %v %v %v", area.x(0), area.x(1), area.x(2))
+	}
+
+	// Bars are centered in equal slots
+	bars := newPlotArea(4, 0, 1, true)
+	slot := bars.plotWidth / 4
+	if bars.x(0) != marginLeft+slot/2 || bars.barWidth("bar") != slot*0.8 || bars.barWidth("histogram") != slot {
+		t.Errorf("Unexpected bar layout: x %v, widths %v %v", bars.x(0), bars.barWidth("bar"), bars.barWidth("histogram"))
+	}
+
+	// A single line point is centered rather than divided by zero
+	if single := newPlotArea(1, 0, 1, false); single.x(0) != marginLeft+single.plotWidth/2 {
+		t.Errorf("Expected a single point centered, got %v", single.x(0))
+	}
+}
+
+func TestHistogramSeries(t *testing.T) {
+	spec := ChartSpec{Kind: "histogram", Values: []float64{0, 1, 2, 3, 4, 10}, Bins: 5}
+	labels, counts := spec.series()
+	if strings.Join(labels, ",") != "0,2,4,6,8" {
+		t.Errorf("Expected bins labelled by lower edge, got %v", labels)
+	}
+	// The maximum belongs to the last bin
+	if want := []float64{2, 2, 1, 0, 1}; !slices.Equal(counts, want) {
+		t.Errorf("Expected counts %v, got %v", want, counts)
+	}
+
+	// Identical values don't make zero-width bins
+	labels, counts = ChartSpec{Kind: "histogram", Values: []float64{7, 7, 7}}.series()
+	if len(labels) != defaultBins || counts[0] != 3 {
+		t.Errorf("Expected the default bins with every value in the first, got %v %v", labels, counts)
+	}
+	if labels, _ := (ChartSpec{Kind: "histogram", Values: []float64{1, 2}, Bins: 1000}).series(); len(labels) != maxBins {
+		t.Errorf("Expected at most %d bins, got %d", maxBins, len(labels))
+	}
+
+	// Line and bar charts are labelled 1, 2, 3... by default
+	if labels, values := (ChartSpec{Kind: "bar", Values: []float64{4, 5}}).series(); strings.Join(labels, ",") != "1,2" || len(values) != 2 {
+		t.Errorf("Unexpected default labels %v", labels)
+	}
+}
+
+func TestChartSpecFromArgs(t *testing.T) {
+	tests := []struct {
+		args map[string]interface{}
+		err  string
+	}{
+		{map[string]interface{}{"kind": "pie", "values": []interface{}{1.0}}, "unsupported chart kind"},
+		{map[string]interface{}{"kind": "line"}, "non-empty"},
+		{map[string]interface{}{"kind": "line", "values": []interface{}{}}, "non-empty"},
+		{map[string]interface{}{"kind": "line", "values": []interface{}{1.0, "two"}}, "values[1]"},
+		{map[string]interface{}{"kind": "bar", "values": []interface{}{1.0, 2.0}, "labels": []interface{}{"a"}}, "1 labels for 2 values"},
+		{map[string]interface{}{"kind": "line", "values": make([]interface{}, maxChartPoints+1)}, "too many values"},
+	}
+	for _, tt := range tests {
+		if _, err := chartSpecFromArgs(tt.args); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%v: expected an error containing %q, got %v", tt.args, tt.err, err)
+		}
+	}
+
+	// Histograms ignore labels
+	spec, err := chartSpecFromArgs(map[string]interface{}{"kind": "histogram", "values": []interface{}{1.0, 2.0}, "labels": []interface{}{"a"}, "bins": 4.0})
+	if err != nil || spec.Labels != nil || spec.Bins != 4 {
+		t.Errorf("Unexpected histogram spec %+v, %v", spec, err)
+	}
+}
+
+func TestRenderSinglePoint(t *testing.T) {
+	for _, kind := range []string{"line", "bar", "histogram"} {
+		spec := ChartSpec{Kind: kind, Title: "One <value>", Values: []float64{42}}
+		svg := string(spec.SVG())
+		if !strings.HasPrefix(svg, "<svg") || strings.Contains(svg, "NaN") || strings.Contains(svg, "Inf") {
+			t.Errorf("%s: expected a finite SVG, got %q", kind, svg)
+		}
+		if !strings.Contains(svg, "One &lt;value&gt;") {
+			t.Errorf("%s: expected the title escaped", kind)
+		}
+		data, err := spec.PNG()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if img, err := png.Decode(bytes.NewReader(data)); err != nil || img.Bounds().Dx() != chartWidth {
+			t.Errorf("%s: expected a %dpx wide PNG, got %v", kind, chartWidth, err)
+		}
+	}
+}
+
+func TestHandlePlot(t *testing.T) {
+	env := NewToolEnv()
+	env.Set("PLOT_DIR", t.TempDir())
+	ctx := withToolEnv(context.Background(), env)
+
+	out, err := handlePlot(ctx, map[string]interface{}{"kind": "bar", "title": "Sales by Month!", "values": []interface{}{3.0, 4.0}, "format": "png"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, _ := env.Get("PLOT_DIR")
+	path := strings.TrimPrefix(out, "Saved bar chart with 2 value(s) to ")
+	if !strings.HasPrefix(path, dir+"/sales-by-month-") || !strings.HasSuffix(path, ".png") {
+		t.Errorf("Expected a file named after the title in PLOT_DIR, got %q", out)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Expected the chart written: %v", err)
+	}
+	if _, err := handlePlot(ctx, map[string]interface{}{"kind": "bar", "values": []interface{}{1.0}, "format": "gif"}); err == nil {
+		t.Error("Expected an unsupported format rejected")
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sakibmulla/agentic-ai/costs"
+	"github.com/sashabaranov/go-openai"
+)
+
+// maxTranscriptChars bounds the transcript sent for a wrap-up; the most
+// recent part is kept
+const maxTranscriptChars = 12000
+
+// TokenUsage is the token count and estimated cost of a conversation
+type TokenUsage struct {
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	EstimatedCostUSD float64 `json:"estimated_cost_usd"`
+}
+
+// add counts one API response
+func (u *TokenUsage) add(usage openai.Usage) {
+	u.PromptTokens += usage.PromptTokens
+	u.CompletionTokens += usage.CompletionTokens
+	u.TotalTokens += usage.TotalTokens
+	u.EstimatedCostUSD = costs.Cost(agentModel, u.PromptTokens, u.CompletionTokens)
+}
+
+// Goal is something the user set out to do in the conversation
+type Goal struct {
+	Goal     string `json:"goal"`
+	Achieved bool   `json:"achieved"`
+	Evidence string `json:"evidence"`
+}
+
+// ReportCard grades a conversation at its end. Goals, unanswered questions
+// and follow-ups come from the model; usage and tools are counted.
+type ReportCard struct {
+	Goals               []Goal         `json:"goals"`
+	UnansweredQuestions []string       `json:"unanswered_questions"`
+	SuggestedFollowUps  []string       `json:"suggested_follow_ups"`
+	ToolsUsed           map[string]int `json:"tools_used"`
+	Usage               TokenUsage     `json:"usage"`
+	// Turns is how many user messages the report covers
+	Turns       int       `json:"turns"`
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// wrapUpPrompt asks for the model-judged parts of the report card
+const wrapUpPrompt = `You review a finished conversation between a user and a tool-using assistant.
+Reply with a JSON object with these keys:
+- "goals": what the user wanted to get done, as [{"goal": "...", "achieved": true|false, "evidence": "one sentence"}]
+- "unanswered_questions": questions the user asked that were not answered
+- "suggested_follow_ups": up to 3 useful next steps for the user
+Use empty lists where nothing applies.`
+
+// WrapUp generates a report card for the conversation so far. The card is
+// kept with the conversation and saved with it.
+func (a *AgentWithTools) WrapUp(ctx context.Context) (*ReportCard, error) {
+	turns := 0
+	for _, msg := range a.conversation {
+		if msg.Role == openai.ChatMessageRoleUser {
+			turns++
+		}
+	}
+	if turns == 0 {
+		return nil, fmt.Errorf("nothing to wrap up: the conversation is empty")
+	}
+
+	resp, err := a.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model: agentModel,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: wrapUpPrompt},
+			{Role: openai.ChatMessageRoleUser, Content: a.env.Redact(wrapUpTranscript(a.conversation))},
+		},
+		ResponseFormat: &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject},
+		Temperature:    0.2,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("wrap-up call failed: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("no response choices returned")
+	}
+
+	card := &ReportCard{}
+	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), card); err != nil {
+		return nil, fmt.Errorf("failed to parse report card: %w", err)
+	}
+	card.ToolsUsed = a.toolsUsed()
+	card.Usage = a.usage
+	card.Turns = turns
+	card.GeneratedAt = time.Now()
+
+	// The wrap-up is spend too, but it is counted after the card's snapshot
+	a.usage.add(resp.Usage)
+	a.reportCard = card
+	return card, nil
+}
+
+// ReportCard returns the conversation's latest report card, if any
+func (a *AgentWithTools) ReportCard() *ReportCard {
+	return a.reportCard
+}
+
+// toolsUsed counts tool calls by name, including those in turns that were
+// summarized when the conversation was saved
+func (a *AgentWithTools) toolsUsed() map[string]int {
+	counts := make(map[string]int)
+	count := func(messages []openai.ChatCompletionMessage) {
+		for _, msg := range messages {
+			for _, call := range msg.ToolCalls {
+				counts[call.Function.Name]++
+			}
+		}
+	}
+	count(a.conversation)
+	for _, messages := range a.archivedTraces {
+		count(messages)
+	}
+	return counts
+}
+
+// wrapUpTranscript renders the conversation for review, with each turn's
+// tool calls reduced to an action summary
+func wrapUpTranscript(messages []openai.ChatCompletionMessage) string {
+	var builder strings.Builder
+	var tools []openai.ChatCompletionMessage
+	flushTools := func() {
+		if len(tools) > 0 {
+			builder.WriteString(summarizeTools("Tools used", tools) + "\n")
+			tools = nil
+		}
+	}
+
+	for _, msg := range messages {
+		switch {
+		case isToolMessage(msg):
+			tools = append(tools, msg)
+		case msg.Role == openai.ChatMessageRoleUser:
+			flushTools()
+			builder.WriteString("User: " + msg.Content + "\n")
+		case msg.Role == openai.ChatMessageRoleAssistant:
+			flushTools()
+			builder.WriteString("Assistant: " + msg.Content + "\n")
+		case strings.HasPrefix(msg.Content, actionSummaryPrefix):
+			builder.WriteString(msg.Content + "\n")
+		}
+	}
+	flushTools()
+
+	transcript := builder.String()
+	if len(transcript) > maxTranscriptChars {
+		transcript = "…" + transcript[len(transcript)-maxTranscriptChars:]
+	}
+	return transcript
+}
+
+// Print writes the report card for the terminal
+func (c *ReportCard) Print() {
+	fmt.Printf("📋 Conversation report card (%d turns)\n", c.Turns)
+	if len(c.Goals) == 0 {
+		fmt.Println("🎯 No clear goals detected")
+	}
+	for _, goal := range c.Goals {
+		status := "❌"
+		if goal.Achieved {
+			status = "✅"
+		}
+		fmt.Printf("🎯 %s %s — %s\n", status, goal.Goal, goal.Evidence)
+	}
+	for _, question := range c.UnansweredQuestions {
+		fmt.Printf("❓ Unanswered: %s\n", question)
+	}
+
+	names := make([]string, 0, len(c.ToolsUsed))
+	for name := range c.ToolsUsed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tools := make([]string, len(names))
+	for i, name := range names {
+		tools[i] = fmt.Sprintf("%s ×%d", name, c.ToolsUsed[name])
+	}
+	if len(tools) == 0 {
+		tools = []string{"none"}
+	}
+	fmt.Printf("🔧 Tools: %s\n", strings.Join(tools, ", "))
+	fmt.Printf("💰 %d tokens (%d prompt, %d completion), about $%.4f\n",
+		c.Usage.TotalTokens, c.Usage.PromptTokens, c.Usage.CompletionTokens, c.Usage.EstimatedCostUSD)
+	for _, followUp := range c.SuggestedFollowUps {
+		fmt.Printf("👉 %s\n", followUp)
+	}
+}
+
+// ReportCardHandler serves the report cards of saved conversations on
+// GET /conversations/<name>/report
+func ReportCardHandler(dir string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/conversations/"), "/report")
+		if !ok || !strings.HasPrefix(r.URL.Path, "/conversations/") {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		path, err := conversationPath(dir, name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			http.Error(w, fmt.Sprintf("no saved conversation %q", name), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "failed to read conversation", http.StatusInternalServerError)
+			return
+		}
+
+		var saved savedConversation
+		if _, err := conversationFormat.Unmarshal(data, &saved); err != nil {
+			http.Error(w, "failed to parse conversation", http.StatusInternalServerError)
+			return
+		}
+		if saved.ReportCard == nil {
+			http.Error(w, fmt.Sprintf("conversation %q has no report card; run /wrapup", name), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(saved.ReportCard)
+	})
+}
@@ -0,0 +1,9 @@
+package main
+
+// Drivers for the SQL tools (SQL_TOOLS_DRIVER=postgres, mysql or sqlite).
+// SQLite is the pure Go port, so the binary still builds without cgo.
+import (
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// maxParallelTools bounds how many of one turn's tool calls run at once
+const maxParallelTools = 4
+
+// toolOutcome is the result of one tool call
+type toolOutcome struct {
+	args     map[string]interface{}
+	result   string
+	err      error
+	duration time.Duration
+}
+
+// runToolCalls executes a turn's tool calls, up to maxParallelTools at a
+// time, and returns their tool messages in call order. Failures, including
+// malformed arguments and unknown tools, become "Error: ..." results so the
+// model can react to them.
+func (a *AgentWithTools) runToolCalls(ctx context.Context, calls []openai.ToolCall) []openai.ChatCompletionMessage {
+	outcomes := make([]toolOutcome, len(calls))
+	toolCtx := withToolEnv(ctx, a.env)
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, maxParallelTools)
+	for i, call := range calls {
+		wg.Add(1)
+		go func(i int, call openai.ToolCall) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			outcomes[i] = a.runToolCall(toolCtx, call)
+		}(i, call)
+	}
+	wg.Wait()
+
+	// Artifacts, traces and callbacks are handled here, one call at a time
+	messages := make([]openai.ChatCompletionMessage, len(calls))
+	for i, call := range calls {
+		outcome := outcomes[i]
+		result := outcome.result
+		trace := newToolCallTrace(call.Function.Name, outcome.args, outcome.duration, result, outcome.err)
+		if outcome.err != nil {
+			result = fmt.Sprintf("Error: %v", outcome.err)
+		}
+
+		// Large results are stored as artifacts; only a digest enters the context
+		content, artifactID := a.contextContent(call.Function.Name, outcome.args, result)
+		trace.ArtifactID = artifactID
+
+		a.lastCalls = append(a.lastCalls, trace)
+		if a.OnToolCall != nil {
+			a.OnToolCall(trace)
+		}
+
+		messages[i] = openai.ChatCompletionMessage{
+			Role:       openai.ChatMessageRoleTool,
+			Name:       call.Function.Name,
+			ToolCallID: call.ID,
+			Content:    content,
+		}
+	}
+	return messages
+}
+
+// runToolCall runs one call. Arguments that don't match the tool's schema
+// are rejected by the registry before the handler runs.
+func (a *AgentWithTools) runToolCall(ctx context.Context, call openai.ToolCall) toolOutcome {
+	var outcome toolOutcome
+	if err := json.Unmarshal([]byte(call.Function.Arguments), &outcome.args); err != nil {
+		outcome.err = fmt.Errorf("failed to parse arguments: %w", err)
+		return outcome
+	}
+
+	start := time.Now()
+	outcome.result, outcome.err = a.tools.Invoke(ctx, call.Function.Name, a.env.Expand(outcome.args))
+	outcome.duration = time.Since(start)
+	return outcome
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ToolCallTrace records a single tool invocation. The JSON form is what
+// HTTP/WebSocket clients receive alongside the answer.
+type ToolCallTrace struct {
+	Name       string                 `json:"name"`
+	Args       map[string]interface{} `json:"args"`
+	DurationMS int64                  `json:"duration_ms"`
+	Result     string                 `json:"result"`
+	Error      string                 `json:"error,omitempty"`
+	Truncated  bool                   `json:"truncated"`
+	// ArtifactID is set when the full result was stored outside the conversation
+	ArtifactID string `json:"artifact_id,omitempty"`
+}
+
+const (
+	// maxTraceResult bounds the result stored in a trace
+	maxTraceResult = 2000
+	// collapsedArgs and collapsedResult bound the compact rendering
+	collapsedArgs   = 3
+	collapsedResult = 80
+	collapsedArgLen = 40
+)
+
+// newToolCallTrace builds a trace, truncating long results
+func newToolCallTrace(name string, args map[string]interface{}, duration time.Duration, result string, err error) ToolCallTrace {
+	trace := ToolCallTrace{
+		Name:       name,
+		Args:       args,
+		DurationMS: duration.Milliseconds(),
+		Result:     result,
+	}
+	if err != nil {
+		trace.Error = err.Error()
+	}
+	if len(trace.Result) > maxTraceResult {
+		trace.Result = trace.Result[:maxTraceResult]
+		trace.Truncated = true
+	}
+	return trace
+}
+
+// Render formats the trace as an indented block. Collapsed output shows
+// the first few arguments and a one-line result; expanded shows everything.
+func (t ToolCallTrace) Render(expanded bool) string {
+	var builder strings.Builder
+
+	status := "✅"
+	if t.Error != "" {
+		status = "❌"
+	}
+	builder.WriteString(fmt.Sprintf("┌ 🔧 %s (%dms) %s\n", t.Name, t.DurationMS, status))
+
+	keys := make([]string, 0, len(t.Args))
+	for key := range t.Args {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for i, key := range keys {
+		if !expanded && i >= collapsedArgs {
+			builder.WriteString(fmt.Sprintf("│   … %d more args\n", len(keys)-collapsedArgs))
+			break
+		}
+		value := formatArg(t.Args[key])
+		if !expanded {
+			value = clip(value, collapsedArgLen)
+		}
+		builder.WriteString(fmt.Sprintf("│   %s: %s\n", key, value))
+	}
+
+	output := t.Result
+	if t.Error != "" {
+		output = "Error: " + t.Error
+	}
+	if expanded {
+		lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+		for i, line := range lines {
+			prefix := "│ "
+			if i == len(lines)-1 {
+				prefix = "└ "
+			}
+			builder.WriteString(prefix + "→ " + line + "\n")
+		}
+		if t.Truncated {
+			builder.WriteString("  (result truncated)\n")
+		}
+		if t.ArtifactID != "" {
+			builder.WriteString(fmt.Sprintf("  (full result stored as %s)\n", t.ArtifactID))
+		}
+	} else {
+		builder.WriteString("└ → " + clip(strings.Join(strings.Fields(output), " "), collapsedResult) + "\n")
+	}
+
+	return builder.String()
+}
+
+// formatArg renders an argument value compactly
+func formatArg(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return fmt.Sprintf("%q", v)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(data)
+	}
+}
+
+// clip shortens s to at most n runes, marking the cut with an ellipsis
+func clip(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
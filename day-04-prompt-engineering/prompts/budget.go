@@ -0,0 +1,174 @@
+package prompts
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// truncationMarker ends an elastic variable that was cut to fit the budget
+const truncationMarker = "\n[... truncated to fit the prompt budget]"
+
+// PromptBudget bounds the tokens in a rendered prompt
+type PromptBudget struct {
+	// MaxTokens is the most tokens a prompt may take up; zero means no limit
+	MaxTokens int
+	// Summarize has the LLM condense elastic variables before they are cut.
+	// It costs a call per variable, but keeps the gist of what would be lost.
+	Summarize bool
+}
+
+// RenderedPrompt is a prompt rendered within the engine's budget
+type RenderedPrompt struct {
+	Prompt string
+	// Tokens is the prompt's size with the chat model's tokenizer
+	Tokens int
+	// Shortened maps each elastic variable that had to be shortened to how:
+	// "summarized" or "truncated"
+	Shortened map[string]string
+}
+
+// SetPromptBudget makes every prompt the engine renders fit in budget, by
+// shortening the templates' elastic variables
+func (pe *PromptEngine) SetPromptBudget(budget PromptBudget) {
+	pe.budget = budget
+}
+
+// RenderPrompt renders a template's active version, selecting its examples
+// and shortening its elastic variables to fit the prompt budget, and reports
+// the prompt's size
+func (pe *PromptEngine) RenderPrompt(ctx context.Context, templateName string, variables map[string]interface{}) (*RenderedPrompt, error) {
+	templateObj, err := pe.GetTemplate(templateName)
+	if err != nil {
+		return nil, err
+	}
+	return pe.render(ctx, templateObj, variables)
+}
+
+// render checks the variables against their types and renders templateObj
+// within the budget. Elastic variables are shortened in the template's order
+// until the prompt fits; a prompt that can't be made to fit is an error.
+func (pe *PromptEngine) render(ctx context.Context, templateObj PromptTemplate, variables map[string]interface{}) (*RenderedPrompt, error) {
+	variables, err := pe.selectExamples(ctx, templateObj.Name, variables)
+	if err != nil {
+		return nil, err
+	}
+	if issues := ValidateVariables(templateObj, variables); len(issues) > 0 {
+		return nil, fmt.Errorf("invalid variables for template '%s': %s", templateObj.Name, strings.Join(issues, "; "))
+	}
+	prompt, err := renderTemplate(templateObj, variables)
+	if err != nil {
+		return nil, err
+	}
+	rendered := &RenderedPrompt{Prompt: prompt, Tokens: pe.tokens.Count(prompt)}
+	if pe.budget.MaxTokens <= 0 || rendered.Tokens <= pe.budget.MaxTokens {
+		return rendered, nil
+	}
+
+	// The caller's map is left as it was
+	shortened := make(map[string]interface{}, len(variables))
+	for k, v := range variables {
+		shortened[k] = v
+	}
+	rendered.Shortened = make(map[string]string)
+
+	rerender := func(name, value string) error {
+		shortened[name] = value
+		prompt, err := renderTemplate(templateObj, shortened)
+		if err != nil {
+			return err
+		}
+		rendered.Prompt, rendered.Tokens = prompt, pe.tokens.Count(prompt)
+		return nil
+	}
+
+	for _, name := range templateObj.Elastic {
+		value, ok := shortened[name].(string)
+		if !ok || value == "" {
+			continue
+		}
+
+		if pe.budget.Summarize {
+			target := pe.tokens.Count(value) - (rendered.Tokens - pe.budget.MaxTokens)
+			if summary, err := pe.condense(ctx, value, target); err == nil && len(summary) < len(value) {
+				if err := rerender(name, summary); err != nil {
+					return nil, err
+				}
+				value = summary
+				rendered.Shortened[name] = "summarized"
+			}
+		}
+
+		// Token counts can shift by a few where the cut joins the rest of
+		// the prompt, so cut again until it fits
+		for rendered.Tokens > pe.budget.MaxTokens && value != "" {
+			target := pe.tokens.Count(value) - (rendered.Tokens - pe.budget.MaxTokens)
+			value = truncateTokens(pe.tokens.Count, value, target)
+			if err := rerender(name, value); err != nil {
+				return nil, err
+			}
+			rendered.Shortened[name] = "truncated"
+		}
+		if rendered.Tokens <= pe.budget.MaxTokens {
+			return rendered, nil
+		}
+	}
+	return nil, fmt.Errorf("prompt for '%s' is %d tokens, over the budget of %d even with its elastic variables shortened",
+		templateObj.Name, rendered.Tokens, pe.budget.MaxTokens)
+}
+
+// truncateTokens returns the longest start of text, on a word boundary where
+// there is one, that takes up at most target tokens with the marker added;
+// "" when not even the marker fits
+func truncateTokens(count func(string) int, text string, target int) string {
+	if count(text) <= target {
+		return text
+	}
+	runes := []rune(text)
+	lo, hi := 0, len(runes)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if count(string(runes[:mid])+truncationMarker) <= target {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	if lo == 0 {
+		return ""
+	}
+	cut := string(runes[:lo])
+	if i := strings.LastIndexAny(cut, " \n\t"); i > len(cut)/2 {
+		cut = cut[:i]
+	}
+	return cut + truncationMarker
+}
+
+// condense asks the LLM to shorten text to about target tokens
+func (pe *PromptEngine) condense(ctx context.Context, text string, target int) (string, error) {
+	if target <= 0 {
+		return "", fmt.Errorf("no room left to condense into")
+	}
+	resp, err := pe.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model: openai.GPT3Dot5Turbo,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role: openai.ChatMessageRoleSystem,
+				Content: fmt.Sprintf("Condense the user's text to under %d tokens. Keep every figure, name and fact another model would need to work with it; drop repetition and filler. Respond with the condensed text only.",
+					target*9/10),
+			},
+			{Role: openai.ChatMessageRoleUser, Content: text},
+		},
+		Temperature: 0,
+		MaxTokens:   target,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to condense text: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("no response from LLM")
+	}
+	return strings.TrimSpace(resp.Choices[0].Message.Content), nil
+}
@@ -0,0 +1,121 @@
+package prompts
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sakibmulla/agentic-ai/endpoint"
+	"github.com/sashabaranov/go-openai"
+)
+
+func analysisVariables() map[string]interface{} {
+	return map[string]interface{}{
+		"domain":        "e-commerce",
+		"data":          strings.Repeat("Week 12: orders 1,204, returns 37, average basket $48.20. ", 200),
+		"analysis_type": "trend analysis",
+		"context":       "Q4 holiday season performance",
+	}
+}
+
+func TestPromptBudgetTruncates(t *testing.T) {
+	engine := NewPromptEngine("test-key", endpoint.Endpoint{}, nil)
+	variables := analysisVariables()
+
+	full, err := engine.RenderPrompt(context.Background(), "data_analysis", variables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if full.Shortened != nil {
+		t.Errorf("Expected nothing shortened without a budget, got %v", full.Shortened)
+	}
+
+	engine.SetPromptBudget(PromptBudget{MaxTokens: 400})
+	rendered, err := engine.RenderPrompt(context.Background(), "data_analysis", variables)
+	if err != nil {
+		t.Fatalf("RenderPrompt failed: %v", err)
+	}
+	if rendered.Tokens > 400 || rendered.Tokens != engine.tokens.Count(rendered.Prompt) {
+		t.Errorf("Expected at most 400 tokens, got %d (counted %d)", rendered.Tokens, engine.tokens.Count(rendered.Prompt))
+	}
+	if rendered.Tokens < 350 {
+		t.Errorf("Expected the budget mostly used, got %d tokens", rendered.Tokens)
+	}
+	if rendered.Shortened["data"] != "truncated" || !strings.Contains(rendered.Prompt, truncationMarker) {
+		t.Errorf("Expected data truncated, got %v:\n%s", rendered.Shortened, rendered.Prompt)
+	}
+	if _, ok := rendered.Shortened["context"]; ok || !strings.Contains(rendered.Prompt, "Business Context: Q4 holiday season performance") {
+		t.Errorf("Expected context kept once data fit, got %v", rendered.Shortened)
+	}
+	if variables["data"] != analysisVariables()["data"] {
+		t.Error("Expected the caller's variables left alone")
+	}
+
+	prompt, err := engine.GeneratePrompt("data_analysis", variables)
+	if err != nil || prompt != rendered.Prompt {
+		t.Errorf("Expected GeneratePrompt to keep to the budget, got %v", err)
+	}
+
+	// The fixed text alone is over this budget
+	engine.SetPromptBudget(PromptBudget{MaxTokens: 20})
+	if _, err := engine.RenderPrompt(context.Background(), "data_analysis", variables); err == nil {
+		t.Error("Expected an error for a budget the fixed text doesn't fit in")
+	}
+}
+
+func TestPromptBudgetSummarizes(t *testing.T) {
+	condensed := "Weekly orders steady near 1,204, returns 37, basket $48.20."
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		var req openai.ChatCompletionRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.MaxTokens <= 0 || !strings.HasPrefix(req.Messages[1].Content, "Week 12") {
+			t.Errorf("Unexpected condense request: %+v", req)
+		}
+		json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Role: "assistant", Content: condensed}}},
+		})
+	}))
+	defer server.Close()
+
+	engine := NewPromptEngine("test-key", endpoint.Endpoint{}, nil)
+	config := openai.DefaultConfig("test-key")
+	config.BaseURL = server.URL + "/v1"
+	engine.client = openai.NewClientWithConfig(config)
+	engine.SetPromptBudget(PromptBudget{MaxTokens: 400, Summarize: true})
+
+	variables := analysisVariables()
+	rendered, err := engine.RenderPrompt(context.Background(), "data_analysis", variables)
+	if err != nil {
+		t.Fatalf("RenderPrompt failed: %v", err)
+	}
+	if calls != 1 || rendered.Shortened["data"] != "summarized" {
+		t.Errorf("Expected data summarized in one call, got %d calls, %v", calls, rendered.Shortened)
+	}
+	if !strings.Contains(rendered.Prompt, "Data: "+condensed) || strings.Contains(rendered.Prompt, truncationMarker) {
+		t.Errorf("Expected the condensed data, untruncated, got:\n%s", rendered.Prompt)
+	}
+}
+
+func TestTruncateTokens(t *testing.T) {
+	engine := NewPromptEngine("test-key", endpoint.Endpoint{}, nil)
+	text := strings.Repeat("alpha beta gamma ", 50)
+
+	if got := truncateTokens(engine.tokens.Count, "short", 10); got != "short" {
+		t.Errorf("Expected text that fits unchanged, got %q", got)
+	}
+	cut := truncateTokens(engine.tokens.Count, text, 30)
+	if engine.tokens.Count(cut) > 30 || !strings.HasSuffix(cut, truncationMarker) {
+		t.Errorf("Expected at most 30 tokens ending in the marker, got %d: %q", engine.tokens.Count(cut), cut)
+	}
+	if body := strings.TrimSuffix(cut, truncationMarker); strings.HasSuffix(body, " ") || !strings.HasSuffix(body, "a") {
+		t.Errorf("Expected a cut on a word boundary, got %q", body)
+	}
+	if got := truncateTokens(engine.tokens.Count, text, 2); got != "" {
+		t.Errorf("Expected nothing when the marker doesn't fit, got %q", got)
+	}
+}
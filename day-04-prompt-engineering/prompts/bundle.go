@@ -0,0 +1,58 @@
+package prompts
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/sakibmulla/agentic-ai/persist"
+)
+
+// TemplateBundle is a file of user templates, read by `load` and written by `save`
+type TemplateBundle struct {
+	Templates []PromptTemplate `json:"templates"`
+}
+
+// templateBundleFormat versions bundle files. Version 1 files held a single
+// template; they load as a bundle of one.
+var templateBundleFormat = persist.NewFormat("template-bundle", 2).Migrate(1, migrateBundleV1)
+
+// migrateBundleV1 wraps a single template in a bundle
+func migrateBundleV1(data json.RawMessage) (json.RawMessage, error) {
+	return json.Marshal(map[string][]json.RawMessage{"templates": {data}})
+}
+
+// LoadTemplateBundle reads the templates in a bundle file. A file written by
+// an older release is upgraded in place, keeping the original as file.v<N>.
+func LoadTemplateBundle(path string) ([]PromptTemplate, error) {
+	var bundle TemplateBundle
+	if err := templateBundleFormat.ReadFile(path, &bundle); err != nil {
+		return nil, fmt.Errorf("failed to load template bundle: %w", err)
+	}
+	if len(bundle.Templates) == 0 {
+		return nil, fmt.Errorf("template bundle %s has no templates", path)
+	}
+	return bundle.Templates, nil
+}
+
+// SaveTemplateBundle writes the user-provided templates to path, sorted by
+// name, and returns how many were written
+func (pe *PromptEngine) SaveTemplateBundle(path string) (int, error) {
+	var bundle TemplateBundle
+	for _, template := range pe.templates {
+		if template.UserProvided {
+			bundle.Templates = append(bundle.Templates, template)
+		}
+	}
+	if len(bundle.Templates) == 0 {
+		return 0, fmt.Errorf("no user templates to save")
+	}
+	sort.Slice(bundle.Templates, func(i, j int) bool {
+		return bundle.Templates[i].Name < bundle.Templates[j].Name
+	})
+
+	if err := templateBundleFormat.WriteFile(path, bundle, 0644); err != nil {
+		return 0, fmt.Errorf("failed to save template bundle: %w", err)
+	}
+	return len(bundle.Templates), nil
+}
@@ -0,0 +1,45 @@
+package prompts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sakibmulla/agentic-ai/endpoint"
+)
+
+// TestTemplateBundle round-trips user templates and upgrades a single
+// template file written before bundles
+func TestTemplateBundle(t *testing.T) {
+	dir := t.TempDir()
+	legacy := filepath.Join(dir, "greeting.json")
+	single := `{"name":"greeting","description":"Greets","template":"Hello {{.name}}","variables":["name"],"category":"demo"}`
+	if err := os.WriteFile(legacy, []byte(single), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	templates, err := LoadTemplateBundle(legacy)
+	if err != nil || len(templates) != 1 || templates[0].Name != "greeting" {
+		t.Fatalf("Expected the single template as a bundle of one, got %+v (%v)", templates, err)
+	}
+	if backup, err := os.ReadFile(legacy + ".v1"); err != nil || string(backup) != single {
+		t.Errorf("Expected the original file kept as .v1, got %q (%v)", backup, err)
+	}
+
+	engine := NewPromptEngine("test-key", endpoint.Endpoint{}, nil)
+	if _, err := engine.SaveTemplateBundle(filepath.Join(dir, "none.json")); err == nil {
+		t.Error("Expected saving with no user templates to fail")
+	}
+	if _, err := engine.AddUserTemplate(templates[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "bundle.json")
+	if saved, err := engine.SaveTemplateBundle(path); err != nil || saved != 1 {
+		t.Fatalf("Expected 1 template saved, got %d (%v)", saved, err)
+	}
+	reloaded, err := LoadTemplateBundle(path)
+	if err != nil || len(reloaded) != 1 || reloaded[0].Template != "Hello {{.name}}" {
+		t.Errorf("Expected the saved bundle to load, got %+v (%v)", reloaded, err)
+	}
+}
@@ -0,0 +1,143 @@
+package prompts
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// CodeSandboxConfig limits a sandboxed `go test` run
+type CodeSandboxConfig struct {
+	Timeout time.Duration
+	// MaxOutputBytes caps the captured test output
+	MaxOutputBytes int
+}
+
+// DefaultCodeSandboxConfig returns limits suitable for small generated programs
+func DefaultCodeSandboxConfig() CodeSandboxConfig {
+	return CodeSandboxConfig{
+		Timeout:        60 * time.Second,
+		MaxOutputBytes: 16 * 1024,
+	}
+}
+
+// TestRun is the outcome of one sandboxed test run
+type TestRun struct {
+	Passed   bool          `json:"passed"`
+	TimedOut bool          `json:"timed_out"`
+	Output   string        `json:"output"`
+	Duration time.Duration `json:"duration"`
+}
+
+// sandboxGoMod is the module file generated code is tested in. It has no
+// requirements, so code using anything beyond the standard library fails
+// to build instead of downloading dependencies.
+const sandboxGoMod = "module sandbox\n\ngo 1.21\n"
+
+// RunGoTests writes the files into a fresh temporary module and runs
+// `go test` there with a timeout, a minimal environment and module
+// downloads disabled. A failing test is reported in the TestRun, not as an
+// error; errors mean the sandbox itself could not run.
+func RunGoTests(ctx context.Context, files map[string]string, config CodeSandboxConfig) (*TestRun, error) {
+	dir, err := os.MkdirTemp("", "codegen-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sandbox directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(sandboxGoMod), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write go.mod: %w", err)
+	}
+	for name, content := range files {
+		if filepath.Base(name) != name || name == "go.mod" {
+			return nil, fmt.Errorf("invalid sandbox file name: %s", name)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "go", "test", "-count=1", "./...")
+	cmd.Dir = dir
+	cmd.Env = sandboxEnv(dir)
+	cmd.WaitDelay = 2 * time.Second
+	output := &limitedBuffer{limit: config.MaxOutputBytes}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	start := time.Now()
+	err = cmd.Run()
+	run := &TestRun{
+		Passed:   err == nil,
+		TimedOut: errors.Is(ctx.Err(), context.DeadlineExceeded),
+		Output:   output.String(),
+		Duration: time.Since(start),
+	}
+
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) && !run.TimedOut {
+		return nil, fmt.Errorf("failed to run go test: %w", err)
+	}
+	if run.TimedOut {
+		run.Output += fmt.Sprintf("\n[sandbox: killed after %v]", config.Timeout)
+	}
+	return run, nil
+}
+
+// sandboxEnv passes through only what the go tool needs to find itself and
+// its build cache; module proxies and cgo are disabled
+func sandboxEnv(dir string) []string {
+	env := []string{
+		"HOME=" + dir,
+		"GOPROXY=off",
+		"GOFLAGS=-mod=mod",
+		"GOTOOLCHAIN=local",
+		"CGO_ENABLED=0",
+	}
+	for _, key := range []string{"PATH", "GOROOT", "GOPATH", "GOCACHE", "TMPDIR"} {
+		if value, ok := os.LookupEnv(key); ok {
+			env = append(env, key+"="+value)
+		}
+	}
+	if _, ok := os.LookupEnv("GOCACHE"); !ok {
+		if cache, err := os.UserCacheDir(); err == nil {
+			env = append(env, "GOCACHE="+filepath.Join(cache, "go-build"))
+		}
+	}
+	return env
+}
+
+// limitedBuffer keeps the first limit bytes written to it
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+// Write implements io.Writer, discarding output past the limit
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); room < len(p) {
+		b.truncated = true
+		if room > 0 {
+			b.buf.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// String returns the captured output
+func (b *limitedBuffer) String() string {
+	if b.truncated {
+		return b.buf.String() + "\n[output truncated]"
+	}
+	return b.buf.String()
+}
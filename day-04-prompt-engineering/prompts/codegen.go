@@ -0,0 +1,200 @@
+package prompts
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// CodeGenConfig controls the generate → test → repair loop
+type CodeGenConfig struct {
+	// MaxRepairs is how many times failing code is sent back for a fix
+	MaxRepairs int
+	// RepairTemperature is used for test generation and repairs
+	RepairTemperature float32
+	Sandbox           CodeSandboxConfig
+}
+
+// DefaultCodeGenConfig returns sensible defaults for generated-code testing
+func DefaultCodeGenConfig() CodeGenConfig {
+	return CodeGenConfig{
+		MaxRepairs:        3,
+		RepairTemperature: 0.2,
+		Sandbox:           DefaultCodeSandboxConfig(),
+	}
+}
+
+// CodeGenResult is the final code and tests with the history of test runs
+type CodeGenResult struct {
+	Code    string     `json:"code"`
+	Tests   string     `json:"tests"`
+	Passed  bool       `json:"passed"`
+	Repairs int        `json:"repairs"`
+	Runs    []*TestRun `json:"runs"`
+	// RepairError is set when the loop stopped because a repair request failed
+	RepairError string `json:"repair_error,omitempty"`
+}
+
+var (
+	// goBlockPattern matches fenced Go code blocks
+	goBlockPattern = regexp.MustCompile("(?s)```(?:go|golang)?[ \\t]*\\n(.*?)```")
+	// packagePattern finds the package clause of a Go file
+	packagePattern = regexp.MustCompile(`(?m)^package\s+(\w+)`)
+)
+
+// extractGoBlocks returns the contents of the fenced Go code blocks in a
+// response, or the whole response when it has no fences but looks like Go
+func extractGoBlocks(response string) []string {
+	var blocks []string
+	for _, match := range goBlockPattern.FindAllStringSubmatch(response, -1) {
+		if block := strings.TrimSpace(match[1]); block != "" {
+			blocks = append(blocks, block+"\n")
+		}
+	}
+	if len(blocks) == 0 && packagePattern.MatchString(response) {
+		blocks = append(blocks, strings.TrimSpace(response)+"\n")
+	}
+	return blocks
+}
+
+// splitCodeAndTests separates test files from code among extracted blocks
+func splitCodeAndTests(blocks []string) (code, tests string) {
+	for _, block := range blocks {
+		if strings.Contains(block, "*testing.T") {
+			if tests == "" {
+				tests = block
+			}
+		} else if code == "" && packagePattern.MatchString(block) {
+			code = block
+		}
+	}
+	return code, tests
+}
+
+// packageName returns the package a Go file declares, defaulting to main
+func packageName(code string) string {
+	if match := packagePattern.FindStringSubmatch(code); match != nil {
+		return match[1]
+	}
+	return "main"
+}
+
+// GenerateCodeWithTests runs the code_generation template, asks the model for
+// table-driven tests of the result, runs them in the sandbox and sends
+// failures back for repair up to config.MaxRepairs times.
+func (pe *PromptEngine) GenerateCodeWithTests(ctx context.Context, variables map[string]interface{}, config CodeGenConfig) (*CodeGenResult, error) {
+	execution, err := pe.ExecutePrompt(ctx, "code_generation", variables)
+	if err != nil {
+		return nil, err
+	}
+
+	code, tests := splitCodeAndTests(extractGoBlocks(execution.Response))
+	if code == "" {
+		return nil, fmt.Errorf("no Go code found in the model's response")
+	}
+
+	// Always generate fresh table-driven tests rather than trusting inline ones
+	tests, err = pe.generateTests(ctx, code, config.RepairTemperature)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CodeGenResult{Code: code, Tests: tests}
+	for {
+		run, err := RunGoTests(ctx, map[string]string{
+			"solution.go":      result.Code,
+			"solution_test.go": result.Tests,
+		}, config.Sandbox)
+		if err != nil {
+			return nil, err
+		}
+		result.Runs = append(result.Runs, run)
+		result.Passed = run.Passed
+
+		if run.Passed || result.Repairs >= config.MaxRepairs {
+			return result, nil
+		}
+
+		code, tests, err := pe.repairCode(ctx, result.Code, result.Tests, run.Output, config.RepairTemperature)
+		if err != nil {
+			// Return what we have; the failing run explains the state
+			result.RepairError = err.Error()
+			return result, nil
+		}
+		result.Code, result.Tests = code, tests
+		result.Repairs++
+	}
+}
+
+// generateTests asks the model for table-driven tests of code
+func (pe *PromptEngine) generateTests(ctx context.Context, code string, temperature float32) (string, error) {
+	prompt := fmt.Sprintf(`Write table-driven Go tests for the code below.
+
+Rules:
+- Use package %s and only the standard library ("testing" plus whatever the code needs)
+- One test function per exported function, each looping over a []struct of cases with t.Run
+- Cover normal inputs and edge cases
+- Do not repeat the code under test
+
+Code:
+`+"```go\n%s```"+`
+
+Respond with a single `+"```go"+` block containing the complete test file.`, packageName(code), code)
+
+	execution, err := pe.complete(ctx, prompt, temperature)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate tests: %w", err)
+	}
+	for _, block := range extractGoBlocks(execution.Response) {
+		if strings.Contains(block, "*testing.T") {
+			return block, nil
+		}
+	}
+	return "", fmt.Errorf("no test file found in the model's response")
+}
+
+// repairCode sends failing code, its tests and the test output back to the
+// model and returns the corrected code and tests
+func (pe *PromptEngine) repairCode(ctx context.Context, code, tests, output string, temperature float32) (string, string, error) {
+	prompt := fmt.Sprintf(`The Go code below fails its tests. Fix it.
+
+Prefer fixing the code. Change a test only if the test itself is clearly wrong.
+Use only the standard library.
+
+Code (solution.go):
+`+"```go\n%s```"+`
+
+Tests (solution_test.go):
+`+"```go\n%s```"+`
+
+go test output:
+`+"```\n%s\n```"+`
+
+Respond with exactly two `+"```go"+` blocks: first the complete solution.go, then the complete solution_test.go.`, code, tests, output)
+
+	execution, err := pe.complete(ctx, prompt, temperature)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to repair code: %w", err)
+	}
+
+	fixedCode, fixedTests := splitCodeAndTests(extractGoBlocks(execution.Response))
+	if fixedCode == "" {
+		return "", "", fmt.Errorf("no Go code found in the repair response")
+	}
+	if fixedTests == "" {
+		fixedTests = tests
+	}
+	return fixedCode, fixedTests, nil
+}
+
+// Summary reports whether the tests passed and how many repairs it took
+func (r *CodeGenResult) Summary() string {
+	status := "❌ tests failing"
+	if r.Passed {
+		status = "✅ tests passing"
+	}
+	last := r.Runs[len(r.Runs)-1]
+	return fmt.Sprintf("%s after %d run(s), %d repair(s); last run took %v", status, len(r.Runs), r.Repairs, last.Duration.Round(time.Millisecond))
+}
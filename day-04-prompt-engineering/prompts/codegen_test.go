@@ -0,0 +1,71 @@
+package prompts
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/sakibmulla/agentic-ai/endpoint"
+	"github.com/sashabaranov/go-openai"
+)
+
+// TestGenerateCodeWithTests drives the generate → test → repair loop against
+// a scripted model whose first answer is buggy
+func TestGenerateCodeWithTests(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available")
+	}
+
+	const buggy = "package mathx\n\n// Abs returns the absolute value of n\nfunc Abs(n int) int {\n\treturn n\n}\n"
+	const fixed = "package mathx\n\n// Abs returns the absolute value of n\nfunc Abs(n int) int {\n\tif n < 0 {\n\t\treturn -n\n\t}\n\treturn n\n}\n"
+	const tests = "package mathx\n\nimport \"testing\"\n\nfunc TestAbs(t *testing.T) {\n\tfor _, tc := range []struct{ in, want int }{{1, 1}, {-2, 2}, {0, 0}} {\n\t\tif got := Abs(tc.in); got != tc.want {\n\t\t\tt.Errorf(\"Abs(%d) = %d, want %d\", tc.in, got, tc.want)\n\t\t}\n\t}\n}\n"
+
+	replies := []string{
+		"Here you go:\n```go\n" + buggy + "```",
+		"```go\n" + tests + "```",
+		"```go\n" + fixed + "```\n```go\n" + tests + "```",
+	}
+	var prompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		prompts = append(prompts, req.Messages[0].Content)
+
+		reply := replies[len(prompts)-1]
+		json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Role: "assistant", Content: reply}}},
+		})
+	}))
+	defer server.Close()
+
+	engine := NewPromptEngine("test-key", endpoint.Endpoint{}, nil)
+	config := openai.DefaultConfig("test-key")
+	config.BaseURL = server.URL + "/v1"
+	engine.client = openai.NewClientWithConfig(config)
+
+	result, err := engine.GenerateCodeWithTests(context.Background(), map[string]interface{}{
+		"task":         "Absolute value of an int",
+		"requirements": []string{"Handle negatives"},
+		"context":      "math helpers",
+	}, DefaultCodeGenConfig())
+	if err != nil {
+		t.Fatalf("GenerateCodeWithTests failed: %v", err)
+	}
+
+	if !result.Passed || result.Repairs != 1 || len(result.Runs) != 2 {
+		t.Fatalf("Expected one repair then passing tests, got %s", result.Summary())
+	}
+	if result.Runs[0].Passed || !strings.Contains(result.Runs[0].Output, "Abs(-2)") {
+		t.Errorf("Expected the first run to fail on Abs(-2), got:\n%s", result.Runs[0].Output)
+	}
+	if !strings.Contains(prompts[1], "package mathx") || !strings.Contains(prompts[2], "Abs(-2) = -2") {
+		t.Error("Test generation and repair prompts should carry the package and the failing output")
+	}
+	if result.Code != fixed {
+		t.Errorf("Expected the repaired code, got:\n%s", result.Code)
+	}
+}
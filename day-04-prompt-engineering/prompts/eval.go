@@ -0,0 +1,384 @@
+package prompts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sakibmulla/agentic-ai/persist"
+)
+
+// Criterion is one thing the judge scores a response on
+type Criterion struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Weight      float64 `json:"weight,omitempty"` // 1 when unset
+}
+
+// Rubric is the weighted criteria responses are judged against
+type Rubric struct {
+	Name     string      `json:"name"`
+	Criteria []Criterion `json:"criteria"`
+	// PassScore is the weighted score (0-1) a response needs to pass
+	PassScore float64 `json:"pass_score"`
+}
+
+// DefaultRubric judges any response on relevance, completeness and clarity
+var DefaultRubric = Rubric{
+	Name: "default",
+	Criteria: []Criterion{
+		{Name: "relevance", Description: "Answers the task that was asked, without drifting off topic"},
+		{Name: "completeness", Description: "Covers every part of the task and every requested section"},
+		{Name: "clarity", Description: "Is well organized, specific and easy to follow"},
+	},
+	PassScore: 0.7,
+}
+
+// EvalCase is one input to a template and what its response must do
+type EvalCase struct {
+	Name      string                 `json:"name"`
+	Variables map[string]interface{} `json:"variables"`
+	// Expect lists case-specific requirements, such as "recommends a
+	// next step"; each is judged like a criterion and must pass on its own
+	Expect []string `json:"expect,omitempty"`
+}
+
+// EvalSuite is a set of test cases for one template
+type EvalSuite struct {
+	Template string     `json:"template"`
+	Rubric   *Rubric    `json:"rubric,omitempty"` // DefaultRubric when unset
+	Cases    []EvalCase `json:"cases"`
+}
+
+// EvalConfig controls evaluation runs
+type EvalConfig struct {
+	// JudgeTemperature is used for the judge's scoring calls
+	JudgeTemperature float32
+	// RegressionDrop is how far a case's score may fall from the previous
+	// run before it counts as a regression
+	RegressionDrop float64
+}
+
+// DefaultEvalConfig returns sensible defaults for evaluation runs
+func DefaultEvalConfig() EvalConfig {
+	return EvalConfig{
+		JudgeTemperature: 0,
+		RegressionDrop:   0.15,
+	}
+}
+
+// CaseResult is the judged outcome of one test case
+type CaseResult struct {
+	Case       string             `json:"case"`
+	Passed     bool               `json:"passed"`
+	Score      float64            `json:"score"`
+	Scores     map[string]float64 `json:"scores,omitempty"`
+	Reasoning  string             `json:"reasoning,omitempty"`
+	TokensUsed int                `json:"tokens_used"`
+	Error      string             `json:"error,omitempty"`
+}
+
+// Regression is a case that got worse since the previous run of a template
+type Regression struct {
+	Case   string  `json:"case"`
+	Before float64 `json:"before"`
+	After  float64 `json:"after"`
+	Reason string  `json:"reason"`
+}
+
+// EvalReport is the result of running a suite once
+type EvalReport struct {
+	Template        string       `json:"template"`
+	TemplateVersion int          `json:"template_version"`
+	Rubric          string       `json:"rubric"`
+	RunAt           time.Time    `json:"run_at"`
+	PassRate        float64      `json:"pass_rate"`
+	AverageScore    float64      `json:"average_score"`
+	Cases           []CaseResult `json:"cases"`
+	Regressions     []Regression `json:"regressions,omitempty"`
+}
+
+// judgeVerdict is the JSON the judge is asked to reply with
+type judgeVerdict struct {
+	Scores    map[string]float64 `json:"scores"`
+	Reasoning string             `json:"reasoning"`
+}
+
+// jsonObjectPattern finds the outermost JSON object in a reply that wraps
+// it in prose or a code fence
+var jsonObjectPattern = regexp.MustCompile(`(?s)\{.*\}`)
+
+// LoadEvalSuite reads a suite from a JSON file
+func LoadEvalSuite(path string) (*EvalSuite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read eval suite: %w", err)
+	}
+
+	var suite EvalSuite
+	if err := json.Unmarshal(data, &suite); err != nil {
+		return nil, fmt.Errorf("failed to parse eval suite: %w", err)
+	}
+	if suite.Template == "" || len(suite.Cases) == 0 {
+		return nil, fmt.Errorf("eval suite %s needs a template and at least one case", path)
+	}
+	return &suite, nil
+}
+
+// RunEvalSuite executes every case of a suite against the template's active
+// version and scores each response with the LLM judge. Each execution keeps
+// its judged score as Quality in the history. A case whose execution or
+// judging fails counts as failed.
+func (pe *PromptEngine) RunEvalSuite(ctx context.Context, suite *EvalSuite, config EvalConfig) (*EvalReport, error) {
+	template, err := pe.GetTemplate(suite.Template)
+	if err != nil {
+		return nil, err
+	}
+	rubric := DefaultRubric
+	if suite.Rubric != nil {
+		rubric = *suite.Rubric
+	}
+	if len(rubric.Criteria) == 0 {
+		return nil, fmt.Errorf("rubric %q has no criteria", rubric.Name)
+	}
+
+	report := &EvalReport{
+		Template:        template.Name,
+		TemplateVersion: template.Version,
+		Rubric:          rubric.Name,
+		RunAt:           time.Now(),
+	}
+	passed := 0
+	total := 0.0
+	for _, c := range suite.Cases {
+		result := pe.runEvalCase(ctx, template.Name, c, rubric, config)
+		if result.Passed {
+			passed++
+		}
+		total += result.Score
+		report.Cases = append(report.Cases, result)
+	}
+	report.PassRate = float64(passed) / float64(len(report.Cases))
+	report.AverageScore = total / float64(len(report.Cases))
+	return report, nil
+}
+
+// runEvalCase executes and judges one case
+func (pe *PromptEngine) runEvalCase(ctx context.Context, templateName string, c EvalCase, rubric Rubric, config EvalConfig) CaseResult {
+	result := CaseResult{Case: c.Name}
+
+	execution, err := pe.ExecutePrompt(ctx, templateName, c.Variables)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.TokensUsed = execution.TokensUsed
+
+	criteria := append([]Criterion(nil), rubric.Criteria...)
+	for i, expectation := range c.Expect {
+		criteria = append(criteria, Criterion{Name: fmt.Sprintf("expect_%d", i+1), Description: expectation})
+	}
+
+	verdict, tokens, err := pe.judge(ctx, execution, criteria, config)
+	result.TokensUsed += tokens
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Scores = verdict.Scores
+	result.Reasoning = verdict.Reasoning
+	result.Score = weightedScore(rubric.Criteria, verdict.Scores)
+	result.Passed = result.Score >= rubric.PassScore
+	for _, criterion := range criteria[len(rubric.Criteria):] {
+		if verdict.Scores[criterion.Name] < rubric.PassScore {
+			result.Passed = false
+		}
+	}
+
+	execution.Quality = result.Score
+	execution.Metadata["eval_case"] = c.Name
+	execution.Metadata["judge_reasoning"] = verdict.Reasoning
+	pe.updateLatest(ctx, execution)
+	return result
+}
+
+// judge scores an execution's response against criteria with the engine's model
+func (pe *PromptEngine) judge(ctx context.Context, execution *PromptExecution, criteria []Criterion, config EvalConfig) (*judgeVerdict, int, error) {
+	return judgeResponse(ctx, pe.complete, execution.GeneratedPrompt, execution.Response, criteria, config.JudgeTemperature)
+}
+
+// completeFunc makes one model call with a single user prompt
+type completeFunc func(ctx context.Context, prompt string, temperature float32) (*PromptExecution, error)
+
+// judgeResponse asks the model behind complete to score a response against
+// criteria, each from 0 to 10, and returns the scores scaled to 0-1
+func judgeResponse(ctx context.Context, complete completeFunc, prompt, response string, criteria []Criterion, temperature float32) (*judgeVerdict, int, error) {
+	var list strings.Builder
+	for _, criterion := range criteria {
+		fmt.Fprintf(&list, "- %s: %s\n", criterion.Name, criterion.Description)
+	}
+
+	judgePrompt := fmt.Sprintf(`You are a strict evaluator of AI responses. Score the response below against each criterion from 0 (not met at all) to 10 (fully met). Judge only what the response says, not what it could have said.
+
+Prompt given to the AI:
+<prompt>
+%s
+</prompt>
+
+Response to evaluate:
+<response>
+%s
+</response>
+
+Criteria:
+%s
+Reply with only a JSON object: {"scores": {"<criterion>": <0-10>, ...}, "reasoning": "<one or two sentences>"}`,
+		prompt, response, list.String())
+
+	reply, err := complete(ctx, judgePrompt, temperature)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to judge response: %w", err)
+	}
+
+	var verdict judgeVerdict
+	if err := json.Unmarshal([]byte(jsonObjectPattern.FindString(reply.Response)), &verdict); err != nil {
+		return nil, reply.TokensUsed, fmt.Errorf("failed to parse judge verdict: %w", err)
+	}
+	for _, criterion := range criteria {
+		score, ok := verdict.Scores[criterion.Name]
+		if !ok {
+			return nil, reply.TokensUsed, fmt.Errorf("judge did not score %q", criterion.Name)
+		}
+		verdict.Scores[criterion.Name] = clampScore(score / 10)
+	}
+	return &verdict, reply.TokensUsed, nil
+}
+
+// weightedScore averages the scores of criteria by weight
+func weightedScore(criteria []Criterion, scores map[string]float64) float64 {
+	total, weights := 0.0, 0.0
+	for _, criterion := range criteria {
+		weight := criterion.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		total += weight * scores[criterion.Name]
+		weights += weight
+	}
+	if weights == 0 {
+		return 0
+	}
+	return total / weights
+}
+
+// clampScore bounds a score to 0-1
+func clampScore(score float64) float64 {
+	if score < 0 {
+		return 0
+	}
+	if score > 1 {
+		return 1
+	}
+	return score
+}
+
+// EvalHistory is every report of every template, oldest first
+type EvalHistory struct {
+	Reports []EvalReport `json:"reports"`
+}
+
+// evalHistoryFormat versions the eval history file
+var evalHistoryFormat = persist.NewFormat("eval-history", 1)
+
+// LoadEvalHistory reads the eval history at path. A missing file is an
+// empty history.
+func LoadEvalHistory(path string) (*EvalHistory, error) {
+	var history EvalHistory
+	if err := evalHistoryFormat.ReadFile(path, &history); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to load eval history: %w", err)
+	}
+	return &history, nil
+}
+
+// Save writes the history to path
+func (h *EvalHistory) Save(path string) error {
+	if err := evalHistoryFormat.WriteFile(path, h, 0644); err != nil {
+		return fmt.Errorf("failed to save eval history: %w", err)
+	}
+	return nil
+}
+
+// Latest returns the most recent report of a template, or nil
+func (h *EvalHistory) Latest(template string) *EvalReport {
+	for i := len(h.Reports) - 1; i >= 0; i-- {
+		if h.Reports[i].Template == template {
+			return &h.Reports[i]
+		}
+	}
+	return nil
+}
+
+// ForTemplate returns the reports of a template, oldest first
+func (h *EvalHistory) ForTemplate(template string) []EvalReport {
+	var reports []EvalReport
+	for _, report := range h.Reports {
+		if report.Template == template {
+			reports = append(reports, report)
+		}
+	}
+	return reports
+}
+
+// Record compares report with the template's previous run, fills in its
+// regressions and appends it
+func (h *EvalHistory) Record(report *EvalReport, config EvalConfig) {
+	if previous := h.Latest(report.Template); previous != nil {
+		report.Regressions = findRegressions(previous, report, config.RegressionDrop)
+	}
+	h.Reports = append(h.Reports, *report)
+}
+
+// findRegressions lists the cases that passed before and fail now, or whose
+// score fell by more than drop. Cases new to this run can't regress.
+func findRegressions(before, after *EvalReport, drop float64) []Regression {
+	previous := make(map[string]CaseResult, len(before.Cases))
+	for _, result := range before.Cases {
+		previous[result.Case] = result
+	}
+
+	var regressions []Regression
+	for _, result := range after.Cases {
+		old, ok := previous[result.Case]
+		if !ok {
+			continue
+		}
+		regression := Regression{Case: result.Case, Before: old.Score, After: result.Score}
+		switch {
+		case old.Passed && !result.Passed:
+			regression.Reason = fmt.Sprintf("passed on v%d, fails on v%d", before.TemplateVersion, after.TemplateVersion)
+		case old.Score-result.Score > drop:
+			regression.Reason = fmt.Sprintf("score fell %.2f → %.2f", old.Score, result.Score)
+		default:
+			continue
+		}
+		regressions = append(regressions, regression)
+	}
+	return regressions
+}
+
+// Summary reports the pass rate, score and regressions of a run
+func (r *EvalReport) Summary() string {
+	status := "✅ no regressions"
+	if len(r.Regressions) > 0 {
+		status = fmt.Sprintf("⚠️ %d regression(s)", len(r.Regressions))
+	}
+	return fmt.Sprintf("%s v%d: %.0f%% passed, average score %.2f (%s rubric); %s",
+		r.Template, r.TemplateVersion, r.PassRate*100, r.AverageScore, r.Rubric, status)
+}
@@ -0,0 +1,101 @@
+package prompts
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sakibmulla/agentic-ai/endpoint"
+	"github.com/sashabaranov/go-openai"
+)
+
+// TestRunEvalSuite judges a suite twice against a scripted model and checks
+// that the second, worse run is reported as a regression
+func TestRunEvalSuite(t *testing.T) {
+	verdict := `{"scores": {"relevance": 9, "completeness": 8, "clarity": 8, "expect_1": 9}, "reasoning": "Covers the trend."}`
+	var judgePrompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		reply := "1. Sales rose 20%, likely seasonal."
+		if prompt := req.Messages[0].Content; strings.Contains(prompt, "strict evaluator") {
+			judgePrompts = append(judgePrompts, prompt)
+			reply = "Here is my verdict:\n```json\n" + verdict + "\n```"
+		}
+		json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Role: "assistant", Content: reply}}},
+			Usage:   openai.Usage{TotalTokens: 10},
+		})
+	}))
+	defer server.Close()
+
+	engine := NewPromptEngine("test-key", endpoint.Endpoint{}, nil)
+	config := openai.DefaultConfig("test-key")
+	config.BaseURL = server.URL + "/v1"
+	engine.client = openai.NewClientWithConfig(config)
+
+	suite := &EvalSuite{
+		Template: "data_analysis",
+		Cases: []EvalCase{{
+			Name:      "holiday_sales",
+			Variables: map[string]interface{}{"domain": "e-commerce", "data": "20% increase", "analysis_type": "trend", "context": "Q4"},
+			Expect:    []string{"Mentions seasonality"},
+		}},
+	}
+
+	report, err := engine.RunEvalSuite(context.Background(), suite, DefaultEvalConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := report.Cases[0]
+	if !result.Passed || report.PassRate != 1 || result.Score < 0.83 || result.Score > 0.84 || result.TokensUsed != 20 {
+		t.Fatalf("Expected the case to pass with score 0.83 and 20 tokens, got %+v", result)
+	}
+	if !strings.Contains(judgePrompts[0], "expect_1: Mentions seasonality") || !strings.Contains(judgePrompts[0], "likely seasonal") {
+		t.Error("The judge prompt should list the case's expectations and carry the response")
+	}
+	if history := engine.GetPromptHistory(); history[0].Quality != result.Score || history[0].TemplateVersion != 1 {
+		t.Errorf("Expected the execution to record its judged quality and version, got %+v", history[0])
+	}
+
+	path := filepath.Join(t.TempDir(), "eval_history.json")
+	history, err := LoadEvalHistory(path)
+	if err != nil {
+		t.Fatalf("Expected a missing history file to load as empty, got %v", err)
+	}
+	history.Record(report, DefaultEvalConfig())
+	if err := history.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// An expectation the response misses fails the case even though the
+	// overall score is high
+	verdict = `{"scores": {"relevance": 9, "completeness": 8, "clarity": 8, "expect_1": 2}, "reasoning": "Ignores seasonality."}`
+	second, err := engine.RunEvalSuite(context.Background(), suite, DefaultEvalConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Cases[0].Passed || second.PassRate != 0 {
+		t.Fatalf("Expected the missed expectation to fail the case, got %+v", second.Cases[0])
+	}
+
+	history, err = LoadEvalHistory(path)
+	if err != nil || len(history.Reports) != 1 {
+		t.Fatalf("Expected the saved history to reload, got %+v (%v)", history, err)
+	}
+	history.Record(second, DefaultEvalConfig())
+	if len(second.Regressions) != 1 || second.Regressions[0].Case != "holiday_sales" {
+		t.Errorf("Expected holiday_sales to regress, got %+v", second.Regressions)
+	}
+
+	verdict = `{"scores": {"relevance": 9}}`
+	third, _ := engine.RunEvalSuite(context.Background(), suite, DefaultEvalConfig())
+	if third.Cases[0].Passed || !strings.Contains(third.Cases[0].Error, "did not score") {
+		t.Errorf("Expected an incomplete verdict to fail the case, got %+v", third.Cases[0])
+	}
+}
@@ -0,0 +1,236 @@
+package prompts
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+
+	"github.com/sakibmulla/agentic-ai/costs"
+	"github.com/sakibmulla/agentic-ai/persist"
+	"github.com/sashabaranov/go-openai"
+)
+
+// LabeledExample is one worked example for a few-shot template: an input,
+// the output wanted for it and, optionally, why
+type LabeledExample struct {
+	Input       string `json:"input"`
+	Output      string `json:"output"`
+	Explanation string `json:"explanation,omitempty"`
+	// Embedding of Input, set when the example is added to a store
+	Embedding []float32 `json:"embedding,omitempty"`
+}
+
+// ExampleSet is a file of labeled examples, read by `examples`
+type ExampleSet struct {
+	Examples []LabeledExample `json:"examples"`
+}
+
+// exampleSetFormat versions example files
+var exampleSetFormat = persist.NewFormat("example-set", 1)
+
+// LoadExampleSet reads the labeled examples in path
+func LoadExampleSet(path string) ([]LabeledExample, error) {
+	var set ExampleSet
+	if err := exampleSetFormat.ReadFile(path, &set); err != nil {
+		return nil, fmt.Errorf("failed to load examples: %w", err)
+	}
+	if len(set.Examples) == 0 {
+		return nil, fmt.Errorf("example file %s has no examples", path)
+	}
+	return set.Examples, nil
+}
+
+// ExampleStore is a vector store of labeled examples, searched by the
+// similarity of their inputs
+type ExampleStore struct {
+	client   *openai.Client
+	examples []LabeledExample
+}
+
+// NewExampleStore creates an empty store that embeds with the engine's client
+func (pe *PromptEngine) NewExampleStore() *ExampleStore {
+	return &ExampleStore{client: pe.client}
+}
+
+// Add embeds the inputs of the examples that have no embedding yet, in one
+// call, and stores them all
+func (s *ExampleStore) Add(ctx context.Context, examples ...LabeledExample) error {
+	var inputs []string
+	var missing []int
+	for i, example := range examples {
+		if len(example.Embedding) == 0 {
+			inputs = append(inputs, example.Input)
+			missing = append(missing, i)
+		}
+	}
+
+	if len(inputs) > 0 {
+		vectors, err := s.embed(ctx, inputs)
+		if err != nil {
+			return err
+		}
+		examples = append([]LabeledExample(nil), examples...)
+		for j, i := range missing {
+			examples[i].Embedding = vectors[j]
+		}
+	}
+	s.examples = append(s.examples, examples...)
+	return nil
+}
+
+// Len returns the number of examples stored
+func (s *ExampleStore) Len() int {
+	return len(s.examples)
+}
+
+// Similar returns the k examples whose inputs are most similar to input,
+// most similar first
+func (s *ExampleStore) Similar(ctx context.Context, input string, k int) ([]LabeledExample, error) {
+	vectors, err := s.embed(ctx, []string{input})
+	if err != nil {
+		return nil, err
+	}
+	query := vectors[0]
+
+	order := make([]int, len(s.examples))
+	scores := make([]float64, len(s.examples))
+	for i, example := range s.examples {
+		order[i] = i
+		scores[i] = cosineSimilarity(query, example.Embedding)
+	}
+	sort.SliceStable(order, func(i, j int) bool { return scores[order[i]] > scores[order[j]] })
+	if k < len(order) {
+		order = order[:k]
+	}
+
+	similar := make([]LabeledExample, len(order))
+	for i, index := range order {
+		similar[i] = s.examples[index]
+	}
+	return similar, nil
+}
+
+// embed returns the embeddings of texts, in order
+func (s *ExampleStore) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	resp, err := s.client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
+		Input: texts,
+		Model: openai.AdaEmbeddingV2,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed examples: %w", err)
+	}
+	if len(resp.Data) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(resp.Data))
+	}
+	vectors := make([][]float32, len(texts))
+	for _, data := range resp.Data {
+		if data.Index < 0 || data.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding index %d out of range", data.Index)
+		}
+		vectors[data.Index] = data.Embedding
+	}
+	return vectors, nil
+}
+
+// cosineSimilarity compares two embeddings; ones that can't be compared
+// score below any that can
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return -2
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		x, y := float64(a[i]), float64(b[i])
+		dot += x * y
+		normA += x * x
+		normB += y * y
+	}
+	if normA == 0 || normB == 0 {
+		return -2
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// ExampleSelector fills a few-shot template's examples with the stored ones
+// most similar to the new input, instead of a fixed list
+type ExampleSelector struct {
+	Store *ExampleStore
+	// K is the number of examples chosen
+	K int
+	// InputVariable holds the new input; "new_input" when empty
+	InputVariable string
+	// ExamplesVariable receives the examples, each with number, input,
+	// output and explanation; "examples" when empty
+	ExamplesVariable string
+}
+
+// SetExampleSelector makes the template's examples come from selector when
+// the caller doesn't supply them. A nil selector removes it.
+func (pe *PromptEngine) SetExampleSelector(templateName string, selector *ExampleSelector) error {
+	if _, err := pe.GetTemplate(templateName); err != nil {
+		return err
+	}
+	if selector == nil {
+		delete(pe.selectors, templateName)
+		return nil
+	}
+	if selector.Store == nil || selector.K <= 0 {
+		return fmt.Errorf("example selector needs a store and K of at least 1")
+	}
+	pe.selectors[templateName] = selector
+	return nil
+}
+
+// selectExamples returns variables with the examples filled in by the
+// template's selector, if it has one and the caller supplied none. If the
+// new input can't be embedded, the first K examples are used.
+func (pe *PromptEngine) selectExamples(ctx context.Context, templateName string, variables map[string]interface{}) (map[string]interface{}, error) {
+	selector, ok := pe.selectors[templateName]
+	if !ok {
+		return variables, nil
+	}
+	examplesVar, inputVar := selector.ExamplesVariable, selector.InputVariable
+	if examplesVar == "" {
+		examplesVar = "examples"
+	}
+	if inputVar == "" {
+		inputVar = "new_input"
+	}
+	if _, supplied := variables[examplesVar]; supplied {
+		return variables, nil
+	}
+	input, ok := variables[inputVar]
+	if !ok {
+		return nil, fmt.Errorf("template '%s' selects examples by {{.%s}}, which is missing", templateName, inputVar)
+	}
+
+	ctx = costs.WithAttribution(ctx, costs.Attribution{Template: templateName})
+	examples, err := selector.Store.Similar(ctx, fmt.Sprintf("%v", input), selector.K)
+	if err != nil {
+		slog.Warn("failed to select similar examples, using the first ones", "template", templateName, "error", err)
+		examples = selector.Store.examples
+		if selector.K < len(examples) {
+			examples = examples[:selector.K]
+		}
+	}
+
+	filled := make([]map[string]interface{}, len(examples))
+	for i, example := range examples {
+		filled[i] = map[string]interface{}{
+			"number":      i + 1,
+			"input":       example.Input,
+			"output":      example.Output,
+			"explanation": example.Explanation,
+		}
+	}
+
+	// The caller's map is left as it was
+	withExamples := make(map[string]interface{}, len(variables)+1)
+	for k, v := range variables {
+		withExamples[k] = v
+	}
+	withExamples[examplesVar] = filled
+	return withExamples, nil
+}
@@ -0,0 +1,97 @@
+package prompts
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sakibmulla/agentic-ai/endpoint"
+	"github.com/sashabaranov/go-openai"
+)
+
+// topicEmbedding embeds text by which of a few topics it mentions, so
+// similarity is predictable
+func topicEmbedding(text string) []float32 {
+	text = strings.ToLower(text)
+	vector := make([]float32, 4)
+	for i, topic := range []string{"file", "user", "string", "queue"} {
+		if strings.Contains(text, topic) {
+			vector[i] = 1
+		}
+	}
+	vector[3] += 0.1
+	return vector
+}
+
+func TestExampleSelection(t *testing.T) {
+	embedCalls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input []string `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		embedCalls++
+		var resp openai.EmbeddingResponse
+		for i, text := range req.Input {
+			resp.Data = append(resp.Data, openai.Embedding{Index: i, Embedding: topicEmbedding(text)})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	engine := NewPromptEngine("test-key", endpoint.Endpoint{}, nil)
+	config := openai.DefaultConfig("test-key")
+	config.BaseURL = server.URL + "/v1"
+	engine.client = openai.NewClientWithConfig(config)
+	ctx := context.Background()
+
+	examples, err := LoadExampleSet("../fewshot/go_naming.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := engine.NewExampleStore()
+	if err := store.Add(ctx, examples...); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if embedCalls != 1 || store.Len() != len(examples) {
+		t.Errorf("Expected all examples embedded in one call, got %d calls", embedCalls)
+	}
+	if err := engine.SetExampleSelector("few_shot_learning", &ExampleSelector{Store: store, K: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	variables := map[string]interface{}{
+		"task_type": "function naming in Go",
+		"new_input": "Function that converts string to uppercase",
+	}
+	prompt, err := engine.GeneratePrompt("few_shot_learning", variables)
+	if err != nil {
+		t.Fatalf("GeneratePrompt failed: %v", err)
+	}
+	if !strings.Contains(prompt, "Example 1:\nInput: Function that converts a string to title case") || !strings.Contains(prompt, "TrimSpace") {
+		t.Errorf("Expected the two string examples, got:\n%s", prompt)
+	}
+	if strings.Contains(prompt, "LoadConfig") || strings.Contains(prompt, "Example 3") {
+		t.Errorf("Expected only the 2 most similar examples, got:\n%s", prompt)
+	}
+	if _, changed := variables["examples"]; changed {
+		t.Error("Expected the caller's variables left alone")
+	}
+
+	// Examples the caller passes win over the selector
+	variables["examples"] = []map[string]interface{}{{"number": 1, "input": "Function that sums ints", "output": "Sum"}}
+	if prompt, _ := engine.GeneratePrompt("few_shot_learning", variables); !strings.Contains(prompt, "Sum") || strings.Contains(prompt, "TrimSpace") {
+		t.Errorf("Expected the caller's examples, got:\n%s", prompt)
+	}
+
+	// Without embeddings the first K examples are used
+	server.Close()
+	delete(variables, "examples")
+	prompt, err = engine.GeneratePrompt("few_shot_learning", variables)
+	if err != nil || !strings.Contains(prompt, "LoadConfig") || !strings.Contains(prompt, "isAdmin") {
+		t.Errorf("Expected the first examples as a fallback, got %v:\n%s", err, prompt)
+	}
+}
@@ -0,0 +1,330 @@
+package prompts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sakibmulla/agentic-ai/costs"
+)
+
+// Variant is one of the prompts an experiment compares. "{{input}}" in the
+// prompt is replaced by each of the experiment's inputs; without it, the
+// input is appended.
+type Variant struct {
+	Name   string `json:"name"`
+	Prompt string `json:"prompt"`
+}
+
+// Experiment compares prompt variants on a shared set of inputs
+type Experiment struct {
+	Name     string    `json:"name"`
+	Variants []Variant `json:"variants"`
+	Inputs   []string  `json:"inputs,omitempty"`
+	// Rubric is what the judge scores against; DefaultRubric when unset
+	Rubric *Rubric `json:"rubric,omitempty"`
+}
+
+// ExperimentConfig controls an experiment run
+type ExperimentConfig struct {
+	// Runs is how many times each variant answers each input
+	Runs int
+	// Temperature is used for the variants' calls
+	Temperature float32
+	// Confidence is the level of the reported intervals, e.g. 0.95
+	Confidence float64
+	// Judge scores responses with the model against the rubric; otherwise
+	// the ScoreResponse heuristics are used
+	Judge            bool
+	JudgeTemperature float32
+}
+
+// DefaultExperimentConfig returns sensible defaults for experiments
+func DefaultExperimentConfig() ExperimentConfig {
+	return ExperimentConfig{
+		Runs:        3,
+		Temperature: 0.7,
+		Confidence:  0.95,
+		Judge:       true,
+	}
+}
+
+// Trial is one variant answering one input once
+type Trial struct {
+	Variant   string        `json:"variant"`
+	Input     int           `json:"input"`
+	Run       int           `json:"run"`
+	Score     float64       `json:"score"`
+	Heuristic float64       `json:"heuristic"`
+	Latency   time.Duration `json:"latency"`
+	Tokens    int           `json:"tokens"`
+	// JudgeTokens were spent scoring the response, not producing it
+	JudgeTokens int    `json:"judge_tokens,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// VariantResult summarizes a variant's successful trials
+type VariantResult struct {
+	Name      string   `json:"name"`
+	Trials    int      `json:"trials"`
+	Failures  int      `json:"failures"`
+	Score     Estimate `json:"score"`
+	LatencyMs Estimate `json:"latency_ms"`
+	Tokens    Estimate `json:"tokens"`
+}
+
+// Comparison is the leading variant's score minus another's
+type Comparison struct {
+	Leader     string  `json:"leader"`
+	Other      string  `json:"other"`
+	Difference float64 `json:"difference"`
+	Low        float64 `json:"low"`
+	High       float64 `json:"high"`
+	// Significant is set when the interval excludes zero
+	Significant bool `json:"significant"`
+}
+
+// ExperimentReport is the outcome of an experiment
+type ExperimentReport struct {
+	Experiment string    `json:"experiment"`
+	RunAt      time.Time `json:"run_at"`
+	Runs       int       `json:"runs"`
+	Inputs     int       `json:"inputs"`
+	Confidence float64   `json:"confidence"`
+	Judged     bool      `json:"judged"`
+	// Variants are ordered by mean score, best first
+	Variants    []VariantResult `json:"variants"`
+	Comparisons []Comparison    `json:"comparisons"`
+	// Winner is set only when the leader beats every other variant
+	Winner         string  `json:"winner,omitempty"`
+	Recommendation string  `json:"recommendation"`
+	Trials         []Trial `json:"trials"`
+}
+
+// LoadExperiment reads an experiment from a JSON file
+func LoadExperiment(path string) (*Experiment, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read experiment: %w", err)
+	}
+	var experiment Experiment
+	if err := json.Unmarshal(data, &experiment); err != nil {
+		return nil, fmt.Errorf("failed to parse experiment: %w", err)
+	}
+	return &experiment, nil
+}
+
+// validate checks an experiment and its config can produce a comparison
+func (e *Experiment) validate(config ExperimentConfig) error {
+	if len(e.Variants) < 2 {
+		return fmt.Errorf("an experiment needs at least two variants")
+	}
+	seen := make(map[string]bool)
+	for _, variant := range e.Variants {
+		if variant.Name == "" || variant.Prompt == "" {
+			return fmt.Errorf("every variant needs a name and a prompt")
+		}
+		if seen[variant.Name] {
+			return fmt.Errorf("duplicate variant %q", variant.Name)
+		}
+		seen[variant.Name] = true
+	}
+	if config.Runs < 1 || config.Runs*max(len(e.Inputs), 1) < 2 {
+		return fmt.Errorf("each variant needs at least two trials to estimate variance")
+	}
+	if config.Confidence <= 0 || config.Confidence >= 1 {
+		return fmt.Errorf("confidence must be between 0 and 1, got %v", config.Confidence)
+	}
+	return nil
+}
+
+// render fills an input into a variant's prompt
+func (v Variant) render(input string) string {
+	if strings.Contains(v.Prompt, "{{input}}") {
+		return strings.ReplaceAll(v.Prompt, "{{input}}", input)
+	}
+	if input == "" {
+		return v.Prompt
+	}
+	return v.Prompt + "\n\n" + input
+}
+
+// RunExperiment runs every variant on every input config.Runs times and
+// compares their scores. Variants take turns within each round, so drift
+// in the model's latency or behavior over the run affects them alike.
+func (po *PromptOptimizer) RunExperiment(ctx context.Context, experiment *Experiment, config ExperimentConfig) (*ExperimentReport, error) {
+	if err := experiment.validate(config); err != nil {
+		return nil, err
+	}
+	inputs := experiment.Inputs
+	if len(inputs) == 0 {
+		inputs = []string{""}
+	}
+	rubric := DefaultRubric
+	if experiment.Rubric != nil {
+		rubric = *experiment.Rubric
+	}
+
+	report := &ExperimentReport{
+		Experiment: experiment.Name,
+		RunAt:      time.Now(),
+		Runs:       config.Runs,
+		Inputs:     len(inputs),
+		Confidence: config.Confidence,
+		Judged:     config.Judge,
+	}
+	for run := 0; run < config.Runs; run++ {
+		for i, input := range inputs {
+			for _, variant := range experiment.Variants {
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+				trial := po.runTrial(ctx, variant, input, rubric, config)
+				trial.Input, trial.Run = i, run
+				report.Trials = append(report.Trials, trial)
+			}
+		}
+	}
+
+	report.summarize(experiment.Variants)
+	return report, nil
+}
+
+// runTrial has a variant answer one input and scores the response
+func (po *PromptOptimizer) runTrial(ctx context.Context, variant Variant, input string, rubric Rubric, config ExperimentConfig) Trial {
+	trial := Trial{Variant: variant.Name}
+
+	ctx = costs.WithAttribution(ctx, costs.Attribution{Template: variant.Name})
+	started := time.Now()
+	execution, err := po.complete(ctx, variant.render(input), config.Temperature)
+	trial.Latency = time.Since(started)
+	if err != nil {
+		trial.Error = err.Error()
+		return trial
+	}
+	trial.Tokens = execution.TokensUsed
+	trial.Heuristic = ScoreResponse(execution.Response, execution.TokensUsed)
+	trial.Score = trial.Heuristic
+
+	if config.Judge {
+		verdict, tokens, err := judgeResponse(ctx, po.complete, execution.GeneratedPrompt, execution.Response, rubric.Criteria, config.JudgeTemperature)
+		trial.JudgeTokens = tokens
+		if err != nil {
+			trial.Error = err.Error()
+			return trial
+		}
+		trial.Score = weightedScore(rubric.Criteria, verdict.Scores)
+	}
+	return trial
+}
+
+// summarize computes each variant's estimates, compares the leader with
+// the rest and recommends a variant
+func (r *ExperimentReport) summarize(variants []Variant) {
+	r.Variants = make([]VariantResult, 0, len(variants))
+	for _, variant := range variants {
+		result := VariantResult{Name: variant.Name}
+		var scores, latencies, tokens []float64
+		for _, trial := range r.Trials {
+			if trial.Variant != variant.Name {
+				continue
+			}
+			result.Trials++
+			if trial.Error != "" {
+				result.Failures++
+				continue
+			}
+			scores = append(scores, trial.Score)
+			latencies = append(latencies, float64(trial.Latency)/float64(time.Millisecond))
+			tokens = append(tokens, float64(trial.Tokens))
+		}
+		result.Score = estimate(scores, r.Confidence)
+		result.LatencyMs = estimate(latencies, r.Confidence)
+		result.Tokens = estimate(tokens, r.Confidence)
+		r.Variants = append(r.Variants, result)
+	}
+	sort.SliceStable(r.Variants, func(i, j int) bool { return r.Variants[i].Score.Mean > r.Variants[j].Score.Mean })
+
+	leader := r.Variants[0]
+	if leader.Score.N < 2 {
+		r.Recommendation = fmt.Sprintf("Not enough successful trials to compare: %s has %d. Fix the failures and run again.", leader.Name, leader.Score.N)
+		return
+	}
+
+	// The leader is compared with every other variant, so each interval is
+	// widened (Bonferroni) to keep the chance of any false win at 1 - Confidence
+	comparisons := len(r.Variants) - 1
+	perComparison := 1 - (1-r.Confidence)/float64(comparisons)
+	beatsAll := true
+	var tied []VariantResult
+	for _, other := range r.Variants[1:] {
+		comparison := Comparison{Leader: leader.Name, Other: other.Name, Difference: leader.Score.Mean - other.Score.Mean}
+		if other.Score.N >= 2 {
+			comparison.Low, comparison.High = welchInterval(leader.Score, other.Score, perComparison)
+			comparison.Significant = comparison.Low > 0
+		}
+		if !comparison.Significant {
+			beatsAll = false
+			if other.Score.N >= 2 {
+				tied = append(tied, other)
+			}
+		}
+		r.Comparisons = append(r.Comparisons, comparison)
+	}
+
+	confidence := fmt.Sprintf("%.0f%%", r.Confidence*100)
+	if beatsAll {
+		r.Winner = leader.Name
+		r.Recommendation = fmt.Sprintf("Adopt %s: it scores higher than every other variant at %s confidence (mean %.2f).", leader.Name, confidence, leader.Score.Mean)
+		return
+	}
+
+	names := make([]string, 0, len(tied))
+	cheapest := leader
+	for _, variant := range tied {
+		names = append(names, variant.Name)
+		if variant.Tokens.Mean < cheapest.Tokens.Mean {
+			cheapest = variant
+		}
+	}
+	r.Recommendation = fmt.Sprintf("No clear winner at %s confidence: %s leads (mean %.2f) but isn't significantly better than %s.",
+		confidence, leader.Name, leader.Score.Mean, strings.Join(names, ", "))
+	if cheapest.Name != leader.Name {
+		r.Recommendation += fmt.Sprintf(" If quality is a tie, %s uses %.0f fewer tokens per response.", cheapest.Name, leader.Tokens.Mean-cheapest.Tokens.Mean)
+	}
+	r.Recommendation += " Add runs or inputs to narrow the intervals."
+}
+
+// Summary renders the report as a table for the terminal
+func (r *ExperimentReport) Summary() string {
+	var sb strings.Builder
+	scorer := "heuristic score"
+	if r.Judged {
+		scorer = "judged score"
+	}
+	fmt.Fprintf(&sb, "%s: %d input(s) × %d run(s), %s, %.0f%% intervals\n\n", r.Experiment, r.Inputs, r.Runs, scorer, r.Confidence*100)
+	fmt.Fprintf(&sb, "  %-20s %-22s %-20s %-14s %s\n", "variant", "score", "latency (ms)", "tokens", "failures")
+	for _, v := range r.Variants {
+		fmt.Fprintf(&sb, "  %-20s %.2f [%.2f, %.2f]     %-20s %-14s %d/%d\n", v.Name,
+			v.Score.Mean, v.Score.Low, v.Score.High,
+			fmt.Sprintf("%.0f ± %.0f", v.LatencyMs.Mean, v.LatencyMs.High-v.LatencyMs.Mean),
+			fmt.Sprintf("%.0f ± %.0f", v.Tokens.Mean, v.Tokens.High-v.Tokens.Mean),
+			v.Failures, v.Trials)
+	}
+	if len(r.Comparisons) > 0 {
+		sb.WriteString("\n")
+	}
+	for _, c := range r.Comparisons {
+		mark := "≈"
+		if c.Significant {
+			mark = ">"
+		}
+		fmt.Fprintf(&sb, "  %s %s %s by %+.2f [%+.2f, %+.2f]\n", c.Leader, mark, c.Other, c.Difference, c.Low, c.High)
+	}
+	fmt.Fprintf(&sb, "\n%s\n", r.Recommendation)
+	return sb.String()
+}
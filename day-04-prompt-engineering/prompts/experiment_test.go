@@ -0,0 +1,122 @@
+package prompts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestTCritical(t *testing.T) {
+	// Values from a t table
+	cases := []struct{ confidence, df, want float64 }{
+		{0.95, 1, 12.706},
+		{0.95, 4, 2.776},
+		{0.95, 30, 2.042},
+		{0.99, 10, 3.169},
+		{0.90, 2, 2.920},
+		{0.95, 1e6, 1.960},
+	}
+	for _, c := range cases {
+		if got := tCritical(c.confidence, c.df); math.Abs(got-c.want) > 0.001 {
+			t.Errorf("tCritical(%v, %v) = %.4f, want %.3f", c.confidence, c.df, got, c.want)
+		}
+	}
+
+	e := estimate([]float64{1, 2, 3, 4, 5}, 0.95)
+	if e.Mean != 3 || math.Abs(e.StdDev-1.5811) > 0.0001 || math.Abs(e.Low-1.0368) > 0.001 {
+		t.Errorf("Unexpected estimate: %+v", e)
+	}
+}
+
+// TestRunExperiment compares three variants against a scripted model and
+// judge: one clearly better, two indistinguishable
+func TestRunExperiment(t *testing.T) {
+	var mu sync.Mutex
+	calls, judged := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		prompt := req.Messages[0].Content
+
+		mu.Lock()
+		calls++
+		if strings.Contains(prompt, "strict evaluator") {
+			judged++
+		}
+		jitter := float64(judged*7%5) * 0.2
+		mu.Unlock()
+
+		reply, tokens := "It adds numbers.", 30
+		switch {
+		case strings.Contains(prompt, "strict evaluator") && strings.Contains(prompt, "Step by step"):
+			reply = fmt.Sprintf(`{"scores": {"relevance": %.1f, "completeness": 9, "clarity": 9}}`, 8+jitter)
+		case strings.Contains(prompt, "strict evaluator"):
+			reply = fmt.Sprintf(`{"scores": {"relevance": %.1f, "completeness": 4, "clarity": 5}}`, 5+jitter)
+		case strings.Contains(prompt, "step by step"):
+			reply, tokens = "Step by step: 1. It adds a and b.", 120
+		case strings.Contains(prompt, "briefly"):
+			tokens = 20
+		}
+		json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Role: "assistant", Content: reply}}},
+			Usage:   openai.Usage{TotalTokens: tokens},
+		})
+	}))
+	defer server.Close()
+
+	config := openai.DefaultConfig("test-key")
+	config.BaseURL = server.URL + "/v1"
+	optimizer := &PromptOptimizer{client: openai.NewClientWithConfig(config)}
+
+	experiment := &Experiment{
+		Name: "explain",
+		Variants: []Variant{
+			{Name: "plain", Prompt: "Explain: {{input}}"},
+			{Name: "detailed", Prompt: "Explain step by step: {{input}}"},
+			{Name: "brief", Prompt: "Explain briefly"},
+		},
+		Inputs: []string{"a + b", "a - b"},
+	}
+	report, err := optimizer.RunExperiment(context.Background(), experiment, DefaultExperimentConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Trials) != 18 || calls != 36 {
+		t.Fatalf("Expected 18 trials with a judge call each, got %d trials and %d calls", len(report.Trials), calls)
+	}
+	if report.Winner != "detailed" || len(report.Comparisons) != 2 || !report.Comparisons[0].Significant {
+		t.Fatalf("Expected detailed to win significantly, got %+v", report)
+	}
+	if v := report.Variants[0]; v.Tokens.Mean != 120 || v.Score.N != 6 || v.Score.Low >= v.Score.Mean {
+		t.Errorf("Unexpected estimates for the winner: %+v", v)
+	}
+
+	// Without the detailed variant the other two tie, and the cheaper one
+	// is suggested even though it trails
+	experiment.Variants = []Variant{experiment.Variants[0], experiment.Variants[2]}
+	report, err = optimizer.RunExperiment(context.Background(), experiment, DefaultExperimentConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Winner != "" || report.Comparisons[0].Significant {
+		t.Errorf("Expected no winner between identical variants, got %+v", report.Comparisons)
+	}
+	if !strings.Contains(report.Recommendation, "brief uses 10 fewer tokens") {
+		t.Errorf("Expected the cheaper variant suggested, got %q", report.Recommendation)
+	}
+	if summary := report.Summary(); !strings.Contains(summary, "No clear winner") {
+		t.Errorf("Unexpected summary:\n%s", summary)
+	}
+
+	if _, err := optimizer.RunExperiment(context.Background(), &Experiment{Variants: experiment.Variants[:1]}, DefaultExperimentConfig()); err == nil {
+		t.Error("Expected an experiment with one variant to be rejected")
+	}
+}
@@ -0,0 +1,102 @@
+package prompts
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/sakibmulla/agentic-ai/endpoint"
+)
+
+// Run `go test -run TestTemplateGolden -update` after an intentional prompt
+// change to rewrite the snapshots, then review the diff before committing.
+var update = flag.Bool("update", false, "rewrite golden prompt snapshots")
+
+// goldenDir holds one <template>.json fixture and one <template>.golden
+// snapshot per built-in template
+const goldenDir = "testdata/golden"
+
+// TestTemplateGolden renders every built-in template with its fixture
+// variables and compares the result with the committed snapshot
+func TestTemplateGolden(t *testing.T) {
+	engine := NewPromptEngine("test-key", endpoint.Endpoint{}, nil)
+
+	names := make([]string, 0, len(engine.ListTemplates()))
+	for name := range engine.ListTemplates() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			variables, err := loadFixture(filepath.Join(goldenDir, name+".json"))
+			if err != nil {
+				t.Fatalf("Missing fixture for template %s (add %s/%s.json): %v", name, goldenDir, name, err)
+			}
+
+			rendered, err := engine.GeneratePrompt(name, variables)
+			if err != nil {
+				t.Fatalf("Failed to render template: %v", err)
+			}
+
+			snapshotPath := filepath.Join(goldenDir, name+".golden")
+			if *update {
+				if err := os.WriteFile(snapshotPath, []byte(rendered), 0644); err != nil {
+					t.Fatalf("Failed to write snapshot: %v", err)
+				}
+				return
+			}
+
+			expected, err := os.ReadFile(snapshotPath)
+			if err != nil {
+				t.Fatalf("Missing snapshot %s (run go test -update): %v", snapshotPath, err)
+			}
+
+			if diff := firstDifference(string(expected), rendered); diff != "" {
+				t.Errorf("Rendered prompt drifted from %s:\n%s\nRun go test -update if the change is intended.", snapshotPath, diff)
+			}
+		})
+	}
+}
+
+// loadFixture reads template variables from a JSON object
+func loadFixture(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var variables map[string]interface{}
+	if err := json.Unmarshal(data, &variables); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture: %w", err)
+	}
+	return variables, nil
+}
+
+// firstDifference describes the first line where got differs from want
+func firstDifference(want, got string) string {
+	if want == got {
+		return ""
+	}
+
+	wantLines := strings.Split(want, "\n")
+	gotLines := strings.Split(got, "\n")
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g || i >= len(wantLines) || i >= len(gotLines) {
+			return fmt.Sprintf("line %d:\n  want: %q\n  got:  %q", i+1, w, g)
+		}
+	}
+	return "snapshots differ"
+}
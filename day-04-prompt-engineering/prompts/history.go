@@ -0,0 +1,338 @@
+package prompts
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// historySchemaVersion is stored as the database's user_version, so a
+// later schema change can tell which databases to migrate
+const historySchemaVersion = 1
+
+// historySchema creates the executions table. The columns queried on are
+// kept alongside the full record, which is stored as JSON.
+const historySchema = `
+CREATE TABLE IF NOT EXISTS executions (
+	id               INTEGER PRIMARY KEY AUTOINCREMENT,
+	template         TEXT    NOT NULL,
+	template_version INTEGER NOT NULL,
+	timestamp        INTEGER NOT NULL,
+	tokens_used      INTEGER NOT NULL,
+	quality          REAL    NOT NULL,
+	record           TEXT    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS executions_template ON executions (template, timestamp);
+CREATE INDEX IF NOT EXISTS executions_timestamp ON executions (timestamp);
+CREATE INDEX IF NOT EXISTS executions_tokens ON executions (tokens_used);
+`
+
+// pruneEvery is the number of executions saved between retention passes
+const pruneEvery = 100
+
+// HistoryRetention bounds how much execution history is kept
+type HistoryRetention struct {
+	// Days drops executions older than this; zero keeps them
+	Days int
+	// MaxRows keeps only the latest executions; zero keeps them all
+	MaxRows int
+}
+
+// historyRetentionFromEnv reads PROMPT_HISTORY_RETENTION_DAYS (default 30)
+// and PROMPT_HISTORY_MAX_ROWS (default 10000); zero keeps everything
+func historyRetentionFromEnv() (HistoryRetention, error) {
+	retention := HistoryRetention{Days: 30, MaxRows: 10000}
+	for _, setting := range []struct {
+		name  string
+		value *int
+	}{
+		{"PROMPT_HISTORY_RETENTION_DAYS", &retention.Days},
+		{"PROMPT_HISTORY_MAX_ROWS", &retention.MaxRows},
+	} {
+		text := os.Getenv(setting.name)
+		if text == "" {
+			continue
+		}
+		n, err := strconv.Atoi(text)
+		if err != nil || n < 0 {
+			return retention, fmt.Errorf("%s must be a number of at least 0, got %q", setting.name, text)
+		}
+		*setting.value = n
+	}
+	return retention, nil
+}
+
+// HistoryStore keeps prompt executions in a SQLite database
+type HistoryStore struct {
+	db        *sql.DB
+	retention HistoryRetention
+	saved     int
+}
+
+// OpenHistoryStore opens (or creates) the execution database at path.
+// Executions past retention are pruned on open and as new ones are saved.
+func OpenHistoryStore(path string, retention HistoryRetention) (*HistoryStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database: %w", err)
+	}
+	// SQLite allows one writer; a single connection avoids "database is locked"
+	db.SetMaxOpenConns(1)
+
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to read history database: %w", err)
+	}
+	if version > historySchemaVersion {
+		db.Close()
+		return nil, fmt.Errorf("history database %s has schema version %d, newer than this release's %d", path, version, historySchemaVersion)
+	}
+	if _, err := db.Exec(historySchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create history tables: %w", err)
+	}
+	if _, err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", historySchemaVersion)); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to set history schema version: %w", err)
+	}
+
+	store := &HistoryStore{db: db, retention: retention}
+	if _, err := store.Prune(context.Background()); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// Close closes the database
+func (s *HistoryStore) Close() error {
+	return s.db.Close()
+}
+
+// Save inserts execution, or updates it if it already has an ID, and sets
+// its ID
+func (s *HistoryStore) Save(ctx context.Context, execution *PromptExecution) error {
+	record, err := json.Marshal(execution)
+	if err != nil {
+		return fmt.Errorf("failed to encode execution: %w", err)
+	}
+
+	if execution.ID != 0 {
+		_, err := s.db.ExecContext(ctx,
+			`UPDATE executions SET template = ?, template_version = ?, timestamp = ?, tokens_used = ?, quality = ?, record = ? WHERE id = ?`,
+			execution.Template, execution.TemplateVersion, execution.Timestamp.UnixNano(), execution.TokensUsed, execution.Quality, record, execution.ID)
+		if err != nil {
+			return fmt.Errorf("failed to update execution %d: %w", execution.ID, err)
+		}
+		return nil
+	}
+
+	result, err := s.db.ExecContext(ctx,
+		`INSERT INTO executions (template, template_version, timestamp, tokens_used, quality, record) VALUES (?, ?, ?, ?, ?, ?)`,
+		execution.Template, execution.TemplateVersion, execution.Timestamp.UnixNano(), execution.TokensUsed, execution.Quality, record)
+	if err != nil {
+		return fmt.Errorf("failed to save execution: %w", err)
+	}
+	if execution.ID, err = result.LastInsertId(); err != nil {
+		return fmt.Errorf("failed to save execution: %w", err)
+	}
+
+	s.saved++
+	if s.saved%pruneEvery == 0 {
+		if _, err := s.Prune(ctx); err != nil {
+			slog.WarnContext(ctx, "failed to prune execution history", "error", err)
+		}
+	}
+	return nil
+}
+
+// Prune deletes the executions past the store's retention and returns how
+// many were deleted
+func (s *HistoryStore) Prune(ctx context.Context) (int64, error) {
+	var deleted int64
+	if s.retention.Days > 0 {
+		cutoff := time.Now().AddDate(0, 0, -s.retention.Days).UnixNano()
+		result, err := s.db.ExecContext(ctx, `DELETE FROM executions WHERE timestamp < ?`, cutoff)
+		if err != nil {
+			return 0, fmt.Errorf("failed to prune execution history: %w", err)
+		}
+		n, _ := result.RowsAffected()
+		deleted += n
+	}
+	if s.retention.MaxRows > 0 {
+		result, err := s.db.ExecContext(ctx,
+			`DELETE FROM executions WHERE id NOT IN (SELECT id FROM executions ORDER BY timestamp DESC, id DESC LIMIT ?)`,
+			s.retention.MaxRows)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to prune execution history: %w", err)
+		}
+		n, _ := result.RowsAffected()
+		deleted += n
+	}
+	return deleted, nil
+}
+
+// ByTemplate returns a template's latest executions, newest first, at most
+// limit of them (zero for all)
+func (s *HistoryStore) ByTemplate(ctx context.Context, template string, limit int) ([]PromptExecution, error) {
+	return s.query(ctx, `SELECT record, id FROM executions WHERE template = ? ORDER BY timestamp DESC, id DESC LIMIT ?`,
+		template, sqlLimit(limit))
+}
+
+// Between returns the executions from from up to but not including to,
+// oldest first
+func (s *HistoryStore) Between(ctx context.Context, from, to time.Time) ([]PromptExecution, error) {
+	return s.query(ctx, `SELECT record, id FROM executions WHERE timestamp >= ? AND timestamp < ? ORDER BY timestamp, id`,
+		from.UnixNano(), to.UnixNano())
+}
+
+// TopExpensive returns the n executions that used the most tokens, most
+// first
+func (s *HistoryStore) TopExpensive(ctx context.Context, n int) ([]PromptExecution, error) {
+	return s.query(ctx, `SELECT record, id FROM executions ORDER BY tokens_used DESC, id DESC LIMIT ?`, sqlLimit(n))
+}
+
+// Count returns the number of executions stored
+func (s *HistoryStore) Count(ctx context.Context) (int, error) {
+	var count int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM executions`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count executions: %w", err)
+	}
+	return count, nil
+}
+
+// query decodes the records a SELECT of record and id returns
+func (s *HistoryStore) query(ctx context.Context, query string, args ...interface{}) ([]PromptExecution, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query execution history: %w", err)
+	}
+	defer rows.Close()
+
+	var executions []PromptExecution
+	for rows.Next() {
+		var record []byte
+		var execution PromptExecution
+		var id int64
+		if err := rows.Scan(&record, &id); err != nil {
+			return nil, fmt.Errorf("failed to read execution: %w", err)
+		}
+		if err := json.Unmarshal(record, &execution); err != nil {
+			return nil, fmt.Errorf("failed to decode execution %d: %w", id, err)
+		}
+		execution.ID = id
+		executions = append(executions, execution)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query execution history: %w", err)
+	}
+	return executions, nil
+}
+
+// sqlLimit turns a limit where zero means none into SQLite's, where -1 does
+func sqlLimit(limit int) int {
+	if limit <= 0 {
+		return -1
+	}
+	return limit
+}
+
+// SetHistoryStore saves every execution to store as well as keeping it in
+// memory. The history queries then read from store, so they cover earlier
+// runs too. A nil store keeps history in memory only.
+func (pe *PromptEngine) SetHistoryStore(store *HistoryStore) {
+	pe.store = store
+}
+
+// record adds a new execution to the history
+func (pe *PromptEngine) record(ctx context.Context, execution *PromptExecution) {
+	pe.saveExecution(ctx, execution)
+	pe.history = append(pe.history, *execution)
+	if limit := pe.historyLimit(); limit > 0 && len(pe.history) > limit {
+		pe.history = append(pe.history[:0], pe.history[len(pe.history)-limit:]...)
+	}
+}
+
+// updateLatest replaces the latest execution in the history with its
+// revised copy, once it has been scored or annotated
+func (pe *PromptEngine) updateLatest(ctx context.Context, execution *PromptExecution) {
+	pe.saveExecution(ctx, execution)
+	pe.history[len(pe.history)-1] = *execution
+}
+
+// saveExecution writes execution to the store, if there is one. Failing to
+// save is logged rather than failing the call that produced it.
+func (pe *PromptEngine) saveExecution(ctx context.Context, execution *PromptExecution) {
+	if pe.store == nil {
+		return
+	}
+	if err := pe.store.Save(ctx, execution); err != nil {
+		slog.WarnContext(ctx, "failed to save prompt execution", "template", execution.Template, "error", err)
+	}
+}
+
+// historyLimit is how many executions are kept in memory: the store's row
+// limit when it has one, so the in-memory copy can't outgrow the database
+func (pe *PromptEngine) historyLimit() int {
+	if pe.store == nil {
+		return 0
+	}
+	return pe.store.retention.MaxRows
+}
+
+// HistoryByTemplate returns a template's latest executions, newest first,
+// at most limit of them (zero for all)
+func (pe *PromptEngine) HistoryByTemplate(ctx context.Context, template string, limit int) ([]PromptExecution, error) {
+	if pe.store != nil {
+		return pe.store.ByTemplate(ctx, template, limit)
+	}
+	var executions []PromptExecution
+	for i := len(pe.history) - 1; i >= 0 && (limit <= 0 || len(executions) < limit); i-- {
+		if pe.history[i].Template == template {
+			executions = append(executions, pe.history[i])
+		}
+	}
+	return executions, nil
+}
+
+// HistoryBetween returns the executions from from up to but not including
+// to, oldest first
+func (pe *PromptEngine) HistoryBetween(ctx context.Context, from, to time.Time) ([]PromptExecution, error) {
+	if pe.store != nil {
+		return pe.store.Between(ctx, from, to)
+	}
+	var executions []PromptExecution
+	for _, execution := range pe.history {
+		if !execution.Timestamp.Before(from) && execution.Timestamp.Before(to) {
+			executions = append(executions, execution)
+		}
+	}
+	return executions, nil
+}
+
+// TopExpensiveExecutions returns the n executions that used the most
+// tokens, most first
+func (pe *PromptEngine) TopExpensiveExecutions(ctx context.Context, n int) ([]PromptExecution, error) {
+	if pe.store != nil {
+		return pe.store.TopExpensive(ctx, n)
+	}
+	executions := append([]PromptExecution(nil), pe.history...)
+	sort.SliceStable(executions, func(i, j int) bool { return executions[i].TokensUsed > executions[j].TokensUsed })
+	if n > 0 && n < len(executions) {
+		executions = executions[:n]
+	}
+	return executions, nil
+}
@@ -0,0 +1,139 @@
+package prompts
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sakibmulla/agentic-ai/endpoint"
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestHistoryStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "history", "executions.db")
+	store, err := OpenHistoryStore(path, HistoryRetention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	for i, e := range []struct {
+		template string
+		age      time.Duration
+		tokens   int
+	}{
+		{"code_generation", 50 * time.Hour, 900},
+		{"data_analysis", 30 * time.Hour, 400},
+		{"code_generation", 20 * time.Hour, 1500},
+		{"code_generation", 2 * time.Hour, 300},
+		{"data_analysis", time.Hour, 700},
+	} {
+		execution := &PromptExecution{
+			Template:   e.template,
+			Timestamp:  now.Add(-e.age),
+			TokensUsed: e.tokens,
+			Metadata:   map[string]interface{}{"run": i},
+		}
+		if err := store.Save(ctx, execution); err != nil || execution.ID == 0 {
+			t.Fatalf("Save failed: %v (id %d)", err, execution.ID)
+		}
+	}
+
+	latest, err := store.ByTemplate(ctx, "code_generation", 2)
+	if err != nil || len(latest) != 2 || latest[0].TokensUsed != 300 || latest[1].TokensUsed != 1500 {
+		t.Errorf("Expected the 2 latest code_generation runs, newest first, got %+v (%v)", latest, err)
+	}
+	day, err := store.Between(ctx, now.Add(-24*time.Hour), now)
+	if err != nil || len(day) != 3 || day[0].TokensUsed != 1500 {
+		t.Errorf("Expected the last day's 3 runs, oldest first, got %+v (%v)", day, err)
+	}
+	top, err := store.TopExpensive(ctx, 2)
+	if err != nil || len(top) != 2 || top[0].TokensUsed != 1500 || top[1].TokensUsed != 900 {
+		t.Errorf("Expected the 2 most expensive runs, got %+v (%v)", top, err)
+	}
+
+	// Updates keep the row; the record round-trips
+	scored := top[0]
+	scored.Quality = 0.9
+	if err := store.Save(ctx, &scored); err != nil {
+		t.Fatal(err)
+	}
+	if top, _ := store.TopExpensive(ctx, 1); top[0].Quality != 0.9 || top[0].ID != scored.ID || top[0].Metadata["run"] != 2.0 {
+		t.Errorf("Expected the scored run updated in place, got %+v", top[0])
+	}
+
+	// Reopening keeps everything, then retention prunes by age and count
+	store.Close()
+	store, err = OpenHistoryStore(path, HistoryRetention{Days: 2, MaxRows: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if count, _ := store.Count(ctx); count != 3 {
+		t.Errorf("Expected 3 runs kept, got %d", count)
+	}
+	if all, _ := store.Between(ctx, now.Add(-72*time.Hour), now); len(all) != 3 || all[0].TokensUsed != 1500 {
+		t.Errorf("Expected the 3 newest runs kept, got %+v", all)
+	}
+}
+
+func TestEngineHistory(t *testing.T) {
+	tokens := []int{500, 1200}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		used := tokens[0]
+		tokens = tokens[1:]
+		json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Role: "assistant", Content: "ok"}}},
+			Usage:   openai.Usage{TotalTokens: used},
+		})
+	}))
+	defer server.Close()
+
+	newEngine := func() *PromptEngine {
+		engine := NewPromptEngine("test-key", endpoint.Endpoint{}, nil)
+		config := openai.DefaultConfig("test-key")
+		config.BaseURL = server.URL + "/v1"
+		engine.client = openai.NewClientWithConfig(config)
+		return engine
+	}
+	ctx := context.Background()
+	variables := map[string]interface{}{"problem": "Slow queries", "context": "Shop", "constraints": "None"}
+
+	// Without a store the queries read the in-memory history
+	engine := newEngine()
+	if _, err := engine.ExecutePrompt(ctx, "chain_of_thought", variables); err != nil {
+		t.Fatal(err)
+	}
+	if executions, _ := engine.HistoryByTemplate(ctx, "chain_of_thought", 0); len(executions) != 1 || executions[0].ID != 0 {
+		t.Errorf("Expected one unsaved execution, got %+v", executions)
+	}
+
+	store, err := OpenHistoryStore(filepath.Join(t.TempDir(), "executions.db"), HistoryRetention{MaxRows: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	engine = newEngine()
+	engine.SetHistoryStore(store)
+	execution, err := engine.ExecutePrompt(ctx, "chain_of_thought", variables)
+	if err != nil || execution.ID == 0 {
+		t.Fatalf("Expected the execution saved, got %+v (%v)", execution, err)
+	}
+	engine.record(ctx, &PromptExecution{Template: "other", Timestamp: time.Now()})
+	if len(engine.GetPromptHistory()) != 1 {
+		t.Errorf("Expected the in-memory history capped at the store's row limit, got %d", len(engine.GetPromptHistory()))
+	}
+
+	top, err := engine.TopExpensiveExecutions(ctx, 5)
+	if err != nil || len(top) != 2 || top[0].TokensUsed != 1200 || top[0].GeneratedPrompt != execution.GeneratedPrompt {
+		t.Errorf("Expected the stored executions, got %+v (%v)", top, err)
+	}
+	if executions, _ := engine.HistoryBetween(ctx, time.Now().Add(-time.Minute), time.Now().Add(time.Minute)); len(executions) != 2 {
+		t.Errorf("Expected 2 executions in the last minute, got %d", len(executions))
+	}
+}
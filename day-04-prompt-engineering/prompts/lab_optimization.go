@@ -1,12 +1,16 @@
-package main
+package prompts
 
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/sakibmulla/agentic-ai/costs"
+	"github.com/sakibmulla/agentic-ai/endpoint"
+	"github.com/sakibmulla/agentic-ai/logging"
 	"github.com/sashabaranov/go-openai"
 )
 
@@ -24,10 +28,11 @@ type TestResult struct {
 	Feedback   string  `json:"feedback"`
 }
 
-// NewPromptOptimizer creates a new prompt optimization tool
-func NewPromptOptimizer(apiKey string) *PromptOptimizer {
+// NewPromptOptimizer creates a new prompt optimization tool that calls api,
+// with calls budgeted and priced by limiter, when given
+func NewPromptOptimizer(apiKey string, api endpoint.Endpoint, limiter *costs.Limiter) *PromptOptimizer {
 	return &PromptOptimizer{
-		client: openai.NewClient(apiKey),
+		client: newOpenAIClient(apiKey, api, limiter),
 	}
 }
 
@@ -52,6 +57,20 @@ func (po *PromptOptimizer) ABTestPrompts(ctx context.Context, promptA, promptB s
 
 // testSinglePrompt executes a single prompt and returns results
 func (po *PromptOptimizer) testSinglePrompt(ctx context.Context, prompt string) (TestResult, error) {
+	execution, err := po.complete(ctx, prompt, 0.7)
+	if err != nil {
+		return TestResult{}, err
+	}
+
+	return TestResult{
+		Prompt:     prompt,
+		Response:   execution.Response,
+		TokensUsed: execution.TokensUsed,
+	}, nil
+}
+
+// complete sends one prompt to the model
+func (po *PromptOptimizer) complete(ctx context.Context, prompt string, temperature float32) (*PromptExecution, error) {
 	req := openai.ChatCompletionRequest{
 		Model: openai.GPT3Dot5Turbo,
 		Messages: []openai.ChatCompletionMessage{
@@ -60,28 +79,36 @@ func (po *PromptOptimizer) testSinglePrompt(ctx context.Context, prompt string)
 				Content: prompt,
 			},
 		},
-		Temperature: 0.7,
+		Temperature: temperature,
 		MaxTokens:   1000,
 	}
 
 	resp, err := po.client.CreateChatCompletion(ctx, req)
 	if err != nil {
-		return TestResult{}, err
+		return nil, err
 	}
 
 	if len(resp.Choices) == 0 {
-		return TestResult{}, fmt.Errorf("no response from model")
+		return nil, fmt.Errorf("no response from model")
 	}
 
-	return TestResult{
-		Prompt:     prompt,
-		Response:   resp.Choices[0].Message.Content,
-		TokensUsed: resp.Usage.TotalTokens,
+	return &PromptExecution{
+		GeneratedPrompt: prompt,
+		Response:        resp.Choices[0].Message.Content,
+		Timestamp:       time.Now(),
+		TokensUsed:      resp.Usage.TotalTokens,
+		Metadata:        map[string]interface{}{"temperature": temperature},
 	}, nil
 }
 
 // scoreResponse provides a simple quality score for responses
 func (po *PromptOptimizer) scoreResponse(response string, tokensUsed int) float64 {
+	return ScoreResponse(response, tokensUsed)
+}
+
+// ScoreResponse rates a response between 0 and 1 using length, token
+// efficiency, structure and completeness heuristics
+func ScoreResponse(response string, tokensUsed int) float64 {
 	score := 0.0
 
 	// Length score (reasonable length is good)
@@ -163,17 +190,31 @@ func findSubstring(str, substr string) bool {
 // RunOptimizationLab demonstrates prompt A/B testing
 func RunOptimizationLab() {
 	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using system environment variables")
+	envErr := godotenv.Load()
+	// LOG_LEVEL and LOG_FORMAT may come from .env
+	logging.Setup(slog.LevelWarn)
+	if envErr != nil {
+		slog.Info("no .env file found, using system environment variables")
+	}
+
+	// OPENAI_BASE_URL and OPENAI_API_TYPE point the client at Azure OpenAI
+	// or an OpenAI-compatible server such as vLLM
+	api, err := endpoint.FromEnv()
+	if err != nil {
+		logging.Fatal("invalid endpoint", "error", err)
 	}
 
 	// Get OpenAI API key
 	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		log.Fatal("OPENAI_API_KEY environment variable is required")
+	if apiKey == "" && api.RequiresKey() {
+		logging.Fatal("OPENAI_API_KEY environment variable is required")
 	}
 
-	optimizer := NewPromptOptimizer(apiKey)
+	limiter, err := costs.FromEnv()
+	if err != nil {
+		logging.Fatal("invalid budget", "error", err)
+	}
+	optimizer := NewPromptOptimizer(apiKey, api, limiter)
 	ctx := context.Background()
 
 	fmt.Println("🔬 Prompt A/B Testing Lab")
@@ -207,7 +248,7 @@ func fibonacci(n int) int {
 
 	resultA, resultB, err := optimizer.ABTestPrompts(ctx, promptA, promptB)
 	if err != nil {
-		log.Fatalf("A/B test failed: %v", err)
+		logging.Fatal("A/B test failed", "error", err)
 	}
 
 	// Display results
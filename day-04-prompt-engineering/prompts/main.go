@@ -0,0 +1,1138 @@
+package prompts
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/sakibmulla/agentic-ai/costs"
+	"github.com/sakibmulla/agentic-ai/endpoint"
+	"github.com/sakibmulla/agentic-ai/logging"
+	"github.com/sakibmulla/agentic-ai/settings"
+	"github.com/sakibmulla/agentic-ai/tokenizer"
+	"github.com/sashabaranov/go-openai"
+)
+
+// PromptTemplate represents a reusable prompt template
+type PromptTemplate struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Template    string                 `json:"template"`
+	Variables   []string               `json:"variables"`
+	Category    string                 `json:"category"`
+	Examples    []PromptExample        `json:"examples"`
+	Metadata    map[string]interface{} `json:"metadata"`
+	// UserProvided templates are parsed and rendered in the sandbox
+	UserProvided bool `json:"user_provided,omitempty"`
+	// Version is the engine's revision number, set by AddTemplate
+	Version int `json:"version,omitempty"`
+	// Elastic names the variables that may be shortened to fit the prompt
+	// budget, the first shortened first
+	Elastic []string `json:"elastic,omitempty"`
+	// Types declares the types of some of Variables; untyped ones take any
+	// value. Variables are checked against them before rendering.
+	Types map[string]VariableSpec `json:"types,omitempty"`
+}
+
+// PromptExample shows how to use a template
+type PromptExample struct {
+	Input       map[string]string `json:"input"`
+	Description string            `json:"description"`
+}
+
+// PromptEngine manages prompt templates and generation
+type PromptEngine struct {
+	templates map[string]PromptTemplate // the active version of each template
+	versions  map[string][]TemplateVersion
+	client    *openai.Client
+	history   []PromptExecution
+	// store persists history when set; see SetHistoryStore
+	store *HistoryStore
+	// budget bounds rendered prompts, counted with tokens
+	budget PromptBudget
+	tokens tokenizer.TokenCounter
+	// selectors pick each template's few-shot examples; see SetExampleSelector
+	selectors map[string]*ExampleSelector
+}
+
+// PromptExecution tracks prompt usage and results
+type PromptExecution struct {
+	// ID is the execution's row in the history store; zero when unsaved
+	ID              int64                  `json:"id,omitempty"`
+	Template        string                 `json:"template"`
+	TemplateVersion int                    `json:"template_version"`
+	Variables       map[string]string      `json:"variables"`
+	GeneratedPrompt string                 `json:"generated_prompt"`
+	Response        string                 `json:"response"`
+	Timestamp       time.Time              `json:"timestamp"`
+	TokensUsed      int                    `json:"tokens_used"`
+	Quality         float64                `json:"quality"`
+	Metadata        map[string]interface{} `json:"metadata"`
+}
+
+// defaultTemperature is the sampling temperature used for template executions
+const defaultTemperature float32 = 0.7
+
+// NewPromptEngine creates a new prompt engineering system that calls api.
+// Its calls are budgeted and priced by limiter, when given, and attributed
+// to the template they run.
+func NewPromptEngine(apiKey string, api endpoint.Endpoint, limiter *costs.Limiter) *PromptEngine {
+	engine := &PromptEngine{
+		templates: make(map[string]PromptTemplate),
+		versions:  make(map[string][]TemplateVersion),
+		client:    newOpenAIClient(apiKey, api, limiter),
+		history:   make([]PromptExecution, 0),
+		selectors: make(map[string]*ExampleSelector),
+		tokens:    tokenizer.ForModel(openai.GPT3Dot5Turbo),
+	}
+
+	// Load built-in templates
+	engine.loadBuiltinTemplates()
+
+	return engine
+}
+
+// newOpenAIClient creates a client for api whose calls go through limiter,
+// when given
+func newOpenAIClient(apiKey string, api endpoint.Endpoint, limiter *costs.Limiter) *openai.Client {
+	config := api.Config(apiKey)
+	// Ollama's requests are sent with its local models, limiter or not
+	httpClient := &http.Client{}
+	if limiter != nil {
+		httpClient = limiter.HTTPClient()
+	}
+	config.HTTPClient = api.HTTPClient(httpClient)
+	return openai.NewClientWithConfig(config)
+}
+
+// loadBuiltinTemplates adds pre-defined prompt templates
+func (pe *PromptEngine) loadBuiltinTemplates() {
+	// Code generation template
+	pe.AddTemplate(PromptTemplate{
+		Name:        "code_generation",
+		Description: "Generate Go code based on requirements",
+		Category:    "programming",
+		Template: `You are an expert Go programmer. Generate clean, efficient, and well-documented Go code.
+
+Task: {{.task}}
+Requirements:
+{{range .requirements}}
+- {{.}}
+{{end}}
+
+Additional Context: {{.context}}
+
+Please provide:
+1. Complete, runnable Go code
+2. Inline comments explaining key logic
+3. Error handling where appropriate
+4. Follow Go best practices and conventions
+
+Code:`,
+		Variables: []string{"task", "requirements", "context"},
+		Types: map[string]VariableSpec{
+			"task":         {Type: VarString},
+			"requirements": {Type: VarList},
+			"context":      {Type: VarString},
+		},
+		Elastic: []string{"context"},
+		Examples: []PromptExample{
+			{
+				Input: map[string]string{
+					"task":         "Create a function to calculate Fibonacci numbers",
+					"requirements": "Efficient algorithm,Handle edge cases,Include tests",
+					"context":      "Part of a math utility package",
+				},
+				Description: "Generate Fibonacci function with tests",
+			},
+		},
+	})
+
+	// Data analysis template
+	pe.AddTemplate(PromptTemplate{
+		Name:        "data_analysis",
+		Description: "Analyze data and provide insights",
+		Category:    "analysis",
+		Template: `You are a senior data analyst with expertise in {{.domain}}. Analyze the following data and provide comprehensive insights.
+
+Data: {{.data}}
+Analysis Type: {{.analysis_type}}
+Business Context: {{.context}}
+
+Please provide:
+1. **Key Findings**: What are the main insights?
+2. **Trends & Patterns**: What trends do you observe?
+3. **Anomalies**: Any unusual patterns or outliers?
+4. **Recommendations**: Actionable next steps
+5. **Confidence Level**: How confident are you in these insights?
+
+Format your response with clear sections and bullet points.`,
+		Variables: []string{"domain", "data", "analysis_type", "context"},
+		Elastic:   []string{"data", "context"},
+		Examples: []PromptExample{
+			{
+				Input: map[string]string{
+					"domain":        "e-commerce",
+					"data":          "Monthly sales data showing 20% increase",
+					"analysis_type": "trend analysis",
+					"context":       "Q4 holiday season performance",
+				},
+				Description: "Analyze e-commerce sales trends",
+			},
+		},
+	})
+
+	// Chain-of-thought problem solving
+	pe.AddTemplate(PromptTemplate{
+		Name:        "chain_of_thought",
+		Description: "Step-by-step problem solving with reasoning",
+		Category:    "reasoning",
+		Template: `Let's solve this problem step by step using clear reasoning.
+
+Problem: {{.problem}}
+Context: {{.context}}
+Constraints: {{.constraints}}
+
+I'll work through this systematically:
+
+Step 1: Understand the Problem
+- What exactly are we trying to solve?
+- What information do we have?
+- What are we looking for?
+
+Step 2: Break Down the Problem
+- What are the key components?
+- Are there sub-problems to solve first?
+- What's the logical sequence?
+
+Step 3: Apply Solution Strategy
+- What approach should we use?
+- Why is this the best method?
+- How do we implement it?
+
+Step 4: Verify the Solution
+- Does this make sense?
+- Have we addressed all requirements?
+- Are there any edge cases?
+
+Let me work through each step:`,
+		Variables: []string{"problem", "context", "constraints"},
+		Elastic:   []string{"context"},
+		Examples: []PromptExample{
+			{
+				Input: map[string]string{
+					"problem":     "Optimize database query performance",
+					"context":     "E-commerce application with slow product searches",
+					"constraints": "Cannot change database schema",
+				},
+				Description: "Database optimization problem",
+			},
+		},
+	})
+
+	// Few-shot learning template
+	pe.AddTemplate(PromptTemplate{
+		Name:        "few_shot_learning",
+		Description: "Learn from examples to perform similar tasks",
+		Category:    "learning",
+		Template: `I'll show you some examples of {{.task_type}}, then ask you to do a similar task.
+
+{{range .examples}}
+Example {{.number}}:
+Input: {{.input}}
+Output: {{.output}}
+Explanation: {{.explanation}}
+
+{{end}}
+
+Now, please apply the same pattern to this new case:
+Input: {{.new_input}}
+Output:`,
+		Variables: []string{"task_type", "examples", "new_input"},
+		Types: map[string]VariableSpec{
+			"examples":  {Type: VarList},
+			"new_input": {Type: VarString},
+		},
+		Examples: []PromptExample{
+			{
+				Input: map[string]string{
+					"task_type": "function naming in Go",
+					"new_input": "Function that converts string to uppercase",
+				},
+				Description: "Learn Go naming conventions from examples",
+			},
+		},
+	})
+
+	// Creative writing template
+	pe.AddTemplate(PromptTemplate{
+		Name:        "creative_writing",
+		Description: "Generate creative content with specific style and requirements",
+		Category:    "creative",
+		Template: `You are a talented {{.writer_type}} with expertise in {{.domain}}.
+
+Writing Task: {{.task}}
+Style: {{.style}}
+Tone: {{.tone}}
+Target Audience: {{.audience}}
+Length: {{.length}}
+
+Key Requirements:
+{{range .requirements}}
+- {{.}}
+{{end}}
+
+Theme/Message: {{.theme}}
+
+Please create engaging content that:
+1. Captures the reader's attention immediately
+2. Maintains the specified tone throughout
+3. Delivers the core message effectively
+4. Is appropriate for the target audience
+5. Follows the style guidelines
+
+Content:`,
+		Variables: []string{"writer_type", "domain", "task", "style", "tone", "audience", "length", "requirements", "theme"},
+		Types: map[string]VariableSpec{
+			"requirements": {Type: VarList},
+		},
+		Examples: []PromptExample{
+			{
+				Input: map[string]string{
+					"writer_type":  "technical blogger",
+					"domain":       "software development",
+					"task":         "Explain microservices architecture",
+					"style":        "conversational yet informative",
+					"tone":         "friendly and approachable",
+					"audience":     "junior developers",
+					"length":       "800-1000 words",
+					"requirements": "Use a real-world analogy,Compare with monoliths,End with next steps",
+					"theme":        "Making complex concepts accessible",
+				},
+				Description: "Technical blog post about microservices",
+			},
+		},
+	})
+}
+
+// AddTemplate adds a template to the engine, or a new version of it if one
+// with the same name exists, and makes it the active version
+func (pe *PromptEngine) AddTemplate(template PromptTemplate) {
+	pe.addVersion(template)
+}
+
+// AddUserTemplate validates a user-supplied template, checks it parses in the
+// sandbox and adds it. Every declared variable is treated as untrusted input,
+// so the returned warnings point at variables placed in instruction positions.
+func (pe *PromptEngine) AddUserTemplate(template PromptTemplate) ([]InjectionWarning, error) {
+	if issues := pe.ValidateTemplate(template); len(issues) > 0 {
+		return nil, fmt.Errorf("invalid template: %s", strings.Join(issues, "; "))
+	}
+	if _, err := ParseSandboxed(template.Name, template.Template, DefaultSandboxConfig()); err != nil {
+		return nil, err
+	}
+
+	template.UserProvided = true
+	pe.AddTemplate(template)
+	return CheckInjection(template.Template, template.Variables), nil
+}
+
+// GetTemplate retrieves the active version of a template by name
+func (pe *PromptEngine) GetTemplate(name string) (PromptTemplate, error) {
+	template, exists := pe.templates[name]
+	if !exists {
+		return PromptTemplate{}, fmt.Errorf("template '%s' not found", name)
+	}
+	return template, nil
+}
+
+// ListTemplates returns all available templates
+func (pe *PromptEngine) ListTemplates() map[string]PromptTemplate {
+	return pe.templates
+}
+
+// GeneratePrompt creates a prompt from a template with variables, selecting
+// its examples if it has an example selector and fitting it to the prompt
+// budget; see RenderPrompt
+func (pe *PromptEngine) GeneratePrompt(templateName string, variables map[string]interface{}) (string, error) {
+	rendered, err := pe.RenderPrompt(context.Background(), templateName, variables)
+	if err != nil {
+		return "", err
+	}
+	return rendered.Prompt, nil
+}
+
+// renderTemplate executes one version of a template with variables
+func renderTemplate(templateObj PromptTemplate, variables map[string]interface{}) (string, error) {
+	templateName := templateObj.Name
+	if templateObj.UserProvided {
+		config := DefaultSandboxConfig()
+		tmpl, err := ParseSandboxed(templateName, templateObj.Template, config)
+		if err != nil {
+			return "", err
+		}
+		return ExecuteSandboxed(tmpl, variables, config)
+	}
+
+	// Create Go template
+	tmpl, err := template.New(templateName).Parse(templateObj.Template)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	// Execute template with variables
+	var result strings.Builder
+	err = tmpl.Execute(&result, variables)
+	if err != nil {
+		return "", fmt.Errorf("failed to execute template: %w", err)
+	}
+
+	return result.String(), nil
+}
+
+// ExecutePrompt generates and executes a prompt using the LLM
+func (pe *PromptEngine) ExecutePrompt(ctx context.Context, templateName string, variables map[string]interface{}) (*PromptExecution, error) {
+	// Generate the prompt from the active version, spending on the template
+	templateObj, err := pe.GetTemplate(templateName)
+	if err != nil {
+		return nil, err
+	}
+	ctx = costs.WithAttribution(ctx, costs.Attribution{Template: templateName})
+	rendered, err := pe.render(ctx, templateObj, variables)
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert variables to string map for storage
+	stringVars := make(map[string]string)
+	for k, v := range variables {
+		stringVars[k] = fmt.Sprintf("%v", v)
+	}
+
+	// Execute with LLM
+	execution, err := pe.complete(ctx, rendered.Prompt, defaultTemperature)
+	if err != nil {
+		return nil, err
+	}
+	execution.Template = templateName
+	execution.TemplateVersion = templateObj.Version
+	execution.Variables = stringVars
+	execution.Metadata["prompt_tokens"] = rendered.Tokens
+	if len(rendered.Shortened) > 0 {
+		execution.Metadata["shortened"] = rendered.Shortened
+	}
+
+	// Store in history
+	pe.record(ctx, execution)
+
+	return execution, nil
+}
+
+// complete sends a prompt to the LLM and returns an unsaved execution record
+func (pe *PromptEngine) complete(ctx context.Context, prompt string, temperature float32) (*PromptExecution, error) {
+	req := openai.ChatCompletionRequest{
+		Model: openai.GPT3Dot5Turbo,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: prompt,
+			},
+		},
+		Temperature: temperature,
+		MaxTokens:   2000,
+	}
+
+	resp, err := pe.client.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("LLM execution failed: %w", err)
+	}
+
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("no response from LLM")
+	}
+
+	return &PromptExecution{
+		GeneratedPrompt: prompt,
+		Response:        resp.Choices[0].Message.Content,
+		Timestamp:       time.Now(),
+		TokensUsed:      resp.Usage.TotalTokens,
+		Quality:         0, // To be set by evaluation
+		Metadata:        map[string]interface{}{"temperature": temperature},
+	}, nil
+}
+
+// AnalyzePromptEffectiveness provides metrics on prompt usage
+func (pe *PromptEngine) AnalyzePromptEffectiveness() map[string]interface{} {
+	if len(pe.history) == 0 {
+		return map[string]interface{}{
+			"total_executions": 0,
+			"message":          "No prompt executions recorded yet",
+		}
+	}
+
+	// Calculate metrics
+	totalExecutions := len(pe.history)
+	totalTokens := 0
+	templateUsage := make(map[string]int)
+	versionUsage := make(map[string]int)
+	avgTokensByTemplate := make(map[string]float64)
+
+	for _, execution := range pe.history {
+		totalTokens += execution.TokensUsed
+		templateUsage[execution.Template]++
+		versionUsage[fmt.Sprintf("%s@v%d", execution.Template, execution.TemplateVersion)]++
+	}
+
+	// Calculate average tokens by template
+	for template, count := range templateUsage {
+		totalForTemplate := 0
+		for _, execution := range pe.history {
+			if execution.Template == template {
+				totalForTemplate += execution.TokensUsed
+			}
+		}
+		avgTokensByTemplate[template] = float64(totalForTemplate) / float64(count)
+	}
+
+	return map[string]interface{}{
+		"total_executions":       totalExecutions,
+		"total_tokens_used":      totalTokens,
+		"average_tokens":         float64(totalTokens) / float64(totalExecutions),
+		"template_usage":         templateUsage,
+		"template_version_usage": versionUsage,
+		"avg_tokens_by_template": avgTokensByTemplate,
+		"most_used_template":     findMostUsedTemplate(templateUsage),
+	}
+}
+
+// findMostUsedTemplate finds the template with highest usage
+func findMostUsedTemplate(usage map[string]int) string {
+	maxUsage := 0
+	mostUsed := ""
+
+	for template, count := range usage {
+		if count > maxUsage {
+			maxUsage = count
+			mostUsed = template
+		}
+	}
+
+	return mostUsed
+}
+
+// GetPromptHistory returns execution history
+func (pe *PromptEngine) GetPromptHistory() []PromptExecution {
+	return pe.history
+}
+
+// ValidateTemplate checks if a template has all required components
+func (pe *PromptEngine) ValidateTemplate(template PromptTemplate) []string {
+	var issues []string
+
+	// Check required fields
+	if template.Name == "" {
+		issues = append(issues, "Template name is required")
+	}
+	if template.Template == "" {
+		issues = append(issues, "Template content is required")
+	}
+
+	// Check for undefined variables in template
+	re := regexp.MustCompile(`\{\{\.(\w+)\}\}`)
+	matches := re.FindAllStringSubmatch(template.Template, -1)
+
+	templateVars := make(map[string]bool)
+	for _, match := range matches {
+		if len(match) > 1 {
+			templateVars[match[1]] = true
+		}
+	}
+
+	// Check if all template variables are declared
+	declaredVars := make(map[string]bool)
+	for _, v := range template.Variables {
+		declaredVars[v] = true
+	}
+
+	for templateVar := range templateVars {
+		if !declaredVars[templateVar] {
+			issues = append(issues, fmt.Sprintf("Variable '%s' used in template but not declared", templateVar))
+		}
+	}
+
+	// Check the variable types
+	for _, v := range template.Variables {
+		if spec, typed := template.Types[v]; typed {
+			issues = append(issues, checkSpec(v, spec)...)
+		}
+	}
+	for _, v := range undeclaredTypes(template) {
+		issues = append(issues, fmt.Sprintf("Variable '%s' has a type but is not declared", v))
+	}
+
+	return issues
+}
+
+// configKeys lists the settings this day reads, for `config show` and
+// `config validate`
+var configKeys = settings.Common.With(
+	settings.Key{Name: "PROMPT_HISTORY_DB", Kind: settings.String},
+	settings.Key{Name: "PROMPT_HISTORY_RETENTION_DAYS", Kind: settings.Int},
+	settings.Key{Name: "PROMPT_HISTORY_MAX_ROWS", Kind: settings.Int},
+)
+
+// Main loads .env and the config file, then runs the prompt engineering
+// demo. args is the command line after the program name, e.g. "config show".
+func Main(args []string) {
+	// Load environment variables, then the config file's CONFIG_PROFILE,
+	// which doesn't replace them
+	envErr := godotenv.Load()
+	run(args, envErr, settings.Load())
+}
+
+// Run runs like Main in an environment the caller has already loaded .env
+// and the config file into, as the agentic command does
+func Run(args []string) {
+	run(args, nil, nil)
+}
+
+// run reports what loading .env and the config file found, then runs the
+// demo
+func run(args []string, envErr, settingsErr error) {
+	// LOG_LEVEL and LOG_FORMAT may come from .env or the config file
+	logging.Setup(slog.LevelWarn)
+	if envErr != nil {
+		slog.Info("no .env file found, using system environment variables")
+	}
+	if settingsErr != nil {
+		logging.Fatal("invalid config file", "error", settingsErr)
+	}
+	// "config show" and "config validate" report the settings instead of running
+	if run, err := configKeys.Start(args, os.Stdout); err != nil {
+		logging.Fatal("invalid configuration", "error", err)
+	} else if !run {
+		return
+	}
+
+	// OPENAI_BASE_URL and OPENAI_API_TYPE point the client at Azure OpenAI
+	// or an OpenAI-compatible server such as vLLM
+	api, err := endpoint.FromEnv()
+	if err != nil {
+		logging.Fatal("invalid endpoint", "error", err)
+	}
+
+	// Get OpenAI API key
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	if apiKey == "" && api.RequiresKey() {
+		logging.Fatal("OPENAI_API_KEY environment variable is required")
+	}
+
+	// Budgets, the cost ledger and prices come from BUDGET, BUDGET_DOWNGRADES,
+	// COST_LEDGER_PATH and PRICING_PATH
+	limiter, err := costs.FromEnv()
+	if err != nil {
+		logging.Fatal("invalid budget", "error", err)
+	}
+
+	// Create prompt engine
+	engine := NewPromptEngine(apiKey, api, limiter)
+	ctx := context.Background()
+
+	// Executions are kept in SQLite when PROMPT_HISTORY_DB is set
+	if path := os.Getenv("PROMPT_HISTORY_DB"); path != "" {
+		retention, err := historyRetentionFromEnv()
+		if err != nil {
+			logging.Fatal("invalid history retention", "error", err)
+		}
+		store, err := OpenHistoryStore(path, retention)
+		if err != nil {
+			logging.Fatal("failed to open execution history", "error", err)
+		}
+		defer store.Close()
+		engine.SetHistoryStore(store)
+	}
+
+	fmt.Println("🎯 Prompt Engineering System")
+	fmt.Println("=============================")
+	fmt.Printf("Available templates: %d\n\n", len(engine.ListTemplates()))
+
+	// Show available templates
+	fmt.Println("📋 Available Templates:")
+	for name, template := range engine.ListTemplates() {
+		fmt.Printf("- %s (%s): %s\n", name, template.Category, template.Description)
+	}
+
+	fmt.Println("\nCommands:")
+	fmt.Println("- 'list' - Show all templates")
+	fmt.Println("- 'demo <template>' - Run a demo of a template")
+	fmt.Println("- 'improve <template>' - Run a demo, retrying with a mutated prompt if quality is low")
+	fmt.Println("- 'stats' - Show prompt usage statistics")
+	fmt.Println("- 'history <template> [n]' - Show a template's latest executions")
+	fmt.Println("- 'history top [n]' - Show the executions that used the most tokens")
+	fmt.Println("- 'history since <duration>' - Show the executions in the last duration, e.g. 24h")
+	fmt.Println("- 'custom' - Create a custom prompt")
+	fmt.Println("- 'load <file.json>' - Load a bundle of user templates (sandboxed)")
+	fmt.Println("- 'save <file.json>' - Save the loaded user templates as a bundle")
+	fmt.Println("- 'examples <template> <file.json> [k]' - Fill a few-shot template with the k stored examples most like each input")
+	fmt.Println("- 'codegen [task]' - Generate Go code, test it and repair failures")
+	fmt.Println("- 'versions <template>' - List a template's versions")
+	fmt.Println("- 'rollback <template> <version>' - Make an earlier version active")
+	fmt.Println("- 'eval <suite.json>' - Judge a template against a test suite and check for regressions")
+	fmt.Println("- 'eval history <template>' - Show a template's pass rate over time")
+	fmt.Println("- 'experiment <file.json>' - Compare prompt variants over repeated runs")
+	fmt.Println("- 'quit' - Exit")
+	fmt.Println()
+
+	scanner := bufio.NewScanner(os.Stdin)
+
+	for {
+		fmt.Print("Prompt> ")
+		if !scanner.Scan() {
+			break
+		}
+
+		input := strings.TrimSpace(scanner.Text())
+		if input == "" {
+			continue
+		}
+
+		if strings.ToLower(input) == "quit" {
+			fmt.Println("👋 Goodbye!")
+			break
+		}
+
+		parts := strings.Fields(input)
+		command := strings.ToLower(parts[0])
+
+		switch command {
+		case "list":
+			fmt.Println("\n📋 Template Details:")
+			for name, template := range engine.ListTemplates() {
+				fmt.Printf("\n%s (%s):\n", name, template.Category)
+				fmt.Printf("  Description: %s\n", template.Description)
+				fmt.Printf("  Variables: %s\n", strings.Join(typedVariables(template), ", "))
+				if len(template.Examples) > 0 {
+					fmt.Printf("  Example: %s\n", template.Examples[0].Description)
+				}
+			}
+
+		case "demo":
+			if len(parts) < 2 {
+				fmt.Println("Usage: demo <template_name>")
+				continue
+			}
+
+			templateName := parts[1]
+			template, err := engine.GetTemplate(templateName)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+
+			if len(template.Examples) == 0 {
+				fmt.Printf("No examples available for template '%s'\n", templateName)
+				continue
+			}
+
+			// Use the first example
+			example := template.Examples[0]
+			variables := CoerceVariables(template, example.Input)
+
+			fmt.Printf("\n🔍 Demo: %s\n", example.Description)
+			fmt.Printf("Template: %s\n\n", templateName)
+
+			execution, err := engine.ExecutePrompt(ctx, templateName, variables)
+			if err != nil {
+				fmt.Printf("Error executing prompt: %v\n", err)
+				continue
+			}
+
+			fmt.Printf("Generated Prompt:\n%s\n\n", execution.GeneratedPrompt)
+			fmt.Printf("Response:\n%s\n\n", execution.Response)
+			fmt.Printf("Tokens used: %d\n\n", execution.TokensUsed)
+
+		case "improve":
+			if len(parts) < 2 {
+				fmt.Println("Usage: improve <template_name>")
+				continue
+			}
+
+			template, err := engine.GetTemplate(parts[1])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			if len(template.Examples) == 0 {
+				fmt.Printf("No examples available for template '%s'\n", parts[1])
+				continue
+			}
+
+			variables := CoerceVariables(template, template.Examples[0].Input)
+
+			result, err := engine.ExecuteWithMutation(ctx, parts[1], variables, DefaultMutationConfig())
+			if err != nil {
+				fmt.Printf("Error executing prompt: %v\n", err)
+				continue
+			}
+
+			fmt.Printf("\n🔁 %s\n\n", result)
+			fmt.Printf("Response:\n%s\n\n", result.Best.Response)
+
+		case "stats":
+			stats := engine.AnalyzePromptEffectiveness()
+			fmt.Println("\n📊 Prompt Usage Statistics:")
+			for key, value := range stats {
+				fmt.Printf("  %s: %v\n", key, value)
+			}
+			fmt.Println()
+
+		case "history":
+			if len(parts) < 2 {
+				fmt.Println("Usage: history <template> [n] | history top [n] | history since <duration>")
+				continue
+			}
+			runHistoryCommand(ctx, engine, parts[1:])
+
+		case "custom":
+			fmt.Println("\n✏️ Custom Prompt Creator")
+			fmt.Print("Enter your prompt: ")
+			if !scanner.Scan() {
+				continue
+			}
+
+			customPrompt := scanner.Text()
+			if customPrompt == "" {
+				fmt.Println("Empty prompt, skipping.")
+				continue
+			}
+
+			// Execute custom prompt directly
+			req := openai.ChatCompletionRequest{
+				Model: openai.GPT3Dot5Turbo,
+				Messages: []openai.ChatCompletionMessage{
+					{
+						Role:    openai.ChatMessageRoleUser,
+						Content: customPrompt,
+					},
+				},
+				Temperature: 0.7,
+				MaxTokens:   1000,
+			}
+
+			resp, err := engine.client.CreateChatCompletion(ctx, req)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+
+			if len(resp.Choices) > 0 {
+				fmt.Printf("\nResponse:\n%s\n\n", resp.Choices[0].Message.Content)
+				fmt.Printf("Tokens used: %d\n\n", resp.Usage.TotalTokens)
+			}
+
+		case "codegen":
+			variables := map[string]interface{}{
+				"task":         strings.TrimSpace(strings.TrimPrefix(input, parts[0])),
+				"requirements": []string{"Handle edge cases", "Use only the standard library"},
+				"context":      "Standalone package; tests will be generated and run automatically",
+			}
+			if len(parts) == 1 {
+				// Use the template's example
+				template, _ := engine.GetTemplate("code_generation")
+				example := template.Examples[0].Input
+				variables["task"] = example["task"]
+				variables["requirements"] = strings.Split(example["requirements"], ",")
+				variables["context"] = example["context"]
+			}
+
+			fmt.Printf("\n🧪 Generating code and tests for: %s\n", variables["task"])
+			result, err := engine.GenerateCodeWithTests(ctx, variables, DefaultCodeGenConfig())
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+
+			fmt.Printf("\nCode:\n%s\nTests:\n%s\n", result.Code, result.Tests)
+			fmt.Println(result.Summary())
+			if !result.Passed {
+				fmt.Printf("Last test output:\n%s\n", result.Runs[len(result.Runs)-1].Output)
+			}
+			fmt.Println()
+
+		case "load":
+			if len(parts) < 2 {
+				fmt.Println("Usage: load <file.json>")
+				continue
+			}
+
+			templates, err := LoadTemplateBundle(parts[1])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+
+			for _, template := range templates {
+				warnings, err := engine.AddUserTemplate(template)
+				if err != nil {
+					fmt.Printf("❌ Rejected '%s': %v\n", template.Name, err)
+					continue
+				}
+				loaded, _ := engine.GetTemplate(template.Name)
+				fmt.Printf("✅ Loaded template '%s' v%d (sandboxed)\n", template.Name, loaded.Version)
+				for _, w := range warnings {
+					fmt.Printf("⚠️  line %d, {{.%s}}: %s\n", w.Line, w.Variable, w.Reason)
+				}
+			}
+
+		case "save":
+			if len(parts) < 2 {
+				fmt.Println("Usage: save <file.json>")
+				continue
+			}
+
+			saved, err := engine.SaveTemplateBundle(parts[1])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			fmt.Printf("💾 Saved %d user template(s) to %s\n", saved, parts[1])
+
+		case "examples":
+			if len(parts) < 3 {
+				fmt.Println("Usage: examples <template_name> <file.json> [k]")
+				continue
+			}
+
+			k := 3
+			if len(parts) > 3 {
+				if k, err = strconv.Atoi(parts[3]); err != nil || k < 1 {
+					fmt.Println("k must be a positive number")
+					continue
+				}
+			}
+			examples, err := LoadExampleSet(parts[2])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			store := engine.NewExampleStore()
+			if err := store.Add(ctx, examples...); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			if err := engine.SetExampleSelector(parts[1], &ExampleSelector{Store: store, K: k}); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			fmt.Printf("🧩 '%s' now picks %d of %d stored examples by similarity\n", parts[1], k, store.Len())
+
+		case "versions":
+			if len(parts) < 2 {
+				fmt.Println("Usage: versions <template_name>")
+				continue
+			}
+
+			versions, err := engine.ListVersions(parts[1])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+
+			fmt.Printf("\n🗂️ Versions of %s:\n", parts[1])
+			for _, v := range versions {
+				marker := " "
+				if v.Active {
+					marker = "*"
+				}
+				fmt.Printf("%s v%d  %s  %s\n", marker, v.Version, v.CreatedAt.Format(time.RFC3339), v.Template.Description)
+			}
+			fmt.Println()
+
+		case "rollback":
+			if len(parts) < 3 {
+				fmt.Println("Usage: rollback <template_name> <version>")
+				continue
+			}
+
+			version, err := strconv.Atoi(strings.TrimPrefix(parts[2], "v"))
+			if err != nil {
+				fmt.Printf("Invalid version %q\n", parts[2])
+				continue
+			}
+			if _, err := engine.Rollback(parts[1], version); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			fmt.Printf("⏪ %s now uses v%d\n", parts[1], version)
+
+		case "eval":
+			if len(parts) < 2 {
+				fmt.Println("Usage: eval <suite.json> | eval history <template>")
+				continue
+			}
+			runEvalCommand(ctx, engine, parts[1:])
+
+		case "experiment":
+			if len(parts) < 2 {
+				fmt.Println("Usage: experiment <file.json>")
+				continue
+			}
+			experiment, err := LoadExperiment(parts[1])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			config := DefaultExperimentConfig()
+			fmt.Printf("\n🔬 Running %d variant(s) × %d input(s) × %d run(s)...\n", len(experiment.Variants), max(len(experiment.Inputs), 1), config.Runs)
+			report, err := NewPromptOptimizer(apiKey, api, limiter).RunExperiment(ctx, experiment, config)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			fmt.Printf("\n📊 %s\n", report.Summary())
+
+		default:
+			fmt.Println("Unknown command. Try 'list', 'demo <template>', 'improve <template>', 'stats', 'custom', 'codegen [task]', 'load <file>', 'save <file>', 'versions <template>', 'rollback <template> <version>', 'eval <suite>', 'experiment <file>', or 'quit'")
+		}
+	}
+
+	// Final statistics
+	if len(engine.GetPromptHistory()) > 0 {
+		fmt.Println("\n📊 Session Summary:")
+		stats := engine.AnalyzePromptEffectiveness()
+		for key, value := range stats {
+			fmt.Printf("  %s: %v\n", key, value)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		slog.Error("failed to read input", "error", err)
+	}
+}
+
+// evalHistoryPath is where the eval command keeps every report
+const evalHistoryPath = "eval_history.json"
+
+// runEvalCommand runs a suite and records it, or shows a template's history
+func runEvalCommand(ctx context.Context, engine *PromptEngine, args []string) {
+	history, err := LoadEvalHistory(evalHistoryPath)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	if args[0] == "history" {
+		if len(args) < 2 {
+			fmt.Println("Usage: eval history <template>")
+			return
+		}
+		reports := history.ForTemplate(args[1])
+		if len(reports) == 0 {
+			fmt.Printf("No eval runs recorded for '%s'\n", args[1])
+			return
+		}
+		fmt.Printf("\n📈 Eval history of %s:\n", args[1])
+		for _, report := range reports {
+			fmt.Printf("  %s  v%d  %3.0f%% passed  score %.2f  %d regression(s)\n",
+				report.RunAt.Format(time.RFC3339), report.TemplateVersion, report.PassRate*100, report.AverageScore, len(report.Regressions))
+		}
+		fmt.Println()
+		return
+	}
+
+	suite, err := LoadEvalSuite(args[0])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	fmt.Printf("\n⚖️ Evaluating %s with %d case(s)...\n", suite.Template, len(suite.Cases))
+	config := DefaultEvalConfig()
+	report, err := engine.RunEvalSuite(ctx, suite, config)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	history.Record(report, config)
+	if err := history.Save(evalHistoryPath); err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+
+	for _, result := range report.Cases {
+		status := "✅"
+		if !result.Passed {
+			status = "❌"
+		}
+		fmt.Printf("%s %s: %.2f", status, result.Case, result.Score)
+		if result.Error != "" {
+			fmt.Printf(" (%s)", result.Error)
+		}
+		fmt.Println()
+		if result.Reasoning != "" {
+			fmt.Printf("   %s\n", result.Reasoning)
+		}
+	}
+	for _, regression := range report.Regressions {
+		fmt.Printf("⚠️  %s regressed: %s\n", regression.Case, regression.Reason)
+	}
+	fmt.Printf("\n%s\n\n", report.Summary())
+}
+
+// runHistoryCommand shows executions from the history: a template's
+// latest, the most expensive, or those since a time
+func runHistoryCommand(ctx context.Context, engine *PromptEngine, args []string) {
+	n := 10
+	if len(args) > 1 && args[0] != "since" {
+		parsed, err := strconv.Atoi(args[1])
+		if err != nil || parsed < 1 {
+			fmt.Println("Error: n must be a positive number")
+			return
+		}
+		n = parsed
+	}
+
+	var executions []PromptExecution
+	var err error
+	switch args[0] {
+	case "top":
+		executions, err = engine.TopExpensiveExecutions(ctx, n)
+	case "since":
+		if len(args) < 2 {
+			fmt.Println("Usage: history since <duration>")
+			return
+		}
+		window, parseErr := time.ParseDuration(args[1])
+		if parseErr != nil {
+			fmt.Printf("Error: invalid duration: %v\n", parseErr)
+			return
+		}
+		now := time.Now()
+		executions, err = engine.HistoryBetween(ctx, now.Add(-window), now.Add(time.Second))
+	default:
+		executions, err = engine.HistoryByTemplate(ctx, args[0], n)
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if len(executions) == 0 {
+		fmt.Println("No executions recorded")
+		return
+	}
+
+	fmt.Println("\n🗂️ Executions:")
+	for _, execution := range executions {
+		fmt.Printf("  %s  %s@v%d  %5d tokens  quality %.2f\n",
+			execution.Timestamp.Format(time.RFC3339), execution.Template, execution.TemplateVersion, execution.TokensUsed, execution.Quality)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,106 @@
+package prompts
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sakibmulla/agentic-ai/costs"
+)
+
+// MutationConfig controls automatic retries of low-quality responses
+type MutationConfig struct {
+	// QualityThreshold is the minimum acceptable ScoreResponse value
+	QualityThreshold float64
+	// TemperatureDrop is subtracted from the temperature for the retry
+	TemperatureDrop float32
+}
+
+// DefaultMutationConfig returns sensible defaults for mutation retries
+func DefaultMutationConfig() MutationConfig {
+	return MutationConfig{
+		QualityThreshold: 0.6,
+		TemperatureDrop:  0.4,
+	}
+}
+
+// MutationResult records both attempts of a mutation retry
+type MutationResult struct {
+	Original *PromptExecution `json:"original"`
+	Mutated  *PromptExecution `json:"mutated,omitempty"`
+	Best     *PromptExecution `json:"best"`
+	Retried  bool             `json:"retried"`
+}
+
+// MutatePrompt rewrites a prompt to ask for more structure and stricter
+// output, which tends to fix rambling or truncated answers
+func MutatePrompt(prompt string) string {
+	return prompt + `
+
+Response requirements:
+- Organize the answer with headings or a numbered list
+- Address every part of the task explicitly
+- Be specific and concrete; avoid filler
+- Finish with a complete sentence`
+}
+
+// ExecuteWithMutation executes a template and, if the response scores below
+// the configured threshold, retries once with a mutated prompt at a lower
+// temperature. Both attempts are stored in the history; the better one is returned.
+func (pe *PromptEngine) ExecuteWithMutation(ctx context.Context, templateName string, variables map[string]interface{}, config MutationConfig) (*MutationResult, error) {
+	original, err := pe.ExecutePrompt(ctx, templateName, variables)
+	if err != nil {
+		return nil, err
+	}
+
+	original.Quality = ScoreResponse(original.Response, original.TokensUsed)
+	original.Metadata["attempt"] = 1
+	pe.updateLatest(ctx, original)
+
+	result := &MutationResult{Original: original, Best: original}
+	if original.Quality >= config.QualityThreshold {
+		return result, nil
+	}
+
+	temperature := defaultTemperature - config.TemperatureDrop
+	if temperature < 0 {
+		temperature = 0
+	}
+
+	ctx = costs.WithAttribution(ctx, costs.Attribution{Template: templateName})
+	mutated, err := pe.complete(ctx, MutatePrompt(original.GeneratedPrompt), temperature)
+	if err != nil {
+		// Keep the original answer if the retry itself fails
+		original.Metadata["mutation_error"] = err.Error()
+		pe.updateLatest(ctx, original)
+		return result, nil
+	}
+
+	mutated.Template = templateName
+	mutated.TemplateVersion = original.TemplateVersion
+	mutated.Variables = original.Variables
+	mutated.Quality = ScoreResponse(mutated.Response, mutated.TokensUsed)
+	mutated.Metadata["attempt"] = 2
+	mutated.Metadata["mutated"] = true
+	mutated.Metadata["original_quality"] = original.Quality
+	pe.record(ctx, mutated)
+
+	result.Mutated = mutated
+	result.Retried = true
+	if mutated.Quality > original.Quality {
+		result.Best = mutated
+	}
+
+	return result, nil
+}
+
+// String summarizes the outcome of a mutation retry
+func (mr *MutationResult) String() string {
+	if !mr.Retried {
+		return fmt.Sprintf("quality %.2f (no retry needed)", mr.Original.Quality)
+	}
+	winner := "original"
+	if mr.Best == mr.Mutated {
+		winner = "mutated"
+	}
+	return fmt.Sprintf("original %.2f, mutated %.2f -> using %s", mr.Original.Quality, mr.Mutated.Quality, winner)
+}
@@ -0,0 +1,332 @@
+package prompts
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// ErrTemplateTimeout is returned when a sandboxed template renders too slowly
+var ErrTemplateTimeout = errors.New("template execution timed out")
+
+// ErrTemplateOutputTooLarge is returned when a sandboxed template renders too much text
+var ErrTemplateOutputTooLarge = errors.New("template output exceeds size limit")
+
+// SandboxConfig limits what a user-provided template may do
+type SandboxConfig struct {
+	Timeout          time.Duration
+	MaxOutputBytes   int
+	MaxTemplateBytes int
+}
+
+// DefaultSandboxConfig returns limits suitable for prompt templates
+func DefaultSandboxConfig() SandboxConfig {
+	return SandboxConfig{
+		Timeout:          2 * time.Second,
+		MaxOutputBytes:   32 * 1024,
+		MaxTemplateBytes: 16 * 1024,
+	}
+}
+
+// sandboxFuncs are the only custom functions user templates may call
+var sandboxFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+	"join": func(sep string, items []interface{}) string {
+		parts := make([]string, len(items))
+		for i, item := range items {
+			parts[i] = fmt.Sprint(item)
+		}
+		return strings.Join(parts, sep)
+	},
+	"truncate": func(n int, s string) string {
+		if runes := []rune(s); len(runes) > n {
+			return string(runes[:n])
+		}
+		return s
+	},
+	"default": func(fallback string, value interface{}) string {
+		if value == nil || fmt.Sprint(value) == "" {
+			return fallback
+		}
+		return fmt.Sprint(value)
+	},
+}
+
+// allowedBuiltins are the text/template builtins that cannot reach outside
+// the data they are given. "call" is excluded because it invokes arbitrary
+// function values.
+var allowedBuiltins = map[string]bool{
+	"and": true, "or": true, "not": true, "len": true, "index": true,
+	"eq": true, "ne": true, "lt": true, "le": true, "gt": true, "ge": true,
+	"print": true, "printf": true, "println": true,
+}
+
+// ParseSandboxed parses a user-provided template, allowing only whitelisted
+// functions and no {{template}}, {{define}} or {{block}} actions
+func ParseSandboxed(name, text string, config SandboxConfig) (*template.Template, error) {
+	if config.MaxTemplateBytes > 0 && len(text) > config.MaxTemplateBytes {
+		return nil, fmt.Errorf("template is %d bytes, limit is %d", len(text), config.MaxTemplateBytes)
+	}
+
+	tmpl, err := template.New(name).Funcs(sandboxFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+	if len(tmpl.Templates()) > 1 {
+		return nil, fmt.Errorf("template definitions ({{define}}/{{block}}) are not allowed")
+	}
+	if tmpl.Tree == nil {
+		return tmpl, nil
+	}
+	if err := checkSandboxNode(tmpl.Tree.Root); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// checkSandboxNode walks the parse tree rejecting disallowed actions
+func checkSandboxNode(node parse.Node) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := checkSandboxNode(child); err != nil {
+				return err
+			}
+		}
+	case *parse.TemplateNode:
+		return fmt.Errorf("{{template %q}} is not allowed", n.Name)
+	case *parse.ActionNode:
+		return checkSandboxNode(n.Pipe)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for _, cmd := range n.Cmds {
+			if err := checkSandboxNode(cmd); err != nil {
+				return err
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if err := checkSandboxNode(arg); err != nil {
+				return err
+			}
+		}
+	case *parse.IdentifierNode:
+		if _, ok := sandboxFuncs[n.Ident]; !ok && !allowedBuiltins[n.Ident] {
+			return fmt.Errorf("function %q is not allowed in templates", n.Ident)
+		}
+	case *parse.IfNode:
+		return checkBranch(&n.BranchNode)
+	case *parse.RangeNode:
+		return checkBranch(&n.BranchNode)
+	case *parse.WithNode:
+		return checkBranch(&n.BranchNode)
+	}
+	return nil
+}
+
+// checkBranch checks the pipeline and both bodies of an if/range/with
+func checkBranch(n *parse.BranchNode) error {
+	if err := checkSandboxNode(n.Pipe); err != nil {
+		return err
+	}
+	if err := checkSandboxNode(n.List); err != nil {
+		return err
+	}
+	if n.ElseList != nil {
+		return checkSandboxNode(n.ElseList)
+	}
+	return nil
+}
+
+// cappedWriter collects output up to a limit and stops accepting writes once aborted
+type cappedWriter struct {
+	builder strings.Builder
+	limit   int
+	aborted atomic.Bool
+}
+
+// Write implements io.Writer
+func (w *cappedWriter) Write(p []byte) (int, error) {
+	if w.aborted.Load() {
+		return 0, ErrTemplateTimeout
+	}
+	if w.limit > 0 && w.builder.Len()+len(p) > w.limit {
+		return 0, ErrTemplateOutputTooLarge
+	}
+	return w.builder.Write(p)
+}
+
+// ExecuteSandboxed renders a template with an output cap and a timeout. A
+// timed-out render is abandoned; it stops at its next write.
+func ExecuteSandboxed(tmpl *template.Template, data interface{}, config SandboxConfig) (string, error) {
+	writer := &cappedWriter{limit: config.MaxOutputBytes}
+	done := make(chan error, 1)
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("template panicked: %v", r)
+			}
+		}()
+		done <- tmpl.Execute(writer, data)
+	}()
+
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = DefaultSandboxConfig().Timeout
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			if errors.Is(err, ErrTemplateOutputTooLarge) {
+				return "", fmt.Errorf("%w (%d bytes)", ErrTemplateOutputTooLarge, config.MaxOutputBytes)
+			}
+			return "", fmt.Errorf("failed to execute template: %w", err)
+		}
+		return writer.builder.String(), nil
+	case <-time.After(timeout):
+		writer.aborted.Store(true)
+		return "", fmt.Errorf("%w after %v", ErrTemplateTimeout, timeout)
+	}
+}
+
+// InjectionWarning flags an untrusted variable placed where the model will
+// read it as instructions rather than data
+type InjectionWarning struct {
+	Variable string `json:"variable"`
+	Line     int    `json:"line"`
+	Reason   string `json:"reason"`
+}
+
+var (
+	// actionPattern matches template actions
+	actionPattern = regexp.MustCompile(`\{\{-?\s*(.*?)\s*-?\}\}`)
+	// labelledSlot matches "Label: {{.var}}" lines, a conventional data slot
+	labelledSlot = regexp.MustCompile(`^\s*[-*]?\s*([A-Za-z][\w ()/&-]{0,40}):\s*\{\{[^}]*\}\}\s*$`)
+	// instructionLabel marks labels whose value is itself read as instructions
+	instructionLabel = regexp.MustCompile(`(?i)\b(instruction|instructions|system|rules?|persona|role|directive|policy)\b`)
+	// tagPair matches <tag>...</tag> on one line
+	tagPair = regexp.MustCompile(`<([A-Za-z][\w-]*)>.*</([A-Za-z][\w-]*)>`)
+)
+
+// CheckInjection reports untrusted variables interpolated into instruction
+// positions: running prose, the start of the prompt, or slots labelled as
+// instructions. Variables inside code fences, quotes, <tag></tag> pairs or
+// ordinary "Label: {{.var}}" lines are treated as delimited data.
+func CheckInjection(text string, untrusted []string) []InjectionWarning {
+	isUntrusted := make(map[string]bool, len(untrusted))
+	for _, name := range untrusted {
+		isUntrusted[name] = true
+	}
+
+	var warnings []InjectionWarning
+	var rangeStack []string // the variable "." refers to inside range/with blocks
+	inFence := false
+	seenContent := false
+
+	for i, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+			continue
+		}
+
+		for _, loc := range actionPattern.FindAllStringSubmatchIndex(line, -1) {
+			action := line[loc[2]:loc[3]]
+			fields := strings.Fields(action)
+			if len(fields) == 0 {
+				continue
+			}
+
+			switch fields[0] {
+			case "range", "with", "if":
+				dot := ""
+				if fields[0] != "if" {
+					dot = variableName(fields[len(fields)-1], rangeStack)
+				}
+				rangeStack = append(rangeStack, dot)
+				continue
+			case "end":
+				if len(rangeStack) > 0 {
+					rangeStack = rangeStack[:len(rangeStack)-1]
+				}
+				continue
+			case "else":
+				continue
+			}
+
+			name := ""
+			for _, field := range fields {
+				if v := variableName(field, rangeStack); v != "" {
+					name = v
+					break
+				}
+			}
+			if name == "" || !isUntrusted[name] {
+				continue
+			}
+
+			if reason := injectionReason(line, loc[0], loc[1], inFence, !seenContent); reason != "" {
+				warnings = append(warnings, InjectionWarning{Variable: name, Line: i + 1, Reason: reason})
+			}
+		}
+
+		if strings.TrimSpace(line) != "" {
+			seenContent = true
+		}
+	}
+	return warnings
+}
+
+// variableName resolves a pipeline field such as ".task" or "." to a variable name
+func variableName(field string, rangeStack []string) string {
+	if field == "." {
+		if len(rangeStack) > 0 {
+			return rangeStack[len(rangeStack)-1]
+		}
+		return ""
+	}
+	if strings.HasPrefix(field, ".") {
+		return strings.SplitN(field[1:], ".", 2)[0]
+	}
+	return ""
+}
+
+// injectionReason explains why the action at line[start:end] is in an
+// instruction position, or returns "" if it is delimited data
+func injectionReason(line string, start, end int, inFence, firstLine bool) string {
+	if inFence {
+		return ""
+	}
+	if strings.Count(line[:start], `"`)%2 == 1 && strings.Contains(line[end:], `"`) {
+		return ""
+	}
+	for _, match := range tagPair.FindAllStringSubmatchIndex(line, -1) {
+		if match[0] <= start && end <= match[1] && line[match[2]:match[3]] == line[match[4]:match[5]] {
+			return ""
+		}
+	}
+
+	if firstLine {
+		return "at the start of the prompt, where it can set the model's instructions"
+	}
+	if m := labelledSlot.FindStringSubmatch(line); m != nil {
+		if instructionLabel.MatchString(m[1]) {
+			return fmt.Sprintf("fills the %q slot, which the model reads as instructions", m[1])
+		}
+		return ""
+	}
+	return "concatenated into instruction text; wrap it in quotes, a code fence or <tags>"
+}
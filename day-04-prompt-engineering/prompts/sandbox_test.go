@@ -0,0 +1,64 @@
+package prompts
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSandbox covers the function whitelist, output cap, timeout and injection check
+func TestSandbox(t *testing.T) {
+	config := DefaultSandboxConfig()
+
+	for _, text := range []string{
+		`{{call .fn}}`,
+		`{{define "x"}}hi{{end}}{{template "x"}}`,
+		`{{block "x" .}}hi{{end}}`,
+	} {
+		if _, err := ParseSandboxed("bad", text, config); err == nil {
+			t.Errorf("Expected %q to be rejected", text)
+		}
+	}
+
+	tmpl, err := ParseSandboxed("ok", `{{upper .name}}: {{truncate 3 .topic}}`, config)
+	if err != nil {
+		t.Fatalf("Failed to parse allowed template: %v", err)
+	}
+	out, err := ExecuteSandboxed(tmpl, map[string]interface{}{"name": "go", "topic": "templates"}, config)
+	if err != nil || out != "GO: tem" {
+		t.Errorf("Expected 'GO: tem', got %q (%v)", out, err)
+	}
+
+	big, _ := ParseSandboxed("big", `{{range .items}}{{.}}{{end}}`, config)
+	items := make([]string, 1000)
+	for i := range items {
+		items[i] = strings.Repeat("x", 100)
+	}
+	if _, err := ExecuteSandboxed(big, map[string]interface{}{"items": items}, config); !errors.Is(err, ErrTemplateOutputTooLarge) {
+		t.Errorf("Expected output cap error, got %v", err)
+	}
+
+	slow := SandboxConfig{Timeout: 20 * time.Millisecond, MaxOutputBytes: 1 << 30}
+	loop, _ := ParseSandboxed("loop", `{{range $i := .n}}{{range $.n}}x{{end}}{{end}}`, slow)
+	if _, err := ExecuteSandboxed(loop, map[string]interface{}{"n": make([]int, 100000)}, slow); !errors.Is(err, ErrTemplateTimeout) {
+		t.Errorf("Expected timeout error, got %v", err)
+	}
+
+	text := strings.Join([]string{
+		"You are a helpful assistant.",
+		"Summarize the following and then {{.task}}.",
+		"Rules: {{.rules}}",
+		"Document: {{.document}}",
+		`Question: "{{.question}}"`,
+		"<notes>{{.notes}}</notes>",
+	}, "\n")
+	warnings := CheckInjection(text, []string{"task", "rules", "document", "question", "notes"})
+	flagged := make(map[string]bool)
+	for _, w := range warnings {
+		flagged[w.Variable] = true
+	}
+	if !flagged["task"] || !flagged["rules"] || len(flagged) != 2 {
+		t.Errorf("Expected task and rules to be flagged, got %+v", warnings)
+	}
+}
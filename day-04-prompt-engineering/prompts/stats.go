@@ -0,0 +1,145 @@
+package prompts
+
+import "math"
+
+// Estimate summarizes a sample: its mean and a confidence interval for it
+type Estimate struct {
+	N      int     `json:"n"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"std_dev"`
+	Low    float64 `json:"low"`
+	High   float64 `json:"high"`
+}
+
+// estimate computes the mean of values with a two-sided confidence
+// interval from Student's t distribution. Fewer than two values give an
+// interval of just the mean.
+func estimate(values []float64, confidence float64) Estimate {
+	e := Estimate{N: len(values)}
+	if e.N == 0 {
+		return e
+	}
+	e.Mean, e.StdDev = meanStdDev(values)
+	e.Low, e.High = e.Mean, e.Mean
+	if e.N > 1 {
+		margin := tCritical(confidence, float64(e.N-1)) * e.StdDev / math.Sqrt(float64(e.N))
+		e.Low, e.High = e.Mean-margin, e.Mean+margin
+	}
+	return e
+}
+
+// meanStdDev returns the mean and sample standard deviation of values
+func meanStdDev(values []float64) (float64, float64) {
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if len(values) < 2 {
+		return mean, 0
+	}
+	squares := 0.0
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(squares / float64(len(values)-1))
+}
+
+// welchInterval returns a confidence interval for mean(a) - mean(b) that
+// doesn't assume equal variances. Both samples need at least two values.
+func welchInterval(a, b Estimate, confidence float64) (float64, float64) {
+	diff := a.Mean - b.Mean
+	va := a.StdDev * a.StdDev / float64(a.N)
+	vb := b.StdDev * b.StdDev / float64(b.N)
+	se := math.Sqrt(va + vb)
+	if se == 0 {
+		return diff, diff
+	}
+	// Welch–Satterthwaite degrees of freedom
+	df := (va + vb) * (va + vb) / (va*va/float64(a.N-1) + vb*vb/float64(b.N-1))
+	margin := tCritical(confidence, df) * se
+	return diff - margin, diff + margin
+}
+
+// tCritical returns t such that a two-sided interval of ±t covers
+// confidence of Student's t distribution with df degrees of freedom
+func tCritical(confidence, df float64) float64 {
+	target := 1 - (1-confidence)/2
+	low, high := 0.0, 1.0
+	for studentTCDF(high, df) < target {
+		high *= 2
+	}
+	for i := 0; i < 100; i++ {
+		mid := (low + high) / 2
+		if studentTCDF(mid, df) < target {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+	return (low + high) / 2
+}
+
+// studentTCDF is the cumulative distribution function of Student's t
+func studentTCDF(t, df float64) float64 {
+	tail := 0.5 * regularizedBeta(df/(df+t*t), df/2, 0.5)
+	if t > 0 {
+		return 1 - tail
+	}
+	return tail
+}
+
+// regularizedBeta is the regularized incomplete beta function I_x(a, b),
+// evaluated with its continued fraction
+func regularizedBeta(x, a, b float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	lga, _ := math.Lgamma(a)
+	lgb, _ := math.Lgamma(b)
+	lgab, _ := math.Lgamma(a + b)
+	front := math.Exp(lgab - lga - lgb + a*math.Log(x) + b*math.Log(1-x))
+
+	// The continued fraction converges quickly only on this side
+	if x > (a+1)/(a+b+2) {
+		return 1 - front*betaFraction(1-x, b, a)/b
+	}
+	return front * betaFraction(x, a, b) / a
+}
+
+// betaFraction evaluates the incomplete beta continued fraction with
+// Lentz's method
+func betaFraction(x, a, b float64) float64 {
+	const tiny = 1e-300
+	c, d := 1.0, 1-(a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1; m <= 200; m++ {
+		fm := float64(m)
+		for _, numerator := range []float64{
+			fm * (b - fm) * x / ((a + 2*fm - 1) * (a + 2*fm)),
+			-(a + fm) * (a + b + fm) * x / ((a + 2*fm) * (a + 2*fm + 1)),
+		} {
+			d = 1 + numerator*d
+			if math.Abs(d) < tiny {
+				d = tiny
+			}
+			c = 1 + numerator/c
+			if math.Abs(c) < tiny {
+				c = tiny
+			}
+			d = 1 / d
+			h *= d * c
+		}
+		if math.Abs(d*c-1) < 1e-12 {
+			break
+		}
+	}
+	return h
+}
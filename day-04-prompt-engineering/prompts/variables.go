@@ -0,0 +1,205 @@
+package prompts
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// VariableType is the kind of value a template variable takes
+type VariableType string
+
+// Template variable types
+const (
+	VarString VariableType = "string"
+	VarList   VariableType = "list"
+	VarNumber VariableType = "number"
+	VarBool   VariableType = "bool"
+	VarEnum   VariableType = "enum"
+)
+
+// VariableSpec declares the type of a template variable
+type VariableSpec struct {
+	Type VariableType `json:"type"`
+	// Allowed lists an enum's values
+	Allowed []string `json:"allowed,omitempty"`
+	// Optional variables may be left out
+	Optional bool `json:"optional,omitempty"`
+}
+
+// checkSpec returns what is wrong with the declaration of variable name
+func checkSpec(name string, spec VariableSpec) []string {
+	switch spec.Type {
+	case VarString, VarList, VarNumber, VarBool:
+		if len(spec.Allowed) > 0 {
+			return []string{fmt.Sprintf("Variable '%s' lists allowed values but is a %s, not an enum", name, spec.Type)}
+		}
+	case VarEnum:
+		if len(spec.Allowed) == 0 {
+			return []string{fmt.Sprintf("Enum variable '%s' has no allowed values", name)}
+		}
+	default:
+		return []string{fmt.Sprintf("Variable '%s' has unknown type %q: use string, list, number, bool or enum", name, spec.Type)}
+	}
+	return nil
+}
+
+// ValidateVariables checks variables against the template's declarations
+// before it is rendered. Every declared variable must be supplied unless its
+// type marks it optional; typed ones must hold a value of that type. Each
+// issue names the variable and what to pass instead.
+func ValidateVariables(templateObj PromptTemplate, variables map[string]interface{}) []string {
+	var issues []string
+	for _, name := range templateObj.Variables {
+		spec, typed := templateObj.Types[name]
+		value, ok := variables[name]
+		if !ok || value == nil {
+			if !spec.Optional {
+				issues = append(issues, fmt.Sprintf("missing %s%s", name, describeSpec(spec, typed)))
+			}
+			continue
+		}
+		if typed {
+			if issue := checkValue(name, spec, value); issue != "" {
+				issues = append(issues, issue)
+			}
+		}
+	}
+	return issues
+}
+
+// describeSpec returns " (a list)" and the like, to say what a missing
+// variable should hold
+func describeSpec(spec VariableSpec, typed bool) string {
+	switch {
+	case !typed:
+		return ""
+	case spec.Type == VarEnum:
+		return fmt.Sprintf(" (one of %s)", strings.Join(spec.Allowed, ", "))
+	case spec.Type == VarList:
+		return " (a list)"
+	default:
+		return fmt.Sprintf(" (a %s)", spec.Type)
+	}
+}
+
+// checkValue returns what is wrong with value for a variable of spec's
+// type, or "" if nothing is
+func checkValue(name string, spec VariableSpec, value interface{}) string {
+	kind := reflect.ValueOf(value).Kind()
+	got := fmt.Sprintf("%T %q", value, truncateValue(value))
+
+	switch spec.Type {
+	case VarString:
+		if kind != reflect.String {
+			return fmt.Sprintf("%s must be a string, got %s", name, got)
+		}
+	case VarList:
+		if kind == reflect.String {
+			return fmt.Sprintf("%s must be a list, got %s: pass a []string, not comma-separated text", name, got)
+		}
+		if kind != reflect.Slice && kind != reflect.Array {
+			return fmt.Sprintf("%s must be a list, got %s", name, got)
+		}
+	case VarNumber:
+		if _, isNumber := value.(json.Number); !isNumber && (kind < reflect.Int || kind > reflect.Float64) {
+			return fmt.Sprintf("%s must be a number, got %s", name, got)
+		}
+	case VarBool:
+		if kind != reflect.Bool {
+			return fmt.Sprintf("%s must be a bool, got %s", name, got)
+		}
+	case VarEnum:
+		text, ok := value.(string)
+		if !ok {
+			return fmt.Sprintf("%s must be one of %s, got %s", name, strings.Join(spec.Allowed, ", "), got)
+		}
+		for _, allowed := range spec.Allowed {
+			if text == allowed {
+				return ""
+			}
+		}
+		return fmt.Sprintf("%s must be one of %s, got %q", name, strings.Join(spec.Allowed, ", "), text)
+	}
+	return ""
+}
+
+// truncateValue prints value briefly for an error message
+func truncateValue(value interface{}) string {
+	text := fmt.Sprint(value)
+	if runes := []rune(text); len(runes) > 40 {
+		return string(runes[:40]) + "..."
+	}
+	return text
+}
+
+// CoerceVariables converts text inputs, such as a template's examples or
+// what a user types, to the types the template declares: lists are split
+// on commas, numbers and bools parsed. Values that don't convert are left
+// as text for ValidateVariables to report.
+func CoerceVariables(templateObj PromptTemplate, inputs map[string]string) map[string]interface{} {
+	variables := make(map[string]interface{}, len(inputs))
+	for name, text := range inputs {
+		variables[name] = text
+		switch templateObj.Types[name].Type {
+		case VarList:
+			var items []string
+			for _, item := range strings.Split(text, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			variables[name] = items
+		case VarNumber:
+			if number, err := strconv.ParseFloat(strings.TrimSpace(text), 64); err == nil {
+				variables[name] = number
+			}
+		case VarBool:
+			if b, err := strconv.ParseBool(strings.TrimSpace(text)); err == nil {
+				variables[name] = b
+			}
+		}
+	}
+	return variables
+}
+
+// typedVariables lists the template's variables with their types, for
+// display: "requirements (list)", "tone (enum: formal|casual)"
+func typedVariables(templateObj PromptTemplate) []string {
+	described := make([]string, 0, len(templateObj.Variables))
+	for _, name := range templateObj.Variables {
+		spec, typed := templateObj.Types[name]
+		switch {
+		case !typed:
+			described = append(described, name)
+		case spec.Type == VarEnum:
+			described = append(described, fmt.Sprintf("%s (enum: %s)", name, strings.Join(spec.Allowed, "|")))
+		default:
+			described = append(described, fmt.Sprintf("%s (%s)", name, spec.Type))
+		}
+		if spec.Optional {
+			described[len(described)-1] += "?"
+		}
+	}
+	return described
+}
+
+// undeclaredTypes returns the names that have a type but aren't declared
+// variables, sorted
+func undeclaredTypes(templateObj PromptTemplate) []string {
+	declared := make(map[string]bool, len(templateObj.Variables))
+	for _, name := range templateObj.Variables {
+		declared[name] = true
+	}
+	var names []string
+	for name := range templateObj.Types {
+		if !declared[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
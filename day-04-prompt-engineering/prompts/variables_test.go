@@ -0,0 +1,92 @@
+package prompts
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/sakibmulla/agentic-ai/endpoint"
+)
+
+func TestVariableValidation(t *testing.T) {
+	engine := NewPromptEngine("test-key", endpoint.Endpoint{}, nil)
+
+	// The comma-separated requirements that {{range}} can't iterate over
+	_, err := engine.GeneratePrompt("code_generation", map[string]interface{}{
+		"task":         "Fibonacci",
+		"requirements": "Efficient algorithm,Handle edge cases",
+	})
+	if err == nil {
+		t.Fatal("Expected invalid variables rejected")
+	}
+	for _, want := range []string{"missing context (a string)", "requirements must be a list, got string", "comma-separated"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in the error, got: %v", want, err)
+		}
+	}
+
+	// Example inputs are text; coercing splits the list
+	codeGen, _ := engine.GetTemplate("code_generation")
+	variables := CoerceVariables(codeGen, codeGen.Examples[0].Input)
+	prompt, err := engine.GeneratePrompt("code_generation", variables)
+	if err != nil || !strings.Contains(prompt, "- Handle edge cases") {
+		t.Errorf("Expected the coerced example to render, got %v:\n%s", err, prompt)
+	}
+
+	report := PromptTemplate{
+		Name:      "report",
+		Template:  "{{.tone}} {{.pages}} {{.draft}}",
+		Variables: []string{"tone", "pages", "draft", "notes"},
+		Types: map[string]VariableSpec{
+			"tone":  {Type: VarEnum, Allowed: []string{"formal", "casual"}},
+			"pages": {Type: VarNumber},
+			"draft": {Type: VarBool},
+			"notes": {Type: VarString, Optional: true},
+		},
+	}
+	if issues := engine.ValidateTemplate(report); len(issues) != 0 {
+		t.Fatalf("Expected a valid template, got %v", issues)
+	}
+	engine.AddTemplate(report)
+
+	for _, tc := range []struct {
+		variables map[string]interface{}
+		issues    []string
+	}{
+		{map[string]interface{}{"tone": "formal", "pages": 3, "draft": true}, nil},
+		{map[string]interface{}{"tone": "casual", "pages": json.Number("2.5"), "draft": false, "notes": "short"}, nil},
+		{map[string]interface{}{"tone": "angry", "pages": "3", "draft": "yes"}, []string{
+			`tone must be one of formal, casual, got "angry"`,
+			"pages must be a number, got string",
+			"draft must be a bool, got string",
+		}},
+		{map[string]interface{}{"notes": 7}, []string{
+			"missing tone (one of formal, casual)", "missing pages (a number)", "missing draft (a bool)", "notes must be a string, got int",
+		}},
+	} {
+		issues := ValidateVariables(report, tc.variables)
+		if len(issues) != len(tc.issues) {
+			t.Errorf("%v: expected %d issues, got %v", tc.variables, len(tc.issues), issues)
+			continue
+		}
+		for i, want := range tc.issues {
+			if !strings.HasPrefix(issues[i], want) {
+				t.Errorf("%v: expected issue %q, got %q", tc.variables, want, issues[i])
+			}
+		}
+	}
+
+	coerced := CoerceVariables(report, map[string]string{"tone": "formal", "pages": "4", "draft": "true"})
+	if coerced["pages"] != 4.0 || coerced["draft"] != true || len(ValidateVariables(report, coerced)) != 0 {
+		t.Errorf("Expected numbers and bools parsed, got %v", coerced)
+	}
+
+	report.Types = map[string]VariableSpec{
+		"tone":   {Type: VarEnum},
+		"pages":  {Type: "integer"},
+		"author": {Type: VarString},
+	}
+	if issues := engine.ValidateTemplate(report); len(issues) != 3 {
+		t.Errorf("Expected the empty enum, unknown type and undeclared variable reported, got %v", issues)
+	}
+}
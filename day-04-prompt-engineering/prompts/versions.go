@@ -0,0 +1,82 @@
+package prompts
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// TemplateVersion is one revision of a template. Versions are numbered from
+// 1 and never reused, so a version recorded in an execution always refers to
+// the same prompt.
+type TemplateVersion struct {
+	Version   int            `json:"version"`
+	CreatedAt time.Time      `json:"created_at"`
+	Active    bool           `json:"active"`
+	Template  PromptTemplate `json:"template"`
+}
+
+// addVersion records template as the next revision of its name and makes it
+// active. Re-adding the active template unchanged keeps its version.
+func (pe *PromptEngine) addVersion(template PromptTemplate) PromptTemplate {
+	if active, ok := pe.templates[template.Name]; ok && sameTemplate(active, template) {
+		return active
+	}
+
+	revisions := pe.versions[template.Name]
+	template.Version = len(revisions) + 1
+	pe.versions[template.Name] = append(revisions, TemplateVersion{
+		Version:   template.Version,
+		CreatedAt: time.Now(),
+		Template:  template,
+	})
+	pe.templates[template.Name] = template
+	return template
+}
+
+// sameTemplate reports whether two templates differ only in version
+func sameTemplate(a, b PromptTemplate) bool {
+	a.Version, b.Version = 0, 0
+	return reflect.DeepEqual(a, b)
+}
+
+// GetTemplateVersion retrieves one version of a template
+func (pe *PromptEngine) GetTemplateVersion(name string, version int) (PromptTemplate, error) {
+	revisions, exists := pe.versions[name]
+	if !exists {
+		return PromptTemplate{}, fmt.Errorf("template '%s' not found", name)
+	}
+	if version < 1 || version > len(revisions) {
+		return PromptTemplate{}, fmt.Errorf("template '%s' has no version %d (latest is %d)", name, version, len(revisions))
+	}
+	return revisions[version-1].Template, nil
+}
+
+// ListVersions returns every version of a template, oldest first, marking
+// the one in use
+func (pe *PromptEngine) ListVersions(name string) ([]TemplateVersion, error) {
+	revisions, exists := pe.versions[name]
+	if !exists {
+		return nil, fmt.Errorf("template '%s' not found", name)
+	}
+
+	active := pe.templates[name].Version
+	versions := make([]TemplateVersion, len(revisions))
+	for i, revision := range revisions {
+		revision.Active = revision.Version == active
+		versions[i] = revision
+	}
+	return versions, nil
+}
+
+// Rollback makes an earlier version of a template the one executions use.
+// Later versions are kept, so rolling forward again is another Rollback; a
+// template added after a rollback becomes a new version.
+func (pe *PromptEngine) Rollback(name string, version int) (PromptTemplate, error) {
+	template, err := pe.GetTemplateVersion(name, version)
+	if err != nil {
+		return PromptTemplate{}, fmt.Errorf("failed to roll back: %w", err)
+	}
+	pe.templates[name] = template
+	return template, nil
+}
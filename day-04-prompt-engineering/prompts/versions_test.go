@@ -0,0 +1,55 @@
+package prompts
+
+import (
+	"testing"
+
+	"github.com/sakibmulla/agentic-ai/endpoint"
+)
+
+// TestTemplateVersions adds revisions of a template, rolls back and checks
+// that rendering follows the active version
+func TestTemplateVersions(t *testing.T) {
+	engine := NewPromptEngine("test-key", endpoint.Endpoint{}, nil)
+	greeting := PromptTemplate{Name: "greeting", Template: "Hello {{.name}}", Variables: []string{"name"}}
+	variables := map[string]interface{}{"name": "Ada"}
+
+	engine.AddTemplate(greeting)
+	engine.AddTemplate(greeting)
+	if versions, _ := engine.ListVersions("greeting"); len(versions) != 1 {
+		t.Fatalf("Expected re-adding an unchanged template to keep one version, got %d", len(versions))
+	}
+
+	greeting.Template = "Hi {{.name}}!"
+	engine.AddTemplate(greeting)
+	if prompt, _ := engine.GeneratePrompt("greeting", variables); prompt != "Hi Ada!" {
+		t.Errorf("Expected the new version to render, got %q", prompt)
+	}
+
+	if _, err := engine.Rollback("greeting", 1); err != nil {
+		t.Fatal(err)
+	}
+	if prompt, _ := engine.GeneratePrompt("greeting", variables); prompt != "Hello Ada" {
+		t.Errorf("Expected v1 to render after the rollback, got %q", prompt)
+	}
+
+	versions, err := engine.ListVersions("greeting")
+	if err != nil || len(versions) != 2 || !versions[0].Active || versions[1].Active {
+		t.Errorf("Expected v1 of 2 versions active, got %+v (%v)", versions, err)
+	}
+	if v2, err := engine.GetTemplateVersion("greeting", 2); err != nil || v2.Template != "Hi {{.name}}!" || v2.Version != 2 {
+		t.Errorf("Expected v2 kept after the rollback, got %+v (%v)", v2, err)
+	}
+
+	greeting.Template = "Hey {{.name}}"
+	engine.AddTemplate(greeting)
+	if active, _ := engine.GetTemplate("greeting"); active.Version != 3 {
+		t.Errorf("Expected a template added after a rollback to be v3, got v%d", active.Version)
+	}
+
+	if _, err := engine.Rollback("greeting", 4); err == nil {
+		t.Error("Expected rolling back to a missing version to fail")
+	}
+	if _, err := engine.ListVersions("missing"); err == nil {
+		t.Error("Expected listing versions of a missing template to fail")
+	}
+}
@@ -0,0 +1,43 @@
+package memory
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sakibmulla/agentic-ai/endpoint"
+)
+
+// newBenchManager returns a manager with a long history and some summaries,
+// filled in directly so no API calls are made
+func newBenchManager() *MemoryManager {
+	mm := NewMemoryManager("test-key", endpoint.Endpoint{}, "bench-user", nil)
+	start := time.Now()
+	for i := 0; i < 200; i++ {
+		content := strings.Repeat(fmt.Sprintf("message %d ", i), 15)
+		mm.conversationHistory = append(mm.conversationHistory, Message{
+			ID:         fmt.Sprintf("msg_%d", i),
+			Role:       "user",
+			Content:    content,
+			Timestamp:  start.Add(time.Duration(i) * time.Second),
+			TokensUsed: mm.messageTokens(content),
+		})
+	}
+	for i := 0; i < 10; i++ {
+		mm.summaries = append(mm.summaries, ConversationSummary{
+			Summary: fmt.Sprintf("Summary of part %d of the conversation", i),
+			EndTime: start.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	return mm
+}
+
+func BenchmarkUpdateContextWindow(b *testing.B) {
+	mm := newBenchManager()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		mm.updateContextWindow()
+	}
+}
@@ -0,0 +1,224 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ContextCandidates is everything a ContextStrategy may put in the window
+type ContextCandidates struct {
+	// History is the conversation not yet summarized, oldest first, with
+	// each message's TokensUsed counted
+	History []Message
+	// Summaries cover the older parts of the conversation, oldest first
+	Summaries []ConversationSummary
+	// Facts are what is known about the user
+	Facts []MemoryFact
+	// QueryEmbedding is the latest user message's embedding; nil when
+	// embeddings are unavailable
+	QueryEmbedding []float32
+	// Now is when the window is packed, for decaying fact confidence
+	Now time.Time
+	// Count returns the tokens a message with content takes up
+	Count func(content string) int
+}
+
+// ContextStrategy chooses what goes into the context window for the next
+// LLM call. Pack returns the messages to send after the system prompt, in
+// order, taking up at most budget tokens between them. The result is copied
+// into the window, so it may share candidates' slices.
+type ContextStrategy interface {
+	Name() string
+	Pack(candidates ContextCandidates, budget int) []Message
+}
+
+// FactPinner is implemented by strategies that put the user's facts in the
+// window themselves, within its budget, so the system prompt leaves them out
+type FactPinner interface {
+	PinsFacts() bool
+}
+
+// Built-in context strategy names
+const (
+	ContextRecency   = "recency"
+	ContextRelevance = "relevance"
+	ContextHybrid    = "hybrid"
+)
+
+// ParseContextStrategy returns the built-in strategy called name, with its
+// default settings; empty means relevance
+func ParseContextStrategy(name string) (ContextStrategy, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case ContextRecency:
+		return RecencyStrategy{}, nil
+	case "", ContextRelevance:
+		return RelevanceStrategy{MaxSummaries: 3}, nil
+	case ContextHybrid:
+		return NewHybridStrategy(), nil
+	default:
+		return nil, fmt.Errorf("invalid context strategy %q: use recency, relevance or hybrid", name)
+	}
+}
+
+// RecencyStrategy sends as many of the latest messages as fit, and nothing
+// older
+type RecencyStrategy struct{}
+
+func (RecencyStrategy) Name() string { return ContextRecency }
+
+func (RecencyStrategy) Pack(candidates ContextCandidates, budget int) []Message {
+	recent, _ := recentMessages(candidates.History, budget, false)
+	return recent
+}
+
+// RelevanceStrategy sends the summaries most similar to the latest user
+// message, then as many of the latest messages as still fit. The latest
+// message is always set aside room first.
+type RelevanceStrategy struct {
+	// MaxSummaries bounds the summaries sent; zero sends all that fit
+	MaxSummaries int
+}
+
+func (s RelevanceStrategy) Name() string { return ContextRelevance }
+
+func (s RelevanceStrategy) Pack(candidates ContextCandidates, budget int) []Message {
+	summaries, used := summaryMessages(candidates, s.MaxSummaries, budget-latestTokens(candidates.History, budget))
+	recent, _ := recentMessages(candidates.History, budget-used, false)
+	return append(summaries, recent...)
+}
+
+// HybridStrategy sends the user's most trusted facts, then the most
+// relevant summaries, then the latest turns. Facts and summaries each get
+// a share of what the latest message leaves of the budget, so neither can
+// crowd out the conversation; what they leave unused goes to the turns.
+type HybridStrategy struct {
+	// FactShare and SummaryShare are the parts of the budget facts and
+	// summaries may take, between 0 and 1
+	FactShare    float64
+	SummaryShare float64
+	// MaxSummaries bounds the summaries sent; zero sends all that fit
+	MaxSummaries int
+}
+
+// NewHybridStrategy gives facts a fifth of the budget and summaries
+// three tenths, leaving at least half for the latest turns
+func NewHybridStrategy() HybridStrategy {
+	return HybridStrategy{FactShare: 0.2, SummaryShare: 0.3, MaxSummaries: 3}
+}
+
+func (s HybridStrategy) Name() string { return ContextHybrid }
+
+func (s HybridStrategy) PinsFacts() bool { return true }
+
+func (s HybridStrategy) Pack(candidates ContextCandidates, budget int) []Message {
+	var window []Message
+	used := 0
+	shared := float64(budget - latestTokens(candidates.History, budget))
+	if facts, ok := pinnedFacts(candidates, int(shared*s.FactShare)); ok {
+		window = append(window, facts)
+		used += facts.TokensUsed
+	}
+
+	summaries, tokens := summaryMessages(candidates, s.MaxSummaries, int(shared*s.SummaryShare))
+	window = append(window, summaries...)
+	used += tokens
+
+	recent, _ := recentMessages(candidates.History, budget-used, true)
+	return append(window, recent...)
+}
+
+// pinnedFactsHeader introduces the facts a strategy pins in the window; it
+// matches the system prompt's
+const pinnedFactsHeader = "What I know about you:"
+
+// pinnedFacts returns a system message listing the facts the system prompt
+// would, confirmed ones first and then by confidence, as many as fit in
+// budget. The IDs of the facts listed are kept in its "facts" metadata.
+func pinnedFacts(candidates ContextCandidates, budget int) (Message, bool) {
+	type scored struct {
+		fact       MemoryFact
+		confidence float64
+	}
+	var facts []scored
+	for _, fact := range candidates.Facts {
+		if confidence := decayedConfidence(fact, candidates.Now); confidence > promptFactConfidence {
+			facts = append(facts, scored{fact, confidence})
+		}
+	}
+	sort.SliceStable(facts, func(i, j int) bool {
+		if facts[i].fact.Confirmed != facts[j].fact.Confirmed {
+			return facts[i].fact.Confirmed
+		}
+		return facts[i].confidence > facts[j].confidence
+	})
+
+	content := pinnedFactsHeader
+	var ids []string
+	for _, f := range facts {
+		next := content + "\n- " + f.fact.Fact
+		if candidates.Count(next) > budget {
+			break
+		}
+		content = next
+		ids = append(ids, f.fact.ID)
+	}
+	if len(ids) == 0 {
+		return Message{}, false
+	}
+	return Message{
+		Role:       "system",
+		Content:    content,
+		Metadata:   map[string]interface{}{"facts": ids},
+		TokensUsed: candidates.Count(content),
+	}, true
+}
+
+// summaryMessages returns system messages for the summaries most relevant to
+// the latest user message, up to limit of them (zero for no limit), as many
+// as fit in budget, and the tokens they take up
+func summaryMessages(candidates ContextCandidates, limit, budget int) ([]Message, int) {
+	if limit <= 0 {
+		limit = len(candidates.Summaries)
+	}
+	var messages []Message
+	used := 0
+	for _, summary := range rankSummaries(candidates.Summaries, candidates.QueryEmbedding, limit) {
+		content := "Previous conversation summary: " + summary.Summary
+		tokens := candidates.Count(content)
+		if used+tokens > budget {
+			continue
+		}
+		messages = append(messages, Message{Role: "system", Content: content, TokensUsed: tokens})
+		used += tokens
+	}
+	return messages, used
+}
+
+// latestTokens is what the latest message takes up, or zero if it doesn't
+// fit in budget; strategies set it aside so the message being answered is
+// always sent
+func latestTokens(history []Message, budget int) int {
+	if len(history) == 0 || history[len(history)-1].TokensUsed > budget {
+		return 0
+	}
+	return history[len(history)-1].TokensUsed
+}
+
+// recentMessages returns as many of the latest messages in history as fit
+// in budget, in order, and the tokens they take up; the result shares
+// history's array. With wholeTurns, a reply
+// whose question didn't fit is left out too, unless it is the latest message.
+func recentMessages(history []Message, budget int, wholeTurns bool) ([]Message, int) {
+	start, used := len(history), 0
+	for start > 0 && used+history[start-1].TokensUsed <= budget {
+		used += history[start-1].TokensUsed
+		start--
+	}
+	if wholeTurns && start > 0 && start < len(history)-1 && history[start].Role == "assistant" {
+		used -= history[start].TokensUsed
+		start++
+	}
+	return history[start:], used
+}
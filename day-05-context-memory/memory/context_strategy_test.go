@@ -0,0 +1,114 @@
+package memory
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// newStrategyManager returns a bench manager whose history alternates user
+// and assistant turns, with facts and with summaries about different topics
+func newStrategyManager() *MemoryManager {
+	mm := newBenchManager()
+	for i := range mm.conversationHistory {
+		if i%2 == 1 {
+			mm.conversationHistory[i].Role = "assistant"
+		}
+	}
+	for i := range mm.summaries {
+		embedding := make([]float32, len(mm.summaries))
+		embedding[i] = 1
+		mm.summaries[i].Embedding = embedding
+	}
+	mm.queryEmbedding = mm.summaries[7].Embedding
+
+	now := time.Now()
+	mm.userMemory.Facts = []MemoryFact{
+		{ID: "likely", Fact: "The user likes hiking", Confidence: 0.8, Timestamp: now},
+		{ID: "confirmed", Fact: "The user's name is Alice", Confidence: 0.75, Confirmed: true, Timestamp: now},
+		{ID: "doubtful", Fact: "The user may own a cat", Confidence: 0.5, Timestamp: now},
+	}
+	return mm
+}
+
+func TestContextStrategiesKeepToBudget(t *testing.T) {
+	for _, name := range []string{ContextRecency, ContextRelevance, ContextHybrid} {
+		for _, limit := range []int{120, 400, 1500} {
+			mm := newStrategyManager()
+			strategy, err := ParseContextStrategy(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mm.config.ContextStrategy = strategy
+			mm.contextWindow.TokenLimit = limit
+			mm.updateContextWindow()
+
+			sent := 0
+			for _, msg := range mm.ContextMessages() {
+				sent += mm.messageTokens(msg.Content)
+			}
+			if sent > limit || sent != mm.contextWindow.TokensUsed {
+				t.Errorf("%s with %d tokens: sent %d, counted %d", name, limit, sent, mm.contextWindow.TokensUsed)
+			}
+			window := mm.contextWindow.Messages
+			if len(window) == 0 || window[len(window)-1].Content != mm.conversationHistory[199].Content {
+				t.Errorf("%s with %d tokens: expected the latest message kept", name, limit)
+			}
+		}
+	}
+}
+
+func TestContextStrategyChoices(t *testing.T) {
+	mm := newStrategyManager()
+	mm.contextWindow.TokenLimit = 1000
+
+	mm.config.ContextStrategy = RecencyStrategy{}
+	mm.updateContextWindow()
+	for _, msg := range mm.contextWindow.Messages {
+		if msg.Role == "system" {
+			t.Errorf("Expected recency to send messages only, got %q", msg.Content)
+		}
+	}
+
+	mm.config.ContextStrategy = RelevanceStrategy{MaxSummaries: 3}
+	mm.updateContextWindow()
+	if first := mm.contextWindow.Messages[0].Content; !strings.HasSuffix(first, "part 7 of the conversation") {
+		t.Errorf("Expected the most similar summary first, got %q", first)
+	}
+	if !strings.Contains(mm.buildSystemPrompt(), "The user likes hiking") {
+		t.Error("Expected relevance to leave facts in the system prompt")
+	}
+
+	mm.config.ContextStrategy = NewHybridStrategy()
+	mm.updateContextWindow()
+	window := mm.contextWindow.Messages
+	if want := "What I know about you:\n- The user's name is Alice\n- The user likes hiking"; window[0].Content != want {
+		t.Errorf("Expected trusted facts pinned, confirmed first, got %q", window[0].Content)
+	}
+	if strings.Contains(mm.buildSystemPrompt(), "hiking") {
+		t.Error("Expected pinned facts left out of the system prompt")
+	}
+	summaries := 0
+	for _, msg := range window[1:] {
+		if strings.HasPrefix(msg.Content, "Previous conversation summary") {
+			summaries++
+		}
+	}
+	if turns := window[1+summaries:]; summaries != 3 || turns[0].Role != "user" {
+		t.Errorf("Expected 3 summaries, then whole turns; got %d summaries, turns starting with %s", summaries, turns[0].Role)
+	}
+
+	mm.ContextMessages()
+	for _, fact := range mm.userMemory.Facts {
+		if referenced := !fact.LastReferenced.IsZero(); referenced != (fact.ID != "doubtful") {
+			t.Errorf("Fact %s referenced = %v", fact.ID, referenced)
+		}
+	}
+
+	if _, err := ParseContextStrategy("random"); err == nil {
+		t.Error("Expected an unknown strategy rejected")
+	}
+	if strategy, _ := ParseContextStrategy(""); strategy.Name() != ContextRelevance {
+		t.Errorf("Expected relevance by default, got %s", strategy.Name())
+	}
+}
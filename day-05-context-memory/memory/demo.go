@@ -1,13 +1,17 @@
-package main
+package memory
 
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"strings"
 
 	"github.com/joho/godotenv"
+	"github.com/sakibmulla/agentic-ai/costs"
+	"github.com/sakibmulla/agentic-ai/endpoint"
+	"github.com/sakibmulla/agentic-ai/logging"
+	"github.com/sakibmulla/agentic-ai/tokenizer"
 	"github.com/sashabaranov/go-openai"
 )
 
@@ -55,18 +59,33 @@ type TokenBudgetManager struct {
 	systemTokens   int
 	historyTokens  int
 	responseTokens int
+	counter        tokenizer.TokenCounter
 }
 
-// NewTokenBudgetManager creates a new token budget manager
-func NewTokenBudgetManager(totalBudget int) *TokenBudgetManager {
+// NewTokenBudgetManager creates a new token budget manager that measures
+// text with counter
+func NewTokenBudgetManager(totalBudget int, counter tokenizer.TokenCounter) *TokenBudgetManager {
 	return &TokenBudgetManager{
 		totalBudget:    totalBudget,
 		systemTokens:   200,                     // Reserve for system prompt
 		responseTokens: 500,                     // Reserve for response
 		historyTokens:  totalBudget - 200 - 500, // Remaining for history
+		counter:        counter,
 	}
 }
 
+// Count returns the tokens text takes up
+func (tbm *TokenBudgetManager) Count(text string) int {
+	return tbm.counter.Count(text)
+}
+
+// ReserveSystemPrompt reserves exactly what the system prompt takes up,
+// instead of the default guess
+func (tbm *TokenBudgetManager) ReserveSystemPrompt(prompt string) {
+	tbm.systemTokens = tbm.Count(prompt) + tokenizer.MessageOverhead
+	tbm.AdjustForResponse(tbm.responseTokens)
+}
+
 // CalculateAvailableHistory returns tokens available for history
 func (tbm *TokenBudgetManager) CalculateAvailableHistory() int {
 	return tbm.historyTokens
@@ -140,22 +159,18 @@ type MemoryDemo struct {
 	learnedFacts  []string
 }
 
-// NewMemoryDemo creates a new memory demonstration
-func NewMemoryDemo(apiKey string) *MemoryDemo {
+// NewMemoryDemo creates a new memory demonstration that calls api, with
+// calls budgeted and priced by limiter, when given
+func NewMemoryDemo(apiKey string, api endpoint.Endpoint, limiter *costs.Limiter) *MemoryDemo {
 	return &MemoryDemo{
-		client:        openai.NewClient(apiKey),
+		client:        newOpenAIClient(apiKey, api, limiter),
 		slidingWindow: NewSlidingWindow(5),
-		budgetManager: NewTokenBudgetManager(2000),
+		budgetManager: NewTokenBudgetManager(2000, tokenizer.ForModel(openai.GPT3Dot5Turbo)),
 		factExtractor: NewFactExtractor(),
 		learnedFacts:  make([]string, 0),
 	}
 }
 
-// estimateTokens provides rough token estimation
-func (md *MemoryDemo) estimateTokens(text string) int {
-	return len(strings.Fields(text)) // Roughly 1 token per word
-}
-
 // ProcessMessage processes a user message with memory features
 func (md *MemoryDemo) ProcessMessage(ctx context.Context, userMessage string) (string, error) {
 	// Extract facts from user message
@@ -173,8 +188,9 @@ func (md *MemoryDemo) ProcessMessage(ctx context.Context, userMessage string) (s
 	contextHistory := md.slidingWindow.GetContext()
 
 	// Check token budget
-	systemTokens := md.estimateTokens(systemPrompt)
-	contextTokens := md.estimateTokens(contextHistory)
+	md.budgetManager.ReserveSystemPrompt(systemPrompt)
+	systemTokens := md.budgetManager.systemTokens
+	contextTokens := md.budgetManager.Count(contextHistory)
 
 	fmt.Printf("📊 Token usage: System=%d, Context=%d, Available=%d\n",
 		systemTokens, contextTokens, md.budgetManager.CalculateAvailableHistory())
@@ -259,17 +275,35 @@ func (md *MemoryDemo) ShowMemoryState() {
 // RunMemoryDemo demonstrates various memory concepts
 func RunMemoryDemo() {
 	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using system environment variables")
+	envErr := godotenv.Load()
+	// LOG_LEVEL and LOG_FORMAT may come from .env
+	logging.Setup(slog.LevelWarn)
+	if envErr != nil {
+		slog.Info("no .env file found, using system environment variables")
+	}
+
+	// OPENAI_BASE_URL and OPENAI_API_TYPE point the client at Azure OpenAI
+	// or an OpenAI-compatible server such as vLLM
+	api, err := endpoint.FromEnv()
+	if err != nil {
+		logging.Fatal("invalid endpoint", "error", err)
 	}
 
 	// Get OpenAI API key
 	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		log.Fatal("OPENAI_API_KEY environment variable is required")
+	if apiKey == "" && api.RequiresKey() {
+		logging.Fatal("OPENAI_API_KEY environment variable is required")
+	}
+
+	if err := api.CheckModels(context.Background()); err != nil {
+		logging.Fatal("local models unavailable", "error", err)
 	}
 
-	demo := NewMemoryDemo(apiKey)
+	limiter, err := costs.FromEnv()
+	if err != nil {
+		logging.Fatal("invalid budget", "error", err)
+	}
+	demo := NewMemoryDemo(apiKey, api, limiter)
 	ctx := context.Background()
 
 	fmt.Println("🔬 Memory Concepts Demonstration")
@@ -298,7 +332,7 @@ func RunMemoryDemo() {
 
 	// Demo 2: Token Budget Management
 	fmt.Println("💰 Demo 2: Token Budget Management")
-	budget := NewTokenBudgetManager(1500)
+	budget := NewTokenBudgetManager(1500, tokenizer.ForModel(openai.GPT3Dot5Turbo))
 	fmt.Printf("Total budget: %d tokens\n", budget.totalBudget)
 	fmt.Printf("System tokens: %d\n", budget.systemTokens)
 	fmt.Printf("Response tokens: %d\n", budget.responseTokens)
@@ -308,6 +342,11 @@ func RunMemoryDemo() {
 	budget.AdjustForResponse(800)
 	fmt.Printf("After adjusting for 800-token response:\n")
 	fmt.Printf("Available for history: %d\n", budget.CalculateAvailableHistory())
+
+	// Reserve what the system prompt really takes instead of the default guess
+	budget.ReserveSystemPrompt("You are a helpful AI assistant with memory of our conversation history.")
+	fmt.Printf("After reserving a %d-token system prompt:\n", budget.systemTokens)
+	fmt.Printf("Available for history: %d\n", budget.CalculateAvailableHistory())
 	fmt.Println()
 
 	// Demo 3: Fact Extraction
@@ -0,0 +1,235 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultStaleDays is how long a fact may go unreferenced before it is a stale candidate
+const defaultStaleDays = 14
+
+// factCategories maps the extraction patterns to a category
+var factCategories = map[string]string{
+	"i am ":       "identity",
+	"my name is ": "identity",
+	"i like ":     "preference",
+	"i prefer ":   "preference",
+	"i work ":     "work",
+	"i study ":    "education",
+	"i live ":     "location",
+	"i use ":      "tools",
+	"i need ":     "needs",
+}
+
+// factCategory returns the category for an extraction pattern
+func factCategory(pattern string) string {
+	if category, ok := factCategories[strings.ToLower(pattern)]; ok {
+		return category
+	}
+	return "personal"
+}
+
+// TimelineEntry is the number of facts learned on one day
+type TimelineEntry struct {
+	Day   string `json:"day"`
+	Count int    `json:"count"`
+}
+
+// FactsByCategory groups the learned facts by category
+func (mm *MemoryManager) FactsByCategory() map[string][]MemoryFact {
+	groups := make(map[string][]MemoryFact)
+	for _, fact := range mm.userMemory.Facts {
+		category := fact.Category
+		if category == "" {
+			category = "uncategorized"
+		}
+		groups[category] = append(groups[category], fact)
+	}
+	return groups
+}
+
+// FactTimeline returns the number of facts learned per day, oldest first
+func (mm *MemoryManager) FactTimeline() []TimelineEntry {
+	counts := make(map[string]int)
+	for _, fact := range mm.userMemory.Facts {
+		counts[fact.Timestamp.Format("2006-01-02")]++
+	}
+
+	timeline := make([]TimelineEntry, 0, len(counts))
+	for day, count := range counts {
+		timeline = append(timeline, TimelineEntry{Day: day, Count: count})
+	}
+	sort.Slice(timeline, func(i, j int) bool {
+		return timeline[i].Day < timeline[j].Day
+	})
+	return timeline
+}
+
+// ConfidenceDistribution buckets fact confidence into tenths (0.0-0.1 ... 0.9-1.0)
+func (mm *MemoryManager) ConfidenceDistribution() [10]int {
+	var buckets [10]int
+	for _, fact := range mm.userMemory.Facts {
+		bucket := int(fact.Confidence * 10)
+		if bucket < 0 {
+			bucket = 0
+		}
+		if bucket > 9 {
+			bucket = 9
+		}
+		buckets[bucket]++
+	}
+	return buckets
+}
+
+// StaleFacts returns unconfirmed facts that have not been referenced in a prompt
+// for the given number of days. Facts never referenced count from when they were learned.
+func (mm *MemoryManager) StaleFacts(days int) []MemoryFact {
+	cutoff := time.Now().AddDate(0, 0, -days)
+
+	stale := []MemoryFact{}
+	for _, fact := range mm.userMemory.Facts {
+		lastUsed := fact.LastReferenced
+		if lastUsed.IsZero() {
+			lastUsed = fact.Timestamp
+		}
+		if !fact.Confirmed && lastUsed.Before(cutoff) {
+			stale = append(stale, fact)
+		}
+	}
+	return stale
+}
+
+// ConfirmFacts marks the facts with the given IDs as confirmed by the user,
+// raising their confidence to 1.0. It returns how many were updated.
+func (mm *MemoryManager) ConfirmFacts(ids []string) int {
+	wanted := idSet(ids)
+	confirmed := 0
+	for i := range mm.userMemory.Facts {
+		if wanted[mm.userMemory.Facts[i].ID] {
+			mm.userMemory.Facts[i].Confirmed = true
+			mm.userMemory.Facts[i].Confidence = 1.0
+			confirmed++
+		}
+	}
+	return confirmed
+}
+
+// DeleteFacts removes the facts with the given IDs and returns how many were removed
+func (mm *MemoryManager) DeleteFacts(ids []string) int {
+	wanted := idSet(ids)
+	kept := mm.userMemory.Facts[:0]
+	for _, fact := range mm.userMemory.Facts {
+		if !wanted[fact.ID] {
+			kept = append(kept, fact)
+		}
+	}
+	deleted := len(mm.userMemory.Facts) - len(kept)
+	mm.userMemory.Facts = kept
+	return deleted
+}
+
+// idSet converts a list of IDs to a lookup set
+func idSet(ids []string) map[string]bool {
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
+
+// handleFactsCommand runs the "facts <view|action>" commands
+func handleFactsCommand(mm *MemoryManager, args []string) {
+	switch strings.ToLower(args[0]) {
+	case "categories":
+		groups := mm.FactsByCategory()
+		categories := make([]string, 0, len(groups))
+		for category := range groups {
+			categories = append(categories, category)
+		}
+		sort.Strings(categories)
+
+		fmt.Println("\n🗂️ Facts by category:")
+		for _, category := range categories {
+			fmt.Printf("  %s (%d)\n", category, len(groups[category]))
+			for _, fact := range groups[category] {
+				fmt.Printf("    - %s\n", fact.Fact)
+			}
+		}
+
+	case "timeline":
+		fmt.Println("\n📅 Facts learned per day:")
+		for _, entry := range mm.FactTimeline() {
+			fmt.Printf("  %s %s %d\n", entry.Day, strings.Repeat("█", entry.Count), entry.Count)
+		}
+
+	case "confidence":
+		fmt.Println("\n📈 Confidence distribution:")
+		for i, count := range mm.ConfidenceDistribution() {
+			fmt.Printf("  %.1f-%.1f %s %d\n", float64(i)/10, float64(i+1)/10, strings.Repeat("█", count), count)
+		}
+
+	case "stale":
+		days := parseStaleDays(args[1:])
+		stale := mm.StaleFacts(days)
+		fmt.Printf("\n🕸️ Stale fact candidates (unreferenced for %d+ days): %d\n", days, len(stale))
+		for _, fact := range stale {
+			fmt.Printf("  - %s (learned %s)\n", fact.Fact, fact.Timestamp.Format("2006-01-02"))
+		}
+
+	case "confirm", "delete":
+		if len(args) < 2 {
+			fmt.Printf("Usage: facts %s <n,n,...|stale [days]>\n", args[0])
+			return
+		}
+		ids, err := selectFactIDs(mm, args[1:])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if strings.ToLower(args[0]) == "confirm" {
+			fmt.Printf("✅ Confirmed %d facts\n", mm.ConfirmFacts(ids))
+		} else {
+			fmt.Printf("🗑️ Deleted %d facts\n", mm.DeleteFacts(ids))
+		}
+
+	default:
+		fmt.Println("Usage: facts [categories|timeline|confidence|stale [days]|confirm <n,...>|delete <n,...>]")
+	}
+	fmt.Println()
+}
+
+// selectFactIDs resolves "stale [days]" or 1-based positions from the facts
+// list (comma or space separated) to fact IDs
+func selectFactIDs(mm *MemoryManager, args []string) ([]string, error) {
+	if strings.ToLower(args[0]) == "stale" {
+		ids := []string{}
+		for _, fact := range mm.StaleFacts(parseStaleDays(args[1:])) {
+			ids = append(ids, fact.ID)
+		}
+		return ids, nil
+	}
+
+	facts := mm.GetUserFacts()
+	ids := []string{}
+	for _, field := range strings.FieldsFunc(strings.Join(args, ","), func(r rune) bool { return r == ',' || r == ' ' }) {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 1 || n > len(facts) {
+			return nil, fmt.Errorf("invalid fact number %q (see 'facts')", field)
+		}
+		ids = append(ids, facts[n-1].ID)
+	}
+	return ids, nil
+}
+
+// parseStaleDays reads an optional day count, defaulting to defaultStaleDays
+func parseStaleDays(args []string) int {
+	if len(args) > 0 {
+		if days, err := strconv.Atoi(args[0]); err == nil && days >= 0 {
+			return days
+		}
+	}
+	return defaultStaleDays
+}
@@ -0,0 +1,217 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	// duplicateFactSimilarity is how similar two facts' embeddings must be
+	// for the new one to count as a restatement
+	duplicateFactSimilarity = 0.92
+	// factHalfLifeDays is how long an unconfirmed fact takes to lose half
+	// its confidence when the user doesn't restate it
+	factHalfLifeDays = 30.0
+	// minFactConfidence is the decayed confidence below which a fact is forgotten
+	minFactConfidence = 0.2
+)
+
+// extractionCategories are the categories the extractor may assign
+var extractionCategories = []string{"identity", "preference", "work", "education", "location", "tools", "needs", "personal"}
+
+// factExtractionPrompt asks for the facts in one exchange as JSON
+var factExtractionPrompt = `Extract durable facts about the user from the exchange below: who they are, what they like, work on, use or need. Ignore facts about the assistant, one-off requests and anything the user didn't state or clearly imply.
+
+Respond with JSON only: {"facts": [{"fact": "...", "category": "...", "confidence": 0.0}]}
+- fact: one short third-person sentence, e.g. "The user works as a backend developer"
+- category: one of ` + strings.Join(extractionCategories, ", ") + `
+- confidence: 0 to 1; 0.9 or more only for explicit statements
+Use {"facts": []} when there are none.`
+
+// extractedFact is one fact found in an exchange, before it is stored
+type extractedFact struct {
+	Fact       string  `json:"fact"`
+	Category   string  `json:"category"`
+	Confidence float64 `json:"confidence"`
+}
+
+// extractAndStoreFacts learns facts from the latest exchange. Facts are
+// extracted by the LLM, falling back to simple patterns when that fails;
+// restated facts reinforce the ones already known instead of duplicating them.
+func (mm *MemoryManager) extractAndStoreFacts(userMessage, assistantResponse string) {
+	facts, err := mm.extractUserFacts(context.Background(), userMessage, assistantResponse)
+	source := "llm_extraction"
+	if err != nil {
+		slog.Warn("failed to extract facts, using patterns", "error", err)
+		facts = extractFactsByPattern(userMessage)
+		source = "user_statement"
+	}
+
+	for _, fact := range facts {
+		mm.storeFact(fact, source)
+	}
+}
+
+// extractUserFacts asks the LLM for the facts in an exchange
+func (mm *MemoryManager) extractUserFacts(ctx context.Context, userMessage, assistantResponse string) ([]extractedFact, error) {
+	resp, err := mm.client.CreateChatCompletion(mm.spendOn(ctx), openai.ChatCompletionRequest{
+		Model: openai.GPT3Dot5Turbo,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: factExtractionPrompt},
+			{Role: openai.ChatMessageRoleUser, Content: fmt.Sprintf("User: %s\nAssistant: %s", userMessage, assistantResponse)},
+		},
+		ResponseFormat: &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject},
+		Temperature:    0,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fact extraction call failed: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("no response choices returned")
+	}
+
+	var result struct {
+		Facts []extractedFact `json:"facts"`
+	}
+	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &result); err != nil {
+		return nil, fmt.Errorf("failed to parse extracted facts: %w", err)
+	}
+
+	facts := result.Facts[:0]
+	for _, fact := range result.Facts {
+		fact.Fact = strings.TrimSpace(fact.Fact)
+		if fact.Fact == "" {
+			continue
+		}
+		if !isExtractionCategory(fact.Category) {
+			fact.Category = "personal"
+		}
+		fact.Confidence = math.Max(0, math.Min(1, fact.Confidence))
+		facts = append(facts, fact)
+	}
+	return facts, nil
+}
+
+// isExtractionCategory reports whether category is one the extractor may assign
+func isExtractionCategory(category string) bool {
+	for _, known := range extractionCategories {
+		if category == known {
+			return true
+		}
+	}
+	return false
+}
+
+// extractFactsByPattern finds sentences such as "I work ..." or "My name
+// is ..." in the user's message
+func extractFactsByPattern(userMessage string) []extractedFact {
+	factPatterns := []string{
+		"I am ", "I like ", "I work ", "I study ", "I live ",
+		"My name is ", "I prefer ", "I use ", "I need ",
+	}
+
+	var facts []extractedFact
+	userLower := strings.ToLower(userMessage)
+	for _, pattern := range factPatterns {
+		pattern = strings.ToLower(pattern)
+		if !strings.Contains(userLower, pattern) {
+			continue
+		}
+		for _, sentence := range strings.Split(userMessage, ".") {
+			if strings.Contains(strings.ToLower(sentence), pattern) {
+				facts = append(facts, extractedFact{
+					Fact:       strings.TrimSpace(sentence),
+					Category:   factCategory(pattern),
+					Confidence: 0.8,
+				})
+				break
+			}
+		}
+	}
+	return facts
+}
+
+// storeFact adds a fact, or reinforces the known fact it restates
+func (mm *MemoryManager) storeFact(extracted extractedFact, source string) {
+	now := time.Now()
+	embedding := mm.embedOrNil(extracted.Fact)
+
+	if known := mm.findKnownFact(extracted.Fact, embedding); known != nil {
+		known.Confidence = math.Max(known.Confidence, extracted.Confidence)
+		known.LastStated = now
+		return
+	}
+
+	fact := MemoryFact{
+		ID:         fmt.Sprintf("fact_%d", now.UnixNano()),
+		Fact:       extracted.Fact,
+		Confidence: extracted.Confidence,
+		Source:     source,
+		Timestamp:  now,
+		Category:   extracted.Category,
+		Metadata:   make(map[string]interface{}),
+		LastStated: now,
+		Embedding:  embedding,
+	}
+	mm.tagPII(fact.Fact, fact.Metadata, true)
+	mm.userMemory.Facts = append(mm.userMemory.Facts, fact)
+}
+
+// findKnownFact returns the known fact that text restates: the same words,
+// or an embedding at least duplicateFactSimilarity similar. Known facts
+// saved without an embedding are embedded on the way.
+func (mm *MemoryManager) findKnownFact(text string, embedding []float32) *MemoryFact {
+	for i := range mm.userMemory.Facts {
+		known := &mm.userMemory.Facts[i]
+		if strings.EqualFold(strings.TrimSpace(known.Fact), text) {
+			return known
+		}
+		if embedding == nil {
+			continue
+		}
+		if known.Embedding == nil {
+			known.Embedding = mm.embedOrNil(known.Fact)
+		}
+		if cosineSimilarity(embedding, known.Embedding) >= duplicateFactSimilarity {
+			return known
+		}
+	}
+	return nil
+}
+
+// decayedConfidence is a fact's confidence now. Unconfirmed facts lose half
+// of it every factHalfLifeDays since the user last stated them.
+func decayedConfidence(fact MemoryFact, now time.Time) float64 {
+	if fact.Confirmed {
+		return fact.Confidence
+	}
+	stated := fact.LastStated
+	if stated.Before(fact.Timestamp) {
+		stated = fact.Timestamp
+	}
+	days := now.Sub(stated).Hours() / 24
+	if days <= 0 {
+		return fact.Confidence
+	}
+	return fact.Confidence * math.Pow(0.5, days/factHalfLifeDays)
+}
+
+// expireFacts forgets unconfirmed facts whose confidence has decayed below
+// minFactConfidence and returns how many were forgotten
+func (mm *MemoryManager) expireFacts() int {
+	now := time.Now()
+	var expired []string
+	for _, fact := range mm.userMemory.Facts {
+		if decayedConfidence(fact, now) < minFactConfidence {
+			expired = append(expired, fact.ID)
+		}
+	}
+	return mm.DeleteFacts(expired)
+}
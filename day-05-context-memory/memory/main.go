@@ -0,0 +1,751 @@
+package memory
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/sakibmulla/agentic-ai/costs"
+	"github.com/sakibmulla/agentic-ai/endpoint"
+	"github.com/sakibmulla/agentic-ai/logging"
+	"github.com/sakibmulla/agentic-ai/settings"
+	"github.com/sakibmulla/agentic-ai/tokenizer"
+	"github.com/sashabaranov/go-openai"
+)
+
+// Message represents a single conversation message
+type Message struct {
+	ID         string                 `json:"id"`
+	Role       string                 `json:"role"`
+	Content    string                 `json:"content"`
+	Timestamp  time.Time              `json:"timestamp"`
+	Metadata   map[string]interface{} `json:"metadata"`
+	TokensUsed int                    `json:"tokens_used"`
+}
+
+// ConversationSummary represents a summarized conversation segment
+type ConversationSummary struct {
+	ID             string    `json:"id"`
+	StartTime      time.Time `json:"start_time"`
+	EndTime        time.Time `json:"end_time"`
+	Summary        string    `json:"summary"`
+	KeyTopics      []string  `json:"key_topics"`
+	ImportantFacts []string  `json:"important_facts"`
+	MessageCount   int       `json:"message_count"`
+	TokensUsed     int       `json:"tokens_used"`
+	// PII lists the kinds of personal data in the summary
+	PII []string `json:"pii,omitempty"`
+	// Embedding of Summary, for finding it by similarity; empty if
+	// embedding failed
+	Embedding []float32 `json:"embedding,omitempty"`
+}
+
+// UserMemory stores persistent information about a user
+type UserMemory struct {
+	UserID      string                 `json:"user_id"`
+	Profile     map[string]interface{} `json:"profile"`
+	Preferences map[string]interface{} `json:"preferences"`
+	Facts       []MemoryFact           `json:"facts"`
+	LastSeen    time.Time              `json:"last_seen"`
+	Sessions    int                    `json:"sessions"`
+}
+
+// MemoryFact represents a learned fact about the user or conversation
+type MemoryFact struct {
+	ID         string                 `json:"id"`
+	Fact       string                 `json:"fact"`
+	Confidence float64                `json:"confidence"`
+	Source     string                 `json:"source"`
+	Timestamp  time.Time              `json:"timestamp"`
+	Category   string                 `json:"category"`
+	Metadata   map[string]interface{} `json:"metadata"`
+	// LastReferenced is when the fact was last put into a prompt
+	LastReferenced time.Time `json:"last_referenced"`
+	Confirmed      bool      `json:"confirmed"`
+	// LastStated is when the user last restated the fact, which resets its decay
+	LastStated time.Time `json:"last_stated"`
+	// Embedding of Fact, for recognizing paraphrases of it
+	Embedding []float32 `json:"embedding,omitempty"`
+}
+
+// ContextWindow manages the conversation context for LLM calls
+type ContextWindow struct {
+	Messages     []Message `json:"messages"`
+	TokenLimit   int       `json:"token_limit"`
+	TokensUsed   int       `json:"tokens_used"`
+	SystemPrompt string    `json:"system_prompt"`
+}
+
+// MemoryManager handles all aspects of conversation memory
+type MemoryManager struct {
+	client              *openai.Client
+	conversationHistory []Message
+	summaries           []ConversationSummary
+	userMemory          *UserMemory
+	contextWindow       *ContextWindow
+	config              MemoryConfig
+	// store persists memory between sessions; nil disables saving
+	store MemoryStore
+	// queryEmbedding is the latest user message's embedding, which
+	// summaries are ranked against; nil falls back to recency
+	queryEmbedding []float32
+	// tokens counts tokens with the chat model's encoding
+	tokens tokenizer.TokenCounter
+	// piiValues maps personal data the LLM found to its kind; it is never saved
+	piiValues map[string]string
+}
+
+// MemoryConfig holds configuration for memory management
+type MemoryConfig struct {
+	MaxMessages         int     `json:"max_messages"`
+	MaxTokens           int     `json:"max_tokens"`
+	SummaryThreshold    int     `json:"summary_threshold"`
+	RelevanceThreshold  float64 `json:"relevance_threshold"`
+	MemoryRetentionDays int     `json:"memory_retention_days"`
+	// PIIMode is how personal data is treated before it is saved
+	PIIMode PIIMode `json:"pii_mode"`
+	// PIIAssist has the LLM look for personal data the patterns miss in
+	// facts and summaries. Messages use the patterns only, since checking
+	// each one would double the calls.
+	PIIAssist bool `json:"pii_llm_assist"`
+	// ContextStrategy packs the context window; nil means relevance
+	ContextStrategy ContextStrategy `json:"-"`
+}
+
+// NewMemoryManager creates a new memory management system that calls api.
+// Its calls are budgeted and priced by limiter, when given, and spent on
+// the user.
+func NewMemoryManager(apiKey string, api endpoint.Endpoint, userID string, limiter *costs.Limiter) *MemoryManager {
+	config := MemoryConfig{
+		MaxMessages:         50,
+		MaxTokens:           3000,
+		SummaryThreshold:    20,
+		RelevanceThreshold:  0.7,
+		MemoryRetentionDays: 30,
+		PIIMode:             PIITag,
+		ContextStrategy:     RelevanceStrategy{MaxSummaries: 3},
+	}
+
+	contextWindow := &ContextWindow{
+		Messages:     make([]Message, 0),
+		TokenLimit:   config.MaxTokens,
+		TokensUsed:   0,
+		SystemPrompt: "You are a helpful AI assistant with memory of our conversation history.",
+	}
+
+	userMemory := &UserMemory{
+		UserID:      userID,
+		Profile:     make(map[string]interface{}),
+		Preferences: make(map[string]interface{}),
+		Facts:       make([]MemoryFact, 0),
+		LastSeen:    time.Now(),
+		Sessions:    1,
+	}
+
+	return &MemoryManager{
+		client:              newOpenAIClient(apiKey, api, limiter),
+		conversationHistory: make([]Message, 0),
+		summaries:           make([]ConversationSummary, 0),
+		userMemory:          userMemory,
+		contextWindow:       contextWindow,
+		config:              config,
+		tokens:              tokenizer.ForModel(openai.GPT3Dot5Turbo),
+		piiValues:           make(map[string]string),
+	}
+}
+
+// newOpenAIClient creates a client for api whose calls go through limiter,
+// when given
+func newOpenAIClient(apiKey string, api endpoint.Endpoint, limiter *costs.Limiter) *openai.Client {
+	config := api.Config(apiKey)
+	// Ollama's requests are sent with its local models, limiter or not
+	httpClient := &http.Client{}
+	if limiter != nil {
+		httpClient = limiter.HTTPClient()
+	}
+	config.HTTPClient = api.HTTPClient(httpClient)
+	return openai.NewClientWithConfig(config)
+}
+
+// spendOn attributes the calls made with ctx to the manager's user
+func (mm *MemoryManager) spendOn(ctx context.Context) context.Context {
+	return costs.WithAttribution(ctx, costs.Attribution{User: mm.userMemory.UserID})
+}
+
+// AddMessage adds a new message to the conversation
+func (mm *MemoryManager) AddMessage(role, content string) {
+	message := Message{
+		ID:         fmt.Sprintf("msg_%d", time.Now().UnixNano()),
+		Role:       role,
+		Content:    content,
+		Timestamp:  time.Now(),
+		Metadata:   make(map[string]interface{}),
+		TokensUsed: mm.messageTokens(content),
+	}
+	mm.tagPII(content, message.Metadata, false)
+
+	mm.conversationHistory = append(mm.conversationHistory, message)
+
+	if role == "user" {
+		mm.queryEmbedding = mm.embedOrNil(content)
+	}
+
+	// Check if we need to summarize old messages
+	if len(mm.conversationHistory) > mm.config.SummaryThreshold {
+		mm.createSummary()
+	}
+
+	// Update context window
+	mm.updateContextWindow()
+
+	mm.flushOrLog()
+}
+
+// messageTokens counts the tokens a message with content takes up in a
+// prompt, including its role and delimiters
+func (mm *MemoryManager) messageTokens(content string) int {
+	return mm.tokens.Count(content) + tokenizer.MessageOverhead
+}
+
+// createSummary creates a summary of older conversation messages
+func (mm *MemoryManager) createSummary() {
+	if len(mm.conversationHistory) < mm.config.SummaryThreshold {
+		return
+	}
+
+	// Take the first half of messages for summarization
+	splitPoint := len(mm.conversationHistory) / 2
+	messagesToSummarize := mm.conversationHistory[:splitPoint]
+
+	// Create conversation text for summarization
+	conversationText := mm.buildConversationText(messagesToSummarize)
+
+	// Generate summary using LLM
+	summary, err := mm.generateSummary(context.Background(), conversationText)
+	if err != nil {
+		slog.Error("failed to generate summary", "error", err)
+		return
+	}
+
+	// Create summary object
+	summaryObj := ConversationSummary{
+		ID:             fmt.Sprintf("summary_%d", time.Now().UnixNano()),
+		StartTime:      messagesToSummarize[0].Timestamp,
+		EndTime:        messagesToSummarize[len(messagesToSummarize)-1].Timestamp,
+		Summary:        summary,
+		KeyTopics:      mm.extractTopics(conversationText),
+		ImportantFacts: mm.extractFacts(summary),
+		MessageCount:   len(messagesToSummarize),
+		TokensUsed:     mm.calculateTokens(messagesToSummarize),
+		Embedding:      mm.embedOrNil(summary),
+		PII:            piiKinds(mm.scanPII(context.Background(), summary, true)),
+	}
+
+	// Store summary and remove old messages
+	mm.summaries = append(mm.summaries, summaryObj)
+	mm.conversationHistory = mm.conversationHistory[splitPoint:]
+
+	fmt.Printf("📝 Created conversation summary covering %d messages\n", len(messagesToSummarize))
+}
+
+// buildConversationText creates a text representation of messages
+func (mm *MemoryManager) buildConversationText(messages []Message) string {
+	var builder strings.Builder
+
+	for _, msg := range messages {
+		builder.WriteString(fmt.Sprintf("%s: %s\n", msg.Role, msg.Content))
+	}
+
+	return builder.String()
+}
+
+// generateSummary creates a summary using the LLM
+func (mm *MemoryManager) generateSummary(ctx context.Context, conversationText string) (string, error) {
+	prompt := fmt.Sprintf(`Please summarize the following conversation, highlighting:
+1. Key topics discussed
+2. Important decisions made
+3. User preferences revealed
+4. Any facts learned about the user
+5. Action items or follow-ups
+
+Conversation:
+%s
+
+Summary:`, conversationText)
+
+	req := openai.ChatCompletionRequest{
+		Model: openai.GPT3Dot5Turbo,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: prompt,
+			},
+		},
+		Temperature: 0.3,
+		MaxTokens:   500,
+	}
+
+	resp, err := mm.client.CreateChatCompletion(mm.spendOn(ctx), req)
+	if err != nil {
+		return "", err
+	}
+
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("no summary generated")
+	}
+
+	return resp.Choices[0].Message.Content, nil
+}
+
+// extractTopics extracts key topics from conversation text
+func (mm *MemoryManager) extractTopics(text string) []string {
+	// Simple keyword extraction - in production, use more sophisticated NLP
+	keywords := []string{}
+
+	// Common technical topics
+	techTopics := []string{"programming", "go", "golang", "code", "function", "api", "database", "web", "server"}
+
+	textLower := strings.ToLower(text)
+	for _, topic := range techTopics {
+		if strings.Contains(textLower, topic) {
+			keywords = append(keywords, topic)
+		}
+	}
+
+	return keywords
+}
+
+// extractFacts extracts facts from summary text
+func (mm *MemoryManager) extractFacts(summary string) []string {
+	// Simple fact extraction - look for declarative sentences
+	facts := []string{}
+
+	sentences := strings.Split(summary, ". ")
+	for _, sentence := range sentences {
+		sentence = strings.TrimSpace(sentence)
+		if len(sentence) > 20 && !strings.Contains(sentence, "?") {
+			facts = append(facts, sentence)
+		}
+	}
+
+	return facts
+}
+
+// calculateTokens sums up tokens used in messages
+func (mm *MemoryManager) calculateTokens(messages []Message) int {
+	total := 0
+	for _, msg := range messages {
+		total += msg.TokensUsed
+	}
+	return total
+}
+
+// updateContextWindow packs the context window for the next LLM call with
+// the configured strategy, within what the system prompt leaves of the limit
+func (mm *MemoryManager) updateContextWindow() {
+	window := mm.contextWindow
+	strategy := mm.contextStrategy()
+	now := time.Now()
+
+	window.SystemPrompt, _ = mm.systemPrompt(now)
+	window.TokensUsed = mm.messageTokens(window.SystemPrompt)
+	packed := strategy.Pack(ContextCandidates{
+		History:        mm.conversationHistory,
+		Summaries:      mm.summaries,
+		Facts:          mm.userMemory.Facts,
+		QueryEmbedding: mm.queryEmbedding,
+		Now:            now,
+		Count:          mm.messageTokens,
+	}, window.TokenLimit-window.TokensUsed)
+
+	// The buffer is reused between turns
+	window.Messages = append(window.Messages[:0], packed...)
+	window.TokensUsed += mm.calculateTokens(window.Messages)
+}
+
+// contextStrategy returns the configured context strategy
+func (mm *MemoryManager) contextStrategy() ContextStrategy {
+	if mm.config.ContextStrategy == nil {
+		return RelevanceStrategy{MaxSummaries: 3}
+	}
+	return mm.config.ContextStrategy
+}
+
+// pinsFacts reports whether the context strategy puts facts in the window
+func (mm *MemoryManager) pinsFacts() bool {
+	pinner, ok := mm.contextStrategy().(FactPinner)
+	return ok && pinner.PinsFacts()
+}
+
+// Chat processes a user message and generates a response
+func (mm *MemoryManager) Chat(ctx context.Context, userMessage string) (string, error) {
+	// Add user message to history
+	mm.AddMessage("user", userMessage)
+
+	// Make LLM call
+	req := openai.ChatCompletionRequest{
+		Model:       openai.GPT3Dot5Turbo,
+		Messages:    mm.ContextMessages(),
+		Temperature: 0.7,
+		MaxTokens:   800,
+	}
+
+	resp, err := mm.client.CreateChatCompletion(mm.spendOn(ctx), req)
+	if err != nil {
+		return "", fmt.Errorf("chat completion failed: %w", err)
+	}
+
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("no response generated")
+	}
+
+	response := resp.Choices[0].Message.Content
+
+	// Extract and store any new facts about the user, before the response
+	// is added so they are saved with it
+	mm.extractAndStoreFacts(userMessage, response)
+
+	// Add assistant response to history
+	mm.AddMessage("assistant", response)
+
+	return response, nil
+}
+
+// ContextMessages returns the messages for the next LLM call: the system
+// prompt followed by the context window. Callers that add their own context,
+// such as retrieved documents, can insert it before the last message.
+func (mm *MemoryManager) ContextMessages() []openai.ChatCompletionMessage {
+	messages := []openai.ChatCompletionMessage{{
+		Role:    openai.ChatMessageRoleSystem,
+		Content: mm.buildSystemPrompt(),
+	}}
+	for _, msg := range mm.contextWindow.Messages {
+		if ids, ok := msg.Metadata["facts"].([]string); ok {
+			mm.markReferenced(ids)
+		}
+		messages = append(messages, openai.ChatCompletionMessage{
+			Role:    msg.Role,
+			Content: msg.Content,
+		})
+	}
+	return messages
+}
+
+// promptFactConfidence is the confidence a fact needs to be put in a prompt
+const promptFactConfidence = 0.7
+
+// buildSystemPrompt creates a context-aware system prompt, marking the facts
+// it lists as referenced
+func (mm *MemoryManager) buildSystemPrompt() string {
+	prompt, listed := mm.systemPrompt(time.Now())
+	mm.markReferenced(listed)
+	return prompt
+}
+
+// systemPrompt returns the system prompt and the IDs of the facts it lists.
+// Facts are left out when the context strategy pins them in the window.
+func (mm *MemoryManager) systemPrompt(now time.Time) (string, []string) {
+	basePrompt := "You are a helpful AI assistant with memory of our conversation history."
+	var listed []string
+
+	// Add user information if available
+	if len(mm.userMemory.Facts) > 0 && !mm.pinsFacts() {
+		basePrompt += "\n\n" + pinnedFactsHeader
+		for _, fact := range mm.userMemory.Facts {
+			if decayedConfidence(fact, now) > promptFactConfidence {
+				basePrompt += fmt.Sprintf("\n- %s", fact.Fact)
+				listed = append(listed, fact.ID)
+			}
+		}
+	}
+
+	// Add user preferences
+	if len(mm.userMemory.Preferences) > 0 {
+		basePrompt += "\n\nYour preferences:"
+		for key, value := range mm.userMemory.Preferences {
+			basePrompt += fmt.Sprintf("\n- %s: %v", key, value)
+		}
+	}
+
+	return basePrompt, listed
+}
+
+// markReferenced records that the facts with ids were just put in a prompt
+func (mm *MemoryManager) markReferenced(ids []string) {
+	now := time.Now()
+	for _, id := range ids {
+		for i := range mm.userMemory.Facts {
+			if mm.userMemory.Facts[i].ID == id {
+				mm.userMemory.Facts[i].LastReferenced = now
+			}
+		}
+	}
+}
+
+// GetMemoryStats returns statistics about the memory system
+func (mm *MemoryManager) GetMemoryStats() map[string]interface{} {
+	return map[string]interface{}{
+		"total_messages":       len(mm.conversationHistory),
+		"summaries_created":    len(mm.summaries),
+		"facts_learned":        len(mm.userMemory.Facts),
+		"context_window_usage": fmt.Sprintf("%d/%d tokens", mm.contextWindow.TokensUsed, mm.contextWindow.TokenLimit),
+		"context_strategy":     mm.contextStrategy().Name(),
+		"user_sessions":        mm.userMemory.Sessions,
+		"last_interaction":     mm.userMemory.LastSeen.Format("2006-01-02 15:04:05"),
+	}
+}
+
+// GetConversationHistory returns the current conversation history
+func (mm *MemoryManager) GetConversationHistory() []Message {
+	return mm.conversationHistory
+}
+
+// GetUserFacts returns learned facts about the user
+func (mm *MemoryManager) GetUserFacts() []MemoryFact {
+	return mm.userMemory.Facts
+}
+
+// ClearMemory resets the memory system
+func (mm *MemoryManager) ClearMemory() {
+	mm.conversationHistory = make([]Message, 0)
+	mm.summaries = make([]ConversationSummary, 0)
+	mm.userMemory.Facts = make([]MemoryFact, 0)
+	mm.updateContextWindow()
+}
+
+// configKeys lists the settings this day reads, for `config show` and
+// `config validate`
+var configKeys = settings.Common.With(
+	settings.Key{Name: "MEMORY_DIR", Kind: settings.String},
+	settings.Key{Name: "MEMORY_STORE", Kind: settings.String},
+	settings.Key{Name: "MEMORY_CONTEXT", Kind: settings.String},
+	settings.Key{Name: "MEMORY_PII", Kind: settings.String},
+	settings.Key{Name: "MEMORY_PII_LLM", Kind: settings.Bool},
+	settings.Key{Name: "ONBOARDING_FILE", Kind: settings.String},
+)
+
+// Main loads .env and the config file, then runs the context and memory
+// demo. args is the command line after the program name, e.g. "config show".
+func Main(args []string) {
+	// Load environment variables, then the config file's CONFIG_PROFILE,
+	// which doesn't replace them
+	envErr := godotenv.Load()
+	run(args, envErr, settings.Load())
+}
+
+// Run runs like Main in an environment the caller has already loaded .env
+// and the config file into, as the agentic command does
+func Run(args []string) {
+	run(args, nil, nil)
+}
+
+// run reports what loading .env and the config file found, then runs the
+// demo
+func run(args []string, envErr, settingsErr error) {
+	// LOG_LEVEL and LOG_FORMAT may come from .env or the config file
+	logging.Setup(slog.LevelWarn)
+	if envErr != nil {
+		slog.Info("no .env file found, using system environment variables")
+	}
+	if settingsErr != nil {
+		logging.Fatal("invalid config file", "error", settingsErr)
+	}
+	// "config show" and "config validate" report the settings instead of running
+	if run, err := configKeys.Start(args, os.Stdout); err != nil {
+		logging.Fatal("invalid configuration", "error", err)
+	} else if !run {
+		return
+	}
+
+	// OPENAI_BASE_URL and OPENAI_API_TYPE point the client at Azure OpenAI
+	// or an OpenAI-compatible server such as vLLM; LLM_PROVIDER=ollama runs
+	// fully offline on local models
+	api, err := endpoint.FromEnv()
+	if err != nil {
+		logging.Fatal("invalid endpoint", "error", err)
+	}
+
+	// Get OpenAI API key
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	if apiKey == "" && api.RequiresKey() {
+		logging.Fatal("OPENAI_API_KEY environment variable is required")
+	}
+
+	if err := api.CheckModels(context.Background()); err != nil {
+		logging.Fatal("local models unavailable", "error", err)
+	}
+
+	// Create memory manager for a user
+	userID := "demo_user_001"
+	// Budgets, the cost ledger and prices come from BUDGET, BUDGET_DOWNGRADES,
+	// COST_LEDGER_PATH and PRICING_PATH
+	limiter, err := costs.FromEnv()
+	if err != nil {
+		logging.Fatal("invalid budget", "error", err)
+	}
+	memoryManager := NewMemoryManager(apiKey, api, userID, limiter)
+	// MEMORY_PII is off, tag or redact; MEMORY_PII_LLM=true adds LLM detection
+	if memoryManager.config.PIIMode, err = ParsePIIMode(os.Getenv("MEMORY_PII")); err != nil {
+		logging.Fatal("invalid MEMORY_PII", "error", err)
+	}
+	memoryManager.config.PIIAssist = os.Getenv("MEMORY_PII_LLM") == "true"
+	// MEMORY_CONTEXT is recency, relevance or hybrid
+	if memoryManager.config.ContextStrategy, err = ParseContextStrategy(os.Getenv("MEMORY_CONTEXT")); err != nil {
+		logging.Fatal("invalid MEMORY_CONTEXT", "error", err)
+	}
+	ctx := context.Background()
+
+	fmt.Println("🧠 Context Management & Memory System")
+	fmt.Println("=====================================")
+	fmt.Printf("User ID: %s\n", userID)
+	fmt.Printf("Memory Config: %d messages, %d tokens max\n",
+		memoryManager.config.MaxMessages, memoryManager.config.MaxTokens)
+	fmt.Println()
+
+	// Restore persisted memory; its absence means this is a first-time user
+	memoryDir := os.Getenv("MEMORY_DIR")
+	if memoryDir == "" {
+		memoryDir = defaultMemoryDir
+	}
+	store, closeStore, err := openMemoryStore(os.Getenv("MEMORY_STORE"), memoryDir, userID)
+	if err != nil {
+		logging.Fatal("failed to open memory store", "error", err)
+	}
+	defer closeStore()
+	returning, err := memoryManager.UseStore(store)
+	if err != nil {
+		logging.Fatal("failed to load user memory", "error", err)
+	}
+
+	onboarding := DefaultOnboarding()
+	if path := os.Getenv("ONBOARDING_FILE"); path != "" {
+		if onboarding, err = LoadOnboardingConfig(path); err != nil {
+			logging.Fatal("failed to load onboarding config", "error", err)
+		}
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+
+	if returning {
+		fmt.Println(welcomeBack(memoryManager, onboarding))
+		fmt.Println()
+	} else {
+		runOnboarding(scanner, memoryManager, onboarding)
+		if err := memoryManager.Flush(); err != nil {
+			slog.Error("failed to save user memory", "error", err)
+		}
+	}
+
+	fmt.Println("💡 This AI assistant has memory! Try:")
+	fmt.Println("- Tell it your name and preferences")
+	fmt.Println("- Ask follow-up questions")
+	fmt.Println("- Reference things you mentioned earlier")
+	fmt.Println("- Have a long conversation to see summarization")
+	fmt.Println()
+	fmt.Println("Commands: 'stats' for memory info, 'facts' for learned facts, 'clear' to reset, 'forget' to delete everything about you, 'quit' to exit")
+	fmt.Println("Fact analytics: 'facts categories|timeline|confidence|stale [days]', 'facts confirm|delete <n,...|stale>'")
+	fmt.Println()
+
+	for {
+		fmt.Print("You: ")
+		if !scanner.Scan() {
+			break
+		}
+
+		input := strings.TrimSpace(scanner.Text())
+		if input == "" {
+			continue
+		}
+
+		if strings.ToLower(input) == "quit" {
+			fmt.Println("👋 Goodbye! Your memories are preserved for next time.")
+			break
+		}
+
+		if strings.ToLower(input) == "stats" {
+			stats := memoryManager.GetMemoryStats()
+			fmt.Println("\n📊 Memory Statistics:")
+			for key, value := range stats {
+				fmt.Printf("  %s: %v\n", key, value)
+			}
+			fmt.Println()
+			continue
+		}
+
+		if strings.ToLower(input) == "facts" {
+			facts := memoryManager.GetUserFacts()
+			fmt.Printf("\n🧠 Facts I've learned about you (%d):\n", len(facts))
+			for i, fact := range facts {
+				fmt.Printf("  %d. %s (confidence: %.2f)", i+1, fact.Fact, decayedConfidence(fact, time.Now()))
+				if kinds := PIIKinds(fact.Metadata); len(kinds) > 0 {
+					fmt.Printf(" 🔒 %s", strings.Join(kinds, ", "))
+				}
+				fmt.Println()
+			}
+			fmt.Println()
+			continue
+		}
+
+		if fields := strings.Fields(input); len(fields) > 1 && strings.ToLower(fields[0]) == "facts" {
+			handleFactsCommand(memoryManager, fields[1:])
+			if err := memoryManager.Flush(); err != nil {
+				slog.Error("failed to save user memory", "error", err)
+			}
+			continue
+		}
+
+		if strings.ToLower(input) == "forget" {
+			if err := memoryManager.ForgetUser(userID); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			fmt.Println("🗑️ Everything about you has been deleted: profile, facts, summaries and history.")
+			continue
+		}
+
+		if strings.ToLower(input) == "clear" {
+			memoryManager.ClearMemory()
+			if err := memoryManager.Flush(); err != nil {
+				slog.Error("failed to save user memory", "error", err)
+			}
+			fmt.Println("🗑️ Memory cleared!")
+			continue
+		}
+
+		// Process chat message
+		response, err := memoryManager.Chat(ctx, input)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			continue
+		}
+
+		fmt.Printf("AI: %s\n\n", response)
+
+		// Show memory update if facts were learned
+		currentFacts := len(memoryManager.GetUserFacts())
+		if currentFacts > 0 {
+			fmt.Printf("💭 [Learned %d facts about you so far]\n\n", currentFacts)
+		}
+	}
+
+	if err := memoryManager.Flush(); err != nil {
+		slog.Error("failed to save user memory", "error", err)
+	}
+
+	// Final memory statistics
+	fmt.Println("\n📊 Final Session Statistics:")
+	stats := memoryManager.GetMemoryStats()
+	for key, value := range stats {
+		fmt.Printf("  %s: %v\n", key, value)
+	}
+
+	if err := scanner.Err(); err != nil {
+		slog.Error("failed to read input", "error", err)
+	}
+}
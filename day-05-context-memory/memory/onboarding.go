@@ -0,0 +1,145 @@
+package memory
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// OnboardingQuestion is one profile question asked of a first-time user.
+// Target is "profile" or "preference" and decides where the answer is stored;
+// Category is the fact category of profile answers.
+type OnboardingQuestion struct {
+	Key      string `json:"key"`
+	Prompt   string `json:"prompt"`
+	Target   string `json:"target"`
+	Category string `json:"category"`
+}
+
+// OnboardingConfig controls the greeting and questions for new users
+type OnboardingConfig struct {
+	Greeting string `json:"greeting"`
+	// WelcomeBack greets returning users; %s is replaced by their name
+	WelcomeBack string               `json:"welcome_back"`
+	Questions   []OnboardingQuestion `json:"questions"`
+}
+
+// DefaultOnboarding asks for name, role, preferred language and answer style
+func DefaultOnboarding() OnboardingConfig {
+	return OnboardingConfig{
+		Greeting:    "👋 Welcome! Let's get to know each other. Press Enter to skip any question.",
+		WelcomeBack: "👋 Welcome back, %s!",
+		Questions: []OnboardingQuestion{
+			{Key: "name", Prompt: "What should I call you?", Target: "profile", Category: "identity"},
+			{Key: "role", Prompt: "What do you do (e.g. student, backend developer)?", Target: "profile", Category: "work"},
+			{Key: "language", Prompt: "Which language should I answer in?", Target: "preference"},
+			{Key: "style", Prompt: "How do you like answers: brief, detailed, or step-by-step?", Target: "preference"},
+		},
+	}
+}
+
+// LoadOnboardingConfig reads an onboarding config from a JSON file. Missing
+// greetings fall back to the defaults.
+func LoadOnboardingConfig(path string) (OnboardingConfig, error) {
+	config := DefaultOnboarding()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("failed to read onboarding config: %w", err)
+	}
+
+	var custom OnboardingConfig
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return config, fmt.Errorf("failed to parse onboarding config: %w", err)
+	}
+	for _, q := range custom.Questions {
+		if q.Key == "" || q.Prompt == "" {
+			return config, fmt.Errorf("onboarding questions need a key and a prompt")
+		}
+		if q.Target != "profile" && q.Target != "preference" {
+			return config, fmt.Errorf("question '%s': target must be 'profile' or 'preference'", q.Key)
+		}
+	}
+
+	if custom.Greeting != "" {
+		config.Greeting = custom.Greeting
+	}
+	if custom.WelcomeBack != "" {
+		config.WelcomeBack = custom.WelcomeBack
+	}
+	if len(custom.Questions) > 0 {
+		config.Questions = custom.Questions
+	}
+	return config, nil
+}
+
+// ApplyOnboarding seeds the user's profile, preferences and facts from the
+// onboarding answers. Profile answers also become confirmed facts; empty
+// answers are skipped.
+func (mm *MemoryManager) ApplyOnboarding(config OnboardingConfig, answers map[string]string) {
+	for _, q := range config.Questions {
+		answer := strings.TrimSpace(answers[q.Key])
+		if answer == "" {
+			continue
+		}
+
+		// Preferences already reach the system prompt on their own
+		if q.Target == "preference" {
+			mm.userMemory.Preferences[q.Key] = answer
+			continue
+		}
+		mm.userMemory.Profile[q.Key] = answer
+
+		category := q.Category
+		if category == "" {
+			category = "personal"
+		}
+		// Stated directly by the user, so the fact is confirmed and fully trusted
+		fact := MemoryFact{
+			ID:         fmt.Sprintf("fact_%d", time.Now().UnixNano()),
+			Fact:       fmt.Sprintf("%s: %s", q.Key, answer),
+			Confidence: 1.0,
+			Source:     "onboarding",
+			Timestamp:  time.Now(),
+			Category:   category,
+			Metadata:   make(map[string]interface{}),
+			Confirmed:  true,
+		}
+		mm.tagPII(fact.Fact, fact.Metadata, true)
+		mm.userMemory.Facts = append(mm.userMemory.Facts, fact)
+	}
+}
+
+// runOnboarding asks the onboarding questions on the console and seeds memory
+func runOnboarding(scanner *bufio.Scanner, mm *MemoryManager, config OnboardingConfig) {
+	fmt.Println(config.Greeting)
+
+	answers := make(map[string]string)
+	for _, q := range config.Questions {
+		fmt.Printf("❓ %s ", q.Prompt)
+		if !scanner.Scan() {
+			break
+		}
+		answers[q.Key] = scanner.Text()
+	}
+
+	mm.ApplyOnboarding(config, answers)
+	if name, ok := mm.userMemory.Profile["name"]; ok {
+		fmt.Printf("✅ Nice to meet you, %v! I'll remember this next time.\n\n", name)
+	} else {
+		fmt.Println("✅ All set! I'll remember this next time.")
+		fmt.Println()
+	}
+}
+
+// welcomeBack returns the greeting for a returning user
+func welcomeBack(mm *MemoryManager, config OnboardingConfig) string {
+	name, ok := mm.userMemory.Profile["name"]
+	if !ok {
+		name = "friend"
+	}
+	return fmt.Sprintf(config.WelcomeBack, name)
+}
@@ -0,0 +1,214 @@
+package memory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sakibmulla/agentic-ai/persist"
+)
+
+// defaultMemoryDir is where user memories are stored when MEMORY_DIR is unset
+const defaultMemoryDir = "./memory"
+
+// MemoryRecord is everything kept about a user between sessions
+type MemoryRecord struct {
+	User      *UserMemory           `json:"user"`
+	Summaries []ConversationSummary `json:"summaries"`
+	History   []Message             `json:"history"`
+}
+
+// MemoryStore persists each user's memory between sessions
+type MemoryStore interface {
+	// Load returns the user's saved memory, or nil if there is none
+	Load(userID string) (*MemoryRecord, error)
+	// Save replaces the user's saved memory
+	Save(userID string, record *MemoryRecord) error
+	// Delete removes the user's saved memory; deleting none is not an error
+	Delete(userID string) error
+}
+
+// memoryRecordFormat versions memory files. Version 1 files, from before
+// summaries and history were kept, hold only the user memory; files from
+// before versioning are read as version 1 too.
+var memoryRecordFormat = persist.NewFormat("user-memory", 2).Migrate(1, migrateMemoryV1)
+
+// migrateMemoryV1 wraps a user memory in a record with no summaries or history
+func migrateMemoryV1(data json.RawMessage) (json.RawMessage, error) {
+	return json.Marshal(map[string]json.RawMessage{"user": data})
+}
+
+// FileMemoryStore keeps each user's memory in a JSON file named after the user
+type FileMemoryStore struct {
+	dir string
+}
+
+// NewFileMemoryStore creates a store keeping files in dir, which is created
+// on the first save
+func NewFileMemoryStore(dir string) *FileMemoryStore {
+	return &FileMemoryStore{dir: dir}
+}
+
+// path returns the file holding a user's memory
+func (s *FileMemoryStore) path(userID string) string {
+	return filepath.Join(s.dir, userID+".json")
+}
+
+// Load reads the user's memory file, upgrading one written by an older
+// release in place
+func (s *FileMemoryStore) Load(userID string) (*MemoryRecord, error) {
+	var record MemoryRecord
+	err := memoryRecordFormat.ReadFile(s.path(userID), &record)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Save writes the user's memory file atomically, so a crash never leaves it
+// half written
+func (s *FileMemoryStore) Save(userID string, record *MemoryRecord) error {
+	return memoryRecordFormat.WriteFile(s.path(userID), record, 0644)
+}
+
+// Delete removes the user's memory file
+func (s *FileMemoryStore) Delete(userID string) error {
+	if err := os.Remove(s.path(userID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// openMemoryStore opens the store kind names in dir: sqlite (the default)
+// keeps every user in dir/memory.db, first importing the user's JSON file
+// from an older release, and file keeps one JSON file per user. The
+// returned function closes the store.
+func openMemoryStore(kind, dir, userID string) (MemoryStore, func() error, error) {
+	files := NewFileMemoryStore(dir)
+	switch kind {
+	case "", StoreSQLite:
+		db, err := OpenSQLiteMemoryStore(filepath.Join(dir, "memory.db"))
+		if err != nil {
+			return nil, nil, err
+		}
+		if imported, err := ImportMemory(files, db, userID); err != nil {
+			db.Close()
+			return nil, nil, fmt.Errorf("failed to import %s: %w", files.path(userID), err)
+		} else if imported {
+			slog.Info("imported user memory into the database", "user", userID, "from", files.path(userID))
+		}
+		return db, db.Close, nil
+	case StoreFile:
+		return files, func() error { return nil }, nil
+	default:
+		return nil, nil, fmt.Errorf("invalid MEMORY_STORE %q: use sqlite or file", kind)
+	}
+}
+
+// UseStore restores the user's memory from store and saves every change to
+// it from then on. It returns false when the user has no saved memory yet.
+func (mm *MemoryManager) UseStore(store MemoryStore) (bool, error) {
+	mm.store = store
+
+	record, err := store.Load(mm.userMemory.UserID)
+	if err != nil {
+		return false, fmt.Errorf("failed to load user memory: %w", err)
+	}
+	if record == nil || record.User == nil {
+		return false, nil
+	}
+
+	memory := record.User
+	if memory.Profile == nil {
+		memory.Profile = make(map[string]interface{})
+	}
+	if memory.Preferences == nil {
+		memory.Preferences = make(map[string]interface{})
+	}
+	memory.Sessions++
+	memory.LastSeen = time.Now()
+
+	mm.userMemory = memory
+	mm.summaries = append(make([]ConversationSummary, 0, len(record.Summaries)), record.Summaries...)
+	mm.conversationHistory = append(make([]Message, 0, len(record.History)), record.History...)
+	// Counts saved by older releases were len/4 estimates
+	for i := range mm.conversationHistory {
+		mm.conversationHistory[i].TokensUsed = mm.messageTokens(mm.conversationHistory[i].Content)
+	}
+	mm.enforceRetention()
+	mm.updateContextWindow()
+	return true, nil
+}
+
+// Flush saves the user's memory, summaries and recent messages to the store,
+// after dropping whatever is past retention and, in PIIRedact mode,
+// redacting personal data
+func (mm *MemoryManager) Flush() error {
+	if mm.store == nil {
+		return nil
+	}
+
+	mm.enforceRetention()
+	mm.userMemory.LastSeen = time.Now()
+	record := &MemoryRecord{
+		User:      mm.userMemory,
+		Summaries: mm.summaries,
+		History:   mm.conversationHistory,
+	}
+	if mm.config.PIIMode == PIIRedact {
+		record = mm.redactedRecord()
+	}
+	if err := mm.store.Save(mm.userMemory.UserID, record); err != nil {
+		return fmt.Errorf("failed to save user memory: %w", err)
+	}
+	return nil
+}
+
+// flushOrLog flushes for callers that can't return an error
+func (mm *MemoryManager) flushOrLog() {
+	if err := mm.Flush(); err != nil {
+		slog.Error("failed to save user memory", "user", mm.userMemory.UserID, "error", err)
+	}
+}
+
+// enforceRetention forgets facts whose confidence has decayed, and
+// messages, summaries and stale unconfirmed facts older than
+// MemoryRetentionDays. Zero or less keeps those.
+func (mm *MemoryManager) enforceRetention() {
+	mm.expireFacts()
+
+	days := mm.config.MemoryRetentionDays
+	if days <= 0 {
+		return
+	}
+	cutoff := time.Now().AddDate(0, 0, -days)
+
+	var stale []string
+	for _, fact := range mm.StaleFacts(days) {
+		stale = append(stale, fact.ID)
+	}
+	mm.DeleteFacts(stale)
+
+	summaries := mm.summaries[:0]
+	for _, summary := range mm.summaries {
+		if !summary.EndTime.Before(cutoff) {
+			summaries = append(summaries, summary)
+		}
+	}
+	mm.summaries = summaries
+
+	history := mm.conversationHistory[:0]
+	for _, message := range mm.conversationHistory {
+		if !message.Timestamp.Before(cutoff) {
+			history = append(history, message)
+		}
+	}
+	mm.conversationHistory = history
+}
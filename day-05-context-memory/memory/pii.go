@@ -0,0 +1,250 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/sakibmulla/agentic-ai/guardrails"
+	"github.com/sashabaranov/go-openai"
+)
+
+// PIIMode is what memory does with personal data before it is saved
+type PIIMode string
+
+const (
+	// PIIOff saves everything as it was said
+	PIIOff PIIMode = "off"
+	// PIITag saves everything, but marks facts, messages and summaries
+	// holding personal data with its kinds
+	PIITag PIIMode = "tag"
+	// PIIRedact tags, and saves the personal data as placeholders such as
+	// [EMAIL]. The current session still sees it as said.
+	PIIRedact PIIMode = "redact"
+)
+
+// ParsePIIMode parses MEMORY_PII; empty means tag
+func ParsePIIMode(value string) (PIIMode, error) {
+	switch mode := PIIMode(strings.ToLower(strings.TrimSpace(value))); mode {
+	case "":
+		return PIITag, nil
+	case PIIOff, PIITag, PIIRedact:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid PII mode %q: use off, tag or redact", value)
+	}
+}
+
+// piiMetadataKey is the metadata key holding the kinds of personal data
+// found in a fact or message
+const piiMetadataKey = "pii"
+
+// piiAssistPrompt asks the LLM for the personal data the patterns miss
+const piiAssistPrompt = `List the personal data about a private individual in the text below: names, home or street addresses, birth dates, phone numbers, emails, account or ID numbers, health details. Ignore public figures, companies and places that aren't someone's address.
+
+Respond with JSON only: {"pii": [{"text": "...", "kind": "..."}]}
+- text: the exact words as they appear in the text
+- kind: one of name, address, birth_date, phone, email, id_number, health, other
+Use {"pii": []} when there is none.`
+
+// scanPII finds the personal data in text: FindPII's patterns, values the
+// LLM flagged before, and, when assist is set and PIIAssist is on, what the
+// LLM finds now. LLM findings are remembered for this session only, so
+// their later mentions are caught without another call.
+func (mm *MemoryManager) scanPII(ctx context.Context, text string, assist bool) []guardrails.PIIMatch {
+	if mm.config.PIIMode == PIIOff || mm.config.PIIMode == "" {
+		return nil
+	}
+	matches := guardrails.FindPII(text)
+
+	if assist && mm.config.PIIAssist {
+		found, err := mm.assistPII(ctx, text)
+		if err != nil {
+			slog.Warn("PII assist failed, using patterns only", "error", err)
+		}
+		for _, match := range found {
+			mm.piiValues[match.Text] = match.Kind
+		}
+	}
+
+	for value, kind := range mm.piiValues {
+		for offset := 0; ; {
+			i := strings.Index(text[offset:], value)
+			if i < 0 {
+				break
+			}
+			start := offset + i
+			matches = addMatch(matches, guardrails.PIIMatch{Kind: kind, Start: start, End: start + len(value), Text: value})
+			offset = start + len(value)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Start < matches[j].Start })
+	return matches
+}
+
+// addMatch adds match unless it overlaps one already found
+func addMatch(matches []guardrails.PIIMatch, match guardrails.PIIMatch) []guardrails.PIIMatch {
+	for _, m := range matches {
+		if match.Start < m.End && m.Start < match.End {
+			return matches
+		}
+	}
+	return append(matches, match)
+}
+
+// assistPII asks the LLM for the personal data in text
+func (mm *MemoryManager) assistPII(ctx context.Context, text string) ([]guardrails.PIIMatch, error) {
+	resp, err := mm.client.CreateChatCompletion(mm.spendOn(ctx), openai.ChatCompletionRequest{
+		Model: openai.GPT3Dot5Turbo,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: piiAssistPrompt},
+			{Role: openai.ChatMessageRoleUser, Content: text},
+		},
+		ResponseFormat: &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject},
+		Temperature:    0,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("PII assist call failed: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("no response choices returned")
+	}
+
+	var result struct {
+		PII []guardrails.PIIMatch `json:"pii"`
+	}
+	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &result); err != nil {
+		return nil, fmt.Errorf("failed to parse PII: %w", err)
+	}
+
+	// Only values that really are in the text; anything else is invented
+	found := result.PII[:0]
+	for _, match := range result.PII {
+		match.Text = strings.TrimSpace(match.Text)
+		if len(match.Text) < 3 || !strings.Contains(text, match.Text) {
+			continue
+		}
+		if match.Kind == "" {
+			match.Kind = "other"
+		}
+		found = append(found, match)
+	}
+	return found, nil
+}
+
+// piiKinds returns the distinct kinds in matches, sorted
+func piiKinds(matches []guardrails.PIIMatch) []string {
+	seen := make(map[string]bool)
+	var kinds []string
+	for _, match := range matches {
+		if !seen[match.Kind] {
+			seen[match.Kind] = true
+			kinds = append(kinds, match.Kind)
+		}
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// tagPII records the kinds of personal data in text under metadata's "pii"
+// key, and reports whether there was any
+func (mm *MemoryManager) tagPII(text string, metadata map[string]interface{}, assist bool) bool {
+	kinds := piiKinds(mm.scanPII(context.Background(), text, assist))
+	if len(kinds) == 0 {
+		return false
+	}
+	metadata[piiMetadataKey] = kinds
+	return true
+}
+
+// PIIKinds returns the kinds of personal data a fact or message was tagged
+// with. Tags read back from a saved file are []interface{}.
+func PIIKinds(metadata map[string]interface{}) []string {
+	switch kinds := metadata[piiMetadataKey].(type) {
+	case []string:
+		return kinds
+	case []interface{}:
+		var names []string
+		for _, kind := range kinds {
+			if name, ok := kind.(string); ok {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+// redact returns text with its personal data replaced by placeholders
+func (mm *MemoryManager) redact(text string) string {
+	matches := mm.scanPII(context.Background(), text, false)
+	if len(matches) == 0 {
+		return text
+	}
+	return guardrails.RedactPII(text, matches)
+}
+
+// redactedRecord returns a copy of the memory to save with personal data
+// redacted. Embeddings of redacted text are dropped, since they still
+// encode what was removed; facts and summaries are embedded again when
+// next needed.
+func (mm *MemoryManager) redactedRecord() *MemoryRecord {
+	user := *mm.userMemory
+	user.Facts = make([]MemoryFact, len(mm.userMemory.Facts))
+	for i, fact := range mm.userMemory.Facts {
+		if redacted := mm.redact(fact.Fact); redacted != fact.Fact {
+			fact.Fact = redacted
+			fact.Embedding = nil
+		}
+		user.Facts[i] = fact
+	}
+
+	summaries := make([]ConversationSummary, len(mm.summaries))
+	for i, summary := range mm.summaries {
+		if redacted := mm.redact(summary.Summary); redacted != summary.Summary {
+			summary.Summary = redacted
+			summary.Embedding = nil
+		}
+		summary.ImportantFacts = make([]string, len(mm.summaries[i].ImportantFacts))
+		for j, fact := range mm.summaries[i].ImportantFacts {
+			summary.ImportantFacts[j] = mm.redact(fact)
+		}
+		summaries[i] = summary
+	}
+
+	history := make([]Message, len(mm.conversationHistory))
+	for i, message := range mm.conversationHistory {
+		message.Content = mm.redact(message.Content)
+		history[i] = message
+	}
+	return &MemoryRecord{User: &user, Summaries: summaries, History: history}
+}
+
+// ForgetUser deletes everything kept about a user: their saved profile,
+// preferences, facts, summaries and history. Forgetting the manager's own
+// user also clears what it holds in memory, so none of it is saved again.
+func (mm *MemoryManager) ForgetUser(userID string) error {
+	if userID == mm.userMemory.UserID {
+		mm.userMemory = &UserMemory{
+			UserID:      userID,
+			Profile:     make(map[string]interface{}),
+			Preferences: make(map[string]interface{}),
+			Facts:       make([]MemoryFact, 0),
+			LastSeen:    mm.userMemory.LastSeen,
+			Sessions:    1,
+		}
+		mm.ClearMemory()
+		mm.queryEmbedding = nil
+		mm.piiValues = make(map[string]string)
+	}
+	if mm.store == nil {
+		return nil
+	}
+	if err := mm.store.Delete(userID); err != nil {
+		return fmt.Errorf("failed to delete user memory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package memory
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sakibmulla/agentic-ai/endpoint"
+	"github.com/sashabaranov/go-openai"
+)
+
+// newTestManager returns a manager whose API calls go to a fake server: the
+// PII assist call finds "Rosewood Clinic" and embeddings are unavailable
+func newTestManager(t *testing.T, mode PIIMode) *MemoryManager {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/embeddings") {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":{"message":"no embeddings in tests"}}`)
+			return
+		}
+		content := `{\"pii\":[{\"text\":\"Rosewood Clinic\",\"kind\":\"health\"},{\"text\":\"invented\",\"kind\":\"name\"}]}`
+		fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":"%s"}}]}`, content)
+	}))
+	t.Cleanup(server.Close)
+
+	mm := NewMemoryManager("test-key", endpoint.Endpoint{}, "alice", nil)
+	config := openai.DefaultConfig("test-key")
+	config.BaseURL = server.URL + "/v1"
+	mm.client = openai.NewClientWithConfig(config)
+	mm.config.PIIMode = mode
+	mm.config.PIIAssist = true
+	return mm
+}
+
+func TestPIIRedactedBeforeSaving(t *testing.T) {
+	mm := newTestManager(t, PIIRedact)
+	store := NewFileMemoryStore(t.TempDir())
+	if _, err := mm.UseStore(store); err != nil {
+		t.Fatal(err)
+	}
+
+	mm.storeFact(extractedFact{Fact: "The user's email is alice@example.com", Category: "identity", Confidence: 0.9}, "test")
+	mm.storeFact(extractedFact{Fact: "The user is treated at Rosewood Clinic", Category: "personal", Confidence: 0.9}, "test")
+	mm.AddMessage("user", "I go to Rosewood Clinic on Mondays")
+
+	facts := mm.GetUserFacts()
+	if kinds := PIIKinds(facts[0].Metadata); len(kinds) != 1 || kinds[0] != "email" {
+		t.Errorf("Expected the email fact tagged, got %v", kinds)
+	}
+	if kinds := PIIKinds(facts[1].Metadata); len(kinds) != 1 || kinds[0] != "health" {
+		t.Errorf("Expected the LLM's finding tagged, got %v", kinds)
+	}
+	if !strings.Contains(facts[0].Fact, "alice@example.com") {
+		t.Error("Expected the session to keep the fact as stated")
+	}
+
+	data, err := os.ReadFile(filepath.Join(store.dir, "alice.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"alice@example.com", "Rosewood Clinic"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("Expected %q redacted from the saved memory", secret)
+		}
+	}
+	if !strings.Contains(string(data), "I go to [HEALTH] on Mondays") {
+		t.Errorf("Expected the LLM's finding redacted from later messages too, got %s", data)
+	}
+}
+
+func TestForgetUser(t *testing.T) {
+	mm := newTestManager(t, PIITag)
+	store := NewFileMemoryStore(t.TempDir())
+	mm.UseStore(store)
+	mm.userMemory.Profile["name"] = "Alice"
+	mm.storeFact(extractedFact{Fact: "My name is Alice Jones", Confidence: 0.9}, "test")
+	if err := mm.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Tag mode keeps the text but marks it
+	record, _ := store.Load("alice")
+	if kinds := PIIKinds(record.User.Facts[0].Metadata); len(kinds) != 1 || kinds[0] != "name" {
+		t.Errorf("Expected the saved fact tagged with its kinds, got %v", kinds)
+	}
+
+	if err := mm.ForgetUser("alice"); err != nil {
+		t.Fatalf("ForgetUser failed: %v", err)
+	}
+	if record, err := store.Load("alice"); record != nil || err != nil {
+		t.Errorf("Expected the saved memory deleted, got %v, %v", record, err)
+	}
+	if len(mm.GetUserFacts()) != 0 || len(mm.userMemory.Profile) != 0 {
+		t.Error("Expected the memory held in the session cleared")
+	}
+	if err := mm.ForgetUser("nobody"); err != nil {
+		t.Errorf("Expected forgetting an unknown user to succeed, got %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+package memory
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// memorySchemaVersion is stored as the database's user_version, so a later
+// schema change can tell which databases to migrate
+const memorySchemaVersion = 1
+
+// memorySchema keeps one row per user. The record is the same versioned
+// JSON a FileMemoryStore writes, so its migrations apply to both stores.
+const memorySchema = `
+CREATE TABLE IF NOT EXISTS memories (
+	user_id    TEXT    PRIMARY KEY,
+	record     TEXT    NOT NULL,
+	updated_at INTEGER NOT NULL
+);
+`
+
+// Memory store kinds MEMORY_STORE selects
+const (
+	StoreSQLite = "sqlite"
+	StoreFile   = "file"
+)
+
+// SQLiteMemoryStore keeps every user's memory in one SQLite database
+type SQLiteMemoryStore struct {
+	db *sql.DB
+}
+
+// OpenSQLiteMemoryStore opens (or creates) the memory database at path
+func OpenSQLiteMemoryStore(path string) (*SQLiteMemoryStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create memory directory: %w", err)
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open memory database: %w", err)
+	}
+	// SQLite allows one writer; a single connection avoids "database is locked"
+	db.SetMaxOpenConns(1)
+
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to read memory database: %w", err)
+	}
+	if version > memorySchemaVersion {
+		db.Close()
+		return nil, fmt.Errorf("memory database %s has schema version %d, newer than this release's %d", path, version, memorySchemaVersion)
+	}
+	if _, err := db.Exec(memorySchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create memory tables: %w", err)
+	}
+	if _, err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", memorySchemaVersion)); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to set memory schema version: %w", err)
+	}
+	return &SQLiteMemoryStore{db: db}, nil
+}
+
+// Close closes the database
+func (s *SQLiteMemoryStore) Close() error {
+	return s.db.Close()
+}
+
+// Load reads the user's row, upgrading one written by an older release in place
+func (s *SQLiteMemoryStore) Load(userID string) (*MemoryRecord, error) {
+	var data []byte
+	err := s.db.QueryRow(`SELECT record FROM memories WHERE user_id = ?`, userID).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read memory of %s: %w", userID, err)
+	}
+
+	var record MemoryRecord
+	upgraded, err := memoryRecordFormat.Unmarshal(data, &record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode memory of %s: %w", userID, err)
+	}
+	if upgraded {
+		if err := s.Save(userID, &record); err != nil {
+			return nil, err
+		}
+	}
+	return &record, nil
+}
+
+// Save replaces the user's row in one statement, so a crash never leaves it
+// half written
+func (s *SQLiteMemoryStore) Save(userID string, record *MemoryRecord) error {
+	data, err := memoryRecordFormat.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode memory of %s: %w", userID, err)
+	}
+	_, err = s.db.Exec(`INSERT INTO memories (user_id, record, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET record = excluded.record, updated_at = excluded.updated_at`,
+		userID, data, time.Now().UnixNano())
+	if err != nil {
+		return fmt.Errorf("failed to save memory of %s: %w", userID, err)
+	}
+	return nil
+}
+
+// Delete removes the user's row
+func (s *SQLiteMemoryStore) Delete(userID string) error {
+	if _, err := s.db.Exec(`DELETE FROM memories WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to delete memory of %s: %w", userID, err)
+	}
+	return nil
+}
+
+// ImportMemory copies the user's memory from one store to another that has
+// none yet, e.g. from the JSON files an older release wrote into the
+// database, and reports whether it copied anything
+func ImportMemory(from, to MemoryStore, userID string) (bool, error) {
+	existing, err := to.Load(userID)
+	if err != nil || existing != nil {
+		return false, err
+	}
+	record, err := from.Load(userID)
+	if err != nil || record == nil {
+		return false, err
+	}
+	if err := to.Save(userID, record); err != nil {
+		return false, err
+	}
+	return true, nil
+}
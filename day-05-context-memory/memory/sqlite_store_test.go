@@ -0,0 +1,104 @@
+package memory
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSQLiteMemoryStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.db")
+	store, err := OpenSQLiteMemoryStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if record, err := store.Load("alice"); record != nil || err != nil {
+		t.Errorf("Expected no memory for a new user, got %v, %v", record, err)
+	}
+
+	// Flushing through the manager saves, and a new session restores it
+	mm := newTestManager(t, PIITag)
+	if returning, err := mm.UseStore(store); returning || err != nil {
+		t.Fatalf("Expected a first-time user, got %v, %v", returning, err)
+	}
+	mm.userMemory.Profile["name"] = "Alice"
+	mm.AddMessage("user", "I like tea")
+	store.Close()
+
+	store, err = OpenSQLiteMemoryStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	restored := newTestManager(t, PIITag)
+	if returning, err := restored.UseStore(store); !returning || err != nil {
+		t.Fatalf("Expected a returning user, got %v, %v", returning, err)
+	}
+	if restored.userMemory.Profile["name"] != "Alice" || len(restored.conversationHistory) != 1 || restored.userMemory.Sessions != 2 {
+		t.Errorf("Unexpected restored memory %+v, history %v", restored.userMemory, restored.conversationHistory)
+	}
+
+	// A row written before summaries were kept is upgraded in place
+	if _, err := store.db.Exec(`UPDATE memories SET record = ? WHERE user_id = 'alice'`, `{"user_id":"alice","profile":{"name":"Old"}}`); err != nil {
+		t.Fatal(err)
+	}
+	record, err := store.Load("alice")
+	if err != nil || record.User == nil || record.User.Profile["name"] != "Old" {
+		t.Fatalf("Expected the old row upgraded, got %+v, %v", record, err)
+	}
+	var data string
+	store.db.QueryRow(`SELECT record FROM memories WHERE user_id = 'alice'`).Scan(&data)
+	if !strings.Contains(data, `"user":`) {
+		t.Errorf("Expected the upgraded row rewritten, got %s", data)
+	}
+
+	if err := store.Delete("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if record, err := store.Load("alice"); record != nil || err != nil {
+		t.Errorf("Expected the memory deleted, got %v, %v", record, err)
+	}
+	if err := store.Delete("alice"); err != nil {
+		t.Errorf("Expected deleting nothing to succeed, got %v", err)
+	}
+}
+
+func TestOpenMemoryStore(t *testing.T) {
+	dir := t.TempDir()
+	files := NewFileMemoryStore(dir)
+	if err := files.Save("alice", &MemoryRecord{User: &UserMemory{UserID: "alice", Profile: map[string]interface{}{"name": "Alice"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The default database imports the user's file from an older release
+	store, closeStore, err := openMemoryStore("", dir, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.(*SQLiteMemoryStore); !ok {
+		t.Errorf("Expected SQLite by default, got %T", store)
+	}
+	if record, err := store.Load("alice"); err != nil || record == nil || record.User.Profile["name"] != "Alice" {
+		t.Errorf("Expected the file imported, got %+v, %v", record, err)
+	}
+	// Once imported, the database is what counts
+	store.Save("alice", &MemoryRecord{User: &UserMemory{UserID: "alice", Profile: map[string]interface{}{"name": "Alicia"}}})
+	closeStore()
+	store, closeStore, _ = openMemoryStore(StoreSQLite, dir, "alice")
+	if record, _ := store.Load("alice"); record.User.Profile["name"] != "Alicia" {
+		t.Errorf("Expected the database not overwritten by the file, got %v", record.User.Profile)
+	}
+	closeStore()
+	if _, err := os.Stat(filepath.Join(dir, "memory.db")); err != nil {
+		t.Errorf("Expected the database in the memory directory: %v", err)
+	}
+
+	if store, _, err := openMemoryStore(StoreFile, dir, "alice"); err != nil || store.(*FileMemoryStore).dir != dir {
+		t.Errorf("Expected the file store, got %T, %v", store, err)
+	}
+	if _, _, err := openMemoryStore("bolt", dir, "alice"); err == nil || !strings.Contains(err.Error(), "MEMORY_STORE") {
+		t.Errorf("Expected an unknown store rejected, got %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// noSimilarity ranks a summary that can't be compared below any that can
+const noSimilarity = -2.0
+
+// embed returns the embedding of text
+func (mm *MemoryManager) embed(ctx context.Context, text string) ([]float32, error) {
+	resp, err := mm.client.CreateEmbeddings(mm.spendOn(ctx), openai.EmbeddingRequest{
+		Input: []string{text},
+		Model: openai.AdaEmbeddingV2,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedding: %w", err)
+	}
+	if len(resp.Data) == 0 {
+		return nil, fmt.Errorf("no embedding data returned")
+	}
+	return resp.Data[0].Embedding, nil
+}
+
+// embedOrNil embeds text, logging and returning nil when embeddings are
+// unavailable so retrieval falls back to recency
+func (mm *MemoryManager) embedOrNil(text string) []float32 {
+	embedding, err := mm.embed(context.Background(), text)
+	if err != nil {
+		slog.Warn("failed to embed text, using recency", "error", err)
+		return nil
+	}
+	return embedding
+}
+
+// rankSummaries returns up to limit summaries, the most similar to query
+// first. Summaries without an embedding, or all of them when query is nil,
+// are ranked by recency after the ones that could be compared.
+func rankSummaries(summaries []ConversationSummary, query []float32, limit int) []ConversationSummary {
+	if limit > len(summaries) {
+		limit = len(summaries)
+	}
+	if limit <= 0 {
+		return []ConversationSummary{}
+	}
+
+	// Only the best few are kept, rather than scoring and sorting them all
+	top := make([]ConversationSummary, 0, limit)
+	scores := make([]float64, 0, limit)
+	for _, summary := range summaries {
+		score := cosineSimilarity(query, summary.Embedding)
+		better := func(pos int) bool {
+			return score > scores[pos] || score == scores[pos] && summary.EndTime.After(top[pos].EndTime)
+		}
+		if len(top) == limit && !better(limit-1) {
+			continue
+		}
+
+		pos := len(top)
+		for pos > 0 && better(pos-1) {
+			pos--
+		}
+		if len(top) < limit {
+			top = append(top, ConversationSummary{})
+			scores = append(scores, 0)
+		}
+		copy(top[pos+1:], top[pos:len(top)-1])
+		copy(scores[pos+1:], scores[pos:len(scores)-1])
+		top[pos], scores[pos] = summary, score
+	}
+	return top
+}
+
+// cosineSimilarity compares two embeddings, returning noSimilarity when
+// either is missing or they differ in length
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return noSimilarity
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		x, y := float64(a[i]), float64(b[i])
+		dot += x * y
+		normA += x * x
+		normB += y * y
+	}
+	if normA == 0 || normB == 0 {
+		return noSimilarity
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
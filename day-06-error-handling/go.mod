@@ -4,5 +4,23 @@ go 1.24.4
 
 require (
 	github.com/joho/godotenv v1.5.1
+	github.com/sakibmulla/agentic-ai/costs v0.0.0
+	github.com/sakibmulla/agentic-ai/endpoint v0.0.0
+	github.com/sakibmulla/agentic-ai/logging v0.0.0
+	github.com/sakibmulla/agentic-ai/persist v0.0.0
+	github.com/sakibmulla/agentic-ai/settings v0.0.0
+	github.com/sakibmulla/agentic-ai/tools v0.0.0
 	github.com/sashabaranov/go-openai v1.40.5
 )
+
+replace github.com/sakibmulla/agentic-ai/costs => ../costs
+
+replace github.com/sakibmulla/agentic-ai/endpoint => ../endpoint
+
+replace github.com/sakibmulla/agentic-ai/logging => ../logging
+
+replace github.com/sakibmulla/agentic-ai/persist => ../persist
+
+replace github.com/sakibmulla/agentic-ai/settings => ../settings
+
+replace github.com/sakibmulla/agentic-ai/tools => ../tools
@@ -0,0 +1,192 @@
+package resilient
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// z99 is the standard normal quantile used to estimate P99 from mean and deviation
+const z99 = 2.326
+
+// TimeoutConfig defines adaptive per-operation timeouts
+type TimeoutConfig struct {
+	// Default is used until an operation has MinSamples observations
+	Default    time.Duration
+	Min        time.Duration
+	Max        time.Duration
+	MinSamples int
+	// Factor multiplies the predicted P99 latency to give headroom
+	Factor float64
+	// Alpha is the exponential smoothing weight of each new observation (0-1]
+	Alpha float64
+}
+
+// latencyModel tracks exponentially smoothed latency for one operation
+type latencyModel struct {
+	mean     float64 // seconds
+	variance float64
+	samples  int64
+	attempts int64
+	timeouts int64
+	current  time.Duration
+	lastUsed time.Time
+}
+
+// TimeoutStats reports the adaptive timeout of one operation
+type TimeoutStats struct {
+	Operation     string
+	Timeout       time.Duration
+	SmoothedMean  time.Duration
+	PredictedP99  time.Duration
+	Samples       int64
+	Attempts      int64
+	Timeouts      int64
+	TimeoutHitPct float64
+}
+
+// AdaptiveTimeouts predicts a per-operation timeout from observed latencies.
+// Latency is modelled with exponentially weighted mean and variance, so the
+// prediction follows recent behaviour; P99 is estimated as mean + 2.33σ and
+// the timeout is P99 * Factor, clamped to [Min, Max].
+type AdaptiveTimeouts struct {
+	config TimeoutConfig
+	models map[string]*latencyModel
+	mu     sync.Mutex
+}
+
+// NewAdaptiveTimeouts creates a timeout predictor
+func NewAdaptiveTimeouts(config TimeoutConfig) *AdaptiveTimeouts {
+	return &AdaptiveTimeouts{
+		config: config,
+		models: make(map[string]*latencyModel),
+	}
+}
+
+// Timeout returns the timeout to use for the next attempt of an operation
+func (at *AdaptiveTimeouts) Timeout(operation string) time.Duration {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	return at.model(operation).current
+}
+
+// WithTimeout derives a context bounded by the operation's adaptive timeout
+func (at *AdaptiveTimeouts) WithTimeout(ctx context.Context, operation string) (context.Context, context.CancelFunc, time.Duration) {
+	timeout := at.Timeout(operation)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, cancel, timeout
+}
+
+// Observe records one attempt. A timed-out attempt contributes its timeout as
+// a lower bound on the real latency, which pushes the next timeout up.
+func (at *AdaptiveTimeouts) Observe(operation string, latency time.Duration, timedOut bool) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	m := at.model(operation)
+	m.attempts++
+	if timedOut {
+		m.timeouts++
+	}
+
+	x := latency.Seconds()
+	if m.samples == 0 {
+		m.mean = x
+		m.variance = 0
+	} else {
+		// Exponentially weighted moving mean and variance
+		alpha := at.config.Alpha
+		diff := x - m.mean
+		incr := alpha * diff
+		m.mean += incr
+		m.variance = (1 - alpha) * (m.variance + diff*incr)
+	}
+	m.samples++
+
+	if m.samples >= int64(at.config.MinSamples) {
+		m.current = at.clamp(time.Duration(m.p99() * at.config.Factor * float64(time.Second)))
+	}
+}
+
+// Stats returns the timeout state of every operation seen so far
+func (at *AdaptiveTimeouts) Stats() []TimeoutStats {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	stats := make([]TimeoutStats, 0, len(at.models))
+	for operation, m := range at.models {
+		s := TimeoutStats{
+			Operation:    operation,
+			Timeout:      m.current,
+			SmoothedMean: time.Duration(m.mean * float64(time.Second)),
+			PredictedP99: time.Duration(m.p99() * float64(time.Second)),
+			Samples:      m.samples,
+			Attempts:     m.attempts,
+			Timeouts:     m.timeouts,
+		}
+		if m.attempts > 0 {
+			s.TimeoutHitPct = float64(m.timeouts) / float64(m.attempts) * 100
+		}
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Operation < stats[j].Operation
+	})
+	return stats
+}
+
+// Reset forgets all observations
+func (at *AdaptiveTimeouts) Reset() {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	at.models = make(map[string]*latencyModel)
+}
+
+// Compact forgets operations that haven't been used for idle, such as tools
+// that were unregistered, and returns how many it forgot
+func (at *AdaptiveTimeouts) Compact(idle time.Duration) int {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	cutoff := time.Now().Add(-idle)
+	dropped := 0
+	for operation, m := range at.models {
+		if m.lastUsed.Before(cutoff) {
+			delete(at.models, operation)
+			dropped++
+		}
+	}
+	return dropped
+}
+
+// model returns the model for an operation, creating it if needed, and marks
+// it used. Callers must hold at.mu.
+func (at *AdaptiveTimeouts) model(operation string) *latencyModel {
+	m, ok := at.models[operation]
+	if !ok {
+		m = &latencyModel{current: at.clamp(at.config.Default)}
+		at.models[operation] = m
+	}
+	m.lastUsed = time.Now()
+	return m
+}
+
+// clamp bounds a timeout to the configured range
+func (at *AdaptiveTimeouts) clamp(timeout time.Duration) time.Duration {
+	if timeout < at.config.Min {
+		return at.config.Min
+	}
+	if at.config.Max > 0 && timeout > at.config.Max {
+		return at.config.Max
+	}
+	return timeout
+}
+
+// p99 estimates the 99th percentile latency in seconds
+func (m *latencyModel) p99() float64 {
+	return m.mean + z99*math.Sqrt(m.variance)
+}
@@ -0,0 +1,84 @@
+package resilient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sakibmulla/agentic-ai/tools"
+	"github.com/sashabaranov/go-openai"
+)
+
+// maxToolRounds bounds the tool calls made while answering one message
+const maxToolRounds = 5
+
+// Tools returns the registry of tools the model may call. With no tools
+// registered, Chat makes a single plain completion.
+func (ra *ResilientAgent) Tools() *tools.Registry {
+	return ra.tools
+}
+
+// chatWithTools answers message, letting the model call the registered
+// tools. Every model call runs as the "chat" operation and every tool call
+// as "tool:<name>", so each gets its own retries, adaptive timeout and
+// injected faults. It returns the answer and the fallback route that served
+// it.
+func (ra *ResilientAgent) chatWithTools(ctx context.Context, message string) (string, string, error) {
+	var functions []openai.FunctionDefinition
+	for _, definition := range ra.tools.Definitions() {
+		functions = append(functions, openai.FunctionDefinition{
+			Name:        definition.Name,
+			Description: definition.Description,
+			Parameters:  definition.Parameters,
+		})
+	}
+	messages := []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: message}}
+
+	for round := 0; round <= maxToolRounds; round++ {
+		var reply openai.ChatCompletionMessage
+		var route string
+		_, err := ra.Execute(ctx, "chat", func(ctx context.Context) (string, error) {
+			var err error
+			reply, route, err = ra.performToolRequest(ctx, messages, functions)
+			return reply.Content, err
+		})
+		if err != nil {
+			return "", "", err
+		}
+		if reply.FunctionCall == nil {
+			return reply.Content, route, nil
+		}
+
+		call := reply.FunctionCall
+		result, err := ra.Execute(ctx, "tool:"+call.Name, func(ctx context.Context) (string, error) {
+			result, err := ra.tools.Call(ctx, call.Name, call.Arguments)
+			// A bad call is the model's mistake, not a failing dependency, so
+			// it neither trips the circuit breaker nor is retried
+			if errors.Is(err, tools.ErrInvalidArguments) || errors.Is(err, tools.ErrUnknownTool) {
+				return fmt.Sprintf("Error: %v", err), nil
+			}
+			return result, err
+		})
+		if err != nil {
+			result = fmt.Sprintf("Error: %v", err)
+		}
+
+		messages = append(messages, reply, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleFunction,
+			Name:    call.Name,
+			Content: result,
+		})
+	}
+	return "", "", fmt.Errorf("no answer after %d tool calls", maxToolRounds)
+}
+
+// performToolRequest makes one API request declaring the tools as
+// functions, through the fallback chain
+func (ra *ResilientAgent) performToolRequest(ctx context.Context, messages []openai.ChatCompletionMessage, functions []openai.FunctionDefinition) (openai.ChatCompletionMessage, string, error) {
+	return ra.complete(ctx, openai.ChatCompletionRequest{
+		Messages:    messages,
+		Functions:   functions,
+		MaxTokens:   150,
+		Temperature: 0.7,
+	})
+}
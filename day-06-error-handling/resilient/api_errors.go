@@ -0,0 +1,213 @@
+package resilient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// defaultRetriableStatusCodes are retried when RetryConfig doesn't list
+// its own: throttling, conflicts and server-side failures. Client errors
+// like 400, 401 and 404 fail the same way however often they're sent.
+var defaultRetriableStatusCodes = []int{
+	http.StatusRequestTimeout, http.StatusConflict, http.StatusTooManyRequests,
+	http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout,
+}
+
+// defaultNonRetriableErrorCodes are OpenAI error codes never retried,
+// even when their status is: a 429 for an exhausted quota won't clear up
+var defaultNonRetriableErrorCodes = []string{"insufficient_quota"}
+
+// APIFailure is a failed OpenAI API call, classified from go-openai's
+// APIError and RequestError. Its message starts with its category, e.g.
+// "rate_limit: ...", like the errors fault injection simulates.
+type APIFailure struct {
+	// Category is rate_limit, quota, timeout, server_error, network, auth,
+	// not_found or client_error
+	Category string
+	// StatusCode is the HTTP status, or 0 when no response arrived
+	StatusCode int
+	// Code is the OpenAI error code, e.g. rate_limit_exceeded
+	Code string
+	// RetryAfter is how long the response asked callers to wait, if it did
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *APIFailure) Error() string {
+	return fmt.Sprintf("%s: %v", e.Category, e.Err)
+}
+
+func (e *APIFailure) Unwrap() error {
+	return e.Err
+}
+
+// classifyError classifies errors for retry and circuit breaker logic.
+// retryAfter is the wait the failed response asked for, from
+// withRetryAfter. Errors without a status fall back to matching their
+// message, as do errors that aren't from the API at all.
+func (ra *ResilientAgent) classifyError(err error, retryAfter time.Duration) error {
+	failure := &APIFailure{Err: err, RetryAfter: retryAfter}
+	var apiErr *openai.APIError
+	var reqErr *openai.RequestError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &apiErr):
+		failure.StatusCode = apiErr.HTTPStatusCode
+		if code, ok := apiErr.Code.(string); ok {
+			failure.Code = code
+		} else if apiErr.Code != nil {
+			failure.Code = fmt.Sprint(apiErr.Code)
+		}
+	case errors.As(err, &reqErr):
+		failure.StatusCode = reqErr.HTTPStatusCode
+	case errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout():
+		failure.Category = "timeout"
+		return failure
+	case errors.As(err, &netErr):
+		failure.Category = "network"
+		return failure
+	default:
+		return classifyMessage(err)
+	}
+
+	switch status := failure.StatusCode; {
+	case status == http.StatusTooManyRequests && failure.Code == "insufficient_quota":
+		failure.Category = "quota"
+	case status == http.StatusTooManyRequests:
+		failure.Category = "rate_limit"
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout:
+		failure.Category = "timeout"
+	case status >= 500:
+		failure.Category = "server_error"
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		failure.Category = "auth"
+	case status == http.StatusNotFound:
+		failure.Category = "not_found"
+	case status >= 400:
+		failure.Category = "client_error"
+	default:
+		return classifyMessage(err)
+	}
+	return failure
+}
+
+// classifyMessage prefixes an error the API types don't describe with the
+// category its message suggests
+func classifyMessage(err error) error {
+	errStr := err.Error()
+
+	switch {
+	case contains(errStr, "rate limit"):
+		return fmt.Errorf("rate_limit: %w", err)
+	case contains(errStr, "timeout"):
+		return fmt.Errorf("timeout: %w", err)
+	case contains(errStr, "server error") || contains(errStr, "internal error"):
+		return fmt.Errorf("server_error: %w", err)
+	case contains(errStr, "network") || contains(errStr, "connection"):
+		return fmt.Errorf("network: %w", err)
+	default:
+		return err
+	}
+}
+
+// isRetriable determines if an error should be retried. API failures
+// with a status are decided by status and error code; other errors by
+// the RetriableErrors categories their messages contain.
+func (rm *RetryManager) isRetriable(err error) bool {
+	var failure *APIFailure
+	if errors.As(err, &failure) && failure.StatusCode > 0 {
+		nonRetriable := rm.config.NonRetriableErrorCodes
+		if nonRetriable == nil {
+			nonRetriable = defaultNonRetriableErrorCodes
+		}
+		statuses := rm.config.RetriableStatusCodes
+		if statuses == nil {
+			statuses = defaultRetriableStatusCodes
+		}
+		switch {
+		case failure.Code != "" && slices.Contains(nonRetriable, failure.Code):
+			return false
+		case failure.Code != "" && slices.Contains(rm.config.RetriableErrorCodes, failure.Code):
+			return true
+		default:
+			return slices.Contains(statuses, failure.StatusCode)
+		}
+	}
+
+	errStr := err.Error()
+	for _, retriableErr := range rm.config.RetriableErrors {
+		if contains(errStr, retriableErr) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryAfter returns the wait err's response asked for, or 0
+func retryAfter(err error) time.Duration {
+	var failure *APIFailure
+	if errors.As(err, &failure) {
+		return failure.RetryAfter
+	}
+	return 0
+}
+
+// retryAfterKey is the context key of a request's Retry-After holder
+type retryAfterKey struct{}
+
+// withRetryAfter returns a context that retryAfterTransport records a
+// failed response's Retry-After in, since go-openai's errors don't carry
+// response headers
+func withRetryAfter(ctx context.Context) (context.Context, *time.Duration) {
+	wait := new(time.Duration)
+	return context.WithValue(ctx, retryAfterKey{}, wait), wait
+}
+
+// retryAfterTransport records the Retry-After of failed responses in the
+// holder on the request's context
+type retryAfterTransport struct {
+	base http.RoundTripper
+}
+
+// captureRetryAfter wraps base, or http.DefaultTransport when it is nil
+func captureRetryAfter(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &retryAfterTransport{base: base}
+}
+
+func (t *retryAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err == nil && resp.StatusCode >= 400 {
+		if wait, ok := req.Context().Value(retryAfterKey{}).(*time.Duration); ok {
+			*wait = parseRetryAfter(resp.Header, time.Now())
+		}
+	}
+	return resp, err
+}
+
+// parseRetryAfter reads OpenAI's retry-after-ms header, or the standard
+// Retry-After in seconds or as an HTTP date. It returns 0 when neither is
+// set or valid.
+func parseRetryAfter(header http.Header, now time.Time) time.Duration {
+	if ms, err := strconv.ParseFloat(header.Get("Retry-After-Ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+	value := header.Get("Retry-After")
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,98 @@
+package resilient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestAPIErrorRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		header    string
+		body      string
+		wantCalls int
+		category  string
+	}{
+		{"429 waits for retry-after-ms", 429, "Retry-After-Ms", `{"error":{"message":"slow down","type":"requests","code":"rate_limit_exceeded"}}`, 2, ""},
+		{"503 is retried", 503, "", `{"error":{"message":"overloaded","type":"server_error"}}`, 2, ""},
+		{"401 is not retried", 401, "", `{"error":{"message":"bad key","type":"invalid_request_error","code":"invalid_api_key"}}`, 1, "auth"},
+		{"404 is not retried", 404, "", `{"error":{"message":"no such model","type":"invalid_request_error","code":"model_not_found"}}`, 1, "not_found"},
+		{"exhausted quota is not retried", 429, "", `{"error":{"message":"quota","type":"insufficient_quota","code":"insufficient_quota"}}`, 1, "quota"},
+		{"too long a Retry-After ends retries", 429, "Retry-After", `{"error":{"message":"slow down","code":"rate_limit_exceeded"}}`, 1, "rate_limit"},
+		{"non-JSON 502 is retried", 502, "", `<html>bad gateway</html>`, 2, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if calls > 1 {
+					w.Header().Set("Content-Type", "application/json")
+					w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+					return
+				}
+				switch tt.header {
+				case "Retry-After-Ms":
+					w.Header().Set("Retry-After-Ms", "150")
+				case "Retry-After":
+					w.Header().Set("Retry-After", "120")
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			config := DefaultReliabilityConfig()
+			config.Retry.BaseDelay = time.Millisecond
+			config.Retry.MaxDelay = 10 * time.Second
+			agent, err := NewResilientAgent("test-key", config)
+			if err != nil {
+				t.Fatalf("Failed to create agent: %v", err)
+			}
+			clientConfig := openai.DefaultConfig("test-key")
+			clientConfig.BaseURL = server.URL + "/v1"
+			clientConfig.HTTPClient = &http.Client{Transport: captureRetryAfter(nil)}
+			agent.client = openai.NewClientWithConfig(clientConfig)
+
+			started := time.Now()
+			_, err = agent.Chat(context.Background(), "hi")
+			if calls != tt.wantCalls {
+				t.Errorf("Expected %d calls, got %d (%v)", tt.wantCalls, calls, err)
+			}
+			if tt.header == "Retry-After-Ms" && time.Since(started) < 150*time.Millisecond {
+				t.Errorf("Expected the retry to wait for retry-after-ms, took %v", time.Since(started))
+			}
+			var failure *APIFailure
+			if tt.category != "" && (!errors.As(err, &failure) || failure.Category != tt.category) {
+				t.Errorf("Expected a %s failure, got %v", tt.category, err)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, tt := range []struct {
+		name, value string
+		want        time.Duration
+	}{
+		{"Retry-After", "7", 7 * time.Second},
+		{"Retry-After", now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{"Retry-After", now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"Retry-After", "soon", 0},
+		{"Retry-After-Ms", "250.5", 250500 * time.Microsecond},
+	} {
+		header := http.Header{}
+		header.Set(tt.name, tt.value)
+		if got := parseRetryAfter(header, now); got != tt.want {
+			t.Errorf("%s: %s = %v, expected %v", tt.name, tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package resilient
+
+import (
+	"testing"
+	"time"
+)
+
+// newBenchMonitor returns a monitor holding a full window of response times
+func newBenchMonitor() (*Monitor, *CircuitBreakers, *RateLimiter) {
+	config := DefaultReliabilityConfig()
+	monitor := NewMonitor(config.Monitoring)
+	for i := 0; i < 1000; i++ {
+		monitor.RecordSuccess(time.Duration(i*7919%1000) * time.Millisecond)
+	}
+	return monitor, NewCircuitBreakers(config.CircuitBreaker, nil), NewRateLimiter(config.RateLimit)
+}
+
+func BenchmarkMonitorRecord(b *testing.B) {
+	monitor, _, _ := newBenchMonitor()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if i%10 == 0 {
+			monitor.RecordFailure(time.Duration(i%1000) * time.Millisecond)
+		} else {
+			monitor.RecordSuccess(time.Duration(i%1000) * time.Millisecond)
+		}
+	}
+}
+
+func BenchmarkMonitorGetMetrics(b *testing.B) {
+	monitor, cb, rl := newBenchMonitor()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		monitor.GetMetrics(cb, rl)
+	}
+}
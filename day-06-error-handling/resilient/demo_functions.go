@@ -1,8 +1,9 @@
-package main
+package resilient
 
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -174,3 +175,42 @@ func demonstrateRecovery(agent *ResilientAgent) {
 
 	fmt.Println()
 }
+
+// runWorkflowDemo runs a three-step writing workflow on topic under a total
+// budget and prints how each step went
+func runWorkflowDemo(agent *ResilientAgent, topic string) {
+	retry := agent.GetConfig().Retry
+	workflow := &Workflow{
+		Name:   "article",
+		Budget: 45 * time.Second,
+		Steps: []WorkflowStep{
+			agent.AgentStep("outline", "Write a three-point outline for a short article about: %s", 15*time.Second, &retry),
+			agent.AgentStep("draft", "Write a short article following this outline:\n%s", 25*time.Second, &retry),
+			agent.AgentStep("summary", "Summarize this article in one sentence:\n%s", 10*time.Second, nil),
+		},
+	}
+
+	fmt.Printf("🧭 Running workflow '%s' (budget %v)...\n", workflow.Name, workflow.Budget)
+	// Batch work yields to interactive chat when the priority queue is on
+	result, err := workflow.Run(WithPriority(context.Background(), PriorityLow), topic)
+	for _, step := range result.Steps {
+		icon := map[StepStatus]string{
+			StepSucceeded: "✅", StepFailed: "❌", StepTimedOut: "⏰", StepCancelled: "🛑", StepSkipped: "⏭️",
+		}[step.Status]
+		fmt.Printf("%s %-8s %-10s attempts=%d duration=%v", icon, step.Name, step.Status, step.Attempts, step.Duration.Round(time.Millisecond))
+		if step.Error != "" {
+			fmt.Printf(" error=%s", step.Error)
+		}
+		fmt.Println()
+	}
+
+	if err != nil {
+		fmt.Printf("❌ Workflow stopped after %v: %v\n", result.Duration.Round(time.Millisecond), err)
+		if skipped := result.Skipped(); len(skipped) > 0 {
+			fmt.Printf("⏭️  Skipped steps: %s\n", strings.Join(skipped, ", "))
+		}
+		return
+	}
+	fmt.Printf("🤖 Result: %s\n", result.Output)
+	fmt.Printf("⏱️  Workflow took %v\n", result.Duration.Round(time.Millisecond))
+}
@@ -0,0 +1,233 @@
+package resilient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ModelRoute is one way to answer a chat request: a model on the agent's
+// own client, or on a secondary OpenAI-compatible provider
+type ModelRoute struct {
+	Model string
+	// BaseURL and APIKey, when set, send the route to another provider
+	BaseURL string
+	APIKey  string
+	client  *openai.Client
+}
+
+// Name identifies the route in logs and metrics: the model, followed by
+// the provider's host for a secondary provider
+func (r ModelRoute) Name() string {
+	if r.BaseURL == "" {
+		return r.Model
+	}
+	host := r.BaseURL
+	if u, err := url.Parse(r.BaseURL); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	return r.Model + "@" + host
+}
+
+// FallbackChain lists the routes a chat request may be served by, in order
+// of preference. A route that fails, or takes longer than LatencySLO, falls
+// back to the next one, as does a route whose circuit breaker is open or
+// whose bulkhead is full. With HedgeDelay set, a route that hasn't answered
+// within it is raced against the next route, and the first answer wins.
+// LatencySLO and HedgeDelay should be below the "chat" operation's
+// timeout, which bounds the whole chain.
+type FallbackChain struct {
+	Routes     []ModelRoute
+	LatencySLO time.Duration
+	HedgeDelay time.Duration
+}
+
+// defaultRoute serves chat requests when no fallback chain is configured
+var defaultRoute = ModelRoute{Model: openai.GPT3Dot5Turbo}
+
+// FallbackChainFromEnv reads FALLBACK_MODELS, a comma-separated chain like
+// "gpt-4o,gpt-4o-mini", LATENCY_SLO_MS and HEDGE_DELAY_MS. A model written
+// as "secondary:<model>" is sent to FALLBACK_BASE_URL with
+// FALLBACK_API_KEY.
+func FallbackChainFromEnv() (FallbackChain, error) {
+	var chain FallbackChain
+	for _, model := range strings.Split(os.Getenv("FALLBACK_MODELS"), ",") {
+		model = strings.TrimSpace(model)
+		if model == "" {
+			continue
+		}
+		route := ModelRoute{Model: model}
+		if name, ok := strings.CutPrefix(model, "secondary:"); ok {
+			route = ModelRoute{Model: name, BaseURL: os.Getenv("FALLBACK_BASE_URL"), APIKey: os.Getenv("FALLBACK_API_KEY")}
+			if route.BaseURL == "" {
+				return chain, fmt.Errorf("%s needs FALLBACK_BASE_URL", model)
+			}
+		}
+		chain.Routes = append(chain.Routes, route)
+	}
+	for name, target := range map[string]*time.Duration{"LATENCY_SLO_MS": &chain.LatencySLO, "HEDGE_DELAY_MS": &chain.HedgeDelay} {
+		if value := os.Getenv(name); value != "" {
+			ms, err := strconv.Atoi(value)
+			if err != nil || ms <= 0 {
+				return chain, fmt.Errorf("invalid %s %q", name, value)
+			}
+			*target = time.Duration(ms) * time.Millisecond
+		}
+	}
+	return chain, nil
+}
+
+// newRoutes gives each route on a secondary provider its own client,
+// through the same transport as the agent's
+func newRoutes(chain FallbackChain, httpClient *http.Client) []ModelRoute {
+	if len(chain.Routes) == 0 {
+		return []ModelRoute{defaultRoute}
+	}
+	routes := make([]ModelRoute, len(chain.Routes))
+	for i, route := range chain.Routes {
+		if route.BaseURL != "" {
+			config := openai.DefaultConfig(route.APIKey)
+			config.BaseURL = route.BaseURL
+			config.HTTPClient = httpClient
+			route.client = openai.NewClientWithConfig(config)
+		}
+		routes[i] = route
+	}
+	return routes
+}
+
+// Routes returns the names of the agent's fallback routes, in order
+func (ra *ResilientAgent) Routes() []string {
+	names := make([]string, len(ra.routes))
+	for i, route := range ra.routes {
+		names[i] = route.Name()
+	}
+	return names
+}
+
+// routeOutcome is one route's answer to a request
+type routeOutcome struct {
+	reply openai.ChatCompletionMessage
+	route string
+	err   error
+}
+
+// complete sends req through the fallback chain and returns the first
+// answer with the name of the route that gave it. When every route fails,
+// the last route's error is returned.
+func (ra *ResilientAgent) complete(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionMessage, string, error) {
+	chain := ra.config.Fallback
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // stops the routes that lost a hedge
+
+	results := make(chan routeOutcome, len(ra.routes))
+	launch := func(route ModelRoute) {
+		go func() {
+			routeCtx, routeCancel := ctx, context.CancelFunc(func() {})
+			if chain.LatencySLO > 0 {
+				routeCtx, routeCancel = context.WithTimeout(ctx, chain.LatencySLO)
+			}
+			defer routeCancel()
+			reply, err := ra.completeIsolated(routeCtx, route, req)
+			if err != nil && routeCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+				err = fmt.Errorf("timeout: %s exceeded the latency SLO of %v", route.Name(), chain.LatencySLO)
+			}
+			results <- routeOutcome{reply: reply, route: route.Name(), err: err}
+		}()
+	}
+
+	var hedge <-chan time.Time
+	next := 0
+	startNext := func() {
+		launch(ra.routes[next])
+		next++
+		hedge = nil
+		if chain.HedgeDelay > 0 && next < len(ra.routes) {
+			hedge = time.After(chain.HedgeDelay)
+		}
+	}
+	startNext()
+
+	var lastErr error
+	for running := 1; running > 0; {
+		select {
+		case <-hedge:
+			slog.InfoContext(ctx, "hedging request", "route", ra.routes[next].Name(), "after", chain.HedgeDelay)
+			ra.monitor.RecordHedge()
+			startNext()
+			running++
+		case outcome := <-results:
+			running--
+			if outcome.err == nil {
+				ra.monitor.RecordServedBy(outcome.route, outcome.route != ra.routes[0].Name())
+				slog.DebugContext(ctx, "response served", "route", outcome.route)
+				return outcome.reply, outcome.route, nil
+			}
+			lastErr = outcome.err
+			if next < len(ra.routes) && ctx.Err() == nil {
+				slog.WarnContext(ctx, "route failed, falling back", "route", outcome.route, "next", ra.routes[next].Name(), "error", outcome.err)
+				startNext()
+				running++
+			}
+		}
+	}
+	return openai.ChatCompletionMessage{}, "", lastErr
+}
+
+// completeIsolated sends req to one route through the route's own circuit
+// breaker and bulkhead, named "model:<route>", so a failing model only
+// opens its own circuit. A hedge loser cancelled by the winner doesn't
+// count against its route.
+func (ra *ResilientAgent) completeIsolated(ctx context.Context, route ModelRoute, req openai.ChatCompletionRequest) (openai.ChatCompletionMessage, error) {
+	resource := "model:" + route.Name()
+	breaker := ra.breakers.Get(resource)
+	if !breaker.Allow() {
+		return openai.ChatCompletionMessage{}, fmt.Errorf("circuit breaker is open for %s", resource)
+	}
+	release, err := ra.bulkheads.Acquire(ctx, resource)
+	if err != nil {
+		return openai.ChatCompletionMessage{}, err
+	}
+	defer release()
+
+	reply, err := ra.completeOn(ctx, route, req)
+	switch {
+	case err == nil:
+		breaker.RecordSuccess()
+	case errors.Is(ctx.Err(), context.Canceled):
+	default:
+		if breaker.RecordFailure() {
+			ra.monitor.RecordTrip()
+			slog.WarnContext(ctx, "circuit breaker opened", "resource", resource)
+		}
+	}
+	return reply, err
+}
+
+// completeOn sends req to one route
+func (ra *ResilientAgent) completeOn(ctx context.Context, route ModelRoute, req openai.ChatCompletionRequest) (openai.ChatCompletionMessage, error) {
+	client := route.client
+	if client == nil {
+		client = ra.client
+	}
+	req.Model = route.Model
+
+	ctx, wait := withRetryAfter(ctx)
+	resp, err := client.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return openai.ChatCompletionMessage{}, ra.classifyError(err, *wait)
+	}
+	if len(resp.Choices) == 0 {
+		return openai.ChatCompletionMessage{}, fmt.Errorf("no response choices received")
+	}
+	return resp.Choices[0].Message, nil
+}
@@ -0,0 +1,121 @@
+package resilient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// fakeModels serves chat completions that answer with the model's name,
+// after failing or stalling as behavior says for that model
+func fakeModels(behavior map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		switch behavior[req.Model] {
+		case "fail":
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error":{"message":"boom","type":"server_error"}}`))
+			return
+		case "slow":
+			select {
+			case <-time.After(2 * time.Second):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"` + req.Model + `"}}]}`))
+	}))
+}
+
+func TestFallbackChain(t *testing.T) {
+	tests := []struct {
+		name       string
+		behavior   map[string]string
+		chain      FallbackChain
+		wantRoute  string
+		wantHedges int64
+		maxTime    time.Duration
+	}{
+		{"primary answers", map[string]string{}, FallbackChain{}, "gpt-4o", 0, time.Second},
+		{"failure falls back", map[string]string{"gpt-4o": "fail"}, FallbackChain{}, "gpt-4o-mini", 0, time.Second},
+		{"missed SLO falls back", map[string]string{"gpt-4o": "slow"}, FallbackChain{LatencySLO: 50 * time.Millisecond}, "gpt-4o-mini", 0, time.Second},
+		{"hedge wins", map[string]string{"gpt-4o": "slow"}, FallbackChain{HedgeDelay: 50 * time.Millisecond}, "gpt-4o-mini", 1, time.Second},
+		{"fast primary is not hedged", map[string]string{"gpt-4o-mini": "slow"}, FallbackChain{HedgeDelay: 200 * time.Millisecond}, "gpt-4o", 0, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakeModels(tt.behavior)
+			defer server.Close()
+
+			config := DefaultReliabilityConfig()
+			config.Retry.MaxAttempts = 1
+			config.Fallback = tt.chain
+			config.Fallback.Routes = []ModelRoute{{Model: "gpt-4o"}, {Model: "gpt-4o-mini"}}
+			agent, err := NewResilientAgent("test-key", config)
+			if err != nil {
+				t.Fatalf("Failed to create agent: %v", err)
+			}
+			clientConfig := openai.DefaultConfig("test-key")
+			clientConfig.BaseURL = server.URL + "/v1"
+			agent.client = openai.NewClientWithConfig(clientConfig)
+
+			started := time.Now()
+			response, route, err := agent.ChatWithRoute(context.Background(), "hi")
+			if err != nil || route != tt.wantRoute || response != tt.wantRoute {
+				t.Fatalf("Expected %s to answer, got %q from %q (%v)", tt.wantRoute, response, route, err)
+			}
+			if elapsed := time.Since(started); elapsed > tt.maxTime {
+				t.Errorf("Expected an answer within %v, took %v", tt.maxTime, elapsed)
+			}
+			metrics := agent.GetMetrics()
+			wantFallbacks := int64(0)
+			if route != "gpt-4o" {
+				wantFallbacks = 1
+			}
+			if metrics.ServedBy[route] != 1 || metrics.FallbackResponses != wantFallbacks || metrics.HedgedRequests != tt.wantHedges {
+				t.Errorf("Unexpected route metrics %v, %d fallbacks, %d hedges", metrics.ServedBy, metrics.FallbackResponses, metrics.HedgedRequests)
+			}
+		})
+	}
+
+	t.Run("every route fails", func(t *testing.T) {
+		server := fakeModels(map[string]string{"gpt-4o": "fail", "gpt-4o-mini": "fail"})
+		defer server.Close()
+		config := DefaultReliabilityConfig()
+		config.Retry.MaxAttempts = 1
+		config.Fallback.Routes = []ModelRoute{{Model: "gpt-4o"}, {Model: "gpt-4o-mini"}}
+		agent, _ := NewResilientAgent("test-key", config)
+		clientConfig := openai.DefaultConfig("test-key")
+		clientConfig.BaseURL = server.URL + "/v1"
+		agent.client = openai.NewClientWithConfig(clientConfig)
+
+		if _, err := agent.Chat(context.Background(), "hi"); err == nil || !strings.HasPrefix(err.Error(), "server_error") {
+			t.Errorf("Expected the last route's server error, got %v", err)
+		}
+	})
+}
+
+func TestFallbackChainFromEnv(t *testing.T) {
+	t.Setenv("FALLBACK_MODELS", "gpt-4o, secondary:llama-3.1-70b")
+	t.Setenv("FALLBACK_BASE_URL", "https://api.groq.com/openai/v1")
+	t.Setenv("HEDGE_DELAY_MS", "800")
+	chain, err := FallbackChainFromEnv()
+	if err != nil {
+		t.Fatalf("Failed to read the chain: %v", err)
+	}
+	if len(chain.Routes) != 2 || chain.Routes[1].Name() != "llama-3.1-70b@api.groq.com" || chain.HedgeDelay != 800*time.Millisecond {
+		t.Errorf("Unexpected chain %+v", chain)
+	}
+	t.Setenv("LATENCY_SLO_MS", "fast")
+	if _, err := FallbackChainFromEnv(); err == nil {
+		t.Error("Expected an invalid SLO to fail")
+	}
+}
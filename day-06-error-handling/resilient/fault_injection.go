@@ -0,0 +1,343 @@
+package resilient
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// faultErrors maps fault types to the simulated error they produce
+var faultErrors = map[string]string{
+	"timeout":      "timeout: simulated timeout error",
+	"ratelimit":    "rate_limit: simulated rate limit error",
+	"server_error": "server_error: simulated server error",
+	"network":      "network: simulated network error",
+	"quota":        "quota: simulated quota exhaustion",
+}
+
+// FaultRule describes one injected fault
+type FaultRule struct {
+	ID int `json:"id"`
+	// Type is one of timeout, ratelimit, server_error, network, quota or latency
+	Type string `json:"type"`
+	// Operation limits the fault to an operation such as "chat", "embeddings"
+	// or "tool:web_search". "tool:*" matches every tool; empty or "*" matches all.
+	Operation string `json:"operation"`
+	// Probability is the chance (0-1] that a matching call is affected; 0 means always
+	Probability float64 `json:"probability"`
+	// Latency is added before the call; for type "latency" it is the only effect
+	Latency   time.Duration `json:"latency"`
+	ExpiresAt time.Time     `json:"expires_at"`
+	Hits      int           `json:"hits"`
+}
+
+// matches reports whether the rule applies to an operation
+func (r FaultRule) matches(operation string) bool {
+	switch {
+	case r.Operation == "" || r.Operation == "*":
+		return true
+	case strings.HasSuffix(r.Operation, "*"):
+		return strings.HasPrefix(operation, strings.TrimSuffix(r.Operation, "*"))
+	default:
+		return r.Operation == operation
+	}
+}
+
+// FaultInjector simulates failure scenarios for chaos experiments
+type FaultInjector struct {
+	rules  []*FaultRule
+	nextID int
+	random *rand.Rand
+	mu     sync.Mutex
+}
+
+// NewFaultInjector creates a new fault injector
+func NewFaultInjector() *FaultInjector {
+	return &FaultInjector{
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// SetSeed makes probabilistic faults reproducible
+func (fi *FaultInjector) SetSeed(seed int64) {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	fi.random = rand.New(rand.NewSource(seed))
+}
+
+// InjectFault makes every operation fail with faultType for duration
+func (fi *FaultInjector) InjectFault(faultType string, duration time.Duration) {
+	fi.AddRule(FaultRule{Type: faultType, ExpiresAt: time.Now().Add(duration)})
+}
+
+// AddRule validates and registers a fault rule, returning it with its ID
+func (fi *FaultInjector) AddRule(rule FaultRule) (FaultRule, error) {
+	if _, ok := faultErrors[rule.Type]; !ok && rule.Type != "latency" {
+		return FaultRule{}, fmt.Errorf("unknown fault type %q", rule.Type)
+	}
+	if rule.Probability < 0 || rule.Probability > 1 {
+		return FaultRule{}, fmt.Errorf("probability must be between 0 and 1")
+	}
+	if rule.Type == "latency" && rule.Latency <= 0 {
+		return FaultRule{}, fmt.Errorf("latency faults need a positive latency")
+	}
+	if rule.ExpiresAt.IsZero() {
+		return FaultRule{}, fmt.Errorf("fault rules need an expiry")
+	}
+
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	fi.nextID++
+	rule.ID = fi.nextID
+	rule.Hits = 0
+	fi.rules = append(fi.rules, &rule)
+	return rule, nil
+}
+
+// RemoveRule deletes a rule by ID
+func (fi *FaultInjector) RemoveRule(id int) error {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	for i, rule := range fi.rules {
+		if rule.ID == id {
+			fi.rules = append(fi.rules[:i], fi.rules[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("fault rule %d not found", id)
+}
+
+// Rules returns the active rules
+func (fi *FaultInjector) Rules() []FaultRule {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	fi.pruneLocked(time.Now())
+	rules := make([]FaultRule, 0, len(fi.rules))
+	for _, rule := range fi.rules {
+		rules = append(rules, *rule)
+	}
+	return rules
+}
+
+// ClearFaults removes all rules
+func (fi *FaultInjector) ClearFaults() {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	fi.rules = nil
+}
+
+// Apply runs the matching rules for an operation: it sleeps for any injected
+// latency and returns the first simulated error that fires
+func (fi *FaultInjector) Apply(ctx context.Context, operation string) error {
+	delay, err := fi.decide(operation)
+
+	if delay > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+	return err
+}
+
+// decide picks the latency and error for one call under the lock
+func (fi *FaultInjector) decide(operation string) (time.Duration, error) {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	fi.pruneLocked(time.Now())
+
+	var delay time.Duration
+	for _, rule := range fi.rules {
+		if !rule.matches(operation) {
+			continue
+		}
+		if rule.Probability > 0 && fi.random.Float64() >= rule.Probability {
+			continue
+		}
+
+		rule.Hits++
+		delay += rule.Latency
+		if rule.Type != "latency" {
+			return delay, fmt.Errorf("%s", faultErrors[rule.Type])
+		}
+	}
+	return delay, nil
+}
+
+// Prune drops expired rules and returns how many it dropped
+func (fi *FaultInjector) Prune() int {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	return fi.pruneLocked(time.Now())
+}
+
+// pruneLocked drops expired rules and returns how many it dropped. Callers
+// must hold fi.mu.
+func (fi *FaultInjector) pruneLocked(now time.Time) int {
+	kept := fi.rules[:0]
+	for _, rule := range fi.rules {
+		if now.Before(rule.ExpiresAt) {
+			kept = append(kept, rule)
+		}
+	}
+	for i := len(kept); i < len(fi.rules); i++ {
+		fi.rules[i] = nil
+	}
+	dropped := len(fi.rules) - len(kept)
+	fi.rules = kept
+	return dropped
+}
+
+// faultRuleRequest is the JSON body accepted by the admin endpoint
+type faultRuleRequest struct {
+	Type        string  `json:"type"`
+	Operation   string  `json:"operation"`
+	Probability float64 `json:"probability"`
+	Latency     string  `json:"latency"`  // e.g. "2s"
+	Duration    string  `json:"duration"` // how long the rule stays active, e.g. "30s"
+}
+
+// AdminHandler exposes the fault injector over HTTP for chaos experiments:
+//
+//	GET    /faults        list active rules
+//	POST   /faults        add a rule (faultRuleRequest JSON)
+//	DELETE /faults        clear all rules
+//	DELETE /faults?id=N   remove one rule
+func (fi *FaultInjector) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/faults", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, fi.Rules())
+
+		case http.MethodPost:
+			var req faultRuleRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			rule, err := req.toRule()
+			if err == nil {
+				rule, err = fi.AddRule(rule)
+			}
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusCreated, rule)
+
+		case http.MethodDelete:
+			if idParam := r.URL.Query().Get("id"); idParam != "" {
+				var id int
+				if _, err := fmt.Sscanf(idParam, "%d", &id); err != nil {
+					writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+					return
+				}
+				if err := fi.RemoveRule(id); err != nil {
+					writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+					return
+				}
+			} else {
+				fi.ClearFaults()
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			w.Header().Set("Allow", "GET, POST, DELETE")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	return mux
+}
+
+// AdminFromEnv returns the address and handler for the admin endpoint named
+// by CHAOS_ADMIN_ADDR, or an empty address when it isn't set. Anyone who
+// reaches the endpoint can break the agent, so it must listen on loopback
+// unless CHAOS_ADMIN_TOKEN is set, and then every request needs an
+// "Authorization: Bearer <token>" header.
+func (fi *FaultInjector) AdminFromEnv() (string, http.Handler, error) {
+	addr := os.Getenv("CHAOS_ADMIN_ADDR")
+	if addr == "" {
+		return "", nil, nil
+	}
+	token := os.Getenv("CHAOS_ADMIN_TOKEN")
+	if token == "" {
+		if !isLoopback(addr) {
+			return "", nil, fmt.Errorf("CHAOS_ADMIN_ADDR %q isn't a loopback address: set CHAOS_ADMIN_TOKEN to serve it there", addr)
+		}
+		return addr, fi.AdminHandler(), nil
+	}
+	return addr, requireToken(token, fi.AdminHandler()), nil
+}
+
+// isLoopback reports whether addr only listens on this machine. A missing
+// host, as in ":8090", listens on every interface.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// requireToken rejects requests without the bearer token
+func requireToken(token string, next http.Handler) http.Handler {
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing or invalid token"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// toRule converts an admin request into a rule
+func (req faultRuleRequest) toRule() (FaultRule, error) {
+	rule := FaultRule{Type: req.Type, Operation: req.Operation, Probability: req.Probability}
+
+	if req.Latency != "" {
+		latency, err := time.ParseDuration(req.Latency)
+		if err != nil {
+			return FaultRule{}, fmt.Errorf("invalid latency: %w", err)
+		}
+		rule.Latency = latency
+	}
+
+	duration := 30 * time.Second
+	if req.Duration != "" {
+		parsed, err := time.ParseDuration(req.Duration)
+		if err != nil {
+			return FaultRule{}, fmt.Errorf("invalid duration: %w", err)
+		}
+		duration = parsed
+	}
+	rule.ExpiresAt = time.Now().Add(duration)
+	return rule, nil
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
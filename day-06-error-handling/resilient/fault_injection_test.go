@@ -0,0 +1,73 @@
+package resilient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdminFromEnv(t *testing.T) {
+	fi := NewFaultInjector()
+	tests := []struct {
+		addr, token string
+		err         string
+	}{
+		{"localhost:8090", "", ""},
+		{"127.0.0.1:8090", "", ""},
+		{"[::1]:8090", "", ""},
+		{":8090", "", "isn't a loopback address"},
+		{"0.0.0.0:8090", "", "isn't a loopback address"},
+		{"10.0.0.5:8090", "", "isn't a loopback address"},
+		{"0.0.0.0:8090", "secret", ""},
+	}
+	for _, tt := range tests {
+		t.Setenv("CHAOS_ADMIN_ADDR", tt.addr)
+		t.Setenv("CHAOS_ADMIN_TOKEN", tt.token)
+		addr, handler, err := fi.AdminFromEnv()
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: expected an error containing %q, got %v", tt.addr, tt.err, err)
+			}
+			continue
+		}
+		if err != nil || addr != tt.addr || handler == nil {
+			t.Errorf("%s: expected the endpoint served, got %q %v", tt.addr, addr, err)
+		}
+	}
+
+	t.Setenv("CHAOS_ADMIN_ADDR", "")
+	if addr, handler, err := fi.AdminFromEnv(); addr != "" || handler != nil || err != nil {
+		t.Errorf("Expected no endpoint without an address, got %q %v", addr, err)
+	}
+}
+
+func TestAdminToken(t *testing.T) {
+	t.Setenv("CHAOS_ADMIN_ADDR", ":8090")
+	t.Setenv("CHAOS_ADMIN_TOKEN", "secret")
+	fi := NewFaultInjector()
+	_, handler, err := fi.AdminFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for header, status := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"secret":        http.StatusUnauthorized,
+		"Bearer secret": http.StatusCreated,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/faults", strings.NewReader(`{"type":"timeout","operation":"chat","probability":1}`))
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != status {
+			t.Errorf("%q: expected %d, got %d", header, status, rec.Code)
+		}
+	}
+	if rules := fi.Rules(); len(rules) != 1 {
+		t.Errorf("Expected only the authorized request to add a fault, got %d", len(rules))
+	}
+}
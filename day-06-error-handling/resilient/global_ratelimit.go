@@ -0,0 +1,137 @@
+package resilient
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// GlobalRateLimitConfig shares one request budget between every agent
+// instance pointed at the same Redis key, e.g. an organization's OpenAI
+// quota. An empty Addr disables it; each instance's own RateLimiter still
+// applies.
+type GlobalRateLimitConfig struct {
+	Addr     string
+	Password string
+	Key      string
+	// Limit requests are admitted across all instances per Window
+	Limit  int
+	Window time.Duration
+	// FailOpen admits requests when Redis can't be reached, relying on the
+	// local limiter alone; otherwise they are rejected
+	FailOpen bool
+}
+
+// GlobalRateLimitFromEnv reads GLOBAL_RATE_LIMIT_REDIS_ADDR,
+// GLOBAL_RATE_LIMIT_REDIS_PASSWORD, GLOBAL_RATE_LIMIT_KEY,
+// GLOBAL_RATE_LIMIT_RPM and GLOBAL_RATE_LIMIT_FAIL_OPEN. The limit is per
+// minute; without an address the global limit is disabled.
+func GlobalRateLimitFromEnv() (GlobalRateLimitConfig, error) {
+	config := GlobalRateLimitConfig{
+		Addr:     os.Getenv("GLOBAL_RATE_LIMIT_REDIS_ADDR"),
+		Password: os.Getenv("GLOBAL_RATE_LIMIT_REDIS_PASSWORD"),
+		Key:      os.Getenv("GLOBAL_RATE_LIMIT_KEY"),
+		Window:   time.Minute,
+		FailOpen: os.Getenv("GLOBAL_RATE_LIMIT_FAIL_OPEN") == "true",
+	}
+	if config.Addr == "" {
+		return config, nil
+	}
+	if config.Key == "" {
+		config.Key = "resilient-agent:rate-limit"
+	}
+	limit, err := strconv.Atoi(os.Getenv("GLOBAL_RATE_LIMIT_RPM"))
+	if err != nil || limit <= 0 {
+		return config, fmt.Errorf("GLOBAL_RATE_LIMIT_RPM must be a positive number of requests per minute")
+	}
+	config.Limit = limit
+	return config, nil
+}
+
+// slidingWindowScript admits a request if fewer than ARGV[1] were admitted
+// in the last ARGV[2] microseconds, recording it as member ARGV[3]. It uses
+// Redis's clock, so instances with skewed clocks agree on the window. It
+// returns {admitted, count, microseconds until a slot frees up}.
+const slidingWindowScript = `
+local key = KEYS[1]
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
+if count < limit then
+  redis.call('ZADD', key, now, ARGV[3])
+  redis.call('PEXPIRE', key, math.ceil(window / 1000))
+  return {1, count + 1, 0}
+end
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+return {0, count, tonumber(oldest[2]) + window - now}
+`
+
+// slidingWindowSHA is the script's SHA1, for EVALSHA
+var slidingWindowSHA = func() string {
+	sum := sha1.Sum([]byte(slidingWindowScript))
+	return hex.EncodeToString(sum[:])
+}()
+
+// GlobalRateLimiter is a sliding-window rate limiter kept in a Redis
+// sorted set, so instances share it. The whole check runs as one Lua
+// script, so concurrent instances can't both take the last slot.
+type GlobalRateLimiter struct {
+	config   GlobalRateLimitConfig
+	client   *redisClient
+	instance string
+	sequence atomic.Int64
+}
+
+// NewGlobalRateLimiter creates a global rate limiter
+func NewGlobalRateLimiter(config GlobalRateLimitConfig) *GlobalRateLimiter {
+	id := make([]byte, 6)
+	rand.Read(id)
+	return &GlobalRateLimiter{
+		config:   config,
+		client:   newRedisClient(config.Addr, config.Password),
+		instance: hex.EncodeToString(id),
+	}
+}
+
+// Allow admits a request against the shared budget. When it doesn't, it
+// returns how long until a slot frees up.
+func (g *GlobalRateLimiter) Allow(ctx context.Context) (bool, time.Duration, error) {
+	ctx, cancel := withRedisTimeout(ctx)
+	defer cancel()
+
+	member := fmt.Sprintf("%s-%d", g.instance, g.sequence.Add(1))
+	args := []string{"1", g.config.Key, strconv.Itoa(g.config.Limit), strconv.FormatInt(g.config.Window.Microseconds(), 10), member}
+	reply, err := g.client.Do(ctx, append([]string{"EVALSHA", slidingWindowSHA}, args...)...)
+	var replyErr redisError
+	if errors.As(err, &replyErr) && strings.HasPrefix(string(replyErr), "NOSCRIPT") {
+		// EVAL loads the script into the cache for the next EVALSHA
+		reply, err = g.client.Do(ctx, append([]string{"EVAL", slidingWindowScript}, args...)...)
+	}
+	if err != nil {
+		return false, 0, err
+	}
+
+	result, ok := reply.([]any)
+	if !ok || len(result) != 3 {
+		return false, 0, fmt.Errorf("redis: unexpected rate limit reply %v", reply)
+	}
+	admitted, _ := result[0].(int64)
+	wait, _ := result[2].(int64)
+	return admitted == 1, time.Duration(wait) * time.Microsecond, nil
+}
+
+// Close closes the limiter's Redis connection
+func (g *GlobalRateLimiter) Close() {
+	g.client.Close()
+}
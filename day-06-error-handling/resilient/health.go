@@ -0,0 +1,172 @@
+package resilient
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ProbeConfig defines the background health probe. A zero Interval
+// disables it, leaving readiness to the circuit breakers alone.
+type ProbeConfig struct {
+	Interval time.Duration
+	Timeout  time.Duration
+	// FailureThreshold is how many probes in a row must fail before the
+	// agent reports itself not ready; one success makes it ready again
+	FailureThreshold int
+}
+
+// ProbeStats reports the health probe's results
+type ProbeStats struct {
+	Ready               bool
+	Probes              int64
+	ConsecutiveFailures int
+	LastProbe           time.Time
+	LastSuccess         time.Time
+	LastError           string
+}
+
+// HealthProber pings the provider in the background, so readiness reflects
+// whether it is reachable even when no requests are being served. Until
+// its first probe the agent is not ready.
+type HealthProber struct {
+	config ProbeConfig
+	probe  func(ctx context.Context) error
+	stats  ProbeStats
+	mu     sync.Mutex
+}
+
+// NewHealthProber creates a prober that calls probe
+func NewHealthProber(config ProbeConfig, probe func(ctx context.Context) error) *HealthProber {
+	return &HealthProber{config: config, probe: probe}
+}
+
+// Run probes immediately and then every Interval until ctx is cancelled
+func (p *HealthProber) Run(ctx context.Context) {
+	if p.config.Interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+	for {
+		p.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check probes once and updates readiness
+func (p *HealthProber) Check(ctx context.Context) ProbeStats {
+	if p.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.config.Timeout)
+		defer cancel()
+	}
+	err := p.probe(ctx)
+
+	p.mu.Lock()
+	wasReady := p.stats.Ready
+	p.stats.Probes++
+	p.stats.LastProbe = time.Now()
+	if err != nil {
+		p.stats.ConsecutiveFailures++
+		p.stats.LastError = err.Error()
+		if p.stats.ConsecutiveFailures >= max(p.config.FailureThreshold, 1) {
+			p.stats.Ready = false
+		}
+	} else {
+		p.stats.ConsecutiveFailures = 0
+		p.stats.LastError = ""
+		p.stats.LastSuccess = p.stats.LastProbe
+		p.stats.Ready = true
+	}
+	stats := p.stats
+	p.mu.Unlock()
+
+	switch {
+	case wasReady && !stats.Ready:
+		slog.Warn("health probe failing, marking not ready", "failures", stats.ConsecutiveFailures, "error", err)
+	case !wasReady && stats.Ready && stats.Probes > 1:
+		slog.Info("health probe recovered, marking ready")
+	case err != nil:
+		slog.Debug("health probe failed", "failures", stats.ConsecutiveFailures, "error", err)
+	}
+	return stats
+}
+
+// Stats returns the result of the last probe
+func (p *HealthProber) Stats() ProbeStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.stats
+}
+
+// ping asks the provider about the primary route's model: a cheap call
+// that costs no tokens. It bypasses Execute, so probes neither use the
+// rate limiter nor show up in request metrics.
+func (ra *ResilientAgent) ping(ctx context.Context) error {
+	route := ra.routes[0]
+	client := route.client
+	if client == nil {
+		client = ra.client
+	}
+	ctx, wait := withRetryAfter(ctx)
+	if _, err := client.GetModel(ctx, route.Model); err != nil {
+		return ra.classifyError(err, *wait)
+	}
+	return nil
+}
+
+// HealthProber returns the agent's background health probe
+func (ra *ResilientAgent) HealthProber() *HealthProber {
+	return ra.prober
+}
+
+// Ready reports whether the agent should receive traffic: the probe, when
+// enabled, last saw the provider reachable, and the chat circuit isn't open
+func (ra *ResilientAgent) Ready() (bool, string) {
+	if ra.config.Probe.Interval > 0 {
+		if stats := ra.prober.Stats(); !stats.Ready {
+			if stats.Probes == 0 {
+				return false, "waiting for the first health probe"
+			}
+			return false, "health probe failing: " + stats.LastError
+		}
+	}
+	if ra.breakers.Get("chat").GetState() == CircuitOpen {
+		return false, "chat circuit breaker is open"
+	}
+	return true, ""
+}
+
+// HealthHandler serves liveness at /healthz and readiness at /readyz, for
+// orchestrators like Kubernetes. /healthz answers 200 while the process
+// runs; /readyz answers 503 while the agent isn't ready. Both describe the
+// agent's health in JSON.
+func (ra *ResilientAgent) HealthHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"status": "ok",
+			"health": ra.GetHealthStatus(),
+		})
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		ready, reason := ra.Ready()
+		body := map[string]any{"status": "ready", "probe": ra.prober.Stats()}
+		status := http.StatusOK
+		if !ready {
+			body["status"] = "not ready"
+			body["reason"] = reason
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, body)
+	})
+	return mux
+}
@@ -0,0 +1,114 @@
+package resilient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestHealthProberReadiness(t *testing.T) {
+	var probeErr error
+	prober := NewHealthProber(ProbeConfig{Interval: time.Minute, FailureThreshold: 2}, func(ctx context.Context) error {
+		return probeErr
+	})
+	ctx := context.Background()
+
+	if prober.Stats().Ready {
+		t.Error("Expected not ready before the first probe")
+	}
+	if stats := prober.Check(ctx); !stats.Ready || stats.LastSuccess.IsZero() {
+		t.Errorf("Expected ready after a successful probe, got %+v", stats)
+	}
+	probeErr = errors.New("network: connection refused")
+	if stats := prober.Check(ctx); !stats.Ready || stats.ConsecutiveFailures != 1 {
+		t.Errorf("Expected one failure below the threshold to stay ready, got %+v", stats)
+	}
+	if stats := prober.Check(ctx); stats.Ready || stats.LastError != probeErr.Error() {
+		t.Errorf("Expected two failures in a row to flip readiness, got %+v", stats)
+	}
+	probeErr = nil
+	if stats := prober.Check(ctx); !stats.Ready || stats.ConsecutiveFailures != 0 || stats.Probes != 4 {
+		t.Errorf("Expected one success to recover, got %+v", stats)
+	}
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	up := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models/gpt-4o" {
+			t.Errorf("Expected the probe to ask about the primary model, got %s", r.URL.Path)
+		}
+		if !up {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":{"message":"overloaded","type":"server_error"}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"gpt-4o","object":"model"}`))
+	}))
+	defer server.Close()
+
+	config := DefaultReliabilityConfig()
+	config.Probe.FailureThreshold = 1
+	config.Fallback.Routes = []ModelRoute{{Model: "gpt-4o"}}
+	agent, err := NewResilientAgent("test-key", config)
+	if err != nil {
+		t.Fatalf("Failed to create agent: %v", err)
+	}
+	clientConfig := openai.DefaultConfig("test-key")
+	clientConfig.BaseURL = server.URL + "/v1"
+	agent.client = openai.NewClientWithConfig(clientConfig)
+	handler := agent.HealthHandler()
+
+	get := func(path string) (int, map[string]any) {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		var body map[string]any
+		if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Expected JSON from %s, got %q", path, recorder.Body.String())
+		}
+		return recorder.Code, body
+	}
+
+	if code, _ := get("/healthz"); code != http.StatusOK {
+		t.Errorf("Expected /healthz to answer 200, got %d", code)
+	}
+	if code, body := get("/readyz"); code != http.StatusServiceUnavailable || body["reason"] != "waiting for the first health probe" {
+		t.Errorf("Expected not ready before probing, got %d %v", code, body)
+	}
+
+	ctx := context.Background()
+	agent.HealthProber().Check(ctx)
+	if code, body := get("/readyz"); code != http.StatusOK {
+		t.Errorf("Expected ready after a successful probe, got %d %v", code, body)
+	}
+
+	up = false
+	agent.HealthProber().Check(ctx)
+	if code, _ := get("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected not ready while the provider fails, got %d", code)
+	}
+	if health := agent.GetHealthStatus(); health.Ready || health.NotReadyReason == "" {
+		t.Errorf("Expected the health status to report not ready, got %+v", health)
+	}
+	if code, _ := get("/healthz"); code != http.StatusOK {
+		t.Errorf("Expected liveness to survive a failing provider, got %d", code)
+	}
+
+	up = true
+	agent.HealthProber().Check(ctx)
+	for i := 0; i < config.CircuitBreaker.FailureThreshold; i++ {
+		agent.Execute(ctx, "chat", func(ctx context.Context) (string, error) {
+			return "", errors.New("invalid request")
+		})
+	}
+	if code, body := get("/readyz"); code != http.StatusServiceUnavailable || body["reason"] != "chat circuit breaker is open" {
+		t.Errorf("Expected the open chat circuit to fail readiness, got %d %v", code, body)
+	}
+}
@@ -0,0 +1,177 @@
+package resilient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrBulkheadFull is returned when a resource has as many requests in
+// flight as its bulkhead allows and none finished within MaxWait
+var ErrBulkheadFull = errors.New("bulkhead full")
+
+// BulkheadConfig caps the requests in flight per resource, so a slow
+// dependency can't tie up every goroutine waiting on it
+type BulkheadConfig struct {
+	// MaxConcurrent caps every resource's requests in flight; zero means
+	// no cap
+	MaxConcurrent int
+	// Limits overrides MaxConcurrent for named resources
+	Limits map[string]int
+	// MaxWait is how long a request waits for a free slot before it is
+	// rejected
+	MaxWait time.Duration
+}
+
+// CircuitBreakers holds a circuit breaker per resource, created on first
+// use, so an endpoint or model that keeps failing only opens its own
+// circuit. Resources are operations such as "chat", "embeddings" and
+// "tool:web_search", and the fallback chain's "model:<route>".
+type CircuitBreakers struct {
+	defaults  CircuitBreakerConfig
+	overrides map[string]CircuitBreakerConfig
+	breakers  map[string]*CircuitBreaker
+	mu        sync.Mutex
+}
+
+// NewCircuitBreakers creates breakers with the defaults config, or its
+// override for the resource
+func NewCircuitBreakers(defaults CircuitBreakerConfig, overrides map[string]CircuitBreakerConfig) *CircuitBreakers {
+	return &CircuitBreakers{
+		defaults:  defaults,
+		overrides: overrides,
+		breakers:  make(map[string]*CircuitBreaker),
+	}
+}
+
+// Get returns the resource's breaker, creating it closed
+func (cbs *CircuitBreakers) Get(resource string) *CircuitBreaker {
+	cbs.mu.Lock()
+	defer cbs.mu.Unlock()
+
+	cb, ok := cbs.breakers[resource]
+	if !ok {
+		config, ok := cbs.overrides[resource]
+		if !ok {
+			config = cbs.defaults
+		}
+		cb = NewCircuitBreaker(config)
+		cbs.breakers[resource] = cb
+	}
+	return cb
+}
+
+// Names returns the resources that have a breaker, sorted
+func (cbs *CircuitBreakers) Names() []string {
+	cbs.mu.Lock()
+	defer cbs.mu.Unlock()
+
+	names := make([]string, 0, len(cbs.breakers))
+	for name := range cbs.breakers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// States returns each breaker's state
+func (cbs *CircuitBreakers) States() map[string]CircuitState {
+	states := make(map[string]CircuitState)
+	for _, name := range cbs.Names() {
+		states[name] = cbs.Get(name).GetState()
+	}
+	return states
+}
+
+// Reset closes every breaker
+func (cbs *CircuitBreakers) Reset() {
+	for _, name := range cbs.Names() {
+		cbs.Get(name).Reset()
+	}
+}
+
+// worstState summarizes states: open if any breaker is, then half-open
+func worstState(states map[string]CircuitState) CircuitState {
+	worst := CircuitClosed
+	for _, state := range states {
+		if state == CircuitOpen {
+			return CircuitOpen
+		}
+		if state == CircuitHalfOpen {
+			worst = CircuitHalfOpen
+		}
+	}
+	return worst
+}
+
+// Bulkheads bound the requests in flight per resource, named like the
+// circuit breakers
+type Bulkheads struct {
+	config BulkheadConfig
+	slots  map[string]chan struct{}
+	mu     sync.Mutex
+}
+
+// NewBulkheads creates bulkheads sized by config
+func NewBulkheads(config BulkheadConfig) *Bulkheads {
+	return &Bulkheads{config: config, slots: make(map[string]chan struct{})}
+}
+
+// limit returns the resource's cap, or 0 for none
+func (b *Bulkheads) limit(resource string) int {
+	if limit, ok := b.config.Limits[resource]; ok {
+		return limit
+	}
+	return b.config.MaxConcurrent
+}
+
+// Acquire takes a slot for resource, waiting up to MaxWait for one. The
+// returned function gives it back.
+func (b *Bulkheads) Acquire(ctx context.Context, resource string) (func(), error) {
+	limit := b.limit(resource)
+	if limit <= 0 {
+		return func() {}, nil
+	}
+	b.mu.Lock()
+	slots, ok := b.slots[resource]
+	if !ok {
+		slots = make(chan struct{}, limit)
+		b.slots[resource] = slots
+	}
+	b.mu.Unlock()
+
+	release := func() { <-slots }
+	select {
+	case slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+	if b.config.MaxWait <= 0 {
+		return nil, fmt.Errorf("%w: %s has %d requests in flight", ErrBulkheadFull, resource, limit)
+	}
+	timer := time.NewTimer(b.config.MaxWait)
+	defer timer.Stop()
+	select {
+	case slots <- struct{}{}:
+		return release, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("%w: %s has had %d requests in flight for %v", ErrBulkheadFull, resource, limit, b.config.MaxWait)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// InFlight returns the requests in flight per capped resource
+func (b *Bulkheads) InFlight() map[string]int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	inFlight := make(map[string]int, len(b.slots))
+	for resource, slots := range b.slots {
+		inFlight[resource] = len(slots)
+	}
+	return inFlight
+}
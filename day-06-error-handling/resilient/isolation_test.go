@@ -0,0 +1,127 @@
+package resilient
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPerResourceCircuitBreakers(t *testing.T) {
+	config := DefaultReliabilityConfig()
+	config.Retry.MaxAttempts = 1
+	config.CircuitBreaker.FailureThreshold = 2
+	config.CircuitBreakerOverrides = map[string]CircuitBreakerConfig{
+		"chat": {FailureThreshold: 5, RecoveryTimeout: time.Minute, ConsecutiveSuccesses: 1},
+	}
+	agent, err := NewResilientAgent("test-key", config)
+	if err != nil {
+		t.Fatalf("Failed to create agent: %v", err)
+	}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		agent.Execute(ctx, "embeddings", func(ctx context.Context) (string, error) {
+			return "", errors.New("server_error: embeddings down")
+		})
+	}
+	if _, err := agent.Execute(ctx, "embeddings", func(ctx context.Context) (string, error) {
+		return "unreachable", nil
+	}); err == nil {
+		t.Error("Expected the embeddings circuit to be open")
+	}
+	if result, err := agent.Execute(ctx, "chat", func(ctx context.Context) (string, error) {
+		return "hello", nil
+	}); err != nil || result != "hello" {
+		t.Errorf("Expected chat to be unaffected by the embeddings outage, got %q, %v", result, err)
+	}
+
+	metrics := agent.GetMetrics()
+	if metrics.CircuitBreakers["embeddings"] != "OPEN" || metrics.CircuitBreakers["chat"] != "CLOSED" || metrics.CircuitBreakerState != "OPEN" {
+		t.Errorf("Expected only embeddings open, got %v (overall %s)", metrics.CircuitBreakers, metrics.CircuitBreakerState)
+	}
+	if health := agent.GetHealthStatus(); len(health.OpenCircuits) != 1 || health.OpenCircuits[0] != "embeddings" {
+		t.Errorf("Expected embeddings reported open, got %v", health.OpenCircuits)
+	}
+
+	if threshold := agent.CircuitBreakers().Get("chat").config.FailureThreshold; threshold != 5 {
+		t.Errorf("Expected chat's override threshold, got %d", threshold)
+	}
+
+	agent.ResetCircuitBreakers()
+	if state := agent.GetMetrics().CircuitBreakerState; state != "CLOSED" {
+		t.Errorf("Expected every circuit closed after a reset, got %s", state)
+	}
+}
+
+func TestBulkheadRejectsWhenFull(t *testing.T) {
+	bulkheads := NewBulkheads(BulkheadConfig{
+		MaxConcurrent: 2,
+		Limits:        map[string]int{"embeddings": 1},
+		MaxWait:       50 * time.Millisecond,
+	})
+	ctx := context.Background()
+
+	release, err := bulkheads.Acquire(ctx, "embeddings")
+	if err != nil {
+		t.Fatalf("Expected a free slot, got %v", err)
+	}
+	if _, err := bulkheads.Acquire(ctx, "embeddings"); !errors.Is(err, ErrBulkheadFull) {
+		t.Errorf("Expected ErrBulkheadFull, got %v", err)
+	}
+	if _, err := bulkheads.Acquire(ctx, "chat"); err != nil {
+		t.Errorf("Expected chat to have its own slots, got %v", err)
+	}
+	if inFlight := bulkheads.InFlight(); inFlight["embeddings"] != 1 || inFlight["chat"] != 1 {
+		t.Errorf("Expected one request in flight each, got %v", inFlight)
+	}
+
+	// A slot freed while waiting is taken
+	go func() {
+		time.Sleep(2 * time.Millisecond)
+		release()
+	}()
+	if _, err := bulkheads.Acquire(ctx, "embeddings"); err != nil {
+		t.Errorf("Expected the freed slot, got %v", err)
+	}
+
+	unbounded := NewBulkheads(BulkheadConfig{})
+	for i := 0; i < 100; i++ {
+		if _, err := unbounded.Acquire(ctx, "chat"); err != nil {
+			t.Fatalf("Expected no cap without MaxConcurrent, got %v", err)
+		}
+	}
+}
+
+func TestBulkheadRejectionInExecute(t *testing.T) {
+	config := DefaultReliabilityConfig()
+	config.Bulkhead = BulkheadConfig{Limits: map[string]int{"embeddings": 1}}
+	agent, err := NewResilientAgent("test-key", config)
+	if err != nil {
+		t.Fatalf("Failed to create agent: %v", err)
+	}
+	ctx := context.Background()
+
+	started, done := make(chan struct{}), make(chan struct{})
+	go agent.Execute(ctx, "embeddings", func(ctx context.Context) (string, error) {
+		close(started)
+		<-done
+		return "ok", nil
+	})
+	<-started
+	defer close(done)
+
+	if _, err := agent.Execute(ctx, "embeddings", func(ctx context.Context) (string, error) {
+		return "ok", nil
+	}); !errors.Is(err, ErrBulkheadFull) {
+		t.Errorf("Expected the full bulkhead to reject, got %v", err)
+	}
+	if _, err := agent.Execute(ctx, "chat", func(ctx context.Context) (string, error) {
+		return "ok", nil
+	}); err != nil {
+		t.Errorf("Expected chat to go through, got %v", err)
+	}
+	if rejected := agent.GetMetrics().BulkheadRejections; rejected != 1 {
+		t.Errorf("Expected one rejection, got %d", rejected)
+	}
+}
@@ -0,0 +1,174 @@
+package resilient
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// MemoryConfig defines when the memory watchdog warns and sheds caches. A
+// zero threshold disables that level.
+type MemoryConfig struct {
+	CheckInterval time.Duration
+	WarnHeapBytes uint64
+	ShedHeapBytes uint64
+}
+
+// Memory pressure levels
+const (
+	MemoryOK   = "ok"
+	MemoryWarn = "warn"
+	MemoryShed = "shed"
+)
+
+// MemoryStats reports the watchdog's last check
+type MemoryStats struct {
+	Level     string
+	HeapAlloc uint64
+	Warnings  int64
+	Sheds     int64
+	LastShed  time.Time
+	Shedders  []string
+}
+
+// shedder is a cache the watchdog can drop under memory pressure
+type shedder struct {
+	name string
+	shed func()
+}
+
+// MemoryWatchdog samples heap usage and reacts before a long-running process
+// runs out of memory: past WarnHeapBytes it logs a warning once, and past
+// ShedHeapBytes it drops every registered cache and returns the freed memory
+// to the OS.
+type MemoryWatchdog struct {
+	config   MemoryConfig
+	shedders []shedder
+	stats    MemoryStats
+	heap     func() uint64
+	mu       sync.Mutex
+}
+
+// NewMemoryWatchdog creates a memory watchdog
+func NewMemoryWatchdog(config MemoryConfig) *MemoryWatchdog {
+	return &MemoryWatchdog{
+		config: config,
+		stats:  MemoryStats{Level: MemoryOK},
+		heap:   heapAlloc,
+	}
+}
+
+// OnShed registers a cache to drop when heap usage crosses ShedHeapBytes.
+// shed must not call back into the watchdog.
+func (w *MemoryWatchdog) OnShed(name string, shed func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.shedders = append(w.shedders, shedder{name: name, shed: shed})
+}
+
+// Run checks heap usage every CheckInterval until ctx is cancelled
+func (w *MemoryWatchdog) Run(ctx context.Context) {
+	if w.config.CheckInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(w.config.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.Check()
+		}
+	}
+}
+
+// Check samples heap usage once, warning or shedding as the thresholds say
+func (w *MemoryWatchdog) Check() MemoryStats {
+	heap := w.heap()
+
+	w.mu.Lock()
+	previous := w.stats.Level
+	level := w.level(heap)
+	w.stats.HeapAlloc = heap
+	w.stats.Level = level
+	shedders := append([]shedder(nil), w.shedders...)
+	w.mu.Unlock()
+
+	switch {
+	case level == MemoryShed:
+		w.shed(heap, shedders)
+	case level == MemoryWarn && previous == MemoryOK:
+		w.mu.Lock()
+		w.stats.Warnings++
+		w.mu.Unlock()
+		slog.Warn("heap usage over the warning threshold",
+			"heap", formatBytes(heap), "threshold", formatBytes(w.config.WarnHeapBytes))
+	case level == MemoryOK && previous != MemoryOK:
+		slog.Info("heap usage back under the warning threshold", "heap", formatBytes(heap))
+	}
+	return w.Stats()
+}
+
+// Stats returns the result of the last check and the registered caches
+func (w *MemoryWatchdog) Stats() MemoryStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	stats := w.stats
+	for _, s := range w.shedders {
+		stats.Shedders = append(stats.Shedders, s.name)
+	}
+	return stats
+}
+
+// level classifies heap usage against the thresholds. Callers must hold w.mu.
+func (w *MemoryWatchdog) level(heap uint64) string {
+	switch {
+	case w.config.ShedHeapBytes > 0 && heap >= w.config.ShedHeapBytes:
+		return MemoryShed
+	case w.config.WarnHeapBytes > 0 && heap >= w.config.WarnHeapBytes:
+		return MemoryWarn
+	default:
+		return MemoryOK
+	}
+}
+
+// shed drops every registered cache, collects garbage and logs what it freed
+func (w *MemoryWatchdog) shed(before uint64, shedders []shedder) {
+	names := make([]string, 0, len(shedders))
+	for _, s := range shedders {
+		s.shed()
+		names = append(names, s.name)
+	}
+	debug.FreeOSMemory()
+	after := w.heap()
+
+	w.mu.Lock()
+	w.stats.Sheds++
+	w.stats.LastShed = time.Now()
+	w.stats.HeapAlloc = after
+	w.stats.Level = w.level(after)
+	w.mu.Unlock()
+
+	slog.Warn("heap usage over the shed threshold, dropped caches",
+		"heap_before", formatBytes(before), "threshold", formatBytes(w.config.ShedHeapBytes),
+		"caches", names, "heap_after", formatBytes(after))
+}
+
+// heapAlloc returns the bytes of allocated heap objects
+func heapAlloc() uint64 {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	return memStats.HeapAlloc
+}
+
+// formatBytes renders a byte count in MB
+func formatBytes(n uint64) string {
+	return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+}
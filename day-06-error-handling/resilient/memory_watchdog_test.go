@@ -0,0 +1,79 @@
+package resilient
+
+import "testing"
+
+// fakeHeap replaces the watchdog's heap sampling with a settable value
+func fakeHeap(w *MemoryWatchdog, heap *uint64) {
+	w.heap = func() uint64 { return *heap }
+}
+
+func TestMemoryWatchdogLevels(t *testing.T) {
+	w := NewMemoryWatchdog(MemoryConfig{WarnHeapBytes: 100, ShedHeapBytes: 200})
+	var heap uint64 = 50
+	fakeHeap(w, &heap)
+
+	if stats := w.Check(); stats.Level != MemoryOK || stats.HeapAlloc != 50 || stats.Warnings != 0 {
+		t.Errorf("Expected ok under the warning threshold, got %+v", stats)
+	}
+
+	// Warnings are counted when the level is entered, not on every check
+	heap = 150
+	for i := 0; i < 3; i++ {
+		if stats := w.Check(); stats.Level != MemoryWarn || stats.Warnings != 1 {
+			t.Errorf("Check %d: expected one warning at the warn level, got %+v", i, stats)
+		}
+	}
+	heap = 50
+	if stats := w.Check(); stats.Level != MemoryOK {
+		t.Errorf("Expected ok once usage dropped, got %+v", stats)
+	}
+	heap = 150
+	if stats := w.Check(); stats.Warnings != 2 {
+		t.Errorf("Expected a second warning after recovering, got %+v", stats)
+	}
+
+	// A zero threshold disables its level
+	disabled := NewMemoryWatchdog(MemoryConfig{ShedHeapBytes: 200})
+	fakeHeap(disabled, &heap)
+	if stats := disabled.Check(); stats.Level != MemoryOK || stats.Warnings != 0 {
+		t.Errorf("Expected no warn level without a threshold, got %+v", stats)
+	}
+}
+
+func TestMemoryWatchdogShed(t *testing.T) {
+	w := NewMemoryWatchdog(MemoryConfig{WarnHeapBytes: 100, ShedHeapBytes: 200})
+	var heap uint64 = 150
+	fakeHeap(w, &heap)
+
+	var shed []string
+	w.OnShed("responses", func() {
+		shed = append(shed, "responses")
+		heap -= 100
+	})
+	w.OnShed("telemetry", func() { shed = append(shed, "telemetry") })
+
+	// Below the shed threshold nothing is dropped
+	w.Check()
+	if len(shed) != 0 {
+		t.Fatalf("Expected no caches dropped at the warn level, got %v", shed)
+	}
+
+	heap = 250
+	stats := w.Check()
+	if len(shed) != 2 || shed[0] != "responses" || shed[1] != "telemetry" {
+		t.Errorf("Expected every cache dropped in order, got %v", shed)
+	}
+	// The level is taken from the heap after shedding
+	if stats.Sheds != 1 || stats.Level != MemoryWarn || stats.HeapAlloc != 150 || stats.LastShed.IsZero() {
+		t.Errorf("Expected one shed leaving the heap at the warn level, got %+v", stats)
+	}
+	if len(stats.Shedders) != 2 {
+		t.Errorf("Expected both caches listed, got %v", stats.Shedders)
+	}
+
+	// Shedding repeats while usage stays over the threshold
+	heap = 400
+	if stats := w.Check(); stats.Sheds != 2 || stats.Level != MemoryShed {
+		t.Errorf("Expected a second shed still over the threshold, got %+v", stats)
+	}
+}
@@ -0,0 +1,286 @@
+package resilient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Priority orders requests waiting for the rate limiter
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+// priorities lists every priority, highest first
+var priorities = []Priority{PriorityHigh, PriorityNormal, PriorityLow}
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityHigh:
+		return "high"
+	case PriorityNormal:
+		return "normal"
+	case PriorityLow:
+		return "low"
+	default:
+		return "unknown"
+	}
+}
+
+// priorityKey is the context key of a request's priority
+type priorityKey struct{}
+
+// WithPriority returns a context whose requests queue at priority, e.g.
+// high for interactive chat and low for batch jobs
+func WithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// PriorityFrom returns the priority set with WithPriority, or normal
+func PriorityFrom(ctx context.Context) Priority {
+	if priority, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return priority
+	}
+	return PriorityNormal
+}
+
+// ErrQueueFull is returned when a priority has MaxQueued requests waiting
+var ErrQueueFull = errors.New("request queue full")
+
+// PriorityQueueConfig makes requests wait for the rate limiter instead of
+// failing straight away. While priorities compete, the limiter's capacity
+// is split by Weights; a priority with nothing queued spills its share to
+// the others, so low priority traffic still gets through when chat is idle.
+type PriorityQueueConfig struct {
+	Enabled bool
+	// Weights are each priority's relative share; a missing one counts as 1
+	Weights map[Priority]float64
+	// MaxWait is the longest a request waits before it is rejected; zero
+	// waits as long as its context allows
+	MaxWait time.Duration
+	// MaxQueued caps each priority's waiting requests; zero means no cap
+	MaxQueued int
+}
+
+// PriorityStats reports one priority's traffic through the queue
+type PriorityStats struct {
+	Queued    int
+	Admitted  int64
+	Rejected  int64 // queue full or waited MaxWait
+	Cancelled int64 // context done while queued
+	AvgWait   time.Duration
+}
+
+// queuePollInterval bounds the dispatcher's sleep while it waits for a
+// token, so it notices the adaptive rate going up
+const queuePollInterval = 100 * time.Millisecond
+
+// queuedRequest is a request waiting for a token
+type queuedRequest struct {
+	admitted chan struct{}
+	enqueued time.Time
+}
+
+// RequestQueue admits requests through the rate limiter by priority, using
+// stride scheduling: each priority's pass advances by 1/weight whenever it
+// is served, and the waiting priority with the lowest pass goes next.
+type RequestQueue struct {
+	config      PriorityQueueConfig
+	limiter     *RateLimiter
+	waiting     map[Priority][]*queuedRequest
+	pass        map[Priority]float64
+	virtual     float64 // pass of the last priority served
+	dispatching bool
+	stats       map[Priority]*PriorityStats
+	totalWait   map[Priority]time.Duration
+	mu          sync.Mutex
+}
+
+// NewRequestQueue creates a queue in front of limiter
+func NewRequestQueue(config PriorityQueueConfig, limiter *RateLimiter) *RequestQueue {
+	q := &RequestQueue{
+		config:    config,
+		limiter:   limiter,
+		waiting:   make(map[Priority][]*queuedRequest),
+		pass:      make(map[Priority]float64),
+		stats:     make(map[Priority]*PriorityStats),
+		totalWait: make(map[Priority]time.Duration),
+	}
+	for _, p := range priorities {
+		q.stats[p] = &PriorityStats{}
+	}
+	return q
+}
+
+// weight returns p's share of capacity
+func (q *RequestQueue) weight(p Priority) float64 {
+	if w, ok := q.config.Weights[p]; ok && w > 0 {
+		return w
+	}
+	return 1
+}
+
+// Acquire waits until the rate limiter admits a request at the context's
+// priority. It fails when the priority's queue is full, after MaxWait, or
+// when ctx is done first.
+func (q *RequestQueue) Acquire(ctx context.Context) error {
+	priority := PriorityFrom(ctx)
+
+	q.mu.Lock()
+	// Nothing waiting means no one to overtake: take a token if there is one
+	if q.queuedLocked() == 0 && q.limiter.Allow() {
+		q.admitLocked(priority, 0)
+		q.mu.Unlock()
+		return nil
+	}
+	if q.config.MaxQueued > 0 && len(q.waiting[priority]) >= q.config.MaxQueued {
+		q.stats[priority].Rejected++
+		q.mu.Unlock()
+		return fmt.Errorf("%w: %d %s priority requests waiting", ErrQueueFull, q.config.MaxQueued, priority)
+	}
+	req := &queuedRequest{admitted: make(chan struct{}), enqueued: time.Now()}
+	if len(q.waiting[priority]) == 0 {
+		// A priority that was idle doesn't get to catch up on the turns
+		// it didn't need
+		q.pass[priority] = max(q.pass[priority], q.virtual)
+	}
+	q.waiting[priority] = append(q.waiting[priority], req)
+	if !q.dispatching {
+		q.dispatching = true
+		go q.dispatch()
+	}
+	q.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if q.config.MaxWait > 0 {
+		timer := time.NewTimer(q.config.MaxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-req.admitted:
+		return nil
+	case <-ctx.Done():
+		if q.remove(priority, req, func(s *PriorityStats) { s.Cancelled++ }) {
+			return ctx.Err()
+		}
+	case <-timeout:
+		if q.remove(priority, req, func(s *PriorityStats) { s.Rejected++ }) {
+			return fmt.Errorf("rate limit exceeded: waited %v in the %s priority queue", q.config.MaxWait, priority)
+		}
+	}
+	// Admitted just as it gave up; the token is spent, so use it
+	return nil
+}
+
+// remove takes req off its queue, counting it with record, and reports
+// whether it was still waiting
+func (q *RequestQueue) remove(priority Priority, req *queuedRequest, record func(*PriorityStats)) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	i := slices.Index(q.waiting[priority], req)
+	if i < 0 {
+		return false
+	}
+	q.waiting[priority] = slices.Delete(q.waiting[priority], i, i+1)
+	record(q.stats[priority])
+	return true
+}
+
+// dispatch hands out tokens to queued requests as the rate limiter frees
+// them, until no request is waiting
+func (q *RequestQueue) dispatch() {
+	for {
+		q.mu.Lock()
+		priority, ok := q.nextLocked()
+		if !ok {
+			q.dispatching = false
+			q.mu.Unlock()
+			return
+		}
+		if q.limiter.Allow() {
+			req := q.waiting[priority][0]
+			q.waiting[priority] = q.waiting[priority][1:]
+			q.virtual = q.pass[priority]
+			q.admitLocked(priority, time.Since(req.enqueued))
+			close(req.admitted)
+			q.mu.Unlock()
+			continue
+		}
+		q.mu.Unlock()
+		wait := max(q.limiter.untilToken(), time.Millisecond)
+		if wait > queuePollInterval {
+			wait = queuePollInterval
+		}
+		time.Sleep(wait)
+	}
+}
+
+// nextLocked returns the waiting priority with the lowest pass, the higher
+// priority on a tie. Callers hold q.mu.
+func (q *RequestQueue) nextLocked() (Priority, bool) {
+	var next Priority
+	found := false
+	for _, p := range priorities {
+		if len(q.waiting[p]) > 0 && (!found || q.pass[p] < q.pass[next]) {
+			next, found = p, true
+		}
+	}
+	return next, found
+}
+
+// admitLocked counts an admitted request. Callers hold q.mu.
+func (q *RequestQueue) admitLocked(priority Priority, waited time.Duration) {
+	q.pass[priority] += 1 / q.weight(priority)
+	q.stats[priority].Admitted++
+	q.totalWait[priority] += waited
+	if waited > 0 {
+		slog.Debug("queued request admitted", "priority", priority, "waited", waited)
+	}
+}
+
+// queuedLocked returns how many requests are waiting. Callers hold q.mu.
+func (q *RequestQueue) queuedLocked() int {
+	n := 0
+	for _, waiting := range q.waiting {
+		n += len(waiting)
+	}
+	return n
+}
+
+// Stats returns each priority's traffic, keyed by its name
+func (q *RequestQueue) Stats() map[string]PriorityStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	stats := make(map[string]PriorityStats, len(priorities))
+	for _, p := range priorities {
+		s := *q.stats[p]
+		s.Queued = len(q.waiting[p])
+		if s.Admitted > 0 {
+			s.AvgWait = q.totalWait[p] / time.Duration(s.Admitted)
+		}
+		stats[p.String()] = s
+	}
+	return stats
+}
+
+// Reset clears the counters, leaving queued requests waiting
+func (q *RequestQueue) Reset() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, p := range priorities {
+		q.stats[p] = &PriorityStats{}
+		q.totalWait[p] = 0
+	}
+}
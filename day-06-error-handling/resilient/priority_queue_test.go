@@ -0,0 +1,116 @@
+package resilient
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// blockedLimiter has no tokens and refills too slowly to matter until
+// unblock raises its rate
+func blockedLimiter() (*RateLimiter, func()) {
+	rl := NewRateLimiter(RateLimitConfig{RequestsPerMinute: 1, BurstSize: 1})
+	rl.tokens = 0
+	return rl, func() {
+		rl.mu.Lock()
+		rl.rate = 60000
+		rl.mu.Unlock()
+	}
+}
+
+func waitQueued(t *testing.T, q *RequestQueue, want int) {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		q.mu.Lock()
+		queued := q.queuedLocked()
+		q.mu.Unlock()
+		if queued == want {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Expected %d queued requests", want)
+}
+
+func TestRequestQueueSharesCapacityByWeight(t *testing.T) {
+	rl, unblock := blockedLimiter()
+	q := NewRequestQueue(PriorityQueueConfig{Enabled: true, Weights: map[Priority]float64{PriorityHigh: 3, PriorityLow: 1}}, rl)
+
+	order := make(chan Priority, 16)
+	for i := 0; i < 8; i++ {
+		for _, p := range []Priority{PriorityHigh, PriorityLow} {
+			go func() {
+				if err := q.Acquire(WithPriority(context.Background(), p)); err == nil {
+					order <- p
+				}
+			}()
+		}
+	}
+	waitQueued(t, q, 16)
+	unblock()
+
+	high := 0
+	for i := 0; i < 8; i++ {
+		if <-order == PriorityHigh {
+			high++
+		}
+	}
+	if high != 6 {
+		t.Errorf("Expected 6 of the first 8 admissions to be high priority at weights 3:1, got %d", high)
+	}
+	// Once high priority is drained, low priority gets all the capacity
+	for i := 0; i < 8; i++ {
+		<-order
+	}
+	stats := q.Stats()
+	if stats["high"].Admitted != 8 || stats["low"].Admitted != 8 || stats["low"].AvgWait <= 0 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestRequestQueueCancellation(t *testing.T) {
+	rl, _ := blockedLimiter()
+	q := NewRequestQueue(PriorityQueueConfig{Enabled: true, MaxQueued: 1}, rl)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- q.Acquire(ctx) }()
+	waitQueued(t, q, 1)
+
+	if err := q.Acquire(context.Background()); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Expected ErrQueueFull, got %v", err)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the cancelled request to give up, got %v", err)
+	}
+	if stats := q.Stats()["normal"]; stats.Cancelled != 1 || stats.Rejected != 1 || stats.Queued != 0 {
+		t.Errorf("Expected one cancelled and one rejected request, got %+v", stats)
+	}
+}
+
+func TestPriorityQueueInExecute(t *testing.T) {
+	config := DefaultReliabilityConfig()
+	config.RateLimit = RateLimitConfig{RequestsPerMinute: 1, BurstSize: 1}
+	config.PriorityQueue.Enabled = true
+	config.PriorityQueue.MaxWait = 20 * time.Millisecond
+	agent, err := NewResilientAgent("test-key", config)
+	if err != nil {
+		t.Fatalf("Failed to create agent: %v", err)
+	}
+	ctx := WithPriority(context.Background(), PriorityHigh)
+	ok := func(ctx context.Context) (string, error) { return "ok", nil }
+
+	if _, err := agent.Execute(ctx, "chat", ok); err != nil {
+		t.Fatalf("Expected the first request through, got %v", err)
+	}
+	if _, err := agent.Execute(ctx, "chat", ok); err == nil || !strings.Contains(err.Error(), "high priority queue") {
+		t.Errorf("Expected the request to give up after MaxWait, got %v", err)
+	}
+	metrics := agent.GetMetrics()
+	if stats := metrics.Priorities["high"]; stats.Admitted != 1 || stats.Rejected != 1 || metrics.RateLimitedRequests != 1 {
+		t.Errorf("Unexpected priority metrics %+v", metrics.Priorities)
+	}
+}
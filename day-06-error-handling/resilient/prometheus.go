@@ -0,0 +1,211 @@
+package resilient
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// defaultLatencyBuckets are the request duration histogram's upper bounds
+// in seconds, from a fast cached answer to a long completion
+var defaultLatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// histogram counts durations into buckets. Unlike the response time window
+// it is never compacted or shed, so rates computed from it stay correct.
+// Callers hold the monitor's lock.
+type histogram struct {
+	bounds []float64
+	counts []uint64 // per bucket, not cumulative; the last is +Inf
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	seconds := d.Seconds()
+	i := 0
+	for i < len(h.bounds) && seconds > h.bounds[i] {
+		i++
+	}
+	h.counts[i]++
+	h.sum += seconds
+	h.count++
+}
+
+func (h *histogram) reset() {
+	clear(h.counts)
+	h.sum = 0
+	h.count = 0
+}
+
+// promSnapshot is what the exporter reads from the monitor under its lock
+type promSnapshot struct {
+	succeeded, failed, rateLimited     int64
+	bulkheadRejected                   int64
+	retriesSucceeded, retriesFailed    int64
+	trips                              int64
+	successLatency, failureLatency     histogram
+	breakers                           []string
+	circuitStates                      map[string]CircuitState
+	inFlight                           map[string]int
+	priorities                         map[string]PriorityStats
+	availableTokens, requestsPerMinute float64
+	rateLimit                          float64
+	routes                             []string
+	servedBy                           map[string]int64
+	hedged                             int64
+}
+
+func (m *Monitor) promSnapshot(cbs *CircuitBreakers, rl *RateLimiter, routes []string) promSnapshot {
+	m.mu.RLock()
+	snap := promSnapshot{
+		succeeded:        m.successfulRequests,
+		failed:           m.failedRequests,
+		rateLimited:      m.rateLimitedRequests,
+		bulkheadRejected: m.bulkheadRejections,
+		retriesSucceeded: m.successfulRetries,
+		retriesFailed:    m.failedRetries,
+		trips:            m.circuitBreakerTrips,
+		successLatency:   *m.successLatency,
+		failureLatency:   *m.failureLatency,
+		servedBy:         maps.Clone(m.servedBy),
+		hedged:           m.hedgedRequests,
+	}
+	snap.successLatency.counts = append([]uint64(nil), m.successLatency.counts...)
+	snap.failureLatency.counts = append([]uint64(nil), m.failureLatency.counts...)
+	m.mu.RUnlock()
+
+	snap.routes = routes
+	snap.breakers = cbs.Names()
+	snap.circuitStates = cbs.States()
+	rl.mu.Lock()
+	rl.trimLocked(time.Now())
+	snap.availableTokens = rl.tokens
+	snap.requestsPerMinute = float64(len(rl.requestTimes))
+	snap.rateLimit = rl.rate
+	rl.mu.Unlock()
+	return snap
+}
+
+// WritePrometheus writes the agent's metrics in the Prometheus text format
+func (ra *ResilientAgent) WritePrometheus(w io.Writer) error {
+	snap := ra.monitor.promSnapshot(ra.breakers, ra.rateLimiter, ra.Routes())
+	snap.inFlight = ra.bulkheads.InFlight()
+	if ra.config.PriorityQueue.Enabled {
+		snap.priorities = ra.queue.Stats()
+	}
+	bw := bufio.NewWriter(w)
+
+	header := func(name, kind, help string) {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+	header("resilient_agent_requests_total", "counter", "Requests by outcome; rate-limited and bulkhead-rejected requests were never sent.")
+	fmt.Fprintf(bw, "resilient_agent_requests_total{outcome=\"success\"} %d\n", snap.succeeded)
+	fmt.Fprintf(bw, "resilient_agent_requests_total{outcome=\"failure\"} %d\n", snap.failed)
+	fmt.Fprintf(bw, "resilient_agent_requests_total{outcome=\"rate_limited\"} %d\n", snap.rateLimited)
+	fmt.Fprintf(bw, "resilient_agent_requests_total{outcome=\"bulkhead_rejected\"} %d\n", snap.bulkheadRejected)
+
+	header("resilient_agent_error_rate", "gauge", "Share of requests that failed since the last reset.")
+	errorRate := 0.0
+	if total := snap.succeeded + snap.failed + snap.rateLimited + snap.bulkheadRejected; total > 0 {
+		errorRate = float64(snap.failed) / float64(total)
+	}
+	fmt.Fprintf(bw, "resilient_agent_error_rate %s\n", formatFloat(errorRate))
+
+	header("resilient_agent_retries_total", "counter", "Retried attempts by outcome.")
+	fmt.Fprintf(bw, "resilient_agent_retries_total{outcome=\"success\"} %d\n", snap.retriesSucceeded)
+	fmt.Fprintf(bw, "resilient_agent_retries_total{outcome=\"failure\"} %d\n", snap.retriesFailed)
+
+	header("resilient_agent_circuit_breaker_trips_total", "counter", "Times any circuit breaker opened.")
+	fmt.Fprintf(bw, "resilient_agent_circuit_breaker_trips_total %d\n", snap.trips)
+
+	header("resilient_agent_circuit_breaker_state", "gauge", "1 for each circuit breaker's current state.")
+	for _, breaker := range snap.breakers {
+		for _, state := range []CircuitState{CircuitClosed, CircuitOpen, CircuitHalfOpen} {
+			current := 0
+			if state == snap.circuitStates[breaker] {
+				current = 1
+			}
+			fmt.Fprintf(bw, "resilient_agent_circuit_breaker_state{breaker=\"%s\",state=\"%s\"} %d\n", breaker, state, current)
+		}
+	}
+
+	header("resilient_agent_bulkhead_in_flight", "gauge", "Requests in flight per bulkhead-capped resource.")
+	for _, resource := range slices.Sorted(maps.Keys(snap.inFlight)) {
+		fmt.Fprintf(bw, "resilient_agent_bulkhead_in_flight{resource=\"%s\"} %d\n", resource, snap.inFlight[resource])
+	}
+
+	header("resilient_agent_rate_limit_tokens", "gauge", "Tokens left in the rate limiter's bucket.")
+	fmt.Fprintf(bw, "resilient_agent_rate_limit_tokens %s\n", formatFloat(snap.availableTokens))
+	header("resilient_agent_requests_per_minute", "gauge", "Requests admitted in the last minute.")
+	fmt.Fprintf(bw, "resilient_agent_requests_per_minute %s\n", formatFloat(snap.requestsPerMinute))
+	header("resilient_agent_rate_limit_requests_per_minute", "gauge", "Requests per minute the rate limiter currently admits.")
+	fmt.Fprintf(bw, "resilient_agent_rate_limit_requests_per_minute %s\n", formatFloat(snap.rateLimit))
+
+	if snap.priorities != nil {
+		header("resilient_agent_priority_requests_total", "counter", "Requests through the priority queue by priority and outcome.")
+		for _, p := range priorities {
+			stats := snap.priorities[p.String()]
+			fmt.Fprintf(bw, "resilient_agent_priority_requests_total{priority=\"%s\",outcome=\"admitted\"} %d\n", p, stats.Admitted)
+			fmt.Fprintf(bw, "resilient_agent_priority_requests_total{priority=\"%s\",outcome=\"rejected\"} %d\n", p, stats.Rejected)
+			fmt.Fprintf(bw, "resilient_agent_priority_requests_total{priority=\"%s\",outcome=\"cancelled\"} %d\n", p, stats.Cancelled)
+		}
+		header("resilient_agent_priority_queue_depth", "gauge", "Requests waiting in the priority queue.")
+		for _, p := range priorities {
+			fmt.Fprintf(bw, "resilient_agent_priority_queue_depth{priority=\"%s\"} %d\n", p, snap.priorities[p.String()].Queued)
+		}
+	}
+
+	header("resilient_agent_responses_total", "counter", "Responses by the fallback route that served them.")
+	for _, route := range snap.routes {
+		fmt.Fprintf(bw, "resilient_agent_responses_total{route=\"%s\"} %d\n", route, snap.servedBy[route])
+	}
+	header("resilient_agent_hedged_requests_total", "counter", "Requests raced against a slow route.")
+	fmt.Fprintf(bw, "resilient_agent_hedged_requests_total %d\n", snap.hedged)
+
+	header("resilient_agent_request_duration_seconds", "histogram", "Request duration, retries included, by outcome.")
+	writeHistogram(bw, "resilient_agent_request_duration_seconds", "success", &snap.successLatency)
+	writeHistogram(bw, "resilient_agent_request_duration_seconds", "failure", &snap.failureLatency)
+
+	return bw.Flush()
+}
+
+// writeHistogram writes h's cumulative buckets, sum and count
+func writeHistogram(w io.Writer, name, outcome string, h *histogram) {
+	var cumulative uint64
+	for i, count := range h.counts {
+		cumulative += count
+		le := "+Inf"
+		if i < len(h.bounds) {
+			le = formatFloat(h.bounds[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{outcome=\"%s\",le=\"%s\"} %d\n", name, outcome, le, cumulative)
+	}
+	fmt.Fprintf(w, "%s_sum{outcome=\"%s\"} %s\n", name, outcome, formatFloat(h.sum))
+	fmt.Fprintf(w, "%s_count{outcome=\"%s\"} %d\n", name, outcome, h.count)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// MetricsHandler serves the agent's metrics to a Prometheus scraper at
+// /metrics
+func (ra *ResilientAgent) MetricsHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := ra.WritePrometheus(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+	return mux
+}
@@ -0,0 +1,73 @@
+package resilient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	config := DefaultReliabilityConfig()
+	config.Retry.BaseDelay = time.Millisecond
+	config.CircuitBreaker.FailureThreshold = 2
+	config.RateLimit.BurstSize = 3
+	config.Monitoring.LatencyBuckets = []float64{0.5, 1}
+	agent, err := NewResilientAgent("test-key", config)
+	if err != nil {
+		t.Fatalf("Failed to create agent: %v", err)
+	}
+	ctx := context.Background()
+
+	// A server error succeeds on its retry
+	calls := 0
+	if _, err := agent.Execute(ctx, "chat", func(ctx context.Context) (string, error) {
+		if calls++; calls == 1 {
+			return "", errors.New("server_error: overloaded")
+		}
+		return "ok", nil
+	}); err != nil {
+		t.Fatalf("Expected the retry to succeed, got %v", err)
+	}
+	// Two failures trip the breaker; the fourth request is rate limited
+	for i := 0; i < 3; i++ {
+		agent.Execute(ctx, "chat", func(ctx context.Context) (string, error) {
+			return "", errors.New("invalid request")
+		})
+	}
+
+	recorder := httptest.NewRecorder()
+	agent.MetricsHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if got := recorder.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Errorf("Expected the Prometheus text format, got %q", got)
+	}
+	body := recorder.Body.String()
+	for _, want := range []string{
+		`resilient_agent_requests_total{outcome="success"} 1`,
+		`resilient_agent_requests_total{outcome="failure"} 2`,
+		`resilient_agent_requests_total{outcome="rate_limited"} 1`,
+		`resilient_agent_error_rate 0.5`,
+		`resilient_agent_retries_total{outcome="success"} 1`,
+		`resilient_agent_retries_total{outcome="failure"} 0`,
+		`resilient_agent_circuit_breaker_trips_total 1`,
+		`resilient_agent_circuit_breaker_state{breaker="chat",state="OPEN"} 1`,
+		`resilient_agent_circuit_breaker_state{breaker="chat",state="CLOSED"} 0`,
+		// The server error halved the adaptive rate
+		`resilient_agent_rate_limit_requests_per_minute 30`,
+		`resilient_agent_request_duration_seconds_bucket{outcome="success",le="0.5"} 1`,
+		`resilient_agent_request_duration_seconds_bucket{outcome="failure",le="+Inf"} 2`,
+		`resilient_agent_request_duration_seconds_count{outcome="failure"} 2`,
+		"# TYPE resilient_agent_request_duration_seconds histogram",
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("Expected %q in:\n%s", want, body)
+		}
+	}
+
+	if metrics := agent.GetMetrics(); metrics.LastCircuitBreakerTrip.IsZero() || metrics.RetrySuccessRate != 1 {
+		t.Errorf("Expected the trip and retry in the metrics, got %+v", metrics)
+	}
+}
@@ -0,0 +1,176 @@
+package resilient
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redisError is an error reply from the server; the connection is still
+// usable after one
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// redisClient speaks just enough of the Redis protocol for the agent's
+// shared state: commands are sent one at a time on a single connection,
+// which is redialed after a network error
+type redisClient struct {
+	addr     string
+	password string
+	conn     net.Conn
+	r        *bufio.Reader
+	mu       sync.Mutex
+}
+
+func newRedisClient(addr, password string) *redisClient {
+	return &redisClient{addr: addr, password: password}
+}
+
+// Do sends a command and returns its reply: a string for simple and bulk
+// strings, an int64 for integers, a []any for arrays, or nil for a nil
+// reply
+func (c *redisClient) Do(ctx context.Context, args ...string) (any, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", c.addr)
+		if err != nil {
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		c.conn, c.r = conn, bufio.NewReader(conn)
+		if c.password != "" {
+			if _, err := c.roundTrip(ctx, "AUTH", c.password); err != nil {
+				c.closeLocked()
+				return nil, err
+			}
+		}
+	}
+	reply, err := c.roundTrip(ctx, args...)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		c.closeLocked()
+	}
+	return reply, err
+}
+
+// Close closes the connection; the next command redials
+func (c *redisClient) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closeLocked()
+}
+
+func (c *redisClient) closeLocked() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn, c.r = nil, nil
+	}
+}
+
+// roundTrip writes args as a RESP array of bulk strings and reads the
+// reply. Callers hold c.mu.
+func (c *redisClient) roundTrip(ctx context.Context, args ...string) (any, error) {
+	deadline, _ := ctx.Deadline()
+	c.conn.SetDeadline(deadline)
+
+	var cmd strings.Builder
+	fmt.Fprintf(&cmd, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&cmd, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.conn, cmd.String()); err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	return readRedisReply(c.r)
+}
+
+// readRedisReply reads one RESP reply
+func readRedisReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad integer %q", line[1:])
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad bulk length %q", line[1:])
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad array length %q", line[1:])
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		// An error element (e.g. from EXEC) leaves the rest of the array
+		// unread, so keep reading to leave the connection at the next reply
+		items := make([]any, n)
+		var firstErr error
+		for i := range items {
+			item, err := readRedisReply(r)
+			var replyErr redisError
+			if err != nil && !errors.As(err, &replyErr) {
+				return nil, err
+			}
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			items[i] = item
+		}
+		if firstErr != nil {
+			return nil, firstErr
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
+
+// redisTimeout bounds a Redis command when the caller's context has no
+// deadline, so a hung server can't stall requests
+const redisTimeout = 2 * time.Second
+
+// withRedisTimeout applies redisTimeout to ctx unless it has a sooner
+// deadline
+func withRedisTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < redisTimeout {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, redisTimeout)
+}
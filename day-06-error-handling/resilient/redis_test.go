@@ -0,0 +1,197 @@
+package resilient
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis serves GET, SET and AUTH from a map, and runs
+// slidingWindowScript in Go for EVAL and EVALSHA, since no Redis server is
+// at hand in tests
+func fakeRedis(t *testing.T, password string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	var mu sync.Mutex
+	values := make(map[string]string)
+	windows := make(map[string][]int64)
+	scripts := make(map[string]bool)
+	slidingWindow := func(key, limitArg, windowArg string) string {
+		limit, _ := strconv.Atoi(limitArg)
+		window, _ := strconv.ParseInt(windowArg, 10, 64)
+		now := time.Now().UnixMicro()
+		kept := windows[key][:0]
+		for _, at := range windows[key] {
+			if at > now-window {
+				kept = append(kept, at)
+			}
+		}
+		windows[key] = kept
+		if len(kept) < limit {
+			windows[key] = append(kept, now)
+			return fmt.Sprintf("*3\r\n:1\r\n:%d\r\n:0\r\n", len(kept)+1)
+		}
+		return fmt.Sprintf("*3\r\n:0\r\n:%d\r\n:%d\r\n", len(kept), kept[0]+window-now)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				authed := password == ""
+				for {
+					args, err := readCommand(r)
+					if err != nil {
+						return
+					}
+					mu.Lock()
+					switch {
+					case args[0] == "AUTH" && args[1] == password:
+						authed = true
+						io.WriteString(conn, "+OK\r\n")
+					case args[0] == "AUTH":
+						io.WriteString(conn, "-WRONGPASS invalid password\r\n")
+					case !authed:
+						io.WriteString(conn, "-NOAUTH Authentication required.\r\n")
+					case args[0] == "SET":
+						values[args[1]] = args[2]
+						io.WriteString(conn, "+OK\r\n")
+					case args[0] == "GET":
+						if value, ok := values[args[1]]; ok {
+							fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(value), value)
+						} else {
+							io.WriteString(conn, "$-1\r\n")
+						}
+					case args[0] == "EVALSHA" && !scripts[args[1]]:
+						io.WriteString(conn, "-NOSCRIPT No matching script. Please use EVAL.\r\n")
+					case args[0] == "EVAL" || args[0] == "EVALSHA":
+						if args[0] == "EVAL" {
+							sum := sha1.Sum([]byte(args[1]))
+							scripts[hex.EncodeToString(sum[:])] = true
+						}
+						io.WriteString(conn, slidingWindow(args[3], args[4], args[5]))
+					}
+					mu.Unlock()
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, n)
+	for i := range args {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(header[1:]))
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(r, arg); err != nil {
+			return nil, err
+		}
+		args[i] = string(arg[:size])
+	}
+	return args, nil
+}
+
+func TestReadRedisReply(t *testing.T) {
+	reply, err := readRedisReply(bufio.NewReader(strings.NewReader("*3\r\n:1\r\n$5\r\nhello\r\n*-1\r\n")))
+	items, ok := reply.([]any)
+	if err != nil || !ok || len(items) != 3 || items[0] != int64(1) || items[1] != "hello" || items[2] != nil {
+		t.Errorf("Unexpected reply %#v, %v", reply, err)
+	}
+	if _, err := readRedisReply(bufio.NewReader(strings.NewReader("-ERR boom\r\n"))); err == nil || err.Error() != "redis: ERR boom" {
+		t.Errorf("Expected the error reply, got %v", err)
+	}
+
+	// An error inside an array is returned after the whole array is read,
+	// so the next reply is read from where it starts
+	r := bufio.NewReader(strings.NewReader("*3\r\n:1\r\n-ERR first\r\n*2\r\n-ERR nested\r\n$2\r\nok\r\n+NEXT\r\n"))
+	if _, err := readRedisReply(r); err == nil || err.Error() != "redis: ERR first" {
+		t.Errorf("Expected the first error in the array, got %v", err)
+	}
+	if reply, err := readRedisReply(r); err != nil || reply != "NEXT" {
+		t.Errorf("Expected the following reply intact, got %#v, %v", reply, err)
+	}
+}
+
+func TestGlobalRateLimiterSharedAcrossInstances(t *testing.T) {
+	addr := fakeRedis(t, "")
+	config := GlobalRateLimitConfig{Addr: addr, Key: "quota", Limit: 3, Window: time.Minute}
+	first, second := NewGlobalRateLimiter(config), NewGlobalRateLimiter(config)
+	defer first.Close()
+	defer second.Close()
+	ctx := context.Background()
+
+	for i, limiter := range []*GlobalRateLimiter{first, second, first} {
+		if allowed, _, err := limiter.Allow(ctx); !allowed || err != nil {
+			t.Fatalf("Expected request %d within the shared limit, got %v, %v", i+1, allowed, err)
+		}
+	}
+	allowed, wait, err := second.Allow(ctx)
+	if allowed || err != nil {
+		t.Fatalf("Expected the fourth request across instances to be rejected, got %v, %v", allowed, err)
+	}
+	if wait <= 0 || wait > time.Minute {
+		t.Errorf("Expected a wait within the window, got %v", wait)
+	}
+}
+
+func TestGlobalRateLimitInExecute(t *testing.T) {
+	addr := fakeRedis(t, "")
+	config := DefaultReliabilityConfig()
+	config.GlobalRateLimit = GlobalRateLimitConfig{Addr: addr, Key: "quota", Limit: 1, Window: time.Minute}
+	agent, err := NewResilientAgent("test-key", config)
+	if err != nil {
+		t.Fatalf("Failed to create agent: %v", err)
+	}
+	ctx := context.Background()
+	ok := func(ctx context.Context) (string, error) { return "ok", nil }
+
+	if _, err := agent.Execute(ctx, "chat", ok); err != nil {
+		t.Fatalf("Expected the first request through, got %v", err)
+	}
+	if _, err := agent.Execute(ctx, "chat", ok); err == nil || !strings.Contains(err.Error(), "global rate limit exceeded") {
+		t.Errorf("Expected the global limit to reject, got %v", err)
+	}
+	if limited := agent.GetMetrics().RateLimitedRequests; limited != 1 {
+		t.Errorf("Expected one rate-limited request, got %d", limited)
+	}
+
+	// Without Redis, requests fail closed unless FailOpen is set
+	config.GlobalRateLimit.Addr = "127.0.0.1:1"
+	closed, _ := NewResilientAgent("test-key", config)
+	if _, err := closed.Execute(ctx, "chat", ok); err == nil || !strings.Contains(err.Error(), "unavailable") {
+		t.Errorf("Expected an unreachable Redis to reject, got %v", err)
+	}
+	config.GlobalRateLimit.FailOpen = true
+	open, _ := NewResilientAgent("test-key", config)
+	if _, err := open.Execute(ctx, "chat", ok); err != nil {
+		t.Errorf("Expected FailOpen to admit the request, got %v", err)
+	}
+}
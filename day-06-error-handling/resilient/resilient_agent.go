@@ -0,0 +1,1354 @@
+package resilient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math"
+	"math/rand"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/sakibmulla/agentic-ai/costs"
+	"github.com/sakibmulla/agentic-ai/endpoint"
+	"github.com/sakibmulla/agentic-ai/logging"
+	"github.com/sakibmulla/agentic-ai/tools"
+	"github.com/sashabaranov/go-openai"
+)
+
+// ResilientAgent represents an AI agent with comprehensive error handling
+type ResilientAgent struct {
+	client        *openai.Client
+	config        *ReliabilityConfig
+	retryManager  *RetryManager
+	breakers      *CircuitBreakers
+	bulkheads     *Bulkheads
+	rateLimiter   *RateLimiter
+	globalLimiter *GlobalRateLimiter // nil without a global rate limit
+	queue         *RequestQueue
+	monitor       *Monitor
+	faultInjector *FaultInjector
+	timeouts      *AdaptiveTimeouts
+	tools         *tools.Registry
+	watchdog      *MemoryWatchdog
+	prober        *HealthProber
+	routes        []ModelRoute
+	mu            sync.RWMutex
+}
+
+// ReliabilityConfig contains all reliability settings
+type ReliabilityConfig struct {
+	Retry          RetryConfig
+	CircuitBreaker CircuitBreakerConfig
+	// CircuitBreakerOverrides tunes the breakers of named resources, e.g.
+	// "embeddings" or "model:gpt-4o"; the rest use CircuitBreaker
+	CircuitBreakerOverrides map[string]CircuitBreakerConfig
+	Bulkhead                BulkheadConfig
+	RateLimit               RateLimitConfig
+	GlobalRateLimit         GlobalRateLimitConfig
+	PriorityQueue           PriorityQueueConfig
+	Monitoring              MonitoringConfig
+	Timeouts                TimeoutConfig
+	Memory                  MemoryConfig
+	Probe                   ProbeConfig
+	Persistence             PersistenceConfig
+	// Fallback lists the models chat requests are served by; empty uses
+	// GPT-3.5 Turbo alone
+	Fallback FallbackChain
+	// Costs, when set, budgets and prices the agent's calls
+	Costs *costs.Limiter
+	// Endpoint is the API the agent calls; the zero value is OpenAI's
+	Endpoint endpoint.Endpoint
+}
+
+// RetryConfig defines retry behavior
+type RetryConfig struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	// MaxDelay caps the backoff. A Retry-After longer than it ends the
+	// retries instead, since retrying sooner would be refused again.
+	MaxDelay          time.Duration
+	BackoffMultiplier float64
+	JitterPercent     int
+	// RetriableErrors are the categories retried for errors without an
+	// HTTP status, matched against the error message
+	RetriableErrors []string
+	// RetriableStatusCodes are the HTTP statuses of API failures that are
+	// retried (default 408, 409, 429 and 5xx), and RetriableErrorCodes
+	// OpenAI error codes retried whatever their status.
+	// NonRetriableErrorCodes are never retried (default insufficient_quota).
+	RetriableStatusCodes   []int
+	RetriableErrorCodes    []string
+	NonRetriableErrorCodes []string
+}
+
+// CircuitBreakerConfig defines circuit breaker behavior
+type CircuitBreakerConfig struct {
+	FailureThreshold     int
+	RecoveryTimeout      time.Duration
+	TestRequestRate      float64
+	ConsecutiveSuccesses int
+}
+
+// RateLimitConfig defines rate limiting behavior
+type RateLimitConfig struct {
+	RequestsPerMinute int
+	BurstSize         int
+	// AdaptiveEnabled adjusts the rate AIMD-style: starting at
+	// RequestsPerMinute, it grows by IncreaseStep after every IncreaseAfter
+	// consecutive successful attempts and is multiplied by DecreaseFactor on
+	// a rate limit error, server error or timeout, staying between
+	// MinRequestsPerMinute and MaxRequestsPerMinute
+	AdaptiveEnabled      bool
+	MinRequestsPerMinute int
+	MaxRequestsPerMinute int
+	IncreaseAfter        int
+	IncreaseStep         float64
+	DecreaseFactor       float64
+	QuotaPercentage      float64
+}
+
+// MonitoringConfig defines monitoring behavior
+type MonitoringConfig struct {
+	MetricsEnabled      bool
+	HealthChecksEnabled bool
+	AlertThreshold      float64
+	// MetricsRetention is how long response times and idle operations'
+	// timeout models are kept
+	MetricsRetention time.Duration
+	// ResponseWindow caps how many recent response times are kept
+	ResponseWindow int
+	// CompactionInterval is how often StartMaintenance drops telemetry
+	// older than MetricsRetention
+	CompactionInterval time.Duration
+	// LatencyBuckets are the upper bounds, in seconds, of the request
+	// duration histogram exported to Prometheus
+	LatencyBuckets []float64
+}
+
+// RetryManager handles retry logic with exponential backoff
+type RetryManager struct {
+	config RetryConfig
+	random *rand.Rand
+}
+
+// CircuitBreaker implements the circuit breaker pattern
+type CircuitBreaker struct {
+	config          CircuitBreakerConfig
+	state           CircuitState
+	failureCount    int
+	lastFailureTime time.Time
+	successCount    int
+	mu              sync.RWMutex
+}
+
+// CircuitState represents the circuit breaker state
+type CircuitState int
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "CLOSED"
+	case CircuitOpen:
+		return "OPEN"
+	case CircuitHalfOpen:
+		return "HALF_OPEN"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// RateLimiter implements token bucket rate limiting
+type RateLimiter struct {
+	config       RateLimitConfig
+	tokens       float64
+	lastRefill   time.Time
+	requestTimes []time.Time
+	rate         float64 // requests per minute currently admitted
+	successes    int     // consecutive successes since the rate last changed
+	lastDecrease time.Time
+	mu           sync.Mutex
+}
+
+// adaptiveDecreaseCooldown keeps a burst of concurrent failures, all caused
+// by the same overload, from cutting the rate more than once
+const adaptiveDecreaseCooldown = time.Second
+
+// defaultResponseWindow is how many recent response times the monitor keeps
+// when MonitoringConfig.ResponseWindow isn't set
+const defaultResponseWindow = 1000
+
+// percentileScratch holds buffers GetMetrics selects percentiles in, so
+// each call doesn't copy the window into a new slice
+var percentileScratch = sync.Pool{
+	New: func() any {
+		buf := make([]time.Duration, 0, defaultResponseWindow)
+		return &buf
+	},
+}
+
+// Monitor collects metrics and health information
+type Monitor struct {
+	config              MonitoringConfig
+	totalRequests       int64
+	successfulRequests  int64
+	failedRequests      int64
+	totalRetries        int64
+	successfulRetries   int64
+	failedRetries       int64
+	circuitBreakerTrips int64
+	lastTrip            time.Time
+	rateLimitedRequests int64
+	bulkheadRejections  int64
+	successLatency      *histogram // every request's duration, unlike the window
+	failureLatency      *histogram
+	responseTimes       []time.Duration // ring of the last window times
+	responseAt          []time.Time     // when each of responseTimes was recorded
+	responseNext        int
+	window              int
+	lastAPISuccess      time.Time
+	lastAPIFailure      time.Time
+	servedBy            map[string]int64 // responses by the route that served them
+	fallbackResponses   int64
+	hedgedRequests      int64
+	mu                  sync.RWMutex
+}
+
+// Metrics represents system metrics
+type Metrics struct {
+	TotalRequests       int64
+	SuccessfulRequests  int64
+	FailedRequests      int64
+	ErrorRate           float64
+	TotalRetries        int64
+	SuccessfulRetries   int64
+	FailedRetries       int64
+	RetrySuccessRate    float64
+	CircuitBreakerTrips int64
+	// CircuitBreakerState is the worst state of any breaker, and
+	// CircuitBreakers each resource's
+	CircuitBreakerState    string
+	CircuitBreakers        map[string]string
+	LastCircuitBreakerTrip time.Time
+	RateLimitedRequests    int64
+	RequestsPerMinute      float64
+	// EffectiveRateLimit is the requests per minute the rate limiter
+	// currently admits, which moves when adaptive rate control is on
+	EffectiveRateLimit float64
+	QuotaUsage         float64
+	AvgResponseTime    time.Duration
+	P95ResponseTime    time.Duration
+	FastestResponse    time.Duration
+	SlowestResponse    time.Duration
+	// ServedBy counts responses by the fallback route that served them;
+	// FallbackResponses are those not served by the first route
+	ServedBy          map[string]int64
+	FallbackResponses int64
+	HedgedRequests    int64
+	// BulkheadRejections counts requests turned away by a full bulkhead;
+	// BulkheadInFlight is each capped resource's requests in flight
+	BulkheadRejections int64
+	BulkheadInFlight   map[string]int
+	// Priorities is each priority's traffic through the request queue,
+	// when it's enabled
+	Priorities map[string]PriorityStats
+}
+
+// HealthStatus represents system health
+type HealthStatus struct {
+	Overall            bool
+	APIConnection      bool
+	CircuitBreakerOpen bool
+	OpenCircuits       []string
+	// Ready is whether the agent should receive traffic, and NotReadyReason
+	// why not
+	Ready               bool
+	NotReadyReason      string
+	RateLimitExceeded   bool
+	LastAPISuccess      time.Time
+	LastAPIFailure      time.Time
+	ConsecutiveFailures int
+	AvailableTokens     int
+	MemoryUsage         float64
+	GoroutineCount      int
+}
+
+// DefaultReliabilityConfig returns default reliability settings
+func DefaultReliabilityConfig() *ReliabilityConfig {
+	return &ReliabilityConfig{
+		Retry: RetryConfig{
+			MaxAttempts:       3,
+			BaseDelay:         100 * time.Millisecond,
+			MaxDelay:          30 * time.Second,
+			BackoffMultiplier: 2.0,
+			JitterPercent:     25,
+			RetriableErrors:   []string{"rate_limit", "timeout", "server_error", "network"},
+		},
+		CircuitBreaker: CircuitBreakerConfig{
+			FailureThreshold:     5,
+			RecoveryTimeout:      60 * time.Second,
+			TestRequestRate:      0.1,
+			ConsecutiveSuccesses: 3,
+		},
+		Bulkhead: BulkheadConfig{
+			MaxConcurrent: 16,
+			MaxWait:       2 * time.Second,
+		},
+		RateLimit: RateLimitConfig{
+			RequestsPerMinute:    60,
+			BurstSize:            10,
+			AdaptiveEnabled:      true,
+			MinRequestsPerMinute: 6,
+			MaxRequestsPerMinute: 120,
+			IncreaseAfter:        10,
+			IncreaseStep:         5,
+			DecreaseFactor:       0.5,
+			QuotaPercentage:      80.0,
+		},
+		PriorityQueue: PriorityQueueConfig{
+			Weights:   map[Priority]float64{PriorityHigh: 6, PriorityNormal: 3, PriorityLow: 1},
+			MaxWait:   10 * time.Second,
+			MaxQueued: 100,
+		},
+		Monitoring: MonitoringConfig{
+			MetricsEnabled:      true,
+			HealthChecksEnabled: true,
+			AlertThreshold:      0.05, // 5% error rate
+			MetricsRetention:    24 * time.Hour,
+			ResponseWindow:      defaultResponseWindow,
+			CompactionInterval:  time.Minute,
+			LatencyBuckets:      defaultLatencyBuckets,
+		},
+		Timeouts: TimeoutConfig{
+			Default:    30 * time.Second,
+			Min:        2 * time.Second,
+			Max:        60 * time.Second,
+			MinSamples: 5,
+			Factor:     1.5,
+			Alpha:      0.2,
+		},
+		Memory: MemoryConfig{
+			CheckInterval: 30 * time.Second,
+			WarnHeapBytes: 256 << 20,
+			ShedHeapBytes: 512 << 20,
+		},
+		Probe: ProbeConfig{
+			Interval:         30 * time.Second,
+			Timeout:          5 * time.Second,
+			FailureThreshold: 3,
+		},
+		Persistence: PersistenceConfig{
+			SaveInterval: 10 * time.Second,
+			MaxAge:       time.Hour,
+		},
+	}
+}
+
+// NewResilientAgent creates a new resilient AI agent
+func NewResilientAgent(apiKey string, config *ReliabilityConfig) (*ResilientAgent, error) {
+	if config == nil {
+		config = DefaultReliabilityConfig()
+	}
+	if apiKey == "" && config.Endpoint.RequiresKey() {
+		return nil, fmt.Errorf("API key is required")
+	}
+
+	clientConfig := config.Endpoint.Config(apiKey)
+	httpClient := &http.Client{}
+	if config.Costs != nil {
+		httpClient = config.Costs.HTTPClient()
+	}
+	httpClient.Transport = captureRetryAfter(httpClient.Transport)
+	// Secondary providers serve the models their routes name, so only the
+	// primary client maps models to the endpoint's local ones
+	clientConfig.HTTPClient = config.Endpoint.HTTPClient(httpClient)
+	client := openai.NewClientWithConfig(clientConfig)
+
+	agent := &ResilientAgent{
+		client:        client,
+		config:        config,
+		retryManager:  NewRetryManager(config.Retry),
+		breakers:      NewCircuitBreakers(config.CircuitBreaker, config.CircuitBreakerOverrides),
+		bulkheads:     NewBulkheads(config.Bulkhead),
+		rateLimiter:   NewRateLimiter(config.RateLimit),
+		monitor:       NewMonitor(config.Monitoring),
+		faultInjector: NewFaultInjector(),
+		timeouts:      NewAdaptiveTimeouts(config.Timeouts),
+		tools:         tools.NewRegistry(),
+		watchdog:      NewMemoryWatchdog(config.Memory),
+		routes:        newRoutes(config.Fallback, httpClient),
+	}
+
+	agent.prober = NewHealthProber(config.Probe, agent.ping)
+	agent.queue = NewRequestQueue(config.PriorityQueue, agent.rateLimiter)
+	if config.GlobalRateLimit.Addr != "" {
+		agent.globalLimiter = NewGlobalRateLimiter(config.GlobalRateLimit)
+	}
+
+	// Under memory pressure, telemetry is the first thing to go; learned
+	// timeouts are kept unless their operation has gone quiet
+	agent.watchdog.OnShed("response times", agent.monitor.Shed)
+	agent.watchdog.OnShed("idle timeout models", func() { agent.timeouts.Compact(time.Minute) })
+	agent.watchdog.OnShed("expired faults", func() { agent.faultInjector.Prune() })
+
+	// Pick up where a previous run left off, so a degraded API isn't hit
+	// at full rate straight after a restart
+	if err := agent.RestoreState(context.Background()); err != nil && !errors.Is(err, ErrNoSavedState) {
+		slog.Warn("starting with fresh circuit breakers", "error", err)
+	}
+
+	return agent, nil
+}
+
+// NewRetryManager creates a new retry manager
+func NewRetryManager(config RetryConfig) *RetryManager {
+	return &RetryManager{
+		config: config,
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// NewCircuitBreaker creates a new circuit breaker
+func NewCircuitBreaker(config CircuitBreakerConfig) *CircuitBreaker {
+	return &CircuitBreaker{
+		config: config,
+		state:  CircuitClosed,
+	}
+}
+
+// NewRateLimiter creates a new rate limiter. Unset adaptive settings keep
+// the rate between 1 and RequestsPerMinute, growing by one after 10
+// successes and halving on overload.
+func NewRateLimiter(config RateLimitConfig) *RateLimiter {
+	if config.AdaptiveEnabled {
+		if config.MinRequestsPerMinute <= 0 {
+			config.MinRequestsPerMinute = 1
+		}
+		if config.MaxRequestsPerMinute < config.RequestsPerMinute {
+			config.MaxRequestsPerMinute = config.RequestsPerMinute
+		}
+		if config.IncreaseAfter <= 0 {
+			config.IncreaseAfter = 10
+		}
+		if config.IncreaseStep <= 0 {
+			config.IncreaseStep = 1
+		}
+		if config.DecreaseFactor <= 0 || config.DecreaseFactor >= 1 {
+			config.DecreaseFactor = 0.5
+		}
+	}
+	return &RateLimiter{
+		config:     config,
+		tokens:     float64(config.BurstSize),
+		lastRefill: time.Now(),
+		rate:       float64(config.RequestsPerMinute),
+	}
+}
+
+// NewMonitor creates a new monitor
+func NewMonitor(config MonitoringConfig) *Monitor {
+	window := config.ResponseWindow
+	if window <= 0 {
+		window = defaultResponseWindow
+	}
+	buckets := config.LatencyBuckets
+	if len(buckets) == 0 {
+		buckets = defaultLatencyBuckets
+	}
+	return &Monitor{
+		config:         config,
+		successLatency: newHistogram(buckets),
+		failureLatency: newHistogram(buckets),
+		responseTimes:  make([]time.Duration, 0, window),
+		responseAt:     make([]time.Time, 0, window),
+		window:         window,
+		servedBy:       make(map[string]int64),
+	}
+}
+
+// Chat sends a message and returns a response with full error handling
+func (ra *ResilientAgent) Chat(ctx context.Context, message string) (string, error) {
+	response, _, err := ra.ChatWithRoute(ctx, message)
+	return response, err
+}
+
+// ChatWithRoute is Chat that also returns the name of the fallback route
+// that served the response
+func (ra *ResilientAgent) ChatWithRoute(ctx context.Context, message string) (string, string, error) {
+	if ra.tools.Len() > 0 {
+		return ra.chatWithTools(ctx, message)
+	}
+	var route string
+	response, err := ra.Execute(ctx, "chat", func(ctx context.Context) (string, error) {
+		var content string
+		var err error
+		content, route, err = ra.performRequest(ctx, message)
+		return content, err
+	})
+	return response, route, err
+}
+
+// allowGlobal checks the rate limit shared with other instances, if any.
+// Redis being unreachable rejects the request unless FailOpen is set.
+func (ra *ResilientAgent) allowGlobal(ctx context.Context, operation string) error {
+	if ra.globalLimiter == nil {
+		return nil
+	}
+	allowed, wait, err := ra.globalLimiter.Allow(ctx)
+	switch {
+	case err != nil && ra.config.GlobalRateLimit.FailOpen:
+		slog.WarnContext(ctx, "global rate limit unavailable, using the local limit alone", "operation", operation, "error", err)
+		return nil
+	case err != nil:
+		slog.WarnContext(ctx, "global rate limit unavailable", "operation", operation, "error", err)
+		return fmt.Errorf("global rate limit unavailable: %w", err)
+	case !allowed:
+		slog.WarnContext(ctx, "global rate limited", "operation", operation, "retry_in", wait)
+		return fmt.Errorf("global rate limit exceeded, next slot in %v", wait.Round(time.Millisecond))
+	}
+	return nil
+}
+
+// Execute runs any operation (e.g. "chat", "embeddings", "tool:web_search")
+// behind the rate limiter, circuit breaker and retry logic. Each attempt gets
+// the operation's adaptive timeout, and injected faults targeting the
+// operation are applied before it.
+func (ra *ResilientAgent) Execute(ctx context.Context, operation string, fn func(ctx context.Context) (string, error)) (string, error) {
+	startTime := time.Now()
+	ctx = logging.EnsureCorrelationID(ctx)
+
+	// Check rate limit, waiting in the priority queue when it's enabled
+	if ra.config.PriorityQueue.Enabled {
+		if err := ra.queue.Acquire(ctx); err != nil {
+			ra.monitor.RecordRateLimited()
+			slog.WarnContext(ctx, "rate limited", "operation", operation, "priority", PriorityFrom(ctx), "error", err)
+			return "", err
+		}
+	} else if !ra.rateLimiter.Allow() {
+		ra.monitor.RecordRateLimited()
+		slog.WarnContext(ctx, "rate limited", "operation", operation)
+		return "", fmt.Errorf("rate limit exceeded")
+	}
+	if err := ra.allowGlobal(ctx, operation); err != nil {
+		ra.monitor.RecordRateLimited()
+		return "", err
+	}
+
+	// Check the operation's own circuit breaker, so one failing endpoint
+	// doesn't block the others
+	breaker := ra.breakers.Get(operation)
+	if !breaker.Allow() {
+		ra.monitor.RecordFailure(time.Since(startTime))
+		slog.WarnContext(ctx, "circuit breaker is open", "operation", operation)
+		return "", fmt.Errorf("circuit breaker is open for %s", operation)
+	}
+
+	// Wait for a slot in the operation's bulkhead
+	release, err := ra.bulkheads.Acquire(ctx, operation)
+	if err != nil {
+		ra.monitor.RecordBulkheadRejected()
+		slog.WarnContext(ctx, "bulkhead rejected request", "operation", operation, "error", err)
+		return "", err
+	}
+	defer release()
+
+	// Perform the request with retry logic
+	attempts := 0
+	response, err := ra.retryManager.Execute(ctx, func() (result string, err error) {
+		attempts++
+		if attempts > 1 {
+			defer func() { ra.monitor.RecordRetry(err == nil) }()
+		}
+		attemptCtx, cancel, timeout := ra.timeouts.WithTimeout(ctx, operation)
+		defer cancel()
+		attemptStart := time.Now()
+
+		// Check for fault injection
+		err = ra.faultInjector.Apply(attemptCtx, operation)
+		if err == nil {
+			result, err = fn(attemptCtx)
+		}
+
+		if attemptCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			ra.timeouts.Observe(operation, timeout, true)
+			ra.rateLimiter.RecordOverload()
+			return "", fmt.Errorf("timeout: %s exceeded its adaptive timeout of %v", operation, timeout)
+		}
+		// Fast failures say nothing about latency, so only successes are observed
+		if err == nil {
+			ra.timeouts.Observe(operation, time.Since(attemptStart), false)
+			ra.rateLimiter.RecordSuccess()
+		} else {
+			if isOverload(err) {
+				ra.rateLimiter.RecordOverload()
+			}
+			slog.WarnContext(ctx, "attempt failed", "operation", operation, "attempt", attempts, "error", err)
+		}
+		return result, err
+	})
+
+	duration := time.Since(startTime)
+
+	if err != nil {
+		if breaker.RecordFailure() {
+			ra.monitor.RecordTrip()
+			slog.WarnContext(ctx, "circuit breaker opened", "operation", operation)
+		}
+		ra.monitor.RecordFailure(duration)
+		slog.ErrorContext(ctx, "operation failed", "operation", operation, "attempts", attempts, "duration_ms", duration.Milliseconds(), "error", err)
+		return "", err
+	}
+
+	breaker.RecordSuccess()
+	ra.monitor.RecordSuccess(duration)
+	slog.DebugContext(ctx, "operation succeeded", "operation", operation, "attempts", attempts, "duration_ms", duration.Milliseconds())
+	return response, nil
+}
+
+// performRequest makes the actual API request through the fallback chain,
+// returning the response and the route that served it
+func (ra *ResilientAgent) performRequest(ctx context.Context, message string) (string, string, error) {
+	req := openai.ChatCompletionRequest{
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: message,
+			},
+		},
+		MaxTokens:   150,
+		Temperature: 0.7,
+	}
+
+	reply, route, err := ra.complete(ctx, req)
+	return reply.Content, route, err
+}
+
+// Execute performs an operation with retry logic
+func (rm *RetryManager) Execute(ctx context.Context, operation func() (string, error)) (string, error) {
+	var lastErr error
+
+	for attempt := 1; attempt <= rm.config.MaxAttempts; attempt++ {
+		result, err := operation()
+		if err == nil {
+			return result, nil
+		}
+
+		lastErr = err
+
+		// Don't retry if it's the last attempt or error is not retriable
+		if attempt == rm.config.MaxAttempts || !rm.isRetriable(err) {
+			break
+		}
+
+		// Calculate delay with exponential backoff and jitter, waiting at
+		// least as long as the server asked
+		delay := rm.calculateDelay(attempt)
+		if wait := retryAfter(err); wait > 0 {
+			if rm.config.MaxDelay > 0 && wait > rm.config.MaxDelay {
+				break
+			}
+			delay = max(delay, wait)
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(delay):
+			// Continue to next attempt
+		}
+	}
+
+	return "", lastErr
+}
+
+// calculateDelay calculates the delay for the next retry attempt
+func (rm *RetryManager) calculateDelay(attempt int) time.Duration {
+	exponentialDelay := float64(rm.config.BaseDelay) * math.Pow(rm.config.BackoffMultiplier, float64(attempt-1))
+
+	// Apply maximum delay cap
+	if exponentialDelay > float64(rm.config.MaxDelay) {
+		exponentialDelay = float64(rm.config.MaxDelay)
+	}
+
+	// Add jitter to prevent thundering herd
+	jitter := 1.0
+	if rm.config.JitterPercent > 0 {
+		jitterRange := float64(rm.config.JitterPercent) / 100.0
+		jitter = 1.0 + (rm.random.Float64()*2-1)*jitterRange
+	}
+
+	finalDelay := time.Duration(exponentialDelay * jitter)
+	return finalDelay
+}
+
+// Allow checks if a request is allowed through the circuit breaker
+func (cb *CircuitBreaker) Allow() bool {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	switch cb.state {
+	case CircuitClosed:
+		return true
+	case CircuitOpen:
+		return cb.shouldAttemptReset()
+	case CircuitHalfOpen:
+		return cb.shouldAllowTestRequest()
+	default:
+		return false
+	}
+}
+
+// shouldAttemptReset checks if the circuit breaker should attempt to reset
+func (cb *CircuitBreaker) shouldAttemptReset() bool {
+	return time.Since(cb.lastFailureTime) >= cb.config.RecoveryTimeout
+}
+
+// shouldAllowTestRequest checks if a test request should be allowed in half-open state
+func (cb *CircuitBreaker) shouldAllowTestRequest() bool {
+	return rand.Float64() < cb.config.TestRequestRate
+}
+
+// RecordFailure records a failure in the circuit breaker, reporting whether
+// it opened the circuit
+func (cb *CircuitBreaker) RecordFailure() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.failureCount++
+	cb.lastFailureTime = time.Now()
+	cb.successCount = 0
+
+	if cb.state == CircuitClosed && cb.failureCount >= cb.config.FailureThreshold ||
+		cb.state == CircuitHalfOpen {
+		cb.state = CircuitOpen
+		return true
+	}
+	return false
+}
+
+// RecordSuccess records a success in the circuit breaker
+func (cb *CircuitBreaker) RecordSuccess() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.failureCount = 0
+	cb.successCount++
+
+	if cb.state == CircuitOpen {
+		cb.state = CircuitHalfOpen
+	} else if cb.state == CircuitHalfOpen && cb.successCount >= cb.config.ConsecutiveSuccesses {
+		cb.state = CircuitClosed
+		cb.successCount = 0
+	}
+}
+
+// Allow checks if a request is allowed by the rate limiter
+func (rl *RateLimiter) Allow() bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+
+	// Refill tokens based on time elapsed
+	elapsed := now.Sub(rl.lastRefill)
+	tokensToAdd := elapsed.Seconds() * rl.rate / 60.0
+
+	rl.tokens = math.Min(rl.tokens+tokensToAdd, float64(rl.config.BurstSize))
+	rl.lastRefill = now
+
+	// Check if we have tokens available
+	if rl.tokens >= 1.0 {
+		rl.tokens--
+
+		// Record request time for rate calculation
+		rl.trimLocked(now)
+		rl.requestTimes = append(rl.requestTimes, now)
+
+		return true
+	}
+
+	return false
+}
+
+// RecordSuccess counts a successful attempt, raising the rate by
+// IncreaseStep after IncreaseAfter in a row when adaptive control is on
+func (rl *RateLimiter) RecordSuccess() {
+	if !rl.config.AdaptiveEnabled {
+		return
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.successes++
+	if rl.successes >= rl.config.IncreaseAfter {
+		rl.successes = 0
+		rl.rate = math.Min(rl.rate+rl.config.IncreaseStep, float64(rl.config.MaxRequestsPerMinute))
+	}
+}
+
+// RecordOverload backs off after a rate limit error, server error or
+// timeout when adaptive control is on, multiplying the rate by
+// DecreaseFactor
+func (rl *RateLimiter) RecordOverload() {
+	if !rl.config.AdaptiveEnabled {
+		return
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.successes = 0
+	now := time.Now()
+	if now.Sub(rl.lastDecrease) < adaptiveDecreaseCooldown {
+		return
+	}
+	rl.lastDecrease = now
+	rl.rate = math.Max(rl.rate*rl.config.DecreaseFactor, float64(rl.config.MinRequestsPerMinute))
+}
+
+// untilToken returns how long until the bucket holds a whole token
+func (rl *RateLimiter) untilToken() time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	tokens := math.Min(rl.tokens+time.Since(rl.lastRefill).Seconds()*rl.rate/60.0, float64(rl.config.BurstSize))
+	if tokens >= 1 || rl.rate <= 0 {
+		return 0
+	}
+	return time.Duration((1 - tokens) * 60 / rl.rate * float64(time.Second))
+}
+
+// Rate returns the requests per minute the limiter currently admits
+func (rl *RateLimiter) Rate() float64 {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	return rl.rate
+}
+
+// isOverload reports whether err says the API is overloaded, which adaptive
+// rate control backs off on
+func isOverload(err error) bool {
+	errStr := err.Error()
+	return contains(errStr, "rate_limit") || contains(errStr, "server_error") || contains(errStr, "timeout")
+}
+
+// Compact drops request times older than a minute
+func (rl *RateLimiter) Compact() int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	return rl.trimLocked(time.Now())
+}
+
+// trimLocked keeps only the last minute of request times, moving them to the
+// front of the slice so it never grows past a minute's worth. It returns how
+// many were dropped. Callers must hold rl.mu.
+func (rl *RateLimiter) trimLocked(now time.Time) int {
+	cutoff := now.Add(-time.Minute)
+	i := 0
+	for i < len(rl.requestTimes) && !rl.requestTimes[i].After(cutoff) {
+		i++
+	}
+	if i > 0 {
+		rl.requestTimes = rl.requestTimes[:copy(rl.requestTimes, rl.requestTimes[i:])]
+	}
+	return i
+}
+
+// GetMetrics returns current system metrics
+func (ra *ResilientAgent) GetMetrics() Metrics {
+	metrics := ra.monitor.GetMetrics(ra.breakers, ra.rateLimiter)
+	metrics.BulkheadInFlight = ra.bulkheads.InFlight()
+	if ra.config.PriorityQueue.Enabled {
+		metrics.Priorities = ra.queue.Stats()
+	}
+	return metrics
+}
+
+// GetHealthStatus returns current health status
+func (ra *ResilientAgent) GetHealthStatus() HealthStatus {
+	health := ra.monitor.GetHealthStatus(ra.breakers, ra.rateLimiter)
+	health.Ready, health.NotReadyReason = ra.Ready()
+	return health
+}
+
+// GetConfig returns the current configuration
+func (ra *ResilientAgent) GetConfig() *ReliabilityConfig {
+	return ra.config
+}
+
+// ResetCircuitBreakers resets all circuit breakers
+func (ra *ResilientAgent) ResetCircuitBreakers() {
+	ra.breakers.Reset()
+}
+
+// CircuitBreakers returns the agent's per-resource circuit breakers
+func (ra *ResilientAgent) CircuitBreakers() *CircuitBreakers {
+	return ra.breakers
+}
+
+// CompactionStats counts the telemetry one compaction dropped
+type CompactionStats struct {
+	ResponseTimes int
+	RequestTimes  int
+	TimeoutModels int
+	FaultRules    int
+}
+
+// Compact drops telemetry older than MetricsRetention (kept forever when it
+// is zero), request times older than the rate window and expired fault rules
+func (ra *ResilientAgent) Compact() CompactionStats {
+	stats := CompactionStats{
+		RequestTimes: ra.rateLimiter.Compact(),
+		FaultRules:   ra.faultInjector.Prune(),
+	}
+	if retention := ra.config.Monitoring.MetricsRetention; retention > 0 {
+		stats.ResponseTimes = ra.monitor.Compact(time.Now().Add(-retention))
+		stats.TimeoutModels = ra.timeouts.Compact(retention)
+	}
+	return stats
+}
+
+// StartMaintenance compacts telemetry every CompactionInterval, saves the
+// agent's state every SaveInterval and runs the memory watchdog and health
+// probe until ctx is cancelled
+func (ra *ResilientAgent) StartMaintenance(ctx context.Context) {
+	if interval := ra.config.Monitoring.CompactionInterval; interval > 0 {
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					ra.Compact()
+				}
+			}
+		}()
+	}
+	if interval := ra.config.Persistence.SaveInterval; interval > 0 && ra.config.Persistence.Store != nil {
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					if err := ra.SaveState(ctx); err != nil {
+						slog.WarnContext(ctx, "agent state not saved", "error", err)
+					}
+				}
+			}
+		}()
+	}
+	go ra.watchdog.Run(ctx)
+	go ra.prober.Run(ctx)
+}
+
+// MemoryWatchdog returns the agent's memory watchdog, so callers can register
+// their own caches to shed under memory pressure
+func (ra *ResilientAgent) MemoryWatchdog() *MemoryWatchdog {
+	return ra.watchdog
+}
+
+// ResetMetrics resets all metrics, including the learned timeouts
+func (ra *ResilientAgent) ResetMetrics() {
+	ra.monitor.Reset()
+	ra.queue.Reset()
+	ra.timeouts.Reset()
+}
+
+// TimeoutStats returns the adaptive timeout and hit rate of each operation
+func (ra *ResilientAgent) TimeoutStats() []TimeoutStats {
+	return ra.timeouts.Stats()
+}
+
+// InjectFault injects a fault for testing
+func (ra *ResilientAgent) InjectFault(faultType string, duration time.Duration) {
+	ra.faultInjector.InjectFault(faultType, duration)
+}
+
+// InjectFaultRule injects a targeted, probabilistic or latency fault
+func (ra *ResilientAgent) InjectFaultRule(rule FaultRule) (FaultRule, error) {
+	return ra.faultInjector.AddRule(rule)
+}
+
+// FaultInjector returns the agent's fault injector for chaos experiments
+func (ra *ResilientAgent) FaultInjector() *FaultInjector {
+	return ra.faultInjector
+}
+
+// ClearFaults clears all injected faults
+func (ra *ResilientAgent) ClearFaults() {
+	ra.faultInjector.ClearFaults()
+}
+
+// Helper functions for the circuit breaker and monitor components
+func (cb *CircuitBreaker) Reset() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.state = CircuitClosed
+	cb.failureCount = 0
+	cb.successCount = 0
+}
+
+func (cb *CircuitBreaker) GetState() CircuitState {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+	return cb.state
+}
+
+func (m *Monitor) RecordSuccess(duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.totalRequests++
+	m.successfulRequests++
+	m.lastAPISuccess = time.Now()
+	m.successLatency.observe(duration)
+	m.recordResponseTime(duration, m.lastAPISuccess)
+}
+
+func (m *Monitor) RecordFailure(duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.totalRequests++
+	m.failedRequests++
+	m.lastAPIFailure = time.Now()
+	m.failureLatency.observe(duration)
+	m.recordResponseTime(duration, m.lastAPIFailure)
+}
+
+// recordResponseTime adds a response time to the window, overwriting the
+// oldest once it is full. Callers hold m.mu.
+func (m *Monitor) recordResponseTime(duration time.Duration, at time.Time) {
+	if len(m.responseTimes) < m.window {
+		m.responseTimes = append(m.responseTimes, duration)
+		m.responseAt = append(m.responseAt, at)
+	} else {
+		m.responseTimes[m.responseNext] = duration
+		m.responseAt[m.responseNext] = at
+	}
+	m.responseNext = (m.responseNext + 1) % m.window
+}
+
+// Compact drops response times recorded before cutoff and returns how many
+// it dropped. The ring is rewritten oldest first, so it fills by appending
+// again until it wraps.
+func (m *Monitor) Compact(cutoff time.Time) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := len(m.responseTimes)
+	oldest := 0
+	if n == m.window {
+		oldest = m.responseNext
+	}
+	times := make([]time.Duration, 0, m.window)
+	at := make([]time.Time, 0, m.window)
+	for i := 0; i < n; i++ {
+		k := (oldest + i) % n
+		if m.responseAt[k].After(cutoff) {
+			times = append(times, m.responseTimes[k])
+			at = append(at, m.responseAt[k])
+		}
+	}
+	if len(times) == n {
+		return 0
+	}
+	m.responseTimes, m.responseAt = times, at
+	m.responseNext = len(times) % m.window
+	return n - len(times)
+}
+
+// Shed releases the response time window. Counters are kept; averages and
+// percentiles restart from the next request.
+func (m *Monitor) Shed() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.responseTimes = nil
+	m.responseAt = nil
+	m.responseNext = 0
+}
+
+// RecordRetry counts a retried attempt and whether it succeeded
+func (m *Monitor) RecordRetry(succeeded bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.totalRetries++
+	if succeeded {
+		m.successfulRetries++
+	} else {
+		m.failedRetries++
+	}
+}
+
+// RecordTrip counts the circuit breaker opening
+func (m *Monitor) RecordTrip() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.circuitBreakerTrips++
+	m.lastTrip = time.Now()
+}
+
+// RecordServedBy counts a response served by route, which was a fallback
+// when it wasn't the chain's first route
+func (m *Monitor) RecordServedBy(route string, fallback bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.servedBy[route]++
+	if fallback {
+		m.fallbackResponses++
+	}
+}
+
+// RecordHedge counts a request raced against a slow route
+func (m *Monitor) RecordHedge() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.hedgedRequests++
+}
+
+// RecordBulkheadRejected counts a request a full bulkhead turned away
+func (m *Monitor) RecordBulkheadRejected() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.totalRequests++
+	m.bulkheadRejections++
+}
+
+func (m *Monitor) RecordRateLimited() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.totalRequests++
+	m.rateLimitedRequests++
+}
+
+func (m *Monitor) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.totalRequests = 0
+	m.successfulRequests = 0
+	m.failedRequests = 0
+	m.totalRetries = 0
+	m.successfulRetries = 0
+	m.failedRetries = 0
+	m.circuitBreakerTrips = 0
+	m.lastTrip = time.Time{}
+	m.rateLimitedRequests = 0
+	m.bulkheadRejections = 0
+	m.successLatency.reset()
+	m.failureLatency.reset()
+	m.responseTimes = m.responseTimes[:0]
+	m.responseAt = m.responseAt[:0]
+	m.responseNext = 0
+	clear(m.servedBy)
+	m.fallbackResponses = 0
+	m.hedgedRequests = 0
+}
+
+func (m *Monitor) GetMetrics(cbs *CircuitBreakers, rl *RateLimiter) Metrics {
+	states := cbs.States()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	metrics := Metrics{
+		TotalRequests:          m.totalRequests,
+		SuccessfulRequests:     m.successfulRequests,
+		FailedRequests:         m.failedRequests,
+		TotalRetries:           m.totalRetries,
+		SuccessfulRetries:      m.successfulRetries,
+		FailedRetries:          m.failedRetries,
+		CircuitBreakerTrips:    m.circuitBreakerTrips,
+		CircuitBreakerState:    worstState(states).String(),
+		CircuitBreakers:        make(map[string]string, len(states)),
+		LastCircuitBreakerTrip: m.lastTrip,
+		RateLimitedRequests:    m.rateLimitedRequests,
+		BulkheadRejections:     m.bulkheadRejections,
+		ServedBy:               maps.Clone(m.servedBy),
+		FallbackResponses:      m.fallbackResponses,
+		HedgedRequests:         m.hedgedRequests,
+	}
+
+	for name, state := range states {
+		metrics.CircuitBreakers[name] = state.String()
+	}
+
+	if m.totalRequests > 0 {
+		metrics.ErrorRate = float64(m.failedRequests) / float64(m.totalRequests)
+	}
+
+	if m.totalRetries > 0 {
+		metrics.RetrySuccessRate = float64(m.successfulRetries) / float64(m.totalRetries)
+	}
+
+	// Calculate response time metrics
+	if len(m.responseTimes) > 0 {
+		total := time.Duration(0)
+		fastest := m.responseTimes[0]
+		slowest := m.responseTimes[0]
+
+		for _, rt := range m.responseTimes {
+			total += rt
+			if rt < fastest {
+				fastest = rt
+			}
+			if rt > slowest {
+				slowest = rt
+			}
+		}
+
+		metrics.AvgResponseTime = total / time.Duration(len(m.responseTimes))
+		metrics.FastestResponse = fastest
+		metrics.SlowestResponse = slowest
+
+		// Calculate P95
+		if len(m.responseTimes) >= 20 {
+			buf := percentileScratch.Get().(*[]time.Duration)
+			scratch := append((*buf)[:0], m.responseTimes...)
+			p95Index := int(float64(len(scratch)) * 0.95)
+			metrics.P95ResponseTime = selectNth(scratch, p95Index)
+			*buf = scratch
+			percentileScratch.Put(buf)
+		}
+	}
+
+	// Calculate requests per minute
+	rl.mu.Lock()
+	rl.trimLocked(time.Now())
+	metrics.RequestsPerMinute = float64(len(rl.requestTimes))
+	metrics.EffectiveRateLimit = rl.rate
+	rl.mu.Unlock()
+
+	return metrics
+}
+
+// selectNth returns the value that would be at index n if values were
+// sorted, in O(n) time on average. It reorders values in place.
+func selectNth(values []time.Duration, n int) time.Duration {
+	lo, hi := 0, len(values)-1
+	for lo < hi {
+		// Median of three guards against already-sorted windows
+		mid := lo + (hi-lo)/2
+		if values[mid] < values[lo] {
+			values[mid], values[lo] = values[lo], values[mid]
+		}
+		if values[hi] < values[lo] {
+			values[hi], values[lo] = values[lo], values[hi]
+		}
+		if values[hi] < values[mid] {
+			values[hi], values[mid] = values[mid], values[hi]
+		}
+		pivot := values[mid]
+
+		i, j := lo, hi
+		for i <= j {
+			for values[i] < pivot {
+				i++
+			}
+			for values[j] > pivot {
+				j--
+			}
+			if i <= j {
+				values[i], values[j] = values[j], values[i]
+				i++
+				j--
+			}
+		}
+		switch {
+		case n <= j:
+			hi = j
+		case n >= i:
+			lo = i
+		default:
+			return values[n]
+		}
+	}
+	return values[n]
+}
+
+func (m *Monitor) GetHealthStatus(cbs *CircuitBreakers, rl *RateLimiter) HealthStatus {
+	var openCircuits []string
+	consecutiveFailures := 0
+	for _, name := range cbs.Names() {
+		cb := cbs.Get(name)
+		cb.mu.RLock()
+		if cb.state == CircuitOpen {
+			openCircuits = append(openCircuits, name)
+		}
+		consecutiveFailures = max(consecutiveFailures, cb.failureCount)
+		cb.mu.RUnlock()
+	}
+	circuitOpen := len(openCircuits) > 0
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
+	rl.mu.Lock()
+	availableTokens := int(rl.tokens)
+	rateLimitExceeded := rl.tokens < 1.0
+	rl.mu.Unlock()
+
+	apiConnected := time.Since(m.lastAPISuccess) < time.Since(m.lastAPIFailure)
+
+	overall := apiConnected && !circuitOpen && !rateLimitExceeded
+
+	return HealthStatus{
+		Overall:             overall,
+		APIConnection:       apiConnected,
+		CircuitBreakerOpen:  circuitOpen,
+		OpenCircuits:        openCircuits,
+		RateLimitExceeded:   rateLimitExceeded,
+		LastAPISuccess:      m.lastAPISuccess,
+		LastAPIFailure:      m.lastAPIFailure,
+		ConsecutiveFailures: consecutiveFailures,
+		AvailableTokens:     availableTokens,
+		MemoryUsage:         float64(memStats.Alloc),
+		GoroutineCount:      runtime.NumGoroutine(),
+	}
+}
+
+// Helper function to check if a string contains a substring
+func contains(s, substr string) bool {
+	return len(s) >= len(substr) &&
+		(s == substr ||
+			(len(s) > len(substr) &&
+				(s[:len(substr)] == substr ||
+					s[len(s)-len(substr):] == substr ||
+					indexOfSubstring(s, substr) != -1)))
+}
+
+// Simple substring search
+func indexOfSubstring(s, substr string) int {
+	for i := 0; i <= len(s)-len(substr); i++ {
+		if s[i:i+len(substr)] == substr {
+			return i
+		}
+	}
+	return -1
+}
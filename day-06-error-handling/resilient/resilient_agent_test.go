@@ -0,0 +1,77 @@
+package resilient
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveRateLimiter(t *testing.T) {
+	rl := NewRateLimiter(RateLimitConfig{
+		RequestsPerMinute:    60,
+		BurstSize:            10,
+		AdaptiveEnabled:      true,
+		MinRequestsPerMinute: 20,
+		MaxRequestsPerMinute: 70,
+		IncreaseAfter:        3,
+		IncreaseStep:         5,
+		DecreaseFactor:       0.5,
+	})
+
+	for i := 0; i < 9; i++ {
+		rl.RecordSuccess()
+	}
+	if rate := rl.Rate(); rate != 70 {
+		t.Errorf("Expected the rate to grow by 5 per 3 successes up to 70, got %v", rate)
+	}
+	rl.RecordOverload()
+	if rate := rl.Rate(); rate != 35 {
+		t.Errorf("Expected an overload to halve the rate, got %v", rate)
+	}
+	rl.RecordOverload()
+	if rate := rl.Rate(); rate != 35 {
+		t.Errorf("Expected a second overload within the cooldown to be ignored, got %v", rate)
+	}
+	rl.lastDecrease = time.Time{}
+	rl.RecordOverload()
+	if rate := rl.Rate(); rate != 20 {
+		t.Errorf("Expected the rate to stop at the minimum, got %v", rate)
+	}
+	rl.RecordSuccess()
+	rl.RecordSuccess()
+	rl.lastDecrease = time.Time{}
+	rl.RecordOverload()
+	rl.RecordSuccess()
+	rl.RecordSuccess()
+	if rate := rl.Rate(); rate != 20 {
+		t.Errorf("Expected an overload to restart the success count, got %v", rate)
+	}
+
+	fixed := NewRateLimiter(RateLimitConfig{RequestsPerMinute: 60, BurstSize: 10})
+	fixed.RecordOverload()
+	if rate := fixed.Rate(); rate != 60 {
+		t.Errorf("Expected a fixed rate without adaptive control, got %v", rate)
+	}
+}
+
+func TestAdaptiveRateInMetrics(t *testing.T) {
+	config := DefaultReliabilityConfig()
+	config.Retry.MaxAttempts = 1
+	agent, err := NewResilientAgent("test-key", config)
+	if err != nil {
+		t.Fatalf("Failed to create agent: %v", err)
+	}
+	agent.Execute(context.Background(), "chat", func(ctx context.Context) (string, error) {
+		return "", errors.New("rate_limit: 429 Too Many Requests")
+	})
+	if got := agent.GetMetrics().EffectiveRateLimit; got != 30 {
+		t.Errorf("Expected a 429 to halve the effective rate to 30, got %v", got)
+	}
+	agent.Execute(context.Background(), "chat", func(ctx context.Context) (string, error) {
+		return "", errors.New("invalid request")
+	})
+	if got := agent.GetMetrics().EffectiveRateLimit; got != 30 {
+		t.Errorf("Expected a client error to leave the rate alone, got %v", got)
+	}
+}
@@ -0,0 +1,254 @@
+package resilient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sakibmulla/agentic-ai/persist"
+)
+
+// stateFormat versions the saved circuit breaker and rate limiter state
+var stateFormat = persist.NewFormat("resilient-agent-state", 1)
+
+// ErrNoSavedState is returned by a StateStore that has nothing saved yet
+var ErrNoSavedState = errors.New("no saved state")
+
+// PersistenceConfig saves the circuit breakers and rate limiter, so a
+// restarted agent remembers it was failing instead of hammering a degraded
+// API. A nil Store disables it.
+type PersistenceConfig struct {
+	Store StateStore
+	// SaveInterval is how often StartMaintenance saves the state
+	SaveInterval time.Duration
+	// MaxAge ignores saved state older than it on startup; zero restores
+	// state of any age
+	MaxAge time.Duration
+}
+
+// AgentState is what the agent saves and restores
+type AgentState struct {
+	SavedAt     time.Time               `json:"saved_at"`
+	Breakers    map[string]BreakerState `json:"breakers"`
+	RateLimiter RateLimiterState        `json:"rate_limiter"`
+}
+
+// BreakerState is one circuit breaker's saved state
+type BreakerState struct {
+	State        string    `json:"state"`
+	FailureCount int       `json:"failure_count"`
+	SuccessCount int       `json:"success_count"`
+	LastFailure  time.Time `json:"last_failure"`
+}
+
+// RateLimiterState is the rate limiter's saved token bucket and adaptive
+// rate
+type RateLimiterState struct {
+	Tokens       float64   `json:"tokens"`
+	LastRefill   time.Time `json:"last_refill"`
+	Rate         float64   `json:"rate"`
+	LastDecrease time.Time `json:"last_decrease"`
+}
+
+// StateStore saves and loads the agent's state
+type StateStore interface {
+	Load(ctx context.Context) (AgentState, error)
+	Save(ctx context.Context, state AgentState) error
+}
+
+// StateStoreFromEnv returns a Redis store when AGENT_STATE_REDIS_ADDR is
+// set, with AGENT_STATE_REDIS_PASSWORD and AGENT_STATE_REDIS_KEY, or a file
+// store when AGENT_STATE_PATH is. It returns nil when neither is set.
+func StateStoreFromEnv() (StateStore, error) {
+	if addr := os.Getenv("AGENT_STATE_REDIS_ADDR"); addr != "" {
+		if os.Getenv("AGENT_STATE_PATH") != "" {
+			return nil, fmt.Errorf("set AGENT_STATE_REDIS_ADDR or AGENT_STATE_PATH, not both")
+		}
+		key := os.Getenv("AGENT_STATE_REDIS_KEY")
+		if key == "" {
+			key = "resilient-agent:state"
+		}
+		return &RedisStateStore{Addr: addr, Password: os.Getenv("AGENT_STATE_REDIS_PASSWORD"), Key: key}, nil
+	}
+	if path := os.Getenv("AGENT_STATE_PATH"); path != "" {
+		return &FileStateStore{Path: path}, nil
+	}
+	return nil, nil
+}
+
+// FileStateStore keeps the state in a JSON file
+type FileStateStore struct {
+	Path string
+}
+
+func (s *FileStateStore) Load(ctx context.Context) (AgentState, error) {
+	var state AgentState
+	err := stateFormat.ReadFile(s.Path, &state)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, ErrNoSavedState
+	}
+	return state, err
+}
+
+func (s *FileStateStore) Save(ctx context.Context, state AgentState) error {
+	return stateFormat.WriteFile(s.Path, state, 0600)
+}
+
+// RedisStateStore keeps the state under a Redis key, so replicas of the
+// agent share it
+type RedisStateStore struct {
+	Addr     string
+	Password string
+	Key      string
+	client   *redisClient
+	once     sync.Once
+}
+
+func (s *RedisStateStore) redis() *redisClient {
+	s.once.Do(func() { s.client = newRedisClient(s.Addr, s.Password) })
+	return s.client
+}
+
+func (s *RedisStateStore) Load(ctx context.Context) (AgentState, error) {
+	var state AgentState
+	ctx, cancel := withRedisTimeout(ctx)
+	defer cancel()
+	reply, err := s.redis().Do(ctx, "GET", s.Key)
+	if err != nil {
+		return state, err
+	}
+	data, ok := reply.(string)
+	if !ok {
+		return state, ErrNoSavedState
+	}
+	_, err = stateFormat.Unmarshal([]byte(data), &state)
+	return state, err
+}
+
+func (s *RedisStateStore) Save(ctx context.Context, state AgentState) error {
+	data, err := stateFormat.Marshal(state)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := withRedisTimeout(ctx)
+	defer cancel()
+	_, err = s.redis().Do(ctx, "SET", s.Key, string(data))
+	return err
+}
+
+// snapshot returns the breaker's state for saving
+func (cb *CircuitBreaker) snapshot() BreakerState {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	return BreakerState{
+		State:        cb.state.String(),
+		FailureCount: cb.failureCount,
+		SuccessCount: cb.successCount,
+		LastFailure:  cb.lastFailureTime,
+	}
+}
+
+// restore puts the breaker back in a saved state. An open circuit stays
+// open until RecoveryTimeout after its last failure, as if never restarted.
+func (cb *CircuitBreaker) restore(saved BreakerState) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch saved.State {
+	case CircuitOpen.String():
+		cb.state = CircuitOpen
+	case CircuitHalfOpen.String():
+		cb.state = CircuitHalfOpen
+	default:
+		cb.state = CircuitClosed
+	}
+	cb.failureCount = saved.FailureCount
+	cb.successCount = saved.SuccessCount
+	cb.lastFailureTime = saved.LastFailure
+}
+
+// snapshot returns the limiter's state for saving
+func (rl *RateLimiter) snapshot() RateLimiterState {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	return RateLimiterState{
+		Tokens:       rl.tokens,
+		LastRefill:   rl.lastRefill,
+		Rate:         rl.rate,
+		LastDecrease: rl.lastDecrease,
+	}
+}
+
+// restore puts the limiter back in a saved state, within the current
+// config's limits. Tokens refill for the time the agent was down on the
+// next Allow. A fixed rate comes from the config, not the saved state.
+func (rl *RateLimiter) restore(saved RateLimiterState) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.tokens = math.Max(0, math.Min(saved.Tokens, float64(rl.config.BurstSize)))
+	if !saved.LastRefill.IsZero() && saved.LastRefill.Before(time.Now()) {
+		rl.lastRefill = saved.LastRefill
+	}
+	if rl.config.AdaptiveEnabled && saved.Rate > 0 {
+		rl.rate = math.Max(float64(rl.config.MinRequestsPerMinute), math.Min(saved.Rate, float64(rl.config.MaxRequestsPerMinute)))
+		rl.lastDecrease = saved.LastDecrease
+	}
+}
+
+// State returns the agent's circuit breakers and rate limiter state
+func (ra *ResilientAgent) State() AgentState {
+	state := AgentState{
+		SavedAt:     time.Now(),
+		Breakers:    make(map[string]BreakerState),
+		RateLimiter: ra.rateLimiter.snapshot(),
+	}
+	for _, name := range ra.breakers.Names() {
+		state.Breakers[name] = ra.breakers.Get(name).snapshot()
+	}
+	return state
+}
+
+// SaveState saves the agent's state to the configured store, if any
+func (ra *ResilientAgent) SaveState(ctx context.Context) error {
+	store := ra.config.Persistence.Store
+	if store == nil {
+		return nil
+	}
+	if err := store.Save(ctx, ra.State()); err != nil {
+		return fmt.Errorf("failed to save agent state: %w", err)
+	}
+	return nil
+}
+
+// RestoreState loads the agent's state from the configured store, if any.
+// State older than MaxAge is ignored.
+func (ra *ResilientAgent) RestoreState(ctx context.Context) error {
+	persistence := ra.config.Persistence
+	if persistence.Store == nil {
+		return nil
+	}
+	state, err := persistence.Store.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load agent state: %w", err)
+	}
+	if age := time.Since(state.SavedAt); persistence.MaxAge > 0 && age > persistence.MaxAge {
+		slog.InfoContext(ctx, "ignoring stale agent state", "age", age.Round(time.Second), "max_age", persistence.MaxAge)
+		return nil
+	}
+
+	for name, saved := range state.Breakers {
+		ra.breakers.Get(name).restore(saved)
+	}
+	ra.rateLimiter.restore(state.RateLimiter)
+	slog.InfoContext(ctx, "restored agent state", "saved_at", state.SavedAt, "breakers", len(state.Breakers))
+	return nil
+}
@@ -0,0 +1,91 @@
+package resilient
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStateSurvivesRestart(t *testing.T) {
+	stores := map[string]StateStore{
+		"file":  &FileStateStore{Path: filepath.Join(t.TempDir(), "state.json")},
+		"redis": &RedisStateStore{Addr: fakeRedis(t, "secret"), Password: "secret", Key: "agent:state"},
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if _, err := store.Load(ctx); !errors.Is(err, ErrNoSavedState) {
+				t.Fatalf("Expected ErrNoSavedState before saving, got %v", err)
+			}
+
+			config := DefaultReliabilityConfig()
+			config.Retry.MaxAttempts = 1
+			config.CircuitBreaker.FailureThreshold = 2
+			config.Persistence.Store = store
+			agent, err := NewResilientAgent("test-key", config)
+			if err != nil {
+				t.Fatalf("Failed to create agent: %v", err)
+			}
+			for i := 0; i < 2; i++ {
+				agent.Execute(ctx, "chat", func(ctx context.Context) (string, error) {
+					return "", errors.New("server_error: overloaded")
+				})
+			}
+			agent.Execute(ctx, "embeddings", func(ctx context.Context) (string, error) {
+				return "", errors.New("invalid request")
+			})
+			if err := agent.SaveState(ctx); err != nil {
+				t.Fatalf("SaveState failed: %v", err)
+			}
+
+			restarted, err := NewResilientAgent("test-key", config)
+			if err != nil {
+				t.Fatalf("Failed to create agent: %v", err)
+			}
+			if state := restarted.CircuitBreakers().Get("chat").GetState(); state != CircuitOpen {
+				t.Errorf("Expected the chat circuit to stay open across a restart, got %s", state)
+			}
+			if failures := restarted.CircuitBreakers().Get("embeddings").snapshot().FailureCount; failures != 1 {
+				t.Errorf("Expected the embeddings failure count restored, got %d", failures)
+			}
+			if rate := restarted.rateLimiter.Rate(); rate != 30 {
+				t.Errorf("Expected the halved adaptive rate restored, got %v", rate)
+			}
+			if tokens := restarted.rateLimiter.snapshot().Tokens; tokens > 8 {
+				t.Errorf("Expected the spent tokens restored, got %v", tokens)
+			}
+		})
+	}
+}
+
+func TestStaleStateIgnored(t *testing.T) {
+	store := &FileStateStore{Path: filepath.Join(t.TempDir(), "state.json")}
+	ctx := context.Background()
+	stale := AgentState{
+		SavedAt:  time.Now().Add(-2 * time.Hour),
+		Breakers: map[string]BreakerState{"chat": {State: "OPEN", FailureCount: 5, LastFailure: time.Now().Add(-2 * time.Hour)}},
+	}
+	if err := store.Save(ctx, stale); err != nil {
+		t.Fatal(err)
+	}
+
+	config := DefaultReliabilityConfig()
+	config.Persistence.Store = store
+	agent, err := NewResilientAgent("test-key", config)
+	if err != nil {
+		t.Fatalf("Failed to create agent: %v", err)
+	}
+	if state := agent.CircuitBreakers().Get("chat").GetState(); state != CircuitClosed {
+		t.Errorf("Expected state older than MaxAge to be ignored, got %s", state)
+	}
+}
+
+func TestRedisStateStoreErrors(t *testing.T) {
+	store := &RedisStateStore{Addr: fakeRedis(t, "secret"), Password: "wrong", Key: "agent:state"}
+	if err := store.Save(context.Background(), AgentState{}); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("Expected the server's auth error, got %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+package resilient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrBudgetExhausted is returned when a workflow runs out of its time budget
+var ErrBudgetExhausted = errors.New("workflow time budget exhausted")
+
+// StepStatus is the outcome of one workflow step
+type StepStatus string
+
+const (
+	StepSucceeded StepStatus = "succeeded"
+	StepFailed    StepStatus = "failed"
+	StepTimedOut  StepStatus = "timed_out" // every attempt hit the step's own timeout
+	StepCancelled StepStatus = "cancelled" // stopped by the workflow budget or the caller
+	StepSkipped   StepStatus = "skipped"   // never started because an earlier step stopped the workflow
+)
+
+// WorkflowStep is one unit of work. Steps run in order and each receives
+// the previous step's output.
+type WorkflowStep struct {
+	Name string
+	// Timeout bounds each attempt; 0 leaves only the workflow budget
+	Timeout time.Duration
+	// Retry is the step's retry policy; nil runs the step once
+	Retry *RetryConfig
+	Run   func(ctx context.Context, input string) (string, error)
+}
+
+// Workflow is a sequence of steps sharing a total time budget. When the
+// budget runs out the running step's context is cancelled and the
+// remaining steps are skipped.
+type Workflow struct {
+	Name string
+	// Budget bounds the whole workflow, retries and backoff included; 0 means no budget
+	Budget time.Duration
+	Steps  []WorkflowStep
+}
+
+// StepResult records how a step went
+type StepResult struct {
+	Name     string
+	Status   StepStatus
+	Attempts int
+	Duration time.Duration
+	Error    string
+}
+
+// WorkflowResult is the outcome of a workflow run
+type WorkflowResult struct {
+	Name     string
+	Output   string
+	Steps    []StepResult
+	Duration time.Duration
+	// BudgetExhausted is set when the workflow stopped because its budget ran out
+	BudgetExhausted bool
+}
+
+// Skipped returns the names of the steps that never started
+func (r *WorkflowResult) Skipped() []string {
+	var skipped []string
+	for _, step := range r.Steps {
+		if step.Status == StepSkipped {
+			skipped = append(skipped, step.Name)
+		}
+	}
+	return skipped
+}
+
+// Run executes the steps in order. The first step that fails, times out or
+// is cancelled stops the workflow; the result always lists every step.
+func (w *Workflow) Run(ctx context.Context, input string) (*WorkflowResult, error) {
+	start := time.Now()
+	result := &WorkflowResult{Name: w.Name}
+
+	budgetCtx := ctx
+	if w.Budget > 0 {
+		var cancel context.CancelFunc
+		budgetCtx, cancel = context.WithTimeout(ctx, w.Budget)
+		defer cancel()
+	}
+
+	output := input
+	var runErr error
+	for i, step := range w.Steps {
+		if runErr != nil {
+			result.Steps = append(result.Steps, StepResult{Name: step.Name, Status: StepSkipped})
+			continue
+		}
+		if budgetCtx.Err() != nil {
+			runErr = w.stopError(ctx, budgetCtx, result)
+			result.Steps = append(result.Steps, StepResult{Name: step.Name, Status: StepSkipped})
+			continue
+		}
+
+		stepResult, stepOutput := runStep(budgetCtx, step, output)
+		result.Steps = append(result.Steps, stepResult)
+		switch stepResult.Status {
+		case StepSucceeded:
+			output = stepOutput
+		case StepCancelled:
+			runErr = fmt.Errorf("step %d (%s) cancelled: %w", i+1, step.Name, w.stopError(ctx, budgetCtx, result))
+		default:
+			runErr = fmt.Errorf("step %d (%s) %s: %s", i+1, step.Name, stepResult.Status, stepResult.Error)
+		}
+	}
+
+	result.Duration = time.Since(start)
+	if runErr != nil {
+		return result, runErr
+	}
+	result.Output = output
+	return result, nil
+}
+
+// stopError explains why the workflow context ended: the caller cancelled
+// it, or the budget ran out
+func (w *Workflow) stopError(ctx, budgetCtx context.Context, result *WorkflowResult) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	result.BudgetExhausted = true
+	return fmt.Errorf("%w after %v", ErrBudgetExhausted, w.Budget)
+}
+
+// runStep runs one step under its retry policy, giving each attempt the
+// step timeout. Cancellation of ctx reaches the running attempt at once.
+func runStep(ctx context.Context, step WorkflowStep, input string) (StepResult, string) {
+	start := time.Now()
+	result := StepResult{Name: step.Name}
+
+	retry := RetryConfig{MaxAttempts: 1}
+	if step.Retry != nil {
+		retry = *step.Retry
+	}
+	timedOut := false
+	output, err := NewRetryManager(retry).Execute(ctx, func() (string, error) {
+		result.Attempts++
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if step.Timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, step.Timeout)
+		}
+		defer cancel()
+
+		output, err := step.Run(attemptCtx, input)
+		timedOut = ctx.Err() == nil && attemptCtx.Err() == context.DeadlineExceeded
+		if timedOut {
+			// Worded so the default retriable errors ("timeout") match it
+			return "", fmt.Errorf("timeout: attempt exceeded the step timeout of %v", step.Timeout)
+		}
+		return output, err
+	})
+
+	result.Duration = time.Since(start)
+	switch {
+	case err == nil:
+		result.Status = StepSucceeded
+	case ctx.Err() != nil:
+		result.Status = StepCancelled
+		result.Error = ctx.Err().Error()
+	case timedOut:
+		result.Status = StepTimedOut
+		result.Error = err.Error()
+	default:
+		result.Status = StepFailed
+		result.Error = err.Error()
+	}
+	return result, output
+}
+
+// AgentStep makes a workflow step that sends prompt (with %s replaced by
+// the previous step's output) through the agent's resilient Execute path
+func (ra *ResilientAgent) AgentStep(name, prompt string, timeout time.Duration, retry *RetryConfig) WorkflowStep {
+	return WorkflowStep{
+		Name:    name,
+		Timeout: timeout,
+		Retry:   retry,
+		Run: func(ctx context.Context, input string) (string, error) {
+			message := prompt
+			if strings.Contains(prompt, "%s") {
+				message = fmt.Sprintf(prompt, input)
+			}
+			return ra.Execute(ctx, "workflow:"+name, func(ctx context.Context) (string, error) {
+				response, _, err := ra.performRequest(ctx, message)
+				return response, err
+			})
+		},
+	}
+}
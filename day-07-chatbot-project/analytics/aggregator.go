@@ -0,0 +1,350 @@
+package analytics
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sakibmulla/agentic-ai/persist"
+)
+
+// Event describes a single interaction. It deliberately carries no prompt,
+// response or user identifier, so only aggregate properties can be stored.
+type Event struct {
+	Kind    string // e.g. "chat", "batch", "tool"
+	Mode    string
+	Tokens  int
+	Latency time.Duration
+	Failed  bool
+	// Sentiment is the user turn's score in [-1, 1], if it was scored
+	Sentiment *float64
+}
+
+// tokenBuckets and latencyBuckets are the upper bounds of histogram bins
+var (
+	tokenBuckets   = []int{50, 100, 250, 500, 1000, 2000, 4000}
+	latencyBuckets = []time.Duration{250 * time.Millisecond, 500 * time.Millisecond, time.Second, 2 * time.Second, 5 * time.Second, 10 * time.Second}
+)
+
+// aggregatesFormat versions the analytics file
+var aggregatesFormat = persist.NewFormat("analytics", 1)
+
+// DayStats holds the aggregates for a single day
+type DayStats struct {
+	Events           int            `json:"events"`
+	Failures         int            `json:"failures"`
+	ByKind           map[string]int `json:"by_kind"`
+	ByMode           map[string]int `json:"by_mode"`
+	TokenHistogram   []int          `json:"token_histogram"`
+	LatencyHistogram []int          `json:"latency_histogram"`
+	TotalTokens      int            `json:"total_tokens"`
+	// Sentiment aggregates user sentiment per mode (persona)
+	Sentiment map[string]*SentimentStats `json:"sentiment,omitempty"`
+}
+
+// SentimentStats accumulates scored turns for one mode on one day
+type SentimentStats struct {
+	Turns    int     `json:"turns"`
+	Sum      float64 `json:"sum"`
+	Negative int     `json:"negative"`
+}
+
+// negativeSentiment is the score at or below which a turn counts as dissatisfied
+const negativeSentiment = -0.3
+
+// Satisfaction reports the sentiment of one mode, or of one day
+type Satisfaction struct {
+	Turns       int     `json:"turns"`
+	Average     float64 `json:"average"`
+	NegativePct float64 `json:"negative_pct"`
+}
+
+// DaySatisfaction is one point of the daily satisfaction trend
+type DaySatisfaction struct {
+	Date string `json:"date"`
+	Satisfaction
+}
+
+// Config controls retention and privacy of the aggregator
+type Config struct {
+	Path          string
+	RetentionDays int
+	// Epsilon enables differential privacy for reports when > 0: Laplace
+	// noise with scale 1/Epsilon is added to every reported count.
+	Epsilon float64
+}
+
+// Aggregator stores usage analytics as per-day counts and histograms only
+type Aggregator struct {
+	config Config
+	days   map[string]*DayStats
+	random *rand.Rand
+	mu     sync.Mutex
+}
+
+// NewAggregator loads existing aggregates from config.Path, if any
+func NewAggregator(config Config) (*Aggregator, error) {
+	if config.RetentionDays <= 0 {
+		config.RetentionDays = 30
+	}
+
+	a := &Aggregator{
+		config: config,
+		days:   make(map[string]*DayStats),
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	if config.Path == "" {
+		return a, nil
+	}
+
+	err := aggregatesFormat.ReadFile(config.Path, &a.days)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to load analytics: %w", err)
+	}
+
+	a.prune(time.Now())
+	return a, nil
+}
+
+// Record folds an event into today's aggregates
+func (a *Aggregator) Record(event Event) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	key := now.Format("2006-01-02")
+	day, ok := a.days[key]
+	if !ok {
+		day = &DayStats{
+			ByKind:           make(map[string]int),
+			ByMode:           make(map[string]int),
+			TokenHistogram:   make([]int, len(tokenBuckets)+1),
+			LatencyHistogram: make([]int, len(latencyBuckets)+1),
+		}
+		a.days[key] = day
+	}
+
+	day.Events++
+	if event.Failed {
+		day.Failures++
+	}
+	day.ByKind[event.Kind]++
+	if event.Mode != "" {
+		day.ByMode[event.Mode]++
+	}
+	day.TotalTokens += event.Tokens
+	day.TokenHistogram[tokenBucket(event.Tokens)]++
+	day.LatencyHistogram[latencyBucket(event.Latency)]++
+	if event.Sentiment != nil {
+		if day.Sentiment == nil {
+			day.Sentiment = make(map[string]*SentimentStats)
+		}
+		stats, ok := day.Sentiment[event.Mode]
+		if !ok {
+			stats = &SentimentStats{}
+			day.Sentiment[event.Mode] = stats
+		}
+		stats.Turns++
+		stats.Sum += math.Max(-1, math.Min(1, *event.Sentiment))
+		if *event.Sentiment <= negativeSentiment {
+			stats.Negative++
+		}
+	}
+
+	a.prune(now)
+	return a.save()
+}
+
+// Report summarizes the retained window. When differential privacy is
+// enabled the counts are noisy and never negative.
+type Report struct {
+	From             string         `json:"from"`
+	To               string         `json:"to"`
+	Events           int            `json:"events"`
+	Failures         int            `json:"failures"`
+	TotalTokens      int            `json:"total_tokens"`
+	ByKind           map[string]int `json:"by_kind"`
+	ByMode           map[string]int `json:"by_mode"`
+	TokenHistogram   map[string]int `json:"token_histogram"`
+	LatencyHistogram map[string]int `json:"latency_histogram"`
+	// Satisfaction is user sentiment per mode; SatisfactionTrend is per day
+	Satisfaction      map[string]Satisfaction `json:"satisfaction"`
+	SatisfactionTrend []DaySatisfaction       `json:"satisfaction_trend"`
+	Noisy             bool                    `json:"noisy"`
+}
+
+// Report aggregates all retained days
+func (a *Aggregator) Report() Report {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	report := Report{
+		ByKind:           make(map[string]int),
+		ByMode:           make(map[string]int),
+		TokenHistogram:   make(map[string]int),
+		LatencyHistogram: make(map[string]int),
+		Satisfaction:     make(map[string]Satisfaction),
+		Noisy:            a.config.Epsilon > 0,
+	}
+	byMode := make(map[string]*SentimentStats)
+
+	keys := make([]string, 0, len(a.days))
+	for key := range a.days {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if len(keys) > 0 {
+		report.From = keys[0]
+		report.To = keys[len(keys)-1]
+	}
+
+	for _, key := range keys {
+		day := a.days[key]
+		report.Events += day.Events
+		report.Failures += day.Failures
+		report.TotalTokens += day.TotalTokens
+		for k, v := range day.ByKind {
+			report.ByKind[k] += v
+		}
+		for k, v := range day.ByMode {
+			report.ByMode[k] += v
+		}
+		for i, v := range day.TokenHistogram {
+			report.TokenHistogram[tokenBucketLabel(i)] += v
+		}
+		for i, v := range day.LatencyHistogram {
+			report.LatencyHistogram[latencyBucketLabel(i)] += v
+		}
+
+		var daily SentimentStats
+		for mode, stats := range day.Sentiment {
+			total, ok := byMode[mode]
+			if !ok {
+				total = &SentimentStats{}
+				byMode[mode] = total
+			}
+			total.add(*stats)
+			daily.add(*stats)
+		}
+		if daily.Turns > 0 {
+			report.SatisfactionTrend = append(report.SatisfactionTrend, DaySatisfaction{Date: key, Satisfaction: a.satisfaction(daily)})
+		}
+	}
+	for mode, stats := range byMode {
+		report.Satisfaction[mode] = a.satisfaction(*stats)
+	}
+
+	if report.Noisy {
+		report.Events = a.noisy(report.Events)
+		report.Failures = a.noisy(report.Failures)
+		report.TotalTokens = a.noisy(report.TotalTokens)
+		for _, m := range []map[string]int{report.ByKind, report.ByMode, report.TokenHistogram, report.LatencyHistogram} {
+			for k, v := range m {
+				m[k] = a.noisy(v)
+			}
+		}
+	}
+
+	return report
+}
+
+// add folds other into s
+func (s *SentimentStats) add(other SentimentStats) {
+	s.Turns += other.Turns
+	s.Sum += other.Sum
+	s.Negative += other.Negative
+}
+
+// satisfaction turns accumulated sentiment into averages. With differential
+// privacy the turn count, sum and negative count are noised before dividing;
+// each turn changes the sum by at most 1, so the same noise scale applies.
+func (a *Aggregator) satisfaction(stats SentimentStats) Satisfaction {
+	turns, sum, negative := float64(stats.Turns), stats.Sum, float64(stats.Negative)
+	if a.config.Epsilon > 0 {
+		turns = float64(a.noisy(stats.Turns))
+		sum += a.laplace()
+		negative = float64(a.noisy(stats.Negative))
+	}
+
+	s := Satisfaction{Turns: int(turns)}
+	if turns > 0 {
+		s.Average = math.Max(-1, math.Min(1, sum/turns))
+		s.NegativePct = math.Min(100, negative/turns*100)
+	}
+	return s
+}
+
+// noisy adds Laplace(1/epsilon) noise to a count
+func (a *Aggregator) noisy(count int) int {
+	noisy := int(math.Round(float64(count) + a.laplace()))
+	if noisy < 0 {
+		return 0
+	}
+	return noisy
+}
+
+// laplace draws Laplace(1/epsilon) noise
+func (a *Aggregator) laplace() float64 {
+	scale := 1 / a.config.Epsilon
+	u := a.random.Float64() - 0.5
+	return -scale * math.Copysign(1, u) * math.Log(1-2*math.Abs(u))
+}
+
+// prune drops days older than the retention window
+func (a *Aggregator) prune(now time.Time) {
+	cutoff := now.AddDate(0, 0, -a.config.RetentionDays).Format("2006-01-02")
+	for key := range a.days {
+		if key < cutoff {
+			delete(a.days, key)
+		}
+	}
+}
+
+// save persists the aggregates. Callers must hold a.mu.
+func (a *Aggregator) save() error {
+	if a.config.Path == "" {
+		return nil
+	}
+
+	if err := aggregatesFormat.WriteFile(a.config.Path, a.days, 0644); err != nil {
+		return fmt.Errorf("failed to write analytics: %w", err)
+	}
+	return nil
+}
+
+func tokenBucket(tokens int) int {
+	for i, bound := range tokenBuckets {
+		if tokens <= bound {
+			return i
+		}
+	}
+	return len(tokenBuckets)
+}
+
+func latencyBucket(latency time.Duration) int {
+	for i, bound := range latencyBuckets {
+		if latency <= bound {
+			return i
+		}
+	}
+	return len(latencyBuckets)
+}
+
+func tokenBucketLabel(i int) string {
+	if i < len(tokenBuckets) {
+		return fmt.Sprintf("<=%d", tokenBuckets[i])
+	}
+	return fmt.Sprintf(">%d", tokenBuckets[len(tokenBuckets)-1])
+}
+
+func latencyBucketLabel(i int) string {
+	if i < len(latencyBuckets) {
+		return fmt.Sprintf("<=%s", latencyBuckets[i])
+	}
+	return fmt.Sprintf(">%s", latencyBuckets[len(latencyBuckets)-1])
+}
@@ -0,0 +1,338 @@
+package bus
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Topics published by the chatbot's subsystems
+const (
+	TopicMemoryUpdated = "memory.updated"
+	TopicJobSubmitted  = "jobs.submitted"
+	TopicJobFinished   = "jobs.finished"
+	TopicCircuitOpened = "circuit.opened"
+	TopicCircuitClosed = "circuit.closed"
+)
+
+// ErrClosed is returned when publishing to or subscribing on a closed bus
+var ErrClosed = errors.New("message bus is closed")
+
+// queueSize bounds the messages waiting for one subscriber
+const queueSize = 256
+
+// Message is an event published on a topic. Data holds the JSON-encoded
+// payload so local and NATS delivery look the same to subscribers.
+type Message struct {
+	Topic  string          `json:"topic"`
+	Source string          `json:"source,omitempty"`
+	Time   time.Time       `json:"time"`
+	Data   json.RawMessage `json:"data,omitempty"`
+}
+
+// Decode unmarshals the message payload into v
+func (m Message) Decode(v interface{}) error {
+	if err := json.Unmarshal(m.Data, v); err != nil {
+		return fmt.Errorf("failed to decode %s message: %w", m.Topic, err)
+	}
+	return nil
+}
+
+// Handler receives messages for a subscription. Each subscription has its
+// own goroutine, so a slow handler only delays its own messages.
+type Handler func(Message)
+
+// Bus lets subsystems publish events and subscribe to them asynchronously.
+// Patterns use NATS syntax: tokens are separated by dots, "*" matches one
+// token and a trailing ">" matches the rest ("circuit.>").
+type Bus interface {
+	Publish(topic string, data interface{}) error
+	Subscribe(pattern string, handler Handler) (*Subscription, error)
+	Stats() Stats
+	Close() error
+}
+
+// Stats is a snapshot of a bus's traffic
+type Stats struct {
+	Backend       string              `json:"backend"`
+	Published     int64               `json:"published"`
+	Subscriptions []SubscriptionStats `json:"subscriptions"`
+}
+
+// SubscriptionStats counts what one subscription received
+type SubscriptionStats struct {
+	Pattern   string `json:"pattern"`
+	Delivered int64  `json:"delivered"`
+	Dropped   int64  `json:"dropped"`
+}
+
+// Subscription is a handler registered for a topic pattern
+type Subscription struct {
+	pattern   string
+	handler   Handler
+	queue     chan Message
+	done      chan struct{}
+	once      sync.Once
+	remove    func()
+	delivered atomic.Int64
+	dropped   atomic.Int64
+}
+
+// newSubscription starts the goroutine that feeds queued messages to handler
+func newSubscription(pattern string, handler Handler) *Subscription {
+	s := &Subscription{
+		pattern: pattern,
+		handler: handler,
+		queue:   make(chan Message, queueSize),
+		done:    make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// run delivers queued messages until the subscription is closed
+func (s *Subscription) run() {
+	for {
+		select {
+		case msg := <-s.queue:
+			s.handler(msg)
+			s.delivered.Add(1)
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// deliver queues a message without blocking the publisher
+func (s *Subscription) deliver(msg Message) {
+	select {
+	case <-s.done:
+	case s.queue <- msg:
+	default:
+		// Slow subscriber; dropping beats stalling the publisher
+		s.dropped.Add(1)
+	}
+}
+
+// Pattern returns the topic pattern the subscription matches
+func (s *Subscription) Pattern() string {
+	return s.pattern
+}
+
+// Unsubscribe stops delivery to the handler. Queued messages are discarded.
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(func() {
+		close(s.done)
+		if s.remove != nil {
+			s.remove()
+		}
+	})
+}
+
+// stats returns the subscription's counters
+func (s *Subscription) stats() SubscriptionStats {
+	return SubscriptionStats{
+		Pattern:   s.pattern,
+		Delivered: s.delivered.Load(),
+		Dropped:   s.dropped.Load(),
+	}
+}
+
+// Local is an in-process Bus
+type Local struct {
+	source    string
+	subs      []*Subscription
+	published atomic.Int64
+	closed    bool
+	mu        sync.Mutex
+}
+
+// NewLocal creates an in-process bus. source is stamped on every message.
+func NewLocal(source string) *Local {
+	return &Local{source: source}
+}
+
+// Publish sends data to every subscription matching topic
+func (b *Local) Publish(topic string, data interface{}) error {
+	msg, err := newMessage(b.source, topic, data)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrClosed
+	}
+	b.published.Add(1)
+	for _, sub := range b.subs {
+		if Match(sub.pattern, topic) {
+			sub.deliver(msg)
+		}
+	}
+	return nil
+}
+
+// Subscribe registers handler for topics matching pattern
+func (b *Local) Subscribe(pattern string, handler Handler) (*Subscription, error) {
+	if err := validatePattern(pattern); err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrClosed
+	}
+	sub := newSubscription(pattern, handler)
+	sub.remove = func() { b.remove(sub) }
+	b.subs = append(b.subs, sub)
+	return sub, nil
+}
+
+// remove drops a subscription from the bus
+func (b *Local) remove(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, s := range b.subs {
+		if s == sub {
+			b.subs = append(b.subs[:i], b.subs[i+1:]...)
+			return
+		}
+	}
+}
+
+// Stats returns the bus's traffic counters
+func (b *Local) Stats() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := Stats{Backend: "local", Published: b.published.Load()}
+	for _, sub := range b.subs {
+		stats.Subscriptions = append(stats.Subscriptions, sub.stats())
+	}
+	return stats
+}
+
+// Close unsubscribes every handler and rejects further publishes
+func (b *Local) Close() error {
+	b.mu.Lock()
+	subs := b.subs
+	b.subs = nil
+	b.closed = true
+	b.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.once.Do(func() { close(sub.done) })
+	}
+	return nil
+}
+
+// newMessage encodes data into a message for topic
+func newMessage(source, topic string, data interface{}) (Message, error) {
+	if err := validateTopic(topic); err != nil {
+		return Message{}, err
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return Message{}, fmt.Errorf("failed to encode %s message: %w", topic, err)
+	}
+	return Message{Topic: topic, Source: source, Time: time.Now(), Data: encoded}, nil
+}
+
+// Match reports whether topic matches a subscription pattern
+func Match(pattern, topic string) bool {
+	patternTokens := strings.Split(pattern, ".")
+	topicTokens := strings.Split(topic, ".")
+
+	for i, token := range patternTokens {
+		if token == ">" {
+			return len(topicTokens) > i
+		}
+		if i >= len(topicTokens) {
+			return false
+		}
+		if token != "*" && token != topicTokens[i] {
+			return false
+		}
+	}
+	return len(patternTokens) == len(topicTokens)
+}
+
+// validateTopic rejects topics that can't be published, including wildcards
+func validateTopic(topic string) error {
+	if err := validatePattern(topic); err != nil {
+		return err
+	}
+	if strings.ContainsAny(topic, "*>") {
+		return fmt.Errorf("invalid topic %q: wildcards are only allowed in subscriptions", topic)
+	}
+	return nil
+}
+
+// validatePattern checks a subscription pattern's tokens
+func validatePattern(pattern string) error {
+	if pattern == "" || strings.ContainsAny(pattern, " \t\r\n") {
+		return fmt.Errorf("invalid topic %q", pattern)
+	}
+	tokens := strings.Split(pattern, ".")
+	for i, token := range tokens {
+		if token == "" {
+			return fmt.Errorf("invalid topic %q: empty token", pattern)
+		}
+		if token == ">" && i != len(tokens)-1 {
+			return fmt.Errorf("invalid topic %q: '>' must be the last token", pattern)
+		}
+	}
+	return nil
+}
+
+// Recorder keeps the most recent messages matching a pattern, for
+// dashboards and the /bus command
+type Recorder struct {
+	sub      *Subscription
+	limit    int
+	messages []Message
+	mu       sync.Mutex
+}
+
+// NewRecorder subscribes to pattern on b and keeps the last limit messages
+func NewRecorder(b Bus, pattern string, limit int) (*Recorder, error) {
+	r := &Recorder{limit: limit}
+	sub, err := b.Subscribe(pattern, r.record)
+	if err != nil {
+		return nil, err
+	}
+	r.sub = sub
+	return r, nil
+}
+
+// record appends a message, dropping the oldest past the limit
+func (r *Recorder) record(msg Message) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.messages = append(r.messages, msg)
+	if len(r.messages) > r.limit {
+		r.messages = r.messages[len(r.messages)-r.limit:]
+	}
+}
+
+// Recent returns the recorded messages, oldest first
+func (r *Recorder) Recent() []Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Message(nil), r.messages...)
+}
+
+// Stop ends the recorder's subscription
+func (r *Recorder) Stop() {
+	r.sub.Unsubscribe()
+}
@@ -0,0 +1,319 @@
+package bus
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// natsDialTimeout bounds connecting and the initial handshake
+const natsDialTimeout = 5 * time.Second
+
+// NATS is a Bus backed by a NATS server, so several chatbot processes can
+// share events. It speaks the plain-text NATS client protocol directly and
+// does not reconnect; a lost connection is reported by Err.
+type NATS struct {
+	source    string
+	conn      net.Conn
+	writer    *bufio.Writer
+	subs      map[int]*Subscription
+	nextSID   int
+	published atomic.Int64
+	err       error
+	closed    bool
+	mu        sync.Mutex
+	// writeMu serializes protocol writes, which also happen from the read loop
+	writeMu sync.Mutex
+}
+
+// ConnectNATS connects to a NATS server at a nats://host:port URL. source is
+// stamped on every message so subscribers can tell processes apart.
+func ConnectNATS(rawURL, source string) (*NATS, error) {
+	addr, err := natsAddress(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, natsDialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS at %s: %w", addr, err)
+	}
+	b := &NATS{
+		source: source,
+		conn:   conn,
+		writer: bufio.NewWriter(conn),
+		subs:   make(map[int]*Subscription),
+	}
+
+	reader := bufio.NewReader(conn)
+	if err := b.handshake(reader); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	go b.readLoop(reader)
+	return b, nil
+}
+
+// natsAddress turns a NATS URL into a host:port, defaulting the port to 4222
+func natsAddress(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "nats" || u.Hostname() == "" {
+		return "", fmt.Errorf("invalid NATS URL %q: expected nats://host:port", rawURL)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "4222"
+	}
+	return net.JoinHostPort(u.Hostname(), port), nil
+}
+
+// handshake reads the server's INFO, sends CONNECT and waits for the PONG
+// that confirms the server accepted it
+func (b *NATS) handshake(reader *bufio.Reader) error {
+	b.conn.SetDeadline(time.Now().Add(natsDialTimeout))
+	defer b.conn.SetDeadline(time.Time{})
+
+	line, err := readLine(reader)
+	if err != nil {
+		return fmt.Errorf("failed to read NATS greeting: %w", err)
+	}
+	if !strings.HasPrefix(line, "INFO") {
+		return fmt.Errorf("unexpected NATS greeting: %s", line)
+	}
+
+	connect, _ := json.Marshal(map[string]interface{}{
+		"verbose":  false,
+		"pedantic": false,
+		"name":     b.source,
+		"lang":     "go",
+	})
+	if err := b.write("CONNECT %s\r\nPING\r\n", connect); err != nil {
+		return fmt.Errorf("failed to send NATS handshake: %w", err)
+	}
+
+	for {
+		line, err := readLine(reader)
+		if err != nil {
+			return fmt.Errorf("failed to complete NATS handshake: %w", err)
+		}
+		switch {
+		case line == "PONG":
+			return nil
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("NATS server rejected connection: %s", strings.TrimSpace(line[4:]))
+		}
+	}
+}
+
+// Publish sends data to the server, which fans it out to every subscriber
+// in every connected process, this one included
+func (b *NATS) Publish(topic string, data interface{}) error {
+	msg, err := newMessage(b.source, topic, data)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s message: %w", topic, err)
+	}
+
+	if err := b.usable(); err != nil {
+		return err
+	}
+	if err := b.write("PUB %s %d\r\n%s\r\n", topic, len(payload), payload); err != nil {
+		return fmt.Errorf("failed to publish %s: %w", topic, err)
+	}
+	b.published.Add(1)
+	return nil
+}
+
+// Subscribe registers handler for topics matching pattern on the server
+func (b *NATS) Subscribe(pattern string, handler Handler) (*Subscription, error) {
+	if err := validatePattern(pattern); err != nil {
+		return nil, err
+	}
+	if err := b.usable(); err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	b.nextSID++
+	sid := b.nextSID
+	sub := newSubscription(pattern, handler)
+	b.subs[sid] = sub
+	b.mu.Unlock()
+
+	sub.remove = func() {
+		b.mu.Lock()
+		delete(b.subs, sid)
+		b.mu.Unlock()
+		b.write("UNSUB %d\r\n", sid)
+	}
+	if err := b.write("SUB %s %d\r\n", pattern, sid); err != nil {
+		sub.Unsubscribe()
+		return nil, fmt.Errorf("failed to subscribe to %s: %w", pattern, err)
+	}
+	return sub, nil
+}
+
+// readLoop dispatches messages from the server until the connection ends
+func (b *NATS) readLoop(reader *bufio.Reader) {
+	for {
+		line, err := readLine(reader)
+		if err != nil {
+			b.fail(err)
+			return
+		}
+
+		switch {
+		case strings.HasPrefix(line, "MSG "):
+			if err := b.dispatch(line, reader); err != nil {
+				b.fail(err)
+				return
+			}
+		case line == "PING":
+			b.write("PONG\r\n")
+		case strings.HasPrefix(line, "-ERR"):
+			b.fail(fmt.Errorf("NATS server error: %s", strings.TrimSpace(line[4:])))
+			return
+		}
+		// INFO, +OK and PONG need no action
+	}
+}
+
+// dispatch reads the payload of a MSG line and hands it to its subscription.
+// The line is "MSG <subject> <sid> [reply-to] <#bytes>".
+func (b *NATS) dispatch(line string, reader *bufio.Reader) error {
+	fields := strings.Fields(line)
+	if len(fields) < 4 || len(fields) > 5 {
+		return fmt.Errorf("malformed NATS message: %s", line)
+	}
+	sid, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return fmt.Errorf("malformed NATS message: %s", line)
+	}
+	size, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil || size < 0 {
+		return fmt.Errorf("malformed NATS message: %s", line)
+	}
+
+	payload := make([]byte, size+2)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return err
+	}
+
+	var msg Message
+	if err := json.Unmarshal(payload[:size], &msg); err != nil || msg.Topic == "" {
+		// Published by something other than a chatbot; pass the raw payload on
+		msg = Message{Topic: fields[1], Time: time.Now(), Data: json.RawMessage(payload[:size])}
+		if !json.Valid(msg.Data) {
+			msg.Data, _ = json.Marshal(string(payload[:size]))
+		}
+	}
+
+	b.mu.Lock()
+	sub, ok := b.subs[sid]
+	b.mu.Unlock()
+	if ok {
+		sub.deliver(msg)
+	}
+	return nil
+}
+
+// fail records a connection error and stops every subscription
+func (b *NATS) fail(err error) {
+	b.mu.Lock()
+	if b.err == nil && !b.closed {
+		b.err = fmt.Errorf("NATS connection lost: %w", err)
+	}
+	subs := b.subs
+	b.subs = make(map[int]*Subscription)
+	b.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.once.Do(func() { close(sub.done) })
+	}
+	b.conn.Close()
+}
+
+// usable returns an error if the bus was closed or lost its connection
+func (b *NATS) usable() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrClosed
+	}
+	return b.err
+}
+
+// Err returns the error that ended the connection, if any
+func (b *NATS) Err() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err
+}
+
+// Stats returns the bus's traffic counters
+func (b *NATS) Stats() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sids := make([]int, 0, len(b.subs))
+	for sid := range b.subs {
+		sids = append(sids, sid)
+	}
+	sort.Ints(sids)
+
+	stats := Stats{Backend: "nats", Published: b.published.Load()}
+	for _, sid := range sids {
+		stats.Subscriptions = append(stats.Subscriptions, b.subs[sid].stats())
+	}
+	return stats
+}
+
+// Close flushes pending writes and disconnects from the server
+func (b *NATS) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	b.mu.Unlock()
+
+	b.writeMu.Lock()
+	b.writer.Flush()
+	b.writeMu.Unlock()
+	b.fail(ErrClosed)
+	return nil
+}
+
+// write sends a protocol command and flushes it
+func (b *NATS) write(format string, args ...interface{}) error {
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+
+	if _, err := fmt.Fprintf(b.writer, format, args...); err != nil {
+		return err
+	}
+	return b.writer.Flush()
+}
+
+// readLine reads one CRLF-terminated protocol line
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,103 @@
+package chatbot
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+
+	"chatbot/jobs"
+)
+
+// BatchResult is one line of a batch job's output file
+type BatchResult struct {
+	Prompt   string `json:"prompt"`
+	Response string `json:"response,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Tokens   int    `json:"tokens"`
+
+	Provenance *Provenance `json:"provenance,omitempty"`
+}
+
+// SubmitBatch starts a background job that answers every non-empty line of
+// inputPath with the current mode's system prompt and writes JSON lines to
+// outputPath. It returns the job ID.
+func (b *Bot) SubmitBatch(inputPath, outputPath string) (string, error) {
+	prompts, err := readPrompts(inputPath)
+	if err != nil {
+		return "", err
+	}
+	if len(prompts) == 0 {
+		return "", fmt.Errorf("no prompts found in %s", inputPath)
+	}
+
+	mode := b.stats.CurrentMode
+	systemPrompt := b.systemPrompt(mode)
+	name := fmt.Sprintf("batch %s (%d prompts)", inputPath, len(prompts))
+
+	return b.jobs.Submit(name, func(ctx context.Context, r *jobs.Reporter) error {
+		out, err := os.Create(outputPath)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer out.Close()
+
+		encoder := json.NewEncoder(out)
+		for i, prompt := range prompts {
+			if ctx.Err() != nil {
+				r.Logf("cancelled after %d/%d prompts", i, len(prompts))
+				return ctx.Err()
+			}
+
+			result := BatchResult{Prompt: prompt}
+			resp, err := b.llmClient.ChatCompletion(ctx, []openai.ChatCompletionMessage{
+				{Role: "system", Content: systemPrompt},
+				{Role: "user", Content: prompt},
+			}, b.config.MaxTokens, b.config.Temperature)
+			if err != nil {
+				result.Error = err.Error()
+				r.Logf("prompt %d failed: %v", i+1, err)
+			} else if len(resp.Choices) > 0 {
+				result.Response = resp.Choices[0].Message.Content
+				result.Tokens = resp.Usage.TotalTokens
+				result.Provenance = b.newProvenance(mode, systemPrompt)
+				r.Logf("prompt %d/%d done (%d tokens)", i+1, len(prompts), result.Tokens)
+			}
+
+			if err := encoder.Encode(result); err != nil {
+				return fmt.Errorf("failed to write result: %w", err)
+			}
+			r.Progress(float64(i+1) / float64(len(prompts)))
+		}
+
+		r.Logf("wrote %d results to %s", len(prompts), outputPath)
+		return nil
+	}), nil
+}
+
+// Jobs returns the bot's background job manager
+func (b *Bot) Jobs() *jobs.Manager {
+	return b.jobs
+}
+
+// readPrompts returns the non-empty lines of a file
+func readPrompts(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open batch input: %w", err)
+	}
+	defer file.Close()
+
+	var prompts []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			prompts = append(prompts, line)
+		}
+	}
+	return prompts, scanner.Err()
+}
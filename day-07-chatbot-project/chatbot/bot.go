@@ -2,22 +2,61 @@ package chatbot
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/sakibmulla/agentic-ai/costs"
+	"github.com/sakibmulla/agentic-ai/guardrails"
+	"github.com/sakibmulla/agentic-ai/logging"
+	"github.com/sakibmulla/agentic-ai/tokenizer"
+	"github.com/sakibmulla/agentic-ai/tools"
 	"github.com/sashabaranov/go-openai"
 
+	"chatbot/analytics"
+	"chatbot/bus"
 	"chatbot/config"
+	"chatbot/jobs"
 	"chatbot/llm"
+	"chatbot/tenants"
 )
 
 // Bot represents the main chatbot instance
 type Bot struct {
-	llmClient *llm.Client
-	config    *Config
-	memory    *Memory
-	history   *History
-	stats     *Stats
+	llmClient  *llm.Client
+	config     *Config
+	memory     *Memory
+	history    *History
+	stats      *Stats
+	slots      *SlotFiller
+	guardrails *Guardrails
+	// filters redacts, moderates and blocks messages and replies, whatever
+	// the persona
+	filters   *guardrails.Pipeline
+	jobs      *jobs.Manager
+	analytics *analytics.Aggregator
+	tenant    string
+	overrides *tenants.OverrideStore
+	keyRing   *tenants.KeyRing
+	events    bus.Bus
+	recorder  *bus.Recorder
+	tools     *tools.Registry
+	retriever Retriever
+	// sampling holds the current mode's sampling options
+	sampling llm.Sampling
+
+	lastResponse   string
+	lastProvenance *Provenance
+	// sentiment holds the score of each user turn in the current conversation
+	sentiment []float64
+	// recovered is the number of messages restored from the memory log at startup
+	recovered int
+	// session is the ID of the session the bot serves; see SessionManager
+	session string
 }
 
 // Config holds bot-specific configuration
@@ -28,6 +67,9 @@ type Config struct {
 	RetryAttempts int
 	RetryDelay    time.Duration
 	SaveDirectory string
+	// MemoryTokenBudget, when positive, compresses memory by tokens rather
+	// than trimming it by MaxHistory
+	MemoryTokenBudget int
 }
 
 // Stats tracks bot usage statistics
@@ -35,6 +77,7 @@ type Stats struct {
 	MessageCount int
 	TokensUsed   int
 	CurrentMode  string
+	Verbosity    Verbosity
 	StartTime    time.Time
 }
 
@@ -47,87 +90,449 @@ func New(llmClient *llm.Client, cfg *config.Config) (*Bot, error) {
 		RetryAttempts: cfg.RetryAttempts,
 		RetryDelay:    cfg.RetryDelay,
 		SaveDirectory: cfg.SaveDirectory,
+
+		MemoryTokenBudget: cfg.MemoryTokenBudget,
 	}
 
 	memory := NewMemory(cfg.MaxHistory)
-	history, err := NewHistory(cfg.SaveDirectory)
+	memory.SetTokenBudget(cfg.MemoryTokenBudget, tokenizer.ForModel(llmClient.GetModel()))
+	history, keyRing, err := OpenHistory(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	slots, err := NewSlotFiller(filepath.Join(cfg.SaveDirectory, "slot_state.json"))
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize history: %w", err)
+		return nil, fmt.Errorf("failed to initialize slot filler: %w", err)
+	}
+	slots.RegisterTask(BookingTask())
+
+	filters, err := guardrails.FromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("invalid guardrail settings: %w", err)
+	}
+	if filters.LogPath == "" {
+		filters.LogPath = filepath.Join(cfg.SaveDirectory, "guardrails_log.jsonl")
+	}
+
+	guardrails := NewGuardrails(filepath.Join(cfg.SaveDirectory, "safety_log.jsonl"))
+	if cfg.SafetyPolicyFile != "" {
+		if err := guardrails.LoadPolicies(cfg.SafetyPolicyFile); err != nil {
+			return nil, fmt.Errorf("failed to load safety policies: %w", err)
+		}
+	}
+
+	jobManager, err := jobs.NewManager(cfg.JobsStatePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize job manager: %w", err)
+	}
+
+	usage, err := analytics.NewAggregator(analytics.Config{
+		Path:          cfg.AnalyticsPath,
+		RetentionDays: cfg.AnalyticsRetentionDays,
+		Epsilon:       cfg.AnalyticsEpsilon,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize analytics: %w", err)
+	}
+
+	overrides, err := tenants.NewOverrideStore(cfg.TenantDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize tenant overrides: %w", err)
+	}
+
+	verbosity := VerbosityNormal
+	if cfg.Verbosity != "" {
+		if verbosity, err = ParseVerbosity(cfg.Verbosity); err != nil {
+			return nil, fmt.Errorf("invalid VERBOSITY: %w", err)
+		}
 	}
 
 	stats := &Stats{
 		MessageCount: 0,
 		TokensUsed:   0,
 		CurrentMode:  "assistant",
+		Verbosity:    verbosity,
 		StartTime:    time.Now(),
 	}
 
+	// Events go to in-process subscribers, or through NATS to every process
+	source := fmt.Sprintf("chatbot/%s/%d", cfg.TenantID, os.Getpid())
+	var events bus.Bus = bus.NewLocal(source)
+	if cfg.BusNATSURL != "" {
+		nats, err := bus.ConnectNATS(cfg.BusNATSURL, source)
+		if err != nil {
+			return nil, err
+		}
+		events = nats
+	}
+	recorder, err := bus.NewRecorder(events, ">", recentEvents)
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to bus: %w", err)
+	}
+	jobManager.SetBus(events)
+	if keys := llmClient.Keys(); keys != nil {
+		keys.SetBus(events)
+	}
+
 	bot := &Bot{
-		llmClient: llmClient,
-		config:    botConfig,
-		memory:    memory,
-		history:   history,
-		stats:     stats,
+		llmClient:  llmClient,
+		config:     botConfig,
+		memory:     memory,
+		history:    history,
+		stats:      stats,
+		slots:      slots,
+		guardrails: guardrails,
+		filters:    filters,
+		jobs:       jobManager,
+		analytics:  usage,
+		tenant:     cfg.TenantID,
+		overrides:  overrides,
+		keyRing:    keyRing,
+		events:     events,
+		recorder:   recorder,
+		tools:      tools.NewRegistry(),
+	}
+
+	// Shared tools the model may call while answering
+	if cfg.EnableTools {
+		bot.tools.MustRegister(tools.Calculator(), tools.TextAnalysis())
+		if cfg.EnableWebTools {
+			web, err := tools.WebFromEnv()
+			if err != nil {
+				return nil, fmt.Errorf("invalid web tool settings: %w", err)
+			}
+			bot.tools.MustRegister(web...)
+		}
 	}
 
-	// Set initial system message
-	bot.memory.SetSystemMessage(llm.GetSystemPrompt("assistant"))
+	// Restore the conversation interrupted by a crash, if logging is enabled
+	if cfg.MemoryWALPath != "" {
+		wal, err := OpenMemoryWAL(cfg.MemoryWALPath)
+		if err != nil {
+			return nil, err
+		}
+		// Memory is encrypted at rest like saved conversations
+		if keyRing != nil {
+			wal.SetEncryption(keyRing, cfg.TenantID)
+		}
+		if _, err := memory.AttachWAL(wal); err != nil {
+			return nil, fmt.Errorf("failed to recover memory: %w", err)
+		}
+		bot.recovered = memory.GetMessageCount()
+		bot.rescoreSentiment(memory.GetConversation())
+	}
+
+	// Set initial system message and sampling
+	bot.memory.SetSystemMessage(bot.conversationPrompt("assistant"))
+	if bot.sampling, err = bot.samplingFor("assistant"); err != nil {
+		return nil, err
+	}
 
 	return bot, nil
 }
 
 // ProcessMessage processes a user message and returns the bot's response
 func (b *Bot) ProcessMessage(ctx context.Context, message string) (string, error) {
+	// Spend the turn's calls on the tenant, session and mode, and tag their
+	// log records with the caller's correlation ID or a new one
+	ctx = costs.WithAttribution(ctx, costs.Attribution{User: b.tenant, Session: b.session, Template: b.stats.CurrentMode})
+	ctx = logging.EnsureCorrelationID(ctx)
+
+	// Route answers to an in-progress form instead of the LLM
+	if b.slots.Active() {
+		return b.fillSlot(message)
+	}
+
+	// Redact or block the message before anything else sees it
+	message, err := b.filters.CheckInput(ctx, message)
+	if errors.Is(err, guardrails.ErrBlocked) {
+		b.stats.MessageCount++
+		b.lastResponse = safetyRefusal
+		b.lastProvenance = nil
+		return safetyRefusal, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// Apply the persona's safety policy to the input
+	turn := b.stats.MessageCount + 1
+	inputVerdict := b.guardrails.CheckInput(b.stats.CurrentMode, message, turn)
+	if inputVerdict.Blocked {
+		b.stats.MessageCount++
+		b.lastResponse = safetyRefusal
+		b.lastProvenance = nil
+		return safetyRefusal, nil
+	}
+
+	// Look up reference material before the turn is remembered, so a
+	// failed lookup leaves memory as it was
+	var reference string
+	if b.retriever != nil {
+		if reference, err = b.retriever.Retrieve(ctx, message); err != nil {
+			return "", fmt.Errorf("failed to retrieve reference material: %w", err)
+		}
+	}
+
 	// Add user message to memory
 	b.memory.AddMessage("user", message)
 	b.stats.MessageCount++
+	sentiment := ScoreSentiment(message)
+	b.sentiment = append(b.sentiment, sentiment)
 
 	// Get conversation messages for the API
 	messages := b.memory.GetMessages()
+	if reference != "" {
+		messages = withReference(messages, reference)
+	}
+	if len(inputVerdict.Guidance) > 0 {
+		messages = withSafetyGuidance(messages, inputVerdict.Guidance)
+	}
 
-	// Try to get response with retries
-	var response *openai.ChatCompletionResponse
-	var err error
+	started := time.Now()
+	response, tokens, err := b.respond(ctx, messages)
+	if err != nil {
+		b.recordUsage(started, tokens, sentiment, true)
+		return "", err
+	}
+
+	botResponse := response.Choices[0].Message.Content
+	if b.guardrails.CheckOutput(b.stats.CurrentMode, botResponse, turn).Blocked {
+		botResponse = safetyRefusal
+	}
+	botResponse, err = b.filters.CheckOutput(ctx, botResponse)
+	if errors.Is(err, guardrails.ErrBlocked) {
+		botResponse = safetyRefusal
+	} else if err != nil {
+		b.recordUsage(started, tokens, sentiment, true)
+		return "", err
+	}
+
+	// Add bot response to memory
+	b.memory.AddMessage("assistant", botResponse)
+	b.lastResponse = botResponse
+	b.lastProvenance = b.newProvenance(b.stats.CurrentMode, b.conversationPrompt(b.stats.CurrentMode))
+
+	// Update token usage
+	b.stats.TokensUsed += tokens
+	b.recordUsage(started, tokens, sentiment, false)
+	b.announceMemory("turn")
+	b.compressMemory(ctx)
+
+	return botResponse, nil
+}
+
+// maxToolRounds bounds the tool calls made while answering one message
+const maxToolRounds = 5
+
+// respond gets the model's reply to messages, running any tools it calls
+// along the way. Tool calls and results are kept out of memory; only the
+// final answer is remembered. It returns the tokens used by every call.
+func (b *Bot) respond(ctx context.Context, messages []openai.ChatCompletionMessage) (*openai.ChatCompletionResponse, int, error) {
+	response, err := b.completeWithRetry(ctx, messages)
+	if err != nil {
+		return nil, 0, err
+	}
+	tokens := response.Usage.TotalTokens
+
+	// Copy so tool messages never land in memory's backing array
+	messages = append([]openai.ChatCompletionMessage(nil), messages...)
+	for round := 0; response.Choices[0].Message.FunctionCall != nil; round++ {
+		if round == maxToolRounds {
+			return nil, tokens, fmt.Errorf("no answer after %d tool calls", maxToolRounds)
+		}
+		call := response.Choices[0].Message.FunctionCall
+
+		// Failed calls, including invalid arguments, are reported to the model
+		result, err := b.tools.Call(ctx, call.Name, call.Arguments)
+		if err != nil {
+			result = fmt.Sprintf("Error: %v", err)
+		}
+		messages = append(messages, response.Choices[0].Message, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleFunction,
+			Name:    call.Name,
+			Content: result,
+		})
+
+		if response, err = b.completeWithRetry(ctx, messages); err != nil {
+			return nil, tokens, err
+		}
+		tokens += response.Usage.TotalTokens
+	}
+	return response, tokens, nil
+}
+
+// completeWithRetry makes one model call, retrying failures other than the
+// spend limit and budgets. The bot's tools are declared when any are registered.
+func (b *Bot) completeWithRetry(ctx context.Context, messages []openai.ChatCompletionMessage) (*openai.ChatCompletionResponse, error) {
+	var functions []openai.FunctionDefinition
+	for _, definition := range b.tools.Definitions() {
+		functions = append(functions, openai.FunctionDefinition{
+			Name:        definition.Name,
+			Description: definition.Description,
+			Parameters:  definition.Parameters,
+		})
+	}
+	maxTokens := b.stats.Verbosity.MaxTokens(b.config.MaxTokens)
+
+	client, err := b.llmClient.WithSampling(b.sampling)
+	if err != nil {
+		return nil, err
+	}
 
+	var response *openai.ChatCompletionResponse
 	for attempt := 0; attempt < b.config.RetryAttempts; attempt++ {
-		response, err = b.llmClient.ChatCompletion(
-			ctx,
-			messages,
-			b.config.MaxTokens,
-			b.config.Temperature,
-		)
-
-		if err == nil {
+		if len(functions) > 0 {
+			response, err = client.ChatCompletionWithFunctions(ctx, messages, functions, maxTokens, b.config.Temperature)
+		} else {
+			response, err = client.ChatCompletion(ctx, messages, maxTokens, b.config.Temperature)
+		}
+
+		if err == nil || errors.Is(err, llm.ErrSpendLimitExceeded) || errors.Is(err, costs.ErrBudgetExceeded) {
 			break
 		}
 
 		if attempt < b.config.RetryAttempts-1 {
+			slog.WarnContext(ctx, "model call failed, retrying", "attempt", attempt+1, "error", err)
 			time.Sleep(b.config.RetryDelay * time.Duration(attempt+1))
 		}
 	}
 
 	if err != nil {
-		return "", fmt.Errorf("failed to get response after %d attempts: %w", b.config.RetryAttempts, err)
+		return nil, fmt.Errorf("failed to get response after %d attempts: %w", b.config.RetryAttempts, err)
 	}
-
 	if len(response.Choices) == 0 {
-		return "", fmt.Errorf("no response choices returned")
+		return nil, fmt.Errorf("no response choices returned")
 	}
+	return response, nil
+}
 
-	botResponse := response.Choices[0].Message.Content
+// safetyRefusal is returned when a turn is blocked by the safety policy
+const safetyRefusal = "Sorry, I can't help with that request."
+
+// withSafetyGuidance returns a copy of messages with the policy guidance
+// appended as a system message for this turn only
+func withSafetyGuidance(messages []openai.ChatCompletionMessage, guidance []string) []openai.ChatCompletionMessage {
+	guided := make([]openai.ChatCompletionMessage, len(messages), len(messages)+1)
+	copy(guided, messages)
+	return append(guided, openai.ChatCompletionMessage{
+		Role:    "system",
+		Content: "Safety policy for this turn:\n- " + strings.Join(guidance, "\n- "),
+	})
+}
 
-	// Add bot response to memory
-	b.memory.AddMessage("assistant", botResponse)
+// recordUsage adds an anonymous chat event to the usage analytics.
+// Analytics failures never fail the turn.
+func (b *Bot) recordUsage(started time.Time, tokens int, sentiment float64, failed bool) {
+	_ = b.analytics.Record(analytics.Event{
+		Kind:      "chat",
+		Mode:      b.stats.CurrentMode,
+		Tokens:    tokens,
+		Latency:   time.Since(started),
+		Failed:    failed,
+		Sentiment: &sentiment,
+	})
+}
 
-	// Update token usage
-	b.stats.TokensUsed += response.Usage.TotalTokens
+// MemoryUpdate is published on memory.updated whenever the conversation changes
+type MemoryUpdate struct {
+	Reason   string `json:"reason"` // turn, compress, clear or load
+	Mode     string `json:"mode"`
+	Messages int    `json:"messages"`
+	Session  string `json:"session,omitempty"`
+}
 
-	return botResponse, nil
+// announceMemory publishes a memory.updated event. Bus failures never fail the turn.
+func (b *Bot) announceMemory(reason string) {
+	_ = b.events.Publish(bus.TopicMemoryUpdated, MemoryUpdate{
+		Reason:   reason,
+		Mode:     b.stats.CurrentMode,
+		Messages: b.memory.GetMessageCount(),
+		Session:  b.session,
+	})
+}
+
+// recentEvents is how many bus messages RecentEvents keeps
+const recentEvents = 20
+
+// Bus returns the message bus the bot's subsystems publish events on
+func (b *Bot) Bus() bus.Bus {
+	return b.events
+}
+
+// RecentEvents returns the latest messages seen on the bus, oldest first
+func (b *Bot) RecentEvents() []bus.Message {
+	return b.recorder.Recent()
+}
+
+// Analytics returns the bot's aggregate usage analytics
+func (b *Bot) Analytics() *analytics.Aggregator {
+	return b.analytics
+}
+
+// Tools returns the registry of tools the model may call. With no tools
+// registered, messages are answered without function calling.
+func (b *Bot) Tools() *tools.Registry {
+	return b.tools
+}
+
+// Guardrails returns the bot's safety guardrails
+func (b *Bot) Guardrails() *Guardrails {
+	return b.guardrails
+}
+
+// Filters returns the guardrail pipeline every message and reply passes
+// through. Processors can be added to its Input and Output before chatting.
+func (b *Bot) Filters() *guardrails.Pipeline {
+	return b.filters
+}
+
+// fillSlot answers the active task's pending question
+func (b *Bot) fillSlot(message string) (string, error) {
+	reply, _, err := b.slots.Fill(message)
+	if err != nil {
+		return "", err
+	}
+
+	b.memory.AddMessage("user", message)
+	b.memory.AddMessage("assistant", reply)
+	b.stats.MessageCount++
+	b.lastResponse = reply
+	b.lastProvenance = nil
+	return reply, nil
+}
+
+// StartTask begins a slot-filling task and returns its first question
+func (b *Bot) StartTask(name string) (string, error) {
+	return b.slots.Start(name)
+}
+
+// ResumeTask returns the pending question of a task restored from a previous session
+func (b *Bot) ResumeTask() (string, bool) {
+	if !b.slots.Active() {
+		return "", false
+	}
+	prompt, err := b.slots.Resume()
+	if err != nil {
+		return "", false
+	}
+	return fmt.Sprintf("Resuming '%s': %s", b.slots.State().Task, prompt), true
+}
+
+// CancelTask abandons the active slot-filling task
+func (b *Bot) CancelTask() error {
+	return b.slots.Cancel()
+}
+
+// Tasks returns the available slot-filling tasks
+func (b *Bot) Tasks() []SlotTask {
+	return b.slots.Tasks()
 }
 
 // SetMode changes the conversation mode
 func (b *Bot) SetMode(mode string) error {
-	availableModes := llm.GetAvailableModes()
+	availableModes := b.Modes()
 	valid := false
 	for _, m := range availableModes {
 		if m == mode {
@@ -139,22 +544,149 @@ func (b *Bot) SetMode(mode string) error {
 	if !valid {
 		return fmt.Errorf("invalid mode '%s'. Available modes: %v", mode, availableModes)
 	}
+	sampling, err := b.samplingFor(mode)
+	if err != nil {
+		return err
+	}
 
 	b.stats.CurrentMode = mode
-	b.memory.SetSystemMessage(llm.GetSystemPrompt(mode))
+	b.sampling = sampling
+	b.memory.SetSystemMessage(b.conversationPrompt(mode))
 	return nil
 }
 
+// samplingFor resolves a mode's sampling options: the configured ones, then
+// the persona's global defaults the model supports, then the tenant's
+// override, which must be supported
+func (b *Bot) samplingFor(mode string) (llm.Sampling, error) {
+	capabilities := b.llmClient.Capabilities()
+	sampling := b.llmClient.Sampling().Merge(llm.PersonaSampling[mode].Supported(capabilities))
+
+	if spec, ok := b.overrides.ResolveSampling(b.tenant, mode); ok {
+		override, err := llm.ParseSampling(spec)
+		if err != nil {
+			return llm.Sampling{}, fmt.Errorf("invalid sampling for persona '%s': %w", mode, err)
+		}
+		sampling = sampling.Merge(override)
+	}
+	if err := sampling.Validate(capabilities); err != nil {
+		return llm.Sampling{}, fmt.Errorf("invalid sampling for persona '%s': %w", mode, err)
+	}
+	return sampling, nil
+}
+
+// PersonaSampling returns the sampling options a mode resolves to
+func (b *Bot) PersonaSampling(mode string) (llm.Sampling, error) {
+	return b.samplingFor(mode)
+}
+
+// SetSamplingOverride stores the tenant's sampling options for a persona,
+// after checking the model supports them. The active conversation picks
+// them up immediately if it uses that mode.
+func (b *Bot) SetSamplingOverride(mode, spec string) error {
+	override, err := llm.ParseSampling(spec)
+	if err != nil {
+		return err
+	}
+	if err := override.Validate(b.llmClient.Capabilities()); err != nil {
+		return err
+	}
+	if err := b.overrides.SetSampling(b.tenant, mode, override.String()); err != nil {
+		return err
+	}
+	return b.refreshSampling(mode)
+}
+
+// ResetSamplingOverride removes the tenant's sampling options for a persona
+func (b *Bot) ResetSamplingOverride(mode string) error {
+	if err := b.overrides.DeleteSampling(b.tenant, mode); err != nil {
+		return err
+	}
+	return b.refreshSampling(mode)
+}
+
+// refreshSampling re-resolves the active mode's sampling after mode changed
+func (b *Bot) refreshSampling(mode string) error {
+	if mode != b.stats.CurrentMode {
+		return nil
+	}
+	sampling, err := b.samplingFor(mode)
+	if err != nil {
+		return err
+	}
+	b.sampling = sampling
+	return nil
+}
+
+// Modes returns the conversation modes available to the bot's tenant
+func (b *Bot) Modes() []string {
+	return b.overrides.Personas(b.tenant, llm.SystemPrompts)
+}
+
+// systemPrompt resolves a mode's prompt, preferring the tenant's override
+func (b *Bot) systemPrompt(mode string) string {
+	if prompt, _, ok := b.overrides.ResolvePersona(b.tenant, mode, llm.SystemPrompts); ok {
+		return prompt
+	}
+	return llm.GetSystemPrompt(mode)
+}
+
+// PersonaSource reports whether a mode's prompt comes from a tenant override or the global default
+func (b *Bot) PersonaSource(mode string) tenants.Source {
+	_, source, _ := b.overrides.ResolvePersona(b.tenant, mode, llm.SystemPrompts)
+	return source
+}
+
+// SetPersonaOverride replaces a persona's system prompt for the bot's tenant.
+// The active conversation picks it up immediately if it uses that mode.
+func (b *Bot) SetPersonaOverride(mode, prompt string) error {
+	if err := b.overrides.SetPersona(b.tenant, mode, prompt); err != nil {
+		return err
+	}
+	if mode == b.stats.CurrentMode {
+		b.memory.SetSystemMessage(b.conversationPrompt(mode))
+	}
+	return nil
+}
+
+// ResetPersonaOverride removes the tenant's override so the global prompt applies again
+func (b *Bot) ResetPersonaOverride(mode string) error {
+	if err := b.overrides.DeletePersona(b.tenant, mode); err != nil {
+		return err
+	}
+	if mode == b.stats.CurrentMode {
+		if _, _, ok := b.overrides.ResolvePersona(b.tenant, mode, llm.SystemPrompts); !ok {
+			// A tenant-only persona was removed; fall back to the default mode
+			b.stats.CurrentMode = "assistant"
+		}
+		b.memory.SetSystemMessage(b.conversationPrompt(b.stats.CurrentMode))
+	}
+	b.announceMemory("load")
+	return nil
+}
+
+// Tenant returns the tenant the bot serves
+func (b *Bot) Tenant() string {
+	return b.tenant
+}
+
+// Overrides returns the per-tenant override store
+func (b *Bot) Overrides() *tenants.OverrideStore {
+	return b.overrides
+}
+
 // ClearMemory clears the conversation memory
 func (b *Bot) ClearMemory() {
 	b.memory.Clear()
-	b.memory.SetSystemMessage(llm.GetSystemPrompt(b.stats.CurrentMode))
+	b.sentiment = nil
+	b.memory.SetSystemMessage(b.conversationPrompt(b.stats.CurrentMode))
+	b.announceMemory("clear")
 }
 
 // SaveConversation saves the current conversation
 func (b *Bot) SaveConversation(name string) error {
 	conversation := b.memory.GetConversation()
-	return b.history.Save(name, conversation)
+	return b.history.save(name, conversation, b.stats.CurrentMode, b.stats.Verbosity)
 }
 
 // LoadConversation loads a saved conversation
@@ -165,14 +697,67 @@ func (b *Bot) LoadConversation(name string) error {
 	}
 
 	b.memory.LoadConversation(conversation.Messages)
+	b.rescoreSentiment(conversation.Messages)
+
+	// Restore the conversation's mode and verbosity; older saves, and modes
+	// the tenant no longer has, keep the current ones
+	if verbosity, err := ParseVerbosity(conversation.Verbosity); err == nil {
+		b.stats.Verbosity = verbosity
+		b.memory.SetSystemMessage(b.conversationPrompt(b.stats.CurrentMode))
+	}
+	if conversation.Mode != "" && conversation.Mode != b.stats.CurrentMode {
+		_ = b.SetMode(conversation.Mode)
+	}
 	return nil
 }
 
+// RecoveredMessages returns how many messages were restored from the memory log at startup
+func (b *Bot) RecoveredMessages() int {
+	return b.recovered
+}
+
+// MemoryLogError reports a failure of the memory write-ahead log, if any
+func (b *Bot) MemoryLogError() error {
+	return b.memory.WALError()
+}
+
 // ListConversations returns a list of saved conversations
 func (b *Bot) ListConversations() []string {
 	return b.history.List()
 }
 
+// History returns the store of saved conversations
+func (b *Bot) History() *History {
+	return b.history
+}
+
+// LastResponse returns the most recent bot response
+func (b *Bot) LastResponse() string {
+	return b.lastResponse
+}
+
+// CostLimiter returns the limiter enforcing the daily and monthly budgets,
+// or nil when none is configured
+func (b *Bot) CostLimiter() *costs.Limiter {
+	return b.llmClient.CostLimiter()
+}
+
+// SpendGuard returns the monthly spend guard, or nil when none is configured
+func (b *Bot) SpendGuard() *llm.SpendGuard {
+	return b.llmClient.GetSpendGuard()
+}
+
+// Provider returns the name of the provider serving the bot's model
+func (b *Bot) Provider() string {
+	return b.llmClient.Provider()
+}
+
+// APIKeys returns the pool of provider API keys the bot's client uses, or
+// nil for a provider without keys
+func (b *Bot) APIKeys() *llm.KeyPool {
+	return b.llmClient.Keys()
+}
+
 // GetStats returns current bot statistics
 func (b *Bot) GetStats() Stats {
 	return *b.stats
@@ -0,0 +1,57 @@
+package chatbot
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// compressionMaxTokens bounds the running summary of compressed messages
+const compressionMaxTokens = 300
+
+// compressionPrompt asks for the running summary to be brought up to date
+const compressionPrompt = `You maintain the running summary of a conversation between a user and an assistant. Older messages are removed from the assistant's view, so the summary is all it will remember of them.
+
+Update the summary with the new messages. Keep the user's goals, preferences and facts about them, decisions made, open questions and anything the assistant promised. Drop small talk. Write compact notes in the third person, at most 150 words. Respond with the updated summary only.`
+
+// compressMemory summarizes the oldest messages once the conversation is
+// over its token budget. A failed summary never fails the turn: the
+// messages are dropped as they would be without a budget.
+func (b *Bot) compressMemory(ctx context.Context) {
+	compressed, err := b.memory.Compress(ctx, b.summarizeMessages)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to summarize old messages, dropping them", "messages", compressed, "error", err)
+	}
+	if compressed > 0 {
+		b.announceMemory("compress")
+	}
+}
+
+// summarizeMessages is the bot's Summarizer: it asks the LLM to fold
+// messages into the running summary
+func (b *Bot) summarizeMessages(ctx context.Context, summary string, messages []openai.ChatCompletionMessage) (string, error) {
+	var prompt strings.Builder
+	if summary != "" {
+		prompt.WriteString("Current summary:\n" + summary + "\n\n")
+	}
+	prompt.WriteString("New messages:\n")
+	for _, msg := range messages {
+		prompt.WriteString(fmt.Sprintf("%s: %s\n", msg.Role, msg.Content))
+	}
+
+	response, err := b.llmClient.ChatCompletion(ctx, []openai.ChatCompletionMessage{
+		{Role: "system", Content: compressionPrompt},
+		{Role: "user", Content: prompt.String()},
+	}, compressionMaxTokens, 0.3)
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize messages: %w", err)
+	}
+	if len(response.Choices) == 0 {
+		return "", fmt.Errorf("no response choices returned")
+	}
+	b.stats.TokensUsed += response.Usage.TotalTokens
+	return strings.TrimSpace(response.Choices[0].Message.Content), nil
+}
@@ -0,0 +1,167 @@
+package chatbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"chatbot/config"
+	"chatbot/jobs"
+	"chatbot/tenants"
+)
+
+// errEncryptionDisabled is returned by key operations without a master key
+var errEncryptionDisabled = errors.New("encryption is not enabled (set TENANT_MASTER_KEY or TENANT_MASTER_KEY_FILE)")
+
+// OpenHistory opens the conversation history cfg describes. When a master
+// key is configured (TENANT_MASTER_KEY, or TENANT_MASTER_KEY_FILE), saved
+// conversations are encrypted with the tenant's data key and the key ring is
+// returned; otherwise it is nil.
+func OpenHistory(cfg *config.Config) (*History, *tenants.KeyRing, error) {
+	if err := tenants.ValidateID(cfg.TenantID); err != nil {
+		return nil, nil, err
+	}
+	history, err := NewHistory(cfg.SaveDirectory)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize history: %w", err)
+	}
+
+	var masterKey []byte
+	switch {
+	case cfg.TenantMasterKey != "" && cfg.TenantMasterKeyFile != "":
+		return nil, nil, fmt.Errorf("set TENANT_MASTER_KEY or TENANT_MASTER_KEY_FILE, not both")
+	case cfg.TenantMasterKey != "":
+		if masterKey, err = tenants.ParseMasterKey(cfg.TenantMasterKey); err != nil {
+			return nil, nil, fmt.Errorf("invalid TENANT_MASTER_KEY: %w", err)
+		}
+	case cfg.TenantMasterKeyFile != "":
+		if masterKey, err = tenants.ReadMasterKeyFile(cfg.TenantMasterKeyFile); err != nil {
+			return nil, nil, fmt.Errorf("invalid TENANT_MASTER_KEY_FILE: %w", err)
+		}
+	default:
+		return history, nil, nil
+	}
+
+	keyRing, err := tenants.NewKeyRing(filepath.Join(cfg.TenantDir, "keys"), masterKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize key ring: %w", err)
+	}
+	history.SetEncryption(keyRing, cfg.TenantID)
+	return history, keyRing, nil
+}
+
+// SealMemoryLog encrypts the memory log cfg names with the tenant's active
+// data key, including records logged before a master key was configured,
+// and returns how many records it holds. The bot does the same when it
+// starts; this is for migrating without starting it.
+func SealMemoryLog(cfg *config.Config, keyRing *tenants.KeyRing) (int, error) {
+	wal, err := OpenMemoryWAL(cfg.MemoryWALPath)
+	if err != nil {
+		return 0, err
+	}
+	defer wal.Close()
+	wal.SetEncryption(keyRing, cfg.TenantID)
+	return NewMemory(cfg.MaxHistory).AttachWAL(wal)
+}
+
+// EncryptionStatus describes the tenant's conversation encryption keys
+type EncryptionStatus struct {
+	Enabled       bool
+	Tenant        string
+	ActiveVersion int
+	Versions      []int
+	// Plaintext counts conversations still stored unencrypted
+	Plaintext int
+}
+
+// EncryptionStatus reports whether conversations are encrypted and which key versions exist
+func (b *Bot) EncryptionStatus() (EncryptionStatus, error) {
+	status := EncryptionStatus{Enabled: b.keyRing != nil, Tenant: b.tenant}
+	if !status.Enabled {
+		return status, nil
+	}
+
+	var err error
+	if status.ActiveVersion, err = b.keyRing.ActiveVersion(b.tenant); err != nil {
+		return status, err
+	}
+	if status.Versions, err = b.keyRing.Versions(b.tenant); err != nil {
+		return status, err
+	}
+	status.Plaintext = len(b.history.Plaintext())
+	return status, nil
+}
+
+// RotateEncryptionKey creates a new data key for the tenant and starts a
+// background job that re-encrypts saved conversations with it. Keys older
+// than it are destroyed once no conversation or memory log record uses them.
+func (b *Bot) RotateEncryptionKey() (string, int, error) {
+	if b.keyRing == nil {
+		return "", 0, errEncryptionDisabled
+	}
+
+	version, err := b.keyRing.Rotate(b.tenant)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to rotate key: %w", err)
+	}
+
+	name := fmt.Sprintf("re-encrypt %s with key v%d", b.tenant, version)
+	id := b.jobs.Submit(name, func(ctx context.Context, r *jobs.Reporter) error {
+		rewritten, err := b.history.Reencrypt(ctx, func(done, total int) {
+			r.Progress(float64(done) / float64(total))
+		})
+		if err != nil {
+			r.Logf("stopped after re-encrypting %d conversations; old keys kept", rewritten)
+			return err
+		}
+		r.Logf("re-encrypted %d conversations with key v%d", rewritten, version)
+
+		// Keys still sealing a conversation or the memory log are kept, e.g.
+		// memory logged before the rotation until the log is next compacted
+		inUse, err := b.history.KeyVersions()
+		if err != nil {
+			return fmt.Errorf("failed to check key use: %w", err)
+		}
+		if wal := b.memory.wal; wal != nil {
+			logged, err := wal.KeyVersions()
+			if err != nil {
+				return fmt.Errorf("failed to check key use: %w", err)
+			}
+			for v := range logged {
+				inUse[v] = true
+			}
+		}
+		retired, err := b.keyRing.Retire(b.tenant, version, inUse)
+		if err != nil {
+			return fmt.Errorf("failed to retire old keys: %w", err)
+		}
+		r.Logf("retired %d old key(s)", retired)
+		return nil
+	})
+
+	return id, version, nil
+}
+
+// EncryptConversations starts a background job that encrypts conversations
+// saved before encryption was enabled, and re-seals any still using an old
+// key, with the tenant's active data key
+func (b *Bot) EncryptConversations() (string, int, error) {
+	if b.keyRing == nil {
+		return "", 0, errEncryptionDisabled
+	}
+
+	plaintext := len(b.history.Plaintext())
+	id := b.jobs.Submit("encrypt "+b.tenant+" conversations", func(ctx context.Context, r *jobs.Reporter) error {
+		rewritten, err := b.history.Reencrypt(ctx, func(done, total int) {
+			r.Progress(float64(done) / float64(total))
+		})
+		if err != nil {
+			r.Logf("stopped after encrypting %d conversations", rewritten)
+			return err
+		}
+		r.Logf("encrypted %d conversations", rewritten)
+		return nil
+	})
+	return id, plaintext, nil
+}
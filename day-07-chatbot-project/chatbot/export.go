@@ -0,0 +1,331 @@
+package chatbot
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ExportFormat is a format conversations can be exported to and imported from
+type ExportFormat string
+
+const (
+	// FormatMarkdown is a transcript for people to read, with a heading per turn
+	FormatMarkdown ExportFormat = "markdown"
+	// FormatFineTune is OpenAI's chat fine-tuning JSONL: one
+	// {"messages": [...]} line per conversation
+	FormatFineTune ExportFormat = "finetune"
+	// FormatShareGPT is a JSON array of {"id", "conversations": [{"from", "value"}]}
+	FormatShareGPT ExportFormat = "sharegpt"
+)
+
+// ParseExportFormat parses a format name: markdown (md), finetune (jsonl,
+// openai) or sharegpt
+func ParseExportFormat(name string) (ExportFormat, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "markdown", "md":
+		return FormatMarkdown, nil
+	case "finetune", "jsonl", "openai":
+		return FormatFineTune, nil
+	case "sharegpt":
+		return FormatShareGPT, nil
+	default:
+		return "", fmt.Errorf("unknown format '%s': expected markdown, finetune or sharegpt", name)
+	}
+}
+
+// FormatForPath picks the format a file's extension suggests: .md is
+// Markdown, .jsonl fine-tuning data and .json ShareGPT
+func FormatForPath(path string) (ExportFormat, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return FormatMarkdown, nil
+	case ".jsonl":
+		return FormatFineTune, nil
+	case ".json":
+		return FormatShareGPT, nil
+	default:
+		return "", fmt.Errorf("can't tell the format of '%s' from its extension; name one", path)
+	}
+}
+
+// Export writes a saved conversation to w in format
+func (h *History) Export(name string, format ExportFormat, w io.Writer) error {
+	conversation, err := h.Load(name)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case FormatMarkdown:
+		return writeMarkdown(w, conversation)
+	case FormatFineTune:
+		return json.NewEncoder(w).Encode(fineTuneExample{Messages: toChatMessages(conversation.Messages)})
+	case FormatShareGPT:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode([]shareGPTConversation{toShareGPT(conversation)})
+	default:
+		return fmt.Errorf("unknown format '%s'", format)
+	}
+}
+
+// Import saves the conversations read from r in format and returns their
+// names. Names come from the Markdown title or ShareGPT id, or are
+// generated; a name already taken gets a numeric suffix, so nothing is
+// overwritten. System messages are dropped: a conversation's prompt comes
+// from its mode.
+func (h *History) Import(r io.Reader, format ExportFormat) ([]string, error) {
+	var conversations []SavedConversation
+	var err error
+	switch format {
+	case FormatMarkdown:
+		var conversation SavedConversation
+		conversation, err = readMarkdown(r)
+		conversations = append(conversations, conversation)
+	case FormatFineTune:
+		conversations, err = readFineTune(r)
+	case FormatShareGPT:
+		conversations, err = readShareGPT(r)
+	default:
+		err = fmt.Errorf("unknown format '%s'", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var names []string
+	for i, conversation := range conversations {
+		conversation.Messages = withoutSystem(conversation.Messages)
+		if len(conversation.Messages) == 0 {
+			return names, fmt.Errorf("conversation %d has no user or assistant messages", i+1)
+		}
+		for j := range conversation.Messages {
+			conversation.Messages[j].Timestamp = now
+		}
+		if conversation.Name == "" {
+			conversation.Name = fmt.Sprintf("imported-%s-%d", now.Format("20060102-150405"), i+1)
+		}
+		conversation.Name = h.unusedName(conversation.Name)
+		conversation.CreatedAt, conversation.UpdatedAt = now, now
+
+		if err := h.saveRecord(conversation); err != nil {
+			return names, err
+		}
+		names = append(names, conversation.Name)
+	}
+	return names, nil
+}
+
+// unusedName returns name, or name-2, name-3... when it is taken
+func (h *History) unusedName(name string) string {
+	candidate := name
+	for i := 2; h.Exists(candidate); i++ {
+		candidate = fmt.Sprintf("%s-%d", name, i)
+	}
+	return candidate
+}
+
+// withoutSystem drops system messages
+func withoutSystem(messages []ConversationMessage) []ConversationMessage {
+	var kept []ConversationMessage
+	for _, message := range messages {
+		if message.Role != "system" {
+			kept = append(kept, message)
+		}
+	}
+	return kept
+}
+
+// checkRole rejects roles a saved conversation can't hold
+func checkRole(role string) error {
+	switch role {
+	case "system", "user", "assistant":
+		return nil
+	default:
+		return fmt.Errorf("unsupported role '%s'", role)
+	}
+}
+
+// Markdown transcripts look like:
+//
+//	# refunds
+//
+//	> Mode: support · Saved: 2026-03-30 12:00
+//
+//	## User
+//
+//	Can I get a refund?
+//
+//	## Assistant
+//
+//	Yes, within 30 days.
+var (
+	markdownRoles = map[string]string{"system": "System", "user": "User", "assistant": "Assistant"}
+	markdownTurn  = regexp.MustCompile(`^## (System|User|Assistant)\s*$`)
+	markdownMode  = regexp.MustCompile(`^> Mode: (\S+)`)
+)
+
+func writeMarkdown(w io.Writer, conversation *SavedConversation) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# %s\n\n", conversation.Name)
+	meta := []string{}
+	if conversation.Mode != "" {
+		meta = append(meta, "Mode: "+conversation.Mode)
+	}
+	meta = append(meta, "Saved: "+conversation.UpdatedAt.Format("2006-01-02 15:04"))
+	fmt.Fprintf(bw, "> %s\n\n", strings.Join(meta, " · "))
+	if conversation.Summary != "" {
+		fmt.Fprintf(bw, "%s\n\n", conversation.Summary)
+	}
+	for _, message := range conversation.Messages {
+		fmt.Fprintf(bw, "## %s\n\n%s\n\n", markdownRoles[message.Role], strings.TrimSpace(message.Content))
+	}
+	return bw.Flush()
+}
+
+func readMarkdown(r io.Reader) (SavedConversation, error) {
+	var conversation SavedConversation
+	var current *ConversationMessage
+	var body []string
+	flush := func() {
+		if current != nil {
+			current.Content = strings.TrimSpace(strings.Join(body, "\n"))
+			conversation.Messages = append(conversation.Messages, *current)
+		}
+		body = nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch match := markdownTurn.FindStringSubmatch(line); {
+		case match != nil:
+			flush()
+			current = &ConversationMessage{Role: strings.ToLower(match[1])}
+		case current != nil:
+			body = append(body, line)
+		case strings.HasPrefix(line, "# ") && conversation.Name == "":
+			conversation.Name = strings.TrimSpace(strings.TrimPrefix(line, "# "))
+		case markdownMode.MatchString(line):
+			conversation.Mode = markdownMode.FindStringSubmatch(line)[1]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return SavedConversation{}, fmt.Errorf("failed to read transcript: %w", err)
+	}
+	flush()
+	return conversation, nil
+}
+
+// chatMessage is a message in OpenAI's chat format
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// fineTuneExample is one line of a fine-tuning file
+type fineTuneExample struct {
+	Messages []chatMessage `json:"messages"`
+}
+
+func toChatMessages(messages []ConversationMessage) []chatMessage {
+	converted := make([]chatMessage, 0, len(messages))
+	for _, message := range messages {
+		converted = append(converted, chatMessage{Role: message.Role, Content: message.Content})
+	}
+	return converted
+}
+
+func readFineTune(r io.Reader) ([]SavedConversation, error) {
+	var conversations []SavedConversation
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var example fineTuneExample
+		if err := json.Unmarshal([]byte(text), &example); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		var conversation SavedConversation
+		for _, message := range example.Messages {
+			if err := checkRole(message.Role); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			conversation.Messages = append(conversation.Messages, ConversationMessage{Role: message.Role, Content: message.Content})
+		}
+		conversations = append(conversations, conversation)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read fine-tuning data: %w", err)
+	}
+	return conversations, nil
+}
+
+// shareGPTConversation is one conversation of a ShareGPT file
+type shareGPTConversation struct {
+	ID            string            `json:"id"`
+	Conversations []shareGPTMessage `json:"conversations"`
+}
+
+type shareGPTMessage struct {
+	From  string `json:"from"`
+	Value string `json:"value"`
+}
+
+// shareGPTRoles maps ShareGPT speakers to roles
+var shareGPTRoles = map[string]string{"system": "system", "human": "user", "gpt": "assistant"}
+
+func toShareGPT(conversation *SavedConversation) shareGPTConversation {
+	converted := shareGPTConversation{ID: conversation.Name}
+	for _, message := range conversation.Messages {
+		from := message.Role
+		for speaker, role := range shareGPTRoles {
+			if role == message.Role {
+				from = speaker
+			}
+		}
+		converted.Conversations = append(converted.Conversations, shareGPTMessage{From: from, Value: message.Content})
+	}
+	return converted
+}
+
+func readShareGPT(r io.Reader) ([]SavedConversation, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ShareGPT data: %w", err)
+	}
+	var shared []shareGPTConversation
+	if err := json.Unmarshal(data, &shared); err != nil {
+		// A single conversation isn't always wrapped in an array
+		var single shareGPTConversation
+		if json.Unmarshal(data, &single) != nil {
+			return nil, fmt.Errorf("failed to parse ShareGPT data: %w", err)
+		}
+		shared = append(shared, single)
+	}
+
+	conversations := make([]SavedConversation, 0, len(shared))
+	for i, conversation := range shared {
+		imported := SavedConversation{Name: conversation.ID}
+		for _, message := range conversation.Conversations {
+			role, ok := shareGPTRoles[message.From]
+			if !ok {
+				return nil, fmt.Errorf("conversation %d: unsupported speaker '%s'", i+1, message.From)
+			}
+			imported.Messages = append(imported.Messages, ConversationMessage{Role: role, Content: message.Value})
+		}
+		conversations = append(conversations, imported)
+	}
+	return conversations, nil
+}
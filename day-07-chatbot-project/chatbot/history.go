@@ -1,15 +1,24 @@
 package chatbot
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/sakibmulla/agentic-ai/persist"
+
+	"chatbot/tenants"
+	"chatbot/utils"
 )
 
+// conversationFormat versions saved conversations. Files saved before
+// versioning are read as version 1.
+var conversationFormat = persist.NewFormat("conversation", 1)
+
 // ConversationMessage represents a single message in a conversation
 type ConversationMessage struct {
 	Role      string    `json:"role"`
@@ -23,11 +32,23 @@ type SavedConversation struct {
 	Messages  []ConversationMessage `json:"messages"`
 	CreatedAt time.Time             `json:"created_at"`
 	UpdatedAt time.Time             `json:"updated_at"`
+
+	// Mode and Verbosity are the persona and response length in effect when saved
+	Mode      string `json:"mode,omitempty"`
+	Verbosity string `json:"verbosity,omitempty"`
+
+	// Summary and MergedFrom are set on conversations created by a merge
+	Summary    string   `json:"summary,omitempty"`
+	MergedFrom []string `json:"merged_from,omitempty"`
 }
 
 // History manages conversation persistence
 type History struct {
 	saveDirectory string
+
+	// keyRing, when set, encrypts saved conversations with the tenant's data key
+	keyRing *tenants.KeyRing
+	tenant  string
 }
 
 // NewHistory creates a new history manager
@@ -42,8 +63,20 @@ func NewHistory(saveDirectory string) (*History, error) {
 	}, nil
 }
 
+// SetEncryption enables envelope encryption of saved conversations.
+// Existing plaintext files stay readable and are encrypted when re-saved.
+func (h *History) SetEncryption(keyRing *tenants.KeyRing, tenant string) {
+	h.keyRing = keyRing
+	h.tenant = tenant
+}
+
 // Save saves a conversation with the given name
 func (h *History) Save(name string, messages []ConversationMessage) error {
+	return h.save(name, messages, "", "")
+}
+
+// save stores a conversation together with its mode and verbosity setting
+func (h *History) save(name string, messages []ConversationMessage, mode string, verbosity Verbosity) error {
 	// Add timestamps to messages if they don't have them
 	for i := range messages {
 		if messages[i].Timestamp.IsZero() {
@@ -56,6 +89,8 @@ func (h *History) Save(name string, messages []ConversationMessage) error {
 		Messages:  messages,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
+		Mode:      mode,
+		Verbosity: string(verbosity),
 	}
 
 	// Check if conversation exists and preserve creation time
@@ -64,13 +99,30 @@ func (h *History) Save(name string, messages []ConversationMessage) error {
 		conversation.CreatedAt = existing.CreatedAt
 	}
 
-	filename := h.getFilename(name)
-	data, err := json.MarshalIndent(conversation, "", "  ")
+	return h.saveRecord(conversation)
+}
+
+// saveRecord writes a complete conversation record
+func (h *History) saveRecord(conversation SavedConversation) error {
+	data, err := conversationFormat.MarshalIndent(conversation)
 	if err != nil {
 		return fmt.Errorf("failed to marshal conversation: %w", err)
 	}
 
-	if err := ioutil.WriteFile(filename, data, 0644); err != nil {
+	return h.write(h.getFilename(conversation.Name), data)
+}
+
+// write stores conversation JSON, encrypting it when a key ring is set
+func (h *History) write(filename string, data []byte) error {
+	if h.keyRing != nil {
+		sealed, err := h.keyRing.Encrypt(h.tenant, data)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt conversation: %w", err)
+		}
+		data = sealed
+	}
+
+	if err := utils.WriteFileAtomic(filename, data, 0644); err != nil {
 		return fmt.Errorf("failed to write conversation file: %w", err)
 	}
 
@@ -86,11 +138,32 @@ func (h *History) Load(name string) (*SavedConversation, error) {
 		return nil, fmt.Errorf("failed to read conversation file: %w", err)
 	}
 
+	if tenants.IsEnvelope(data) {
+		if h.keyRing == nil {
+			return nil, fmt.Errorf("conversation is encrypted but no master key is configured")
+		}
+		if data, err = h.keyRing.Decrypt(h.tenant, data); err != nil {
+			return nil, fmt.Errorf("failed to decrypt conversation: %w", err)
+		}
+	}
+
 	var conversation SavedConversation
-	if err := json.Unmarshal(data, &conversation); err != nil {
+	upgraded, err := conversationFormat.Unmarshal(data, &conversation)
+	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal conversation: %w", err)
 	}
 
+	// A conversation saved by an earlier release is rewritten in the current format
+	if upgraded {
+		current, err := conversationFormat.MarshalIndent(conversation)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal conversation: %w", err)
+		}
+		if err := h.write(filename, current); err != nil {
+			return nil, fmt.Errorf("failed to upgrade conversation: %w", err)
+		}
+	}
+
 	return &conversation, nil
 }
 
@@ -112,6 +185,81 @@ func (h *History) List() []string {
 	return conversations
 }
 
+// Reencrypt rewrites every conversation not sealed with the tenant's active
+// data key (including plaintext ones). progress is called after each file.
+func (h *History) Reencrypt(ctx context.Context, progress func(done, total int)) (int, error) {
+	if h.keyRing == nil {
+		return 0, fmt.Errorf("encryption is not enabled")
+	}
+
+	active, err := h.keyRing.ActiveVersion(h.tenant)
+	if err != nil {
+		return 0, err
+	}
+
+	names := h.List()
+	rewritten := 0
+	for i, name := range names {
+		if ctx.Err() != nil {
+			return rewritten, ctx.Err()
+		}
+
+		filename := h.getFilename(name)
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return rewritten, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+
+		if version, err := tenants.KeyVersion(data); err != nil || version != active {
+			conversation, err := h.Load(name)
+			if err != nil {
+				return rewritten, fmt.Errorf("failed to load %s: %w", name, err)
+			}
+			plain, err := conversationFormat.MarshalIndent(conversation)
+			if err != nil {
+				return rewritten, fmt.Errorf("failed to marshal %s: %w", name, err)
+			}
+			if err := h.write(filename, plain); err != nil {
+				return rewritten, err
+			}
+			rewritten++
+		}
+
+		if progress != nil {
+			progress(i+1, len(names))
+		}
+	}
+
+	return rewritten, nil
+}
+
+// KeyVersions returns the data key versions saved conversations are sealed with
+func (h *History) KeyVersions() (map[int]bool, error) {
+	versions := make(map[int]bool)
+	for _, name := range h.List() {
+		data, err := ioutil.ReadFile(h.getFilename(name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		if version, err := tenants.KeyVersion(data); err == nil {
+			versions[version] = true
+		}
+	}
+	return versions, nil
+}
+
+// Plaintext returns the conversations stored unencrypted
+func (h *History) Plaintext() []string {
+	var names []string
+	for _, name := range h.List() {
+		data, err := ioutil.ReadFile(h.getFilename(name))
+		if err == nil && !tenants.IsEnvelope(data) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
 // Delete removes a saved conversation
 func (h *History) Delete(name string) error {
 	filename := h.getFilename(name)
@@ -1,15 +1,42 @@
 package chatbot
 
 import (
+	"context"
+	"strings"
 	"time"
 
+	"github.com/sakibmulla/agentic-ai/tokenizer"
 	"github.com/sashabaranov/go-openai"
 )
 
+// summaryNoteName names the system note, right after the system message,
+// that summarizes the messages compressed so far
+const summaryNoteName = "conversation_summary"
+
+// summaryNotePrefix introduces the summary in its note
+const summaryNotePrefix = "Summary of the earlier conversation, which is no longer shown:\n"
+
+// minKeptMessages is how many of the latest messages Compress always keeps,
+// so the model sees the last exchange word for word
+const minKeptMessages = 2
+
+// Summarizer folds messages into summary, the summary of the conversation
+// before them, which is empty the first time. It returns the new summary.
+type Summarizer func(ctx context.Context, summary string, messages []openai.ChatCompletionMessage) (string, error)
+
 // Memory manages conversation history and context
 type Memory struct {
 	messages   []openai.ChatCompletionMessage
 	maxHistory int
+
+	// tokenBudget, when positive, bounds the conversation's tokens instead
+	// of maxHistory bounding its messages; see Compress
+	tokenBudget int
+	tokens      tokenizer.TokenCounter
+
+	// wal, when set, logs every change before it is applied
+	wal    *MemoryWAL
+	walErr error
 }
 
 // NewMemory creates a new memory instance
@@ -20,8 +47,40 @@ func NewMemory(maxHistory int) *Memory {
 	}
 }
 
+// SetTokenBudget bounds the conversation by tokens, counted with counter,
+// rather than by messages. Old messages are no longer dropped as new ones
+// arrive; Compress summarizes them once the budget is exceeded. A budget of
+// zero restores the message limit.
+func (m *Memory) SetTokenBudget(budget int, counter tokenizer.TokenCounter) {
+	m.tokenBudget = budget
+	m.tokens = counter
+}
+
+// AttachWAL replays the write-ahead log into memory and logs all later
+// changes to it. It returns the number of records recovered.
+func (m *Memory) AttachWAL(wal *MemoryWAL) (int, error) {
+	recovered, err := wal.Replay(m)
+	if err != nil {
+		return recovered, err
+	}
+	m.wal = wal
+	return recovered, nil
+}
+
+// WALError returns the last write-ahead log failure, if any. Once the log
+// fails, memory keeps working but is no longer crash-safe.
+func (m *Memory) WALError() error {
+	return m.walErr
+}
+
 // AddMessage adds a message to memory
 func (m *Memory) AddMessage(role, content string) {
+	m.change(walRecord{Op: walAdd, Role: role, Content: content})
+}
+
+// appendMessage adds a message, trimming the oldest beyond maxHistory
+// unless a token budget is set
+func (m *Memory) appendMessage(role, content string) {
 	message := openai.ChatCompletionMessage{
 		Role:    role,
 		Content: content,
@@ -29,24 +88,28 @@ func (m *Memory) AddMessage(role, content string) {
 
 	m.messages = append(m.messages, message)
 
-	// Keep only the most recent messages (plus system message)
-	if len(m.messages) > m.maxHistory+1 { // +1 for system message
-		// Keep system message (first) and trim user/assistant messages
-		systemMsg := m.messages[0]
+	// Keep only the most recent messages (plus system message and summary)
+	start := m.conversationStart()
+	if m.tokenBudget <= 0 && len(m.messages) > m.maxHistory+start {
 		recentMessages := m.messages[len(m.messages)-m.maxHistory:]
-		m.messages = append([]openai.ChatCompletionMessage{systemMsg}, recentMessages...)
+		m.messages = append(append([]openai.ChatCompletionMessage{}, m.messages[:start]...), recentMessages...)
 	}
 }
 
 // SetSystemMessage sets or updates the system message
 func (m *Memory) SetSystemMessage(content string) {
+	m.change(walRecord{Op: walSystem, Content: content})
+}
+
+// setSystemMessage replaces or inserts the system message
+func (m *Memory) setSystemMessage(content string) {
 	systemMsg := openai.ChatCompletionMessage{
 		Role:    "system",
 		Content: content,
 	}
 
 	// If we already have messages and the first is a system message, replace it
-	if len(m.messages) > 0 && m.messages[0].Role == "system" {
+	if len(m.messages) > 0 && m.messages[0].Role == "system" && m.messages[0].Name != summaryNoteName {
 		m.messages[0] = systemMsg
 	} else {
 		// Insert system message at the beginning
@@ -61,7 +124,7 @@ func (m *Memory) GetMessages() []openai.ChatCompletionMessage {
 
 // Clear clears all messages from memory
 func (m *Memory) Clear() {
-	m.messages = make([]openai.ChatCompletionMessage, 0)
+	m.change(walRecord{Op: walClear})
 }
 
 // GetConversation returns the conversation without system message for saving
@@ -83,31 +146,149 @@ func (m *Memory) GetConversation() []ConversationMessage {
 
 // LoadConversation loads a conversation into memory
 func (m *Memory) LoadConversation(conversation []ConversationMessage) {
+	loaded := &Memory{maxHistory: m.maxHistory, tokenBudget: m.tokenBudget}
+
 	// Keep system message if it exists
-	var systemMsg *openai.ChatCompletionMessage
 	if len(m.messages) > 0 && m.messages[0].Role == "system" {
-		systemMsg = &m.messages[0]
-	}
-
-	// Clear and reload
-	m.messages = make([]openai.ChatCompletionMessage, 0)
-
-	// Add system message back
-	if systemMsg != nil {
-		m.messages = append(m.messages, *systemMsg)
+		loaded.messages = append(loaded.messages, m.messages[0])
 	}
 
 	// Add conversation messages
 	for _, msg := range conversation {
-		m.AddMessage(msg.Role, msg.Content)
+		loaded.appendMessage(msg.Role, msg.Content)
 	}
+
+	// Logged as one snapshot so a crash never leaves a half-loaded conversation
+	m.change(walRecord{Op: walSnapshot, Messages: loaded.messages})
 }
 
-// GetMessageCount returns the number of messages (excluding system)
+// GetMessageCount returns the number of messages (excluding system and summary)
 func (m *Memory) GetMessageCount() int {
-	count := len(m.messages)
-	if count > 0 && m.messages[0].Role == "system" {
-		count--
+	return len(m.messages) - m.conversationStart()
+}
+
+// Summary returns the summary of the compressed messages, or "" if none
+// have been
+func (m *Memory) Summary() string {
+	if i := m.summaryIndex(); i >= 0 {
+		return strings.TrimPrefix(m.messages[i].Content, summaryNotePrefix)
+	}
+	return ""
+}
+
+// Compress summarizes the oldest messages once the conversation is over its
+// token budget, replacing them with a system note after the system message.
+// Each compression folds more messages into the note, so it always covers
+// everything no longer shown. Messages are compressed, oldest first, until
+// the rest fit in half the budget, leaving room for the conversation to
+// grow before the next compression. It returns the number of messages
+// compressed.
+//
+// If summarize fails the messages are dropped anyway, keeping the earlier
+// summary, so a failing LLM can't let memory grow without bound; the error
+// is returned.
+func (m *Memory) Compress(ctx context.Context, summarize Summarizer) (int, error) {
+	start := m.conversationStart()
+	if m.tokenBudget <= 0 || m.countTokens(m.messages[start:]) <= m.tokenBudget {
+		return 0, nil
+	}
+
+	// Keep the latest messages that fit in half the budget
+	conversation := m.messages[start:]
+	cut, kept := len(conversation), 0
+	for cut > 0 {
+		tokens := m.countTokens(conversation[cut-1 : cut])
+		if len(conversation)-cut >= minKeptMessages && kept+tokens > m.tokenBudget/2 {
+			break
+		}
+		kept += tokens
+		cut--
+	}
+	if cut == 0 {
+		return 0, nil
+	}
+
+	summary, err := summarize(ctx, m.Summary(), conversation[:cut])
+	if err != nil {
+		summary = m.Summary()
+	}
+
+	messages := make([]openai.ChatCompletionMessage, 0, len(conversation)-cut+2)
+	if len(m.messages) > 0 && m.messages[0].Role == "system" && m.messages[0].Name != summaryNoteName {
+		messages = append(messages, m.messages[0])
+	}
+	if summary != "" {
+		messages = append(messages, openai.ChatCompletionMessage{
+			Role:    "system",
+			Name:    summaryNoteName,
+			Content: summaryNotePrefix + summary,
+		})
+	}
+	messages = append(messages, conversation[cut:]...)
+
+	// Logged as one snapshot, like a loaded conversation
+	m.change(walRecord{Op: walSnapshot, Messages: messages})
+	return cut, err
+}
+
+// summaryIndex returns the index of the summary note, or -1 if there is none
+func (m *Memory) summaryIndex() int {
+	for i := 0; i < len(m.messages) && i < 2; i++ {
+		if m.messages[i].Role == "system" && m.messages[i].Name == summaryNoteName {
+			return i
+		}
+	}
+	return -1
+}
+
+// conversationStart returns the index of the first message after the
+// system message and summary note
+func (m *Memory) conversationStart() int {
+	if i := m.summaryIndex(); i >= 0 {
+		return i + 1
+	}
+	if len(m.messages) > 0 && m.messages[0].Role == "system" {
+		return 1
+	}
+	return 0
+}
+
+// countTokens counts the tokens messages take up in a prompt
+func (m *Memory) countTokens(messages []openai.ChatCompletionMessage) int {
+	total := 0
+	for _, msg := range messages {
+		total += m.tokens.Count(msg.Content) + tokenizer.MessageOverhead
+	}
+	return total
+}
+
+// change logs a record to the write-ahead log, if any, then applies it
+func (m *Memory) change(record walRecord) {
+	if m.wal != nil {
+		if err := m.wal.append(record); err != nil {
+			m.walErr = err
+		}
+	}
+
+	m.apply(record)
+
+	if m.wal != nil && m.wal.records >= walCompactEvery {
+		if err := m.wal.compact(m.messages); err != nil {
+			m.walErr = err
+		}
+	}
+}
+
+// apply performs a logged change on the in-memory messages
+func (m *Memory) apply(record walRecord) {
+	switch record.Op {
+	case walAdd:
+		m.appendMessage(record.Role, record.Content)
+	case walSystem:
+		m.setSystemMessage(record.Content)
+	case walClear:
+		m.messages = make([]openai.ChatCompletionMessage, 0)
+	case walSnapshot:
+		m.messages = append(make([]openai.ChatCompletionMessage, 0, len(record.Messages)), record.Messages...)
 	}
-	return count
 }
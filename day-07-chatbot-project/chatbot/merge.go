@@ -0,0 +1,195 @@
+package chatbot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// summaryMaxTokens bounds the regenerated summary of a merged conversation
+const summaryMaxTokens = 200
+
+// MergeResult describes a merge performed by Bot.MergeConversations
+type MergeResult struct {
+	Conversation      *SavedConversation
+	DuplicatesRemoved int
+	// SummaryFallback is true when the LLM summary failed and a local one was used
+	SummaryFallback bool
+}
+
+// mergeTurn is a user message together with the replies that followed it
+type mergeTurn struct {
+	messages []ConversationMessage
+	start    time.Time
+	order    int
+}
+
+// MergeMessages combines conversations chronologically and drops duplicate
+// turns (the same user message with the same replies), keeping the earliest.
+// It returns the merged messages and the number of turns removed.
+func MergeMessages(conversations []*SavedConversation) ([]ConversationMessage, int) {
+	// Older conversations first, so overlapping copies are kept from the original
+	sorted := make([]*SavedConversation, len(conversations))
+	copy(sorted, conversations)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt.Before(sorted[j].CreatedAt)
+	})
+
+	var turns []mergeTurn
+	for _, conversation := range sorted {
+		for _, turn := range splitTurns(conversation.Messages) {
+			turn.order = len(turns)
+			turns = append(turns, turn)
+		}
+	}
+
+	seen := make(map[string]bool)
+	unique := turns[:0]
+	for _, turn := range turns {
+		key := turnKey(turn.messages)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, turn)
+	}
+	removed := len(turns) - len(unique)
+
+	// Saved timestamps may be identical within a file, so ties keep source order
+	sort.SliceStable(unique, func(i, j int) bool {
+		if !unique[i].start.Equal(unique[j].start) {
+			return unique[i].start.Before(unique[j].start)
+		}
+		return unique[i].order < unique[j].order
+	})
+
+	var merged []ConversationMessage
+	for _, turn := range unique {
+		merged = append(merged, turn.messages...)
+	}
+	return merged, removed
+}
+
+// splitTurns groups messages into turns that each start at a user message
+func splitTurns(messages []ConversationMessage) []mergeTurn {
+	var turns []mergeTurn
+	for _, msg := range messages {
+		if msg.Role == "user" || len(turns) == 0 {
+			turns = append(turns, mergeTurn{start: msg.Timestamp})
+		}
+		last := &turns[len(turns)-1]
+		last.messages = append(last.messages, msg)
+	}
+	return turns
+}
+
+// turnKey identifies a turn by its roles and whitespace-normalized content
+func turnKey(messages []ConversationMessage) string {
+	var builder strings.Builder
+	for _, msg := range messages {
+		builder.WriteString(msg.Role)
+		builder.WriteString(":")
+		builder.WriteString(strings.Join(strings.Fields(strings.ToLower(msg.Content)), " "))
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+// MergeConversations combines saved conversations into a new one called name,
+// removing duplicate turns and regenerating the summary. The originals are
+// left untouched and linked from the merged record.
+func (b *Bot) MergeConversations(ctx context.Context, name string, sources []string) (*MergeResult, error) {
+	if len(sources) < 2 {
+		return nil, fmt.Errorf("need at least two conversations to merge")
+	}
+	if b.history.Exists(name) {
+		return nil, fmt.Errorf("conversation '%s' already exists", name)
+	}
+
+	conversations := make([]*SavedConversation, 0, len(sources))
+	for _, source := range sources {
+		conversation, err := b.history.Load(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load '%s': %w", source, err)
+		}
+		conversations = append(conversations, conversation)
+	}
+
+	messages, removed := MergeMessages(conversations)
+
+	summary, err := b.summarizeConversation(ctx, messages)
+	fallback := err != nil
+	if fallback {
+		summary = localSummary(messages, sources)
+	}
+
+	now := time.Now()
+	merged := SavedConversation{
+		Name:       name,
+		Messages:   messages,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		Summary:    summary,
+		MergedFrom: sources,
+	}
+	if err := b.history.saveRecord(merged); err != nil {
+		return nil, err
+	}
+
+	return &MergeResult{
+		Conversation:      &merged,
+		DuplicatesRemoved: removed,
+		SummaryFallback:   fallback,
+	}, nil
+}
+
+// summarizeConversation asks the LLM for a short summary of a conversation
+func (b *Bot) summarizeConversation(ctx context.Context, conversation []ConversationMessage) (string, error) {
+	var transcript strings.Builder
+	for _, msg := range conversation {
+		transcript.WriteString(fmt.Sprintf("%s: %s\n", msg.Role, msg.Content))
+	}
+
+	messages := []openai.ChatCompletionMessage{
+		{Role: "system", Content: "Summarize this conversation in 2-3 sentences: the topics covered, decisions made and open questions."},
+		{Role: "user", Content: transcript.String()},
+	}
+
+	response, err := b.llmClient.ChatCompletion(ctx, messages, summaryMaxTokens, 0.3)
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize conversation: %w", err)
+	}
+	if len(response.Choices) == 0 {
+		return "", fmt.Errorf("no response choices returned")
+	}
+	return strings.TrimSpace(response.Choices[0].Message.Content), nil
+}
+
+// localSummary describes a conversation without the LLM, listing its first questions
+func localSummary(messages []ConversationMessage, sources []string) string {
+	var questions []string
+	for _, msg := range messages {
+		if msg.Role == "user" && len(questions) < 3 {
+			questions = append(questions, clipText(msg.Content, 60))
+		}
+	}
+
+	summary := fmt.Sprintf("Merged from %s (%d messages).", strings.Join(sources, ", "), len(messages))
+	if len(questions) > 0 {
+		summary += " Starts with: " + strings.Join(questions, "; ")
+	}
+	return summary
+}
+
+// clipText shortens s to at most n runes
+func clipText(s string, n int) string {
+	runes := []rune(strings.TrimSpace(s))
+	if len(runes) <= n {
+		return string(runes)
+	}
+	return string(runes[:n]) + "…"
+}
@@ -0,0 +1,139 @@
+package chatbot
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CodeBlock represents a fenced code block found in a response
+type CodeBlock struct {
+	Language string
+	Content  string
+}
+
+// languageExtensions maps common fence languages to file extensions
+var languageExtensions = map[string]string{
+	"go":         ".go",
+	"golang":     ".go",
+	"python":     ".py",
+	"py":         ".py",
+	"javascript": ".js",
+	"js":         ".js",
+	"typescript": ".ts",
+	"ts":         ".ts",
+	"bash":       ".sh",
+	"sh":         ".sh",
+	"shell":      ".sh",
+	"json":       ".json",
+	"yaml":       ".yaml",
+	"yml":        ".yaml",
+	"sql":        ".sql",
+	"html":       ".html",
+	"css":        ".css",
+	"markdown":   ".md",
+	"md":         ".md",
+	"rust":       ".rs",
+	"java":       ".java",
+	"c":          ".c",
+	"cpp":        ".cpp",
+}
+
+// ExtractCodeBlocks returns all fenced code blocks (```lang ... ```) in text
+func ExtractCodeBlocks(text string) []CodeBlock {
+	var blocks []CodeBlock
+	var current *CodeBlock
+	var body []string
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "```") {
+			if current != nil {
+				body = append(body, line)
+			}
+			continue
+		}
+
+		if current == nil {
+			// Opening fence
+			current = &CodeBlock{Language: strings.ToLower(strings.TrimSpace(strings.TrimPrefix(trimmed, "```")))}
+			body = nil
+			continue
+		}
+
+		// Closing fence
+		current.Content = strings.Join(body, "\n")
+		blocks = append(blocks, *current)
+		current = nil
+	}
+
+	return blocks
+}
+
+// Extension returns the file extension for the block's language
+func (cb CodeBlock) Extension() string {
+	if ext, ok := languageExtensions[cb.Language]; ok {
+		return ext
+	}
+	return ".txt"
+}
+
+// JoinCodeBlocks concatenates the contents of code blocks separated by blank lines
+func JoinCodeBlocks(blocks []CodeBlock) string {
+	contents := make([]string, len(blocks))
+	for i, block := range blocks {
+		contents[i] = block.Content
+	}
+	return strings.Join(contents, "\n\n")
+}
+
+// WriteOutput writes a response to path. When splitCode is true every code
+// block is also written to its own file next to path (name_1.go, name_2.py, ...).
+// If provenance is given, .json exports carry it as a field and other exports
+// get a markdown footer; split code files are left untouched.
+// It returns the list of files written.
+func WriteOutput(path, response string, splitCode bool, provenance *Provenance) ([]string, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create output directory: %w", err)
+		}
+	}
+
+	content := []byte(response)
+	if provenance != nil {
+		if strings.EqualFold(filepath.Ext(path), ".json") {
+			data, err := json.MarshalIndent(struct {
+				Response   string      `json:"response"`
+				Provenance *Provenance `json:"provenance"`
+			}{response, provenance}, "", "  ")
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal output: %w", err)
+			}
+			content = data
+		} else {
+			content = []byte(response + provenance.MarkdownFooter())
+		}
+	}
+
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write output file: %w", err)
+	}
+	written := []string{path}
+
+	if !splitCode {
+		return written, nil
+	}
+
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	for i, block := range ExtractCodeBlocks(response) {
+		blockPath := fmt.Sprintf("%s_%d%s", base, i+1, block.Extension())
+		if err := os.WriteFile(blockPath, []byte(block.Content+"\n"), 0644); err != nil {
+			return written, fmt.Errorf("failed to write code block %d: %w", i+1, err)
+		}
+		written = append(written, blockPath)
+	}
+
+	return written, nil
+}
@@ -0,0 +1,68 @@
+package chatbot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AgentVersion identifies this chatbot build in provenance metadata
+const AgentVersion = "chatbot/1.0"
+
+// Provenance records where a generated artifact came from so downstream
+// consumers can trace it
+type Provenance struct {
+	Model         string    `json:"model"`
+	Persona       string    `json:"persona"`
+	PromptVersion string    `json:"prompt_version"` // hash of the system prompt used
+	Template      string    `json:"template,omitempty"`
+	Sources       []string  `json:"sources,omitempty"`
+	Tenant        string    `json:"tenant,omitempty"`
+	GeneratedAt   time.Time `json:"generated_at"`
+	AgentVersion  string    `json:"agent_version"`
+}
+
+// MarkdownFooter renders the provenance as a footer for markdown exports
+func (p Provenance) MarkdownFooter() string {
+	var builder strings.Builder
+	builder.WriteString("\n\n---\n")
+	builder.WriteString(fmt.Sprintf("_Generated by %s with %s (persona: %s, prompt %s",
+		p.AgentVersion, p.Model, p.Persona, p.PromptVersion))
+	if p.Template != "" {
+		builder.WriteString(", template: " + p.Template)
+	}
+	if p.Tenant != "" {
+		builder.WriteString(", tenant: " + p.Tenant)
+	}
+	builder.WriteString(fmt.Sprintf(") at %s_\n", p.GeneratedAt.UTC().Format(time.RFC3339)))
+	if len(p.Sources) > 0 {
+		builder.WriteString("_Sources: " + strings.Join(p.Sources, ", ") + "_\n")
+	}
+	return builder.String()
+}
+
+// promptVersion returns a short, stable identifier for a prompt's text
+func promptVersion(prompt string) string {
+	sum := sha256.Sum256([]byte(prompt))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// newProvenance describes a response generated with the given system prompt
+func (b *Bot) newProvenance(mode, systemPrompt string) *Provenance {
+	return &Provenance{
+		Model:         b.llmClient.GetModel(),
+		Persona:       mode,
+		PromptVersion: promptVersion(systemPrompt),
+		Template:      fmt.Sprintf("persona:%s (%s)", mode, b.PersonaSource(mode)),
+		Tenant:        b.tenant,
+		GeneratedAt:   time.Now(),
+		AgentVersion:  AgentVersion,
+	}
+}
+
+// LastProvenance returns the provenance of the most recent response, if any
+func (b *Bot) LastProvenance() *Provenance {
+	return b.lastProvenance
+}
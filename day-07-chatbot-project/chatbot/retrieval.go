@@ -0,0 +1,36 @@
+package chatbot
+
+import (
+	"context"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Retriever finds reference material, such as documentation passages, for
+// a user message
+type Retriever interface {
+	Retrieve(ctx context.Context, message string) (string, error)
+}
+
+// SetRetriever makes the bot look up reference material for every message.
+// It is given to the model for that turn only, so memory holds the
+// conversation rather than the documents. A nil retriever turns it off.
+func (b *Bot) SetRetriever(retriever Retriever) {
+	b.retriever = retriever
+}
+
+// Retriever returns the bot's retriever, or nil when it has none
+func (b *Bot) Retriever() Retriever {
+	return b.retriever
+}
+
+// withReference returns a copy of messages with reference material appended
+// as a system message for this turn only
+func withReference(messages []openai.ChatCompletionMessage, reference string) []openai.ChatCompletionMessage {
+	referenced := make([]openai.ChatCompletionMessage, len(messages), len(messages)+1)
+	copy(referenced, messages)
+	return append(referenced, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleSystem,
+		Content: "Reference material for the user's latest message. Answer from it where it applies and say so when it doesn't cover the question.\n\n" + reference,
+	})
+}
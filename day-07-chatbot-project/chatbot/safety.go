@@ -0,0 +1,278 @@
+package chatbot
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SafetyAction is what a policy does when a category is detected
+type SafetyAction string
+
+const (
+	// SafetyAllow lets the turn through unchanged
+	SafetyAllow SafetyAction = "allow"
+	// SafetyGuide lets the turn through but adds the policy's guidance to the system prompt
+	SafetyGuide SafetyAction = "guide"
+	// SafetyBlock refuses the turn
+	SafetyBlock SafetyAction = "block"
+)
+
+// safetyCategories maps each moderated category to the phrases that trigger it
+var safetyCategories = map[string][]string{
+	"violence":  {"kill", "murder", "stab", "shoot", "weapon", "bomb", "torture", "attack"},
+	"medical":   {"diagnose", "diagnosis", "symptom", "dosage", "prescription", "medication", "treatment for", "should i take"},
+	"self_harm": {"suicide", "kill myself", "self-harm", "self harm", "end my life", "hurt myself"},
+	"illegal":   {"make meth", "build a bomb", "hack into", "steal a", "counterfeit", "launder money"},
+}
+
+// SafetyPolicy configures how a persona treats each category
+type SafetyPolicy struct {
+	Persona  string                  `json:"persona"`
+	Rules    map[string]SafetyAction `json:"rules"`
+	Guidance map[string]string       `json:"guidance"`
+}
+
+// SafetyDecision records a policy decision for a single turn
+type SafetyDecision struct {
+	Turn      int          `json:"turn"`
+	Persona   string       `json:"persona"`
+	Direction string       `json:"direction"` // "input" or "output"
+	Category  string       `json:"category"`
+	Action    SafetyAction `json:"action"`
+	Timestamp time.Time    `json:"timestamp"`
+}
+
+// SafetyVerdict is the outcome of checking one message
+type SafetyVerdict struct {
+	Blocked   bool
+	Guidance  []string
+	Decisions []SafetyDecision
+}
+
+// defaultGuidance is the system instruction injected for "guide" actions
+var defaultGuidance = map[string]string{
+	"violence":  "Do not provide instructions that could facilitate real-world violence.",
+	"medical":   "Do not give medical advice, diagnoses or dosages. Share general information only and recommend consulting a qualified healthcare professional.",
+	"self_harm": "The user may be in distress. Respond with empathy, do not provide methods of self-harm, and encourage contacting local emergency services or a crisis line.",
+	"illegal":   "Do not provide instructions for illegal activities.",
+}
+
+// DefaultSafetyPolicies returns the built-in policy for each persona
+func DefaultSafetyPolicies() map[string]SafetyPolicy {
+	return map[string]SafetyPolicy{
+		"casual": {
+			Persona: "casual",
+			Rules: map[string]SafetyAction{
+				"violence":  SafetyGuide,
+				"medical":   SafetyGuide,
+				"self_harm": SafetyGuide,
+				"illegal":   SafetyBlock,
+			},
+		},
+		"assistant": {
+			Persona: "assistant",
+			Rules: map[string]SafetyAction{
+				"violence":  SafetyGuide,
+				"medical":   SafetyGuide,
+				"self_harm": SafetyGuide,
+				"illegal":   SafetyBlock,
+			},
+		},
+		"creative": {
+			// Fiction may depict violence; real-world harm is still guarded
+			Persona: "creative",
+			Rules: map[string]SafetyAction{
+				"violence":  SafetyAllow,
+				"medical":   SafetyGuide,
+				"self_harm": SafetyGuide,
+				"illegal":   SafetyBlock,
+			},
+		},
+	}
+}
+
+// Guardrails applies per-persona safety policies to user input and bot output
+// and keeps a log of every non-trivial decision
+type Guardrails struct {
+	policies map[string]SafetyPolicy
+	log      []SafetyDecision
+	logPath  string
+	mu       sync.Mutex
+}
+
+// NewGuardrails creates guardrails with the default policies. Decisions are
+// appended to logPath as JSON lines when it is non-empty.
+func NewGuardrails(logPath string) *Guardrails {
+	return &Guardrails{
+		policies: DefaultSafetyPolicies(),
+		logPath:  logPath,
+	}
+}
+
+// LoadPolicies merges persona policies from a JSON file (a list of SafetyPolicy)
+func (g *Guardrails) LoadPolicies(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read safety policy file: %w", err)
+	}
+
+	var policies []SafetyPolicy
+	if err := json.Unmarshal(data, &policies); err != nil {
+		return fmt.Errorf("failed to parse safety policy file: %w", err)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, policy := range policies {
+		if policy.Persona == "" {
+			return fmt.Errorf("safety policy is missing a persona")
+		}
+		for category, action := range policy.Rules {
+			if _, ok := safetyCategories[category]; !ok {
+				return fmt.Errorf("unknown safety category '%s' in policy for %s", category, policy.Persona)
+			}
+			if action != SafetyAllow && action != SafetyGuide && action != SafetyBlock {
+				return fmt.Errorf("invalid action '%s' for %s/%s", action, policy.Persona, category)
+			}
+		}
+
+		existing, ok := g.policies[policy.Persona]
+		if !ok {
+			existing = SafetyPolicy{Persona: policy.Persona, Rules: make(map[string]SafetyAction)}
+		}
+		for category, action := range policy.Rules {
+			existing.Rules[category] = action
+		}
+		if len(policy.Guidance) > 0 {
+			if existing.Guidance == nil {
+				existing.Guidance = make(map[string]string)
+			}
+			for category, text := range policy.Guidance {
+				existing.Guidance[category] = text
+			}
+		}
+		g.policies[policy.Persona] = existing
+	}
+
+	return nil
+}
+
+// Policy returns the policy for a persona, falling back to the assistant policy
+func (g *Guardrails) Policy(persona string) SafetyPolicy {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.policyLocked(persona)
+}
+
+func (g *Guardrails) policyLocked(persona string) SafetyPolicy {
+	if policy, ok := g.policies[persona]; ok {
+		return policy
+	}
+	return g.policies["assistant"]
+}
+
+// CheckInput evaluates a user message
+func (g *Guardrails) CheckInput(persona, text string, turn int) SafetyVerdict {
+	return g.check(persona, text, turn, "input")
+}
+
+// CheckOutput evaluates a bot response
+func (g *Guardrails) CheckOutput(persona, text string, turn int) SafetyVerdict {
+	return g.check(persona, text, turn, "output")
+}
+
+// Decisions returns the logged decisions
+func (g *Guardrails) Decisions() []SafetyDecision {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	decisions := make([]SafetyDecision, len(g.log))
+	copy(decisions, g.log)
+	return decisions
+}
+
+// check classifies text and applies the persona's rules
+func (g *Guardrails) check(persona, text string, turn int, direction string) SafetyVerdict {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	policy := g.policyLocked(persona)
+	verdict := SafetyVerdict{}
+
+	for _, category := range detectCategories(text) {
+		action, ok := policy.Rules[category]
+		if !ok {
+			action = SafetyGuide
+		}
+
+		decision := SafetyDecision{
+			Turn:      turn,
+			Persona:   persona,
+			Direction: direction,
+			Category:  category,
+			Action:    action,
+			Timestamp: time.Now(),
+		}
+		verdict.Decisions = append(verdict.Decisions, decision)
+		g.record(decision)
+
+		switch action {
+		case SafetyBlock:
+			verdict.Blocked = true
+		case SafetyGuide:
+			guidance := policy.Guidance[category]
+			if guidance == "" {
+				guidance = defaultGuidance[category]
+			}
+			verdict.Guidance = append(verdict.Guidance, guidance)
+		}
+	}
+
+	return verdict
+}
+
+// record appends a decision to the in-memory log and the log file
+func (g *Guardrails) record(decision SafetyDecision) {
+	g.log = append(g.log, decision)
+
+	if g.logPath == "" {
+		return
+	}
+
+	data, err := json.Marshal(decision)
+	if err != nil {
+		return
+	}
+
+	file, err := os.OpenFile(g.logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	file.Write(append(data, '\n'))
+}
+
+// detectCategories returns the moderated categories mentioned in text
+func detectCategories(text string) []string {
+	lower := strings.ToLower(text)
+
+	var categories []string
+	for category, phrases := range safetyCategories {
+		for _, phrase := range phrases {
+			if strings.Contains(lower, phrase) {
+				categories = append(categories, category)
+				break
+			}
+		}
+	}
+
+	sort.Strings(categories)
+	return categories
+}
@@ -0,0 +1,155 @@
+package chatbot
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Sentiment tracking thresholds
+const (
+	// recentTurns is the window compared against the rest of the conversation
+	recentTurns = 3
+	// degradingScore flags a conversation whose recent turns average this low
+	degradingScore = -0.3
+	// degradingDrop flags a conversation whose recent turns fell this far below earlier ones
+	degradingDrop = 0.4
+)
+
+// sentimentLexicon maps words to a polarity in [-1, 1]. It is deliberately
+// small: it only needs to catch satisfaction and frustration with the bot.
+var sentimentLexicon = map[string]float64{
+	"thanks": 0.6, "thank": 0.6, "great": 0.8, "perfect": 0.9, "awesome": 0.9,
+	"excellent": 0.9, "helpful": 0.7, "good": 0.5, "nice": 0.5, "love": 0.8,
+	"works": 0.5, "worked": 0.6, "solved": 0.7, "clear": 0.4, "cool": 0.4,
+	"amazing": 0.9, "glad": 0.6, "appreciate": 0.7, "exactly": 0.5, "fixed": 0.6,
+	"bad": -0.6, "wrong": -0.7, "useless": -0.9, "terrible": -0.9, "awful": -0.9,
+	"confusing": -0.6, "confused": -0.5, "broken": -0.7, "annoying": -0.7,
+	"frustrating": -0.8, "frustrated": -0.8, "hate": -0.9, "stupid": -0.8,
+	"incorrect": -0.7, "worse": -0.7, "worst": -0.9, "fail": -0.6, "failed": -0.6,
+	"doesn't": -0.2, "unhelpful": -0.8, "again": -0.2, "ugh": -0.7, "sigh": -0.5,
+	"nonsense": -0.8, "ridiculous": -0.7, "disappointed": -0.8, "waste": -0.7,
+}
+
+// negations flip the polarity of the next sentiment word
+var negations = map[string]bool{
+	"not": true, "no": true, "never": true, "isn't": true, "don't": true,
+	"didn't": true, "wasn't": true, "aren't": true, "can't": true, "won't": true,
+}
+
+// ScoreSentiment estimates the sentiment of a user message in [-1, 1] with a
+// lexicon, negation handling and emphasis cues. Zero means neutral.
+func ScoreSentiment(text string) float64 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+
+	var total float64
+	var hits int
+	negated := false
+	for _, word := range words {
+		if negations[word] {
+			negated = true
+			continue
+		}
+		polarity, ok := sentimentLexicon[word]
+		if !ok {
+			continue
+		}
+		if negated {
+			polarity = -polarity * 0.8
+			negated = false
+		}
+		total += polarity
+		hits++
+	}
+	if hits == 0 {
+		return 0
+	}
+
+	score := total / float64(hits)
+	// Exclamations and shouting amplify whatever the words express
+	if strings.Contains(text, "!!") || isShouting(text) {
+		score *= 1.3
+	}
+	return clampScore(score)
+}
+
+// isShouting reports whether most letters of a longer message are upper case
+func isShouting(text string) bool {
+	var letters, upper int
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+	}
+	return letters >= 8 && upper*10 >= letters*7
+}
+
+// clampScore bounds a score to [-1, 1]
+func clampScore(score float64) float64 {
+	if score > 1 {
+		return 1
+	}
+	if score < -1 {
+		return -1
+	}
+	return score
+}
+
+// Satisfaction summarizes the user sentiment of the current conversation
+type Satisfaction struct {
+	Turns   int
+	Average float64
+	// Recent averages the last few turns; Trend is Recent minus the earlier average
+	Recent float64
+	Trend  float64
+	// Degrading is set when recent turns are clearly negative or falling
+	Degrading bool
+}
+
+// summarizeSentiment computes the satisfaction of a sequence of turn scores
+func summarizeSentiment(scores []float64) Satisfaction {
+	s := Satisfaction{Turns: len(scores)}
+	if len(scores) == 0 {
+		return s
+	}
+
+	s.Average = mean(scores)
+	split := len(scores) - recentTurns
+	if split < 0 {
+		split = 0
+	}
+	s.Recent = mean(scores[split:])
+	if split > 0 {
+		s.Trend = s.Recent - mean(scores[:split])
+	}
+	s.Degrading = len(scores) >= recentTurns && (s.Recent <= degradingScore || s.Trend <= -degradingDrop)
+	return s
+}
+
+// mean averages a non-empty slice
+func mean(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// rescoreSentiment rebuilds the turn scores of a restored conversation
+func (b *Bot) rescoreSentiment(messages []ConversationMessage) {
+	b.sentiment = nil
+	for _, msg := range messages {
+		if msg.Role == "user" {
+			b.sentiment = append(b.sentiment, ScoreSentiment(msg.Content))
+		}
+	}
+}
+
+// Satisfaction returns the sentiment trend of the current conversation
+func (b *Bot) Satisfaction() Satisfaction {
+	return summarizeSentiment(b.sentiment)
+}
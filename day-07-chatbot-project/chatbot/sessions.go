@@ -0,0 +1,344 @@
+package chatbot
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sakibmulla/agentic-ai/tokenizer"
+)
+
+// sessionPrefix keeps session conversations apart from ones saved by name
+const sessionPrefix = "session-"
+
+// sessionPattern is what a session ID may look like; it becomes part of file names
+var sessionPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// ValidateSessionID checks a session ID is safe to use
+func ValidateSessionID(id string) error {
+	if !sessionPattern.MatchString(id) {
+		return fmt.Errorf("invalid session ID %q: use 1-64 letters, digits, '_' or '-'", id)
+	}
+	return nil
+}
+
+// Session is one user's conversation with the bot
+type Session struct {
+	ID  string
+	bot *Bot
+
+	// mu serializes the session's messages; info, a snapshot taken after
+	// each use, is guarded by the manager's lock
+	mu   sync.Mutex
+	info SessionInfo
+}
+
+// SessionInfo describes a session held in memory
+type SessionInfo struct {
+	ID         string    `json:"id"`
+	Mode       string    `json:"mode"`
+	Messages   int       `json:"messages"`
+	TokensUsed int       `json:"tokens_used"`
+	StartTime  time.Time `json:"start_time"`
+	LastActive time.Time `json:"last_active"`
+}
+
+// SessionManager keeps a conversation per session, so many users can talk
+// to the bot at once. Each session has its own memory, mode, verbosity,
+// stats and guided task, and is saved after every use, so a session evicted
+// for being idle picks up where it left off when it comes back. Sessions
+// share the base bot's client, tools, guardrails, filters, analytics, bus
+// and tenant.
+//
+// Different sessions may be used concurrently; messages within a session
+// are handled one at a time.
+type SessionManager struct {
+	base *Bot
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+
+	// OnCreate, when set, is invoked with each session's bot before its
+	// first use, e.g. to give it its own retriever
+	OnCreate func(id string, bot *Bot)
+}
+
+// NewSessionManager creates sessions from base, evicting those idle for
+// longer than ttl (never, when ttl is zero)
+func NewSessionManager(base *Bot, ttl time.Duration) *SessionManager {
+	return &SessionManager{
+		base:     base,
+		ttl:      ttl,
+		now:      time.Now,
+		sessions: make(map[string]*Session),
+	}
+}
+
+// ProcessMessage answers a message in a session, starting or resuming it
+func (m *SessionManager) ProcessMessage(ctx context.Context, id, message string) (string, error) {
+	var reply string
+	err := m.With(id, func(bot *Bot) error {
+		var err error
+		reply, err = bot.ProcessMessage(ctx, message)
+		return err
+	})
+	return reply, err
+}
+
+// With runs fn with the session's bot, starting or resuming the session,
+// and saves the conversation afterwards. No other call on the same session
+// runs at the same time.
+func (m *SessionManager) With(id string, fn func(bot *Bot) error) error {
+	session, err := m.acquire(id)
+	if err != nil {
+		return err
+	}
+	defer m.release(session)
+
+	if err := fn(session.bot); err != nil {
+		return err
+	}
+	if err := session.bot.SaveConversation(sessionPrefix + id); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+// acquire returns the session locked for use, creating it when needed
+func (m *SessionManager) acquire(id string) (*Session, error) {
+	if err := ValidateSessionID(id); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	session, ok := m.sessions[id]
+	if !ok {
+		bot, err := m.resume(id)
+		if err != nil {
+			m.mu.Unlock()
+			return nil, err
+		}
+		session = &Session{ID: id, bot: bot, info: bot.sessionInfo()}
+		m.sessions[id] = session
+	}
+	session.info.LastActive = m.now()
+	m.mu.Unlock()
+
+	session.mu.Lock()
+	return session, nil
+}
+
+// release unlocks a session after use
+func (m *SessionManager) release(session *Session) {
+	info := session.bot.sessionInfo()
+	m.mu.Lock()
+	session.info = info
+	session.info.LastActive = m.now()
+	m.mu.Unlock()
+	session.mu.Unlock()
+}
+
+// resume creates a session's bot, loading its saved conversation if any
+func (m *SessionManager) resume(id string) (*Bot, error) {
+	bot, err := m.base.forkSession(id)
+	if err != nil {
+		return nil, err
+	}
+	if m.OnCreate != nil {
+		m.OnCreate(id, bot)
+	}
+	if m.base.history.Exists(sessionPrefix + id) {
+		if err := bot.LoadConversation(sessionPrefix + id); err != nil {
+			return nil, fmt.Errorf("failed to resume session: %w", err)
+		}
+	}
+	return bot, nil
+}
+
+// Delete ends a session and removes its saved conversation, reporting
+// whether there was one
+func (m *SessionManager) Delete(id string) (bool, error) {
+	if err := ValidateSessionID(id); err != nil {
+		return false, err
+	}
+
+	m.mu.Lock()
+	session, active := m.sessions[id]
+	delete(m.sessions, id)
+	m.mu.Unlock()
+
+	// Let a message in progress finish before its conversation goes
+	if active {
+		session.mu.Lock()
+		defer session.mu.Unlock()
+	}
+
+	if err := os.Remove(m.base.sessionSlotPath(id)); err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to remove session task state: %w", err)
+	}
+	if !m.base.history.Exists(sessionPrefix + id) {
+		return active, nil
+	}
+	if err := m.base.history.Delete(sessionPrefix + id); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// EvictIdle drops the sessions unused for longer than the TTL from memory
+// and returns their IDs. Their conversations stay saved. Sessions in use
+// are never evicted.
+func (m *SessionManager) EvictIdle() []string {
+	if m.ttl <= 0 {
+		return nil
+	}
+	cutoff := m.now().Add(-m.ttl)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var evicted []string
+	for id, session := range m.sessions {
+		if session.info.LastActive.After(cutoff) || !session.mu.TryLock() {
+			continue
+		}
+		delete(m.sessions, id)
+		session.mu.Unlock()
+		evicted = append(evicted, id)
+	}
+	sort.Strings(evicted)
+	return evicted
+}
+
+// Run evicts idle sessions periodically until ctx is done
+func (m *SessionManager) Run(ctx context.Context) {
+	if m.ttl <= 0 {
+		return
+	}
+	interval := m.ttl / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.EvictIdle()
+		}
+	}
+}
+
+// Active describes the sessions held in memory as of their latest use,
+// most recently active first
+func (m *SessionManager) Active() []SessionInfo {
+	m.mu.Lock()
+	infos := make([]SessionInfo, 0, len(m.sessions))
+	for _, session := range m.sessions {
+		infos = append(infos, session.info)
+	}
+	m.mu.Unlock()
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].LastActive.After(infos[j].LastActive) })
+	return infos
+}
+
+// Saved returns the IDs of the sessions with a saved conversation
+func (m *SessionManager) Saved() []string {
+	var ids []string
+	for _, name := range m.base.history.List() {
+		if strings.HasPrefix(name, sessionPrefix) {
+			ids = append(ids, strings.TrimPrefix(name, sessionPrefix))
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Transcript returns a session's saved conversation
+func (m *SessionManager) Transcript(id string) (*SavedConversation, error) {
+	if err := ValidateSessionID(id); err != nil {
+		return nil, err
+	}
+	conversation, err := m.base.history.Load(sessionPrefix + id)
+	if err != nil {
+		return nil, err
+	}
+	conversation.Name = id
+	return conversation, nil
+}
+
+// Base returns the bot sessions are created from
+func (m *SessionManager) Base() *Bot {
+	return m.base
+}
+
+// sessionInfo describes the bot's session. Callers must hold the session's lock.
+func (b *Bot) sessionInfo() SessionInfo {
+	return SessionInfo{
+		ID:         b.session,
+		Mode:       b.stats.CurrentMode,
+		Messages:   b.memory.GetMessageCount(),
+		TokensUsed: b.stats.TokensUsed,
+		StartTime:  b.stats.StartTime,
+	}
+}
+
+// sessionSlotPath is where a session's guided task state is kept
+func (b *Bot) sessionSlotPath(id string) string {
+	return filepath.Join(b.config.SaveDirectory, "sessions", id+".slot_state.json")
+}
+
+// forkSession returns a bot for one session, with its own memory, stats,
+// guided task state and sampling, sharing everything else with b
+func (b *Bot) forkSession(id string) (*Bot, error) {
+	slots, err := NewSlotFiller(b.sessionSlotPath(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize slot filler: %w", err)
+	}
+	for _, task := range b.slots.Tasks() {
+		slots.RegisterTask(task)
+	}
+
+	fork := &Bot{
+		llmClient:  b.llmClient,
+		config:     b.config,
+		memory:     NewMemory(b.config.MaxHistory),
+		history:    b.history,
+		slots:      slots,
+		guardrails: b.guardrails,
+		filters:    b.filters,
+		jobs:       b.jobs,
+		analytics:  b.analytics,
+		tenant:     b.tenant,
+		overrides:  b.overrides,
+		keyRing:    b.keyRing,
+		events:     b.events,
+		recorder:   b.recorder,
+		tools:      b.tools,
+		retriever:  b.retriever,
+		session:    id,
+		stats: &Stats{
+			CurrentMode: "assistant",
+			Verbosity:   b.stats.Verbosity,
+			StartTime:   time.Now(),
+		},
+	}
+	fork.memory.SetTokenBudget(b.config.MemoryTokenBudget, tokenizer.ForModel(b.llmClient.GetModel()))
+	fork.memory.SetSystemMessage(fork.conversationPrompt("assistant"))
+	if fork.sampling, err = fork.samplingFor("assistant"); err != nil {
+		return nil, err
+	}
+	return fork, nil
+}
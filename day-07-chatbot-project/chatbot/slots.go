@@ -0,0 +1,301 @@
+package chatbot
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sakibmulla/agentic-ai/persist"
+)
+
+// Slot is a single field a task needs before it can be completed
+type Slot struct {
+	Name   string
+	Prompt string
+	// Validate normalizes the user's answer or returns an error explaining
+	// why it was rejected. A nil Validate accepts any non-empty answer.
+	Validate func(value string) (string, error)
+}
+
+// SlotTask describes a multi-step form such as a booking
+type SlotTask struct {
+	Name        string
+	Description string
+	Slots       []Slot
+	OnComplete  func(values map[string]string) (string, error)
+}
+
+// slotStateFormat versions the saved slot state
+var slotStateFormat = persist.NewFormat("slot-state", 1)
+
+// SlotState is the persisted progress of the active task
+type SlotState struct {
+	Task      string            `json:"task"`
+	Values    map[string]string `json:"values"`
+	StartedAt time.Time         `json:"started_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// SlotFiller asks follow-up questions until every slot of the active task is
+// filled and valid, then calls the task's completion handler. Progress is
+// saved after every answer so an interrupted task resumes in the next session.
+type SlotFiller struct {
+	tasks     map[string]SlotTask
+	state     *SlotState
+	statePath string
+	mu        sync.Mutex
+}
+
+// NewSlotFiller creates a slot filler that persists its state to statePath
+func NewSlotFiller(statePath string) (*SlotFiller, error) {
+	sf := &SlotFiller{
+		tasks:     make(map[string]SlotTask),
+		statePath: statePath,
+	}
+
+	var state SlotState
+	err := slotStateFormat.ReadFile(statePath, &state)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to load slot state: %w", err)
+	}
+	if err == nil {
+		sf.state = &state
+	}
+
+	return sf, nil
+}
+
+// RegisterTask makes a task available to Start
+func (sf *SlotFiller) RegisterTask(task SlotTask) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	sf.tasks[task.Name] = task
+}
+
+// Tasks returns the registered tasks sorted by name
+func (sf *SlotFiller) Tasks() []SlotTask {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+
+	tasks := make([]SlotTask, 0, len(sf.tasks))
+	for _, task := range sf.tasks {
+		tasks = append(tasks, task)
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].Name < tasks[j].Name })
+	return tasks
+}
+
+// Active reports whether a task is in progress
+func (sf *SlotFiller) Active() bool {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	return sf.state != nil
+}
+
+// State returns a copy of the active task's progress, or nil
+func (sf *SlotFiller) State() *SlotState {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+
+	if sf.state == nil {
+		return nil
+	}
+	state := *sf.state
+	state.Values = make(map[string]string, len(sf.state.Values))
+	for k, v := range sf.state.Values {
+		state.Values[k] = v
+	}
+	return &state
+}
+
+// Start begins a task and returns the first question
+func (sf *SlotFiller) Start(name string) (string, error) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+
+	task, ok := sf.tasks[name]
+	if !ok {
+		return "", fmt.Errorf("unknown task '%s'", name)
+	}
+
+	sf.state = &SlotState{
+		Task:      name,
+		Values:    make(map[string]string),
+		StartedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := sf.save(); err != nil {
+		return "", err
+	}
+
+	return sf.nextPrompt(task), nil
+}
+
+// Resume returns the pending question for a task restored from disk
+func (sf *SlotFiller) Resume() (string, error) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+
+	if sf.state == nil {
+		return "", fmt.Errorf("no task in progress")
+	}
+	task, ok := sf.tasks[sf.state.Task]
+	if !ok {
+		return "", fmt.Errorf("unknown task '%s'", sf.state.Task)
+	}
+	return sf.nextPrompt(task), nil
+}
+
+// Fill uses input as the answer to the next unfilled slot. It returns the
+// next question, a validation hint, or the completion handler's result once
+// all slots are filled (done is true in that case).
+func (sf *SlotFiller) Fill(input string) (reply string, done bool, err error) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+
+	if sf.state == nil {
+		return "", false, fmt.Errorf("no task in progress")
+	}
+
+	task, ok := sf.tasks[sf.state.Task]
+	if !ok {
+		return "", false, fmt.Errorf("unknown task '%s'", sf.state.Task)
+	}
+
+	slot := sf.nextSlot(task)
+	if slot != nil {
+		value := strings.TrimSpace(input)
+		if value == "" {
+			return slot.Prompt, false, nil
+		}
+
+		if slot.Validate != nil {
+			normalized, verr := slot.Validate(value)
+			if verr != nil {
+				return fmt.Sprintf("%v. %s", verr, slot.Prompt), false, nil
+			}
+			value = normalized
+		}
+
+		sf.state.Values[slot.Name] = value
+		sf.state.UpdatedAt = time.Now()
+		if err := sf.save(); err != nil {
+			return "", false, err
+		}
+	}
+
+	if next := sf.nextSlot(task); next != nil {
+		return next.Prompt, false, nil
+	}
+
+	values := sf.state.Values
+	result := fmt.Sprintf("%s complete.", task.Name)
+	if task.OnComplete != nil {
+		result, err = task.OnComplete(values)
+		if err != nil {
+			return "", false, fmt.Errorf("task '%s' failed: %w", task.Name, err)
+		}
+	}
+
+	sf.state = nil
+	if err := sf.save(); err != nil {
+		return "", true, err
+	}
+	return result, true, nil
+}
+
+// Cancel abandons the active task
+func (sf *SlotFiller) Cancel() error {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+
+	sf.state = nil
+	return sf.save()
+}
+
+// nextSlot returns the first slot without a value
+func (sf *SlotFiller) nextSlot(task SlotTask) *Slot {
+	for i := range task.Slots {
+		if _, filled := sf.state.Values[task.Slots[i].Name]; !filled {
+			return &task.Slots[i]
+		}
+	}
+	return nil
+}
+
+// nextPrompt returns the question for the next slot
+func (sf *SlotFiller) nextPrompt(task SlotTask) string {
+	if slot := sf.nextSlot(task); slot != nil {
+		return slot.Prompt
+	}
+	return "All details collected. Send any message to finish."
+}
+
+// save persists the current state; a nil state removes the file
+func (sf *SlotFiller) save() error {
+	if sf.state == nil {
+		if err := os.Remove(sf.statePath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to clear slot state: %w", err)
+		}
+		return nil
+	}
+
+	if err := slotStateFormat.WriteFile(sf.statePath, sf.state, 0644); err != nil {
+		return fmt.Errorf("failed to write slot state: %w", err)
+	}
+	return nil
+}
+
+// BookingTask returns the built-in meeting booking task
+func BookingTask() SlotTask {
+	return SlotTask{
+		Name:        "booking",
+		Description: "Book a meeting (date, time, attendees)",
+		Slots: []Slot{
+			{
+				Name:   "date",
+				Prompt: "What date should I book? (YYYY-MM-DD)",
+				Validate: func(value string) (string, error) {
+					date, err := time.Parse("2006-01-02", value)
+					if err != nil {
+						return "", fmt.Errorf("'%s' is not a valid date", value)
+					}
+					if date.Before(time.Now().Truncate(24 * time.Hour)) {
+						return "", fmt.Errorf("%s is in the past", value)
+					}
+					return date.Format("2006-01-02"), nil
+				},
+			},
+			{
+				Name:   "time",
+				Prompt: "What time? (HH:MM, 24-hour)",
+				Validate: func(value string) (string, error) {
+					t, err := time.Parse("15:04", value)
+					if err != nil {
+						return "", fmt.Errorf("'%s' is not a valid time", value)
+					}
+					return t.Format("15:04"), nil
+				},
+			},
+			{
+				Name:   "attendees",
+				Prompt: "How many attendees?",
+				Validate: func(value string) (string, error) {
+					n, err := strconv.Atoi(value)
+					if err != nil || n < 1 {
+						return "", fmt.Errorf("attendees must be a positive number")
+					}
+					return strconv.Itoa(n), nil
+				},
+			},
+		},
+		OnComplete: func(values map[string]string) (string, error) {
+			return fmt.Sprintf("Booked a meeting on %s at %s for %s attendees. ✅",
+				values["date"], values["time"], values["attendees"]), nil
+		},
+	}
+}
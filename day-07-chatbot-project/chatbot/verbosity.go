@@ -0,0 +1,83 @@
+package chatbot
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Verbosity controls how long responses are. It is independent of the
+// persona: the persona sets tone, verbosity sets length.
+type Verbosity string
+
+const (
+	VerbosityTerse    Verbosity = "terse"
+	VerbosityNormal   Verbosity = "normal"
+	VerbosityDetailed Verbosity = "detailed"
+)
+
+// Verbosities lists the supported levels from shortest to longest
+var Verbosities = []Verbosity{VerbosityTerse, VerbosityNormal, VerbosityDetailed}
+
+// ParseVerbosity validates a verbosity name
+func ParseVerbosity(value string) (Verbosity, error) {
+	v := Verbosity(strings.ToLower(strings.TrimSpace(value)))
+	for _, known := range Verbosities {
+		if v == known {
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("invalid verbosity '%s'. Available levels: %v", value, Verbosities)
+}
+
+// Instruction is appended to the system prompt; normal adds nothing
+func (v Verbosity) Instruction() string {
+	switch v {
+	case VerbosityTerse:
+		return "Answer as briefly as possible: one or two sentences, no preamble, no lists unless asked."
+	case VerbosityDetailed:
+		return "Give thorough answers: explain your reasoning, cover edge cases and include examples where helpful."
+	default:
+		return ""
+	}
+}
+
+// MaxTokens scales the configured response budget for this level
+func (v Verbosity) MaxTokens(base int) int {
+	switch v {
+	case VerbosityTerse:
+		if base/3 < 32 {
+			return min(base, 32)
+		}
+		return base / 3
+	case VerbosityDetailed:
+		return base * 2
+	default:
+		return base
+	}
+}
+
+// SetVerbosity changes the response length for the current conversation
+func (b *Bot) SetVerbosity(value string) error {
+	verbosity, err := ParseVerbosity(value)
+	if err != nil {
+		return err
+	}
+
+	b.stats.Verbosity = verbosity
+	b.memory.SetSystemMessage(b.conversationPrompt(b.stats.CurrentMode))
+	return nil
+}
+
+// Verbosity returns the effective verbosity of the current conversation
+func (b *Bot) Verbosity() Verbosity {
+	return b.stats.Verbosity
+}
+
+// conversationPrompt is the persona prompt plus the verbosity instruction
+func (b *Bot) conversationPrompt(mode string) string {
+	prompt := b.systemPrompt(mode)
+	if instruction := b.stats.Verbosity.Instruction(); instruction != "" {
+		prompt += "\n\n" + instruction
+	}
+	return prompt
+}
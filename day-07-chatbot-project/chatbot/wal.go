@@ -0,0 +1,236 @@
+package chatbot
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sakibmulla/agentic-ai/persist"
+	"github.com/sashabaranov/go-openai"
+
+	"chatbot/tenants"
+	"chatbot/utils"
+)
+
+// walCompactEvery is the number of log records after which the log is
+// rewritten as a single snapshot of the current memory
+const walCompactEvery = 200
+
+// WAL record operations
+const (
+	walAdd      = "add"
+	walSystem   = "system"
+	walClear    = "clear"
+	walSnapshot = "snapshot"
+)
+
+// walFormat versions each line of the log. Lines written before versioning
+// are read as version 1.
+var walFormat = persist.NewFormat("memory-log", 1)
+
+// walRecord is one line of the memory write-ahead log
+type walRecord struct {
+	Op       string                         `json:"op"`
+	Role     string                         `json:"role,omitempty"`
+	Content  string                         `json:"content,omitempty"`
+	Messages []openai.ChatCompletionMessage `json:"messages,omitempty"`
+	Time     time.Time                      `json:"time"`
+}
+
+// MemoryWAL is an append-only log of memory changes. Each change is synced
+// to disk before it is applied, so after a crash the conversation can be
+// rebuilt by replaying the log.
+type MemoryWAL struct {
+	path    string
+	file    *os.File
+	records int
+
+	// keyRing, when set, seals each record with the tenant's data key
+	keyRing *tenants.KeyRing
+	tenant  string
+}
+
+// OpenMemoryWAL opens (or creates) the log at path
+func OpenMemoryWAL(path string) (*MemoryWAL, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create memory log directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open memory log: %w", err)
+	}
+	return &MemoryWAL{path: path, file: file}, nil
+}
+
+// SetEncryption seals records with the tenant's data key, like History's.
+// Call it before Replay, which seals any plaintext records already logged.
+func (w *MemoryWAL) SetEncryption(keyRing *tenants.KeyRing, tenant string) {
+	w.keyRing = keyRing
+	w.tenant = tenant
+}
+
+// Replay applies every complete record to memory and returns how many were
+// applied. A torn final record, left by a crash mid-append, is truncated so
+// the log is consistent again. A log with records from an older release, or
+// not sealed with the active data key, is compacted into a current snapshot.
+func (w *MemoryWAL) Replay(memory *Memory) (int, error) {
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to read memory log: %w", err)
+	}
+	active := 0
+	if w.keyRing != nil {
+		var err error
+		if active, err = w.keyRing.ActiveVersion(w.tenant); err != nil {
+			return 0, err
+		}
+	}
+
+	reader := bufio.NewReader(w.file)
+	var good int64
+	applied := 0
+	outdated := false
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break // a trailing line without a newline was never fully written
+		}
+		if err != nil {
+			return applied, fmt.Errorf("failed to read memory log: %w", err)
+		}
+
+		data := bytes.TrimSpace(line)
+		if !json.Valid(data) {
+			break
+		}
+		data, sealed, err := w.open(data, active)
+		if err != nil {
+			// A whole record that won't decrypt isn't torn; keep it
+			return applied, err
+		}
+
+		var record walRecord
+		upgraded, err := walFormat.Unmarshal(data, &record)
+		if errors.Is(err, persist.ErrNewerVersion) {
+			// Not torn, just unreadable here; truncating would lose it
+			return applied, fmt.Errorf("failed to replay memory log: %w", err)
+		}
+		if err != nil {
+			break
+		}
+		memory.apply(record)
+		good += int64(len(line))
+		applied++
+		outdated = outdated || upgraded || !sealed
+	}
+
+	if err := w.file.Truncate(good); err != nil {
+		return applied, fmt.Errorf("failed to truncate memory log: %w", err)
+	}
+	w.records = applied
+	if outdated {
+		if err := w.compact(memory.messages); err != nil {
+			return applied, err
+		}
+	}
+	return applied, nil
+}
+
+// open returns a record's JSON, decrypting it when it is sealed, and whether
+// it is stored as it would be written now: sealed with the active key when
+// encryption is on, and in plaintext when it is off
+func (w *MemoryWAL) open(data []byte, active int) ([]byte, bool, error) {
+	if !tenants.IsEnvelope(data) {
+		return data, w.keyRing == nil, nil
+	}
+	if w.keyRing == nil {
+		return nil, false, fmt.Errorf("memory log is encrypted but no master key is configured")
+	}
+	version, err := tenants.KeyVersion(data)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to decrypt memory log: %w", err)
+	}
+	plain, err := w.keyRing.Decrypt(w.tenant, data)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to decrypt memory log: %w", err)
+	}
+	return plain, version == active, nil
+}
+
+// seal marshals a record as one line, encrypted when a key ring is set
+func (w *MemoryWAL) seal(record walRecord) ([]byte, error) {
+	data, err := walFormat.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal memory log record: %w", err)
+	}
+	if w.keyRing != nil {
+		if data, err = w.keyRing.Encrypt(w.tenant, data); err != nil {
+			return nil, fmt.Errorf("failed to encrypt memory log record: %w", err)
+		}
+	}
+	return append(data, '\n'), nil
+}
+
+// KeyVersions returns the data key versions the log's records are sealed with
+func (w *MemoryWAL) KeyVersions() (map[int]bool, error) {
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read memory log: %w", err)
+	}
+	versions := make(map[int]bool)
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if version, err := tenants.KeyVersion(line); err == nil {
+			versions[version] = true
+		}
+	}
+	return versions, nil
+}
+
+// append writes and syncs a record
+func (w *MemoryWAL) append(record walRecord) error {
+	record.Time = time.Now()
+	data, err := w.seal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := w.file.Write(data); err != nil {
+		return fmt.Errorf("failed to write memory log: %w", err)
+	}
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync memory log: %w", err)
+	}
+
+	w.records++
+	return nil
+}
+
+// compact replaces the log with one snapshot of messages
+func (w *MemoryWAL) compact(messages []openai.ChatCompletionMessage) error {
+	data, err := w.seal(walRecord{Op: walSnapshot, Messages: messages, Time: time.Now()})
+	if err != nil {
+		return err
+	}
+	if err := utils.WriteFileAtomic(w.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to compact memory log: %w", err)
+	}
+
+	// The old handle points at the replaced file
+	file, err := os.OpenFile(w.path, os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to reopen memory log: %w", err)
+	}
+	w.file.Close()
+	w.file = file
+	w.records = 1
+	return nil
+}
+
+// Close closes the log file
+func (w *MemoryWAL) Close() error {
+	return w.file.Close()
+}
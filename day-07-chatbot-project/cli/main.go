@@ -0,0 +1,1124 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	"chatbot/analytics"
+	"chatbot/bus"
+	"chatbot/chatbot"
+	"chatbot/compare"
+	"chatbot/config"
+	"chatbot/doctor"
+	"chatbot/llm"
+	"chatbot/server"
+	"chatbot/utils"
+	"chatbot/vectors"
+
+	"github.com/sakibmulla/agentic-ai/costs"
+	"github.com/sakibmulla/agentic-ai/logging"
+)
+
+// Run runs like Main in an environment the caller has already loaded .env
+// and the config file into, as the agentic command does
+func Run(args []string) {
+	config.UseLoadedFiles()
+	Main(args)
+}
+
+// Main runs the chatbot, or the subcommand named by args[0]: doctor,
+// compare-models, serve, encrypt-history or config. args is the command
+// line after the program name.
+func Main(args []string) {
+	if len(args) > 0 && args[0] == "doctor" {
+		os.Exit(runDoctor())
+	}
+	if len(args) > 0 && args[0] == "compare-models" {
+		os.Exit(runCompareModels(args[1:]))
+	}
+	if len(args) > 0 && args[0] == "serve" {
+		os.Exit(runServe())
+	}
+	if len(args) > 0 && args[0] == "encrypt-history" {
+		os.Exit(runEncryptHistory())
+	}
+	if len(args) > 0 && args[0] == "config" {
+		os.Exit(runConfig(args[1:]))
+	}
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Printf("Error loading configuration: %v\n", err)
+		os.Exit(1)
+	}
+	logging.Setup(slog.LevelWarn)
+
+	bot, _, err := newBot(cfg)
+	if err != nil {
+		fmt.Printf("Error initializing chatbot: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := watchCircuits(bot.Bus()); err != nil {
+		fmt.Printf("Error subscribing to circuit events: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Setup graceful shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		<-sigChan
+		fmt.Println("\nShutting down gracefully...")
+		cancel()
+	}()
+
+	// Start the chat loop
+	if err := runChatLoop(ctx, bot); err != nil {
+		fmt.Printf("Chat loop error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// newBot creates the LLM client, with the cost limiter and, when a monthly
+// limit is configured, the spend guard, and the bot around it
+func newBot(cfg *config.Config) (*chatbot.Bot, *llm.Client, error) {
+	llmClient, err := newLLMClient(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize LLM client: %w", err)
+	}
+	limiter, err := newCostLimiter(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	llmClient.SetCostLimiter(limiter)
+
+	if cfg.MonthlySpendLimit > 0 {
+		guard, err := llm.NewSpendGuard(cfg.SpendLedgerPath, cfg.MonthlySpendLimit)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load spend ledger: %w", err)
+		}
+		llmClient.SetSpendGuard(guard)
+	}
+
+	bot, err := chatbot.New(llmClient, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return bot, llmClient, nil
+}
+
+// newCostLimiter creates the limiter enforcing the configured budget, with
+// the cost ledger, after loading the pricing file
+func newCostLimiter(cfg *config.Config) (*costs.Limiter, error) {
+	if cfg.PricingPath != "" {
+		if err := costs.LoadPrices(cfg.PricingPath); err != nil {
+			return nil, err
+		}
+	}
+	budget, err := costs.ParseBudget(cfg.Budget)
+	if err != nil {
+		return nil, fmt.Errorf("invalid BUDGET: %w", err)
+	}
+	if budget.Downgrades, err = costs.ParseDowngrades(cfg.BudgetDowngrades); err != nil {
+		return nil, fmt.Errorf("invalid BUDGET_DOWNGRADES: %w", err)
+	}
+	tracker, err := costs.NewTracker(cfg.CostLedgerPath)
+	if err != nil {
+		return nil, err
+	}
+	return costs.NewLimiter(tracker, budget), nil
+}
+
+// runServe serves the bot, its conversations and the vector store over
+// HTTP until interrupted. It returns the exit code.
+func runServe() int {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Printf("Error loading configuration: %v\n", err)
+		return 1
+	}
+	logging.Setup(slog.LevelInfo)
+	bot, llmClient, err := newBot(cfg)
+	if err != nil {
+		fmt.Printf("Error initializing chatbot: %v\n", err)
+		return 1
+	}
+	store, err := vectors.NewStore(llmClient.Embedder(cfg.OllamaEmbedModel), cfg.VectorStorePath)
+	if err != nil {
+		fmt.Printf("Error loading vector store: %v\n", err)
+		return 1
+	}
+	srv := server.New(bot, store, server.Config{
+		MaxInFlight:    cfg.ServerMaxInFlight,
+		RequestTimeout: cfg.ServerRequestTimeout,
+		TopK:           cfg.RetrievalTopK,
+		MinScore:       cfg.RetrievalMinScore,
+		SessionTTL:     cfg.SessionTTL,
+	})
+	httpServer := &http.Server{Addr: cfg.ServerAddr, Handler: srv.Handler()}
+
+	evictCtx, stopEvicting := context.WithCancel(context.Background())
+	defer stopEvicting()
+	go srv.Sessions().Run(evictCtx)
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		fmt.Println("\nShutting down gracefully...")
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.ServerRequestTimeout)
+		defer cancel()
+		httpServer.Shutdown(ctx)
+	}()
+
+	stats := store.Stats()
+	fmt.Printf("🚀 Serving on http://%s (%d documents, %d passages)\n", cfg.ServerAddr, stats.Documents, stats.Passages)
+	fmt.Println("   POST /chat, GET /conversations, POST /documents, POST /search, GET /metrics, GET /health")
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Printf("Server error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// newLLMClient creates the client for the configured provider, balancing
+// across several keys when a keys file is configured, with the configured
+// sampling options
+func newLLMClient(cfg *config.Config) (*llm.Client, error) {
+	var client *llm.Client
+	switch {
+	case cfg.Provider == llm.ProviderOllama:
+		api, err := cfg.OllamaEndpoint()
+		if err != nil {
+			return nil, err
+		}
+		client = llm.NewOllamaClient(api, cfg.Model)
+	case cfg.APIKeysFile == "":
+		var err error
+		if client, err = llm.NewClient(cfg.OpenAIAPIKey, cfg.Endpoint, cfg.Model); err != nil {
+			return nil, err
+		}
+	default:
+		keys, err := llm.LoadKeyPool(cfg.APIKeysFile, cfg.KeyUsagePath)
+		if err != nil {
+			return nil, err
+		}
+		client = llm.NewPooledClient(keys, cfg.Model)
+	}
+
+	sampling, err := llm.ParseSampling(cfg.Sampling)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SAMPLING: %w", err)
+	}
+	return client.WithSampling(sampling)
+}
+
+// runCompareModels runs saved conversations and/or a prompts file through
+// two models and prints or writes the diff report. It returns the exit code.
+func runCompareModels(args []string) int {
+	flags := flag.NewFlagSet("compare-models", flag.ContinueOnError)
+	prompts := flags.String("prompts", "", "file with one prompt per line")
+	conversations := flags.Bool("conversations", true, "include saved conversations from SAVE_DIRECTORY")
+	judgeModel := flags.String("judge", "", "model that picks the better answer (default: no judging)")
+	out := flags.String("out", "", "write the report to a .json or .md file instead of printing it")
+	limit := flags.Int("limit", 0, "compare at most this many cases (0 = all)")
+	flags.Usage = func() {
+		fmt.Println("Usage: chatbot compare-models [flags] <model-a> <model-b>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Printf("Error loading configuration: %v\n", err)
+		return 1
+	}
+	base, err := newLLMClient(cfg)
+	if err != nil {
+		fmt.Printf("Error initializing LLM client: %v\n", err)
+		return 1
+	}
+
+	cases, err := comparisonCorpus(cfg, *prompts, *conversations)
+	if err != nil {
+		fmt.Printf("Error loading corpus: %v\n", err)
+		return 1
+	}
+	if *limit > 0 && len(cases) > *limit {
+		cases = cases[:*limit]
+	}
+
+	// All clients share the key pool, the cost limiter, and the spend guard
+	// when one is configured
+	modelClient := func(model string) *llm.Client {
+		if base.Provider() == llm.ProviderOllama {
+			// newLLMClient has checked the endpoint
+			api, _ := cfg.OllamaEndpoint()
+			return llm.NewOllamaClient(api, model)
+		}
+		return llm.NewPooledClient(base.Keys(), model)
+	}
+	clients := []*llm.Client{modelClient(flags.Arg(0)), modelClient(flags.Arg(1))}
+	compareConfig := compare.Config{MaxTokens: cfg.MaxTokens, Temperature: cfg.Temperature}
+	if *judgeModel != "" {
+		compareConfig.Judge = modelClient(*judgeModel)
+		clients = append(clients, compareConfig.Judge)
+	}
+	if cfg.MonthlySpendLimit > 0 {
+		guard, err := llm.NewSpendGuard(cfg.SpendLedgerPath, cfg.MonthlySpendLimit)
+		if err != nil {
+			fmt.Printf("Error loading spend ledger: %v\n", err)
+			return 1
+		}
+		for _, client := range clients {
+			client.SetSpendGuard(guard)
+		}
+	}
+	limiter, err := newCostLimiter(cfg)
+	if err != nil {
+		fmt.Printf("Error loading budget: %v\n", err)
+		return 1
+	}
+	for _, client := range clients {
+		client.SetCostLimiter(limiter)
+	}
+
+	fmt.Printf("🔬 Comparing %s and %s on %d cases...\n", flags.Arg(0), flags.Arg(1), len(cases))
+	report, err := compare.Run(context.Background(), clients[0], clients[1], cases, compareConfig)
+	if err != nil {
+		fmt.Printf("Comparison failed: %v\n", err)
+		return 1
+	}
+
+	if *out == "" {
+		fmt.Println(report.Markdown())
+		return 0
+	}
+	data := []byte(report.Markdown())
+	if strings.HasSuffix(*out, ".json") {
+		if data, err = json.MarshalIndent(report, "", "  "); err != nil {
+			fmt.Printf("Error encoding report: %v\n", err)
+			return 1
+		}
+	}
+	if err := utils.WriteFileAtomic(*out, data, 0644); err != nil {
+		fmt.Printf("Error writing report: %v\n", err)
+		return 1
+	}
+	fmt.Printf("📄 Report written to %s\n", *out)
+	return 0
+}
+
+// comparisonCorpus collects the cases for compare-models. Conversations that
+// can't be read (e.g. encrypted ones) are skipped with a warning.
+func comparisonCorpus(cfg *config.Config, promptsPath string, includeConversations bool) ([]compare.Case, error) {
+	var cases []compare.Case
+	if promptsPath != "" {
+		prompts, err := compare.LoadPrompts(promptsPath)
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, prompts...)
+	}
+
+	if includeConversations {
+		history, err := chatbot.NewHistory(cfg.SaveDirectory)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range history.List() {
+			conversation, err := history.Load(name)
+			if err != nil {
+				fmt.Printf("⚠️  Skipping %s: %v\n", name, err)
+				continue
+			}
+			if c, ok := compare.FromConversation(conversation); ok {
+				cases = append(cases, c)
+			}
+		}
+	}
+	return cases, nil
+}
+
+// watchCircuits alerts on the console when an API key is rested or recovers
+func watchCircuits(events bus.Bus) error {
+	_, err := events.Subscribe("circuit.>", func(msg bus.Message) {
+		var event llm.CircuitEvent
+		if err := msg.Decode(&event); err != nil {
+			return
+		}
+		if msg.Topic == bus.TopicCircuitOpened {
+			fmt.Printf("\n🚨 API key '%s' failed and is rested until %s: %s\n", event.Key, event.Until.Format("15:04:05"), event.Error)
+		} else {
+			fmt.Printf("\n✅ API key '%s' is serving requests again\n", event.Key)
+		}
+	})
+	return err
+}
+
+// runEncryptHistory encrypts the saved conversations and memory log of
+// TENANT_ID in place, for migrating files written before a master key was
+// configured. It needs no API key.
+func runEncryptHistory() int {
+	cfg := config.LoadUnvalidated()
+	logging.Setup(slog.LevelWarn)
+	history, keyRing, err := chatbot.OpenHistory(cfg)
+	if err != nil {
+		fmt.Printf("Error opening conversation history: %v\n", err)
+		return 1
+	}
+	if keyRing == nil {
+		fmt.Println("Error: set TENANT_MASTER_KEY or TENANT_MASTER_KEY_FILE first")
+		return 1
+	}
+
+	fmt.Printf("🔒 Encrypting %d plaintext conversation(s) in %s for tenant %s...\n",
+		len(history.Plaintext()), cfg.SaveDirectory, cfg.TenantID)
+	rewritten, err := history.Reencrypt(context.Background(), nil)
+	if err != nil {
+		fmt.Printf("Error after rewriting %d conversation(s): %v\n", rewritten, err)
+		return 1
+	}
+	fmt.Printf("Rewrote %d conversation(s) with the active key. ✅\n", rewritten)
+
+	if cfg.MemoryWALPath != "" {
+		records, err := chatbot.SealMemoryLog(cfg, keyRing)
+		if err != nil {
+			fmt.Printf("Error sealing the memory log: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Sealed the memory log %s (%d record(s)) with the active key. ✅\n", cfg.MemoryWALPath, records)
+	}
+	return 0
+}
+
+// runConfig shows the settings in effect and where each came from, or
+// checks them, and returns the process exit code
+func runConfig(args []string) int {
+	if err := config.LoadFiles(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	if err := config.Keys.Command(args, os.Stdout); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	if args[0] == "validate" {
+		if _, err := config.Load(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return 1
+		}
+		fmt.Println("Configuration is valid. ✅")
+	}
+	return 0
+}
+
+// runDoctor checks the environment and returns the process exit code
+func runDoctor() int {
+	fmt.Println("🩺 Checking your environment...")
+	failures := doctor.Print(doctor.Run(context.Background(), config.LoadUnvalidated()))
+	if failures > 0 {
+		fmt.Printf("\n%d check(s) failed. Apply the fixes above and run doctor again.\n", failures)
+		return 1
+	}
+	fmt.Println("\nAll required checks passed. 🎉")
+	return 0
+}
+
+func runChatLoop(ctx context.Context, bot *chatbot.Bot) error {
+	scanner := bufio.NewScanner(os.Stdin)
+
+	// Print welcome message
+	fmt.Println("🤖 Welcome to the Simple Chatbot!")
+	fmt.Println("Type 'help' for commands, 'quit' to exit.")
+	fmt.Printf("Available modes: %s\n", strings.Join(bot.Modes(), ", "))
+	fmt.Println(strings.Repeat("-", 50))
+
+	if recovered := bot.RecoveredMessages(); recovered > 0 {
+		fmt.Printf("♻️  Restored %d messages from the previous session (use /clear to start fresh)\n", recovered)
+	}
+	if prompt, ok := bot.ResumeTask(); ok {
+		fmt.Printf("Bot: %s\n", prompt)
+	}
+
+	degradingShown, walWarned := false, false
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+			fmt.Print("\nYou: ")
+			if !scanner.Scan() {
+				return scanner.Err()
+			}
+
+			input := strings.TrimSpace(scanner.Text())
+			if input == "" {
+				continue
+			}
+
+			// Handle special commands
+			if handled, err := handleCommand(input, bot); err != nil {
+				fmt.Printf("Command error: %v\n", err)
+				continue
+			} else if handled {
+				continue
+			}
+
+			// Get bot response
+			response, err := bot.ProcessMessage(ctx, input)
+			if err != nil {
+				fmt.Printf("Bot error: %v\n", err)
+				continue
+			}
+
+			fmt.Printf("Bot: %s\n", response)
+
+			if err := bot.MemoryLogError(); err != nil && !walWarned {
+				fmt.Printf("⚠️  Memory log failed, this conversation will not survive a crash: %v\n", err)
+				walWarned = true
+			}
+
+			// Flag a souring conversation once, so the user can change tack
+			if satisfaction := bot.Satisfaction(); satisfaction.Degrading && !degradingShown {
+				fmt.Println("😟 This conversation seems to be going badly. Try /mode, /verbosity or /clear to change approach.")
+				degradingShown = true
+			} else if !satisfaction.Degrading {
+				degradingShown = false
+			}
+		}
+	}
+}
+
+func handleCommand(input string, bot *chatbot.Bot) (bool, error) {
+	if !strings.HasPrefix(input, "/") && input != "help" && input != "quit" {
+		return false, nil
+	}
+
+	switch {
+	case input == "quit" || input == "/quit":
+		fmt.Println("Goodbye! 👋")
+		os.Exit(0)
+		return true, nil
+
+	case input == "help" || input == "/help":
+		printHelp()
+		return true, nil
+
+	case strings.HasPrefix(input, "/mode "):
+		mode := strings.TrimPrefix(input, "/mode ")
+		if err := bot.SetMode(mode); err != nil {
+			return true, err
+		}
+		fmt.Printf("Switched to %s mode! 🎭\n", mode)
+		return true, nil
+
+	case input == "/verbosity":
+		fmt.Printf("Verbosity: %s (levels: %v)\n", bot.Verbosity(), chatbot.Verbosities)
+		return true, nil
+
+	case strings.HasPrefix(input, "/verbosity "):
+		if err := bot.SetVerbosity(strings.TrimPrefix(input, "/verbosity ")); err != nil {
+			return true, err
+		}
+		fmt.Printf("Verbosity set to %s 📏\n", bot.Verbosity())
+		return true, nil
+
+	case input == "/persona" || strings.HasPrefix(input, "/persona "):
+		return true, handlePersonaCommand(strings.TrimSpace(strings.TrimPrefix(input, "/persona")), bot)
+
+	case input == "/clear":
+		bot.ClearMemory()
+		fmt.Println("Conversation memory cleared! 🧹")
+		return true, nil
+
+	case strings.HasPrefix(input, "/save "):
+		name := strings.TrimPrefix(input, "/save ")
+		if err := bot.SaveConversation(name); err != nil {
+			return true, err
+		}
+		fmt.Printf("Conversation saved as '%s' 💾\n", name)
+		return true, nil
+
+	case strings.HasPrefix(input, "/load "):
+		name := strings.TrimPrefix(input, "/load ")
+		if err := bot.LoadConversation(name); err != nil {
+			return true, err
+		}
+		fmt.Printf("Conversation '%s' loaded! 📂\n", name)
+		return true, nil
+
+	case strings.HasPrefix(input, "/merge "):
+		args := strings.Fields(strings.TrimPrefix(input, "/merge "))
+		if len(args) < 3 {
+			return true, fmt.Errorf("usage: /merge <new-name> <conversation> <conversation> [...]")
+		}
+		result, err := bot.MergeConversations(context.Background(), args[0], args[1:])
+		if err != nil {
+			return true, err
+		}
+		fmt.Printf("Merged %d conversations into '%s' 🔗 (%d messages, %d duplicate turns removed)\n",
+			len(args)-1, args[0], len(result.Conversation.Messages), result.DuplicatesRemoved)
+		if result.SummaryFallback {
+			fmt.Println("⚠️  Could not reach the model; used a basic summary instead")
+		}
+		fmt.Printf("Summary: %s\n", result.Conversation.Summary)
+		return true, nil
+
+	case strings.HasPrefix(input, "/export "):
+		args := strings.Fields(strings.TrimPrefix(input, "/export "))
+		if len(args) < 2 || len(args) > 3 {
+			return true, fmt.Errorf("usage: /export <conversation> <path> [markdown|finetune|sharegpt]")
+		}
+		format, err := conversationFormat(args[1], args[2:])
+		if err != nil {
+			return true, err
+		}
+		file, err := os.Create(args[1])
+		if err != nil {
+			return true, fmt.Errorf("failed to create %s: %w", args[1], err)
+		}
+		err = bot.History().Export(args[0], format, file)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(args[1])
+			return true, err
+		}
+		fmt.Printf("Exported '%s' to %s as %s 📤\n", args[0], args[1], format)
+		return true, nil
+
+	case strings.HasPrefix(input, "/import "):
+		args := strings.Fields(strings.TrimPrefix(input, "/import "))
+		if len(args) < 1 || len(args) > 2 {
+			return true, fmt.Errorf("usage: /import <path> [markdown|finetune|sharegpt]")
+		}
+		format, err := conversationFormat(args[0], args[1:])
+		if err != nil {
+			return true, err
+		}
+		file, err := os.Open(args[0])
+		if err != nil {
+			return true, err
+		}
+		defer file.Close()
+		names, err := bot.History().Import(file, format)
+		for _, name := range names {
+			fmt.Printf("Imported '%s' 📥\n", name)
+		}
+		return true, err
+
+	case input == "/history":
+		conversations := bot.ListConversations()
+		if len(conversations) == 0 {
+			fmt.Println("No saved conversations found.")
+		} else {
+			fmt.Println("Saved conversations:")
+			for _, conv := range conversations {
+				fmt.Printf("  - %s\n", conv)
+			}
+		}
+		return true, nil
+
+	case input == "/stats":
+		stats := bot.GetStats()
+		fmt.Printf("Session stats:\n")
+		fmt.Printf("  Messages: %d\n", stats.MessageCount)
+		fmt.Printf("  Tokens used: %d\n", stats.TokensUsed)
+		fmt.Printf("  Current mode: %s\n", stats.CurrentMode)
+		fmt.Printf("  Verbosity: %s\n", stats.Verbosity)
+		if satisfaction := bot.Satisfaction(); satisfaction.Turns > 0 {
+			fmt.Printf("  Sentiment: %+.2f average, %+.2f recent (trend %+.2f over %d turns)\n",
+				satisfaction.Average, satisfaction.Recent, satisfaction.Trend, satisfaction.Turns)
+			if satisfaction.Degrading {
+				fmt.Println("  ⚠️  Satisfaction is degrading")
+			}
+		}
+		return true, nil
+
+	case input == "/copy" || input == "/copy code":
+		response := bot.LastResponse()
+		if response == "" {
+			return true, fmt.Errorf("no response to copy yet")
+		}
+
+		if input == "/copy code" {
+			blocks := chatbot.ExtractCodeBlocks(response)
+			if len(blocks) == 0 {
+				return true, fmt.Errorf("last response contains no code blocks")
+			}
+			response = chatbot.JoinCodeBlocks(blocks)
+		}
+
+		if err := utils.CopyToClipboard(response); err != nil {
+			return true, err
+		}
+		fmt.Println("Copied to clipboard! 📋")
+		return true, nil
+
+	case strings.HasPrefix(input, "/saveout "):
+		args := strings.Fields(strings.TrimPrefix(input, "/saveout "))
+		if len(args) == 0 {
+			return true, fmt.Errorf("usage: /saveout <path> [--split]")
+		}
+
+		response := bot.LastResponse()
+		if response == "" {
+			return true, fmt.Errorf("no response to save yet")
+		}
+
+		splitCode := len(args) > 1 && args[1] == "--split"
+		files, err := chatbot.WriteOutput(args[0], response, splitCode, bot.LastProvenance())
+		if err != nil {
+			return true, err
+		}
+		for _, file := range files {
+			fmt.Printf("Wrote %s 📝\n", file)
+		}
+		return true, nil
+
+	case input == "/tasks":
+		fmt.Println("Available tasks:")
+		for _, task := range bot.Tasks() {
+			fmt.Printf("  - %s: %s\n", task.Name, task.Description)
+		}
+		return true, nil
+
+	case input == "/task cancel":
+		if err := bot.CancelTask(); err != nil {
+			return true, err
+		}
+		fmt.Println("Task cancelled. ❌")
+		return true, nil
+
+	case strings.HasPrefix(input, "/task "):
+		prompt, err := bot.StartTask(strings.TrimPrefix(input, "/task "))
+		if err != nil {
+			return true, err
+		}
+		fmt.Printf("Bot: %s\n", prompt)
+		return true, nil
+
+	case strings.HasPrefix(input, "/batch "):
+		args := strings.Fields(strings.TrimPrefix(input, "/batch "))
+		if len(args) != 2 {
+			return true, fmt.Errorf("usage: /batch <input-file> <output-file>")
+		}
+		id, err := bot.SubmitBatch(args[0], args[1])
+		if err != nil {
+			return true, err
+		}
+		fmt.Printf("Started job %s ⏳ (use /jobs status %s)\n", id, id)
+		return true, nil
+
+	case input == "/jobs" || strings.HasPrefix(input, "/jobs "):
+		return true, handleJobsCommand(strings.Fields(strings.TrimPrefix(input, "/jobs")), bot)
+
+	case input == "/safety":
+		stats := bot.GetStats()
+		policy := bot.Guardrails().Policy(stats.CurrentMode)
+		fmt.Printf("Safety policy for %s mode:\n", stats.CurrentMode)
+		categories := make([]string, 0, len(policy.Rules))
+		for category := range policy.Rules {
+			categories = append(categories, category)
+		}
+		sort.Strings(categories)
+		for _, category := range categories {
+			fmt.Printf("  %-10s %s\n", category, policy.Rules[category])
+		}
+
+		decisions := bot.Guardrails().Decisions()
+		if len(decisions) > 0 {
+			fmt.Println("Recent decisions:")
+			start := len(decisions) - 5
+			if start < 0 {
+				start = 0
+			}
+			for _, d := range decisions[start:] {
+				fmt.Printf("  turn %d %s: %s -> %s\n", d.Turn, d.Direction, d.Category, d.Action)
+			}
+		}
+		return true, nil
+
+	case input == "/costs":
+		limiter := bot.CostLimiter()
+		if limiter == nil {
+			fmt.Println("Cost tracking is not configured.")
+			return true, nil
+		}
+		for _, spend := range []costs.Spend{limiter.Tracker().Today(), limiter.Tracker().ThisMonth()} {
+			fmt.Printf("%s: $%.4f over %d requests\n", spend.Period, spend.Total.Cost, spend.Total.Requests)
+			for _, breakdown := range []struct {
+				name   string
+				totals map[string]*costs.Totals
+			}{{"model", spend.Models}, {"mode", spend.Templates}, {"session", spend.Sessions}} {
+				keys := make([]string, 0, len(breakdown.totals))
+				for key := range breakdown.totals {
+					keys = append(keys, key)
+				}
+				sort.Strings(keys)
+				for _, key := range keys {
+					fmt.Printf("  %-8s %-24s $%.4f (%d requests)\n", breakdown.name, key, breakdown.totals[key].Cost, breakdown.totals[key].Requests)
+				}
+			}
+		}
+		if budget := limiter.Budget(); budget.Total.Daily > 0 || budget.Total.Monthly > 0 {
+			fmt.Printf("Budget: $%.2f a day, $%.2f a month (0 = no limit)\n", budget.Total.Daily, budget.Total.Monthly)
+		}
+		return true, nil
+
+	case input == "/spend":
+		guard := bot.SpendGuard()
+		if guard == nil {
+			fmt.Println("Monthly spend limit is not configured (set MONTHLY_SPEND_LIMIT_USD).")
+			return true, nil
+		}
+		month := guard.MonthToDate()
+		fmt.Printf("Month-to-date spend: $%.4f of $%.2f (%d requests)\n", month.SpentUSD, guard.Limit(), month.Requests)
+		if guard.Stopped() {
+			fmt.Println("⛔ Hard stop is active: paid provider calls are refused.")
+		}
+		return true, nil
+
+	case input == "/bus":
+		printBus(bot)
+		return true, nil
+
+	case input == "/analytics":
+		report := bot.Analytics().Report()
+		if report.Events == 0 && !report.Noisy {
+			fmt.Println("No usage recorded yet.")
+			return true, nil
+		}
+		fmt.Printf("📈 Usage %s to %s", report.From, report.To)
+		if report.Noisy {
+			fmt.Print(" (differentially private, counts are approximate)")
+		}
+		fmt.Printf("\n  Events: %d, failures: %d, tokens: %d\n", report.Events, report.Failures, report.TotalTokens)
+		printCounts("By mode", report.ByMode)
+		printCounts("Tokens per turn", report.TokenHistogram)
+		printCounts("Latency", report.LatencyHistogram)
+		printSatisfaction(report)
+		return true, nil
+
+	case input == "/admin keys":
+		status, err := bot.EncryptionStatus()
+		if err != nil {
+			return true, err
+		}
+		if !status.Enabled {
+			fmt.Println("Conversation encryption is disabled (set TENANT_MASTER_KEY or TENANT_MASTER_KEY_FILE).")
+			return true, nil
+		}
+		fmt.Printf("🔐 Tenant %s: active key v%d, held versions %v\n", status.Tenant, status.ActiveVersion, status.Versions)
+		if status.Plaintext > 0 {
+			fmt.Printf("⚠️  %d conversation(s) still unencrypted; run /admin encrypt-history\n", status.Plaintext)
+		}
+		return true, nil
+
+	case input == "/admin encrypt-history":
+		id, plaintext, err := bot.EncryptConversations()
+		if err != nil {
+			return true, err
+		}
+		fmt.Printf("🔒 Encrypting %d plaintext conversation(s) in job %s (use /jobs status %s)\n", plaintext, id, id)
+		return true, nil
+
+	case input == "/admin rotate-key":
+		id, version, err := bot.RotateEncryptionKey()
+		if err != nil {
+			return true, err
+		}
+		fmt.Printf("🔑 Rotated to key v%d; re-encrypting in job %s (use /jobs status %s)\n", version, id, id)
+		return true, nil
+
+	case input == "/admin apikeys" || input == "/admin apikeys reload":
+		keys := bot.APIKeys()
+		if keys == nil {
+			return true, fmt.Errorf("the %s provider uses no API keys", bot.Provider())
+		}
+		if strings.HasSuffix(input, " reload") {
+			if err := keys.Reload(); err != nil {
+				return true, err
+			}
+			fmt.Println("API keys reloaded. 🔄")
+		}
+		fmt.Printf("🗝️  %d API key(s), strategy %s\n", keys.Size(), keys.Strategy())
+		for _, key := range keys.Status() {
+			limit := "unlimited"
+			if key.RequestsPerMinute > 0 {
+				limit = fmt.Sprintf("%d/%d per min", key.RecentRequests, key.RequestsPerMinute)
+			}
+			fmt.Printf("  %-12s weight %d, %s, month: %d requests, %d tokens, $%.4f\n",
+				key.Name, key.Weight, limit, key.MonthToDate.Requests, key.MonthToDate.Tokens, key.MonthToDate.SpentUSD)
+			if !key.CoolingDownUntil.IsZero() {
+				fmt.Printf("    ⏸️  resting until %s: %s\n", key.CoolingDownUntil.Format("15:04:05"), key.LastError)
+			}
+		}
+		return true, nil
+
+	case input == "/admin reset-spend":
+		guard := bot.SpendGuard()
+		if guard == nil {
+			return true, fmt.Errorf("monthly spend limit is not configured")
+		}
+		if err := guard.Reset(); err != nil {
+			return true, err
+		}
+		fmt.Println("Spend hard stop lifted by admin. 🔓")
+		return true, nil
+
+	default:
+		fmt.Printf("Unknown command: %s\n", input)
+		return true, nil
+	}
+}
+
+// conversationFormat returns the format named in args, or the one path's
+// extension suggests
+func conversationFormat(path string, args []string) (chatbot.ExportFormat, error) {
+	if len(args) > 0 {
+		return chatbot.ParseExportFormat(args[0])
+	}
+	return chatbot.FormatForPath(path)
+}
+
+// handlePersonaCommand shows or edits the tenant's persona overrides
+func handlePersonaCommand(args string, bot *chatbot.Bot) error {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		fmt.Printf("Tenant: %s\n", bot.Tenant())
+		for _, mode := range bot.Modes() {
+			sampling, err := bot.PersonaSampling(mode)
+			switch {
+			case err != nil:
+				fmt.Printf("  %-12s (%s) ⚠️  %v\n", mode, bot.PersonaSource(mode), err)
+			case sampling != llm.Sampling{}:
+				fmt.Printf("  %-12s (%s) sampling %s\n", mode, bot.PersonaSource(mode), sampling)
+			default:
+				fmt.Printf("  %-12s (%s)\n", mode, bot.PersonaSource(mode))
+			}
+		}
+		return nil
+	}
+
+	switch fields[0] {
+	case "set":
+		if len(fields) < 3 {
+			return fmt.Errorf("usage: /persona set <mode> <system prompt>")
+		}
+		if err := bot.SetPersonaOverride(fields[1], strings.Join(fields[2:], " ")); err != nil {
+			return err
+		}
+		fmt.Printf("Persona '%s' overridden for tenant %s ✏️\n", fields[1], bot.Tenant())
+	case "reset":
+		if len(fields) != 2 {
+			return fmt.Errorf("usage: /persona reset <mode>")
+		}
+		if err := bot.ResetPersonaOverride(fields[1]); err != nil {
+			return err
+		}
+		fmt.Printf("Persona '%s' restored to the global default ↩️\n", fields[1])
+	case "sampling":
+		if len(fields) != 3 {
+			return fmt.Errorf("usage: /persona sampling <mode> <top_k=40,min_p=0.05,...|reset>")
+		}
+		if fields[2] == "reset" {
+			if err := bot.ResetSamplingOverride(fields[1]); err != nil {
+				return err
+			}
+			fmt.Printf("Sampling for '%s' restored to the defaults ↩️\n", fields[1])
+			return nil
+		}
+		if err := bot.SetSamplingOverride(fields[1], fields[2]); err != nil {
+			return err
+		}
+		fmt.Printf("Sampling for '%s' set to %s for tenant %s 🎛️\n", fields[1], fields[2], bot.Tenant())
+	default:
+		return fmt.Errorf("unknown persona command: %s", fields[0])
+	}
+	return nil
+}
+
+// printCounts prints a map of counts sorted by key
+func printCounts(title string, counts map[string]int) {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fmt.Printf("  %s:\n", title)
+	for _, key := range keys {
+		fmt.Printf("    %-10s %d\n", key, counts[key])
+	}
+}
+
+// printSatisfaction prints user sentiment per mode and the daily trend
+func printSatisfaction(report analytics.Report) {
+	if len(report.Satisfaction) == 0 {
+		return
+	}
+
+	modes := make([]string, 0, len(report.Satisfaction))
+	for mode := range report.Satisfaction {
+		modes = append(modes, mode)
+	}
+	sort.Strings(modes)
+
+	fmt.Println("  Satisfaction by mode:")
+	for _, mode := range modes {
+		s := report.Satisfaction[mode]
+		fmt.Printf("    %-10s %+.2f average, %.0f%% negative (%d turns)\n", mode, s.Average, s.NegativePct, s.Turns)
+	}
+	fmt.Println("  Satisfaction trend:")
+	for _, day := range report.SatisfactionTrend {
+		fmt.Printf("    %s %+.2f (%d turns)\n", day.Date, day.Average, day.Turns)
+	}
+}
+
+// printBus shows the bus backend, its subscriptions and recent events
+func printBus(bot *chatbot.Bot) {
+	stats := bot.Bus().Stats()
+	fmt.Printf("\n📡 Message bus (%s): %d published\n", stats.Backend, stats.Published)
+	for _, sub := range stats.Subscriptions {
+		fmt.Printf("  %-16s delivered %d, dropped %d\n", sub.Pattern, sub.Delivered, sub.Dropped)
+	}
+
+	events := bot.RecentEvents()
+	if len(events) == 0 {
+		fmt.Println("No events yet")
+		return
+	}
+	fmt.Println("Recent events:")
+	for _, msg := range events {
+		fmt.Printf("  %s %-16s %s\n", msg.Time.Format("15:04:05"), msg.Topic, msg.Data)
+	}
+}
+
+func handleJobsCommand(args []string, bot *chatbot.Bot) error {
+	manager := bot.Jobs()
+	if len(args) == 0 || args[0] == "list" {
+		jobList := manager.List()
+		if len(jobList) == 0 {
+			fmt.Println("No jobs yet.")
+			return nil
+		}
+		for _, job := range jobList {
+			fmt.Printf("  %s  %-11s %3.0f%%  %s\n", job.ID, job.Status, job.Progress*100, job.Name)
+		}
+		return nil
+	}
+
+	if len(args) < 2 {
+		return fmt.Errorf("usage: /jobs [list|status|logs|cancel] <id>")
+	}
+
+	id := args[1]
+	switch args[0] {
+	case "status":
+		job, err := manager.Get(id)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Job %s: %s\n", job.ID, job.Name)
+		fmt.Printf("  Status: %s (%.0f%%)\n", job.Status, job.Progress*100)
+		if job.Error != "" {
+			fmt.Printf("  Error: %s\n", job.Error)
+		}
+		for _, line := range job.Logs[max(0, len(job.Logs)-5):] {
+			fmt.Printf("  %s\n", line)
+		}
+		return nil
+
+	case "logs":
+		job, err := manager.Get(id)
+		if err != nil {
+			return err
+		}
+		stream, err := manager.Subscribe(id)
+		if err != nil {
+			return err
+		}
+		for _, line := range job.Logs {
+			fmt.Println(line)
+		}
+		for line := range stream {
+			fmt.Println(line)
+		}
+		return nil
+
+	case "cancel":
+		if err := manager.Cancel(id); err != nil {
+			return err
+		}
+		fmt.Printf("Cancellation requested for %s 🛑\n", id)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown jobs command: %s", args[0])
+	}
+}
+
+func printHelp() {
+	fmt.Println("\n📚 Available Commands:")
+	fmt.Println("  help                 - Show this help message")
+	fmt.Println("  quit                 - Exit the chatbot")
+	fmt.Println("  /mode <mode>         - Change conversation mode (see /persona for the list)")
+	fmt.Println("  /persona             - List personas and whether the tenant overrides them")
+	fmt.Println("  /persona set <mode> <prompt> - Override (or add) a persona for this tenant")
+	fmt.Println("  /persona reset <mode> - Remove the tenant override")
+	fmt.Println("  /persona sampling <mode> <opts|reset> - Set top_p, top_k, min_p, repeat_penalty for a persona")
+	fmt.Println("  /verbosity [level]   - Show or set response length (terse, normal, detailed)")
+	fmt.Println("  /clear               - Clear conversation memory")
+	fmt.Println("  /save <name>         - Save current conversation")
+	fmt.Println("  /load <name>         - Load a saved conversation")
+	fmt.Println("  /merge <new> <a> <b> - Merge saved conversations, dropping duplicate turns")
+	fmt.Println("  /history             - List saved conversations")
+	fmt.Println("  /export <name> <path> [format] - Export a conversation as markdown, finetune (JSONL) or sharegpt")
+	fmt.Println("  /import <path> [format] - Import conversations; the format defaults to the file extension")
+	fmt.Println("  /stats               - Show session statistics and conversation sentiment")
+	fmt.Println("  /copy [code]         - Copy the last response (or only its code blocks) to the clipboard")
+	fmt.Println("  /saveout <path> [--split] - Write the last response to a file (--split saves code blocks separately)")
+	fmt.Println("  /tasks               - List guided tasks (e.g. booking)")
+	fmt.Println("  /task <name>         - Start a guided task; /task cancel abandons it")
+	fmt.Println("  /batch <in> <out>    - Answer every line of a file in a background job")
+	fmt.Println("  /jobs [list]         - List background jobs")
+	fmt.Println("  /jobs status|logs|cancel <id> - Inspect, follow or cancel a job")
+	fmt.Println("  /bus                 - Show message bus subscriptions and recent events")
+	fmt.Println("  /safety              - Show the safety policy for the current mode and recent decisions")
+	fmt.Println("  /spend               - Show month-to-date spend against the monthly limit")
+	fmt.Println("  /costs               - Show today's and this month's spend by model, mode and session")
+	fmt.Println("  /analytics           - Show aggregate usage, histograms and satisfaction per mode")
+	fmt.Println("  /admin reset-spend   - Lift the monthly spend hard stop")
+	fmt.Println("  /admin keys          - Show the tenant's conversation encryption keys")
+	fmt.Println("  /admin apikeys [reload] - Show provider API keys with rate and spend, or reload the keys file")
+	fmt.Println("  /admin rotate-key    - Rotate the tenant key and re-encrypt conversations in the background")
+	fmt.Println("  /admin encrypt-history - Encrypt conversations saved before encryption was enabled")
+	fmt.Println("\n💡 Tips:")
+	fmt.Println("  - The bot remembers your conversation within the session")
+	fmt.Println("  - Try different modes for different conversation styles")
+	fmt.Println("  - Save important conversations for later reference")
+}
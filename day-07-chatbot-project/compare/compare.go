@@ -0,0 +1,367 @@
+package compare
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/sashabaranov/go-openai"
+
+	"chatbot/chatbot"
+	"chatbot/llm"
+)
+
+// Judge verdicts
+const (
+	PreferA = "A"
+	PreferB = "B"
+	Tie     = "tie"
+)
+
+// Case is one prompt or conversation prefix sent to both models
+type Case struct {
+	Name     string
+	Messages []openai.ChatCompletionMessage
+}
+
+// Config controls a model comparison
+type Config struct {
+	MaxTokens   int
+	Temperature float64
+	// Judge is asked which answer is better; nil skips judging
+	Judge *llm.Client
+}
+
+// Answer is one model's response to a case
+type Answer struct {
+	Content string        `json:"content"`
+	Words   int           `json:"words"`
+	Tokens  int           `json:"tokens"`
+	CostUSD float64       `json:"cost_usd"`
+	Latency time.Duration `json:"latency"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// Result compares the two answers to a case
+type Result struct {
+	Case       string  `json:"case"`
+	A          Answer  `json:"a"`
+	B          Answer  `json:"b"`
+	Similarity float64 `json:"similarity"`
+	// Preference is PreferA, PreferB, Tie, or empty when not judged
+	Preference string `json:"preference,omitempty"`
+}
+
+// Summary aggregates a comparison over the whole corpus
+type Summary struct {
+	Cases          int           `json:"cases"`
+	Failed         int           `json:"failed"`
+	MeanSimilarity float64       `json:"mean_similarity"`
+	MeanWordsA     float64       `json:"mean_words_a"`
+	MeanWordsB     float64       `json:"mean_words_b"`
+	CostA          float64       `json:"cost_usd_a"`
+	CostB          float64       `json:"cost_usd_b"`
+	MeanLatencyA   time.Duration `json:"mean_latency_a"`
+	MeanLatencyB   time.Duration `json:"mean_latency_b"`
+	WinsA          int           `json:"wins_a"`
+	WinsB          int           `json:"wins_b"`
+	Ties           int           `json:"ties"`
+}
+
+// Report is the outcome of running a corpus through two models
+type Report struct {
+	ModelA    string    `json:"model_a"`
+	ModelB    string    `json:"model_b"`
+	Judge     string    `json:"judge,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Results   []Result  `json:"results"`
+	Summary   Summary   `json:"summary"`
+}
+
+// LoadPrompts reads a corpus file with one prompt per line. Blank lines and
+// lines starting with # are skipped.
+func LoadPrompts(path string) ([]Case, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open prompts file: %w", err)
+	}
+	defer file.Close()
+
+	var cases []Case
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		cases = append(cases, Case{
+			Name:     fmt.Sprintf("prompt %d", len(cases)+1),
+			Messages: []openai.ChatCompletionMessage{{Role: "user", Content: line}},
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read prompts file: %w", err)
+	}
+	return cases, nil
+}
+
+// FromConversation replays a saved conversation up to its last user turn,
+// so both models answer the question the original model answered last.
+// It returns false for conversations without a user turn.
+func FromConversation(conversation *chatbot.SavedConversation) (Case, bool) {
+	last := -1
+	for i, msg := range conversation.Messages {
+		if msg.Role == "user" {
+			last = i
+		}
+	}
+	if last < 0 {
+		return Case{}, false
+	}
+
+	messages := make([]openai.ChatCompletionMessage, 0, last+1)
+	for _, msg := range conversation.Messages[:last+1] {
+		messages = append(messages, openai.ChatCompletionMessage{Role: msg.Role, Content: msg.Content})
+	}
+	return Case{Name: conversation.Name, Messages: messages}, true
+}
+
+// Run sends every case to both clients and compares the answers. A failed
+// call is recorded in the result rather than stopping the run.
+func Run(ctx context.Context, a, b *llm.Client, cases []Case, config Config) (*Report, error) {
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("the corpus is empty")
+	}
+
+	report := &Report{ModelA: a.GetModel(), ModelB: b.GetModel(), CreatedAt: time.Now()}
+	if config.Judge != nil {
+		report.Judge = config.Judge.GetModel()
+	}
+
+	for i, c := range cases {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		result := Result{
+			Case: c.Name,
+			A:    ask(ctx, a, c.Messages, config),
+			B:    ask(ctx, b, c.Messages, config),
+		}
+		if result.A.Error == "" && result.B.Error == "" {
+			result.Similarity = Similarity(result.A.Content, result.B.Content)
+			if config.Judge != nil {
+				// Alternate the order the answers are shown in to cancel out position bias
+				result.Preference = judge(ctx, config.Judge, c.Messages, result.A.Content, result.B.Content, i%2 == 1)
+			}
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	report.Summary = summarize(report.Results)
+	return report, nil
+}
+
+// ask gets one model's answer and measures it
+func ask(ctx context.Context, client *llm.Client, messages []openai.ChatCompletionMessage, config Config) Answer {
+	started := time.Now()
+	resp, err := client.ChatCompletion(ctx, messages, config.MaxTokens, config.Temperature)
+	answer := Answer{Latency: time.Since(started)}
+	if err != nil {
+		answer.Error = err.Error()
+		return answer
+	}
+	if len(resp.Choices) == 0 {
+		answer.Error = "no response choices returned"
+		return answer
+	}
+
+	answer.Content = resp.Choices[0].Message.Content
+	answer.Words = len(strings.Fields(answer.Content))
+	answer.Tokens = resp.Usage.CompletionTokens
+	answer.CostUSD = llm.EstimateCost(client.GetModel(), resp.Usage)
+	return answer
+}
+
+// judgePrompt asks for a single-word verdict so it can be parsed reliably
+const judgePrompt = `You are comparing two assistant answers to the same conversation.
+Judge helpfulness, correctness and clarity; ignore length unless it hurts clarity.
+
+Conversation:
+%s
+
+Answer 1:
+%s
+
+Answer 2:
+%s
+
+Reply with exactly one word: 1, 2, or TIE.`
+
+// judge asks the judge model which answer is better. swapped shows B first.
+// An unusable verdict counts as a tie.
+func judge(ctx context.Context, client *llm.Client, messages []openai.ChatCompletionMessage, a, b string, swapped bool) string {
+	var transcript strings.Builder
+	for _, msg := range messages {
+		fmt.Fprintf(&transcript, "%s: %s\n", msg.Role, msg.Content)
+	}
+
+	first, second := a, b
+	if swapped {
+		first, second = b, a
+	}
+	resp, err := client.ChatCompletion(ctx, []openai.ChatCompletionMessage{
+		{Role: "user", Content: fmt.Sprintf(judgePrompt, transcript.String(), first, second)},
+	}, 5, 0)
+	if err != nil || len(resp.Choices) == 0 {
+		return Tie
+	}
+
+	verdict := strings.ToUpper(strings.TrimSpace(resp.Choices[0].Message.Content))
+	switch {
+	case strings.HasPrefix(verdict, "1"):
+		if swapped {
+			return PreferB
+		}
+		return PreferA
+	case strings.HasPrefix(verdict, "2"):
+		if swapped {
+			return PreferA
+		}
+		return PreferB
+	default:
+		return Tie
+	}
+}
+
+// Similarity is the cosine similarity of the two texts' word counts, from
+// 0 (no words in common) to 1 (same words in the same proportions)
+func Similarity(a, b string) float64 {
+	countsA, countsB := wordCounts(a), wordCounts(b)
+	if len(countsA) == 0 || len(countsB) == 0 {
+		if len(countsA) == len(countsB) {
+			return 1
+		}
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for word, n := range countsA {
+		dot += float64(n * countsB[word])
+		normA += float64(n * n)
+	}
+	for _, n := range countsB {
+		normB += float64(n * n)
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// wordCounts counts the lower-cased words of a text
+func wordCounts(text string) map[string]int {
+	counts := make(map[string]int)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		counts[word]++
+	}
+	return counts
+}
+
+// summarize aggregates the per-case results; failed cases only count as failed
+func summarize(results []Result) Summary {
+	summary := Summary{Cases: len(results)}
+	var latencyA, latencyB time.Duration
+	ok := 0
+	for _, r := range results {
+		summary.CostA += r.A.CostUSD
+		summary.CostB += r.B.CostUSD
+		if r.A.Error != "" || r.B.Error != "" {
+			summary.Failed++
+			continue
+		}
+
+		ok++
+		summary.MeanSimilarity += r.Similarity
+		summary.MeanWordsA += float64(r.A.Words)
+		summary.MeanWordsB += float64(r.B.Words)
+		latencyA += r.A.Latency
+		latencyB += r.B.Latency
+		switch r.Preference {
+		case PreferA:
+			summary.WinsA++
+		case PreferB:
+			summary.WinsB++
+		case Tie:
+			summary.Ties++
+		}
+	}
+	if ok == 0 {
+		return summary
+	}
+
+	summary.MeanSimilarity /= float64(ok)
+	summary.MeanWordsA /= float64(ok)
+	summary.MeanWordsB /= float64(ok)
+	summary.MeanLatencyA = latencyA / time.Duration(ok)
+	summary.MeanLatencyB = latencyB / time.Duration(ok)
+	return summary
+}
+
+// Markdown renders the report as a table per case followed by the summary
+func (r *Report) Markdown() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Model comparison: %s vs %s\n\n", r.ModelA, r.ModelB)
+	if r.Judge != "" {
+		fmt.Fprintf(&sb, "Judge: %s\n\n", r.Judge)
+	}
+
+	sb.WriteString("| Case | Similarity | Words A/B | Cost A/B (USD) | Latency A/B | Preferred |\n")
+	sb.WriteString("|------|-----------:|----------:|---------------:|------------:|-----------|\n")
+	for _, res := range r.Results {
+		if res.A.Error != "" || res.B.Error != "" {
+			fmt.Fprintf(&sb, "| %s | error: %s |  |  |  |  |\n", res.Case, firstError(res))
+			continue
+		}
+		fmt.Fprintf(&sb, "| %s | %.2f | %d/%d | %.4f/%.4f | %v/%v | %s |\n",
+			res.Case, res.Similarity, res.A.Words, res.B.Words, res.A.CostUSD, res.B.CostUSD,
+			res.A.Latency.Round(time.Millisecond), res.B.Latency.Round(time.Millisecond), r.preferred(res.Preference))
+	}
+
+	s := r.Summary
+	fmt.Fprintf(&sb, "\n## Summary\n\n")
+	fmt.Fprintf(&sb, "- Cases: %d (%d failed)\n", s.Cases, s.Failed)
+	fmt.Fprintf(&sb, "- Mean similarity: %.2f\n", s.MeanSimilarity)
+	fmt.Fprintf(&sb, "- Mean length: %.0f words (%s) vs %.0f words (%s)\n", s.MeanWordsA, r.ModelA, s.MeanWordsB, r.ModelB)
+	fmt.Fprintf(&sb, "- Total cost: $%.4f (%s) vs $%.4f (%s)\n", s.CostA, r.ModelA, s.CostB, r.ModelB)
+	fmt.Fprintf(&sb, "- Mean latency: %v (%s) vs %v (%s)\n", s.MeanLatencyA.Round(time.Millisecond), r.ModelA, s.MeanLatencyB.Round(time.Millisecond), r.ModelB)
+	if r.Judge != "" {
+		fmt.Fprintf(&sb, "- Judge preference: %s %d, %s %d, ties %d\n", r.ModelA, s.WinsA, r.ModelB, s.WinsB, s.Ties)
+	}
+	return sb.String()
+}
+
+// preferred names the model a verdict favours
+func (r *Report) preferred(preference string) string {
+	switch preference {
+	case PreferA:
+		return r.ModelA
+	case PreferB:
+		return r.ModelB
+	default:
+		return preference
+	}
+}
+
+// firstError returns the error of whichever call failed, safe for a table cell
+func firstError(res Result) string {
+	err := res.B.Error
+	if res.A.Error != "" {
+		err = res.A.Error
+	}
+	return strings.ReplaceAll(err, "|", "/")
+}
@@ -2,15 +2,20 @@ package config
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
 	"strconv"
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/sakibmulla/agentic-ai/endpoint"
+	"github.com/sakibmulla/agentic-ai/settings"
 )
 
 // Config holds all configuration for the chatbot
 type Config struct {
+	// Provider serves the model: "openai" or "ollama"
+	Provider      string
 	OpenAIAPIKey  string
 	Model         string
 	MaxTokens     int
@@ -19,29 +24,228 @@ type Config struct {
 	RetryAttempts int
 	RetryDelay    time.Duration
 	SaveDirectory string
+	Verbosity     string
+
+	// Endpoint is where OpenAI calls go: OpenAI, Azure OpenAI or an
+	// OpenAI-compatible server (OPENAI_API_TYPE, OPENAI_BASE_URL, ...)
+	Endpoint endpoint.Endpoint
+	// endpointErr is why the endpoint settings couldn't be used
+	endpointErr error
+
+	MonthlySpendLimit float64
+	SpendLedgerPath   string
+	// Budget bounds spend per day and month, overall and by user (tenant),
+	// session and mode; see costs.ParseBudget. BudgetDowngrades names the
+	// cheaper models used as a budget runs low.
+	Budget           string
+	BudgetDowngrades string
+	CostLedgerPath   string
+	// PricingPath names a JSON file of model prices that replace or add to
+	// the built-in ones; see costs.LoadPrices
+	PricingPath string
+
+	SafetyPolicyFile string
+
+	JobsStatePath string
+
+	AnalyticsPath          string
+	AnalyticsRetentionDays int
+	AnalyticsEpsilon       float64
+
+	TenantID        string
+	TenantDir       string
+	TenantMasterKey string
+	// TenantMasterKeyFile, when set, holds the master key instead of the environment
+	TenantMasterKeyFile string
+
+	// APIKeysFile, when set, lists several API keys to balance across
+	APIKeysFile  string
+	KeyUsagePath string
+
+	// MemoryWALPath, when set, enables the crash-recovery log for conversation memory
+	MemoryWALPath string
+	// MemoryTokenBudget, when positive, summarizes the oldest messages once
+	// the conversation takes more tokens than this, instead of dropping
+	// messages beyond MaxHistory
+	MemoryTokenBudget int
+
+	// BusNATSURL, when set, shares bus events with other processes through NATS
+	BusNATSURL string
+
+	// EnableTools lets the model call the shared tools (calculator, text analysis)
+	EnableTools bool
+	// EnableWebTools also gives it fetch_url and, with WEB_SEARCH_PROVIDER,
+	// web_search; it requires EnableTools
+	EnableWebTools bool
+
+	// OllamaURL is the Ollama server used by the ollama provider
+	OllamaURL string
+	// Sampling sets sampling options beyond temperature for every persona,
+	// e.g. "top_k=40,min_p=0.05"; see llm.ParseSampling
+	Sampling string
+
+	// ServerAddr is where `chatbot serve` listens
+	ServerAddr           string
+	ServerMaxInFlight    int
+	ServerRequestTimeout time.Duration
+	// SessionTTL is how long a server session stays in memory unused
+	SessionTTL time.Duration
+	// VectorStorePath holds the documents added through the server
+	VectorStorePath  string
+	OllamaEmbedModel string
+	// RetrievalTopK and RetrievalMinScore choose the passages given to the
+	// model with each message
+	RetrievalTopK     int
+	RetrievalMinScore float64
 }
 
-// Load creates a new configuration from environment variables
+// Load creates a new configuration from environment variables and the
+// config file's CONFIG_PROFILE (see settings.Load), and checks it
 func Load() (*Config, error) {
+	cfg, err := load()
+	if err != nil {
+		return nil, err
+	}
+	if err := settings.Err(Keys.Validate()); err != nil {
+		return nil, err
+	}
+
+	switch cfg.Provider {
+	case "openai":
+		if cfg.endpointErr != nil {
+			return nil, cfg.endpointErr
+		}
+		if cfg.OpenAIAPIKey == "" && cfg.APIKeysFile == "" && cfg.Endpoint.RequiresKey() {
+			return nil, fmt.Errorf("OPENAI_API_KEY (or OPENAI_API_KEYS_FILE) is required, in the environment or the config file")
+		}
+	case "ollama":
+		if cfg.endpointErr != nil {
+			return nil, cfg.endpointErr
+		}
+		if _, err := cfg.OllamaEndpoint(); err != nil {
+			return nil, err
+		}
+		if cfg.EnableTools {
+			return nil, fmt.Errorf("ENABLE_TOOLS is not supported with the ollama provider")
+		}
+	default:
+		return nil, fmt.Errorf("unknown LLM_PROVIDER %q: use openai or ollama", cfg.Provider)
+	}
+
+	return cfg, nil
+}
+
+// OllamaEndpoint returns the endpoint the ollama provider calls: OllamaURL,
+// serving Model and OllamaEmbedModel, with OLLAMA_TIMEOUT_SECONDS
+func (c *Config) OllamaEndpoint() (endpoint.Endpoint, error) {
+	return endpoint.Ollama(c.OllamaURL, c.Model, c.OllamaEmbedModel, c.Endpoint.Timeout)
+}
+
+// LoadUnvalidated reads the configuration without requiring an API key,
+// so diagnostics can run on a broken environment. A config file that can't
+// be read is reported and skipped.
+func LoadUnvalidated() *Config {
+	cfg, err := load()
+	if err != nil {
+		slog.Warn("skipping the config file", "error", err)
+	}
+	return cfg
+}
+
+// filesLoaded is set by UseLoadedFiles
+var filesLoaded bool
+
+// UseLoadedFiles tells LoadFiles that the caller, such as the agentic
+// command, has already loaded .env and the config file it chose, so they
+// aren't read again. Call it before loading the configuration.
+func UseLoadedFiles() {
+	filesLoaded = true
+}
+
+// LoadFiles reads .env and then the config file into the environment,
+// without replacing variables already set
+func LoadFiles() error {
+	if filesLoaded {
+		return nil
+	}
 	// Try to load .env file (ignore error if file doesn't exist)
 	_ = godotenv.Load()
+	return settings.Load()
+}
+
+// load reads the configuration after LoadFiles
+func load() (*Config, error) {
+	err := LoadFiles()
+	return fromEnv(), err
+}
 
-	cfg := &Config{
+// fromEnv reads the configuration from environment variables
+func fromEnv() *Config {
+	provider := getEnvWithDefault("LLM_PROVIDER", "openai")
+	model := getEnvWithDefault("OPENAI_MODEL", "gpt-3.5-turbo")
+	if provider == "ollama" {
+		model = getEnvWithDefault("OLLAMA_MODEL", endpoint.DefaultOllamaModel)
+	}
+
+	api, endpointErr := endpoint.FromEnv()
+
+	return &Config{
+		Provider:      provider,
 		OpenAIAPIKey:  getEnvWithDefault("OPENAI_API_KEY", ""),
-		Model:         getEnvWithDefault("OPENAI_MODEL", "gpt-3.5-turbo"),
+		Endpoint:      api,
+		endpointErr:   endpointErr,
+		Model:         model,
 		MaxTokens:     getEnvIntWithDefault("MAX_TOKENS", 150),
 		Temperature:   getEnvFloatWithDefault("TEMPERATURE", 0.7),
 		MaxHistory:    getEnvIntWithDefault("MAX_HISTORY", 10),
 		RetryAttempts: getEnvIntWithDefault("RETRY_ATTEMPTS", 3),
 		RetryDelay:    time.Duration(getEnvIntWithDefault("RETRY_DELAY_MS", 1000)) * time.Millisecond,
 		SaveDirectory: getEnvWithDefault("SAVE_DIRECTORY", "./data/conversations"),
-	}
+		Verbosity:     getEnvWithDefault("VERBOSITY", "normal"),
 
-	if cfg.OpenAIAPIKey == "" {
-		return nil, fmt.Errorf("OPENAI_API_KEY environment variable is required")
-	}
+		MonthlySpendLimit: getEnvFloatWithDefault("MONTHLY_SPEND_LIMIT_USD", 0),
+		SpendLedgerPath:   getEnvWithDefault("SPEND_LEDGER_PATH", "./data/spend_ledger.json"),
+		Budget:            getEnvWithDefault("BUDGET", ""),
+		BudgetDowngrades:  getEnvWithDefault("BUDGET_DOWNGRADES", ""),
+		CostLedgerPath:    getEnvWithDefault("COST_LEDGER_PATH", "./data/costs.json"),
+		PricingPath:       getEnvWithDefault("PRICING_PATH", ""),
 
-	return cfg, nil
+		SafetyPolicyFile: getEnvWithDefault("SAFETY_POLICY_FILE", ""),
+
+		JobsStatePath: getEnvWithDefault("JOBS_STATE_PATH", "./data/jobs.json"),
+
+		AnalyticsPath:          getEnvWithDefault("ANALYTICS_PATH", "./data/analytics.json"),
+		AnalyticsRetentionDays: getEnvIntWithDefault("ANALYTICS_RETENTION_DAYS", 30),
+		AnalyticsEpsilon:       getEnvFloatWithDefault("ANALYTICS_DP_EPSILON", 0),
+
+		TenantID:            getEnvWithDefault("TENANT_ID", "default"),
+		TenantDir:           getEnvWithDefault("TENANT_DIR", "./data/tenants"),
+		TenantMasterKey:     getEnvWithDefault("TENANT_MASTER_KEY", ""),
+		TenantMasterKeyFile: getEnvWithDefault("TENANT_MASTER_KEY_FILE", ""),
+
+		APIKeysFile:  getEnvWithDefault("OPENAI_API_KEYS_FILE", ""),
+		KeyUsagePath: getEnvWithDefault("KEY_USAGE_PATH", "./data/key_usage.json"),
+
+		MemoryWALPath:     getEnvWithDefault("MEMORY_WAL_PATH", ""),
+		MemoryTokenBudget: getEnvIntWithDefault("MEMORY_TOKEN_BUDGET", 0),
+
+		BusNATSURL: getEnvWithDefault("BUS_NATS_URL", ""),
+
+		EnableTools:    getEnvBoolWithDefault("ENABLE_TOOLS", false),
+		EnableWebTools: getEnvBoolWithDefault("ENABLE_WEB_TOOLS", false),
+
+		OllamaURL: getEnvWithDefault("OLLAMA_URL", endpoint.DefaultOllamaURL),
+		Sampling:  getEnvWithDefault("SAMPLING", ""),
+
+		ServerAddr:           getEnvWithDefault("SERVER_ADDR", "localhost:8080"),
+		ServerMaxInFlight:    getEnvIntWithDefault("SERVER_MAX_IN_FLIGHT", 8),
+		ServerRequestTimeout: time.Duration(getEnvIntWithDefault("SERVER_REQUEST_TIMEOUT_SECONDS", 60)) * time.Second,
+		SessionTTL:           time.Duration(getEnvIntWithDefault("SESSION_TTL_MINUTES", 30)) * time.Minute,
+		VectorStorePath:      getEnvWithDefault("VECTOR_STORE_PATH", "./data/vectors.json"),
+		OllamaEmbedModel:     getEnvWithDefault("OLLAMA_EMBED_MODEL", endpoint.DefaultOllamaEmbedModel),
+		RetrievalTopK:        getEnvIntWithDefault("RETRIEVAL_TOP_K", 4),
+		RetrievalMinScore:    getEnvFloatWithDefault("RETRIEVAL_MIN_SCORE", 0.8),
+	}
 }
 
 func getEnvWithDefault(key, defaultValue string) string {
@@ -68,3 +272,12 @@ func getEnvFloatWithDefault(key string, defaultValue float64) float64 {
 	}
 	return defaultValue
 }
+
+func getEnvBoolWithDefault(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
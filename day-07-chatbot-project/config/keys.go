@@ -0,0 +1,80 @@
+package config
+
+import "github.com/sakibmulla/agentic-ai/settings"
+
+// Keys lists the settings the chatbot reads, from the environment or the
+// config file, for validation and `chatbot config show`
+var Keys = settings.Schema{
+	{Name: "LLM_PROVIDER", Kind: settings.String},
+	{Name: "OPENAI_API_KEY", Kind: settings.String, Secret: true},
+	{Name: "OPENAI_API_KEYS_FILE", Kind: settings.String},
+	{Name: "OPENAI_API_TYPE", Kind: settings.String},
+	{Name: "OPENAI_BASE_URL", Kind: settings.String},
+	{Name: "OPENAI_API_VERSION", Kind: settings.String},
+	{Name: "OPENAI_DEPLOYMENT", Kind: settings.String},
+	{Name: "OPENAI_MODEL", Kind: settings.String},
+	{Name: "OLLAMA_URL", Kind: settings.String},
+	{Name: "OLLAMA_MODEL", Kind: settings.String},
+	{Name: "OLLAMA_EMBED_MODEL", Kind: settings.String},
+	{Name: "KEY_USAGE_PATH", Kind: settings.String},
+
+	{Name: "MAX_TOKENS", Kind: settings.Int},
+	{Name: "TEMPERATURE", Kind: settings.Float},
+	{Name: "SAMPLING", Kind: settings.String},
+	{Name: "MAX_HISTORY", Kind: settings.Int},
+	{Name: "MEMORY_TOKEN_BUDGET", Kind: settings.Int},
+	{Name: "VERBOSITY", Kind: settings.String},
+	{Name: "RETRY_ATTEMPTS", Kind: settings.Int},
+	{Name: "RETRY_DELAY_MS", Kind: settings.Int},
+
+	{Name: "SAVE_DIRECTORY", Kind: settings.String},
+	{Name: "MEMORY_WAL_PATH", Kind: settings.String},
+	{Name: "BUS_NATS_URL", Kind: settings.String},
+	{Name: "JOBS_STATE_PATH", Kind: settings.String},
+
+	{Name: "ENABLE_TOOLS", Kind: settings.Bool},
+	{Name: "ENABLE_WEB_TOOLS", Kind: settings.Bool},
+	{Name: "WEB_SEARCH_PROVIDER", Kind: settings.String},
+	{Name: "BING_API_KEY", Kind: settings.String, Secret: true},
+	{Name: "SERPAPI_API_KEY", Kind: settings.String, Secret: true},
+	{Name: "FETCH_ALLOW_DOMAINS", Kind: settings.String},
+	{Name: "FETCH_DENY_DOMAINS", Kind: settings.String},
+	{Name: "FETCH_MAX_BYTES", Kind: settings.Int},
+	{Name: "FETCH_TIMEOUT_SECONDS", Kind: settings.Int},
+
+	{Name: "MONTHLY_SPEND_LIMIT_USD", Kind: settings.Float},
+	{Name: "SPEND_LEDGER_PATH", Kind: settings.String},
+	{Name: "BUDGET", Kind: settings.String},
+	{Name: "BUDGET_DOWNGRADES", Kind: settings.String},
+	{Name: "COST_LEDGER_PATH", Kind: settings.String},
+	{Name: "PRICING_PATH", Kind: settings.String},
+
+	{Name: "LOG_LEVEL", Kind: settings.String},
+	{Name: "LOG_FORMAT", Kind: settings.String},
+
+	{Name: "SAFETY_POLICY_FILE", Kind: settings.String},
+	{Name: "GUARDRAILS_PII", Kind: settings.Bool},
+	{Name: "GUARDRAILS_INJECTION", Kind: settings.Bool},
+	{Name: "GUARDRAILS_MAX_INPUT_CHARS", Kind: settings.Int},
+	{Name: "GUARDRAILS_MODERATION", Kind: settings.Bool},
+	{Name: "GUARDRAILS_BANNED_TOPICS", Kind: settings.String},
+	{Name: "GUARDRAILS_PROFANITY", Kind: settings.Bool},
+	{Name: "GUARDRAILS_LOG", Kind: settings.String},
+
+	{Name: "ANALYTICS_PATH", Kind: settings.String},
+	{Name: "ANALYTICS_RETENTION_DAYS", Kind: settings.Int},
+	{Name: "ANALYTICS_DP_EPSILON", Kind: settings.Float},
+
+	{Name: "TENANT_ID", Kind: settings.String},
+	{Name: "TENANT_DIR", Kind: settings.String},
+	{Name: "TENANT_MASTER_KEY", Kind: settings.String, Secret: true},
+	{Name: "TENANT_MASTER_KEY_FILE", Kind: settings.String},
+
+	{Name: "SERVER_ADDR", Kind: settings.String},
+	{Name: "SERVER_MAX_IN_FLIGHT", Kind: settings.Int},
+	{Name: "SERVER_REQUEST_TIMEOUT_SECONDS", Kind: settings.Int},
+	{Name: "SESSION_TTL_MINUTES", Kind: settings.Int},
+	{Name: "VECTOR_STORE_PATH", Kind: settings.String},
+	{Name: "RETRIEVAL_TOP_K", Kind: settings.Int},
+	{Name: "RETRIEVAL_MIN_SCORE", Kind: settings.Float},
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package doctor
+
+import "errors"
+
+// freeSpace is not implemented on this platform
+func freeSpace(dir string) (uint64, error) {
+	return 0, errors.New("free space check not supported on this platform")
+}
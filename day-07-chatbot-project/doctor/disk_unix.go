@@ -0,0 +1,14 @@
+//go:build unix
+
+package doctor
+
+import "syscall"
+
+// freeSpace returns the bytes available to unprivileged users on dir's filesystem
+func freeSpace(dir string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, err
+	}
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
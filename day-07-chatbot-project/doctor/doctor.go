@@ -0,0 +1,411 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sakibmulla/agentic-ai/endpoint"
+	"github.com/sashabaranov/go-openai"
+
+	"chatbot/config"
+	"chatbot/llm"
+)
+
+// Status is the outcome of a single check
+type Status string
+
+const (
+	StatusOK   Status = "ok"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+)
+
+// minFreeBytes is the free space below which a data directory is flagged
+const minFreeBytes = 100 * 1024 * 1024
+
+// maxClockSkew is the drift beyond which request signing and logs get confusing
+const maxClockSkew = 30 * time.Second
+
+// Check is the result of one diagnostic, with a suggested fix when it did not pass
+type Check struct {
+	Name   string
+	Status Status
+	Detail string
+	Fix    string
+}
+
+// Run performs all environment checks. It never returns early, so a single
+// run reports every problem at once.
+func Run(ctx context.Context, cfg *config.Config) []Check {
+	var checks []Check
+	if cfg.Provider == llm.ProviderOllama {
+		ollamaCheck, models := checkOllama(ctx, cfg)
+		checks = append(checks, ollamaCheck, checkOllamaModel(cfg.Model, models))
+	} else {
+		checks = append(checks, checkNetwork(cfg.Endpoint))
+		apiCheck, models := checkAPIKey(ctx, cfg)
+		checks = append(checks, apiCheck, checkModel(cfg.Model, models))
+	}
+	checks = append(checks, checkSampling(cfg))
+
+	for _, dir := range dataDirectories(cfg) {
+		checks = append(checks, checkDisk(dir))
+	}
+
+	checks = append(checks, checkTokenizer(), checkClock(ctx, cfg.Endpoint))
+	return checks
+}
+
+// Print writes the checks with actionable fixes and returns the number of failures
+func Print(checks []Check) int {
+	failures := 0
+	for _, check := range checks {
+		icon := "✅"
+		switch check.Status {
+		case StatusWarn:
+			icon = "⚠️ "
+		case StatusFail:
+			icon = "❌"
+			failures++
+		}
+
+		fmt.Printf("%s %-22s %s\n", icon, check.Name, check.Detail)
+		if check.Fix != "" && check.Status != StatusOK {
+			fmt.Printf("   ↳ fix: %s\n", check.Fix)
+		}
+	}
+	return failures
+}
+
+// apiURL is the base URL of the API the chatbot talks to
+func apiURL(api endpoint.Endpoint) *url.URL {
+	if u, err := url.Parse(api.BaseURL); err == nil && api.BaseURL != "" {
+		return u
+	}
+	return &url.URL{Scheme: "https", Host: "api.openai.com", Path: "/v1"}
+}
+
+// checkNetwork verifies DNS resolution and a TCP connection to the API
+func checkNetwork(api endpoint.Endpoint) Check {
+	check := Check{Name: "Network"}
+
+	base := apiURL(api)
+	apiHost, port := base.Hostname(), base.Port()
+	if port == "" {
+		port = "443"
+		if base.Scheme == "http" {
+			port = "80"
+		}
+	}
+
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(apiHost, port), 5*time.Second)
+	if err != nil {
+		check.Status = StatusFail
+		check.Detail = fmt.Sprintf("cannot reach %s: %v", apiHost, err)
+		check.Fix = "check your internet connection, proxy (HTTPS_PROXY) and firewall settings"
+		return check
+	}
+	conn.Close()
+
+	check.Status = StatusOK
+	check.Detail = fmt.Sprintf("%s reachable in %s", apiHost, time.Since(start).Round(time.Millisecond))
+	return check
+}
+
+// checkAPIKey validates the key with a cheap model list call
+func checkAPIKey(ctx context.Context, cfg *config.Config) (Check, []string) {
+	check := Check{Name: "API key"}
+
+	if cfg.OpenAIAPIKey == "" && cfg.APIKeysFile == "" && cfg.Endpoint.RequiresKey() {
+		check.Status = StatusFail
+		check.Detail = "OPENAI_API_KEY is not set"
+		check.Fix = "add OPENAI_API_KEY=sk-... to .env (see .env.example)"
+		return check, nil
+	}
+
+	var client *llm.Client
+	var err error
+	if cfg.APIKeysFile != "" {
+		var keys *llm.KeyPool
+		if keys, err = llm.LoadKeyPool(cfg.APIKeysFile, ""); err == nil {
+			client = llm.NewPooledClient(keys, cfg.Model)
+		}
+	} else {
+		client, err = llm.NewClient(cfg.OpenAIAPIKey, cfg.Endpoint, cfg.Model)
+	}
+	if err != nil {
+		check.Status = StatusFail
+		check.Detail = err.Error()
+		return check, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	models, err := client.ListModels(ctx)
+	if err != nil {
+		check.Status = StatusFail
+		check.Detail = err.Error()
+
+		var apiErr *openai.APIError
+		if errors.As(err, &apiErr) && apiErr.HTTPStatusCode == http.StatusUnauthorized {
+			check.Detail = "the API rejected the key (401)"
+			check.Fix = "create a new key at https://platform.openai.com/api-keys and update .env"
+		} else {
+			check.Fix = "see the Network check; if it passed, retry later or check the provider status page"
+		}
+		return check, nil
+	}
+
+	check.Status = StatusOK
+	check.Detail = fmt.Sprintf("valid (%d models available)", len(models))
+	return check, models
+}
+
+// checkModel compares the configured model with the registry and the account's models
+func checkModel(model string, available []string) Check {
+	check := Check{Name: "Model"}
+
+	if available != nil {
+		found := false
+		for _, id := range available {
+			if id == model {
+				found = true
+				break
+			}
+		}
+		if !found {
+			check.Status = StatusFail
+			check.Detail = fmt.Sprintf("%s is not available to this API key", model)
+			check.Fix = "set OPENAI_MODEL to one of the models your account can use (e.g. gpt-3.5-turbo)"
+			return check
+		}
+	}
+
+	if !llm.IsKnownModel(model) {
+		check.Status = StatusWarn
+		check.Detail = fmt.Sprintf("%s is not in the pricing registry; cost estimates fall back to gpt-3.5-turbo prices", model)
+		check.Fix = "add the model's prices to the file at PRICING_PATH for accurate spend tracking"
+		return check
+	}
+
+	check.Status = StatusOK
+	check.Detail = model
+	if available == nil {
+		check.Status = StatusWarn
+		check.Detail = model + " is in the registry, but availability could not be confirmed"
+		check.Fix = "fix the API key check to confirm model access"
+	}
+	return check
+}
+
+// checkOllama verifies the Ollama server answers and lists its models
+func checkOllama(ctx context.Context, cfg *config.Config) (Check, []string) {
+	check := Check{Name: "Ollama"}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	api, err := cfg.OllamaEndpoint()
+	if err != nil {
+		check.Status = StatusFail
+		check.Detail = err.Error()
+		check.Fix = "set OLLAMA_URL to your server, e.g. " + endpoint.DefaultOllamaURL
+		return check, nil
+	}
+	models, err := api.OllamaModels(ctx)
+	if err != nil {
+		check.Status = StatusFail
+		check.Detail = err.Error()
+		check.Fix = "start Ollama (ollama serve) or point OLLAMA_URL at your server"
+		return check, nil
+	}
+
+	check.Status = StatusOK
+	check.Detail = fmt.Sprintf("%s reachable (%d models pulled)", cfg.OllamaURL, len(models))
+	return check, models
+}
+
+// checkOllamaModel checks the configured model has been pulled. Ollama
+// reads a model without a tag as its latest tag.
+func checkOllamaModel(model string, available []string) Check {
+	check := Check{Name: "Model"}
+	if available == nil {
+		check.Status = StatusWarn
+		check.Detail = model + " could not be confirmed"
+		check.Fix = "fix the Ollama check to confirm the model is pulled"
+		return check
+	}
+
+	tagged := model
+	if !strings.Contains(model, ":") {
+		tagged += ":latest"
+	}
+	for _, name := range available {
+		if name == model || name == tagged {
+			check.Status = StatusOK
+			check.Detail = model
+			return check
+		}
+	}
+
+	check.Status = StatusFail
+	check.Detail = fmt.Sprintf("%s has not been pulled", model)
+	check.Fix = fmt.Sprintf("run: ollama pull %s", model)
+	return check
+}
+
+// checkSampling validates SAMPLING against what the model supports
+func checkSampling(cfg *config.Config) Check {
+	check := Check{Name: "Sampling"}
+
+	sampling, err := llm.ParseSampling(cfg.Sampling)
+	if err == nil {
+		err = sampling.Validate(llm.GetCapabilities(cfg.Provider, cfg.Model))
+	}
+	if err != nil {
+		check.Status = StatusFail
+		check.Detail = err.Error()
+		check.Fix = "fix SAMPLING in .env, e.g. SAMPLING=top_p=0.9 (top_k, min_p and repeat_penalty need the ollama provider)"
+		return check
+	}
+
+	check.Status = StatusOK
+	check.Detail = "provider defaults"
+	if cfg.Sampling != "" {
+		check.Detail = sampling.String()
+	}
+	return check
+}
+
+// dataDirectories returns the directories the chatbot writes to
+func dataDirectories(cfg *config.Config) []string {
+	dirs := []string{cfg.SaveDirectory}
+	seen := map[string]bool{filepath.Clean(cfg.SaveDirectory): true}
+
+	for _, path := range []string{cfg.SpendLedgerPath, cfg.JobsStatePath, cfg.AnalyticsPath} {
+		if path == "" {
+			continue
+		}
+		dir := filepath.Clean(filepath.Dir(path))
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// checkDisk verifies a data directory is writable and has free space
+func checkDisk(dir string) Check {
+	check := Check{Name: "Disk " + dir}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		check.Status = StatusFail
+		check.Detail = fmt.Sprintf("cannot create directory: %v", err)
+		check.Fix = "create the directory or point the setting at a writable location"
+		return check
+	}
+
+	probe, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		check.Status = StatusFail
+		check.Detail = fmt.Sprintf("directory is not writable: %v", err)
+		check.Fix = "fix the directory permissions (chmod/chown) or change the path in .env"
+		return check
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	free, err := freeSpace(dir)
+	if err != nil {
+		check.Status = StatusWarn
+		check.Detail = "writable, free space unknown"
+		return check
+	}
+
+	check.Detail = fmt.Sprintf("writable, %s free", formatBytes(free))
+	check.Status = StatusOK
+	if free < minFreeBytes {
+		check.Status = StatusWarn
+		check.Fix = "free up disk space; conversations and job state will fail to save when the disk is full"
+	}
+	return check
+}
+
+// checkTokenizer reports how token counts are computed
+func checkTokenizer() Check {
+	return Check{
+		Name:   "Tokenizer",
+		Status: StatusOK,
+		Detail: "built-in estimator (~4 chars/token); billed usage comes from API responses",
+	}
+}
+
+// checkClock compares the local clock with the API server's Date header
+func checkClock(ctx context.Context, api endpoint.Endpoint) Check {
+	check := Check{Name: "Clock"}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, apiURL(api).JoinPath("models").String(), nil)
+	if err != nil {
+		check.Status = StatusWarn
+		check.Detail = err.Error()
+		return check
+	}
+
+	sent := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		check.Status = StatusWarn
+		check.Detail = "could not fetch server time"
+		check.Fix = "see the Network check"
+		return check
+	}
+	resp.Body.Close()
+
+	serverTime, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		check.Status = StatusWarn
+		check.Detail = "server did not return a usable Date header"
+		return check
+	}
+
+	// Compare against the midpoint of the request; Date has 1s resolution
+	local := sent.Add(time.Since(sent) / 2)
+	skew := local.Sub(serverTime).Round(time.Second)
+	if skew < 0 {
+		skew = -skew
+	}
+
+	check.Detail = fmt.Sprintf("skew %s", skew)
+	check.Status = StatusOK
+	if skew > maxClockSkew {
+		check.Status = StatusWarn
+		check.Fix = "enable time synchronisation (e.g. timedatectl set-ntp true); monthly spend and analytics buckets use the local clock"
+	}
+	return check
+}
+
+// formatBytes renders a byte count in human units
+func formatBytes(n uint64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	value := float64(n)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", value), ".0") + " " + units[unit]
+}
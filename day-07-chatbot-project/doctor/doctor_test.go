@@ -0,0 +1,100 @@
+package doctor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"chatbot/config"
+)
+
+func TestCheckModel(t *testing.T) {
+	tests := []struct {
+		name      string
+		model     string
+		available []string
+		status    Status
+		detail    string
+	}{
+		{"available and priced", "gpt-3.5-turbo", []string{"gpt-4o", "gpt-3.5-turbo"}, StatusOK, "gpt-3.5-turbo"},
+		{"not on the account", "gpt-4o", []string{"gpt-3.5-turbo"}, StatusFail, "not available to this API key"},
+		{"no prices", "ft:gpt-3.5-turbo:acme", []string{"ft:gpt-3.5-turbo:acme"}, StatusWarn, "not in the pricing registry"},
+		{"unconfirmed", "gpt-3.5-turbo", nil, StatusWarn, "could not be confirmed"},
+		// An empty list means the account has no models, not that it is unknown
+		{"empty list", "gpt-3.5-turbo", []string{}, StatusFail, "not available"},
+	}
+	for _, tt := range tests {
+		check := checkModel(tt.model, tt.available)
+		if check.Status != tt.status || !strings.Contains(check.Detail, tt.detail) {
+			t.Errorf("%s: expected %s containing %q, got %s %q", tt.name, tt.status, tt.detail, check.Status, check.Detail)
+		}
+		if check.Status != StatusOK && check.Fix == "" {
+			t.Errorf("%s: expected a fix", tt.name)
+		}
+	}
+}
+
+func TestCheckOllamaModel(t *testing.T) {
+	pulled := []string{"llama3.2:latest", "qwen2.5:7b"}
+	for model, status := range map[string]Status{
+		"llama3.2":        StatusOK,
+		"llama3.2:latest": StatusOK,
+		"qwen2.5:7b":      StatusOK,
+		"qwen2.5":         StatusFail,
+	} {
+		if check := checkOllamaModel(model, pulled); check.Status != status {
+			t.Errorf("%s: expected %s, got %s %q", model, status, check.Status, check.Detail)
+		}
+	}
+	if check := checkOllamaModel("llama3.2", nil); check.Status != StatusWarn {
+		t.Errorf("Expected an unconfirmed model to warn, got %s", check.Status)
+	}
+}
+
+func TestDataDirectories(t *testing.T) {
+	cfg := &config.Config{
+		SaveDirectory:   "data/conversations",
+		SpendLedgerPath: "data/spend.json",
+		JobsStatePath:   "data/./jobs.json",
+		AnalyticsPath:   "data/conversations/../analytics.db",
+	}
+	dirs := dataDirectories(cfg)
+	if strings.Join(dirs, ",") != "data/conversations,data" {
+		t.Errorf("Expected each directory once, save directory first, got %v", dirs)
+	}
+
+	// Unset paths are skipped
+	if dirs := dataDirectories(&config.Config{SaveDirectory: "saves", JobsStatePath: "state/jobs.json"}); strings.Join(dirs, ",") != "saves,state" {
+		t.Errorf("Unexpected directories %v", dirs)
+	}
+}
+
+func TestCheckDisk(t *testing.T) {
+	// A missing directory is created, and the probe file cleaned up
+	dir := filepath.Join(t.TempDir(), "new", "data")
+	check := checkDisk(dir)
+	if check.Status == StatusFail || !strings.HasPrefix(check.Detail, "writable") || check.Name != "Disk "+dir {
+		t.Errorf("Expected a writable directory, got %+v", check)
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+		t.Errorf("Expected the directory created and left empty, got %v %v", entries, err)
+	}
+
+	// A path under a file can't be created
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if check := checkDisk(filepath.Join(file, "data")); check.Status != StatusFail || check.Fix == "" {
+		t.Errorf("Expected a failure with a fix, got %+v", check)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[uint64]string{0: "0 B", 1023: "1023 B", 1024: "1 KB", 1536: "1.5 KB", 100 << 20: "100 MB", 3 << 40: "3 TB"} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, expected %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sakibmulla/agentic-ai/persist"
+
+	"chatbot/bus"
+)
+
+// Status is the lifecycle state of a job
+type Status string
+
+const (
+	StatusPending     Status = "pending"
+	StatusRunning     Status = "running"
+	StatusSucceeded   Status = "succeeded"
+	StatusFailed      Status = "failed"
+	StatusCancelled   Status = "cancelled"
+	StatusInterrupted Status = "interrupted" // the process exited while the job was running
+)
+
+// maxLogLines bounds the log kept for each job
+const maxLogLines = 500
+
+// Job is a long-running operation tracked by the Manager
+type Job struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Status     Status    `json:"status"`
+	Progress   float64   `json:"progress"` // 0.0 - 1.0
+	Logs       []string  `json:"logs"`
+	Error      string    `json:"error,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	StartedAt  time.Time `json:"started_at,omitempty"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+}
+
+// Done reports whether the job has reached a terminal state
+func (j Job) Done() bool {
+	switch j.Status {
+	case StatusSucceeded, StatusFailed, StatusCancelled, StatusInterrupted:
+		return true
+	default:
+		return false
+	}
+}
+
+// Func is the work performed by a job. It should honour ctx cancellation
+// and report progress through the Reporter.
+type Func func(ctx context.Context, r *Reporter) error
+
+// Reporter lets a running job publish progress and log lines
+type Reporter struct {
+	manager *Manager
+	id      string
+}
+
+// Progress sets the completion fraction (clamped to 0-1)
+func (r *Reporter) Progress(fraction float64) {
+	if fraction < 0 {
+		fraction = 0
+	} else if fraction > 1 {
+		fraction = 1
+	}
+	r.manager.update(r.id, func(job *Job) {
+		job.Progress = fraction
+	})
+}
+
+// Logf appends a formatted line to the job log
+func (r *Reporter) Logf(format string, args ...interface{}) {
+	line := fmt.Sprintf("%s %s", time.Now().Format("15:04:05"), fmt.Sprintf(format, args...))
+	r.manager.update(r.id, func(job *Job) {
+		job.Logs = append(job.Logs, line)
+		if len(job.Logs) > maxLogLines {
+			job.Logs = job.Logs[len(job.Logs)-maxLogLines:]
+		}
+	})
+	r.manager.publish(r.id, line)
+}
+
+// stateFormat versions the job state file
+var stateFormat = persist.NewFormat("jobs", 1)
+
+// Manager runs jobs in the background and persists their state
+type Manager struct {
+	path        string
+	jobs        map[string]*Job
+	cancels     map[string]context.CancelFunc
+	subscribers map[string][]chan string
+	nextID      int
+	events      bus.Bus
+	mu          sync.Mutex
+}
+
+// NewManager creates a job manager persisting to path. Jobs that were
+// running when the previous process exited are marked interrupted.
+func NewManager(path string) (*Manager, error) {
+	m := &Manager{
+		path:        path,
+		jobs:        make(map[string]*Job),
+		cancels:     make(map[string]context.CancelFunc),
+		subscribers: make(map[string][]chan string),
+	}
+
+	var saved []*Job
+	err := stateFormat.ReadFile(path, &saved)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to load job state: %w", err)
+	}
+	if err == nil {
+		for _, job := range saved {
+			if !job.Done() {
+				job.Status = StatusInterrupted
+				job.FinishedAt = time.Now()
+			}
+			m.jobs[job.ID] = job
+			m.nextID++
+		}
+	}
+
+	return m, nil
+}
+
+// SetBus publishes job submissions and outcomes on events
+func (m *Manager) SetBus(events bus.Bus) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = events
+}
+
+// announce publishes a job snapshot on the bus, if one is set
+func (m *Manager) announce(topic, id string) {
+	m.mu.Lock()
+	events := m.events
+	job, ok := m.jobs[id]
+	var copied Job
+	if ok {
+		copied = snapshot(job)
+	}
+	m.mu.Unlock()
+
+	if events != nil && ok {
+		events.Publish(topic, copied)
+	}
+}
+
+// Submit starts fn in the background and returns the new job's ID
+func (m *Manager) Submit(name string, fn Func) string {
+	m.mu.Lock()
+	m.nextID++
+	id := fmt.Sprintf("job-%d-%d", time.Now().Unix(), m.nextID)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	m.jobs[id] = &Job{
+		ID:        id,
+		Name:      name,
+		Status:    StatusPending,
+		CreatedAt: time.Now(),
+	}
+	m.cancels[id] = cancel
+	m.saveLocked()
+	m.mu.Unlock()
+
+	m.announce(bus.TopicJobSubmitted, id)
+	go m.run(ctx, id, fn)
+	return id
+}
+
+// run executes a job and records its outcome
+func (m *Manager) run(ctx context.Context, id string, fn Func) {
+	m.update(id, func(job *Job) {
+		job.Status = StatusRunning
+		job.StartedAt = time.Now()
+	})
+
+	err := fn(ctx, &Reporter{manager: m, id: id})
+
+	m.update(id, func(job *Job) {
+		job.FinishedAt = time.Now()
+		switch {
+		case ctx.Err() != nil:
+			job.Status = StatusCancelled
+		case err != nil:
+			job.Status = StatusFailed
+			job.Error = err.Error()
+		default:
+			job.Status = StatusSucceeded
+			job.Progress = 1
+		}
+	})
+
+	m.mu.Lock()
+	if cancel, ok := m.cancels[id]; ok {
+		cancel()
+		delete(m.cancels, id)
+	}
+	for _, ch := range m.subscribers[id] {
+		close(ch)
+	}
+	delete(m.subscribers, id)
+	m.mu.Unlock()
+
+	m.announce(bus.TopicJobFinished, id)
+}
+
+// Get returns a snapshot of a job
+func (m *Manager) Get(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, fmt.Errorf("job %s not found", id)
+	}
+	return snapshot(job), nil
+}
+
+// List returns all jobs, newest first
+func (m *Manager) List() []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	jobs := make([]Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		jobs = append(jobs, snapshot(job))
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
+	})
+	return jobs
+}
+
+// Cancel requests cancellation of a running job
+func (m *Manager) Cancel(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return fmt.Errorf("job %s not found", id)
+	}
+	if job.Done() {
+		return fmt.Errorf("job %s already %s", id, job.Status)
+	}
+
+	if cancel, ok := m.cancels[id]; ok {
+		cancel()
+	}
+	return nil
+}
+
+// Subscribe streams new log lines of a job until it finishes. The returned
+// channel is closed when the job is done (immediately if it already is).
+func (m *Manager) Subscribe(id string) (<-chan string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("job %s not found", id)
+	}
+
+	ch := make(chan string, 64)
+	if job.Done() {
+		close(ch)
+		return ch, nil
+	}
+	m.subscribers[id] = append(m.subscribers[id], ch)
+	return ch, nil
+}
+
+// update applies fn to a job under the lock and persists the result
+func (m *Manager) update(id string, fn func(job *Job)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if job, ok := m.jobs[id]; ok {
+		fn(job)
+		m.saveLocked()
+	}
+}
+
+// publish sends a log line to subscribers without blocking the job
+func (m *Manager) publish(id, line string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, ch := range m.subscribers[id] {
+		select {
+		case ch <- line:
+		default:
+			// Slow subscriber; the line is still in the job log
+		}
+	}
+}
+
+// saveLocked writes all jobs to disk. Callers must hold m.mu.
+func (m *Manager) saveLocked() {
+	if m.path == "" {
+		return
+	}
+
+	jobs := make([]*Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		jobs = append(jobs, job)
+	}
+
+	stateFormat.WriteFile(m.path, jobs, 0644)
+}
+
+// snapshot copies a job so callers can't race with the runner
+func snapshot(job *Job) Job {
+	copied := *job
+	copied.Logs = append([]string(nil), job.Logs...)
+	return copied
+}
@@ -0,0 +1,63 @@
+package llm
+
+import "strings"
+
+// Providers the client can talk to
+const (
+	ProviderOpenAI = "openai"
+	ProviderOllama = "ollama"
+)
+
+// ModelCapabilities describes what a model accepts
+type ModelCapabilities struct {
+	Provider string
+	// Sampling lists the sampling options honoured beyond temperature
+	Sampling []string
+}
+
+// Supports reports whether the model honours a sampling option
+func (m ModelCapabilities) Supports(option string) bool {
+	for _, supported := range m.Sampling {
+		if supported == option {
+			return true
+		}
+	}
+	return false
+}
+
+// ProviderDefaults are the capabilities of models a provider serves that
+// aren't in ModelRegistry. Ollama passes its options to llama.cpp, so every
+// model it serves takes the full set.
+var ProviderDefaults = map[string]ModelCapabilities{
+	ProviderOpenAI: {Provider: ProviderOpenAI, Sampling: []string{SamplingTopP}},
+	ProviderOllama: {Provider: ProviderOllama, Sampling: []string{SamplingTopP, SamplingTopK, SamplingMinP, SamplingRepeatPenalty}},
+}
+
+// ModelRegistry lists models whose capabilities differ from, or should be
+// pinned apart from, their provider's defaults
+var ModelRegistry = map[string]ModelCapabilities{
+	"gpt-3.5-turbo": ProviderDefaults[ProviderOpenAI],
+	"gpt-4":         ProviderDefaults[ProviderOpenAI],
+	"gpt-4-turbo":   ProviderDefaults[ProviderOpenAI],
+	"gpt-4o":        ProviderDefaults[ProviderOpenAI],
+	"gpt-4o-mini":   ProviderDefaults[ProviderOpenAI],
+	// Reasoning models fix their sampling
+	"o1": {Provider: ProviderOpenAI},
+	"o3": {Provider: ProviderOpenAI},
+}
+
+// GetCapabilities returns the capabilities of a provider's model, matching
+// dated variants (gpt-4-0613) and tags (llama3.1:8b) by their longest known
+// prefix. Models that aren't registered get the provider's defaults.
+func GetCapabilities(provider, model string) ModelCapabilities {
+	best := ""
+	for name, capabilities := range ModelRegistry {
+		if capabilities.Provider == provider && strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best != "" {
+		return ModelRegistry[best]
+	}
+	return ProviderDefaults[provider]
+}
@@ -3,52 +3,248 @@ package llm
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"net/http"
 
+	"github.com/sakibmulla/agentic-ai/costs"
+	"github.com/sakibmulla/agentic-ai/endpoint"
 	"github.com/sashabaranov/go-openai"
 )
 
-// Client wraps the OpenAI client with additional functionality
+// Client wraps the OpenAI client with additional functionality. It can also
+// talk to models served by Ollama (see NewOllamaClient).
 type Client struct {
-	client *openai.Client
-	model  string
+	keys       *KeyPool
+	model      string
+	provider   string
+	spendGuard *SpendGuard
+	// costs budgets calls by user, session and mode and keeps their ledger
+	costs *costs.Limiter
+	// sampling is sent with every request; see WithSampling
+	sampling Sampling
+	// structuredRepairs overrides DefaultStructuredRepairs when set
+	structuredRepairs *int
+
+	// ollama and http are set for the Ollama provider
+	ollama endpoint.Endpoint
+	http   *http.Client
 }
 
-// NewClient creates a new LLM client
-func NewClient(apiKey, model string) (*Client, error) {
-	if apiKey == "" {
-		return nil, fmt.Errorf("API key is required")
+// NewClient creates a new LLM client that calls api: OpenAI, Azure OpenAI
+// or an OpenAI-compatible server
+func NewClient(apiKey string, api endpoint.Endpoint, model string) (*Client, error) {
+	keys, err := NewKeyPool(apiKey, api)
+	if err != nil {
+		return nil, err
 	}
+	return NewPooledClient(keys, model), nil
+}
 
+// NewPooledClient creates a client that spreads calls across a pool of API keys
+func NewPooledClient(keys *KeyPool, model string) *Client {
 	if model == "" {
 		model = openai.GPT3Dot5Turbo
 	}
 
-	client := openai.NewClient(apiKey)
-
 	return &Client{
-		client: client,
-		model:  model,
-	}, nil
+		keys:     keys,
+		model:    model,
+		provider: ProviderOpenAI,
+	}
+}
+
+// Keys returns the client's API key pool
+func (c *Client) Keys() *KeyPool {
+	return c.keys
+}
+
+// SetSpendGuard enables monthly spend enforcement for this client
+func (c *Client) SetSpendGuard(guard *SpendGuard) {
+	c.spendGuard = guard
+}
+
+// GetSpendGuard returns the client's spend guard, if any
+func (c *Client) GetSpendGuard() *SpendGuard {
+	return c.spendGuard
+}
+
+// SetCostLimiter budgets this client's calls and records what they cost.
+// Calls are attributed with costs.WithAttribution on their context.
+func (c *Client) SetCostLimiter(limiter *costs.Limiter) {
+	c.costs = limiter
+}
+
+// CostLimiter returns the client's cost limiter, if any
+func (c *Client) CostLimiter() *costs.Limiter {
+	return c.costs
 }
 
 // ChatCompletion sends a chat completion request to OpenAI
 func (c *Client) ChatCompletion(ctx context.Context, messages []openai.ChatCompletionMessage, maxTokens int, temperature float64) (*openai.ChatCompletionResponse, error) {
-	req := openai.ChatCompletionRequest{
+	return c.complete(ctx, openai.ChatCompletionRequest{
 		Model:       c.model,
 		Messages:    messages,
 		MaxTokens:   maxTokens,
 		Temperature: float32(temperature),
+	})
+}
+
+// ChatCompletionWithFunctions sends a chat completion request declaring
+// functions the model may call instead of answering
+func (c *Client) ChatCompletionWithFunctions(ctx context.Context, messages []openai.ChatCompletionMessage, functions []openai.FunctionDefinition, maxTokens int, temperature float64) (*openai.ChatCompletionResponse, error) {
+	return c.complete(ctx, openai.ChatCompletionRequest{
+		Model:       c.model,
+		Messages:    messages,
+		Functions:   functions,
+		MaxTokens:   maxTokens,
+		Temperature: float32(temperature),
+	})
+}
+
+// complete sends req through the key pool, enforcing and recording spend.
+// The cost limiter may swap the model for a cheaper one.
+func (c *Client) complete(ctx context.Context, req openai.ChatCompletionRequest) (*openai.ChatCompletionResponse, error) {
+	if c.costs != nil {
+		model, err := c.costs.Admit(ctx, req.Model)
+		if err != nil {
+			return nil, err
+		}
+		req.Model = model
 	}
 
-	resp, err := c.client.CreateChatCompletion(ctx, req)
-	if err != nil {
-		return nil, fmt.Errorf("chat completion failed: %w", err)
+	if c.provider == ProviderOllama {
+		resp, err := c.completeOllama(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		c.recordCost(ctx, req.Model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+		return resp, nil
+	}
+	req.TopP = float32(c.sampling.TopP)
+
+	if c.spendGuard != nil {
+		if err := c.spendGuard.Allow(c.provider); err != nil {
+			return nil, err
+		}
 	}
 
+	var resp openai.ChatCompletionResponse
+	err := c.pooled(ctx, "chat completion", func(client *openai.Client) (int, float64, error) {
+		var err error
+		resp, err = client.CreateChatCompletion(ctx, req)
+		return resp.Usage.TotalTokens, EstimateCost(req.Model, resp.Usage), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.recordCost(ctx, req.Model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
 	return &resp, nil
 }
 
+// recordCost adds a call to the cost limiter's ledger, if there is one. The
+// call has already been paid for, so a failure is logged rather than
+// returned in place of its result.
+func (c *Client) recordCost(ctx context.Context, model string, promptTokens, completionTokens int) {
+	if c.costs == nil {
+		return
+	}
+	if _, err := c.costs.Record(ctx, model, promptTokens, completionTokens); err != nil {
+		slog.WarnContext(ctx, "failed to record cost", "model", model, "error", err)
+	}
+}
+
+// pooled makes call with a key from the pool and records its tokens and
+// cost against the key and the spend guard. Each key is tried at most once;
+// a key rejected for quota, auth or server reasons is rested and the call
+// fails over to the next one.
+func (c *Client) pooled(ctx context.Context, operation string, call func(client *openai.Client) (int, float64, error)) error {
+	var lastErr error
+	for attempt := 0; attempt < c.keys.Size(); attempt++ {
+		key, err := c.keys.acquire()
+		if err != nil {
+			if lastErr != nil {
+				return fmt.Errorf("%s failed: %w", operation, lastErr)
+			}
+			return err
+		}
+
+		tokens, cost, err := call(key.client)
+		if err != nil {
+			lastErr = err
+			if c.keys.reportFailure(key, err) && ctx.Err() == nil {
+				continue
+			}
+			return fmt.Errorf("%s failed: %w", operation, err)
+		}
+
+		// The call has been paid for, so its result is returned even when
+		// its spend can't be recorded
+		if err := c.keys.reportSuccess(key, tokens, cost); err != nil {
+			slog.WarnContext(ctx, "failed to record key spend", "operation", operation, "error", err)
+		}
+		if c.spendGuard != nil {
+			if err := c.spendGuard.Record(c.provider, cost); err != nil {
+				slog.WarnContext(ctx, "failed to record spend", "operation", operation, "cost_usd", cost, "error", err)
+			}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("%s failed: %w", operation, lastErr)
+}
+
 // GetModel returns the current model being used
 func (c *Client) GetModel() string {
 	return c.model
 }
+
+// Provider returns the name of the provider serving the model
+func (c *Client) Provider() string {
+	return c.provider
+}
+
+// Capabilities returns what the client's model accepts
+func (c *Client) Capabilities() ModelCapabilities {
+	return GetCapabilities(c.provider, c.model)
+}
+
+// Sampling returns the sampling options sent with each request
+func (c *Client) Sampling() Sampling {
+	return c.sampling
+}
+
+// WithSampling returns a copy of the client that sends sampling with each
+// request, sharing its keys and spend guard. It fails if the model doesn't
+// honour an option.
+func (c *Client) WithSampling(sampling Sampling) (*Client, error) {
+	if err := sampling.Validate(c.Capabilities()); err != nil {
+		return nil, fmt.Errorf("invalid sampling for %s: %w", c.model, err)
+	}
+	copied := *c
+	copied.sampling = sampling
+	return &copied, nil
+}
+
+// ListModels returns the IDs of the models available to the API key, or
+// pulled into the Ollama server
+func (c *Client) ListModels(ctx context.Context) ([]string, error) {
+	if c.provider == ProviderOllama {
+		return c.ollama.OllamaModels(ctx)
+	}
+
+	key, err := c.keys.acquire()
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := key.client.ListModels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list models: %w", err)
+	}
+
+	ids := make([]string, 0, len(list.Models))
+	for _, model := range list.Models {
+		ids = append(ids, model.ID)
+	}
+	return ids, nil
+}
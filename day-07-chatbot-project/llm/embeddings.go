@@ -0,0 +1,96 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/sakibmulla/agentic-ai/costs"
+	"github.com/sashabaranov/go-openai"
+)
+
+// OpenAIEmbeddingModel is the model OpenAI texts are embedded with
+const OpenAIEmbeddingModel = "text-embedding-ada-002"
+
+// embedBatchSize is how many texts go into one embedding request
+const embedBatchSize = 64
+
+// Embedder turns texts into vectors with the same provider, keys and spend
+// guard as the client it came from, so a local setup never calls out to
+// OpenAI
+type Embedder struct {
+	client *Client
+	model  string
+}
+
+// Embedder returns an embedder for the client's provider. OpenAI embeds
+// with OpenAIEmbeddingModel; ollamaModel names the model Ollama embeds with.
+func (c *Client) Embedder(ollamaModel string) *Embedder {
+	model := OpenAIEmbeddingModel
+	if c.provider == ProviderOllama {
+		model = ollamaModel
+	}
+	return &Embedder{client: c, model: model}
+}
+
+// Model names the embedding model; vectors from different models can't be
+// compared
+func (e *Embedder) Model() string {
+	return e.model
+}
+
+// Embed returns one vector per text, in order
+func (e *Embedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if limiter := e.client.costs; limiter != nil {
+		if err := limiter.Check(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if e.client.provider == ProviderOllama {
+		return e.embedOllama(ctx, texts)
+	}
+	if guard := e.client.spendGuard; guard != nil {
+		if err := guard.Allow(e.client.provider); err != nil {
+			return nil, err
+		}
+	}
+
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += embedBatchSize {
+		end := min(start+embedBatchSize, len(texts))
+		var resp openai.EmbeddingResponse
+		err := e.client.pooled(ctx, "embedding", func(client *openai.Client) (int, float64, error) {
+			var err error
+			resp, err = client.CreateEmbeddings(ctx, openai.EmbeddingRequest{Input: texts[start:end], Model: openai.AdaEmbeddingV2})
+			return resp.Usage.TotalTokens, costs.Cost(e.model, resp.Usage.TotalTokens, 0), err
+		})
+		if err != nil {
+			return nil, err
+		}
+		e.client.recordCost(ctx, e.model, resp.Usage.TotalTokens, 0)
+		if len(resp.Data) != end-start {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", end-start, len(resp.Data))
+		}
+		for _, data := range resp.Data {
+			vectors = append(vectors, data.Embedding)
+		}
+	}
+	return vectors, nil
+}
+
+// embedOllama embeds texts with Ollama's /api/embed
+func (e *Embedder) embedOllama(ctx context.Context, texts []string) ([][]float32, error) {
+	var resp struct {
+		Embeddings      [][]float32 `json:"embeddings"`
+		PromptEvalCount int         `json:"prompt_eval_count"`
+	}
+	body := map[string]interface{}{"model": e.model, "input": texts}
+	if err := e.client.ollamaCall(ctx, http.MethodPost, "/api/embed", body, &resp); err != nil {
+		return nil, fmt.Errorf("embedding failed: %w", err)
+	}
+	if len(resp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(resp.Embeddings))
+	}
+	e.client.recordCost(ctx, e.model, resp.PromptEvalCount, 0)
+	return resp.Embeddings, nil
+}
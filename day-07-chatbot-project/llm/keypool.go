@@ -0,0 +1,452 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sakibmulla/agentic-ai/endpoint"
+	"github.com/sakibmulla/agentic-ai/persist"
+	"github.com/sashabaranov/go-openai"
+
+	"chatbot/bus"
+)
+
+// ErrNoKeyAvailable is returned when every key is rate limited or cooling down
+var ErrNoKeyAvailable = errors.New("no API key available")
+
+// Key selection strategies
+const (
+	StrategyWeighted = "weighted"
+	StrategyFailover = "failover"
+)
+
+// keyCooldown is how long a key is skipped after a quota, auth or server error
+const keyCooldown = time.Minute
+
+// KeySpec configures one provider API key
+type KeySpec struct {
+	Name string `json:"name"`
+	Key  string `json:"key,omitempty"`
+	// KeyEnv names an environment variable holding the key, to keep secrets out of the file
+	KeyEnv string `json:"key_env,omitempty"`
+	// Weight is the share of traffic under the weighted strategy (default 1)
+	Weight int `json:"weight"`
+	// RequestsPerMinute caps calls made with this key; 0 means unlimited
+	RequestsPerMinute int `json:"requests_per_minute"`
+	// BaseURL overrides the API endpoint, e.g. for a proxy, a vLLM server
+	// or an Azure OpenAI resource
+	BaseURL string `json:"base_url,omitempty"`
+	// APIType is "openai" (the default) or "azure"; APIVersion and
+	// Deployment apply to Azure, as OPENAI_API_VERSION and
+	// OPENAI_DEPLOYMENT do
+	APIType    string `json:"api_type,omitempty"`
+	APIVersion string `json:"api_version,omitempty"`
+	Deployment string `json:"deployment,omitempty"`
+}
+
+// Endpoint returns the API the key is used with
+func (s KeySpec) Endpoint() (endpoint.Endpoint, error) {
+	return endpoint.Parse(s.APIType, s.BaseURL, s.APIVersion, s.Deployment)
+}
+
+// keysFile is the format of OPENAI_API_KEYS_FILE
+type keysFile struct {
+	Strategy string    `json:"strategy"`
+	Keys     []KeySpec `json:"keys"`
+}
+
+// keyUsageFormat versions the per-key usage file. The keys file itself is
+// written by hand and is not versioned.
+var keyUsageFormat = persist.NewFormat("key-usage", 1)
+
+// KeySpend is one key's usage for a month
+type KeySpend struct {
+	Requests int     `json:"requests"`
+	Tokens   int     `json:"tokens"`
+	SpentUSD float64 `json:"spent_usd"`
+}
+
+// KeyStatus is a snapshot of one key for display
+type KeyStatus struct {
+	Name              string
+	Weight            int
+	RequestsPerMinute int
+	RecentRequests    int
+	CoolingDownUntil  time.Time
+	LastError         string
+	MonthToDate       KeySpend
+}
+
+// pooledKey is a key with its client and runtime state
+type pooledKey struct {
+	spec    KeySpec
+	client  *openai.Client
+	current int // smooth weighted round-robin counter
+
+	recent        []time.Time // call times within the last minute
+	cooldownUntil time.Time
+	lastError     string
+}
+
+// KeyPool spreads calls across several API keys (for example two OpenAI
+// orgs with separate quotas). Keys are picked by smooth weighted round-robin
+// or, with the failover strategy, in file order. A key over its per-minute
+// limit or recently rejected by the API is skipped. When loaded from a file
+// the pool reloads it on change, so keys can be rotated without a restart.
+type KeyPool struct {
+	path      string
+	modTime   time.Time
+	strategy  string
+	keys      []*pooledKey
+	usagePath string
+	usage     map[string]map[string]*KeySpend // month -> key name -> spend
+	now       func() time.Time
+	events    bus.Bus
+	mu        sync.Mutex
+}
+
+// CircuitEvent is published when a key is rested after an API error
+// (circuit.opened) and when it serves a call again (circuit.closed)
+type CircuitEvent struct {
+	Key   string    `json:"key"`
+	Error string    `json:"error,omitempty"`
+	Until time.Time `json:"until,omitempty"`
+}
+
+// NewKeyPool creates a pool with a single key for api, used when no keys
+// file is configured
+func NewKeyPool(apiKey string, api endpoint.Endpoint) (*KeyPool, error) {
+	if apiKey == "" && api.RequiresKey() {
+		return nil, fmt.Errorf("API key is required")
+	}
+	pool := &KeyPool{
+		strategy: StrategyFailover,
+		usage:    make(map[string]map[string]*KeySpend),
+		now:      time.Now,
+	}
+	pool.keys = []*pooledKey{newPooledKey(KeySpec{Name: "default", Key: apiKey, Weight: 1}, api)}
+	return pool, nil
+}
+
+// LoadKeyPool reads keys from a JSON file and records per-key spend in usagePath
+func LoadKeyPool(path, usagePath string) (*KeyPool, error) {
+	pool := &KeyPool{
+		path:      path,
+		usagePath: usagePath,
+		usage:     make(map[string]map[string]*KeySpend),
+		now:       time.Now,
+	}
+	if err := pool.Reload(); err != nil {
+		return nil, err
+	}
+
+	if usagePath != "" {
+		err := keyUsageFormat.ReadFile(usagePath, &pool.usage)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to load key usage: %w", err)
+		}
+	}
+	return pool, nil
+}
+
+// Reload re-reads the keys file. Runtime state of keys that keep their name
+// is preserved; removed keys stop receiving traffic immediately.
+func (p *KeyPool) Reload() error {
+	if p.path == "" {
+		return fmt.Errorf("key pool was not loaded from a file")
+	}
+
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return fmt.Errorf("failed to read keys file: %w", err)
+	}
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return fmt.Errorf("failed to read keys file: %w", err)
+	}
+
+	var file keysFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse keys file: %w", err)
+	}
+	if file.Strategy == "" {
+		file.Strategy = StrategyWeighted
+	}
+	if file.Strategy != StrategyWeighted && file.Strategy != StrategyFailover {
+		return fmt.Errorf("unknown key strategy %q (use %s or %s)", file.Strategy, StrategyWeighted, StrategyFailover)
+	}
+	if len(file.Keys) == 0 {
+		return fmt.Errorf("keys file must list at least one key")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	existing := make(map[string]*pooledKey, len(p.keys))
+	for _, key := range p.keys {
+		existing[key.spec.Name] = key
+	}
+
+	keys := make([]*pooledKey, 0, len(file.Keys))
+	seen := make(map[string]bool)
+	for i, spec := range file.Keys {
+		if spec.Name == "" {
+			spec.Name = fmt.Sprintf("key-%d", i+1)
+		}
+		if seen[spec.Name] {
+			return fmt.Errorf("duplicate key name %q", spec.Name)
+		}
+		seen[spec.Name] = true
+
+		api, err := spec.Endpoint()
+		if err != nil {
+			return fmt.Errorf("key %q: %w", spec.Name, err)
+		}
+		if spec.KeyEnv != "" {
+			spec.Key = os.Getenv(spec.KeyEnv)
+		}
+		if spec.Key == "" && api.RequiresKey() {
+			return fmt.Errorf("key %q has no value (set key or key_env)", spec.Name)
+		}
+		if spec.Weight <= 0 {
+			spec.Weight = 1
+		}
+
+		if old, ok := existing[spec.Name]; ok && sameClient(old.spec, spec) {
+			old.spec = spec
+			keys = append(keys, old)
+		} else {
+			keys = append(keys, newPooledKey(spec, api))
+		}
+	}
+
+	p.strategy = file.Strategy
+	p.keys = keys
+	p.modTime = info.ModTime()
+	return nil
+}
+
+// acquire picks the key for the next call and counts it against the key's rate limit
+func (p *KeyPool) acquire() (*pooledKey, error) {
+	p.reloadIfChanged()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	var available []*pooledKey
+	for _, key := range p.keys {
+		key.prune(now)
+		if now.Before(key.cooldownUntil) {
+			continue
+		}
+		if key.spec.RequestsPerMinute > 0 && len(key.recent) >= key.spec.RequestsPerMinute {
+			continue
+		}
+		available = append(available, key)
+	}
+	if len(available) == 0 {
+		return nil, fmt.Errorf("%w: all %d keys are rate limited or cooling down", ErrNoKeyAvailable, len(p.keys))
+	}
+
+	chosen := available[0]
+	if p.strategy == StrategyWeighted {
+		total := 0
+		for _, key := range available {
+			key.current += key.spec.Weight
+			total += key.spec.Weight
+			if key.current > chosen.current {
+				chosen = key
+			}
+		}
+		chosen.current -= total
+	}
+
+	chosen.recent = append(chosen.recent, now)
+	return chosen, nil
+}
+
+// reportFailure puts a key into cooldown when the error says it is unusable
+// for now, and reports whether another key should be tried. A lone key is
+// never rested, since there is nothing to fail over to.
+func (p *KeyPool) reportFailure(key *pooledKey, err error) bool {
+	if !isKeyError(err) {
+		return false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.keys) < 2 {
+		key.lastError = err.Error()
+		return false
+	}
+
+	key.cooldownUntil = p.now().Add(keyCooldown)
+	key.lastError = err.Error()
+	p.announce(bus.TopicCircuitOpened, CircuitEvent{Key: key.spec.Name, Error: key.lastError, Until: key.cooldownUntil})
+	return true
+}
+
+// reportSuccess records a completed call's tokens and cost against the key
+func (p *KeyPool) reportSuccess(key *pooledKey, tokens int, costUSD float64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key.lastError = ""
+	if !key.cooldownUntil.IsZero() {
+		key.cooldownUntil = time.Time{}
+		p.announce(bus.TopicCircuitClosed, CircuitEvent{Key: key.spec.Name})
+	}
+	spend := p.monthSpend(p.now().Format("2006-01"), key.spec.Name)
+	spend.Requests++
+	spend.Tokens += tokens
+	spend.SpentUSD += costUSD
+	return p.saveUsage()
+}
+
+// SetBus publishes circuit events for keys entering and leaving cooldown
+func (p *KeyPool) SetBus(events bus.Bus) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = events
+}
+
+// announce publishes a circuit event, if a bus is set. Bus publishing
+// never blocks on subscribers, so it is safe under p.mu.
+func (p *KeyPool) announce(topic string, event CircuitEvent) {
+	if p.events != nil {
+		p.events.Publish(topic, event)
+	}
+}
+
+// Status returns a snapshot of every key, in configured order
+func (p *KeyPool) Status() []KeyStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	month := now.Format("2006-01")
+	statuses := make([]KeyStatus, 0, len(p.keys))
+	for _, key := range p.keys {
+		key.prune(now)
+		status := KeyStatus{
+			Name:              key.spec.Name,
+			Weight:            key.spec.Weight,
+			RequestsPerMinute: key.spec.RequestsPerMinute,
+			RecentRequests:    len(key.recent),
+			LastError:         key.lastError,
+			MonthToDate:       *p.monthSpend(month, key.spec.Name),
+		}
+		if now.Before(key.cooldownUntil) {
+			status.CoolingDownUntil = key.cooldownUntil
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// Strategy returns the selection strategy in use
+func (p *KeyPool) Strategy() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.strategy
+}
+
+// Size returns the number of configured keys
+func (p *KeyPool) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.keys)
+}
+
+// reloadIfChanged picks up edits to the keys file. A broken edit keeps the
+// previous keys in service.
+func (p *KeyPool) reloadIfChanged() {
+	if p.path == "" {
+		return
+	}
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return
+	}
+
+	p.mu.Lock()
+	changed := !info.ModTime().Equal(p.modTime)
+	p.mu.Unlock()
+
+	if changed {
+		_ = p.Reload()
+	}
+}
+
+// monthSpend returns a key's spend entry for a month. Callers must hold p.mu.
+func (p *KeyPool) monthSpend(month, name string) *KeySpend {
+	keys, ok := p.usage[month]
+	if !ok {
+		keys = make(map[string]*KeySpend)
+		p.usage[month] = keys
+	}
+	spend, ok := keys[name]
+	if !ok {
+		spend = &KeySpend{}
+		keys[name] = spend
+	}
+	return spend
+}
+
+// saveUsage writes per-key spend atomically. Callers must hold p.mu.
+func (p *KeyPool) saveUsage() error {
+	if p.usagePath == "" {
+		return nil
+	}
+
+	if err := keyUsageFormat.WriteFile(p.usagePath, p.usage, 0644); err != nil {
+		return fmt.Errorf("failed to write key usage: %w", err)
+	}
+	return nil
+}
+
+// newPooledKey creates the client for a key used with api
+func newPooledKey(spec KeySpec, api endpoint.Endpoint) *pooledKey {
+	return &pooledKey{spec: spec, client: openai.NewClientWithConfig(api.Config(spec.Key))}
+}
+
+// sameClient reports whether a reloaded key can keep its client
+func sameClient(old, spec KeySpec) bool {
+	return old.Key == spec.Key && old.BaseURL == spec.BaseURL && old.APIType == spec.APIType &&
+		old.APIVersion == spec.APIVersion && old.Deployment == spec.Deployment
+}
+
+// prune drops call times older than a minute
+func (k *pooledKey) prune(now time.Time) {
+	cutoff := now.Add(-time.Minute)
+	kept := k.recent[:0]
+	for _, t := range k.recent {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	k.recent = kept
+}
+
+// isKeyError reports whether an API error means this key should be rested:
+// auth failures, quota or rate limits, and server errors
+func isKeyError(err error) bool {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		status := apiErr.HTTPStatusCode
+		return status == http.StatusUnauthorized || status == http.StatusForbidden ||
+			status == http.StatusTooManyRequests || status >= 500
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return reqErr.HTTPStatusCode == http.StatusTooManyRequests || reqErr.HTTPStatusCode >= 500
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sakibmulla/agentic-ai/endpoint"
+	"github.com/sashabaranov/go-openai"
+)
+
+// ollamaMessage is a chat message in Ollama's native API
+type ollamaMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ollamaChatRequest is the body of POST /api/chat. The native API is used
+// rather than Ollama's OpenAI-compatible one because only it takes top_k,
+// min_p and repeat_penalty.
+type ollamaChatRequest struct {
+	Model    string                 `json:"model"`
+	Messages []ollamaMessage        `json:"messages"`
+	Stream   bool                   `json:"stream"`
+	Options  map[string]interface{} `json:"options,omitempty"`
+	// Format "json" constrains the reply to a JSON value
+	Format string `json:"format,omitempty"`
+}
+
+// ollamaChatResponse is the reply to a non-streamed /api/chat request
+type ollamaChatResponse struct {
+	Model           string        `json:"model"`
+	CreatedAt       time.Time     `json:"created_at"`
+	Message         ollamaMessage `json:"message"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+}
+
+// NewOllamaClient creates a client for model served by the Ollama endpoint
+// api, as endpoint.Ollama returns. Local models cost nothing, so spend isn't
+// tracked.
+func NewOllamaClient(api endpoint.Endpoint, model string) *Client {
+	return &Client{
+		model:    model,
+		provider: ProviderOllama,
+		ollama:   api,
+		http:     &http.Client{Timeout: api.Timeout},
+	}
+}
+
+// completeOllama sends req to Ollama with the client's sampling options
+func (c *Client) completeOllama(ctx context.Context, req openai.ChatCompletionRequest) (*openai.ChatCompletionResponse, error) {
+	if len(req.Functions) > 0 {
+		return nil, fmt.Errorf("function calling is not supported with the %s provider", ProviderOllama)
+	}
+
+	body := ollamaChatRequest{
+		Model:    req.Model,
+		Messages: make([]ollamaMessage, len(req.Messages)),
+		Options:  c.ollamaOptions(req),
+	}
+	if req.ResponseFormat != nil && req.ResponseFormat.Type == openai.ChatCompletionResponseFormatTypeJSONObject {
+		body.Format = "json"
+	}
+	for i, message := range req.Messages {
+		body.Messages[i] = ollamaMessage{Role: message.Role, Content: message.Content}
+	}
+
+	var reply ollamaChatResponse
+	if err := c.ollamaCall(ctx, http.MethodPost, "/api/chat", body, &reply); err != nil {
+		return nil, fmt.Errorf("chat completion failed: %w", err)
+	}
+
+	finish := openai.FinishReasonStop
+	if reply.DoneReason == "length" {
+		finish = openai.FinishReasonLength
+	}
+	return &openai.ChatCompletionResponse{
+		Object:  "chat.completion",
+		Created: reply.CreatedAt.Unix(),
+		Model:   reply.Model,
+		Choices: []openai.ChatCompletionChoice{{
+			Message:      openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: reply.Message.Content},
+			FinishReason: finish,
+		}},
+		Usage: openai.Usage{
+			PromptTokens:     reply.PromptEvalCount,
+			CompletionTokens: reply.EvalCount,
+			TotalTokens:      reply.PromptEvalCount + reply.EvalCount,
+		},
+	}, nil
+}
+
+// ollamaOptions translates a request's limits and the client's sampling
+// into Ollama's model options
+func (c *Client) ollamaOptions(req openai.ChatCompletionRequest) map[string]interface{} {
+	options := map[string]interface{}{"temperature": req.Temperature}
+	if req.MaxTokens > 0 {
+		options["num_predict"] = req.MaxTokens
+	}
+	if c.sampling.TopP != 0 {
+		options[SamplingTopP] = c.sampling.TopP
+	}
+	if c.sampling.TopK != 0 {
+		options[SamplingTopK] = c.sampling.TopK
+	}
+	if c.sampling.MinP != 0 {
+		options[SamplingMinP] = c.sampling.MinP
+	}
+	if c.sampling.RepeatPenalty != 0 {
+		options[SamplingRepeatPenalty] = c.sampling.RepeatPenalty
+	}
+	return options
+}
+
+// ollamaCall sends body (if any) to the Ollama API and decodes the reply into out
+func (c *Client) ollamaCall(ctx context.Context, method, path string, body, out interface{}) error {
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.ollama.BaseURL+path, &payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&failure)
+		if failure.Error == "" {
+			failure.Error = resp.Status
+		}
+		return fmt.Errorf("ollama returned %d: %s", resp.StatusCode, failure.Error)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
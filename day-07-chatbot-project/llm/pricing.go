@@ -0,0 +1,32 @@
+package llm
+
+import (
+	"github.com/sakibmulla/agentic-ai/costs"
+	"github.com/sashabaranov/go-openai"
+)
+
+// ModelPricing holds USD prices per 1K tokens for a model
+type ModelPricing = costs.Price
+
+// GetPricing returns the pricing for a model from costs.Prices, matching
+// dated variants (e.g. gpt-4-0613) by their longest known prefix. Unknown
+// models are priced as gpt-3.5-turbo.
+func GetPricing(model string) ModelPricing {
+	if pricing, ok := costs.Lookup(model); ok {
+		return pricing
+	}
+	return costs.Prices["gpt-3.5-turbo"]
+}
+
+// IsKnownModel reports whether a model (or its dated variant) is in the pricing registry
+func IsKnownModel(model string) bool {
+	_, ok := costs.Lookup(model)
+	return ok
+}
+
+// EstimateCost returns the USD cost of a request's token usage
+func EstimateCost(model string, usage openai.Usage) float64 {
+	pricing := GetPricing(model)
+	return float64(usage.PromptTokens)/1000*pricing.PromptPer1K +
+		float64(usage.CompletionTokens)/1000*pricing.CompletionPer1K
+}
@@ -0,0 +1,158 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Sampling option names, as written in specs like "top_k=40,min_p=0.05"
+const (
+	SamplingTopP          = "top_p"
+	SamplingTopK          = "top_k"
+	SamplingMinP          = "min_p"
+	SamplingRepeatPenalty = "repeat_penalty"
+)
+
+// ErrUnsupportedSampling is returned for a sampling option the model ignores
+var ErrUnsupportedSampling = errors.New("unsupported sampling option")
+
+// Sampling holds the sampling options beyond temperature. A zero option is
+// left at the provider's default.
+type Sampling struct {
+	TopP          float64 `json:"top_p,omitempty"`
+	TopK          int     `json:"top_k,omitempty"`
+	MinP          float64 `json:"min_p,omitempty"`
+	RepeatPenalty float64 `json:"repeat_penalty,omitempty"`
+}
+
+// PersonaSampling holds the global sampling defaults of personas that have
+// them. Options the model ignores are dropped rather than rejected; tenants
+// can override them, including with provider-specific ones.
+var PersonaSampling = map[string]Sampling{
+	"creative": {TopP: 0.95},
+}
+
+// ParseSampling parses a spec such as "top_k=40,min_p=0.05". An empty spec
+// sets nothing.
+func ParseSampling(spec string) (Sampling, error) {
+	var s Sampling
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return Sampling{}, fmt.Errorf("invalid sampling option %q: expected name=value", field)
+		}
+		name = strings.TrimSpace(name)
+		value = strings.TrimSpace(value)
+
+		var err error
+		switch name {
+		case SamplingTopP:
+			s.TopP, err = strconv.ParseFloat(value, 64)
+		case SamplingTopK:
+			s.TopK, err = strconv.Atoi(value)
+		case SamplingMinP:
+			s.MinP, err = strconv.ParseFloat(value, 64)
+		case SamplingRepeatPenalty:
+			s.RepeatPenalty, err = strconv.ParseFloat(value, 64)
+		default:
+			return Sampling{}, fmt.Errorf("unknown sampling option %q", name)
+		}
+		if err != nil {
+			return Sampling{}, fmt.Errorf("invalid %s %q", name, value)
+		}
+	}
+	return s, s.checkRanges()
+}
+
+// String formats s as a spec ParseSampling accepts
+func (s Sampling) String() string {
+	var fields []string
+	if s.TopP != 0 {
+		fields = append(fields, SamplingTopP+"="+strconv.FormatFloat(s.TopP, 'g', -1, 64))
+	}
+	if s.TopK != 0 {
+		fields = append(fields, SamplingTopK+"="+strconv.Itoa(s.TopK))
+	}
+	if s.MinP != 0 {
+		fields = append(fields, SamplingMinP+"="+strconv.FormatFloat(s.MinP, 'g', -1, 64))
+	}
+	if s.RepeatPenalty != 0 {
+		fields = append(fields, SamplingRepeatPenalty+"="+strconv.FormatFloat(s.RepeatPenalty, 'g', -1, 64))
+	}
+	return strings.Join(fields, ",")
+}
+
+// Merge returns s with every option over sets replacing its own
+func (s Sampling) Merge(over Sampling) Sampling {
+	if over.TopP != 0 {
+		s.TopP = over.TopP
+	}
+	if over.TopK != 0 {
+		s.TopK = over.TopK
+	}
+	if over.MinP != 0 {
+		s.MinP = over.MinP
+	}
+	if over.RepeatPenalty != 0 {
+		s.RepeatPenalty = over.RepeatPenalty
+	}
+	return s
+}
+
+// Supported returns s without the options the model ignores
+func (s Sampling) Supported(capabilities ModelCapabilities) Sampling {
+	if !capabilities.Supports(SamplingTopP) {
+		s.TopP = 0
+	}
+	if !capabilities.Supports(SamplingTopK) {
+		s.TopK = 0
+	}
+	if !capabilities.Supports(SamplingMinP) {
+		s.MinP = 0
+	}
+	if !capabilities.Supports(SamplingRepeatPenalty) {
+		s.RepeatPenalty = 0
+	}
+	return s
+}
+
+// Validate checks that s is in range and that the model honours every
+// option it sets
+func (s Sampling) Validate(capabilities ModelCapabilities) error {
+	if err := s.checkRanges(); err != nil {
+		return err
+	}
+	set := map[string]bool{
+		SamplingTopP:          s.TopP != 0,
+		SamplingTopK:          s.TopK != 0,
+		SamplingMinP:          s.MinP != 0,
+		SamplingRepeatPenalty: s.RepeatPenalty != 0,
+	}
+	for _, option := range []string{SamplingTopP, SamplingTopK, SamplingMinP, SamplingRepeatPenalty} {
+		if set[option] && !capabilities.Supports(option) {
+			return fmt.Errorf("%w: this %s model ignores %s", ErrUnsupportedSampling, capabilities.Provider, option)
+		}
+	}
+	return nil
+}
+
+// checkRanges rejects option values no provider accepts
+func (s Sampling) checkRanges() error {
+	switch {
+	case s.TopP < 0 || s.TopP > 1:
+		return fmt.Errorf("%s must be between 0 and 1, got %g", SamplingTopP, s.TopP)
+	case s.TopK < 0:
+		return fmt.Errorf("%s must be positive, got %d", SamplingTopK, s.TopK)
+	case s.MinP < 0 || s.MinP > 1:
+		return fmt.Errorf("%s must be between 0 and 1, got %g", SamplingMinP, s.MinP)
+	case s.RepeatPenalty < 0 || s.RepeatPenalty > 2:
+		return fmt.Errorf("%s must be between 0 and 2, got %g", SamplingRepeatPenalty, s.RepeatPenalty)
+	}
+	return nil
+}
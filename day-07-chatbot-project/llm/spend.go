@@ -0,0 +1,165 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sakibmulla/agentic-ai/persist"
+)
+
+// ErrSpendLimitExceeded is returned when the monthly hard stop is active
+var ErrSpendLimitExceeded = errors.New("monthly spend limit exceeded")
+
+// freeProviders are never blocked by the spend guard
+var freeProviders = map[string]bool{
+	"local":  true,
+	"mock":   true,
+	"ollama": true,
+}
+
+// MonthlySpend records spend for a single calendar month
+type MonthlySpend struct {
+	SpentUSD float64 `json:"spent_usd"`
+	Requests int     `json:"requests"`
+	// ResetAtUSD is what had been spent when an admin last lifted the hard
+	// stop; the limit then applies to spend beyond it
+	ResetAtUSD float64 `json:"reset_at_usd,omitempty"`
+}
+
+// ledgerFormat versions the spend ledger
+var ledgerFormat = persist.NewFormat("spend-ledger", 1)
+
+// spendLedger is the persisted form of the guard's state
+type spendLedger struct {
+	Months    map[string]*MonthlySpend `json:"months"`
+	Stopped   bool                     `json:"stopped"`
+	StoppedAt time.Time                `json:"stopped_at,omitempty"`
+	UpdatedAt time.Time                `json:"updated_at"`
+}
+
+// SpendGuard enforces a monthly spend limit across sessions. Once the limit
+// is reached every paid-provider call is refused until an administrator
+// resets the guard; the stop is not lifted automatically when the month rolls over.
+type SpendGuard struct {
+	path     string
+	limitUSD float64
+	ledger   spendLedger
+	mu       sync.Mutex
+}
+
+// NewSpendGuard loads (or creates) the ledger at path. A limit of zero or
+// less disables the hard stop but spend is still recorded.
+func NewSpendGuard(path string, limitUSD float64) (*SpendGuard, error) {
+	guard := &SpendGuard{
+		path:     path,
+		limitUSD: limitUSD,
+		ledger:   spendLedger{Months: make(map[string]*MonthlySpend)},
+	}
+
+	err := ledgerFormat.ReadFile(path, &guard.ledger)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to load spend ledger: %w", err)
+	}
+	if err == nil {
+		if guard.ledger.Months == nil {
+			guard.ledger.Months = make(map[string]*MonthlySpend)
+		}
+	}
+
+	return guard, nil
+}
+
+// Allow reports whether a call to the given provider may proceed
+func (sg *SpendGuard) Allow(provider string) error {
+	if freeProviders[provider] {
+		return nil
+	}
+
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+
+	if sg.ledger.Stopped {
+		return fmt.Errorf("%w: $%.2f spent this month against a $%.2f limit; paid calls are disabled until an admin runs /admin reset-spend",
+			ErrSpendLimitExceeded, sg.currentMonth().SpentUSD, sg.limitUSD)
+	}
+	return nil
+}
+
+// Record adds the cost of a completed call and engages the hard stop when
+// the monthly limit is crossed
+func (sg *SpendGuard) Record(provider string, costUSD float64) error {
+	if freeProviders[provider] {
+		return nil
+	}
+
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+
+	month := sg.currentMonth()
+	month.SpentUSD += costUSD
+	month.Requests++
+
+	if sg.limitUSD > 0 && month.SpentUSD-month.ResetAtUSD >= sg.limitUSD && !sg.ledger.Stopped {
+		sg.ledger.Stopped = true
+		sg.ledger.StoppedAt = time.Now()
+	}
+
+	return sg.save()
+}
+
+// Reset lifts the hard stop, allowing up to the limit again on top of what
+// this month has already spent, so the next call doesn't trip it straight
+// back. It is intended to be called only from an explicit admin command.
+func (sg *SpendGuard) Reset() error {
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+
+	month := sg.currentMonth()
+	month.ResetAtUSD = month.SpentUSD
+	sg.ledger.Stopped = false
+	sg.ledger.StoppedAt = time.Time{}
+	return sg.save()
+}
+
+// MonthToDate returns this month's spend
+func (sg *SpendGuard) MonthToDate() MonthlySpend {
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+	return *sg.currentMonth()
+}
+
+// Limit returns the configured monthly limit in USD
+func (sg *SpendGuard) Limit() float64 {
+	return sg.limitUSD
+}
+
+// Stopped reports whether the hard stop is active
+func (sg *SpendGuard) Stopped() bool {
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+	return sg.ledger.Stopped
+}
+
+// currentMonth returns the ledger entry for the current month, creating it if needed
+func (sg *SpendGuard) currentMonth() *MonthlySpend {
+	key := time.Now().Format("2006-01")
+	month, ok := sg.ledger.Months[key]
+	if !ok {
+		month = &MonthlySpend{}
+		sg.ledger.Months[key] = month
+	}
+	return month
+}
+
+// save writes the ledger to disk atomically
+func (sg *SpendGuard) save() error {
+	sg.ledger.UpdatedAt = time.Now()
+
+	if err := ledgerFormat.WriteFile(sg.path, sg.ledger, 0644); err != nil {
+		return fmt.Errorf("failed to write spend ledger: %w", err)
+	}
+	return nil
+}
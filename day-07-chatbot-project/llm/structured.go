@@ -0,0 +1,128 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sakibmulla/agentic-ai/tools"
+	"github.com/sashabaranov/go-openai"
+)
+
+// DefaultStructuredRepairs is how many times GenerateStructured re-prompts
+// a model whose reply doesn't match the schema
+const DefaultStructuredRepairs = 2
+
+// structuredMaxTokens bounds each structured reply
+const structuredMaxTokens = 1000
+
+// StructuredError is returned when no reply matched the schema
+type StructuredError struct {
+	// Attempts counts the replies requested, the first one included
+	Attempts int
+	// Response is the last reply
+	Response string
+	Err      error
+}
+
+func (e *StructuredError) Error() string {
+	return fmt.Sprintf("no valid structured output after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *StructuredError) Unwrap() error {
+	return e.Err
+}
+
+// SetStructuredRepairs sets how many times GenerateStructured re-prompts
+// with the validation error; a negative n disables repairs
+func (c *Client) SetStructuredRepairs(n int) {
+	if n < 0 {
+		n = 0
+	}
+	c.structuredRepairs = &n
+}
+
+// GenerateStructured asks the model to answer prompt with a JSON object
+// matching schema and decodes it into out. A nil schema is derived from
+// out's type with tools.SchemaOf. The request uses JSON mode, and a reply
+// that isn't valid JSON or doesn't match the schema is sent back with the
+// error so the model can repair it.
+func (c *Client) GenerateStructured(ctx context.Context, prompt string, schema *tools.Schema, out interface{}) error {
+	if schema == nil {
+		derived, err := tools.SchemaOf(out)
+		if err != nil {
+			return fmt.Errorf("invalid output type: %w", err)
+		}
+		schema = &derived
+	}
+	definition, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	repairs := DefaultStructuredRepairs
+	if c.structuredRepairs != nil {
+		repairs = *c.structuredRepairs
+	}
+
+	messages := []openai.ChatCompletionMessage{
+		{
+			Role: openai.ChatMessageRoleSystem,
+			Content: "Respond only with a JSON object, without markdown fences, matching this JSON schema:\n" +
+				string(definition),
+		},
+		{Role: openai.ChatMessageRoleUser, Content: prompt},
+	}
+
+	var reply string
+	var lastErr error
+	for attempt := 1; attempt <= repairs+1; attempt++ {
+		resp, err := c.complete(ctx, openai.ChatCompletionRequest{
+			Model:          c.model,
+			Messages:       messages,
+			MaxTokens:      structuredMaxTokens,
+			ResponseFormat: &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject},
+		})
+		if err != nil {
+			return err
+		}
+		if len(resp.Choices) == 0 {
+			return fmt.Errorf("no response from model")
+		}
+
+		reply = resp.Choices[0].Message.Content
+		if lastErr = decodeStructured(reply, *schema, out); lastErr == nil {
+			return nil
+		}
+		messages = append(messages,
+			openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: reply},
+			openai.ChatCompletionMessage{
+				Role:    openai.ChatMessageRoleUser,
+				Content: fmt.Sprintf("That reply is invalid: %v. Respond again with only the corrected JSON object.", lastErr),
+			},
+		)
+	}
+	return &StructuredError{Attempts: repairs + 1, Response: reply, Err: lastErr}
+}
+
+// decodeStructured checks reply against schema, then decodes it into out
+func decodeStructured(reply string, schema tools.Schema, out interface{}) error {
+	reply = strings.TrimSpace(reply)
+	// Models without JSON mode sometimes fence the object anyway
+	reply = strings.TrimPrefix(reply, "```json")
+	reply = strings.TrimPrefix(reply, "```")
+	reply = strings.TrimSuffix(reply, "```")
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(reply), &value); err != nil {
+		return fmt.Errorf("not valid JSON: %w", err)
+	}
+	if err := schema.Validate(value); err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(reply), out); err != nil {
+		return fmt.Errorf("does not fit the output type: %w", err)
+	}
+	return nil
+}
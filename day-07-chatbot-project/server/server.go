@@ -0,0 +1,479 @@
+// Package server exposes the chatbot, its conversation memory and the
+// vector store over HTTP, so other services can use them
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"chatbot/chatbot"
+	"chatbot/llm"
+	"chatbot/vectors"
+
+	"github.com/sakibmulla/agentic-ai/costs"
+	"github.com/sakibmulla/agentic-ai/logging"
+)
+
+// SessionHeader carries the session a request belongs to
+const SessionHeader = "X-Session-ID"
+
+// Config bounds the work the server takes on
+type Config struct {
+	// MaxInFlight is how many chat requests may wait for the bot at once;
+	// more are turned away with 503
+	MaxInFlight int
+	// RequestTimeout bounds one chat request, retrieval and tools included
+	RequestTimeout time.Duration
+	// TopK is how many passages chat answers are grounded in, and how many
+	// results POST /search returns unless asked for more
+	TopK int
+	// MinScore is the similarity a passage needs to ground a chat answer
+	MinScore float64
+	// SessionTTL is how long a session stays in memory unused; it is
+	// resumed from its saved conversation when it comes back
+	SessionTTL time.Duration
+}
+
+// Server serves the bot over HTTP. Every session has its own conversation
+// and retriever, so sessions are answered concurrently and never see each
+// other's memory.
+type Server struct {
+	bot      *chatbot.Bot
+	sessions *chatbot.SessionManager
+	store    *vectors.Store
+	config   Config
+	inFlight chan struct{}
+
+	metricsMu sync.Mutex
+	metrics   Metrics
+}
+
+// Metrics counts the server's traffic
+type Metrics struct {
+	Requests       int           `json:"requests"`
+	Errors         int           `json:"errors"`
+	Rejected       int           `json:"rejected"`
+	TimedOut       int           `json:"timed_out"`
+	Sessions       int           `json:"sessions"`
+	Searches       int           `json:"searches"`
+	DocumentsAdded int           `json:"documents_added"`
+	TotalLatency   time.Duration `json:"total_latency"`
+	MaxLatency     time.Duration `json:"max_latency"`
+	AverageLatency time.Duration `json:"average_latency"`
+}
+
+// ChatRequest is the body of POST /chat. The session may instead be given
+// in the X-Session-ID header; without either a new session is started.
+type ChatRequest struct {
+	Message string `json:"message"`
+	Session string `json:"session,omitempty"`
+}
+
+// ChatResponse is the reply to POST /chat
+type ChatResponse struct {
+	Reply   string           `json:"reply"`
+	Session string           `json:"session"`
+	Sources []vectors.Result `json:"sources"`
+}
+
+// ConversationSummary describes one session's saved conversation
+type ConversationSummary struct {
+	Session   string    `json:"session"`
+	Messages  int       `json:"messages"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SearchRequest is the body of POST /search
+type SearchRequest struct {
+	Query string `json:"query"`
+	TopK  int    `json:"top_k,omitempty"`
+}
+
+// chatEvent is published on the bot's bus after every chat request
+type chatEvent struct {
+	Session string        `json:"session"`
+	Sources int           `json:"sources"`
+	Latency time.Duration `json:"latency"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// New creates a server whose sessions are forked from bot and answer from
+// store
+func New(bot *chatbot.Bot, store *vectors.Store, config Config) *Server {
+	if config.MaxInFlight <= 0 {
+		config.MaxInFlight = 8
+	}
+	if config.RequestTimeout <= 0 {
+		config.RequestTimeout = time.Minute
+	}
+	if config.TopK <= 0 {
+		config.TopK = 4
+	}
+	if config.MinScore <= 0 {
+		config.MinScore = 0.8
+	}
+	s := &Server{
+		bot:      bot,
+		sessions: chatbot.NewSessionManager(bot, config.SessionTTL),
+		store:    store,
+		config:   config,
+		inFlight: make(chan struct{}, config.MaxInFlight),
+	}
+	s.sessions.OnCreate = func(id string, session *chatbot.Bot) {
+		session.SetRetriever(vectors.NewRetriever(store, config.TopK, config.MinScore))
+		s.count(func(m *Metrics) { m.Sessions++ })
+	}
+	return s
+}
+
+// Sessions returns the server's sessions, e.g. to run their eviction
+func (s *Server) Sessions() *chatbot.SessionManager {
+	return s.sessions
+}
+
+// Handler returns the server's routes. Each request gets a correlation ID,
+// the caller's X-Correlation-ID or a new one, that tags its log records.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/chat", s.handleChat)
+	mux.HandleFunc("/conversations", s.handleConversations)
+	mux.HandleFunc("/conversations/", s.handleConversation)
+	mux.HandleFunc("/documents", s.handleDocuments)
+	mux.HandleFunc("/documents/", s.handleDocument)
+	mux.HandleFunc("/search", s.handleSearch)
+	mux.HandleFunc("/health", s.handleHealth)
+	mux.HandleFunc("/metrics", s.handleMetrics)
+	return logging.Middleware(mux)
+}
+
+// handleChat answers one message in the caller's session
+func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	var req ChatRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&req); err != nil || req.Message == "" {
+		writeError(w, http.StatusBadRequest, `expected {"message": "..."}`)
+		return
+	}
+	if req.Session == "" {
+		req.Session = r.Header.Get(SessionHeader)
+	}
+	if req.Session == "" {
+		req.Session = newSessionID()
+	}
+	if err := chatbot.ValidateSessionID(req.Session); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	select {
+	case s.inFlight <- struct{}{}:
+		defer func() { <-s.inFlight }()
+	default:
+		s.count(func(m *Metrics) { m.Rejected++ })
+		writeError(w, http.StatusServiceUnavailable, "too many requests in flight, try again shortly")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.config.RequestTimeout)
+	defer cancel()
+
+	started := time.Now()
+	reply, sources, err := s.answer(ctx, req.Session, req.Message)
+	latency := time.Since(started)
+
+	event := chatEvent{Session: req.Session, Sources: len(sources), Latency: latency}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	if err := s.bot.Bus().Publish("server.chat", event); err != nil {
+		slog.WarnContext(ctx, "failed to publish chat event", "error", err)
+	}
+	s.count(func(m *Metrics) {
+		m.Requests++
+		m.TotalLatency += latency
+		if latency > m.MaxLatency {
+			m.MaxLatency = latency
+		}
+		if err != nil {
+			m.Errors++
+		}
+	})
+	slog.InfoContext(ctx, "chat", "session", req.Session, "sources", len(sources), "latency_ms", latency.Milliseconds(), "error", err)
+
+	w.Header().Set(SessionHeader, req.Session)
+	switch {
+	case errors.Is(err, llm.ErrSpendLimitExceeded):
+		writeError(w, http.StatusTooManyRequests, "the monthly spend limit has been reached")
+	case errors.Is(err, costs.ErrBudgetExceeded):
+		writeError(w, http.StatusTooManyRequests, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		s.count(func(m *Metrics) { m.TimedOut++ })
+		writeError(w, http.StatusGatewayTimeout, "the answer took too long")
+	case err != nil:
+		writeError(w, http.StatusBadGateway, err.Error())
+	default:
+		writeJSON(w, http.StatusOK, ChatResponse{Reply: reply, Session: req.Session, Sources: sources})
+	}
+}
+
+// answer answers a message in the session, with the passages it was
+// grounded in
+func (s *Server) answer(ctx context.Context, session, message string) (string, []vectors.Result, error) {
+	var reply string
+	sources := []vectors.Result{}
+	err := s.sessions.With(session, func(bot *chatbot.Bot) error {
+		// The request may have waited out its deadline for the session
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		retriever, _ := bot.Retriever().(*vectors.Retriever)
+		if retriever != nil {
+			retriever.Reset()
+		}
+		var err error
+		if reply, err = bot.ProcessMessage(ctx, message); err != nil {
+			return err
+		}
+		if retriever != nil && retriever.LastResults() != nil {
+			sources = retriever.LastResults()
+		}
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return reply, sources, nil
+}
+
+// handleConversations lists the sessions' saved conversations, most
+// recently updated first
+func (s *Server) handleConversations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+	summaries := []ConversationSummary{}
+	for _, session := range s.sessions.Saved() {
+		conversation, err := s.sessions.Transcript(session)
+		if err != nil {
+			slog.WarnContext(r.Context(), "failed to load conversation", "session", session, "error", err)
+			continue
+		}
+		summaries = append(summaries, ConversationSummary{
+			Session:   session,
+			Messages:  len(conversation.Messages),
+			UpdatedAt: conversation.UpdatedAt,
+		})
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].UpdatedAt.After(summaries[j].UpdatedAt) })
+	writeJSON(w, http.StatusOK, map[string]interface{}{"conversations": summaries})
+}
+
+// handleConversation returns (GET) or ends (DELETE) one session's conversation
+func (s *Server) handleConversation(w http.ResponseWriter, r *http.Request) {
+	session := strings.TrimPrefix(r.URL.Path, "/conversations/")
+	if err := chatbot.ValidateSessionID(session); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		conversation, err := s.sessions.Transcript(session)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			writeError(w, http.StatusNotFound, "no conversation for session "+session)
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, err.Error())
+		default:
+			writeJSON(w, http.StatusOK, conversation)
+		}
+	case http.MethodDelete:
+		deleted, err := s.sessions.Delete(session)
+		switch {
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, err.Error())
+		case !deleted:
+			writeError(w, http.StatusNotFound, "no conversation for session "+session)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "use GET or DELETE")
+	}
+}
+
+// handleDocuments embeds a document into the vector store
+func (s *Server) handleDocuments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	var doc vectors.Document
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&doc); err != nil || doc.ID == "" || doc.Text == "" {
+		writeError(w, http.StatusBadRequest, `expected {"id": "...", "text": "...", "metadata": {...}}`)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.config.RequestTimeout)
+	defer cancel()
+	passages, err := s.store.Add(ctx, doc)
+	switch {
+	case errors.Is(err, llm.ErrSpendLimitExceeded):
+		writeError(w, http.StatusTooManyRequests, "the monthly spend limit has been reached")
+	case errors.Is(err, costs.ErrBudgetExceeded):
+		writeError(w, http.StatusTooManyRequests, err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	s.count(func(m *Metrics) { m.DocumentsAdded++ })
+	slog.InfoContext(ctx, "document added", "id", doc.ID, "passages", passages)
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"id": doc.ID, "passages": passages})
+}
+
+// handleDocument removes a document from the vector store
+func (s *Server) handleDocument(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeError(w, http.StatusMethodNotAllowed, "use DELETE")
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/documents/")
+	deleted, err := s.store.Delete(id)
+	switch {
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	case !deleted:
+		writeError(w, http.StatusNotFound, "no document "+id)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleSearch returns the passages most similar to a query
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	var req SearchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&req); err != nil || req.Query == "" {
+		writeError(w, http.StatusBadRequest, `expected {"query": "...", "top_k": 4}`)
+		return
+	}
+	if req.TopK <= 0 {
+		req.TopK = s.config.TopK
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.config.RequestTimeout)
+	defer cancel()
+	results, err := s.store.Search(ctx, req.Query, req.TopK)
+	switch {
+	case errors.Is(err, llm.ErrSpendLimitExceeded):
+		writeError(w, http.StatusTooManyRequests, "the monthly spend limit has been reached")
+	case errors.Is(err, costs.ErrBudgetExceeded):
+		writeError(w, http.StatusTooManyRequests, err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	if results == nil {
+		results = []vectors.Result{}
+	}
+	s.count(func(m *Metrics) { m.Searches++ })
+	writeJSON(w, http.StatusOK, map[string]interface{}{"results": results})
+}
+
+// handleHealth reports whether the bot can answer
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	health := map[string]interface{}{
+		"status":   "ok",
+		"provider": s.bot.Provider(),
+		"vectors":  s.store.Stats(),
+	}
+	status := http.StatusOK
+	if guard := s.bot.SpendGuard(); guard != nil && guard.Stopped() {
+		health["status"] = "degraded"
+		health["reason"] = "monthly spend limit reached"
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, health)
+}
+
+// handleMetrics reports the server's traffic with the bot's usage, the
+// event bus and the API keys
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	s.metricsMu.Lock()
+	metrics := s.metrics
+	s.metricsMu.Unlock()
+	if metrics.Requests > 0 {
+		metrics.AverageLatency = metrics.TotalLatency / time.Duration(metrics.Requests)
+	}
+
+	report := map[string]interface{}{
+		"server":    metrics,
+		"in_flight": len(s.inFlight),
+		"sessions":  s.sessions.Active(),
+		"vectors":   s.store.Stats(),
+		"usage":     s.bot.Analytics().Report(),
+		"bus":       s.bot.Bus().Stats(),
+	}
+	if keys := s.bot.APIKeys(); keys != nil {
+		report["api_keys"] = keys.Status()
+	}
+	if guard := s.bot.SpendGuard(); guard != nil {
+		report["spend"] = map[string]interface{}{
+			"month_to_date": guard.MonthToDate(),
+			"limit_usd":     guard.Limit(),
+		}
+	}
+	if limiter := s.bot.CostLimiter(); limiter != nil {
+		report["costs"] = map[string]interface{}{
+			"today":  limiter.Tracker().Today(),
+			"month":  limiter.Tracker().ThisMonth(),
+			"budget": limiter.Budget(),
+		}
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// count updates the metrics
+func (s *Server) count(update func(*Metrics)) {
+	s.metricsMu.Lock()
+	defer s.metricsMu.Unlock()
+	update(&s.metrics)
+}
+
+// newSessionID returns a random session ID
+func newSessionID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn("failed to write response", "error", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,346 @@
+package tenants
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sakibmulla/agentic-ai/persist"
+)
+
+// envelopeMagic marks data encrypted by a KeyRing
+const envelopeMagic = "tenant-envelope-v1"
+
+// DataKey is one version of a tenant's data encryption key. Only the
+// wrapped (master-key encrypted) form is ever written to disk.
+type DataKey struct {
+	Version   int       `json:"version"`
+	Wrapped   []byte    `json:"wrapped"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// keysFormat versions the tenant key files
+var keysFormat = persist.NewFormat("tenant-keys", 1)
+
+// tenantKeys is the persisted key set of one tenant
+type tenantKeys struct {
+	Tenant string    `json:"tenant"`
+	Active int       `json:"active"`
+	Keys   []DataKey `json:"keys"`
+}
+
+// envelope is the stored form of encrypted data
+type envelope struct {
+	Magic      string `json:"magic"`
+	Tenant     string `json:"tenant"`
+	KeyVersion int    `json:"key_version"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// KeyRing implements envelope encryption: every tenant has its own data keys,
+// which are wrapped with a master key. Leaking one tenant's data key exposes
+// only that tenant, and rotating replaces it without touching other tenants.
+type KeyRing struct {
+	dir    string
+	master cipher.AEAD
+	keys   map[string]*tenantKeys
+	mu     sync.Mutex
+}
+
+// ParseMasterKey decodes a 32-byte master key given as base64 or hex
+func ParseMasterKey(value string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+	if key, err := base64.StdEncoding.DecodeString(value); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if key, err := hex.DecodeString(value); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	return nil, fmt.Errorf("master key must be 32 bytes encoded as base64 or hex")
+}
+
+// ReadMasterKeyFile reads a master key from a file that only its owner can
+// read, so the key can be kept out of the environment
+func ReadMasterKeyFile(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read master key file: %w", err)
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("master key file %s is readable by other users (chmod 600 it)", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read master key file: %w", err)
+	}
+	return ParseMasterKey(string(data))
+}
+
+// NewKeyRing creates a key ring storing wrapped tenant keys in dir
+func NewKeyRing(dir string, masterKey []byte) (*KeyRing, error) {
+	master, err := newAEAD(masterKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid master key: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create key directory: %w", err)
+	}
+
+	return &KeyRing{
+		dir:    dir,
+		master: master,
+		keys:   make(map[string]*tenantKeys),
+	}, nil
+}
+
+// IsEnvelope reports whether data was produced by KeyRing.Encrypt
+func IsEnvelope(data []byte) bool {
+	return bytes.Contains(data, []byte(`"magic":"`+envelopeMagic+`"`))
+}
+
+// Encrypt seals plaintext with the tenant's active data key. The tenant ID
+// is bound as additional data, so an envelope can't be replayed for another tenant.
+func (k *KeyRing) Encrypt(tenant string, plaintext []byte) ([]byte, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	keys, err := k.load(tenant)
+	if err != nil {
+		return nil, err
+	}
+	if keys.Active == 0 {
+		if _, err := k.rotateLocked(keys); err != nil {
+			return nil, err
+		}
+	}
+
+	aead, err := k.dataKey(keys, keys.Active)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return json.Marshal(envelope{
+		Magic:      envelopeMagic,
+		Tenant:     tenant,
+		KeyVersion: keys.Active,
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, plaintext, []byte(tenant)),
+	})
+}
+
+// Decrypt opens an envelope written for tenant
+func (k *KeyRing) Decrypt(tenant string, data []byte) ([]byte, error) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil || env.Magic != envelopeMagic {
+		return nil, fmt.Errorf("data is not an encrypted envelope")
+	}
+	if env.Tenant != tenant {
+		return nil, fmt.Errorf("envelope belongs to tenant %s", env.Tenant)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	keys, err := k.load(tenant)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := k.dataKey(keys, env.KeyVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := aead.Open(nil, env.Nonce, env.Ciphertext, []byte(tenant))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return plaintext, nil
+}
+
+// KeyVersion returns the data key version an envelope was sealed with
+func KeyVersion(data []byte) (int, error) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil || env.Magic != envelopeMagic {
+		return 0, fmt.Errorf("data is not an encrypted envelope")
+	}
+	return env.KeyVersion, nil
+}
+
+// ActiveVersion returns the tenant's current data key version (0 if none yet)
+func (k *KeyRing) ActiveVersion(tenant string) (int, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	keys, err := k.load(tenant)
+	if err != nil {
+		return 0, err
+	}
+	return keys.Active, nil
+}
+
+// Versions returns all data key versions still held for a tenant
+func (k *KeyRing) Versions(tenant string) ([]int, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	keys, err := k.load(tenant)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]int, 0, len(keys.Keys))
+	for _, key := range keys.Keys {
+		versions = append(versions, key.Version)
+	}
+	return versions, nil
+}
+
+// Rotate creates a new data key for the tenant and makes it active. Existing
+// data stays readable with the old key until it is re-encrypted and retired.
+func (k *KeyRing) Rotate(tenant string) (int, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	keys, err := k.load(tenant)
+	if err != nil {
+		return 0, err
+	}
+	return k.rotateLocked(keys)
+}
+
+// Retire destroys the tenant's data keys older than version below, except
+// those inUse still names, and returns how many it destroyed. Pass the
+// version data was just re-encrypted with and the versions stored envelopes
+// still reference: a rotation made while re-encrypting leaves older keys
+// that data written meanwhile depends on, and the active key is always kept.
+func (k *KeyRing) Retire(tenant string, below int, inUse map[int]bool) (int, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	keys, err := k.load(tenant)
+	if err != nil {
+		return 0, err
+	}
+
+	kept := keys.Keys[:0]
+	retired := 0
+	for _, key := range keys.Keys {
+		if key.Version >= below || key.Version == keys.Active || inUse[key.Version] {
+			kept = append(kept, key)
+		} else {
+			retired++
+		}
+	}
+	keys.Keys = kept
+	return retired, k.save(keys)
+}
+
+// rotateLocked adds a fresh data key. Callers must hold k.mu.
+func (k *KeyRing) rotateLocked(keys *tenantKeys) (int, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return 0, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	nonce := make([]byte, k.master.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return 0, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	version := 1
+	for _, key := range keys.Keys {
+		if key.Version >= version {
+			version = key.Version + 1
+		}
+	}
+
+	keys.Keys = append(keys.Keys, DataKey{
+		Version:   version,
+		Wrapped:   append(nonce, k.master.Seal(nil, nonce, raw, []byte(keys.Tenant))...),
+		CreatedAt: time.Now(),
+	})
+	keys.Active = version
+
+	if err := k.save(keys); err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// dataKey unwraps a tenant's data key. Callers must hold k.mu.
+func (k *KeyRing) dataKey(keys *tenantKeys, version int) (cipher.AEAD, error) {
+	for _, key := range keys.Keys {
+		if key.Version != version {
+			continue
+		}
+		size := k.master.NonceSize()
+		if len(key.Wrapped) < size {
+			return nil, fmt.Errorf("corrupt data key %d for %s", version, keys.Tenant)
+		}
+		raw, err := k.master.Open(nil, key.Wrapped[:size], key.Wrapped[size:], []byte(keys.Tenant))
+		if err != nil {
+			return nil, fmt.Errorf("failed to unwrap data key %d for %s: %w", version, keys.Tenant, err)
+		}
+		return newAEAD(raw)
+	}
+	return nil, fmt.Errorf("data key %d for %s not found (retired?)", version, keys.Tenant)
+}
+
+// load returns a tenant's key set, reading it on first use. Callers must hold k.mu.
+func (k *KeyRing) load(tenant string) (*tenantKeys, error) {
+	if err := ValidateID(tenant); err != nil {
+		return nil, err
+	}
+	if keys, ok := k.keys[tenant]; ok {
+		return keys, nil
+	}
+
+	keys := &tenantKeys{Tenant: tenant}
+	err := keysFormat.ReadFile(k.path(tenant), keys)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to load keys for %s: %w", tenant, err)
+	}
+
+	k.keys[tenant] = keys
+	return keys, nil
+}
+
+// save writes a tenant's wrapped keys atomically. Callers must hold k.mu.
+func (k *KeyRing) save(keys *tenantKeys) error {
+	if err := keysFormat.WriteFile(k.path(keys.Tenant), keys, 0600); err != nil {
+		return fmt.Errorf("failed to write keys: %w", err)
+	}
+	return nil
+}
+
+// path returns the key file for a tenant
+func (k *KeyRing) path(tenant string) string {
+	return filepath.Join(k.dir, tenant+".keys.json")
+}
+
+// newAEAD creates an AES-256-GCM cipher
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
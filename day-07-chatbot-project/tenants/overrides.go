@@ -0,0 +1,293 @@
+package tenants
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sakibmulla/agentic-ai/persist"
+)
+
+// DefaultTenant is used when no tenant is configured. It has no overrides
+// unless some are explicitly stored for it.
+const DefaultTenant = "default"
+
+// Source says where a resolved persona or template came from
+type Source string
+
+const (
+	SourceTenant Source = "tenant"
+	SourceGlobal Source = "global"
+)
+
+// validID restricts tenant IDs to safe file names
+var validID = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// overridesFormat versions the tenant override files
+var overridesFormat = persist.NewFormat("tenant-overrides", 1)
+
+// Overrides is the set of personas and templates a tenant has replaced or added
+type Overrides struct {
+	Tenant    string            `json:"tenant"`
+	Personas  map[string]string `json:"personas"`
+	Templates map[string]string `json:"templates"`
+	// Sampling holds sampling specs by persona, such as "top_k=40,min_p=0.05"
+	Sampling  map[string]string `json:"sampling,omitempty"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// OverrideStore keeps per-tenant overrides in one JSON file per tenant.
+//
+// Precedence: a tenant's override of a persona or template always wins;
+// anything the tenant has not overridden falls back to the global default.
+// Tenants may also add personas and templates that have no global default.
+type OverrideStore struct {
+	dir   string
+	cache map[string]*Overrides
+	mu    sync.Mutex
+}
+
+// NewOverrideStore creates a store rooted at dir
+func NewOverrideStore(dir string) (*OverrideStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create tenant directory: %w", err)
+	}
+	return &OverrideStore{
+		dir:   dir,
+		cache: make(map[string]*Overrides),
+	}, nil
+}
+
+// ValidateID checks that a tenant ID is usable
+func ValidateID(tenant string) error {
+	if !validID.MatchString(tenant) {
+		return fmt.Errorf("invalid tenant id %q: use 1-64 letters, digits, '-' or '_'", tenant)
+	}
+	return nil
+}
+
+// Get returns a copy of a tenant's overrides (empty if none are stored)
+func (s *OverrideStore) Get(tenant string) (Overrides, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, err := s.load(tenant)
+	if err != nil {
+		return Overrides{}, err
+	}
+	return copyOverrides(o), nil
+}
+
+// ResolvePersona returns the tenant's system prompt for mode, falling back
+// to the global prompt from defaults
+func (s *OverrideStore) ResolvePersona(tenant, mode string, defaults map[string]string) (string, Source, bool) {
+	s.mu.Lock()
+	o, err := s.load(tenant)
+	s.mu.Unlock()
+
+	if err == nil {
+		if prompt, ok := o.Personas[mode]; ok {
+			return prompt, SourceTenant, true
+		}
+	}
+	if prompt, ok := defaults[mode]; ok {
+		return prompt, SourceGlobal, true
+	}
+	return "", "", false
+}
+
+// ResolveTemplate returns the tenant's template text for name, falling back
+// to the global template from defaults
+func (s *OverrideStore) ResolveTemplate(tenant, name string, defaults map[string]string) (string, Source, bool) {
+	s.mu.Lock()
+	o, err := s.load(tenant)
+	s.mu.Unlock()
+
+	if err == nil {
+		if text, ok := o.Templates[name]; ok {
+			return text, SourceTenant, true
+		}
+	}
+	if text, ok := defaults[name]; ok {
+		return text, SourceGlobal, true
+	}
+	return "", "", false
+}
+
+// ResolveSampling returns the tenant's sampling spec for a persona, if it
+// has one. Options it doesn't set keep the global defaults.
+func (s *OverrideStore) ResolveSampling(tenant, mode string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, err := s.load(tenant)
+	if err != nil {
+		return "", false
+	}
+	spec, ok := o.Sampling[mode]
+	return spec, ok
+}
+
+// Personas lists the persona names visible to a tenant: the global ones plus
+// any the tenant added
+func (s *OverrideStore) Personas(tenant string, defaults map[string]string) []string {
+	s.mu.Lock()
+	o, err := s.load(tenant)
+	s.mu.Unlock()
+
+	names := make(map[string]bool, len(defaults))
+	for name := range defaults {
+		names[name] = true
+	}
+	if err == nil {
+		for name := range o.Personas {
+			names[name] = true
+		}
+	}
+
+	list := make([]string, 0, len(names))
+	for name := range names {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// SetPersona stores a tenant override for a persona's system prompt
+func (s *OverrideStore) SetPersona(tenant, mode, prompt string) error {
+	if mode == "" || prompt == "" {
+		return fmt.Errorf("persona name and prompt are required")
+	}
+	return s.modify(tenant, func(o *Overrides) {
+		o.Personas[mode] = prompt
+	})
+}
+
+// DeletePersona removes a tenant's persona override, restoring the global default
+func (s *OverrideStore) DeletePersona(tenant, mode string) error {
+	return s.modify(tenant, func(o *Overrides) {
+		delete(o.Personas, mode)
+	})
+}
+
+// SetSampling stores a tenant's sampling spec for a persona. The spec is
+// stored as given; callers validate it against the model.
+func (s *OverrideStore) SetSampling(tenant, mode, spec string) error {
+	if mode == "" || spec == "" {
+		return fmt.Errorf("persona name and sampling options are required")
+	}
+	return s.modify(tenant, func(o *Overrides) {
+		o.Sampling[mode] = spec
+	})
+}
+
+// DeleteSampling removes a tenant's sampling spec for a persona
+func (s *OverrideStore) DeleteSampling(tenant, mode string) error {
+	return s.modify(tenant, func(o *Overrides) {
+		delete(o.Sampling, mode)
+	})
+}
+
+// SetTemplate stores a tenant override for a template
+func (s *OverrideStore) SetTemplate(tenant, name, text string) error {
+	if name == "" || text == "" {
+		return fmt.Errorf("template name and text are required")
+	}
+	return s.modify(tenant, func(o *Overrides) {
+		o.Templates[name] = text
+	})
+}
+
+// DeleteTemplate removes a tenant's template override, restoring the global default
+func (s *OverrideStore) DeleteTemplate(tenant, name string) error {
+	return s.modify(tenant, func(o *Overrides) {
+		delete(o.Templates, name)
+	})
+}
+
+// modify applies fn to a tenant's overrides and persists them
+func (s *OverrideStore) modify(tenant string, fn func(o *Overrides)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, err := s.load(tenant)
+	if err != nil {
+		return err
+	}
+
+	fn(o)
+	o.UpdatedAt = time.Now()
+	return s.save(o)
+}
+
+// load returns the cached overrides for a tenant, reading them from disk on
+// first use. Callers must hold s.mu.
+func (s *OverrideStore) load(tenant string) (*Overrides, error) {
+	if err := ValidateID(tenant); err != nil {
+		return nil, err
+	}
+	if o, ok := s.cache[tenant]; ok {
+		return o, nil
+	}
+
+	o := &Overrides{
+		Tenant:    tenant,
+		Personas:  make(map[string]string),
+		Templates: make(map[string]string),
+		Sampling:  make(map[string]string),
+	}
+
+	err := overridesFormat.ReadFile(s.path(tenant), o)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to load overrides for %s: %w", tenant, err)
+	}
+	if err == nil {
+		if o.Personas == nil {
+			o.Personas = make(map[string]string)
+		}
+		if o.Templates == nil {
+			o.Templates = make(map[string]string)
+		}
+		if o.Sampling == nil {
+			o.Sampling = make(map[string]string)
+		}
+	}
+
+	s.cache[tenant] = o
+	return o, nil
+}
+
+// save writes a tenant's overrides atomically. Callers must hold s.mu.
+func (s *OverrideStore) save(o *Overrides) error {
+	if err := overridesFormat.WriteFile(s.path(o.Tenant), o, 0644); err != nil {
+		return fmt.Errorf("failed to write overrides: %w", err)
+	}
+	return nil
+}
+
+// path returns the overrides file for a tenant
+func (s *OverrideStore) path(tenant string) string {
+	return filepath.Join(s.dir, tenant+".json")
+}
+
+// copyOverrides returns a deep copy so callers can't mutate the cache
+func copyOverrides(o *Overrides) Overrides {
+	copied := *o
+	copied.Personas = make(map[string]string, len(o.Personas))
+	for k, v := range o.Personas {
+		copied.Personas[k] = v
+	}
+	copied.Templates = make(map[string]string, len(o.Templates))
+	for k, v := range o.Templates {
+		copied.Templates[k] = v
+	}
+	copied.Sampling = make(map[string]string, len(o.Sampling))
+	for k, v := range o.Sampling {
+		copied.Sampling[k] = v
+	}
+	return copied
+}
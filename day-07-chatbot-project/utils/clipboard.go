@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// clipboardCommand describes an external program that accepts clipboard
+// contents on stdin
+type clipboardCommand struct {
+	name string
+	args []string
+}
+
+// clipboardCommands returns the candidate clipboard programs for the current OS
+func clipboardCommands() []clipboardCommand {
+	switch runtime.GOOS {
+	case "darwin":
+		return []clipboardCommand{{name: "pbcopy"}}
+	case "windows":
+		return []clipboardCommand{{name: "clip"}}
+	default:
+		return []clipboardCommand{
+			{name: "wl-copy"},
+			{name: "xclip", args: []string{"-selection", "clipboard"}},
+			{name: "xsel", args: []string{"--clipboard", "--input"}},
+			{name: "clip.exe"}, // WSL
+		}
+	}
+}
+
+// CopyToClipboard writes text to the system clipboard using the first
+// available clipboard program
+func CopyToClipboard(text string) error {
+	for _, candidate := range clipboardCommands() {
+		path, err := exec.LookPath(candidate.name)
+		if err != nil {
+			continue
+		}
+
+		cmd := exec.Command(path, candidate.args...)
+		cmd.Stdin = strings.NewReader(text)
+		if err := cmd.Run(); err != nil {
+			return NewChatbotError(ErrorTypeInternal, fmt.Sprintf("%s failed", candidate.name), err)
+		}
+		return nil
+	}
+
+	return NewChatbotError(ErrorTypeConfig, "no clipboard program found (install xclip, xsel or wl-copy)", nil)
+}
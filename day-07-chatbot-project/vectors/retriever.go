@@ -0,0 +1,71 @@
+package vectors
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Retriever gives the bot the passages closest to each message. It
+// implements chatbot.Retriever.
+type Retriever struct {
+	store *Store
+	// topK is how many passages are retrieved per message
+	topK int
+	// minScore drops passages too dissimilar to help
+	minScore float64
+
+	mu   sync.Mutex
+	last []Result
+}
+
+// NewRetriever creates a retriever over store
+func NewRetriever(store *Store, topK int, minScore float64) *Retriever {
+	return &Retriever{store: store, topK: topK, minScore: minScore}
+}
+
+// Retrieve returns the passages for message numbered for citation, or ""
+// when none is similar enough
+func (r *Retriever) Retrieve(ctx context.Context, message string) (string, error) {
+	var results []Result
+	if r.store.Stats().Passages > 0 {
+		found, err := r.store.Search(ctx, message, r.topK)
+		if err != nil {
+			return "", err
+		}
+		for _, result := range found {
+			if result.Score >= r.minScore {
+				results = append(results, result)
+			}
+		}
+	}
+
+	r.mu.Lock()
+	r.last = results
+	r.mu.Unlock()
+	return formatPassages(results), nil
+}
+
+// LastResults returns the passages retrieved for the latest message
+func (r *Retriever) LastResults() []Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.last
+}
+
+// Reset forgets the latest results, before a message that may skip retrieval
+func (r *Retriever) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.last = nil
+}
+
+// formatPassages numbers passages with their document so the model can cite them
+func formatPassages(results []Result) string {
+	var sb strings.Builder
+	for i, result := range results {
+		fmt.Fprintf(&sb, "[%d] %s\n%s\n\n", i+1, result.DocumentID, result.Text)
+	}
+	return strings.TrimSpace(sb.String())
+}
@@ -0,0 +1,273 @@
+// Package vectors is a small embedding store for documents the chatbot
+// answers from. Documents are split into passages, embedded with the chat
+// provider and searched by cosine similarity.
+package vectors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sakibmulla/agentic-ai/persist"
+)
+
+// maxPassageChars bounds a passage, so each embeds one topic
+const maxPassageChars = 1200
+
+// storeFormat versions the store file
+var storeFormat = persist.NewFormat("vectors", 1)
+
+// Embedder turns texts into vectors
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	// Model names the embedding model; vectors from different models can't
+	// be compared
+	Model() string
+}
+
+// Document is a text added to the store. Adding a document with an ID
+// already stored replaces it.
+type Document struct {
+	ID       string            `json:"id"`
+	Text     string            `json:"text"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Result is a passage matching a query
+type Result struct {
+	DocumentID string            `json:"document_id"`
+	Passage    int               `json:"passage"`
+	Text       string            `json:"text"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	Score      float64           `json:"score"`
+}
+
+// Stats describes the store
+type Stats struct {
+	Model     string `json:"model"`
+	Documents int    `json:"documents"`
+	Passages  int    `json:"passages"`
+}
+
+// storedDocument is a document with its embedded passages
+type storedDocument struct {
+	Metadata map[string]string `json:"metadata,omitempty"`
+	AddedAt  time.Time         `json:"added_at"`
+	Passages []passage         `json:"passages"`
+}
+
+// passage is a piece of a document with its unit-length vector
+type passage struct {
+	Text   string    `json:"text"`
+	Vector []float32 `json:"vector"`
+}
+
+// storeFile is the persisted store
+type storeFile struct {
+	Model     string                     `json:"model"`
+	Documents map[string]*storedDocument `json:"documents"`
+}
+
+// Store holds embedded documents, optionally persisted to a file
+type Store struct {
+	embedder Embedder
+	path     string
+
+	mu   sync.RWMutex
+	data storeFile
+}
+
+// NewStore creates a store that embeds with embedder, loading the documents
+// saved at path. An empty path keeps the store in memory only.
+func NewStore(embedder Embedder, path string) (*Store, error) {
+	s := &Store{
+		embedder: embedder,
+		path:     path,
+		data:     storeFile{Model: embedder.Model(), Documents: make(map[string]*storedDocument)},
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	var saved storeFile
+	err := storeFormat.ReadFile(path, &saved)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load vector store: %w", err)
+	}
+	if len(saved.Documents) > 0 && saved.Model != embedder.Model() {
+		return nil, fmt.Errorf("vector store %s was embedded with %s, not %s: delete it to re-add the documents", path, saved.Model, embedder.Model())
+	}
+	for id, doc := range saved.Documents {
+		s.data.Documents[id] = doc
+	}
+	return s, nil
+}
+
+// Add embeds a document and stores it, returning how many passages it was
+// split into
+func (s *Store) Add(ctx context.Context, doc Document) (int, error) {
+	if doc.ID == "" || strings.TrimSpace(doc.Text) == "" {
+		return 0, fmt.Errorf("a document needs an id and text")
+	}
+	texts := split(doc.Text, maxPassageChars)
+	vectors, err := s.embedder.Embed(ctx, texts)
+	if err != nil {
+		return 0, fmt.Errorf("failed to embed document: %w", err)
+	}
+
+	stored := &storedDocument{Metadata: doc.Metadata, AddedAt: time.Now(), Passages: make([]passage, len(texts))}
+	for i, text := range texts {
+		stored.Passages[i] = passage{Text: text, Vector: normalize(vectors[i])}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Documents[doc.ID] = stored
+	return len(texts), s.save()
+}
+
+// Delete removes a document, reporting whether it was stored
+func (s *Store) Delete(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.data.Documents[id]; !ok {
+		return false, nil
+	}
+	delete(s.data.Documents, id)
+	return true, s.save()
+}
+
+// Search returns the k passages most similar to query, best first
+func (s *Store) Search(ctx context.Context, query string, k int) ([]Result, error) {
+	vectors, err := s.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	q := normalize(vectors[0])
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var results []Result
+	for id, doc := range s.data.Documents {
+		for i, p := range doc.Passages {
+			results = append(results, Result{DocumentID: id, Passage: i, Text: p.Text, Metadata: doc.Metadata, Score: dot(q, p.Vector)})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if results[i].DocumentID != results[j].DocumentID {
+			return results[i].DocumentID < results[j].DocumentID
+		}
+		return results[i].Passage < results[j].Passage
+	})
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results, nil
+}
+
+// Stats describes the store
+func (s *Store) Stats() Stats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := Stats{Model: s.data.Model, Documents: len(s.data.Documents)}
+	for _, doc := range s.data.Documents {
+		stats.Passages += len(doc.Passages)
+	}
+	return stats
+}
+
+// save persists the store. Callers must hold s.mu.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+	if err := storeFormat.WriteFile(s.path, s.data, 0644); err != nil {
+		return fmt.Errorf("failed to write vector store: %w", err)
+	}
+	return nil
+}
+
+// split breaks text into passages of at most maxChars, keeping paragraphs
+// together where they fit
+func split(text string, maxChars int) []string {
+	var passages []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			passages = append(passages, current.String())
+			current.Reset()
+		}
+	}
+
+	for _, paragraph := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+		if current.Len() > 0 && current.Len()+2+len(paragraph) > maxChars {
+			flush()
+		}
+		// A paragraph too long on its own is cut between words
+		for len(paragraph) > maxChars {
+			cut := strings.LastIndexAny(paragraph[:maxChars], " \n")
+			if cut <= 0 {
+				cut = maxChars
+				for !utf8.RuneStart(paragraph[cut]) {
+					cut--
+				}
+			}
+			passages = append(passages, strings.TrimSpace(paragraph[:cut]))
+			paragraph = strings.TrimSpace(paragraph[cut:])
+		}
+		if current.Len() > 0 {
+			current.WriteString("\n\n")
+		}
+		current.WriteString(paragraph)
+	}
+	flush()
+	return passages
+}
+
+// normalize scales v to unit length, so similarity is a dot product
+func normalize(v []float32) []float32 {
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	if norm == 0 {
+		return v
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = x * scale
+	}
+	return out
+}
+
+// dot returns the dot product of two vectors of the same length
+func dot(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
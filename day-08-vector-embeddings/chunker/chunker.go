@@ -0,0 +1,380 @@
+// Package chunker splits large documents into overlapping chunks sized for
+// embedding. Boundaries fall between paragraphs or sentences; a sentence is
+// only cut when it is longer than a whole chunk.
+package chunker
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Format is the kind of text being chunked
+type Format string
+
+const (
+	Plain    Format = "plain"
+	Markdown Format = "markdown"
+	// PDFText is text extracted from a PDF (e.g. by pdftotext): hard line
+	// wraps, hyphenated line breaks and form feeds between pages
+	PDFText Format = "pdf-text"
+)
+
+// charsPerToken matches the rough token estimate used across the course
+const charsPerToken = 4
+
+// Config controls chunk size and overlap, both in estimated tokens
+type Config struct {
+	Size    int
+	Overlap int
+	// Format selects the parsing rules; empty means Plain
+	Format Format
+}
+
+// DefaultConfig suits prose: chunks of about 300 tokens sharing about 50
+func DefaultConfig() Config {
+	return Config{Size: 300, Overlap: 50, Format: Plain}
+}
+
+// Chunk is a piece of a document
+type Chunk struct {
+	// Index is the chunk's position in the document, from 0
+	Index int
+	Text  string
+	// Heading is the markdown section the chunk belongs to, as "Title > Section"
+	Heading string
+	Tokens  int
+}
+
+// EstimateTokens gives a rough token count (~4 characters per token)
+func EstimateTokens(text string) int {
+	return len(text) / charsPerToken
+}
+
+// DetectFormat guesses a document's format from its file name and content
+func DetectFormat(path, text string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return Markdown
+	}
+	if strings.ContainsRune(text, '\f') {
+		return PDFText
+	}
+	return Plain
+}
+
+// unit is the smallest piece the packer places: a sentence, a code block,
+// or part of one too long for a chunk
+type unit struct {
+	text string
+	// paragraph marks the first unit of a paragraph
+	paragraph bool
+}
+
+// section is a run of text under one markdown heading
+type section struct {
+	heading string
+	body    string
+}
+
+// Split chunks text. Consecutive chunks share up to Overlap tokens of
+// whole sentences; markdown chunks never span two sections.
+func Split(text string, config Config) []Chunk {
+	if config.Size <= 0 {
+		config.Size = DefaultConfig().Size
+	}
+	if config.Overlap < 0 || config.Overlap >= config.Size {
+		config.Overlap = 0
+	}
+
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	var sections []section
+	switch config.Format {
+	case Markdown:
+		sections = markdownSections(text)
+	case PDFText:
+		sections = []section{{body: normalizePDFText(text)}}
+	default:
+		sections = []section{{body: text}}
+	}
+
+	maxChars := config.Size * charsPerToken
+	var chunks []Chunk
+	for _, s := range sections {
+		for _, text := range pack(units(s.body, maxChars, config.Format == Markdown), maxChars, config.Overlap*charsPerToken) {
+			chunks = append(chunks, Chunk{
+				Index:   len(chunks),
+				Text:    text,
+				Heading: s.heading,
+				Tokens:  EstimateTokens(text),
+			})
+		}
+	}
+	return chunks
+}
+
+// pack fills chunks with units up to maxChars, starting each chunk after the
+// first with the trailing units of the previous one that fit overlapChars
+func pack(units []unit, maxChars, overlapChars int) []string {
+	var chunks []string
+	var current []unit
+	size := 0
+	fresh := 0 // units in current that are not overlap
+
+	flush := func() {
+		if fresh == 0 {
+			return
+		}
+		chunks = append(chunks, join(current))
+
+		var carried []unit
+		shared := 0
+		for i := len(current) - 1; i >= 0; i-- {
+			if shared+len(current[i].text)+1 > overlapChars {
+				break
+			}
+			shared += len(current[i].text) + 1
+			carried = append([]unit{current[i]}, carried...)
+		}
+		current, size, fresh = carried, shared, 0
+	}
+
+	for _, u := range units {
+		if size+len(u.text)+1 > maxChars {
+			flush()
+			// Drop overlap that would leave no room for the next unit
+			for len(current) > 0 && size+len(u.text)+1 > maxChars {
+				size -= len(current[0].text) + 1
+				current = current[1:]
+			}
+		}
+		current = append(current, u)
+		size += len(u.text) + 1
+		fresh++
+	}
+	flush()
+	return chunks
+}
+
+// join rebuilds text from units, keeping paragraph breaks
+func join(units []unit) string {
+	var builder strings.Builder
+	for i, u := range units {
+		switch {
+		case i == 0:
+		case u.paragraph:
+			builder.WriteString("\n\n")
+		default:
+			builder.WriteString(" ")
+		}
+		builder.WriteString(u.text)
+	}
+	return builder.String()
+}
+
+// sentenceEnd matches the end of a sentence and the space after it
+var sentenceEnd = regexp.MustCompile(`[.!?]["')\]]*\s+`)
+
+// units splits a body into paragraphs and paragraphs into sentences. In
+// markdown, fenced code blocks, lists and tables stay whole where they fit.
+func units(body string, maxChars int, markdown bool) []unit {
+	var result []unit
+	for _, paragraph := range paragraphs(body, markdown) {
+		if markdown && isStructured(paragraph) {
+			// Pieces of a split block each start a paragraph so line breaks survive joining
+			for _, piece := range splitLines(paragraph, maxChars) {
+				result = append(result, unit{text: piece, paragraph: true})
+			}
+			continue
+		}
+		for i, piece := range sentences(strings.Join(strings.Fields(paragraph), " "), maxChars) {
+			result = append(result, unit{text: piece, paragraph: i == 0})
+		}
+	}
+	return result
+}
+
+// paragraphs splits on blank lines, keeping a markdown code fence together
+// even when it contains blank lines
+func paragraphs(body string, markdown bool) []string {
+	var result []string
+	var current []string
+	inFence := false
+	flush := func() {
+		if text := strings.TrimSpace(strings.Join(current, "\n")); text != "" {
+			result = append(result, text)
+		}
+		current = nil
+	}
+
+	for _, line := range strings.Split(body, "\n") {
+		if markdown && strings.HasPrefix(strings.TrimSpace(line), "```") {
+			if !inFence {
+				flush()
+			}
+			current = append(current, line)
+			inFence = !inFence
+			if !inFence {
+				flush()
+			}
+			continue
+		}
+		if !inFence && strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		current = append(current, line)
+	}
+	flush()
+	return result
+}
+
+// isStructured reports whether a markdown block is code, a list or a table,
+// whose line breaks matter
+func isStructured(block string) bool {
+	trimmed := strings.TrimSpace(block)
+	for _, prefix := range []string{"```", "- ", "* ", "|", "1. "} {
+		if strings.HasPrefix(trimmed, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// sentences splits a paragraph at sentence ends, cutting any sentence
+// longer than maxChars at word boundaries
+func sentences(paragraph string, maxChars int) []string {
+	var result []string
+	start := 0
+	for _, loc := range sentenceEnd.FindAllStringIndex(paragraph, -1) {
+		result = append(result, splitWords(strings.TrimSpace(paragraph[start:loc[1]]), maxChars)...)
+		start = loc[1]
+	}
+	if rest := strings.TrimSpace(paragraph[start:]); rest != "" {
+		result = append(result, splitWords(rest, maxChars)...)
+	}
+	return result
+}
+
+// splitWords cuts text longer than maxChars into pieces at word boundaries
+func splitWords(text string, maxChars int) []string {
+	if len(text) <= maxChars {
+		return []string{text}
+	}
+	var pieces []string
+	var current strings.Builder
+	for _, word := range strings.Fields(text) {
+		if current.Len() > 0 && current.Len()+1+len(word) > maxChars {
+			pieces = append(pieces, current.String())
+			current.Reset()
+		}
+		if current.Len() > 0 {
+			current.WriteString(" ")
+		}
+		current.WriteString(word)
+	}
+	if current.Len() > 0 {
+		pieces = append(pieces, current.String())
+	}
+	return pieces
+}
+
+// splitLines cuts a structured block longer than maxChars at line breaks
+func splitLines(block string, maxChars int) []string {
+	if len(block) <= maxChars {
+		return []string{block}
+	}
+	var pieces []string
+	var current []string
+	size := 0
+	for _, line := range strings.Split(block, "\n") {
+		if len(current) > 0 && size+len(line)+1 > maxChars {
+			pieces = append(pieces, strings.Join(current, "\n"))
+			current, size = nil, 0
+		}
+		current = append(current, line)
+		size += len(line) + 1
+	}
+	if len(current) > 0 {
+		pieces = append(pieces, strings.Join(current, "\n"))
+	}
+
+	// A single line can still be too long
+	var result []string
+	for _, piece := range pieces {
+		result = append(result, splitWords(piece, maxChars)...)
+	}
+	return result
+}
+
+// headingPattern matches an ATX markdown heading
+var headingPattern = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+
+// markdownSections splits markdown at headings, naming each section by its
+// heading trail. Headings inside code fences are ignored.
+func markdownSections(text string) []section {
+	type heading struct {
+		level int
+		name  string
+	}
+	var sections []section
+	var trail []heading
+	var body []string
+	inFence := false
+	flush := func() {
+		if strings.TrimSpace(strings.Join(body, "\n")) == "" {
+			body = nil
+			return
+		}
+		names := make([]string, len(trail))
+		for i, h := range trail {
+			names[i] = h.name
+		}
+		sections = append(sections, section{heading: strings.Join(names, " > "), body: strings.Join(body, "\n")})
+		body = nil
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+		}
+		match := headingPattern.FindStringSubmatch(line)
+		if inFence || match == nil {
+			body = append(body, line)
+			continue
+		}
+
+		flush()
+		level := len(match[1])
+		for len(trail) > 0 && trail[len(trail)-1].level >= level {
+			trail = trail[:len(trail)-1]
+		}
+		trail = append(trail, heading{level: level, name: match[2]})
+	}
+	flush()
+	return sections
+}
+
+// Patterns for cleaning PDF-extracted text
+var (
+	hyphenBreak = regexp.MustCompile(`(\p{L})-\n\s*(\p{Ll})`)
+	pageNumber  = regexp.MustCompile(`(?m)^[ \t]*(Page[ \t]+)?\d+([ \t]+of[ \t]+\d+)?[ \t]*(\n|$)`)
+	blankLines  = regexp.MustCompile(`\n\s*\n`)
+)
+
+// normalizePDFText undoes PDF extraction artifacts: it rejoins hyphenated
+// words, drops page breaks and bare page numbers, and unwraps hard-wrapped
+// lines while keeping paragraph breaks
+func normalizePDFText(text string) string {
+	text = strings.ReplaceAll(text, "\f", "\n\n")
+	text = pageNumber.ReplaceAllString(text, "")
+	text = hyphenBreak.ReplaceAllString(text, "$1$2")
+
+	var paragraphs []string
+	for _, paragraph := range blankLines.Split(text, -1) {
+		if joined := strings.Join(strings.Fields(paragraph), " "); joined != "" {
+			paragraphs = append(paragraphs, joined)
+		}
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
@@ -0,0 +1,143 @@
+package chunker
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// numberedSentences returns n sentences of 40 characters, 10 tokens each
+func numberedSentences(n int) []string {
+	sentences := make([]string, n)
+	for i := range sentences {
+		prefix := fmt.Sprintf("Sentence %02d says ", i)
+		sentences[i] = prefix + strings.Repeat("x", 39-len(prefix)) + "."
+	}
+	return sentences
+}
+
+func TestSplitOverlap(t *testing.T) {
+	sentences := numberedSentences(8)
+	// A 30-token chunk holds two sentences; a 15-token overlap carries one
+	chunks := Split(strings.Join(sentences, " "), Config{Size: 30, Overlap: 15})
+
+	if len(chunks) != 7 {
+		t.Fatalf("Expected 7 chunks, got %d: %q", len(chunks), chunks)
+	}
+	for i, chunk := range chunks {
+		want := sentences[i] + " " + sentences[i+1]
+		if chunk.Index != i || chunk.Text != want {
+			t.Errorf("Chunk %d: expected %q, got %d %q", i, want, chunk.Index, chunk.Text)
+		}
+		if chunk.Tokens != EstimateTokens(chunk.Text) || chunk.Tokens > 30 {
+			t.Errorf("Chunk %d: expected at most 30 estimated tokens, got %d", i, chunk.Tokens)
+		}
+	}
+}
+
+func TestSplitWithoutOverlap(t *testing.T) {
+	sentences := numberedSentences(7)
+	text := strings.Join(sentences, " ")
+	for _, overlap := range []int{0, 30, -1} {
+		chunks := Split(text, Config{Size: 30, Overlap: overlap})
+		var texts []string
+		for _, chunk := range chunks {
+			texts = append(texts, chunk.Text)
+		}
+		// An overlap as large as the chunk is ignored
+		if len(chunks) != 4 || strings.Join(texts, " ") != text {
+			t.Errorf("Overlap %d: expected 4 disjoint chunks covering the text, got %q", overlap, texts)
+		}
+	}
+}
+
+func TestSplitTokenBoundaries(t *testing.T) {
+	// Sentences and paragraphs are kept whole when they fit
+	text := "First paragraph, first sentence. First paragraph, second one.\n\nSecond paragraph here."
+	chunks := Split(text, Config{Size: 100})
+	if len(chunks) != 1 || chunks[0].Text != text {
+		t.Errorf("Expected the text as one chunk with its paragraph break, got %q", chunks)
+	}
+
+	// A sentence longer than a chunk is cut between words
+	words := strings.Repeat("word ", 30)
+	chunks = Split(words+"end.", Config{Size: 10})
+	for _, chunk := range chunks {
+		if len(chunk.Text) > 40 {
+			t.Errorf("Expected chunks of at most 40 characters, got %d: %q", len(chunk.Text), chunk.Text)
+		}
+		for _, word := range strings.Fields(chunk.Text) {
+			if word != "word" && word != "end." {
+				t.Errorf("Expected whole words, got %q in %q", word, chunk.Text)
+			}
+		}
+	}
+	if len(chunks) != 4 {
+		t.Errorf("Expected 4 chunks, got %d", len(chunks))
+	}
+
+	// Overlap is dropped rather than leave no room for the next sentence
+	long := strings.Repeat("y", 110) + "."
+	chunks = Split("Short one. "+long, Config{Size: 30, Overlap: 10})
+	if len(chunks) != 2 || chunks[1].Text != long {
+		t.Errorf("Expected the long sentence alone, got %q", chunks)
+	}
+}
+
+func TestSplitMarkdown(t *testing.T) {
+	text := strings.Join([]string{
+		"# Guide",
+		"Intro text.",
+		"## Install",
+		"Run the installer.",
+		"```sh",
+		"# not a heading",
+		"",
+		"make install",
+		"```",
+		"## Use",
+		"- one",
+		"- two",
+	}, "\n")
+	chunks := Split(text, Config{Size: 100, Format: Markdown})
+
+	want := []struct{ heading, text string }{
+		{"Guide", "Intro text."},
+		{"Guide > Install", "Run the installer.\n\n```sh\n# not a heading\n\nmake install\n```"},
+		{"Guide > Use", "- one\n- two"},
+	}
+	if len(chunks) != len(want) {
+		t.Fatalf("Expected a chunk per section, got %q", chunks)
+	}
+	for i, w := range want {
+		if chunks[i].Heading != w.heading || chunks[i].Text != w.text {
+			t.Errorf("Chunk %d: expected %q %q, got %q %q", i, w.heading, w.text, chunks[i].Heading, chunks[i].Text)
+		}
+	}
+}
+
+func TestNormalizePDFText(t *testing.T) {
+	text := "The embed-\nding model maps\ntext to vectors.\n\n3\n\fPage 4 of 9\nA new para-\ngraph."
+	chunks := Split(text, Config{Size: 100, Format: PDFText})
+	want := "The embedding model maps text to vectors.\n\nA new paragraph."
+	if len(chunks) != 1 || chunks[0].Text != want {
+		t.Errorf("Expected %q, got %q", want, chunks)
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		path, text string
+		want       Format
+	}{
+		{"notes.md", "", Markdown},
+		{"README.MARKDOWN", "", Markdown},
+		{"paper.txt", "page one\fpage two", PDFText},
+		{"notes.txt", "plain", Plain},
+	}
+	for _, tt := range tests {
+		if got := DetectFormat(tt.path, tt.text); got != tt.want {
+			t.Errorf("DetectFormat(%q) = %s, expected %s", tt.path, got, tt.want)
+		}
+	}
+}
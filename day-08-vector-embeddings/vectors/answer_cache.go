@@ -0,0 +1,160 @@
+package vectors
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cachedAnswer is an answer stored with the chunks it was generated from
+type cachedAnswer struct {
+	answer   string
+	chunkIDs []string
+	storedAt time.Time
+}
+
+// AnswerCacheStats summarizes cache effectiveness
+type AnswerCacheStats struct {
+	Entries       int
+	Hits          int
+	Misses        int
+	Invalidations int
+}
+
+// AnswerCache stores RAG answers keyed by the normalized question and the
+// ranked retrieval set (chunk IDs plus content hashes). An identical question
+// over unchanged chunks is a hit; updating or deleting any chunk drops every
+// answer that cited it.
+type AnswerCache struct {
+	maxEntries int
+	entries    map[string]*cachedAnswer
+	byChunk    map[string]map[string]bool // chunk ID -> cache keys
+	order      []string                   // live keys in insertion order, for eviction
+	stats      AnswerCacheStats
+	mu         sync.Mutex
+}
+
+// NewAnswerCache creates a cache holding at most maxEntries answers
+func NewAnswerCache(maxEntries int) *AnswerCache {
+	if maxEntries <= 0 {
+		maxEntries = 500
+	}
+	return &AnswerCache{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*cachedAnswer),
+		byChunk:    make(map[string]map[string]bool),
+	}
+}
+
+// AnswerCacheKey derives the cache key for a question and its retrieved chunks
+func AnswerCacheKey(question string, results []SearchResult) string {
+	parts := make([]string, 0, len(results))
+	for _, result := range results {
+		textHash := sha256.Sum256([]byte(result.Embedding.Text))
+		parts = append(parts, result.Embedding.ID+"@"+hex.EncodeToString(textHash[:8]))
+	}
+	// Passages are numbered in ranked order and the answer cites them by
+	// number, so the same chunks in a different order are a different key
+
+	key := sha256.Sum256([]byte(normalizeQuestion(question) + "\n" + strings.Join(parts, "\n")))
+	return hex.EncodeToString(key[:])
+}
+
+// normalizeQuestion lowercases, collapses whitespace and drops trailing punctuation
+func normalizeQuestion(question string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(question)), " ")
+	return strings.TrimRight(normalized, "?!. ")
+}
+
+// Get returns a cached answer
+func (c *AnswerCache) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		c.stats.Misses++
+		return "", false
+	}
+	c.stats.Hits++
+	return entry.answer, true
+}
+
+// Put stores an answer along with the IDs of the chunks it cites
+func (c *AnswerCache) Put(key, answer string, chunkIDs []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// A replaced answer keeps its place in the eviction order, but may
+	// cite different chunks
+	if old, exists := c.entries[key]; exists {
+		c.unindexLocked(key, old.chunkIDs)
+	} else {
+		c.order = append(c.order, key)
+	}
+	c.entries[key] = &cachedAnswer{answer: answer, chunkIDs: chunkIDs, storedAt: time.Now()}
+	for _, id := range chunkIDs {
+		if c.byChunk[id] == nil {
+			c.byChunk[id] = make(map[string]bool)
+		}
+		c.byChunk[id][key] = true
+	}
+
+	for len(c.entries) > c.maxEntries && len(c.order) > 0 {
+		c.removeLocked(c.order[0])
+	}
+}
+
+// InvalidateChunk drops every answer that cited the chunk and returns how many were removed
+func (c *AnswerCache) InvalidateChunk(id string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for key := range c.byChunk[id] {
+		if _, ok := c.entries[key]; ok {
+			c.removeLocked(key)
+			removed++
+		}
+	}
+	delete(c.byChunk, id)
+	c.stats.Invalidations += removed
+	return removed
+}
+
+// Stats returns a snapshot of cache statistics
+func (c *AnswerCache) Stats() AnswerCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = len(c.entries)
+	return stats
+}
+
+// removeLocked deletes an entry, its chunk index and its place in the
+// eviction order. Callers must hold c.mu.
+func (c *AnswerCache) removeLocked(key string) {
+	entry, ok := c.entries[key]
+	if !ok {
+		return
+	}
+	delete(c.entries, key)
+	c.unindexLocked(key, entry.chunkIDs)
+	if i := slices.Index(c.order, key); i >= 0 {
+		c.order = slices.Delete(c.order, i, i+1)
+	}
+}
+
+// unindexLocked drops key from the chunks it cited. Callers must hold c.mu.
+func (c *AnswerCache) unindexLocked(key string, chunkIDs []string) {
+	for _, id := range chunkIDs {
+		delete(c.byChunk[id], key)
+		if len(c.byChunk[id]) == 0 {
+			delete(c.byChunk, id)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package vectors
+
+import (
+	"fmt"
+	"testing"
+)
+
+func cacheResults(texts ...string) []SearchResult {
+	results := make([]SearchResult, len(texts))
+	for i, text := range texts {
+		results[i] = SearchResult{Embedding: Embedding{ID: fmt.Sprint("chunk-", i), Text: text}}
+	}
+	return results
+}
+
+func TestAnswerCacheKey(t *testing.T) {
+	results := cacheResults("alpha", "beta")
+	key := AnswerCacheKey("What is alpha?", results)
+
+	if got := AnswerCacheKey("  what IS   alpha ", cacheResults("alpha", "beta")); got != key {
+		t.Errorf("Expected a normalized question over the same chunks to match, got %s and %s", got, key)
+	}
+	if got := AnswerCacheKey("What is beta?", results); got == key {
+		t.Error("Expected a different question to change the key")
+	}
+	if got := AnswerCacheKey("What is alpha?", cacheResults("alpha", "gamma")); got == key {
+		t.Error("Expected changed chunk content to change the key")
+	}
+	// Citations are passage numbers, so a reordered retrieval set can't
+	// reuse the answer
+	reordered := []SearchResult{results[1], results[0]}
+	if got := AnswerCacheKey("What is alpha?", reordered); got == key {
+		t.Error("Expected reordered passages to change the key")
+	}
+}
+
+func TestAnswerCacheInvalidation(t *testing.T) {
+	cache := NewAnswerCache(10)
+	cache.Put("a", "answer a [1]", []string{"chunk-0", "chunk-1"})
+	cache.Put("b", "answer b [1]", []string{"chunk-1"})
+	cache.Put("c", "answer c [1]", []string{"chunk-2"})
+
+	if answer, ok := cache.Get("a"); !ok || answer != "answer a [1]" {
+		t.Fatalf("Expected a cached answer, got %q, %v", answer, ok)
+	}
+	if removed := cache.InvalidateChunk("chunk-1"); removed != 2 {
+		t.Errorf("Expected both answers citing chunk-1 dropped, got %d", removed)
+	}
+	for _, key := range []string{"a", "b"} {
+		if _, ok := cache.Get(key); ok {
+			t.Errorf("Expected %s invalidated", key)
+		}
+	}
+	if _, ok := cache.Get("c"); !ok {
+		t.Error("Expected an answer citing other chunks to survive")
+	}
+	if removed := cache.InvalidateChunk("chunk-1"); removed != 0 {
+		t.Errorf("Expected nothing left to invalidate, got %d", removed)
+	}
+
+	stats := cache.Stats()
+	if stats.Entries != 1 || stats.Hits != 2 || stats.Misses != 2 || stats.Invalidations != 2 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestAnswerCacheEviction(t *testing.T) {
+	cache := NewAnswerCache(2)
+	cache.Put("a", "answer a", []string{"chunk-0"})
+	cache.Put("b", "answer b", []string{"chunk-1"})
+	cache.Put("a", "answer a again", []string{"chunk-0"})
+	cache.Put("c", "answer c", []string{"chunk-2"})
+
+	if _, ok := cache.Get("a"); ok {
+		t.Error("Expected the oldest entry evicted, even after it was replaced")
+	}
+	for _, key := range []string{"b", "c"} {
+		if _, ok := cache.Get(key); !ok {
+			t.Errorf("Expected %s kept", key)
+		}
+	}
+	if stats := cache.Stats(); stats.Entries != 2 {
+		t.Errorf("Expected 2 entries, got %d", stats.Entries)
+	}
+	// The evicted entry no longer counts as citing its chunk
+	if removed := cache.InvalidateChunk("chunk-0"); removed != 0 {
+		t.Errorf("Expected nothing to invalidate for an evicted entry, got %d", removed)
+	}
+}
+
+func TestAnswerCacheReplace(t *testing.T) {
+	cache := NewAnswerCache(2)
+	cache.Put("a", "answer a", []string{"chunk-0"})
+	cache.Put("a", "answer a again", []string{"chunk-1"})
+
+	// The replaced answer no longer cites chunk-0
+	if removed := cache.InvalidateChunk("chunk-0"); removed != 0 {
+		t.Errorf("Expected the old citation dropped on replace, got %d removed", removed)
+	}
+	if answer, ok := cache.Get("a"); !ok || answer != "answer a again" {
+		t.Errorf("Expected the replacement kept, got %q, %v", answer, ok)
+	}
+	if removed := cache.InvalidateChunk("chunk-1"); removed != 1 {
+		t.Errorf("Expected the new citation indexed, got %d removed", removed)
+	}
+
+	// A key stored again after invalidation is queued once, so it doesn't
+	// push out live entries early
+	cache.Put("a", "answer a", []string{"chunk-0"})
+	cache.Put("b", "answer b", []string{"chunk-1"})
+	if len(cache.order) != 2 {
+		t.Errorf("Expected each live key queued once, got %v", cache.order)
+	}
+	cache.Put("c", "answer c", []string{"chunk-2"})
+	if _, ok := cache.Get("b"); !ok {
+		t.Error("Expected b kept when c evicts the oldest entry")
+	}
+	if _, ok := cache.Get("a"); ok {
+		t.Error("Expected a evicted")
+	}
+}
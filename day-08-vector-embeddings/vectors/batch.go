@@ -0,0 +1,200 @@
+package vectors
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Embedding API limits, shared by OpenAI's embedding models
+const (
+	// maxInputTokens is the longest single input the model accepts
+	maxInputTokens = 8191
+	// maxRequestInputs is the most inputs one embeddings request may carry
+	maxRequestInputs = 2048
+)
+
+// Document is a text to embed and store
+type Document struct {
+	ID       string
+	Text     string
+	Metadata map[string]interface{}
+}
+
+// BatchConfig controls how AddDocuments groups and sends embedding requests
+type BatchConfig struct {
+	// MaxInputs and MaxTokens bound a single request (estimated tokens)
+	MaxInputs int
+	MaxTokens int
+	// Concurrency is how many requests may be in flight at once
+	Concurrency int
+}
+
+// DefaultBatchConfig keeps requests well inside the API limits, so one
+// failed request only affects a modest number of documents
+func DefaultBatchConfig() BatchConfig {
+	return BatchConfig{
+		MaxInputs:   256,
+		MaxTokens:   50000,
+		Concurrency: 4,
+	}
+}
+
+// DocumentError is a document that could not be embedded
+type DocumentError struct {
+	ID  string
+	Err error
+}
+
+// BatchReport summarizes an AddDocuments call
+type BatchReport struct {
+	Added    int
+	Requests int
+	Failed   []DocumentError
+}
+
+// AddDocuments embeds documents with as few API calls as the batch limits
+// allow and stores the ones that succeed, in input order. When some
+// documents fail, the report lists them and the error summarizes them.
+func (vs *VectorStore) AddDocuments(ctx context.Context, docs []Document) (*BatchReport, error) {
+	return vs.AddDocumentsWithConfig(ctx, docs, DefaultBatchConfig())
+}
+
+// AddDocumentsWithConfig is AddDocuments with explicit batch limits
+func (vs *VectorStore) AddDocumentsWithConfig(ctx context.Context, docs []Document, config BatchConfig) (*BatchReport, error) {
+	report := &BatchReport{}
+	vectors := make([][]float64, len(docs))
+	errs := make([]error, len(docs))
+
+	// Reject inputs the API would refuse, so they can't fail a whole batch
+	var valid []int
+	for i, doc := range docs {
+		switch {
+		case doc.Text == "":
+			errs[i] = fmt.Errorf("document text is empty")
+		case estimateTokens(doc.Text) > maxInputTokens:
+			errs[i] = fmt.Errorf("document is about %d tokens, over the %d token input limit", estimateTokens(doc.Text), maxInputTokens)
+		default:
+			valid = append(valid, i)
+		}
+	}
+
+	batches := planBatches(docs, valid, config)
+	report.Requests = len(batches)
+
+	concurrency := config.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, batch := range batches {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(batch []int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			embeddings, err := vs.embedBatch(ctx, docs, batch)
+			for j, i := range batch {
+				if err != nil {
+					errs[i] = err
+				} else {
+					vectors[i] = embeddings[j]
+				}
+			}
+		}(batch)
+	}
+	wg.Wait()
+
+	var embedded []Embedding
+	for i, doc := range docs {
+		if errs[i] != nil {
+			report.Failed = append(report.Failed, DocumentError{ID: doc.ID, Err: errs[i]})
+			continue
+		}
+		embedded = append(embedded, vs.newEmbedding(doc.ID, doc.Text, vectors[i], doc.Metadata))
+	}
+	if len(embedded) > 0 {
+		if err := vs.put(ctx, embedded...); err != nil {
+			return report, fmt.Errorf("failed to store %d embedded documents: %w", len(embedded), err)
+		}
+		report.Added = len(embedded)
+	}
+
+	if len(report.Failed) > 0 {
+		return report, fmt.Errorf("%d of %d documents failed to embed (first: %s: %v)",
+			len(report.Failed), len(docs), report.Failed[0].ID, report.Failed[0].Err)
+	}
+	return report, nil
+}
+
+// planBatches groups document indexes into requests within the input and
+// token limits, keeping input order
+func planBatches(docs []Document, indexes []int, config BatchConfig) [][]int {
+	maxInputs := config.MaxInputs
+	if maxInputs < 1 || maxInputs > maxRequestInputs {
+		maxInputs = maxRequestInputs
+	}
+
+	var batches [][]int
+	var current []int
+	tokens := 0
+	for _, i := range indexes {
+		docTokens := estimateTokens(docs[i].Text)
+		if len(current) > 0 && (len(current) >= maxInputs || (config.MaxTokens > 0 && tokens+docTokens > config.MaxTokens)) {
+			batches = append(batches, current)
+			current, tokens = nil, 0
+		}
+		current = append(current, i)
+		tokens += docTokens
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+	return batches
+}
+
+// embedBatch embeds the documents at indexes with one request, returning
+// the vectors in the same order
+func (vs *VectorStore) embedBatch(ctx context.Context, docs []Document, indexes []int) ([][]float64, error) {
+	inputs := make([]string, len(indexes))
+	for j, i := range indexes {
+		inputs[j] = docs[i].Text
+	}
+
+	return vs.model.embed(ctx, vs.client, inputs)
+}
+
+// toVectors converts a response's embeddings to vectors in input order. The
+// API reports each embedding's input index; don't rely on response order.
+// The vectors share one backing array, so a batch costs one allocation
+// rather than one per document.
+func toVectors(data []openai.Embedding) ([][]float64, error) {
+	total := 0
+	for _, embedding := range data {
+		total += len(embedding.Embedding)
+	}
+	backing := make([]float64, total)
+
+	vectors := make([][]float64, len(data))
+	for _, embedding := range data {
+		if embedding.Index < 0 || embedding.Index >= len(data) || vectors[embedding.Index] != nil {
+			return nil, fmt.Errorf("unexpected embedding index %d", embedding.Index)
+		}
+		n := len(embedding.Embedding)
+		vectors[embedding.Index] = toFloat64Into(backing[:n:n], embedding.Embedding)
+		backing = backing[n:]
+	}
+	return vectors, nil
+}
+
+// toFloat64Into converts embedding into dst, which must be as long
+func toFloat64Into(dst []float64, embedding []float32) []float64 {
+	for i, v := range embedding {
+		dst[i] = float64(v)
+	}
+	return dst
+}
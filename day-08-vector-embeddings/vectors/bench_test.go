@@ -0,0 +1,108 @@
+package vectors
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/sakibmulla/agentic-ai/endpoint"
+	"github.com/sashabaranov/go-openai"
+)
+
+// benchDimensions matches text-embedding-ada-002
+const benchDimensions = 1536
+
+func randomVector(rng *rand.Rand) []float64 {
+	vector := make([]float64, benchDimensions)
+	for i := range vector {
+		vector[i] = rng.Float64()*2 - 1
+	}
+	return vector
+}
+
+// newBenchStore returns a store of n random documents
+func newBenchStore(n int) *VectorStore {
+	rng := rand.New(rand.NewSource(1))
+	store := NewVectorStore("test-key", endpoint.Endpoint{}, nil)
+	store.embeddings = make([]Embedding, 0, n)
+	for i := 0; i < n; i++ {
+		store.embeddings = append(store.embeddings, store.newEmbedding(fmt.Sprintf("doc-%d", i), "", randomVector(rng), nil))
+	}
+	return store
+}
+
+var (
+	largeStore     *VectorStore
+	largeStoreOnce sync.Once
+)
+
+func BenchmarkCosineSimilarity(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	x, y := randomVector(rng), randomVector(rng)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		CosineSimilarity(x, y)
+	}
+}
+
+func BenchmarkDot32(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	x, y := unitVector(randomVector(rng)), unitVector(randomVector(rng))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dot32(x, y)
+	}
+}
+
+func BenchmarkRank(b *testing.B) {
+	store := newBenchStore(1000)
+	query := randomVector(rand.New(rand.NewSource(2)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		store.rank(query, 5, nil)
+	}
+}
+
+// BenchmarkRank100k compares scoring a 100k-document corpus with
+// CosineSimilarity on the stored float64 vectors against rank's dot
+// products on precomputed float32 unit vectors. The corpus takes about
+// 2 GB.
+func BenchmarkRank100k(b *testing.B) {
+	largeStoreOnce.Do(func() { largeStore = newBenchStore(100000) })
+	query := randomVector(rand.New(rand.NewSource(2)))
+
+	b.Run("float64-cosine", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			best := -1.0
+			for j := range largeStore.embeddings {
+				if similarity := CosineSimilarity(query, largeStore.embeddings[j].Vector); similarity > best {
+					best = similarity
+				}
+			}
+		}
+	})
+	b.Run("float32-unit", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			largeStore.rank(query, 5, nil)
+		}
+	})
+}
+
+func BenchmarkToVectors(b *testing.B) {
+	data := make([]openai.Embedding, 100)
+	for i := range data {
+		data[i] = openai.Embedding{Index: i, Embedding: make([]float32, benchDimensions)}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := toVectors(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package vectors
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Chunk is a piece of a document ready to be embedded
+type Chunk struct {
+	ID       string                 `json:"id"`
+	Text     string                 `json:"text"`
+	Metadata map[string]interface{} `json:"metadata"`
+}
+
+// CodeChunker splits source files on function and type boundaries so that
+// each chunk holds complete declarations
+type CodeChunker struct {
+	// MaxTokens is the token budget per chunk. Small neighbouring
+	// declarations are merged up to this size; larger ones are split by lines.
+	MaxTokens int
+}
+
+// NewCodeChunker creates a code chunker with the given token budget
+func NewCodeChunker(maxTokens int) *CodeChunker {
+	if maxTokens <= 0 {
+		maxTokens = 400
+	}
+	return &CodeChunker{MaxTokens: maxTokens}
+}
+
+// codeSection is a contiguous range of lines describing one or more declarations
+type codeSection struct {
+	startLine int // 1-based
+	endLine   int // inclusive
+	symbols   []string
+	kind      string
+}
+
+// boundaryPatterns detect top-level definitions in languages without a parser
+var boundaryPatterns = map[string]*regexp.Regexp{
+	".py":   regexp.MustCompile(`^(async\s+def|def|class)\s+(\w+)`),
+	".js":   regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?(?:async\s+)?(function\*?|class|const|let)\s+(\w+)`),
+	".ts":   regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?(?:async\s+)?(function\*?|class|interface|type|const|enum)\s+(\w+)`),
+	".java": regexp.MustCompile(`^\s{0,4}(?:public|private|protected|static|final|abstract|\s)*(class|interface|enum|[\w<>\[\]]+)\s+(\w+)\s*[({]`),
+	".rs":   regexp.MustCompile(`^(?:pub(?:\([\w:]+\))?\s+)?(fn|struct|enum|trait|impl|mod)\s+(\w+)`),
+	".rb":   regexp.MustCompile(`^\s{0,2}(def|class|module)\s+([\w.?!]+)`),
+}
+
+// ChunkFile splits a source file into chunks. Go files are parsed with
+// go/parser; other languages use line-based boundary heuristics.
+func (cc *CodeChunker) ChunkFile(path string, src []byte) ([]Chunk, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	lines := strings.Split(string(src), "\n")
+
+	var sections []codeSection
+	metadata := map[string]interface{}{
+		"path":     path,
+		"language": strings.TrimPrefix(ext, "."),
+	}
+
+	if ext == ".go" {
+		pkg, goSections, err := goSections(path, src)
+		if err != nil {
+			return nil, err
+		}
+		metadata["package"] = pkg
+		sections = goSections
+	} else {
+		sections = heuristicSections(lines, boundaryPatterns[ext])
+	}
+
+	sections = cc.mergeSmall(sections, lines)
+
+	var chunks []Chunk
+	for _, section := range sections {
+		for _, part := range cc.splitLarge(section, lines) {
+			chunkMeta := make(map[string]interface{}, len(metadata)+4)
+			for k, v := range metadata {
+				chunkMeta[k] = v
+			}
+			chunkMeta["line"] = part.startLine
+			chunkMeta["end_line"] = part.endLine
+			chunkMeta["symbols"] = part.symbols
+			chunkMeta["kind"] = part.kind
+
+			chunks = append(chunks, Chunk{
+				ID:       fmt.Sprintf("%s:%d", path, part.startLine),
+				Text:     strings.Join(lines[part.startLine-1:part.endLine], "\n"),
+				Metadata: chunkMeta,
+			})
+		}
+	}
+
+	return chunks, nil
+}
+
+// goSections returns one section per top-level declaration, including its doc comment
+func goSections(path string, src []byte) (string, []codeSection, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	var sections []codeSection
+
+	// Package clause and imports form the header section
+	headerEnd := fset.Position(file.Name.End()).Line
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			headerEnd = fset.Position(gen.End()).Line
+		}
+	}
+	sections = append(sections, codeSection{startLine: 1, endLine: headerEnd, kind: "header"})
+
+	for _, decl := range file.Decls {
+		start := decl.Pos()
+		var symbols []string
+		var kind string
+
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Doc != nil {
+				start = d.Doc.Pos()
+			}
+			kind = "func"
+			name := d.Name.Name
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				kind = "method"
+				name = receiverName(d.Recv.List[0].Type) + "." + name
+			}
+			symbols = []string{name}
+
+		case *ast.GenDecl:
+			if d.Tok == token.IMPORT {
+				continue
+			}
+			if d.Doc != nil {
+				start = d.Doc.Pos()
+			}
+			kind = d.Tok.String()
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					symbols = append(symbols, s.Name.Name)
+				case *ast.ValueSpec:
+					for _, name := range s.Names {
+						symbols = append(symbols, name.Name)
+					}
+				}
+			}
+		}
+
+		sections = append(sections, codeSection{
+			startLine: fset.Position(start).Line,
+			endLine:   fset.Position(decl.End()).Line,
+			symbols:   symbols,
+			kind:      kind,
+		})
+	}
+
+	return file.Name.Name, sections, nil
+}
+
+// receiverName returns the type name of a method receiver
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(t.X)
+	case *ast.IndexExpr:
+		return receiverName(t.X)
+	case *ast.IndexListExpr:
+		return receiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	default:
+		return ""
+	}
+}
+
+// heuristicSections starts a new section at every line matching pattern.
+// Comment and decorator lines directly above a definition stay with it.
+func heuristicSections(lines []string, pattern *regexp.Regexp) []codeSection {
+	if pattern == nil {
+		return []codeSection{{startLine: 1, endLine: len(lines), kind: "file"}}
+	}
+
+	var sections []codeSection
+	current := codeSection{startLine: 1, kind: "header"}
+
+	for i, line := range lines {
+		match := pattern.FindStringSubmatch(line)
+		if match == nil || i == 0 {
+			if match != nil {
+				current.kind = match[1]
+				current.symbols = []string{match[2]}
+			}
+			continue
+		}
+
+		// Pull preceding comments/decorators into the new section
+		start := i
+		for start > current.startLine && isLeadingLine(lines[start-1]) {
+			start--
+		}
+
+		current.endLine = start
+		if current.endLine >= current.startLine {
+			sections = append(sections, current)
+		}
+		current = codeSection{startLine: start + 1, kind: match[1], symbols: []string{match[2]}}
+	}
+
+	current.endLine = len(lines)
+	return append(sections, current)
+}
+
+// isLeadingLine reports whether a line belongs to the definition below it
+func isLeadingLine(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, "#") ||
+		strings.HasPrefix(trimmed, "//") ||
+		strings.HasPrefix(trimmed, "@") ||
+		strings.HasPrefix(trimmed, "/*") ||
+		strings.HasPrefix(trimmed, "*")
+}
+
+// mergeSmall joins neighbouring sections while they fit in the token budget
+func (cc *CodeChunker) mergeSmall(sections []codeSection, lines []string) []codeSection {
+	var merged []codeSection
+
+	for _, section := range sections {
+		if len(merged) > 0 {
+			last := &merged[len(merged)-1]
+			combined := strings.Join(lines[last.startLine-1:section.endLine], "\n")
+			if estimateTokens(combined) <= cc.MaxTokens {
+				last.endLine = section.endLine
+				last.symbols = append(last.symbols, section.symbols...)
+				if last.kind != section.kind {
+					last.kind = "mixed"
+				}
+				continue
+			}
+		}
+		merged = append(merged, section)
+	}
+
+	return merged
+}
+
+// splitLarge breaks a section that exceeds the token budget into line ranges
+func (cc *CodeChunker) splitLarge(section codeSection, lines []string) []codeSection {
+	text := strings.Join(lines[section.startLine-1:section.endLine], "\n")
+	if estimateTokens(text) <= cc.MaxTokens {
+		return []codeSection{section}
+	}
+
+	var parts []codeSection
+	start := section.startLine
+	tokens := 0
+	for line := section.startLine; line <= section.endLine; line++ {
+		lineTokens := estimateTokens(lines[line-1]) + 1
+		if tokens+lineTokens > cc.MaxTokens && line > start {
+			parts = append(parts, codeSection{startLine: start, endLine: line - 1, symbols: section.symbols, kind: section.kind})
+			start = line
+			tokens = 0
+		}
+		tokens += lineTokens
+	}
+
+	return append(parts, codeSection{startLine: start, endLine: section.endLine, symbols: section.symbols, kind: section.kind})
+}
+
+// estimateTokens provides a rough token count estimate
+func estimateTokens(text string) int {
+	// Rough estimation: ~4 characters per token
+	return len(text) / 4
+}
+
+// AddChunks embeds and stores chunks in batches; chunks that fail are
+// reported in the error while the rest are still stored
+func (vs *VectorStore) AddChunks(ctx context.Context, chunks []Chunk) error {
+	docs := make([]Document, len(chunks))
+	for i, chunk := range chunks {
+		docs[i] = Document{ID: chunk.ID, Text: chunk.Text, Metadata: chunk.Metadata}
+	}
+	if _, err := vs.AddDocuments(ctx, docs); err != nil {
+		return fmt.Errorf("failed to add chunks: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package vectors
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// goFixture has a header and three declarations, each kept whole
+var goFixture = []string{
+	"package demo\n\nimport (\n\t\"fmt\"\n\t\"strings\"\n)",
+	"// Greeter says hello to someone by name\ntype Greeter struct {\n\tName string\n}",
+	"// Hello greets by name\nfunc (g *Greeter) Hello() string {\n\treturn fmt.Sprintf(\"hello %s\", g.Name)\n}",
+	"func Add(a, b int) int {\n\treturn a + b\n}",
+}
+
+// chunkSymbols returns the symbols each chunk holds
+func chunkSymbols(chunks []Chunk) [][]string {
+	symbols := make([][]string, len(chunks))
+	for i, chunk := range chunks {
+		symbols[i], _ = chunk.Metadata["symbols"].([]string)
+	}
+	return symbols
+}
+
+func TestCodeChunkerGoBoundaries(t *testing.T) {
+	src := strings.Join(goFixture, "\n\n")
+
+	// A budget too small to merge two declarations, but large enough for each
+	chunks, err := NewCodeChunker(25).ChunkFile("demo/greeter.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != len(goFixture) {
+		t.Fatalf("Expected a chunk per declaration, got %d: %q", len(chunks), chunks)
+	}
+	for i, chunk := range chunks {
+		if chunk.Text != goFixture[i] {
+			t.Errorf("Chunk %d: expected %q, got %q", i, goFixture[i], chunk.Text)
+		}
+	}
+	if got := fmt.Sprint(chunkSymbols(chunks)); got != "[[] [Greeter] [Greeter.Hello] [Add]]" {
+		t.Errorf("Unexpected symbols %s", got)
+	}
+	hello := chunks[2]
+	if hello.ID != "demo/greeter.go:13" || hello.Metadata["kind"] != "method" || hello.Metadata["package"] != "demo" ||
+		hello.Metadata["line"] != 13 || hello.Metadata["end_line"] != 16 {
+		t.Errorf("Unexpected chunk %s: %v", hello.ID, hello.Metadata)
+	}
+
+	// A larger budget merges whole declarations, never parts of them
+	chunks, err = NewCodeChunker(60).ChunkFile("demo/greeter.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 2 || chunks[0].Text != strings.Join(goFixture[:3], "\n\n") || chunks[1].Text != goFixture[3] {
+		t.Errorf("Expected the first three sections merged, got %q", chunks)
+	}
+	if chunks[0].Metadata["kind"] != "mixed" {
+		t.Errorf("Expected merged kinds to be mixed, got %v", chunks[0].Metadata["kind"])
+	}
+
+	if _, err := NewCodeChunker(0).ChunkFile("broken.go", []byte("package demo\nfunc {")); err == nil {
+		t.Error("Expected a parse error")
+	}
+}
+
+func TestCodeChunkerSplitsOversized(t *testing.T) {
+	var body []string
+	for i := 0; i < 40; i++ {
+		body = append(body, fmt.Sprintf("\ttotal += %d * value", i))
+	}
+	big := "func Big(value int) int {\n\ttotal := 0\n" + strings.Join(body, "\n") + "\n\treturn total\n}"
+	src := "package demo\n\n" + big
+
+	chunker := NewCodeChunker(50)
+	chunks, err := chunker.ChunkFile("big.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parts []string
+	for _, chunk := range chunks[1:] {
+		parts = append(parts, chunk.Text)
+		if estimateTokens(chunk.Text) > chunker.MaxTokens {
+			t.Errorf("Expected parts within %d tokens, got %d", chunker.MaxTokens, estimateTokens(chunk.Text))
+		}
+		if symbols := chunk.Metadata["symbols"].([]string); len(symbols) != 1 || symbols[0] != "Big" {
+			t.Errorf("Expected every part labelled Big, got %v", symbols)
+		}
+	}
+	if len(parts) < 3 || strings.Join(parts, "\n") != big {
+		t.Errorf("Expected the function split by lines into parts that rebuild it, got %d parts", len(parts))
+	}
+	for i := 1; i < len(chunks)-1; i++ {
+		if chunks[i].Metadata["end_line"].(int)+1 != chunks[i+1].Metadata["line"].(int) {
+			t.Errorf("Expected consecutive line ranges, got %v and %v", chunks[i].Metadata, chunks[i+1].Metadata)
+		}
+	}
+}
+
+func TestCodeChunkerHeuristicBoundaries(t *testing.T) {
+	sections := []string{
+		"import os",
+		"@cached\ndef load(path):\n    return open(path).read()",
+		"# Store keeps documents\nclass Store:\n    pass",
+	}
+	chunks, err := NewCodeChunker(14).ChunkFile("store.py", []byte(strings.Join(sections, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 3 {
+		t.Fatalf("Expected a chunk per definition, got %q", chunks)
+	}
+	for i, chunk := range chunks {
+		if chunk.Text != sections[i] {
+			t.Errorf("Chunk %d: expected %q with its decorator or comment, got %q", i, sections[i], chunk.Text)
+		}
+	}
+	if got := fmt.Sprint(chunkSymbols(chunks)); got != "[[] [load] [Store]]" || chunks[1].Metadata["language"] != "py" {
+		t.Errorf("Unexpected symbols %s", got)
+	}
+
+	// Files in other languages are one section
+	chunks, err = NewCodeChunker(100).ChunkFile("notes.txt", []byte("one\ntwo"))
+	if err != nil || len(chunks) != 1 || chunks[0].Metadata["kind"] != "file" {
+		t.Errorf("Expected the whole file as one chunk, got %q, %v", chunks, err)
+	}
+}
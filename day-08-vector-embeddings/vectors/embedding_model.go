@@ -0,0 +1,159 @@
+package vectors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// EmbeddingModel is the model a store embeds with and the length of the
+// vectors it returns. Vectors from different models can't be compared,
+// even when their lengths happen to match.
+type EmbeddingModel struct {
+	Name string
+	// Dimensions is the vector length. text-embedding-3 models can return
+	// shortened vectors; other models only their native length.
+	Dimensions int
+}
+
+// Known embedding models, at their native dimensions
+var (
+	AdaV2  = EmbeddingModel{Name: string(openai.AdaEmbeddingV2), Dimensions: 1536}
+	Small3 = EmbeddingModel{Name: string(openai.SmallEmbedding3), Dimensions: 1536}
+	Large3 = EmbeddingModel{Name: string(openai.LargeEmbedding3), Dimensions: 3072}
+)
+
+// knownEmbeddingModels are the models ParseEmbeddingModel knows the
+// dimensions of
+var knownEmbeddingModels = []EmbeddingModel{AdaV2, Small3, Large3}
+
+// ErrMixedEmbeddings is returned by a search whose query vector can't be
+// compared with every stored vector
+var ErrMixedEmbeddings = errors.New("embeddings from different models")
+
+// ParseEmbeddingModel reads "name" or "name:dimensions", e.g.
+// "text-embedding-3-large:1024". A known model without dimensions gets its
+// native ones; an unknown one learns them from its first embedding.
+func ParseEmbeddingModel(text string) (EmbeddingModel, error) {
+	name, dims, hasDims := strings.Cut(strings.TrimSpace(text), ":")
+	model := EmbeddingModel{Name: strings.TrimSpace(name)}
+	if model.Name == "" {
+		return model, fmt.Errorf("embedding model name is empty")
+	}
+	for _, known := range knownEmbeddingModels {
+		if known.Name == model.Name {
+			model.Dimensions = known.Dimensions
+		}
+	}
+	if hasDims {
+		n, err := strconv.Atoi(strings.TrimSpace(dims))
+		if err != nil || n <= 0 {
+			return model, fmt.Errorf("invalid dimensions in embedding model %q", text)
+		}
+		if model.Dimensions != 0 && n != model.Dimensions && !model.shortens() {
+			return model, fmt.Errorf("%s only returns %d dimensions", model.Name, model.Dimensions)
+		}
+		model.Dimensions = n
+	}
+	return model, nil
+}
+
+// shortens reports whether the model can be asked for fewer dimensions
+func (m EmbeddingModel) shortens() bool {
+	return strings.HasPrefix(m.Name, "text-embedding-3")
+}
+
+// String returns "name (N dimensions)"
+func (m EmbeddingModel) String() string {
+	if m.Dimensions == 0 {
+		return m.Name
+	}
+	return fmt.Sprintf("%s (%d dimensions)", m.Name, m.Dimensions)
+}
+
+// request builds an embeddings request for inputs
+func (m EmbeddingModel) request(inputs []string) openai.EmbeddingRequest {
+	req := openai.EmbeddingRequest{Input: inputs, Model: openai.EmbeddingModel(m.Name)}
+	if m.shortens() {
+		req.Dimensions = m.Dimensions
+	}
+	return req
+}
+
+// embed embeds inputs with the model in one request, returning the vectors
+// in input order. A vector of the wrong length is an error, so a
+// misconfigured model can't reach the store.
+func (m EmbeddingModel) embed(ctx context.Context, client *openai.Client, inputs []string) ([][]float64, error) {
+	resp, err := client.CreateEmbeddings(ctx, m.request(inputs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embeddings: %w", err)
+	}
+	if len(resp.Data) != len(inputs) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(inputs), len(resp.Data))
+	}
+
+	vectors, err := toVectors(resp.Data)
+	if err != nil {
+		return nil, err
+	}
+	for _, vector := range vectors {
+		if m.Dimensions != 0 && len(vector) != m.Dimensions {
+			return nil, fmt.Errorf("%s returned %d dimensions, expected %d", m.Name, len(vector), m.Dimensions)
+		}
+	}
+	return vectors, nil
+}
+
+// EmbeddingModel returns the model the store embeds with
+func (vs *VectorStore) EmbeddingModel() EmbeddingModel {
+	return vs.model
+}
+
+// SetEmbeddingModel switches an empty store, or one already embedded with
+// model, to model. A store holding other vectors needs Migrate, which
+// re-embeds them.
+func (vs *VectorStore) SetEmbeddingModel(model EmbeddingModel) error {
+	for _, embedding := range vs.embeddings {
+		if !model.matches(embedding) {
+			return fmt.Errorf("store holds %s vectors: migrate it to %s instead", embeddingModelOf(embedding), model)
+		}
+	}
+	vs.model = model
+	return nil
+}
+
+// matches reports whether embedding was made by the model, or could have
+// been: embeddings stored before models were recorded only have a length
+func (m EmbeddingModel) matches(embedding Embedding) bool {
+	if embedding.Model != "" && embedding.Model != m.Name {
+		return false
+	}
+	return m.Dimensions == 0 || len(embedding.Vector) == m.Dimensions
+}
+
+// embeddingModelOf returns the model recorded for embedding
+func embeddingModelOf(embedding Embedding) EmbeddingModel {
+	name := embedding.Model
+	if name == "" {
+		name = "unrecorded model"
+	}
+	return EmbeddingModel{Name: name, Dimensions: len(embedding.Vector)}
+}
+
+// checkEmbeddings returns ErrMixedEmbeddings, naming the first offender,
+// when a query embedded with the store's model can't be compared with
+// every stored vector
+func (vs *VectorStore) checkEmbeddings(queryDimensions int) error {
+	query := EmbeddingModel{Name: vs.model.Name, Dimensions: queryDimensions}
+	for _, embedding := range vs.embeddings {
+		if !query.matches(embedding) {
+			return fmt.Errorf("%w: the query is %s but document %s is %s; migrate the store to one model",
+				ErrMixedEmbeddings, query, embedding.ID, embeddingModelOf(embedding))
+		}
+	}
+	return nil
+}
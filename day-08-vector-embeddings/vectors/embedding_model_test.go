@@ -0,0 +1,130 @@
+package vectors
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sakibmulla/agentic-ai/endpoint"
+	"github.com/sashabaranov/go-openai"
+)
+
+// newEmbeddingServer fakes the embeddings API: each input gets a vector of
+// the requested dimensions, or 4 by default, whose first element is the
+// input's length. Inputs containing "fail" fail their request.
+func newEmbeddingServer(t *testing.T) *VectorStore {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input      []string `json:"input"`
+			Dimensions int      `json:"dimensions"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("bad request: %v", err)
+		}
+		dims := req.Dimensions
+		if dims == 0 {
+			dims = 4
+		}
+		resp := openai.EmbeddingResponse{}
+		for i, input := range req.Input {
+			if strings.Contains(input, "fail") {
+				http.Error(w, `{"error":{"message":"boom"}}`, http.StatusInternalServerError)
+				return
+			}
+			vector := make([]float32, dims)
+			vector[0], vector[dims-1] = float32(len(input)), 1
+			resp.Data = append(resp.Data, openai.Embedding{Index: i, Embedding: vector})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+	return NewVectorStore("test-key", endpoint.Endpoint{BaseURL: server.URL + "/v1"}, nil)
+}
+
+func TestParseEmbeddingModel(t *testing.T) {
+	for text, want := range map[string]EmbeddingModel{
+		"text-embedding-ada-002":      AdaV2,
+		"text-embedding-3-large:1024": {Name: "text-embedding-3-large", Dimensions: 1024},
+		"nomic-embed-text":            {Name: "nomic-embed-text"},
+	} {
+		if got, err := ParseEmbeddingModel(text); err != nil || got != want {
+			t.Errorf("ParseEmbeddingModel(%q) = %v, %v; expected %v", text, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "text-embedding-ada-002:512", "text-embedding-3-small:zero"} {
+		if _, err := ParseEmbeddingModel(bad); err == nil {
+			t.Errorf("Expected %q rejected", bad)
+		}
+	}
+}
+
+func TestMixedEmbeddingsRefused(t *testing.T) {
+	ctx := context.Background()
+	store := newEmbeddingServer(t)
+	if err := store.SetEmbeddingModel(EmbeddingModel{Name: "text-embedding-3-small", Dimensions: 8}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.AddDocuments(ctx, []Document{{ID: "a", Text: "alpha"}, {ID: "b", Text: "beta"}}); err != nil {
+		t.Fatal(err)
+	}
+	if doc, _ := store.GetDocument("a"); doc.Model != "text-embedding-3-small" || doc.Dimensions != 8 {
+		t.Errorf("Expected the model recorded, got %s with %d dimensions", doc.Model, doc.Dimensions)
+	}
+	if err := store.SetEmbeddingModel(Large3); err == nil {
+		t.Error("Expected switching a populated store without migrating to fail")
+	}
+
+	// A vector left by another model makes searches fail, not rank it
+	store.embeddings = append(store.embeddings, Embedding{ID: "old", Vector: make([]float64, 1536), Model: AdaV2.Name})
+	if _, err := store.Search(ctx, "alpha", 2); !errors.Is(err, ErrMixedEmbeddings) || !strings.Contains(err.Error(), "old") {
+		t.Errorf("Expected ErrMixedEmbeddings naming the document, got %v", err)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	store := newEmbeddingServer(t)
+	if err := store.SetEmbeddingModel(EmbeddingModel{Name: "local", Dimensions: 4}); err != nil {
+		t.Fatal(err)
+	}
+	docs := []Document{{ID: "a", Text: "alpha"}, {ID: "b", Text: "beta"}, {ID: "c", Text: "gamma ray"}}
+	if _, err := store.AddDocuments(ctx, docs); err != nil {
+		t.Fatal(err)
+	}
+	var changed []string
+	store.OnChange(func(id string) { changed = append(changed, id) })
+
+	target := EmbeddingModel{Name: "text-embedding-3-small", Dimensions: 16}
+	var last MigrationProgress
+	calls := 0
+	report, err := store.Migrate(ctx, target, BatchConfig{MaxInputs: 2, Concurrency: 1}, func(p MigrationProgress) {
+		calls++
+		last = p
+	})
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if report.Migrated != 3 || report.Requests != 2 || calls != 2 || last.Done != 3 || last.Total != 3 {
+		t.Errorf("Unexpected report %+v or progress %+v after %d call(s)", report, last, calls)
+	}
+	if store.EmbeddingModel() != target || len(changed) != 3 {
+		t.Errorf("Expected the store on %s with every document changed, got %s and %v", target, store.EmbeddingModel(), changed)
+	}
+	if results, err := store.Search(ctx, "gamma ray", 1); err != nil || results[0].Embedding.ID != "c" || len(results[0].Embedding.Vector) != 16 {
+		t.Errorf("Expected searches with the new model, got %+v, %v", results, err)
+	}
+
+	// A failed document leaves the store as it was
+	store.embeddings = append(store.embeddings, store.newEmbedding("d", "fail", make([]float64, 16), nil))
+	if report, err := store.Migrate(ctx, EmbeddingModel{Name: "text-embedding-3-large", Dimensions: 32}, BatchConfig{MaxInputs: 1}, nil); err == nil || len(report.Failed) != 1 {
+		t.Fatalf("Expected one failed document, got %+v, %v", report, err)
+	}
+	if store.EmbeddingModel() != target {
+		t.Errorf("Expected the store left on %s, got %s", target, store.EmbeddingModel())
+	}
+}
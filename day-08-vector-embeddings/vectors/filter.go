@@ -0,0 +1,235 @@
+package vectors
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Condition tests one metadata value. Exactly one of Values (equality or
+// in-set) and a range (Min and Max, either of which may be infinite) is
+// used.
+type Condition struct {
+	Key string
+	// Values the metadata value may equal, compared as text
+	Values []string
+	// Min and Max bound a numeric value, inclusive unless the Exclusive
+	// flag is set
+	Min, Max                   float64
+	MinExclusive, MaxExclusive bool
+	isRange                    bool
+}
+
+// Filter keeps documents whose metadata meets every condition. The empty
+// filter keeps everything.
+type Filter []Condition
+
+// Eq matches documents whose key equals value
+func Eq(key string, value interface{}) Condition {
+	return Condition{Key: key, Values: []string{fmt.Sprint(value)}}
+}
+
+// In matches documents whose key equals any of values
+func In(key string, values ...interface{}) Condition {
+	condition := Condition{Key: key}
+	for _, value := range values {
+		condition.Values = append(condition.Values, fmt.Sprint(value))
+	}
+	return condition
+}
+
+// Range matches documents whose key is a number in [min, max]. Use
+// math.Inf for an open end.
+func Range(key string, min, max float64) Condition {
+	return Condition{Key: key, Min: min, Max: max, isRange: true}
+}
+
+// Matches reports whether metadata meets the condition. A missing key
+// never matches.
+func (c Condition) Matches(metadata map[string]interface{}) bool {
+	value, ok := metadata[c.Key]
+	if !ok || value == nil {
+		return false
+	}
+	if c.isRange {
+		n, ok := toNumber(value)
+		if !ok {
+			return false
+		}
+		if n < c.Min || (c.MinExclusive && n == c.Min) {
+			return false
+		}
+		return n < c.Max || (!c.MaxExclusive && n == c.Max)
+	}
+
+	text := fmt.Sprint(value)
+	for _, want := range c.Values {
+		if text == want {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches reports whether metadata meets every condition
+func (f Filter) Matches(metadata map[string]interface{}) bool {
+	for _, condition := range f {
+		if !condition.Matches(metadata) {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the filter as ParseFilter reads it
+func (f Filter) String() string {
+	parts := make([]string, len(f))
+	for i, c := range f {
+		switch {
+		case !c.isRange && len(c.Values) == 1:
+			parts[i] = c.Key + "=" + c.Values[0]
+		case !c.isRange:
+			parts[i] = c.Key + " in (" + strings.Join(c.Values, ", ") + ")"
+		default:
+			var bounds []string
+			if !math.IsInf(c.Min, -1) {
+				op := ">="
+				if c.MinExclusive {
+					op = ">"
+				}
+				bounds = append(bounds, c.Key+op+strconv.FormatFloat(c.Min, 'g', -1, 64))
+			}
+			if !math.IsInf(c.Max, 1) {
+				op := "<="
+				if c.MaxExclusive {
+					op = "<"
+				}
+				bounds = append(bounds, c.Key+op+strconv.FormatFloat(c.Max, 'g', -1, 64))
+			}
+			parts[i] = strings.Join(bounds, " and ")
+		}
+	}
+	return strings.Join(parts, " and ")
+}
+
+// ParseFilter reads conditions joined by "and":
+//
+//	category=AI
+//	category in (AI, ML)
+//	year>=2020 and year<2024
+//
+// Comparisons are >, >=, < and <=; bounds on the same key form one range.
+func ParseFilter(text string) (Filter, error) {
+	var filter Filter
+	ranges := make(map[string]int)
+	for _, part := range splitAnd(text) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, fmt.Errorf("empty condition in filter %q", text)
+		}
+
+		if key, list, ok := cutIn(part); ok {
+			list = strings.TrimSpace(list)
+			if !strings.HasPrefix(list, "(") || !strings.HasSuffix(list, ")") {
+				return nil, fmt.Errorf("invalid condition %q: expected %s in (a, b)", part, key)
+			}
+			condition := Condition{Key: key}
+			for _, value := range strings.Split(list[1:len(list)-1], ",") {
+				if value = strings.TrimSpace(value); value != "" {
+					condition.Values = append(condition.Values, value)
+				}
+			}
+			if len(condition.Values) == 0 {
+				return nil, fmt.Errorf("invalid condition %q: empty set", part)
+			}
+			filter = append(filter, condition)
+			continue
+		}
+
+		key, op, value, ok := cutComparison(part)
+		if !ok || key == "" || value == "" {
+			return nil, fmt.Errorf("invalid condition %q: expected key=value, key in (a, b) or a comparison such as key>=1", part)
+		}
+		if op == "=" {
+			filter = append(filter, Eq(key, value))
+			continue
+		}
+
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid condition %q: %s needs a number", part, op)
+		}
+		i, seen := ranges[key]
+		if !seen {
+			i = len(filter)
+			ranges[key] = i
+			filter = append(filter, Range(key, math.Inf(-1), math.Inf(1)))
+		}
+		switch op {
+		case ">", ">=":
+			filter[i].Min, filter[i].MinExclusive = n, op == ">"
+		case "<", "<=":
+			filter[i].Max, filter[i].MaxExclusive = n, op == "<"
+		}
+	}
+	return filter, nil
+}
+
+// splitAnd splits text on the word "and", in any case
+func splitAnd(text string) []string {
+	var parts []string
+	fields := strings.Fields(text)
+	start := 0
+	for i, field := range fields {
+		if strings.EqualFold(field, "and") {
+			parts = append(parts, strings.Join(fields[start:i], " "))
+			start = i + 1
+		}
+	}
+	return append(parts, strings.Join(fields[start:], " "))
+}
+
+// cutIn splits "key in (...)"
+func cutIn(part string) (key, list string, ok bool) {
+	fields := strings.Fields(part)
+	if len(fields) < 3 || !strings.EqualFold(fields[1], "in") {
+		return "", "", false
+	}
+	return fields[0], strings.Join(fields[2:], " "), true
+}
+
+// cutComparison splits "key op value", trying two-character operators first
+func cutComparison(part string) (key, op, value string, ok bool) {
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		if key, value, found := strings.Cut(part, op); found {
+			return strings.TrimSpace(key), op, strings.TrimSpace(value), true
+		}
+	}
+	return "", "", "", false
+}
+
+// toNumber reads a metadata value as a number: JSON numbers, Go numeric
+// types, or numeric text
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
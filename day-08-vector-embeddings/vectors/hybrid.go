@@ -0,0 +1,239 @@
+package vectors
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// SearchMode chooses how Search ranks documents
+type SearchMode string
+
+// Search modes
+const (
+	// VectorSearch ranks by cosine similarity alone
+	VectorSearch SearchMode = "vector"
+	// HybridSearch blends cosine similarity with BM25 keyword scores, so
+	// exact terms such as names and error codes count as well as meaning
+	HybridSearch SearchMode = "hybrid"
+)
+
+// BM25 parameters, the usual defaults
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// SearchOptions refine a search
+type SearchOptions struct {
+	TopK int
+	// Filter drops documents before they are scored
+	Filter Filter
+	Mode   SearchMode
+	// KeywordWeight is the share of a hybrid score that comes from BM25,
+	// between 0 and 1; the rest comes from similarity
+	KeywordWeight float64
+	// Reranker, when set, re-scores the best RerankCandidates documents
+	// (by default 4 per result) and keeps the TopK most relevant
+	Reranker         Reranker
+	RerankCandidates int
+}
+
+// DefaultSearchOptions returns a vector search for the top 3 documents,
+// with a keyword weight of 0.3 for hybrid mode
+func DefaultSearchOptions() SearchOptions {
+	return SearchOptions{TopK: 3, Mode: VectorSearch, KeywordWeight: 0.3}
+}
+
+// ParseSearchMode reads "vector" (or "") or "hybrid"
+func ParseSearchMode(text string) (SearchMode, error) {
+	switch mode := SearchMode(strings.ToLower(strings.TrimSpace(text))); mode {
+	case "", VectorSearch:
+		return VectorSearch, nil
+	case HybridSearch:
+		return HybridSearch, nil
+	default:
+		return "", fmt.Errorf("unknown search mode %q: use %s or %s", text, VectorSearch, HybridSearch)
+	}
+}
+
+// SearchWith searches the documents matching options.Filter, ranking them
+// by similarity or, in hybrid mode, by a weighted blend of similarity and
+// BM25 score, then by relevance when options.Reranker is set
+func (vs *VectorStore) SearchWith(ctx context.Context, query string, options SearchOptions) ([]SearchResult, error) {
+	if options.KeywordWeight < 0 || options.KeywordWeight > 1 {
+		return nil, fmt.Errorf("keyword weight must be between 0 and 1, got %g", options.KeywordWeight)
+	}
+	mode, err := ParseSearchMode(string(options.Mode))
+	if err != nil {
+		return nil, err
+	}
+
+	if mode == HybridSearch && vs.backend != nil {
+		return nil, fmt.Errorf("hybrid search %w", errNeedsMemory)
+	}
+
+	queryVector, err := vs.GenerateEmbedding(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate query embedding: %w", err)
+	}
+
+	// A reranked search ranks more candidates than it returns
+	candidates := options
+	if options.Reranker != nil {
+		candidates.TopK = options.RerankCandidates
+		if candidates.TopK == 0 {
+			candidates.TopK = rerankCandidatesPerResult * options.TopK
+		}
+		if candidates.TopK < options.TopK {
+			candidates.TopK = options.TopK
+		}
+	}
+
+	var results []SearchResult
+	if vs.backend != nil {
+		if results, err = vs.queryBackend(ctx, queryVector, candidates); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := vs.checkEmbeddings(len(queryVector)); err != nil {
+			return nil, err
+		}
+		if mode == HybridSearch {
+			results = vs.rankHybrid(queryVector, query, candidates)
+		} else {
+			results = vs.rank(queryVector, candidates.TopK, candidates.Filter)
+		}
+	}
+	if options.Reranker != nil {
+		if results, err = rerank(ctx, options.Reranker, query, results, options.TopK); err != nil {
+			return nil, err
+		}
+	}
+	for _, result := range results {
+		vs.retrievals[result.Embedding.ID]++
+	}
+	return results, nil
+}
+
+// queryBackend searches the backend, refusing results embedded by another
+// model as checkEmbeddings does in memory
+func (vs *VectorStore) queryBackend(ctx context.Context, queryVector []float64, options SearchOptions) ([]SearchResult, error) {
+	results, err := vs.backend.Query(ctx, queryVector, options.TopK, options.Filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query the vector backend: %w", err)
+	}
+	query := EmbeddingModel{Name: vs.model.Name, Dimensions: len(queryVector)}
+	for i := range results {
+		if !query.matches(results[i].Embedding) {
+			return nil, fmt.Errorf("%w: the query is %s but document %s is %s; re-ingest the backend with one model",
+				ErrMixedEmbeddings, query, results[i].Embedding.ID, embeddingModelOf(results[i].Embedding))
+		}
+		results[i].Score = results[i].Similarity
+	}
+	return results, nil
+}
+
+// rankHybrid scores every document matching the filter by similarity and
+// BM25, scaling BM25 by the best score so both lie in the same range, and
+// returns the topK best blends
+func (vs *VectorStore) rankHybrid(queryVector []float64, query string, options SearchOptions) []SearchResult {
+	unit := unitVector(queryVector)
+	terms := tokenize(query)
+
+	var candidates []*Embedding
+	totalLength := 0
+	for i := range vs.embeddings {
+		embedding := &vs.embeddings[i]
+		if !options.Filter.Matches(embedding.Metadata) {
+			continue
+		}
+		if embedding.terms == nil {
+			embedding.terms, embedding.length = termFrequencies(embedding.Text)
+		}
+		candidates = append(candidates, embedding)
+		totalLength += embedding.length
+	}
+	if len(candidates) == 0 || options.TopK <= 0 {
+		return []SearchResult{}
+	}
+
+	// Document frequencies over the candidates, as the corpus being searched
+	docFreq := make(map[string]int, len(terms))
+	for _, term := range terms {
+		if _, done := docFreq[term]; done {
+			continue
+		}
+		for _, embedding := range candidates {
+			if embedding.terms[term] > 0 {
+				docFreq[term]++
+			}
+		}
+	}
+	avgLength := float64(totalLength) / float64(len(candidates))
+
+	results := make([]SearchResult, len(candidates))
+	best := 0.0
+	for i, embedding := range candidates {
+		if embedding.unit == nil {
+			embedding.unit = unitVector(embedding.Vector)
+		}
+		var similarity float64
+		if len(embedding.unit) == len(unit) {
+			similarity = float64(dot32(unit, embedding.unit))
+		}
+		keyword := bm25(terms, embedding.terms, embedding.length, avgLength, docFreq, len(candidates))
+		if keyword > best {
+			best = keyword
+		}
+		results[i] = SearchResult{Embedding: *embedding, Similarity: similarity, KeywordScore: keyword}
+	}
+
+	for i := range results {
+		normalized := 0.0
+		if best > 0 {
+			normalized = results[i].KeywordScore / best
+		}
+		results[i].Score = (1-options.KeywordWeight)*results[i].Similarity + options.KeywordWeight*normalized
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > options.TopK {
+		results = results[:options.TopK]
+	}
+	return results
+}
+
+// bm25 scores a document's term frequencies against the query terms
+func bm25(terms []string, frequencies map[string]int, length int, avgLength float64, docFreq map[string]int, docs int) float64 {
+	score := 0.0
+	for _, term := range terms {
+		tf := float64(frequencies[term])
+		if tf == 0 {
+			continue
+		}
+		idf := math.Log(1 + (float64(docs)-float64(docFreq[term])+0.5)/(float64(docFreq[term])+0.5))
+		score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(length)/avgLength))
+	}
+	return score
+}
+
+// termFrequencies counts the terms of text, returning them and its length
+// in terms
+func termFrequencies(text string) (map[string]int, int) {
+	terms := tokenize(text)
+	frequencies := make(map[string]int, len(terms))
+	for _, term := range terms {
+		frequencies[term]++
+	}
+	return frequencies, len(terms)
+}
+
+// tokenize lowercases text and splits it into runs of letters and digits
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
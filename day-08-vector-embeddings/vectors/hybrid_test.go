@@ -0,0 +1,84 @@
+package vectors
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	filter, err := ParseFilter("category in (AI, ML) and source=wiki AND year>=2020 and year<2024")
+	if err != nil {
+		t.Fatalf("ParseFilter failed: %v", err)
+	}
+	if len(filter) != 3 || filter.String() != "category in (AI, ML) and source=wiki and year>=2020 and year<2024" {
+		t.Errorf("Unexpected filter %q", filter)
+	}
+
+	for _, tc := range []struct {
+		metadata map[string]interface{}
+		want     bool
+	}{
+		{map[string]interface{}{"category": "ML", "source": "wiki", "year": 2021.0}, true},
+		{map[string]interface{}{"category": "ML", "source": "wiki", "year": "2020"}, true},
+		{map[string]interface{}{"category": "CV", "source": "wiki", "year": 2021}, false},
+		{map[string]interface{}{"category": "AI", "source": "wiki", "year": 2024}, false},
+		{map[string]interface{}{"category": "AI", "source": "wiki"}, false},
+	} {
+		if got := filter.Matches(tc.metadata); got != tc.want {
+			t.Errorf("Matches(%v) = %v, expected %v", tc.metadata, got, tc.want)
+		}
+	}
+
+	if !(Filter{Range("score", 0.5, math.Inf(1)), Eq("draft", false)}).Matches(map[string]interface{}{"score": 0.5, "draft": false}) {
+		t.Error("Expected an inclusive range and boolean equality to match")
+	}
+	for _, bad := range []string{"category", "year>=recent", "category in AI", "a=1 and", "tag in ()"} {
+		if _, err := ParseFilter(bad); err == nil {
+			t.Errorf("Expected %q rejected", bad)
+		}
+	}
+}
+
+func TestSearchWith(t *testing.T) {
+	ctx := context.Background()
+	store := newEmbeddingServer(t)
+	store.SetEmbeddingModel(EmbeddingModel{Name: "local"})
+	// The fake embeds by text length, so the two "error" documents are
+	// no more similar to the query than the others
+	docs := []Document{
+		{ID: "a", Text: "go channels", Metadata: map[string]interface{}{"lang": "go", "year": 2019}},
+		{ID: "b", Text: "error code E1234 in go", Metadata: map[string]interface{}{"lang": "go", "year": 2023}},
+		{ID: "c", Text: "rust ownership", Metadata: map[string]interface{}{"lang": "rust", "year": 2022}},
+		{ID: "d", Text: "python E1234 error", Metadata: map[string]interface{}{"lang": "python", "year": 2021}},
+	}
+	if _, err := store.AddDocuments(ctx, docs); err != nil {
+		t.Fatal(err)
+	}
+
+	filter, _ := ParseFilter("lang in (go, python) and year>=2020")
+	results, err := store.SearchWith(ctx, "anything", SearchOptions{TopK: 5, Filter: filter})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Score != results[0].Similarity {
+		t.Fatalf("Expected the 2 matching documents ranked by similarity, got %+v", results)
+	}
+	for _, result := range results {
+		if result.Embedding.ID != "b" && result.Embedding.ID != "d" {
+			t.Errorf("Expected only b and d, got %s", result.Embedding.ID)
+		}
+	}
+
+	results, err = store.SearchWith(ctx, "E1234", SearchOptions{TopK: 2, Mode: HybridSearch, KeywordWeight: 0.9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].KeywordScore == 0 || results[1].KeywordScore == 0 {
+		t.Errorf("Expected the documents with the keyword first, got %+v", results)
+	}
+
+	if _, err := store.SearchWith(ctx, "x", SearchOptions{TopK: 1, KeywordWeight: 2}); err == nil {
+		t.Error("Expected a keyword weight over 1 rejected")
+	}
+}
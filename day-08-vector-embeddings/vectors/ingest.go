@@ -0,0 +1,49 @@
+package vectors
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sakibmulla/agentic-ai/day-08-vector-embeddings/chunker"
+)
+
+// IngestText splits a large document into chunks and adds them to the store
+// as <id>#<n>, n counting from 1. Each chunk's metadata records the document
+// ID, chunk number and markdown heading on top of metadata.
+func (vs *VectorStore) IngestText(ctx context.Context, id, text string, config chunker.Config, metadata map[string]interface{}) (*BatchReport, error) {
+	chunks := chunker.Split(text, config)
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("document %s has no text to ingest", id)
+	}
+
+	docs := make([]Document, len(chunks))
+	for i, chunk := range chunks {
+		chunkMetadata := map[string]interface{}{"document": id, "chunk": chunk.Index + 1}
+		if chunk.Heading != "" {
+			chunkMetadata["heading"] = chunk.Heading
+		}
+		for key, value := range metadata {
+			chunkMetadata[key] = value
+		}
+		docs[i] = Document{ID: fmt.Sprintf("%s#%d", id, chunk.Index+1), Text: chunk.Text, Metadata: chunkMetadata}
+	}
+	return vs.AddDocuments(ctx, docs)
+}
+
+// IngestFile ingests a text, markdown or PDF-extracted text file under its
+// base name, detecting the format when config.Format is empty
+func (vs *VectorStore) IngestFile(ctx context.Context, path string, config chunker.Config) (*BatchReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if config.Format == "" {
+		config.Format = chunker.DetectFormat(path, string(data))
+	}
+	return vs.IngestText(ctx, filepath.Base(path), string(data), config, map[string]interface{}{
+		"path":   path,
+		"format": string(config.Format),
+	})
+}
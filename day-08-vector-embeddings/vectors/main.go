@@ -0,0 +1,821 @@
+package vectors
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/sakibmulla/agentic-ai/costs"
+	"github.com/sakibmulla/agentic-ai/day-08-vector-embeddings/chunker"
+	"github.com/sakibmulla/agentic-ai/endpoint"
+	"github.com/sakibmulla/agentic-ai/logging"
+	"github.com/sakibmulla/agentic-ai/settings"
+	"github.com/sakibmulla/agentic-ai/tools"
+	"github.com/sashabaranov/go-openai"
+)
+
+// Embedding represents a text embedding with metadata
+type Embedding struct {
+	ID       string                 `json:"id"`
+	Text     string                 `json:"text"`
+	Vector   []float64              `json:"vector"`
+	Metadata map[string]interface{} `json:"metadata"`
+	// Model is the embedding model that produced Vector, and Dimensions
+	// its length
+	Model      string `json:"model,omitempty"`
+	Dimensions int    `json:"dimensions,omitempty"`
+	// unit is Vector scaled to length 1, as float32, computed when the
+	// embedding is stored; ranking is then one dot product per document
+	unit []float32
+	// terms counts Text's terms, and length is their total, for keyword
+	// scoring; both are computed on the first hybrid search
+	terms  map[string]int
+	length int
+}
+
+// VectorStore provides in-memory vector storage and search
+type VectorStore struct {
+	embeddings []Embedding
+	// backend, when set, holds the embeddings instead of embeddings
+	backend    VectorBackend
+	client     *openai.Client
+	model      EmbeddingModel
+	listeners  []func(id string)
+	retrievals map[string]int
+}
+
+// SearchResult represents a search result with similarity score
+type SearchResult struct {
+	Embedding  Embedding `json:"embedding"`
+	Similarity float64   `json:"similarity"`
+	// KeywordScore is the BM25 score of a hybrid search, and Score what the
+	// results are ranked by: the similarity, or the hybrid blend
+	KeywordScore float64 `json:"keyword_score,omitempty"`
+	Score        float64 `json:"score"`
+	// Relevance is a reranker's calibrated score, from 0 to 1; reranked
+	// results are ordered by it
+	Relevance float64 `json:"relevance,omitempty"`
+}
+
+// NewVectorStore creates a new vector store that calls api, embedding with
+// AdaV2, or Ollama's embedding model, until SetEmbeddingModel. Its embedding and chat calls are budgeted
+// and priced by limiter, when given.
+func NewVectorStore(apiKey string, api endpoint.Endpoint, limiter *costs.Limiter) *VectorStore {
+	clientConfig := api.Config(apiKey)
+	// Ollama's requests are sent with its local models, limiter or not
+	httpClient := &http.Client{}
+	if limiter != nil {
+		httpClient = limiter.HTTPClient()
+	}
+	clientConfig.HTTPClient = api.HTTPClient(httpClient)
+
+	model := AdaV2
+	if api.IsOllama() {
+		// Dimensions are learned from the first embedding
+		model = EmbeddingModel{Name: api.EmbedModel}
+	}
+
+	return &VectorStore{
+		embeddings: make([]Embedding, 0),
+		client:     openai.NewClientWithConfig(clientConfig),
+		model:      model,
+		retrievals: make(map[string]int),
+	}
+}
+
+// GenerateEmbedding creates an embedding for the given text with the
+// store's model
+func (vs *VectorStore) GenerateEmbedding(ctx context.Context, text string) ([]float64, error) {
+	vectors, err := vs.model.embed(ctx, vs.client, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+// AddDocument adds a document to the vector store
+func (vs *VectorStore) AddDocument(ctx context.Context, id, text string, metadata map[string]interface{}) error {
+	vector, err := vs.GenerateEmbedding(ctx, text)
+	if err != nil {
+		return fmt.Errorf("failed to generate embedding: %w", err)
+	}
+
+	return vs.put(ctx, vs.newEmbedding(id, text, vector, metadata))
+}
+
+// newEmbedding builds an embedding of the store's model, precomputing its
+// normalized vector. A model of unknown dimensions takes those of its first
+// vector.
+func (vs *VectorStore) newEmbedding(id, text string, vector []float64, metadata map[string]interface{}) Embedding {
+	if vs.model.Dimensions == 0 {
+		vs.model.Dimensions = len(vector)
+	}
+	return Embedding{ID: id, Text: text, Vector: vector, Metadata: metadata, Model: vs.model.Name, Dimensions: len(vector), unit: unitVector(vector)}
+}
+
+// CosineSimilarity calculates cosine similarity between two vectors
+func CosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+
+	var dotProduct, normA, normB float64
+	for i := range a {
+		dotProduct += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return dotProduct / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// unitVector returns v scaled to length 1 as float32. A zero vector stays
+// zero, so it is similar to nothing, as in CosineSimilarity.
+func unitVector(v []float64) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += x * x
+	}
+
+	unit := make([]float32, len(v))
+	if sum == 0 {
+		return unit
+	}
+	scale := 1 / math.Sqrt(sum)
+	for i, x := range v {
+		unit[i] = float32(x * scale)
+	}
+	return unit
+}
+
+// dot32 returns the dot product of two vectors of the same length. It sums
+// in four lanes, so each addition doesn't wait on the one before it, and
+// takes four-element subslices so the loop body has no bounds checks.
+func dot32(a, b []float32) float32 {
+	b = b[:len(a)]
+	var s0, s1, s2, s3 float32
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		x, y := a[i:i+4:i+4], b[i:i+4:i+4]
+		s0 += x[0] * y[0]
+		s1 += x[1] * y[1]
+		s2 += x[2] * y[2]
+		s3 += x[3] * y[3]
+	}
+	for ; i < len(a); i++ {
+		s0 += a[i] * b[i]
+	}
+	return s0 + s1 + s2 + s3
+}
+
+// Search performs semantic search in the vector store. It fails with
+// ErrMixedEmbeddings, rather than rank incomparable vectors, when the store
+// holds embeddings of another model.
+func (vs *VectorStore) Search(ctx context.Context, query string, topK int) ([]SearchResult, error) {
+	return vs.SearchWith(ctx, query, SearchOptions{TopK: topK, Mode: VectorSearch})
+}
+
+// rank returns the topK stored embeddings matching filter that are most
+// similar to queryVector. With both sides normalized, cosine similarity is
+// a float32 dot product. It keeps only the best topK as it scans, in order,
+// instead of sorting every document.
+func (vs *VectorStore) rank(queryVector []float64, topK int, filter Filter) []SearchResult {
+	if topK > len(vs.embeddings) {
+		topK = len(vs.embeddings)
+	}
+	if topK <= 0 {
+		return []SearchResult{}
+	}
+
+	query := unitVector(queryVector)
+	results := make([]SearchResult, 0, topK)
+	for i := range vs.embeddings {
+		embedding := &vs.embeddings[i]
+		if len(filter) > 0 && !filter.Matches(embedding.Metadata) {
+			continue
+		}
+		if embedding.unit == nil {
+			// Stored without newEmbedding; normalize it once
+			embedding.unit = unitVector(embedding.Vector)
+		}
+		var similarity float64
+		if len(embedding.unit) == len(query) {
+			similarity = float64(dot32(query, embedding.unit))
+		}
+		if len(results) == topK && similarity <= results[topK-1].Similarity {
+			continue
+		}
+
+		// Insert after any equal score, so ties keep store order
+		pos := len(results)
+		for pos > 0 && results[pos-1].Similarity < similarity {
+			pos--
+		}
+		if len(results) < topK {
+			results = append(results, SearchResult{})
+		}
+		copy(results[pos+1:], results[pos:len(results)-1])
+		results[pos] = SearchResult{Embedding: *embedding, Similarity: similarity, Score: similarity}
+	}
+	return results
+}
+
+// RetrievalCount returns how often a document has been returned by Search
+func (vs *VectorStore) RetrievalCount(id string) int {
+	return vs.retrievals[id]
+}
+
+// GetDocumentCount returns the number of documents in the store, or 0 when
+// the backend can't be counted
+func (vs *VectorStore) GetDocumentCount() int {
+	if vs.backend != nil {
+		n, err := vs.backend.Count(context.Background())
+		if err != nil {
+			logBackendError("count", err)
+		}
+		return n
+	}
+	return len(vs.embeddings)
+}
+
+// GetDocument retrieves a document by ID
+func (vs *VectorStore) GetDocument(id string) (*Embedding, error) {
+	if vs.backend != nil {
+		embedding, err := vs.backend.Get(context.Background(), id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get document %s: %w", id, err)
+		}
+		if embedding == nil {
+			return nil, fmt.Errorf("document with ID %s not found", id)
+		}
+		return embedding, nil
+	}
+	for _, embedding := range vs.embeddings {
+		if embedding.ID == id {
+			return &embedding, nil
+		}
+	}
+	return nil, fmt.Errorf("document with ID %s not found", id)
+}
+
+// UpdateDocument re-embeds a document and replaces it in place
+func (vs *VectorStore) UpdateDocument(ctx context.Context, id, text string, metadata map[string]interface{}) error {
+	if vs.backend != nil {
+		if _, err := vs.GetDocument(id); err != nil {
+			return err
+		}
+		vector, err := vs.GenerateEmbedding(ctx, text)
+		if err != nil {
+			return fmt.Errorf("failed to generate embedding: %w", err)
+		}
+		if err := vs.put(ctx, vs.newEmbedding(id, text, vector, metadata)); err != nil {
+			return fmt.Errorf("failed to store document %s: %w", id, err)
+		}
+		vs.notifyChange(id)
+		return nil
+	}
+	for i := range vs.embeddings {
+		if vs.embeddings[i].ID != id {
+			continue
+		}
+
+		vector, err := vs.GenerateEmbedding(ctx, text)
+		if err != nil {
+			return fmt.Errorf("failed to generate embedding: %w", err)
+		}
+		vs.embeddings[i] = vs.newEmbedding(id, text, vector, metadata)
+		vs.notifyChange(id)
+		return nil
+	}
+	return fmt.Errorf("document with ID %s not found", id)
+}
+
+// DeleteDocument removes a document from the store
+func (vs *VectorStore) DeleteDocument(id string) error {
+	if vs.backend != nil {
+		if _, err := vs.GetDocument(id); err != nil {
+			return err
+		}
+		if err := vs.backend.Delete(context.Background(), []string{id}); err != nil {
+			return fmt.Errorf("failed to delete document %s: %w", id, err)
+		}
+		vs.notifyChange(id)
+		return nil
+	}
+	for i := range vs.embeddings {
+		if vs.embeddings[i].ID == id {
+			vs.embeddings = append(vs.embeddings[:i], vs.embeddings[i+1:]...)
+			vs.notifyChange(id)
+			return nil
+		}
+	}
+	return fmt.Errorf("document with ID %s not found", id)
+}
+
+// OnChange registers fn to be called with the ID of every updated or deleted document
+func (vs *VectorStore) OnChange(fn func(id string)) {
+	vs.listeners = append(vs.listeners, fn)
+}
+
+// notifyChange tells listeners that a document changed
+func (vs *VectorStore) notifyChange(id string) {
+	for _, fn := range vs.listeners {
+		fn(id)
+	}
+}
+
+// configKeys lists the settings this day reads, for `config show` and
+// `config validate`
+var configKeys = settings.Common.With(
+	settings.Key{Name: "EMBEDDING_MODEL", Kind: settings.String},
+	settings.Key{Name: "EMBEDDINGS_PER_HOUR", Kind: settings.Int},
+	settings.Key{Name: "SEARCH_MODE", Kind: settings.String},
+	settings.Key{Name: "RERANKER", Kind: settings.String},
+	settings.Key{Name: "RERANK_MODEL", Kind: settings.String},
+	settings.Key{Name: "RERANK_URL", Kind: settings.String},
+	settings.Key{Name: "RERANK_CANDIDATES", Kind: settings.Int},
+	settings.Key{Name: "VECTOR_BACKEND", Kind: settings.String},
+	settings.Key{Name: "QDRANT_URL", Kind: settings.String},
+	settings.Key{Name: "QDRANT_COLLECTION", Kind: settings.String},
+	settings.Key{Name: "QDRANT_API_KEY", Kind: settings.String, Secret: true},
+	settings.Key{Name: "PGVECTOR_DSN", Kind: settings.String, Secret: true},
+	settings.Key{Name: "PGVECTOR_TABLE", Kind: settings.String},
+	settings.Key{Name: "PINECONE_API_KEY", Kind: settings.String, Secret: true},
+	settings.Key{Name: "PINECONE_HOST", Kind: settings.String},
+	settings.Key{Name: "PINECONE_NAMESPACE", Kind: settings.String},
+	settings.Key{Name: "SEARCH_KEYWORD_WEIGHT", Kind: settings.Float},
+	settings.Key{Name: "WORKSPACE_DIR", Kind: settings.String},
+	settings.Key{Name: "SEARCH_ADDR", Kind: settings.String},
+)
+
+// Main loads .env and the config file, then runs the vector embeddings
+// demo, or the search server when args[0] is serve-search. args is the
+// command line after the program name.
+func Main(args []string) {
+	// Load environment variables, then the config file's CONFIG_PROFILE,
+	// which doesn't replace them
+	envErr := godotenv.Load()
+	run(args, envErr, settings.Load())
+}
+
+// Run runs like Main in an environment the caller has already loaded .env
+// and the config file into, as the agentic command does
+func Run(args []string) {
+	run(args, nil, nil)
+}
+
+// run reports what loading .env and the config file found, then runs the
+// demo
+func run(args []string, envErr, settingsErr error) {
+	// LOG_LEVEL and LOG_FORMAT may come from .env or the config file
+	logging.Setup(slog.LevelWarn)
+	if envErr != nil {
+		slog.Info("no .env file found, using system environment variables")
+	}
+	if settingsErr != nil {
+		logging.Fatal("invalid config file", "error", settingsErr)
+	}
+	// "config show" and "config validate" report the settings instead of running
+	if run, err := configKeys.Start(args, os.Stdout); err != nil {
+		logging.Fatal("invalid configuration", "error", err)
+	} else if !run {
+		return
+	}
+
+	// OPENAI_BASE_URL and OPENAI_API_TYPE point the client at Azure OpenAI
+	// or an OpenAI-compatible server such as vLLM; LLM_PROVIDER=ollama runs
+	// fully offline on local models
+	api, err := endpoint.FromEnv()
+	if err != nil {
+		logging.Fatal("invalid endpoint", "error", err)
+	}
+
+	// Get OpenAI API key
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	if apiKey == "" && api.RequiresKey() {
+		logging.Fatal("OPENAI_API_KEY environment variable is required")
+	}
+
+	if err := api.CheckModels(context.Background()); err != nil {
+		logging.Fatal("local models unavailable", "error", err)
+	}
+
+	// Budgets, the cost ledger and prices come from BUDGET, BUDGET_DOWNGRADES,
+	// COST_LEDGER_PATH and PRICING_PATH
+	limiter, err := costs.FromEnv()
+	if err != nil {
+		logging.Fatal("invalid budget", "error", err)
+	}
+
+	// Create vector store
+	vectorStore := NewVectorStore(apiKey, api, limiter)
+	// EMBEDDING_MODEL is "name" or "name:dimensions", e.g. text-embedding-3-small
+	if name := os.Getenv("EMBEDDING_MODEL"); name != "" {
+		model, err := ParseEmbeddingModel(name)
+		if err != nil {
+			logging.Fatal("invalid EMBEDDING_MODEL", "error", err)
+		}
+		if err := vectorStore.SetEmbeddingModel(model); err != nil {
+			logging.Fatal("invalid EMBEDDING_MODEL", "error", err)
+		}
+	}
+	ctx := context.Background()
+
+	// VECTOR_BACKEND=qdrant, pgvector or pinecone keeps the documents in a
+	// vector database instead of memory
+	backend, err := backendFromEnv(ctx)
+	if err != nil {
+		logging.Fatal("invalid vector backend", "error", err)
+	}
+	if backend != nil {
+		if err := vectorStore.UseBackend(ctx, backend); err != nil {
+			logging.Fatal("failed to use vector backend", "error", err)
+		}
+	}
+
+	// "serve-search [file...]" serves the store to other agents instead of running the demo
+	if len(args) > 0 && args[0] == "serve-search" {
+		logging.Setup(slog.LevelInfo)
+		runSearchServer(ctx, vectorStore, args[1:])
+		return
+	}
+
+	fmt.Println("🔍 Vector Database & Embeddings Demo")
+	fmt.Println("=====================================")
+
+	// Sample documents to add to the vector store
+	documents := sampleDocuments()
+
+	// Add documents to vector store in as few embedding requests as possible
+	fmt.Println("📥 Adding documents to vector store...")
+	report, err := vectorStore.AddDocuments(ctx, documents)
+	for _, failed := range report.Failed {
+		slog.Error("failed to add document", "id", failed.ID, "error", failed.Err)
+	}
+	if err == nil {
+		fmt.Printf("✅ Embedded %d documents in %d request(s)\n", report.Added, report.Requests)
+	}
+
+	fmt.Printf("\n📊 Vector store contains %d documents\n\n", vectorStore.GetDocumentCount())
+
+	// Demonstrate semantic search
+	queries := []string{
+		"What is machine learning?",
+		"How do computers understand images?",
+		"Programming languages for AI",
+		"Neural networks and deep learning",
+		"Language understanding by machines",
+	}
+
+	for _, query := range queries {
+		fmt.Printf("🔍 Query: %s\n", query)
+		fmt.Println(strings.Repeat("-", 50))
+
+		results, err := vectorStore.Search(ctx, query, 3)
+		if err != nil {
+			slog.Error("search failed", "error", err)
+			continue
+		}
+
+		for i, result := range results {
+			fmt.Printf("%d. [%.3f] %s\n",
+				i+1,
+				result.Similarity,
+				result.Embedding.Text)
+			fmt.Printf("   ID: %s, Category: %v\n",
+				result.Embedding.ID,
+				result.Embedding.Metadata["category"])
+		}
+		fmt.Println()
+	}
+
+	// Demonstrate similarity between documents
+	fmt.Println("📈 Document Similarity Analysis")
+	fmt.Println(strings.Repeat("=", 40))
+
+	doc1, _ := vectorStore.GetDocument("doc1") // AI
+	doc2, _ := vectorStore.GetDocument("doc2") // ML
+	doc6, _ := vectorStore.GetDocument("doc6") // Go programming
+
+	sim12 := CosineSimilarity(doc1.Vector, doc2.Vector)
+	sim16 := CosineSimilarity(doc1.Vector, doc6.Vector)
+	sim26 := CosineSimilarity(doc2.Vector, doc6.Vector)
+
+	fmt.Printf("AI ↔ ML similarity: %.3f\n", sim12)
+	fmt.Printf("AI ↔ Go similarity: %.3f\n", sim16)
+	fmt.Printf("ML ↔ Go similarity: %.3f\n", sim26)
+
+	fmt.Println("\n✨ Vector search demo complete!")
+	fmt.Println("Notice how semantically similar documents have higher similarity scores!")
+
+	runInteractiveSearch(ctx, vectorStore)
+}
+
+// sampleDocuments are the documents the demo starts with
+func sampleDocuments() []Document {
+	return []Document{
+		{
+			ID:   "doc1",
+			Text: "Artificial intelligence is the simulation of human intelligence in machines that are programmed to think and learn like humans.",
+			Metadata: map[string]interface{}{
+				"category": "AI",
+				"source":   "encyclopedia",
+			},
+		},
+		{
+			ID:   "doc2",
+			Text: "Machine learning is a subset of artificial intelligence that focuses on the development of algorithms that allow computers to learn from data.",
+			Metadata: map[string]interface{}{
+				"category": "ML",
+				"source":   "textbook",
+			},
+		},
+		{
+			ID:   "doc3",
+			Text: "Natural language processing enables computers to understand, interpret, and generate human language in a valuable way.",
+			Metadata: map[string]interface{}{
+				"category": "NLP",
+				"source":   "research",
+			},
+		},
+		{
+			ID:   "doc4",
+			Text: "Deep learning uses neural networks with multiple layers to model and understand complex patterns in data.",
+			Metadata: map[string]interface{}{
+				"category": "DL",
+				"source":   "article",
+			},
+		},
+		{
+			ID:   "doc5",
+			Text: "Computer vision allows machines to interpret and understand visual information from the world around them.",
+			Metadata: map[string]interface{}{
+				"category": "CV",
+				"source":   "journal",
+			},
+		},
+		{
+			ID:   "doc6",
+			Text: "Go is a programming language developed by Google that emphasizes simplicity, efficiency, and strong support for concurrent programming.",
+			Metadata: map[string]interface{}{
+				"category": "Programming",
+				"source":   "documentation",
+			},
+		},
+	}
+}
+
+// runInteractiveSearch lets the user query the store and inspect cited sources
+func runInteractiveSearch(ctx context.Context, vectorStore *VectorStore) {
+	fmt.Println("\n💬 Interactive search")
+	fmt.Println("Type a query to search, '/ask <question>' for a generated answer, '/chat <question>' to ask")
+	fmt.Println("follow-up questions with conversation memory, '/sources' to list cited chunks,")
+	fmt.Println("'/open <n>' to view one, '/ingest <file>' to chunk and add a text, markdown or PDF-extracted file,")
+	fmt.Println("'/cache' for answer cache stats, '/update <id> <text>' to queue a")
+	fmt.Println("document change, '/backlog' for the refresh queue, '/migrate <model>' to")
+	fmt.Println("re-embed every document with another embedding model, '/filter <expr>' to only")
+	fmt.Println("search matching metadata (e.g. category in (AI, ML)), '/mode vector|hybrid', 'quit' to exit")
+	fmt.Println("Set WORKSPACE_DIR to index a code workspace that is reindexed as files change.")
+
+	// SEARCH_MODE=hybrid adds BM25 keyword scores, weighted by SEARCH_KEYWORD_WEIGHT
+	options := DefaultSearchOptions()
+	if mode, err := ParseSearchMode(os.Getenv("SEARCH_MODE")); err != nil {
+		slog.Warn("ignoring SEARCH_MODE", "error", err)
+	} else {
+		options.Mode = mode
+	}
+	if text := os.Getenv("SEARCH_KEYWORD_WEIGHT"); text != "" {
+		if weight, err := strconv.ParseFloat(text, 64); err != nil || weight < 0 || weight > 1 {
+			slog.Warn("ignoring SEARCH_KEYWORD_WEIGHT: expected a number between 0 and 1", "value", text)
+		} else {
+			options.KeywordWeight = weight
+		}
+	}
+	// RERANKER=llm or cross-encoder re-scores the candidates of each search
+	if reranker, candidates, err := rerankerFromEnv(vectorStore.client); err != nil {
+		slog.Warn("searching without a reranker", "error", err)
+	} else {
+		options.Reranker, options.RerankCandidates = reranker, candidates
+	}
+
+	tracker := NewSourceTracker()
+	cache := NewAnswerCache(500)
+	pipeline := NewRAGPipeline(vectorStore, cache)
+	agent := NewRAGAgent(vectorStore, NewConversationBuffer(2000), DefaultRAGConfig())
+	agent.Tools().MustRegister(tools.Calculator(), tools.TextAnalysis())
+
+	perHour, _ := strconv.Atoi(os.Getenv("EMBEDDINGS_PER_HOUR"))
+	scheduler := NewRefreshScheduler(vectorStore, perHour)
+	scanner := bufio.NewScanner(os.Stdin)
+
+	// Optionally index a workspace and keep it fresh as files change
+	var workspace *Workspace
+	if root := os.Getenv("WORKSPACE_DIR"); root != "" {
+		workspace = NewWorkspace(vectorStore, DefaultWorkspaceConfig(root))
+		report, err := workspace.Index(ctx)
+		if err != nil {
+			slog.Error("failed to index workspace", "error", err)
+		}
+		if report != nil {
+			fmt.Printf("🗂️  Indexed %d file(s) from %s (%d chunks)\n", report.Reindexed, root, report.Embedded+report.Reused)
+		}
+		tracker.SetWorkspace(workspace)
+		go workspace.Watch(ctx)
+	}
+
+	for {
+		fmt.Print("\nQuery: ")
+		if !scanner.Scan() {
+			break
+		}
+
+		// Re-embed queued documents within the hourly budget before serving the query
+		if n, err := scheduler.RunOnce(ctx); err != nil {
+			slog.Error("failed to refresh workspace", "error", err)
+		} else if n > 0 {
+			fmt.Printf("♻️  Re-embedded %d changed document(s)\n", n)
+		}
+		if workspace != nil {
+			report, err := workspace.Sync(ctx)
+			if err != nil {
+				slog.Error("failed to reindex workspace", "error", err)
+			}
+			if report.Reindexed+report.Removed > 0 {
+				fmt.Printf("🔄 Reindexed %d changed and %d deleted workspace file(s): %d chunk(s) embedded, %d unchanged\n",
+					report.Reindexed, report.Removed, report.Embedded, report.Reused)
+			}
+		}
+
+		input := strings.TrimSpace(scanner.Text())
+		switch {
+		case input == "":
+			continue
+
+		case input == "quit":
+			return
+
+		case strings.HasPrefix(input, "/update "):
+			fields := strings.SplitN(strings.TrimSpace(strings.TrimPrefix(input, "/update ")), " ", 2)
+			if len(fields) < 2 {
+				fmt.Println("Usage: /update <id> <text>")
+				continue
+			}
+			var metadata map[string]interface{}
+			if doc, err := vectorStore.GetDocument(fields[0]); err == nil {
+				metadata = doc.Metadata
+			}
+			scheduler.Enqueue(fields[0], fields[1], metadata)
+			fmt.Printf("Queued %s for re-embedding (%d pending)\n", fields[0], scheduler.Backlog().Pending)
+
+		case strings.HasPrefix(input, "/ingest "):
+			path := strings.TrimSpace(strings.TrimPrefix(input, "/ingest "))
+			report, err := vectorStore.IngestFile(ctx, path, chunker.DefaultConfig())
+			if report != nil {
+				for _, failed := range report.Failed {
+					slog.Error("failed to add chunk", "id", failed.ID, "error", failed.Err)
+				}
+			}
+			if err != nil && report == nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			fmt.Printf("📥 Ingested %d chunk(s) of %s\n", report.Added, path)
+
+		case input == "/backlog":
+			backlog := scheduler.Backlog()
+			fmt.Printf("Refresh backlog: %d pending, %d/%d embeddings used this hour, %d refreshed total\n",
+				backlog.Pending, backlog.UsedThisHour, backlog.BudgetPerHour, backlog.ProcessedTotal)
+			if backlog.Pending > 0 {
+				fmt.Printf("  Next up: %s (oldest queued %s ago)\n",
+					strings.Join(backlog.ByRetrievalRank, ", "), time.Since(backlog.OldestQueued).Round(time.Second))
+			}
+			if backlog.EstimatedDrain > 0 {
+				fmt.Printf("  Budget exhausted: about %s until the queue drains\n", backlog.EstimatedDrain)
+			}
+			if backlog.LastError != "" {
+				fmt.Printf("  Last error: %s\n", backlog.LastError)
+			}
+
+		case strings.HasPrefix(input, "/migrate "):
+			model, err := ParseEmbeddingModel(strings.TrimPrefix(input, "/migrate "))
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			fmt.Printf("♻️  Re-embedding %d document(s) with %s...\n", vectorStore.GetDocumentCount(), model)
+			report, err := vectorStore.Migrate(ctx, model, DefaultBatchConfig(), func(p MigrationProgress) {
+				fmt.Printf("  %d/%d done, %d failed (%s)\n", p.Done, p.Total, p.Failed, p.Elapsed.Round(time.Millisecond))
+			})
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			fmt.Printf("✅ Migrated %d document(s) from %s to %s in %d request(s)\n",
+				report.Migrated, report.From, report.To, report.Requests)
+
+		case input == "/filter" || strings.HasPrefix(input, "/filter "):
+			filter, err := ParseFilter(strings.TrimSpace(strings.TrimPrefix(input, "/filter")))
+			if input == "/filter" {
+				filter, err = nil, nil
+			}
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			options.Filter = filter
+			if len(filter) == 0 {
+				fmt.Println("Searching every document")
+			} else {
+				fmt.Printf("Searching documents where %s\n", filter)
+			}
+
+		case strings.HasPrefix(input, "/mode "):
+			mode, err := ParseSearchMode(strings.TrimPrefix(input, "/mode "))
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			options.Mode = mode
+			fmt.Printf("Search mode: %s\n", mode)
+
+		case input == "/sources":
+			fmt.Print(tracker.FormatSources())
+
+		case strings.HasPrefix(input, "/ask "):
+			question := strings.TrimSpace(strings.TrimPrefix(input, "/ask "))
+			answer, err := pipeline.Answer(ctx, question)
+			if err != nil {
+				slog.Error("failed to answer", "error", err)
+				continue
+			}
+
+			tracker.Record(question, answer.Sources)
+			if answer.Cached {
+				fmt.Println("⚡ (cached answer)")
+			}
+			fmt.Println(answer.Answer)
+			fmt.Println("Use /sources for the cited chunks.")
+
+		case strings.HasPrefix(input, "/chat "):
+			question := strings.TrimSpace(strings.TrimPrefix(input, "/chat "))
+			answer, err := agent.Ask(ctx, question)
+			if err != nil {
+				slog.Error("failed to answer", "error", err)
+				continue
+			}
+
+			tracker.Record(question, answer.Sources)
+			fmt.Println(answer.Answer)
+			if len(answer.Sources) == 0 {
+				fmt.Println("(no passages above the relevance threshold)")
+			}
+			for _, n := range answer.Citations {
+				fmt.Printf("  [%d] %s\n", n, answer.Sources[n-1].Embedding.ID)
+			}
+
+		case input == "/cache":
+			stats := cache.Stats()
+			fmt.Printf("Answer cache: %d entries, %d hits, %d misses, %d invalidated\n",
+				stats.Entries, stats.Hits, stats.Misses, stats.Invalidations)
+
+		case strings.HasPrefix(input, "/open "):
+			n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(input, "/open ")))
+			if err != nil {
+				fmt.Println("Usage: /open <n>")
+				continue
+			}
+			if err := tracker.Open(n); err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
+
+		default:
+			results, err := vectorStore.SearchWith(ctx, input, options)
+			if err != nil {
+				slog.Error("search failed", "error", err)
+				continue
+			}
+
+			tracker.Record(input, results)
+			for i, result := range results {
+				fmt.Printf("[%d] %s\n", i+1, truncate(result.Embedding.Text, 100))
+			}
+			fmt.Println("Use /sources for scores or /open <n> for the full chunk.")
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		slog.Error("failed to read input", "error", err)
+	}
+}
@@ -0,0 +1,112 @@
+package vectors
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MigrationProgress is reported after each batch of a migration
+type MigrationProgress struct {
+	Done    int
+	Failed  int
+	Total   int
+	Elapsed time.Duration
+}
+
+// MigrationReport summarizes a Migrate call
+type MigrationReport struct {
+	From, To EmbeddingModel
+	Migrated int
+	Requests int
+	Failed   []DocumentError
+	Elapsed  time.Duration
+}
+
+// Migrate re-embeds every stored document with model, in batches, calling
+// progress (when not nil) after each one. The store keeps serving its old
+// vectors until all documents are re-embedded, then switches to model in
+// one step; if any document fails, nothing changes and the report lists
+// the failures. Each migrated document is reported to OnChange listeners.
+// Stores with a VectorBackend can't be migrated in place.
+func (vs *VectorStore) Migrate(ctx context.Context, model EmbeddingModel, config BatchConfig, progress func(MigrationProgress)) (*MigrationReport, error) {
+	if vs.backend != nil {
+		return nil, fmt.Errorf("migration %w: ingest the documents again into a new collection", errNeedsMemory)
+	}
+	start := time.Now()
+	report := &MigrationReport{From: vs.model, To: model}
+
+	docs := make([]Document, len(vs.embeddings))
+	indexes := make([]int, len(docs))
+	for i, embedding := range vs.embeddings {
+		docs[i] = Document{ID: embedding.ID, Text: embedding.Text, Metadata: embedding.Metadata}
+		indexes[i] = i
+	}
+	batches := planBatches(docs, indexes, config)
+	report.Requests = len(batches)
+
+	// The batches embed with the new model; the store's stays in use until
+	// the switch
+	target := &VectorStore{client: vs.client, model: model}
+	vectors := make([][]float64, len(docs))
+	errs := make([]error, len(docs))
+
+	concurrency := config.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	status := MigrationProgress{Total: len(docs)}
+	for _, batch := range batches {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(batch []int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			embeddings, err := target.embedBatch(ctx, docs, batch)
+			mu.Lock()
+			defer mu.Unlock()
+			for j, i := range batch {
+				if err != nil {
+					errs[i] = err
+					status.Failed++
+				} else {
+					vectors[i] = embeddings[j]
+					status.Done++
+				}
+			}
+			if progress != nil {
+				status.Elapsed = time.Since(start)
+				progress(status)
+			}
+		}(batch)
+	}
+	wg.Wait()
+
+	for i, doc := range docs {
+		if errs[i] != nil {
+			report.Failed = append(report.Failed, DocumentError{ID: doc.ID, Err: errs[i]})
+		}
+	}
+	report.Elapsed = time.Since(start)
+	if len(report.Failed) > 0 {
+		return report, fmt.Errorf("%d of %d documents failed to re-embed with %s, store left on %s (first: %s: %v)",
+			len(report.Failed), len(docs), model, vs.model, report.Failed[0].ID, report.Failed[0].Err)
+	}
+
+	migrated := make([]Embedding, len(docs))
+	for i, doc := range docs {
+		migrated[i] = target.newEmbedding(doc.ID, doc.Text, vectors[i], doc.Metadata)
+	}
+	vs.embeddings = migrated
+	vs.model = target.model
+	report.Migrated = len(migrated)
+	for _, doc := range docs {
+		vs.notifyChange(doc.ID)
+	}
+	return report, nil
+}
@@ -0,0 +1,228 @@
+package vectors
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	_ "github.com/lib/pq" // Postgres driver for VECTOR_BACKEND=pgvector
+)
+
+// tableName is what NewPgvectorBackend accepts as a table name, since it
+// is written into the SQL rather than passed as a parameter
+var tableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// PgvectorBackend keeps embeddings in a Postgres table with the pgvector
+// extension:
+//
+//	id text primary key, text text, model text, metadata jsonb, embedding vector
+//
+// The embedding column has no fixed dimensions, so any model fits; for an
+// approximate index on large tables, fix them and add one, e.g.
+// CREATE INDEX ON documents USING hnsw ((embedding::vector(1536)) vector_cosine_ops).
+type PgvectorBackend struct {
+	db    *sql.DB
+	table string
+}
+
+// NewPgvectorBackend uses table in db, creating the extension and the table
+// if needed
+func NewPgvectorBackend(ctx context.Context, db *sql.DB, table string) (*PgvectorBackend, error) {
+	if !tableName.MatchString(table) {
+		return nil, fmt.Errorf("invalid table name %q", table)
+	}
+	p := &PgvectorBackend{db: db, table: table}
+	for _, statement := range []string{
+		`CREATE EXTENSION IF NOT EXISTS vector`,
+		`CREATE TABLE IF NOT EXISTS ` + table + ` (
+			id text PRIMARY KEY,
+			text text NOT NULL,
+			model text NOT NULL DEFAULT '',
+			metadata jsonb NOT NULL DEFAULT '{}',
+			embedding vector NOT NULL
+		)`,
+	} {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return nil, fmt.Errorf("failed to prepare pgvector table %s: %w", table, err)
+		}
+	}
+	return p, nil
+}
+
+// Upsert stores embeddings in one transaction
+func (p *PgvectorBackend) Upsert(ctx context.Context, embeddings []Embedding) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statement, err := tx.PrepareContext(ctx, `INSERT INTO `+p.table+` (id, text, model, metadata, embedding)
+		VALUES ($1, $2, $3, $4, $5::vector)
+		ON CONFLICT (id) DO UPDATE SET text = EXCLUDED.text, model = EXCLUDED.model,
+			metadata = EXCLUDED.metadata, embedding = EXCLUDED.embedding`)
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+	for _, embedding := range embeddings {
+		metadata, err := json.Marshal(embedding.Metadata)
+		if err != nil || embedding.Metadata == nil {
+			metadata = []byte("{}")
+		}
+		if _, err := statement.ExecContext(ctx, embedding.ID, embedding.Text, embedding.Model, string(metadata), vectorLiteral(embedding.Vector)); err != nil {
+			return fmt.Errorf("failed to store %s: %w", embedding.ID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Delete removes the rows of ids
+func (p *PgvectorBackend) Delete(ctx context.Context, ids []string) error {
+	for _, id := range ids {
+		if _, err := p.db.ExecContext(ctx, `DELETE FROM `+p.table+` WHERE id = $1`, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get returns the row for id, or nil
+func (p *PgvectorBackend) Get(ctx context.Context, id string) (*Embedding, error) {
+	row := p.db.QueryRowContext(ctx, `SELECT id, text, model, metadata, embedding::text, 1 FROM `+p.table+` WHERE id = $1`, id)
+	result, err := scanResult(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &result.Embedding, nil
+}
+
+// Query orders the rows matching filter by cosine distance, <=>, reporting
+// 1 - distance as the similarity
+func (p *PgvectorBackend) Query(ctx context.Context, vector []float64, topK int, filter Filter) ([]SearchResult, error) {
+	where, args := pgvectorWhere(filter, 3)
+	rows, err := p.db.QueryContext(ctx, `SELECT id, text, model, metadata, embedding::text, 1 - (embedding <=> $1::vector)
+		FROM `+p.table+where+` ORDER BY embedding <=> $1::vector LIMIT $2`,
+		append([]interface{}{vectorLiteral(vector), topK}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		result, err := scanResult(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// Count returns the number of rows
+func (p *PgvectorBackend) Count(ctx context.Context) (int, error) {
+	var n int
+	err := p.db.QueryRowContext(ctx, `SELECT count(*) FROM `+p.table).Scan(&n)
+	return n, err
+}
+
+// scanResult reads a row of id, text, model, metadata, embedding and
+// similarity
+func scanResult(row interface{ Scan(...interface{}) error }) (SearchResult, error) {
+	var result SearchResult
+	var metadata, vector string
+	embedding := &result.Embedding
+	if err := row.Scan(&embedding.ID, &embedding.Text, &embedding.Model, &metadata, &vector, &result.Similarity); err != nil {
+		return result, err
+	}
+	if err := json.Unmarshal([]byte(metadata), &embedding.Metadata); err != nil {
+		return result, fmt.Errorf("invalid metadata for %s: %w", embedding.ID, err)
+	}
+	var err error
+	if embedding.Vector, err = parseVectorLiteral(vector); err != nil {
+		return result, fmt.Errorf("invalid embedding for %s: %w", embedding.ID, err)
+	}
+	embedding.Dimensions = len(embedding.Vector)
+	return result, nil
+}
+
+// pgvectorWhere translates a filter to a WHERE clause whose parameters are
+// numbered from first. Metadata values compare as text, as in memory;
+// ranges only match JSON numbers.
+func pgvectorWhere(filter Filter, first int) (string, []interface{}) {
+	if len(filter) == 0 {
+		return "", nil
+	}
+	var clauses []string
+	var args []interface{}
+	param := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(first+len(args)-1)
+	}
+	for _, condition := range filter {
+		key := param(condition.Key) + "::text"
+		if !condition.isRange {
+			placeholders := make([]string, len(condition.Values))
+			for i, value := range condition.Values {
+				placeholders[i] = param(value)
+			}
+			clauses = append(clauses, "metadata->>"+key+" IN ("+strings.Join(placeholders, ", ")+")")
+			continue
+		}
+
+		bounds := []string{"jsonb_typeof(metadata->" + key + ") = 'number'"}
+		number := "(metadata->>" + key + ")::float8"
+		if !math.IsInf(condition.Min, -1) {
+			op := " >= "
+			if condition.MinExclusive {
+				op = " > "
+			}
+			bounds = append(bounds, number+op+param(condition.Min))
+		}
+		if !math.IsInf(condition.Max, 1) {
+			op := " <= "
+			if condition.MaxExclusive {
+				op = " < "
+			}
+			bounds = append(bounds, number+op+param(condition.Max))
+		}
+		clauses = append(clauses, "("+strings.Join(bounds, " AND ")+")")
+	}
+	return " WHERE " + strings.Join(clauses, " AND "), args
+}
+
+// vectorLiteral formats a vector as pgvector reads it, "[1,2,3]"
+func vectorLiteral(vector []float64) string {
+	parts := make([]string, len(vector))
+	for i, x := range vector {
+		parts[i] = strconv.FormatFloat(x, 'g', -1, 32)
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
+
+// parseVectorLiteral reads a vector as pgvector writes it
+func parseVectorLiteral(text string) ([]float64, error) {
+	text = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(text), "["), "]")
+	if text == "" {
+		return []float64{}, nil
+	}
+	parts := strings.Split(text, ",")
+	vector := make([]float64, len(parts))
+	for i, part := range parts {
+		x, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, err
+		}
+		vector[i] = x
+	}
+	return vector, nil
+}
@@ -0,0 +1,224 @@
+package vectors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Metadata fields PineconeBackend adds to each vector's own metadata
+const (
+	pineconeTextField  = "_text"
+	pineconeModelField = "_model"
+)
+
+// PineconeBackend keeps embeddings in a Pinecone index, through its data
+// plane API. The index must already exist, with the cosine metric and the
+// embedding model's dimensions. The document text and model are kept in
+// each vector's metadata, whose values Pinecone limits to strings, numbers,
+// bools and lists of strings.
+type PineconeBackend struct {
+	host      string
+	apiKey    string
+	namespace string
+	http      *http.Client
+}
+
+// NewPineconeBackend uses the index served at host, as shown in the
+// Pinecone console (e.g. my-index-abc123.svc.us-east-1.pinecone.io), within
+// namespace, which may be empty
+func NewPineconeBackend(host, apiKey, namespace string) *PineconeBackend {
+	if !strings.Contains(host, "://") {
+		host = "https://" + host
+	}
+	return &PineconeBackend{
+		host:      strings.TrimSuffix(host, "/"),
+		apiKey:    apiKey,
+		namespace: namespace,
+		http:      &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// pineconeVector is a vector as Pinecone stores and returns it
+type pineconeVector struct {
+	ID       string                 `json:"id"`
+	Values   []float64              `json:"values,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	Score    float64                `json:"score,omitempty"`
+}
+
+// pineconeUpsertLimit is the most vectors one upsert request may carry
+const pineconeUpsertLimit = 100
+
+// Upsert stores embeddings, in requests of up to 100 vectors
+func (p *PineconeBackend) Upsert(ctx context.Context, embeddings []Embedding) error {
+	for start := 0; start < len(embeddings); start += pineconeUpsertLimit {
+		end := start + pineconeUpsertLimit
+		if end > len(embeddings) {
+			end = len(embeddings)
+		}
+		vectors := make([]pineconeVector, 0, end-start)
+		for _, embedding := range embeddings[start:end] {
+			metadata := map[string]interface{}{pineconeTextField: embedding.Text, pineconeModelField: embedding.Model}
+			for key, value := range embedding.Metadata {
+				metadata[key] = value
+			}
+			vectors = append(vectors, pineconeVector{ID: embedding.ID, Values: embedding.Vector, Metadata: metadata})
+		}
+		if err := p.call(ctx, http.MethodPost, "/vectors/upsert", map[string]interface{}{"vectors": vectors, "namespace": p.namespace}, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes the vectors of ids
+func (p *PineconeBackend) Delete(ctx context.Context, ids []string) error {
+	return p.call(ctx, http.MethodPost, "/vectors/delete", map[string]interface{}{"ids": ids, "namespace": p.namespace}, nil)
+}
+
+// Get fetches the vector for id, or nil
+func (p *PineconeBackend) Get(ctx context.Context, id string) (*Embedding, error) {
+	query := url.Values{"ids": {id}}
+	if p.namespace != "" {
+		query.Set("namespace", p.namespace)
+	}
+	var resp struct {
+		Vectors map[string]pineconeVector `json:"vectors"`
+	}
+	if err := p.call(ctx, http.MethodGet, "/vectors/fetch?"+query.Encode(), nil, &resp); err != nil {
+		return nil, err
+	}
+	vector, ok := resp.Vectors[id]
+	if !ok {
+		return nil, nil
+	}
+	embedding := vector.embedding()
+	return &embedding, nil
+}
+
+// Query searches the namespace with filter translated to Pinecone's
+func (p *PineconeBackend) Query(ctx context.Context, vector []float64, topK int, filter Filter) ([]SearchResult, error) {
+	request := map[string]interface{}{
+		"vector": vector, "topK": topK, "namespace": p.namespace,
+		"includeMetadata": true, "includeValues": true,
+	}
+	if len(filter) > 0 {
+		request["filter"] = pineconeFilter(filter)
+	}
+	var resp struct {
+		Matches []pineconeVector `json:"matches"`
+	}
+	if err := p.call(ctx, http.MethodPost, "/query", request, &resp); err != nil {
+		return nil, err
+	}
+	results := make([]SearchResult, len(resp.Matches))
+	for i, match := range resp.Matches {
+		results[i] = SearchResult{Embedding: match.embedding(), Similarity: match.Score}
+	}
+	return results, nil
+}
+
+// Count returns the number of vectors in the namespace
+func (p *PineconeBackend) Count(ctx context.Context) (int, error) {
+	var stats struct {
+		Namespaces map[string]struct {
+			VectorCount int `json:"vectorCount"`
+		} `json:"namespaces"`
+	}
+	if err := p.call(ctx, http.MethodPost, "/describe_index_stats", map[string]interface{}{}, &stats); err != nil {
+		return 0, err
+	}
+	return stats.Namespaces[p.namespace].VectorCount, nil
+}
+
+// embedding rebuilds the stored embedding from a vector, separating the
+// text and model from the document's metadata
+func (v pineconeVector) embedding() Embedding {
+	embedding := Embedding{ID: v.ID, Vector: v.Values, Dimensions: len(v.Values), Metadata: map[string]interface{}{}}
+	for key, value := range v.Metadata {
+		switch key {
+		case pineconeTextField:
+			embedding.Text, _ = value.(string)
+		case pineconeModelField:
+			embedding.Model, _ = value.(string)
+		default:
+			embedding.Metadata[key] = value
+		}
+	}
+	return embedding
+}
+
+// pineconeFilter translates a filter to Pinecone's MongoDB-style one
+func pineconeFilter(filter Filter) map[string]interface{} {
+	and := make([]interface{}, 0, len(filter))
+	for _, condition := range filter {
+		if condition.isRange {
+			bounds := map[string]interface{}{}
+			switch {
+			case math.IsInf(condition.Min, -1):
+			case condition.MinExclusive:
+				bounds["$gt"] = condition.Min
+			default:
+				bounds["$gte"] = condition.Min
+			}
+			switch {
+			case math.IsInf(condition.Max, 1):
+			case condition.MaxExclusive:
+				bounds["$lt"] = condition.Max
+			default:
+				bounds["$lte"] = condition.Max
+			}
+			and = append(and, map[string]interface{}{condition.Key: bounds})
+			continue
+		}
+		or := make([]interface{}, len(condition.Values))
+		for i, value := range condition.Values {
+			or[i] = map[string]interface{}{condition.Key: map[string]interface{}{"$eq": filterValue(value)}}
+		}
+		and = append(and, map[string]interface{}{"$or": or})
+	}
+	return map[string]interface{}{"$and": and}
+}
+
+// call sends a request to the index at path and decodes the response into
+// result, when given
+func (p *PineconeBackend) call(ctx context.Context, method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, p.host+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Api-Key", p.apiKey)
+
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("pinecone request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return newStatusError("pinecone", resp)
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode pinecone response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package vectors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// QdrantBackend keeps embeddings in a Qdrant collection, through its REST
+// API. Point IDs are UUIDs derived from document IDs; each point's payload
+// holds the document ID, text, model and metadata.
+type QdrantBackend struct {
+	baseURL    string
+	collection string
+	apiKey     string
+	http       *http.Client
+	// created is set once the collection is known to exist
+	created bool
+}
+
+// NewQdrantBackend uses collection on the Qdrant server at baseURL, e.g.
+// http://localhost:6333, creating it on the first upsert if needed. apiKey
+// is only needed for Qdrant Cloud.
+func NewQdrantBackend(baseURL, collection, apiKey string) *QdrantBackend {
+	return &QdrantBackend{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		collection: collection,
+		apiKey:     apiKey,
+		http:       &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// qdrantPoint is a point as Qdrant stores and returns it
+type qdrantPoint struct {
+	ID      string                 `json:"id"`
+	Vector  []float64              `json:"vector,omitempty"`
+	Payload map[string]interface{} `json:"payload,omitempty"`
+	Score   float64                `json:"score,omitempty"`
+}
+
+// Upsert stores embeddings, creating the collection sized to their vectors
+// if it doesn't exist
+func (q *QdrantBackend) Upsert(ctx context.Context, embeddings []Embedding) error {
+	if len(embeddings) == 0 {
+		return nil
+	}
+	if err := q.ensureCollection(ctx, len(embeddings[0].Vector)); err != nil {
+		return err
+	}
+	points := make([]qdrantPoint, len(embeddings))
+	for i, embedding := range embeddings {
+		points[i] = qdrantPoint{
+			ID:     pointID(embedding.ID),
+			Vector: embedding.Vector,
+			Payload: map[string]interface{}{
+				"doc_id":   embedding.ID,
+				"text":     embedding.Text,
+				"model":    embedding.Model,
+				"metadata": embedding.Metadata,
+			},
+		}
+	}
+	return q.call(ctx, http.MethodPut, "/points?wait=true", map[string]interface{}{"points": points}, nil)
+}
+
+// ensureCollection creates the collection with cosine distance unless it
+// exists
+func (q *QdrantBackend) ensureCollection(ctx context.Context, dimensions int) error {
+	if q.created {
+		return nil
+	}
+	err := q.call(ctx, http.MethodGet, "", nil, nil)
+	var status *statusError
+	if errors.As(err, &status) && status.Code == http.StatusNotFound {
+		err = q.call(ctx, http.MethodPut, "", map[string]interface{}{
+			"vectors": map[string]interface{}{"size": dimensions, "distance": "Cosine"},
+		}, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create collection %s: %w", q.collection, err)
+	}
+	q.created = true
+	return nil
+}
+
+// Delete removes the points of ids
+func (q *QdrantBackend) Delete(ctx context.Context, ids []string) error {
+	points := make([]string, len(ids))
+	for i, id := range ids {
+		points[i] = pointID(id)
+	}
+	return q.call(ctx, http.MethodPost, "/points/delete?wait=true", map[string]interface{}{"points": points}, nil)
+}
+
+// Get returns the point for id, or nil
+func (q *QdrantBackend) Get(ctx context.Context, id string) (*Embedding, error) {
+	var points []qdrantPoint
+	err := q.call(ctx, http.MethodPost, "/points", map[string]interface{}{
+		"ids": []string{pointID(id)}, "with_payload": true, "with_vector": true,
+	}, &points)
+	if err != nil || len(points) == 0 {
+		return nil, err
+	}
+	embedding := points[0].embedding()
+	return &embedding, nil
+}
+
+// Query searches the collection with filter translated to Qdrant's
+func (q *QdrantBackend) Query(ctx context.Context, vector []float64, topK int, filter Filter) ([]SearchResult, error) {
+	request := map[string]interface{}{
+		"vector": vector, "limit": topK, "with_payload": true, "with_vector": true,
+	}
+	if len(filter) > 0 {
+		request["filter"] = qdrantFilter(filter)
+	}
+	var points []qdrantPoint
+	if err := q.call(ctx, http.MethodPost, "/points/search", request, &points); err != nil {
+		return nil, err
+	}
+	results := make([]SearchResult, len(points))
+	for i, point := range points {
+		results[i] = SearchResult{Embedding: point.embedding(), Similarity: point.Score}
+	}
+	return results, nil
+}
+
+// Count returns the exact number of points
+func (q *QdrantBackend) Count(ctx context.Context) (int, error) {
+	var result struct {
+		Count int `json:"count"`
+	}
+	err := q.call(ctx, http.MethodPost, "/points/count", map[string]interface{}{"exact": true}, &result)
+	return result.Count, err
+}
+
+// embedding rebuilds the stored embedding from a point
+func (p qdrantPoint) embedding() Embedding {
+	embedding := Embedding{Vector: p.Vector}
+	embedding.ID, _ = p.Payload["doc_id"].(string)
+	embedding.Text, _ = p.Payload["text"].(string)
+	embedding.Model, _ = p.Payload["model"].(string)
+	embedding.Metadata, _ = p.Payload["metadata"].(map[string]interface{})
+	embedding.Dimensions = len(p.Vector)
+	return embedding
+}
+
+// qdrantFilter translates a filter: every condition must hold, a set
+// matches any of its values, and ranges become range conditions
+func qdrantFilter(filter Filter) map[string]interface{} {
+	must := make([]interface{}, 0, len(filter))
+	for _, condition := range filter {
+		key := "metadata." + condition.Key
+		if condition.isRange {
+			bounds := map[string]interface{}{}
+			switch {
+			case math.IsInf(condition.Min, -1):
+			case condition.MinExclusive:
+				bounds["gt"] = condition.Min
+			default:
+				bounds["gte"] = condition.Min
+			}
+			switch {
+			case math.IsInf(condition.Max, 1):
+			case condition.MaxExclusive:
+				bounds["lt"] = condition.Max
+			default:
+				bounds["lte"] = condition.Max
+			}
+			must = append(must, map[string]interface{}{"key": key, "range": bounds})
+			continue
+		}
+		should := make([]interface{}, len(condition.Values))
+		for i, value := range condition.Values {
+			should[i] = map[string]interface{}{"key": key, "match": map[string]interface{}{"value": filterValue(value)}}
+		}
+		must = append(must, map[string]interface{}{"should": should})
+	}
+	return map[string]interface{}{"must": must}
+}
+
+// call sends a request for the collection at path and decodes the
+// response's result into result, when given
+func (q *QdrantBackend) call(ctx context.Context, method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, q.baseURL+"/collections/"+url.PathEscape(q.collection)+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if q.apiKey != "" {
+		req.Header.Set("api-key", q.apiKey)
+	}
+
+	resp, err := q.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("qdrant request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return newStatusError("qdrant", resp)
+	}
+	if result == nil {
+		return nil
+	}
+	var envelope struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("failed to decode qdrant response: %w", err)
+	}
+	return json.Unmarshal(envelope.Result, result)
+}
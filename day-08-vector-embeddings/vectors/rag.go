@@ -0,0 +1,101 @@
+package vectors
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// RAGAnswer is a generated answer with the chunks it was grounded on
+type RAGAnswer struct {
+	Question string
+	Answer   string
+	Sources  []SearchResult
+	// Citations are the 1-based Sources numbers cited in the answer
+	Citations []int
+	Cached    bool
+}
+
+// RAGPipeline answers questions from the vector store's documents
+type RAGPipeline struct {
+	store *VectorStore
+	cache *AnswerCache
+	TopK  int
+	Model string
+}
+
+// NewRAGPipeline creates a pipeline over store. Cached answers are dropped
+// automatically when a chunk they cite is updated or deleted.
+func NewRAGPipeline(store *VectorStore, cache *AnswerCache) *RAGPipeline {
+	if cache != nil {
+		store.OnChange(func(id string) {
+			cache.InvalidateChunk(id)
+		})
+	}
+	return &RAGPipeline{
+		store: store,
+		cache: cache,
+		TopK:  3,
+		Model: openai.GPT3Dot5Turbo,
+	}
+}
+
+// Answer retrieves relevant chunks and generates a grounded answer, serving
+// it from the cache when the same question was asked over the same chunks
+func (p *RAGPipeline) Answer(ctx context.Context, question string) (*RAGAnswer, error) {
+	results, err := p.store.Search(ctx, question, p.TopK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve context: %w", err)
+	}
+
+	answer := &RAGAnswer{Question: question, Sources: results}
+
+	var key string
+	if p.cache != nil {
+		key = AnswerCacheKey(question, results)
+		if cached, ok := p.cache.Get(key); ok {
+			answer.Answer = cached
+			answer.Citations = parseCitations(cached, len(results))
+			answer.Cached = true
+			return answer, nil
+		}
+	}
+
+	resp, err := p.store.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model: p.Model,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: "Answer using only the numbered context passages. Cite passages like [1]. If the context does not contain the answer, say so."},
+			{Role: openai.ChatMessageRoleUser, Content: buildRAGPrompt(question, results)},
+		},
+		Temperature: 0.2,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate answer: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("no response choices returned")
+	}
+	answer.Answer = resp.Choices[0].Message.Content
+	answer.Citations = parseCitations(answer.Answer, len(results))
+
+	if p.cache != nil {
+		ids := make([]string, 0, len(results))
+		for _, result := range results {
+			ids = append(ids, result.Embedding.ID)
+		}
+		p.cache.Put(key, answer.Answer, ids)
+	}
+
+	return answer, nil
+}
+
+// buildRAGPrompt numbers the retrieved passages so the answer can cite them
+func buildRAGPrompt(question string, results []SearchResult) string {
+	var builder strings.Builder
+	builder.WriteString("Context:\n")
+	builder.WriteString(formatPassages(results))
+	builder.WriteString("\nQuestion: " + question)
+	return builder.String()
+}
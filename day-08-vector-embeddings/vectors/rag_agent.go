@@ -0,0 +1,262 @@
+package vectors
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sakibmulla/agentic-ai/day-08-vector-embeddings/chunker"
+	"github.com/sakibmulla/agentic-ai/tools"
+	"github.com/sashabaranov/go-openai"
+)
+
+// ConversationMemory is the context window a RAGAgent answers within.
+// Day 5's MemoryManager has this shape; ConversationBuffer is a minimal
+// implementation for running the agent on its own.
+type ConversationMemory interface {
+	// AddMessage records a user or assistant message
+	AddMessage(role, content string)
+	// ContextMessages returns the messages for the next model call: the
+	// system prompt, any summaries and the recent history, ending with the
+	// latest user message
+	ContextMessages() []openai.ChatCompletionMessage
+}
+
+// RAGConfig controls how a RAGAgent chunks documents and retrieves passages
+type RAGConfig struct {
+	// ChunkTokens is the size of a chunk; ChunkOverlap is how many tokens
+	// consecutive chunks share
+	ChunkTokens  int
+	ChunkOverlap int
+	// TopK is how many chunks are retrieved per question
+	TopK int
+	// MinSimilarity drops retrieved chunks scoring below it
+	MinSimilarity float64
+	// ContextTokens bounds the passages injected into the context window
+	ContextTokens int
+	Model         string
+}
+
+// DefaultRAGConfig returns settings suited to prose documents
+func DefaultRAGConfig() RAGConfig {
+	return RAGConfig{
+		ChunkTokens:   300,
+		ChunkOverlap:  50,
+		TopK:          4,
+		MinSimilarity: 0.75,
+		ContextTokens: 1500,
+		Model:         openai.GPT3Dot5Turbo,
+	}
+}
+
+// RAGAgent answers questions in a conversation, grounding each answer in
+// passages retrieved from the vector store. Passages are injected for the
+// current question only; the memory keeps just the questions and answers.
+type RAGAgent struct {
+	store  *VectorStore
+	memory ConversationMemory
+	config RAGConfig
+	tools  *tools.Registry
+}
+
+// maxToolRounds bounds the tool calls made while answering one question
+const maxToolRounds = 5
+
+// NewRAGAgent creates an agent over store that remembers the conversation in memory
+func NewRAGAgent(store *VectorStore, memory ConversationMemory, config RAGConfig) *RAGAgent {
+	return &RAGAgent{store: store, memory: memory, config: config, tools: tools.NewRegistry()}
+}
+
+// Tools returns the registry of tools the model may call while answering,
+// e.g. a calculator for questions about figures in the documents
+func (a *RAGAgent) Tools() *tools.Registry {
+	return a.tools
+}
+
+// Ingest chunks a document and adds the chunks to the store as <id>#<n>.
+// The format is detected from id and the text, so "guide.md" is split by
+// markdown sections.
+func (a *RAGAgent) Ingest(ctx context.Context, id, text string, metadata map[string]interface{}) (*BatchReport, error) {
+	return a.store.IngestText(ctx, id, text, chunker.Config{
+		Size:    a.config.ChunkTokens,
+		Overlap: a.config.ChunkOverlap,
+		Format:  chunker.DetectFormat(id, text),
+	}, metadata)
+}
+
+// Ask answers question in the context of the conversation so far. The
+// answer's Sources are the passages the model was given and Citations the
+// ones it cited.
+func (a *RAGAgent) Ask(ctx context.Context, question string) (*RAGAnswer, error) {
+	results, err := a.store.Search(ctx, question, a.config.TopK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve context: %w", err)
+	}
+	passages := a.selectPassages(results)
+
+	a.memory.AddMessage(openai.ChatMessageRoleUser, question)
+	messages := injectPassages(a.memory.ContextMessages(), passages)
+
+	content, err := a.complete(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+	a.memory.AddMessage(openai.ChatMessageRoleAssistant, content)
+	return &RAGAnswer{
+		Question:  question,
+		Answer:    content,
+		Sources:   passages,
+		Citations: parseCitations(content, len(passages)),
+	}, nil
+}
+
+// complete generates the answer to messages, running any tools the model
+// calls first. Tool calls and results are not added to memory.
+func (a *RAGAgent) complete(ctx context.Context, messages []openai.ChatCompletionMessage) (string, error) {
+	var functions []openai.FunctionDefinition
+	for _, definition := range a.tools.Definitions() {
+		functions = append(functions, openai.FunctionDefinition{
+			Name:        definition.Name,
+			Description: definition.Description,
+			Parameters:  definition.Parameters,
+		})
+	}
+
+	for round := 0; round <= maxToolRounds; round++ {
+		resp, err := a.store.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+			Model:       a.config.Model,
+			Messages:    messages,
+			Functions:   functions,
+			Temperature: 0.2,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to generate answer: %w", err)
+		}
+		if len(resp.Choices) == 0 {
+			return "", fmt.Errorf("no response choices returned")
+		}
+
+		reply := resp.Choices[0].Message
+		if reply.FunctionCall == nil {
+			return reply.Content, nil
+		}
+		result, err := a.tools.Call(ctx, reply.FunctionCall.Name, reply.FunctionCall.Arguments)
+		if err != nil {
+			result = fmt.Sprintf("Error: %v", err)
+		}
+		messages = append(messages, reply, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleFunction,
+			Name:    reply.FunctionCall.Name,
+			Content: result,
+		})
+	}
+	return "", fmt.Errorf("no answer after %d tool calls", maxToolRounds)
+}
+
+// selectPassages keeps the results above the relevance threshold that fit
+// the passage token budget, best first
+func (a *RAGAgent) selectPassages(results []SearchResult) []SearchResult {
+	var passages []SearchResult
+	tokens := 0
+	for _, result := range results {
+		if result.Similarity < a.config.MinSimilarity {
+			continue
+		}
+		size := estimateTokens(result.Embedding.Text)
+		if a.config.ContextTokens > 0 && tokens+size > a.config.ContextTokens {
+			continue
+		}
+		passages = append(passages, result)
+		tokens += size
+	}
+	return passages
+}
+
+// injectPassages inserts the numbered passages as a system message just
+// before the latest user message
+func injectPassages(messages []openai.ChatCompletionMessage, passages []SearchResult) []openai.ChatCompletionMessage {
+	instruction := "No relevant passages were found for this question. Say you don't know rather than guessing."
+	if len(passages) > 0 {
+		instruction = "Answer the next question using only these numbered passages. Cite them like [1]. If they do not contain the answer, say so.\n\n" + formatPassages(passages)
+	}
+	injected := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleSystem, Content: instruction}
+
+	at := len(messages)
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == openai.ChatMessageRoleUser {
+			at = i
+			break
+		}
+	}
+	result := make([]openai.ChatCompletionMessage, 0, len(messages)+1)
+	result = append(result, messages[:at]...)
+	result = append(result, injected)
+	return append(result, messages[at:]...)
+}
+
+// formatPassages numbers passages and labels them with their chunk ID
+func formatPassages(passages []SearchResult) string {
+	var builder strings.Builder
+	for i, passage := range passages {
+		builder.WriteString(fmt.Sprintf("[%d] (%s) %s\n", i+1, passage.Embedding.ID, passage.Embedding.Text))
+	}
+	return builder.String()
+}
+
+// citationPattern matches a citation such as [2]
+var citationPattern = regexp.MustCompile(`\[(\d+)\]`)
+
+// parseCitations returns the passage numbers cited in answer, in order of
+// first citation, ignoring numbers with no matching passage
+func parseCitations(answer string, passages int) []int {
+	var cited []int
+	seen := make(map[int]bool)
+	for _, match := range citationPattern.FindAllStringSubmatch(answer, -1) {
+		n, err := strconv.Atoi(match[1])
+		if err != nil || n < 1 || n > passages || seen[n] {
+			continue
+		}
+		seen[n] = true
+		cited = append(cited, n)
+	}
+	return cited
+}
+
+// ConversationBuffer is a ConversationMemory holding the most recent
+// messages that fit a token budget
+type ConversationBuffer struct {
+	SystemPrompt string
+	MaxTokens    int
+	messages     []openai.ChatCompletionMessage
+}
+
+// NewConversationBuffer creates a buffer keeping about maxTokens of history
+func NewConversationBuffer(maxTokens int) *ConversationBuffer {
+	return &ConversationBuffer{
+		SystemPrompt: "You are a helpful assistant answering questions about a document collection.",
+		MaxTokens:    maxTokens,
+	}
+}
+
+// AddMessage records a message
+func (b *ConversationBuffer) AddMessage(role, content string) {
+	b.messages = append(b.messages, openai.ChatCompletionMessage{Role: role, Content: content})
+}
+
+// ContextMessages returns the system prompt and as many recent messages as fit the budget
+func (b *ConversationBuffer) ContextMessages() []openai.ChatCompletionMessage {
+	start, tokens := len(b.messages), 0
+	for start > 0 {
+		size := estimateTokens(b.messages[start-1].Content)
+		if b.MaxTokens > 0 && tokens+size > b.MaxTokens && start < len(b.messages) {
+			break
+		}
+		tokens += size
+		start--
+	}
+
+	messages := []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleSystem, Content: b.SystemPrompt}}
+	return append(messages, b.messages[start:]...)
+}
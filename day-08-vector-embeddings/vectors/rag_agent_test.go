@@ -0,0 +1,130 @@
+package vectors
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sakibmulla/agentic-ai/endpoint"
+	"github.com/sakibmulla/agentic-ai/tools"
+	"github.com/sashabaranov/go-openai"
+)
+
+// newRAGServer fakes the API: each input is embedded as a vector whose
+// first element is its length, and each chat completion is answered with
+// reply, given the request
+func newRAGServer(t *testing.T, reply func(openai.ChatCompletionRequest) openai.ChatCompletionMessage) *VectorStore {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/chat/completions") {
+			var req openai.ChatCompletionRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("bad request: %v", err)
+			}
+			json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+				Choices: []openai.ChatCompletionChoice{{Message: reply(req)}},
+			})
+			return
+		}
+		var req struct {
+			Input []string `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("bad request: %v", err)
+		}
+		resp := openai.EmbeddingResponse{}
+		for i, input := range req.Input {
+			resp.Data = append(resp.Data, openai.Embedding{Index: i, Embedding: []float32{float32(len(input)), 0, 0, 1}})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+	store := NewVectorStore("test-key", endpoint.Endpoint{BaseURL: server.URL + "/v1"}, nil)
+	store.SetEmbeddingModel(EmbeddingModel{Name: "local"})
+	return store
+}
+
+func TestRAGAgentAsk(t *testing.T) {
+	var requests []openai.ChatCompletionRequest
+	store := newRAGServer(t, func(req openai.ChatCompletionRequest) openai.ChatCompletionMessage {
+		requests = append(requests, req)
+		if len(requests) == 1 {
+			return openai.ChatCompletionMessage{
+				Role:         openai.ChatMessageRoleAssistant,
+				FunctionCall: &openai.FunctionCall{Name: "calculator", Arguments: `{"operation":"multiply","a":12,"b":4}`},
+			}
+		}
+		return openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: "Four batches make 48 widgets [1], see also [9]."}
+	})
+	ctx := context.Background()
+	if err := store.AddDocument(ctx, "factory#1", "The factory makes twelve widgets per batch.", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	memory := NewConversationBuffer(1000)
+	agent := NewRAGAgent(store, memory, DefaultRAGConfig())
+	agent.Tools().MustRegister(tools.Calculator())
+
+	answer, err := agent.Ask(ctx, "How many widgets do four batches make?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(answer.Sources) != 1 || answer.Sources[0].Embedding.ID != "factory#1" {
+		t.Errorf("Expected the retrieved passage as the source, got %+v", answer.Sources)
+	}
+	if len(answer.Citations) != 1 || answer.Citations[0] != 1 {
+		t.Errorf("Expected only the citation of a real passage, got %v", answer.Citations)
+	}
+
+	// The passages go in just before the question, and the tool result follows the call
+	if len(requests) != 2 {
+		t.Fatalf("Expected a tool round, then the answer, got %d requests", len(requests))
+	}
+	first := requests[0].Messages
+	if len(first) != 3 || first[1].Role != openai.ChatMessageRoleSystem || !strings.Contains(first[1].Content, "[1] (factory#1) The factory") ||
+		first[2].Content != "How many widgets do four batches make?" {
+		t.Errorf("Expected the passages injected before the question, got %+v", first)
+	}
+	if len(requests[0].Functions) != 1 || requests[0].Functions[0].Name != "calculator" {
+		t.Errorf("Expected the calculator offered, got %+v", requests[0].Functions)
+	}
+	second := requests[1].Messages
+	if last := second[len(second)-1]; last.Role != openai.ChatMessageRoleFunction || last.Name != "calculator" || !strings.Contains(last.Content, "48") {
+		t.Errorf("Expected the calculator's result sent back, got %+v", last)
+	}
+
+	// Memory keeps the question and answer, not the passages or tool calls
+	messages := memory.ContextMessages()
+	if len(messages) != 3 || messages[1].Role != openai.ChatMessageRoleUser || messages[2].Content != answer.Answer {
+		t.Errorf("Expected only the question and answer remembered, got %+v", messages)
+	}
+}
+
+func TestRAGAgentToolRoundLimit(t *testing.T) {
+	calls := 0
+	store := newRAGServer(t, func(openai.ChatCompletionRequest) openai.ChatCompletionMessage {
+		calls++
+		return openai.ChatCompletionMessage{
+			Role:         openai.ChatMessageRoleAssistant,
+			FunctionCall: &openai.FunctionCall{Name: "calculator", Arguments: `{"operation":"add","a":1,"b":1}`},
+		}
+	})
+	memory := NewConversationBuffer(1000)
+	agent := NewRAGAgent(store, memory, DefaultRAGConfig())
+	agent.Tools().MustRegister(tools.Calculator())
+
+	_, err := agent.Ask(context.Background(), "What is one plus one, forever?")
+	if err == nil || !strings.Contains(err.Error(), "no answer after") {
+		t.Fatalf("Expected the tool rounds capped, got %v", err)
+	}
+	if calls != maxToolRounds+1 {
+		t.Errorf("Expected %d model calls, got %d", maxToolRounds+1, calls)
+	}
+	// An agent that gives up remembers no answer
+	if messages := memory.ContextMessages(); messages[len(messages)-1].Role != openai.ChatMessageRoleUser {
+		t.Errorf("Expected no answer remembered, got %+v", messages)
+	}
+}
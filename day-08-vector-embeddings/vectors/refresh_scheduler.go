@@ -0,0 +1,179 @@
+package vectors
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// pendingRefresh is a changed document waiting to be re-embedded
+type pendingRefresh struct {
+	id       string
+	text     string
+	metadata map[string]interface{}
+	queuedAt time.Time
+}
+
+// BacklogReport describes the refresh queue
+type BacklogReport struct {
+	Pending         int
+	OldestQueued    time.Time
+	UsedThisHour    int
+	BudgetPerHour   int
+	EstimatedDrain  time.Duration
+	LastError       string
+	ProcessedTotal  int
+	ByRetrievalRank []string // pending IDs in the order they will be processed
+}
+
+// RefreshScheduler queues changed documents and re-embeds them within an
+// embeddings-per-hour budget, so bursts of edits don't exhaust API quota.
+// Frequently retrieved documents are refreshed first.
+type RefreshScheduler struct {
+	store     *VectorStore
+	perHour   int
+	pending   map[string]*pendingRefresh
+	spent     []time.Time // embedding calls in the last hour
+	processed int
+	lastError string
+	now       func() time.Time
+	mu        sync.Mutex
+}
+
+// NewRefreshScheduler creates a scheduler allowing perHour embeddings per hour
+func NewRefreshScheduler(store *VectorStore, perHour int) *RefreshScheduler {
+	if perHour <= 0 {
+		perHour = 60
+	}
+	return &RefreshScheduler{
+		store:   store,
+		perHour: perHour,
+		pending: make(map[string]*pendingRefresh),
+		now:     time.Now,
+	}
+}
+
+// Enqueue schedules a document for (re-)embedding. Queuing the same ID again
+// replaces the pending text, so only the latest version is embedded.
+func (rs *RefreshScheduler) Enqueue(id, text string, metadata map[string]interface{}) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	queuedAt := rs.now()
+	if existing, ok := rs.pending[id]; ok {
+		queuedAt = existing.queuedAt
+	}
+	rs.pending[id] = &pendingRefresh{id: id, text: text, metadata: metadata, queuedAt: queuedAt}
+}
+
+// RunOnce embeds as many queued documents as the remaining hourly budget
+// allows and returns how many were processed. The VectorStore is not safe
+// for concurrent use, so call this from the goroutine that owns the store.
+func (rs *RefreshScheduler) RunOnce(ctx context.Context) (int, error) {
+	processed := 0
+	for {
+		item := rs.next()
+		if item == nil {
+			return processed, nil
+		}
+
+		var err error
+		if _, lookupErr := rs.store.GetDocument(item.id); lookupErr == nil {
+			err = rs.store.UpdateDocument(ctx, item.id, item.text, item.metadata)
+		} else {
+			err = rs.store.AddDocument(ctx, item.id, item.text, item.metadata)
+		}
+
+		rs.mu.Lock()
+		if err != nil {
+			// Put it back unless a newer version was queued meanwhile
+			if _, requeued := rs.pending[item.id]; !requeued {
+				rs.pending[item.id] = item
+			}
+			rs.lastError = err.Error()
+			rs.mu.Unlock()
+			return processed, fmt.Errorf("failed to refresh %s: %w", item.id, err)
+		}
+		rs.processed++
+		rs.mu.Unlock()
+		processed++
+	}
+}
+
+// Backlog reports the queue size, budget usage and estimated time to drain
+func (rs *RefreshScheduler) Backlog() BacklogReport {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rs.pruneLocked()
+	report := BacklogReport{
+		Pending:        len(rs.pending),
+		UsedThisHour:   len(rs.spent),
+		BudgetPerHour:  rs.perHour,
+		LastError:      rs.lastError,
+		ProcessedTotal: rs.processed,
+	}
+
+	for _, item := range rs.orderedLocked() {
+		report.ByRetrievalRank = append(report.ByRetrievalRank, item.id)
+		if report.OldestQueued.IsZero() || item.queuedAt.Before(report.OldestQueued) {
+			report.OldestQueued = item.queuedAt
+		}
+	}
+
+	// Whatever doesn't fit in this hour's remaining budget waits for later hours
+	remaining := rs.perHour - len(rs.spent)
+	if overflow := report.Pending - remaining; overflow > 0 {
+		hours := (overflow + rs.perHour - 1) / rs.perHour
+		report.EstimatedDrain = time.Duration(hours) * time.Hour
+	}
+
+	return report
+}
+
+// next takes the highest-priority item if the budget allows and charges for it
+func (rs *RefreshScheduler) next() *pendingRefresh {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rs.pruneLocked()
+	if len(rs.pending) == 0 || len(rs.spent) >= rs.perHour {
+		return nil
+	}
+
+	item := rs.orderedLocked()[0]
+	delete(rs.pending, item.id)
+	rs.spent = append(rs.spent, rs.now())
+	return item
+}
+
+// orderedLocked sorts pending items by retrieval count, then queue time.
+// Callers must hold rs.mu.
+func (rs *RefreshScheduler) orderedLocked() []*pendingRefresh {
+	items := make([]*pendingRefresh, 0, len(rs.pending))
+	for _, item := range rs.pending {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		ri, rj := rs.store.RetrievalCount(items[i].id), rs.store.RetrievalCount(items[j].id)
+		if ri != rj {
+			return ri > rj
+		}
+		return items[i].queuedAt.Before(items[j].queuedAt)
+	})
+	return items
+}
+
+// pruneLocked forgets embedding calls older than an hour. Callers must hold rs.mu.
+func (rs *RefreshScheduler) pruneLocked() {
+	cutoff := rs.now().Add(-time.Hour)
+	kept := rs.spent[:0]
+	for _, t := range rs.spent {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	rs.spent = kept
+}
@@ -0,0 +1,83 @@
+package vectors
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRefreshSchedulerBudget(t *testing.T) {
+	store := newEmbeddingServer(t)
+	store.SetEmbeddingModel(EmbeddingModel{Name: "local"})
+	ctx := context.Background()
+
+	scheduler := NewRefreshScheduler(store, 2)
+	clock := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	scheduler.now = func() time.Time { return clock }
+
+	scheduler.Enqueue("cold", "rarely retrieved", nil)
+	clock = clock.Add(time.Minute)
+	scheduler.Enqueue("newer", "queued later", nil)
+	scheduler.Enqueue("hot", "often retrieved", nil)
+	store.retrievals["hot"] = 5
+	// Queuing again replaces the text but keeps the place in line
+	scheduler.Enqueue("cold", "rarely retrieved, edited", nil)
+
+	backlog := scheduler.Backlog()
+	if got := strings.Join(backlog.ByRetrievalRank, ","); got != "hot,cold,newer" {
+		t.Errorf("Expected hot documents first, then oldest, got %s", got)
+	}
+	if backlog.Pending != 3 || !backlog.OldestQueued.Equal(clock.Add(-time.Minute)) || backlog.EstimatedDrain != time.Hour {
+		t.Errorf("Unexpected backlog %+v", backlog)
+	}
+
+	// Only the hour's budget is spent, in priority order
+	if n, err := scheduler.RunOnce(ctx); err != nil || n != 2 {
+		t.Fatalf("Expected 2 refreshed, got %d %v", n, err)
+	}
+	if doc, err := store.GetDocument("cold"); err != nil || doc.Text != "rarely retrieved, edited" {
+		t.Errorf("Expected the latest text embedded, got %v %v", doc, err)
+	}
+	if _, err := store.GetDocument("newer"); err == nil {
+		t.Error("Expected the lowest priority document to wait")
+	}
+
+	// Nothing is due until the first call leaves the hour
+	clock = clock.Add(59 * time.Minute)
+	if n, _ := scheduler.RunOnce(ctx); n != 0 {
+		t.Errorf("Expected no budget within the hour, got %d refreshed", n)
+	}
+	if backlog := scheduler.Backlog(); backlog.UsedThisHour != 2 || backlog.Pending != 1 {
+		t.Errorf("Unexpected backlog %+v", backlog)
+	}
+	clock = clock.Add(time.Minute)
+	if n, err := scheduler.RunOnce(ctx); err != nil || n != 1 {
+		t.Errorf("Expected the last document refreshed once the budget renewed, got %d %v", n, err)
+	}
+	if backlog := scheduler.Backlog(); backlog.Pending != 0 || backlog.ProcessedTotal != 3 || backlog.EstimatedDrain != 0 {
+		t.Errorf("Unexpected backlog %+v", backlog)
+	}
+}
+
+func TestRefreshSchedulerRequeuesFailures(t *testing.T) {
+	store := newEmbeddingServer(t)
+	store.SetEmbeddingModel(EmbeddingModel{Name: "local"})
+	scheduler := NewRefreshScheduler(store, 10)
+
+	// The fake embedder rejects texts containing "fail"
+	scheduler.Enqueue("broken", "this will fail", nil)
+	n, err := scheduler.RunOnce(context.Background())
+	if err == nil || n != 0 {
+		t.Fatalf("Expected the refresh to fail, got %d %v", n, err)
+	}
+	backlog := scheduler.Backlog()
+	if backlog.Pending != 1 || backlog.LastError == "" || backlog.UsedThisHour != 1 {
+		t.Errorf("Expected the document requeued with its error, and the call charged, got %+v", backlog)
+	}
+
+	scheduler.Enqueue("broken", "fixed now", nil)
+	if n, err := scheduler.RunOnce(context.Background()); err != nil || n != 1 {
+		t.Errorf("Expected the fixed document refreshed, got %d %v", n, err)
+	}
+}
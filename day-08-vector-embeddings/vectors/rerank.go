@@ -0,0 +1,197 @@
+package vectors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Reranker re-scores search candidates against the query, setting each
+// result's Relevance to a calibrated score between 0 and 1
+type Reranker interface {
+	Rerank(ctx context.Context, query string, results []SearchResult) error
+}
+
+// rerankCandidatesPerResult is how many candidates a reranked search
+// fetches per result it returns, when SearchOptions.RerankCandidates is
+// unset
+const rerankCandidatesPerResult = 4
+
+// rerank re-scores results with reranker and returns the topK most relevant.
+// Similarity and Score keep their original values, for debugging.
+func rerank(ctx context.Context, reranker Reranker, query string, results []SearchResult, topK int) ([]SearchResult, error) {
+	if len(results) == 0 {
+		return results, nil
+	}
+	if err := reranker.Rerank(ctx, query, results); err != nil {
+		return nil, fmt.Errorf("failed to rerank: %w", err)
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Relevance > results[j].Relevance })
+	if len(results) > topK {
+		results = results[:topK]
+	}
+	return results, nil
+}
+
+// rerankPrompt asks for a relevance grade per passage
+const rerankPrompt = `You grade how well passages answer a search query.
+For each numbered passage, give a score from 0 to 10: 10 answers the query directly, 5 is on topic but incomplete, 0 is unrelated.
+Reply with JSON only: {"scores": [{"passage": 1, "score": 7}, ...]}, one entry per passage.`
+
+// LLMReranker grades candidates with a chat model, in one call per search
+type LLMReranker struct {
+	client *openai.Client
+	model  string
+}
+
+// NewLLMReranker grades with model, e.g. openai.GPT4oMini, through client
+func NewLLMReranker(client *openai.Client, model string) *LLMReranker {
+	return &LLMReranker{client: client, model: model}
+}
+
+// Rerank sets each result's Relevance to its grade divided by 10. A
+// passage the model skips gets 0.
+func (r *LLMReranker) Rerank(ctx context.Context, query string, results []SearchResult) error {
+	var passages strings.Builder
+	fmt.Fprintf(&passages, "Query: %s\n", query)
+	for i, result := range results {
+		fmt.Fprintf(&passages, "\nPassage %d:\n%s\n", i+1, truncate(result.Embedding.Text, 1500))
+	}
+
+	resp, err := r.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model: r.model,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: rerankPrompt},
+			{Role: openai.ChatMessageRoleUser, Content: passages.String()},
+		},
+		ResponseFormat: &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject},
+		Temperature:    0,
+	})
+	if err != nil {
+		return fmt.Errorf("rerank call failed: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return fmt.Errorf("no response choices returned")
+	}
+
+	var grades struct {
+		Scores []struct {
+			Passage int     `json:"passage"`
+			Score   float64 `json:"score"`
+		} `json:"scores"`
+	}
+	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &grades); err != nil {
+		return fmt.Errorf("failed to parse rerank scores: %w", err)
+	}
+	for i := range results {
+		results[i].Relevance = 0
+	}
+	for _, grade := range grades.Scores {
+		if grade.Passage >= 1 && grade.Passage <= len(results) {
+			results[grade.Passage-1].Relevance = math.Max(0, math.Min(1, grade.Score/10))
+		}
+	}
+	return nil
+}
+
+// CrossEncoderReranker scores candidates with a cross-encoder model served
+// over HTTP with the /rerank API of Hugging Face's text-embeddings-inference
+// (also offered by Infinity and others):
+//
+//	POST {"query": "...", "texts": ["...", ...], "raw_scores": true}
+//	→ [{"index": 0, "score": 2.7}, ...]
+//
+// Raw scores are logits; a sigmoid calibrates them to 0..1.
+type CrossEncoderReranker struct {
+	url  string
+	http *http.Client
+}
+
+// NewCrossEncoderReranker posts to the rerank endpoint at url, e.g.
+// http://localhost:8080/rerank
+func NewCrossEncoderReranker(url string) *CrossEncoderReranker {
+	return &CrossEncoderReranker{url: url, http: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Rerank sets each result's Relevance to the sigmoid of its score
+func (r *CrossEncoderReranker) Rerank(ctx context.Context, query string, results []SearchResult) error {
+	texts := make([]string, len(results))
+	for i, result := range results {
+		texts[i] = result.Embedding.Text
+	}
+	body, err := json.Marshal(map[string]interface{}{"query": query, "texts": texts, "raw_scores": true})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("cross-encoder request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return newStatusError("cross-encoder", resp)
+	}
+
+	var scores []struct {
+		Index int     `json:"index"`
+		Score float64 `json:"score"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&scores); err != nil {
+		return fmt.Errorf("failed to decode cross-encoder scores: %w", err)
+	}
+	if len(scores) != len(results) {
+		return fmt.Errorf("expected %d cross-encoder scores, got %d", len(results), len(scores))
+	}
+	for _, score := range scores {
+		if score.Index < 0 || score.Index >= len(results) {
+			return fmt.Errorf("unexpected cross-encoder index %d", score.Index)
+		}
+		results[score.Index].Relevance = 1 / (1 + math.Exp(-score.Score))
+	}
+	return nil
+}
+
+// rerankerFromEnv returns the reranker RERANKER names: none (the default,
+// nil), llm (graded by RERANK_MODEL) or cross-encoder (at RERANK_URL).
+// RERANK_CANDIDATES is how many candidates it re-scores.
+func rerankerFromEnv(client *openai.Client) (Reranker, int, error) {
+	candidates := 0
+	if text := os.Getenv("RERANK_CANDIDATES"); text != "" {
+		n, err := strconv.Atoi(text)
+		if err != nil || n < 1 {
+			return nil, 0, fmt.Errorf("RERANK_CANDIDATES must be a positive number, got %q", text)
+		}
+		candidates = n
+	}
+
+	switch kind := strings.ToLower(os.Getenv("RERANKER")); kind {
+	case "", "none":
+		return nil, 0, nil
+	case "llm":
+		return NewLLMReranker(client, getEnv("RERANK_MODEL", openai.GPT4oMini)), candidates, nil
+	case "cross-encoder":
+		url := os.Getenv("RERANK_URL")
+		if url == "" {
+			return nil, 0, fmt.Errorf("RERANKER=cross-encoder needs RERANK_URL, e.g. http://localhost:8080/rerank")
+		}
+		return NewCrossEncoderReranker(url), candidates, nil
+	default:
+		return nil, 0, fmt.Errorf("unknown RERANKER %q: use none, llm or cross-encoder", kind)
+	}
+}
@@ -0,0 +1,102 @@
+package vectors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sakibmulla/agentic-ai/endpoint"
+	"github.com/sashabaranov/go-openai"
+)
+
+// lengthReranker scores longer texts as more relevant
+type lengthReranker struct{ seen int }
+
+func (r *lengthReranker) Rerank(ctx context.Context, query string, results []SearchResult) error {
+	r.seen = len(results)
+	for i := range results {
+		results[i].Relevance = float64(len(results[i].Embedding.Text)) / 100
+	}
+	return nil
+}
+
+func TestSearchWithReranker(t *testing.T) {
+	ctx := context.Background()
+	store := newEmbeddingServer(t)
+	store.SetEmbeddingModel(EmbeddingModel{Name: "local"})
+	var docs []Document
+	for i := 1; i <= 10; i++ {
+		docs = append(docs, Document{ID: fmt.Sprint(i), Text: strings.Repeat("x", i*5)})
+	}
+	if _, err := store.AddDocuments(ctx, docs); err != nil {
+		t.Fatal(err)
+	}
+
+	// The query is most similar to the short documents; the reranker
+	// prefers the longest of the candidates
+	reranker := &lengthReranker{}
+	results, err := store.SearchWith(ctx, "xxxxx", SearchOptions{TopK: 2, Reranker: reranker})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reranker.seen != 8 || len(results) != 2 || results[0].Embedding.ID != "8" || results[1].Embedding.ID != "7" {
+		t.Fatalf("Expected the 2 longest of 8 candidates, got %d candidates and %+v", reranker.seen, results)
+	}
+	if results[0].Relevance != 0.4 || results[0].Similarity == 0 || results[0].Similarity != results[0].Score {
+		t.Errorf("Expected relevance beside the original similarity, got %+v", results[0])
+	}
+	if _, err := store.SearchWith(ctx, "xxxxx", SearchOptions{TopK: 2, Reranker: reranker, RerankCandidates: 3}); err != nil || reranker.seen != 3 {
+		t.Errorf("Expected 3 candidates reranked, got %d, %v", reranker.seen, err)
+	}
+}
+
+func TestCrossEncoderReranker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query     string   `json:"query"`
+			Texts     []string `json:"texts"`
+			RawScores bool     `json:"raw_scores"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Query != "q" || len(req.Texts) != 2 || !req.RawScores {
+			t.Errorf("Unexpected request %+v", req)
+		}
+		fmt.Fprint(w, `[{"index":1,"score":2.0},{"index":0,"score":-1.0}]`)
+	}))
+	defer server.Close()
+
+	results := []SearchResult{{Embedding: Embedding{Text: "a"}}, {Embedding: Embedding{Text: "b"}}}
+	if err := NewCrossEncoderReranker(server.URL).Rerank(context.Background(), "q", results); err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(results[0].Relevance-0.2689) > 0.001 || math.Abs(results[1].Relevance-0.8808) > 0.001 {
+		t.Errorf("Expected sigmoid-calibrated scores, got %.4f and %.4f", results[0].Relevance, results[1].Relevance)
+	}
+}
+
+func TestLLMReranker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if !strings.Contains(req.Messages[1].Content, "Passage 2:\nbeta") {
+			t.Errorf("Expected numbered passages, got %q", req.Messages[1].Content)
+		}
+		content, _ := json.Marshal(`{"scores":[{"passage":2,"score":9},{"passage":7,"score":10}]}`)
+		fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":%s}}]}`, content)
+	}))
+	defer server.Close()
+
+	client := openai.NewClientWithConfig(endpoint.Endpoint{BaseURL: server.URL + "/v1"}.Config("test-key"))
+	results := []SearchResult{{Embedding: Embedding{Text: "alpha"}, Relevance: 0.5}, {Embedding: Embedding{Text: "beta"}}}
+	if err := NewLLMReranker(client, openai.GPT4oMini).Rerank(context.Background(), "q", results); err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Relevance != 0 || results[1].Relevance != 0.9 {
+		t.Errorf("Expected grades scaled to 0..1 and skipped passages at 0, got %.2f and %.2f", results[0].Relevance, results[1].Relevance)
+	}
+}
@@ -0,0 +1,94 @@
+package vectors
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/sakibmulla/agentic-ai/day-08-vector-embeddings/chunker"
+	"github.com/sakibmulla/agentic-ai/logging"
+	"github.com/sakibmulla/agentic-ai/tools"
+)
+
+// SearchDocuments searches the store for the search_documents tool
+func (vs *VectorStore) SearchDocuments(ctx context.Context, query string, topK int) ([]tools.Document, error) {
+	results, err := vs.Search(ctx, query, topK)
+	if err != nil {
+		return nil, err
+	}
+	documents := make([]tools.Document, 0, len(results))
+	for _, result := range results {
+		documents = append(documents, tools.Document{
+			ID:       result.Embedding.ID,
+			Score:    result.Similarity,
+			Text:     result.Embedding.Text,
+			Metadata: result.Embedding.Metadata,
+		})
+	}
+	return documents, nil
+}
+
+// searchServer serves a store to other processes. The VectorStore is not
+// safe for concurrent use, so requests take turns, and the workspace is
+// synced by the request holding the lock.
+type searchServer struct {
+	mu        sync.Mutex
+	store     *VectorStore
+	workspace *Workspace
+}
+
+func (s *searchServer) SearchDocuments(ctx context.Context, query string, topK int) ([]tools.Document, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.workspace != nil {
+		if _, err := s.workspace.Sync(ctx); err != nil {
+			slog.ErrorContext(ctx, "failed to reindex workspace", "error", err)
+		}
+	}
+	documents, err := s.store.SearchDocuments(ctx, query, topK)
+	slog.InfoContext(ctx, "searched documents", "query", query, "results", len(documents))
+	return documents, err
+}
+
+// runSearchServer serves vectorStore for the search_documents tool of
+// other agents (e.g. day 3's, with DOCUMENT_SEARCH_URL) on SEARCH_ADDR. It
+// holds the sample documents, the files named on the command line and,
+// with WORKSPACE_DIR, an indexed workspace.
+func runSearchServer(ctx context.Context, vectorStore *VectorStore, files []string) {
+	report, err := vectorStore.AddDocuments(ctx, sampleDocuments())
+	for _, failed := range report.Failed {
+		slog.Error("failed to add document", "id", failed.ID, "error", failed.Err)
+	}
+	if err != nil {
+		logging.Fatal("failed to add the sample documents", "error", err)
+	}
+	for _, path := range files {
+		if _, err := vectorStore.IngestFile(ctx, path, chunker.DefaultConfig()); err != nil {
+			logging.Fatal("failed to ingest file", "path", path, "error", err)
+		}
+	}
+
+	server := &searchServer{store: vectorStore}
+	if root := os.Getenv("WORKSPACE_DIR"); root != "" {
+		server.workspace = NewWorkspace(vectorStore, DefaultWorkspaceConfig(root))
+		if _, err := server.workspace.Index(ctx); err != nil {
+			logging.Fatal("failed to index workspace", "error", err)
+		}
+		go server.workspace.Watch(ctx)
+	}
+
+	addr := os.Getenv("SEARCH_ADDR")
+	if addr == "" {
+		addr = "localhost:8090"
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/search", tools.DocumentSearchHandler(server))
+	fmt.Printf("🔎 Serving %d documents on http://%s/search\n", vectorStore.GetDocumentCount(), addr)
+	if err := http.ListenAndServe(addr, logging.Middleware(mux)); err != nil {
+		logging.Fatal("search server stopped", "error", err)
+	}
+}
@@ -0,0 +1,185 @@
+package vectors
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// SourceTracker remembers the chunks cited for the most recent answer so the
+// user can inspect them with /sources and /open <n>
+type SourceTracker struct {
+	query   string
+	results []SearchResult
+	// workspace, when set, flags sources whose file changed since indexing
+	workspace *Workspace
+}
+
+// NewSourceTracker creates an empty source tracker
+func NewSourceTracker() *SourceTracker {
+	return &SourceTracker{}
+}
+
+// Record stores the results cited for a query, replacing the previous set
+func (st *SourceTracker) Record(query string, results []SearchResult) {
+	st.query = query
+	st.results = results
+}
+
+// SetWorkspace makes FormatSources report the workspace index's freshness
+func (st *SourceTracker) SetWorkspace(workspace *Workspace) {
+	st.workspace = workspace
+}
+
+// Sources returns the currently cited results
+func (st *SourceTracker) Sources() []SearchResult {
+	return st.results
+}
+
+// FormatSources renders a numbered list of cited chunks with their scores
+func (st *SourceTracker) FormatSources() string {
+	var builder strings.Builder
+	if st.workspace != nil {
+		builder.WriteString(formatWorkspaceStatus(st.workspace.Status()))
+	}
+	if len(st.results) == 0 {
+		builder.WriteString("No sources cited yet. Ask a question first.")
+		return builder.String()
+	}
+
+	builder.WriteString(fmt.Sprintf("Sources for %q:\n", st.query))
+	for i, result := range st.results {
+		// Reranked sources show their relevance, then the similarity they
+		// were retrieved with
+		relevance := ""
+		if result.Relevance > 0 {
+			relevance = fmt.Sprintf("relevance %.3f, ", result.Relevance)
+		}
+		builder.WriteString(fmt.Sprintf("  [%d] %s%.3f  %s  %s%s\n",
+			i+1,
+			relevance,
+			result.Similarity,
+			sourceLocation(result.Embedding),
+			truncate(result.Embedding.Text, 60),
+			st.staleness(result.Embedding)))
+	}
+
+	return builder.String()
+}
+
+// staleness marks a source whose workspace file changed after it was indexed
+func (st *SourceTracker) staleness(embedding Embedding) string {
+	path, _ := embedding.Metadata["path"].(string)
+	if st.workspace == nil || path == "" {
+		return ""
+	}
+	pending, ok := st.workspace.Staleness(path)
+	switch {
+	case !ok:
+		return ""
+	case pending.Deleted:
+		return "  ⚠️ stale: file deleted"
+	default:
+		return fmt.Sprintf("  ⚠️ stale: file changed %s ago", time.Since(pending.Since).Round(time.Second))
+	}
+}
+
+// formatWorkspaceStatus summarizes the workspace index and its pending changes
+func formatWorkspaceStatus(status WorkspaceStatus) string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("🗂️  Workspace %s: %d files, %d chunks", status.Root, status.Files, status.Chunks))
+	if len(status.Pending) == 0 {
+		builder.WriteString(", up to date\n")
+	} else {
+		builder.WriteString(fmt.Sprintf(", %d change(s) pending reindex (oldest %s ago)\n",
+			len(status.Pending), time.Since(status.Pending[0].Since).Round(time.Second)))
+	}
+	if status.LastError != "" {
+		builder.WriteString(fmt.Sprintf("  Last error: %s\n", status.LastError))
+	}
+	return builder.String()
+}
+
+// Get returns the n-th cited source (1-based, as shown by FormatSources)
+func (st *SourceTracker) Get(n int) (*SearchResult, error) {
+	if n < 1 || n > len(st.results) {
+		return nil, fmt.Errorf("source %d not found (have %d sources)", n, len(st.results))
+	}
+	return &st.results[n-1], nil
+}
+
+// Open prints the full text of the n-th source. If the chunk metadata points
+// at a file and $EDITOR is set, the file is opened at the chunk's line instead.
+func (st *SourceTracker) Open(n int) error {
+	result, err := st.Get(n)
+	if err != nil {
+		return err
+	}
+
+	path, _ := result.Embedding.Metadata["path"].(string)
+	editor := os.Getenv("EDITOR")
+	if path != "" && editor != "" {
+		return openInEditor(editor, path, metadataInt(result.Embedding.Metadata, "line"))
+	}
+
+	fmt.Printf("📄 [%d] %s (similarity %.3f)\n", n, sourceLocation(result.Embedding), result.Similarity)
+	fmt.Println(strings.Repeat("-", 50))
+	fmt.Println(result.Embedding.Text)
+	return nil
+}
+
+// sourceLocation describes where a chunk came from (file:line, source or ID)
+func sourceLocation(embedding Embedding) string {
+	if path, ok := embedding.Metadata["path"].(string); ok && path != "" {
+		if line := metadataInt(embedding.Metadata, "line"); line > 0 {
+			return fmt.Sprintf("%s:%d", path, line)
+		}
+		return path
+	}
+	if source, ok := embedding.Metadata["source"].(string); ok && source != "" {
+		return fmt.Sprintf("%s (%s)", embedding.ID, source)
+	}
+	return embedding.ID
+}
+
+// metadataInt reads an integer metadata value that may have been decoded as
+// int or float64 (JSON)
+func metadataInt(metadata map[string]interface{}, key string) int {
+	switch v := metadata[key].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	default:
+		return 0
+	}
+}
+
+// openInEditor launches the user's editor at the given line
+func openInEditor(editor, path string, line int) error {
+	args := []string{path}
+	if line > 0 {
+		// +N is understood by vi, vim, nano, emacs and most terminal editors
+		args = []string{fmt.Sprintf("+%d", line), path}
+	}
+
+	cmd := exec.Command(editor, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to open %s in %s: %w", path, editor, err)
+	}
+	return nil
+}
+
+// truncate shortens text to max characters for single-line display
+func truncate(text string, max int) string {
+	text = strings.ReplaceAll(text, "\n", " ")
+	if len(text) <= max {
+		return text
+	}
+	return text[:max-3] + "..."
+}
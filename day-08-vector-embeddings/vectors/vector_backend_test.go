@@ -0,0 +1,154 @@
+package vectors
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"testing"
+)
+
+// mapBackend is a VectorBackend over a map, ranking by CosineSimilarity
+type mapBackend struct {
+	embeddings map[string]Embedding
+	queries    []Filter
+}
+
+func (b *mapBackend) Upsert(ctx context.Context, embeddings []Embedding) error {
+	for _, embedding := range embeddings {
+		b.embeddings[embedding.ID] = embedding
+	}
+	return nil
+}
+
+func (b *mapBackend) Delete(ctx context.Context, ids []string) error {
+	for _, id := range ids {
+		delete(b.embeddings, id)
+	}
+	return nil
+}
+
+func (b *mapBackend) Get(ctx context.Context, id string) (*Embedding, error) {
+	if embedding, ok := b.embeddings[id]; ok {
+		return &embedding, nil
+	}
+	return nil, nil
+}
+
+func (b *mapBackend) Query(ctx context.Context, vector []float64, topK int, filter Filter) ([]SearchResult, error) {
+	b.queries = append(b.queries, filter)
+	var results []SearchResult
+	for _, embedding := range b.embeddings {
+		if filter.Matches(embedding.Metadata) {
+			results = append(results, SearchResult{Embedding: embedding, Similarity: CosineSimilarity(vector, embedding.Vector)})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Similarity > results[j].Similarity })
+	if len(results) > topK {
+		results = results[:topK]
+	}
+	return results, nil
+}
+
+func (b *mapBackend) Count(ctx context.Context) (int, error) {
+	return len(b.embeddings), nil
+}
+
+func TestVectorBackend(t *testing.T) {
+	ctx := context.Background()
+	store := newEmbeddingServer(t)
+	store.SetEmbeddingModel(EmbeddingModel{Name: "local"})
+	if err := store.AddDocument(ctx, "early", "in memory first", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	backend := &mapBackend{embeddings: make(map[string]Embedding)}
+	if err := store.UseBackend(ctx, backend); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := backend.embeddings["early"]; !ok || len(store.embeddings) != 0 {
+		t.Fatal("Expected the in-memory documents moved to the backend")
+	}
+	if _, err := store.AddDocuments(ctx, []Document{
+		{ID: "a", Text: "alpha", Metadata: map[string]interface{}{"lang": "go"}},
+		{ID: "b", Text: "beta beta", Metadata: map[string]interface{}{"lang": "rust"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if store.GetDocumentCount() != 3 {
+		t.Errorf("Expected 3 documents in the backend, got %d", store.GetDocumentCount())
+	}
+
+	results, err := store.SearchWith(ctx, "alpha", SearchOptions{TopK: 5, Filter: Filter{Eq("lang", "go")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Embedding.ID != "a" || results[0].Score != results[0].Similarity || len(backend.queries) != 1 {
+		t.Errorf("Expected the filter passed to the backend, got %+v", results)
+	}
+
+	if err := store.UpdateDocument(ctx, "a", "alpha two", map[string]interface{}{"lang": "go"}); err != nil {
+		t.Fatal(err)
+	}
+	if doc, err := store.GetDocument("a"); err != nil || doc.Text != "alpha two" {
+		t.Errorf("Expected the update stored, got %+v, %v", doc, err)
+	}
+	if err := store.DeleteDocument("b"); err != nil || store.GetDocumentCount() != 2 {
+		t.Errorf("Expected b deleted, got %v with %d left", err, store.GetDocumentCount())
+	}
+	if err := store.DeleteDocument("missing"); err == nil {
+		t.Error("Expected deleting a missing document to fail")
+	}
+
+	if _, err := store.SearchWith(ctx, "alpha", SearchOptions{TopK: 1, Mode: HybridSearch}); !errors.Is(err, errNeedsMemory) {
+		t.Errorf("Expected hybrid search refused, got %v", err)
+	}
+	if _, err := store.Migrate(ctx, Small3, DefaultBatchConfig(), nil); !errors.Is(err, errNeedsMemory) {
+		t.Errorf("Expected migration refused, got %v", err)
+	}
+	backend.embeddings["stray"] = Embedding{ID: "stray", Vector: []float64{1, 0, 0, 1}, Model: "other"}
+	if _, err := store.Search(ctx, "x", 5); !errors.Is(err, ErrMixedEmbeddings) {
+		t.Errorf("Expected a result from another model refused, got %v", err)
+	}
+}
+
+func TestBackendFilters(t *testing.T) {
+	filter, err := ParseFilter("lang in (go, 2) and year>=2020 and year<2024")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qdrant, _ := json.Marshal(qdrantFilter(filter))
+	wantQdrant := `{"must":[{"should":[{"key":"metadata.lang","match":{"value":"go"}},{"key":"metadata.lang","match":{"value":2}}]},{"key":"metadata.year","range":{"gte":2020,"lt":2024}}]}`
+	if string(qdrant) != wantQdrant {
+		t.Errorf("Qdrant filter:\n%s\nexpected:\n%s", qdrant, wantQdrant)
+	}
+
+	pinecone, _ := json.Marshal(pineconeFilter(filter))
+	wantPinecone := `{"$and":[{"$or":[{"lang":{"$eq":"go"}},{"lang":{"$eq":2}}]},{"year":{"$gte":2020,"$lt":2024}}]}`
+	if string(pinecone) != wantPinecone {
+		t.Errorf("Pinecone filter:\n%s\nexpected:\n%s", pinecone, wantPinecone)
+	}
+
+	where, args := pgvectorWhere(filter, 3)
+	wantWhere := " WHERE metadata->>$3::text IN ($4, $5) AND (jsonb_typeof(metadata->$6::text) = 'number' AND (metadata->>$6::text)::float8 >= $7 AND (metadata->>$6::text)::float8 < $8)"
+	wantArgs := []interface{}{"lang", "go", "2", "year", 2020.0, 2024.0}
+	if where != wantWhere || !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("pgvector clause:\n%s %v\nexpected:\n%s %v", where, args, wantWhere, wantArgs)
+	}
+	if where, _ := pgvectorWhere(Filter{Range("score", 0.5, math.Inf(1))}, 1); where != " WHERE (jsonb_typeof(metadata->$1::text) = 'number' AND (metadata->>$1::text)::float8 >= $2)" {
+		t.Errorf("Unexpected open range clause %s", where)
+	}
+
+	if vector, err := parseVectorLiteral(vectorLiteral([]float64{0.5, -1, 2})); err != nil || fmt.Sprint(vector) != "[0.5 -1 2]" {
+		t.Errorf("Expected vectors to survive pgvector's text form, got %v, %v", vector, err)
+	}
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	if id := pointID("handbook.md#3"); !uuid.MatchString(id) || id != pointID("handbook.md#3") || id == pointID("handbook.md#4") {
+		t.Errorf("Expected a stable version 5 UUID per document, got %s", id)
+	}
+}
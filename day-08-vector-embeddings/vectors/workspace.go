@@ -0,0 +1,413 @@
+package vectors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sakibmulla/agentic-ai/day-08-vector-embeddings/chunker"
+)
+
+// WorkspaceConfig controls how a workspace is watched and chunked
+type WorkspaceConfig struct {
+	Root string
+	// PollInterval is how often Watch checks files for changes
+	PollInterval time.Duration
+	// Debounce is how long a file must stay unchanged before it is
+	// reindexed, so a burst of saves costs one re-embedding
+	Debounce time.Duration
+	// ChunkTokens is the chunk size for both code and prose
+	ChunkTokens int
+	// MaxFileBytes skips larger files (generated code, data dumps)
+	MaxFileBytes int64
+}
+
+// DefaultWorkspaceConfig watches root every second with a 2 second debounce
+func DefaultWorkspaceConfig(root string) WorkspaceConfig {
+	return WorkspaceConfig{
+		Root:         root,
+		PollInterval: time.Second,
+		Debounce:     2 * time.Second,
+		ChunkTokens:  400,
+		MaxFileBytes: 1 << 20,
+	}
+}
+
+// proseExtensions are indexed with the document chunker; code files with a
+// known language use the CodeChunker
+var proseExtensions = map[string]bool{".md": true, ".markdown": true, ".txt": true}
+
+// skippedDirs are never walked
+var skippedDirs = map[string]bool{".git": true, "vendor": true, "node_modules": true}
+
+// fileStamp identifies a version of a file without reading it
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// indexedFile is a file whose chunks are in the store
+type indexedFile struct {
+	stamp     fileStamp
+	chunkIDs  []string
+	indexedAt time.Time
+}
+
+// fileChange is a file that differs from its indexed version
+type fileChange struct {
+	stamp     fileStamp
+	deleted   bool
+	firstSeen time.Time
+	lastSeen  time.Time
+}
+
+// PendingFile is a changed file waiting to be reindexed
+type PendingFile struct {
+	Path    string
+	Deleted bool
+	// Since is when the change was first noticed
+	Since time.Time
+}
+
+// WorkspaceStatus describes how fresh the workspace index is
+type WorkspaceStatus struct {
+	Root     string
+	Files    int
+	Chunks   int
+	Pending  []PendingFile
+	LastSync time.Time
+	// LastError is the most recent reindexing failure, if any
+	LastError string
+}
+
+// SyncReport summarizes one Sync
+type SyncReport struct {
+	Reindexed int
+	Removed   int
+	// Embedded chunks were new or changed; Reused chunks kept their vector
+	Embedded int
+	Reused   int
+}
+
+// Workspace keeps a vector index of a directory's code and documents fresh.
+// Watch notices changed files in the background; Sync re-chunks them and
+// re-embeds only the chunks whose text changed.
+type Workspace struct {
+	store   *VectorStore
+	config  WorkspaceConfig
+	code    *CodeChunker
+	indexed map[string]*indexedFile
+	changes map[string]*fileChange
+	chunks  int
+
+	lastSync  time.Time
+	lastError string
+	now       func() time.Time
+	mu        sync.Mutex
+}
+
+// NewWorkspace creates an empty index of config.Root in store; call Index to fill it
+func NewWorkspace(store *VectorStore, config WorkspaceConfig) *Workspace {
+	return &Workspace{
+		store:   store,
+		config:  config,
+		code:    NewCodeChunker(config.ChunkTokens),
+		indexed: make(map[string]*indexedFile),
+		changes: make(map[string]*fileChange),
+		now:     time.Now,
+	}
+}
+
+// Index scans the workspace and indexes every new or changed file right
+// away, without waiting for the debounce
+func (w *Workspace) Index(ctx context.Context) (*SyncReport, error) {
+	if err := w.Scan(); err != nil {
+		return nil, err
+	}
+	return w.sync(ctx, true)
+}
+
+// Watch scans the workspace every PollInterval until ctx is done. It only
+// records changes; the store is updated by Sync.
+func (w *Workspace) Watch(ctx context.Context) {
+	ticker := time.NewTicker(w.config.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.Scan(); err != nil {
+				w.mu.Lock()
+				w.lastError = err.Error()
+				w.mu.Unlock()
+			}
+		}
+	}
+}
+
+// Scan compares the files on disk with the index and records what changed.
+// A file that changes again restarts its debounce; one that changes back
+// is no longer pending.
+func (w *Workspace) Scan() error {
+	stamps := make(map[string]fileStamp)
+	err := filepath.WalkDir(w.config.Root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path != w.config.Root && (skippedDirs[entry.Name()] || strings.HasPrefix(entry.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !w.indexable(path) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if info.Size() <= w.config.MaxFileBytes {
+			stamps[path] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan workspace: %w", err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	now := w.now()
+	observe := func(path string, stamp fileStamp, deleted bool) {
+		if file, ok := w.indexed[path]; ok && !deleted && file.stamp == stamp {
+			delete(w.changes, path)
+			return
+		}
+		change, ok := w.changes[path]
+		if !ok {
+			w.changes[path] = &fileChange{stamp: stamp, deleted: deleted, firstSeen: now, lastSeen: now}
+			return
+		}
+		if change.stamp != stamp || change.deleted != deleted {
+			change.stamp, change.deleted, change.lastSeen = stamp, deleted, now
+		}
+	}
+
+	for path, stamp := range stamps {
+		observe(path, stamp, false)
+	}
+	for path := range w.indexed {
+		if _, ok := stamps[path]; !ok {
+			observe(path, fileStamp{}, true)
+		}
+	}
+	// A new file removed before it was indexed is forgotten
+	for path := range w.changes {
+		_, onDisk := stamps[path]
+		_, indexed := w.indexed[path]
+		if !onDisk && !indexed {
+			delete(w.changes, path)
+		}
+	}
+	return nil
+}
+
+// Sync reindexes the changed files whose debounce has passed. The
+// VectorStore is not safe for concurrent use, so call this from the
+// goroutine that owns the store.
+func (w *Workspace) Sync(ctx context.Context) (*SyncReport, error) {
+	return w.sync(ctx, false)
+}
+
+func (w *Workspace) sync(ctx context.Context, force bool) (*SyncReport, error) {
+	w.mu.Lock()
+	var ready []string
+	now := w.now()
+	for path, change := range w.changes {
+		if force || now.Sub(change.lastSeen) >= w.config.Debounce {
+			ready = append(ready, path)
+		}
+	}
+	w.mu.Unlock()
+	sort.Strings(ready)
+
+	// A file that fails (e.g. Go code that doesn't parse mid-edit) stays
+	// pending without holding up the others
+	report := &SyncReport{}
+	var errs []error
+	for _, path := range ready {
+		if err := w.reindex(ctx, path, report); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lastSync = w.now()
+	w.lastError = ""
+	if len(errs) > 0 {
+		w.lastError = errs[len(errs)-1].Error()
+	}
+	return report, errors.Join(errs...)
+}
+
+// reindex replaces a file's chunks with its current ones. Chunks whose text
+// is unchanged keep their vector even if their ID moved (e.g. code shifted
+// down by a new function above it).
+func (w *Workspace) reindex(ctx context.Context, path string, report *SyncReport) error {
+	w.mu.Lock()
+	previous := w.indexed[path]
+	w.mu.Unlock()
+
+	info, statErr := os.Stat(path)
+	var chunks []Chunk
+	if statErr == nil {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if chunks, err = w.chunk(path, src); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(statErr) {
+		return fmt.Errorf("failed to stat %s: %w", path, statErr)
+	}
+
+	// Vectors of the previous chunks, by text
+	vectors := make(map[string][]float64)
+	if previous != nil {
+		for _, id := range previous.chunkIDs {
+			if doc, err := w.store.GetDocument(id); err == nil && w.store.model.matches(*doc) {
+				vectors[doc.Text] = doc.Vector
+			}
+			_ = w.store.DeleteDocument(id)
+		}
+	}
+
+	var fresh []Document
+	ids := make([]string, 0, len(chunks))
+	for _, chunk := range chunks {
+		ids = append(ids, chunk.ID)
+		if vector, ok := vectors[chunk.Text]; ok {
+			if err := w.store.put(ctx, w.store.newEmbedding(chunk.ID, chunk.Text, vector, chunk.Metadata)); err != nil {
+				return fmt.Errorf("failed to store %s: %w", chunk.ID, err)
+			}
+			report.Reused++
+			continue
+		}
+		fresh = append(fresh, Document{ID: chunk.ID, Text: chunk.Text, Metadata: chunk.Metadata})
+	}
+	var embedErr error
+	if len(fresh) > 0 {
+		added, err := w.store.AddDocuments(ctx, fresh)
+		if added != nil {
+			report.Embedded += added.Added
+			failed := make(map[string]bool, len(added.Failed))
+			for _, f := range added.Failed {
+				failed[f.ID] = true
+			}
+			stored := ids[:0]
+			for _, id := range ids {
+				if !failed[id] {
+					stored = append(stored, id)
+				}
+			}
+			ids = stored
+		}
+		if err != nil {
+			embedErr = fmt.Errorf("failed to reindex %s: %w", path, err)
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if previous != nil {
+		w.chunks -= len(previous.chunkIDs)
+	}
+	if statErr != nil {
+		delete(w.indexed, path)
+		report.Removed++
+	} else {
+		stamp := fileStamp{modTime: info.ModTime(), size: info.Size()}
+		if embedErr != nil {
+			// Keep what was stored, but leave the file pending so it is retried
+			stamp = fileStamp{}
+		}
+		w.indexed[path] = &indexedFile{stamp: stamp, chunkIDs: ids, indexedAt: w.now()}
+		w.chunks += len(ids)
+		report.Reindexed++
+	}
+	// A change seen after the file was read stays pending
+	if change, ok := w.changes[path]; ok && embedErr == nil {
+		if file, indexed := w.indexed[path]; (indexed && file.stamp == change.stamp) || (!indexed && change.deleted) {
+			delete(w.changes, path)
+		}
+	}
+	return embedErr
+}
+
+// chunk splits a file with the chunker suited to it
+func (w *Workspace) chunk(path string, src []byte) ([]Chunk, error) {
+	if !proseExtensions[strings.ToLower(filepath.Ext(path))] {
+		return w.code.ChunkFile(path, src)
+	}
+
+	config := chunker.Config{Size: w.config.ChunkTokens, Overlap: w.config.ChunkTokens / 8, Format: chunker.DetectFormat(path, string(src))}
+	var chunks []Chunk
+	for _, c := range chunker.Split(string(src), config) {
+		metadata := map[string]interface{}{"path": path, "document": path, "chunk": c.Index + 1, "format": string(config.Format)}
+		if c.Heading != "" {
+			metadata["heading"] = c.Heading
+		}
+		chunks = append(chunks, Chunk{ID: fmt.Sprintf("%s#%d", path, c.Index+1), Text: c.Text, Metadata: metadata})
+	}
+	return chunks, nil
+}
+
+// indexable reports whether a file is code or prose the workspace indexes
+func (w *Workspace) indexable(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	_, code := boundaryPatterns[ext]
+	return ext == ".go" || code || proseExtensions[ext]
+}
+
+// Staleness reports whether path has changed since it was indexed, and for
+// how long. Files outside the workspace are never stale.
+func (w *Workspace) Staleness(path string) (PendingFile, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	change, ok := w.changes[path]
+	if !ok {
+		return PendingFile{}, false
+	}
+	return PendingFile{Path: path, Deleted: change.deleted, Since: change.firstSeen}, true
+}
+
+// Status returns the index size and the files waiting to be reindexed, oldest first
+func (w *Workspace) Status() WorkspaceStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	status := WorkspaceStatus{
+		Root:      w.config.Root,
+		Files:     len(w.indexed),
+		Chunks:    w.chunks,
+		LastSync:  w.lastSync,
+		LastError: w.lastError,
+	}
+	for path, change := range w.changes {
+		status.Pending = append(status.Pending, PendingFile{Path: path, Deleted: change.deleted, Since: change.firstSeen})
+	}
+	sort.Slice(status.Pending, func(i, j int) bool {
+		return status.Pending[i].Since.Before(status.Pending[j].Since)
+	})
+	return status
+}
@@ -0,0 +1,141 @@
+package vectors
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeFile writes a workspace file with its modification time shifted, so
+// a rewrite within the filesystem's clock resolution is still seen
+func writeFile(t *testing.T, path, text string, shift time.Duration) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stamp := time.Now().Add(shift)
+	if err := os.Chtimes(path, stamp, stamp); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWorkspacesStaySeparate(t *testing.T) {
+	store := newEmbeddingServer(t)
+	store.SetEmbeddingModel(EmbeddingModel{Name: "local"})
+	ctx := context.Background()
+
+	rootA, rootB := t.TempDir(), t.TempDir()
+	notesA, notesB := filepath.Join(rootA, "notes.md"), filepath.Join(rootB, "notes.md")
+	codeB := filepath.Join(rootB, "pkg", "add.go")
+	writeFile(t, notesA, "# A\nNotes for the first workspace.", -time.Hour)
+	writeFile(t, notesB, "# B\nNotes for the second workspace.", -time.Hour)
+	writeFile(t, codeB, "package pkg\n\nfunc Add(a, b int) int {\n\treturn a + b\n}", -time.Hour)
+	// Hidden and skipped directories aren't indexed
+	writeFile(t, filepath.Join(rootB, ".git", "HEAD.md"), "ignored", -time.Hour)
+	writeFile(t, filepath.Join(rootB, "vendor", "dep.go"), "package dep", -time.Hour)
+
+	a := NewWorkspace(store, DefaultWorkspaceConfig(rootA))
+	b := NewWorkspace(store, DefaultWorkspaceConfig(rootB))
+	for _, w := range []*Workspace{a, b} {
+		if _, err := w.Index(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if status := a.Status(); status.Files != 1 || status.Chunks != 1 || len(status.Pending) != 0 {
+		t.Errorf("Expected only A's file indexed in A, got %+v", status)
+	}
+	if status := b.Status(); status.Files != 2 || status.Chunks != 2 || len(status.Pending) != 0 {
+		t.Errorf("Expected only B's files indexed in B, got %+v", status)
+	}
+	if store.GetDocumentCount() != 3 {
+		t.Fatalf("Expected both workspaces' chunks in the store, got %d", store.GetDocumentCount())
+	}
+
+	// Changing and deleting A's files touches neither B's chunks nor its status
+	writeFile(t, notesA, "# A\nRewritten notes for the first workspace.", 0)
+	if err := a.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	if _, stale := a.Staleness(notesA); !stale {
+		t.Error("Expected A's rewritten notes stale in A")
+	}
+	if _, stale := b.Staleness(notesA); stale {
+		t.Error("Expected A's file never stale in B")
+	}
+	if err := os.Remove(notesA); err != nil {
+		t.Fatal(err)
+	}
+	report, err := a.Index(ctx)
+	if err != nil || report.Removed != 1 {
+		t.Fatalf("Expected A's notes removed, got %+v %v", report, err)
+	}
+	if err := b.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	if status := b.Status(); status.Chunks != 2 || len(status.Pending) != 0 {
+		t.Errorf("Expected B unchanged, got %+v", status)
+	}
+	if store.GetDocumentCount() != 2 {
+		t.Errorf("Expected only A's chunk dropped, got %d documents", store.GetDocumentCount())
+	}
+	for _, id := range []string{notesB + "#1", codeB + ":1"} {
+		if _, err := store.GetDocument(id); err != nil {
+			t.Errorf("Expected B's chunk %s kept: %v", id, err)
+		}
+	}
+}
+
+func TestWorkspaceDebounce(t *testing.T) {
+	store := newEmbeddingServer(t)
+	store.SetEmbeddingModel(EmbeddingModel{Name: "local"})
+	ctx := context.Background()
+
+	root := t.TempDir()
+	path := filepath.Join(root, "notes.md")
+	writeFile(t, path, "First paragraph.\n\nSecond paragraph.", -time.Hour)
+
+	config := DefaultWorkspaceConfig(root)
+	config.ChunkTokens = 8
+	w := NewWorkspace(store, config)
+	clock := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	w.now = func() time.Time { return clock }
+	if _, err := w.Index(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, path, "First paragraph.\n\nSecond paragraph, edited.", 0)
+	if err := w.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	clock = clock.Add(config.Debounce / 2)
+	if report, err := w.Sync(ctx); err != nil || report.Reindexed != 0 {
+		t.Errorf("Expected nothing reindexed within the debounce, got %+v %v", report, err)
+	}
+
+	// Another save restarts the debounce, but the change keeps its first sighting
+	writeFile(t, path, "First paragraph.\n\nSecond paragraph, edited again.", time.Minute)
+	if err := w.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	clock = clock.Add(config.Debounce / 2)
+	if report, _ := w.Sync(ctx); report.Reindexed != 0 {
+		t.Error("Expected a second save to restart the debounce")
+	}
+	if pending, ok := w.Staleness(path); !ok || !pending.Since.Equal(clock.Add(-config.Debounce)) {
+		t.Errorf("Expected the file stale since its first change, got %+v", pending)
+	}
+
+	clock = clock.Add(config.Debounce)
+	report, err := w.Sync(ctx)
+	if err != nil || report.Reindexed != 1 || report.Reused != 1 || report.Embedded != 1 {
+		t.Errorf("Expected one chunk reused and the edited one embedded, got %+v %v", report, err)
+	}
+	if _, stale := w.Staleness(path); stale {
+		t.Error("Expected the file fresh after Sync")
+	}
+}
@@ -0,0 +1,223 @@
+// Package endpoint points the course's OpenAI clients at the API they talk
+// to: OpenAI itself, Azure OpenAI, an OpenAI-compatible server such as
+// vLLM or LM Studio, or a local Ollama.
+//
+//	OPENAI_BASE_URL=http://localhost:1234/v1        # LM Studio
+//
+//	OPENAI_API_TYPE=azure
+//	OPENAI_BASE_URL=https://my-resource.openai.azure.com
+//	OPENAI_API_VERSION=2024-02-01
+//	OPENAI_DEPLOYMENT=gpt-35-turbo=chat,text-embedding-ada-002=embed
+//
+//	LLM_PROVIDER=ollama                             # offline, no API key
+//	OLLAMA_MODEL=llama3.1
+//	OLLAMA_EMBED_MODEL=nomic-embed-text
+package endpoint
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// API types
+const (
+	TypeOpenAI = "openai"
+	TypeAzure  = "azure"
+	TypeOllama = "ollama"
+)
+
+// DefaultAzureAPIVersion is the Azure api-version used when none is set
+const DefaultAzureAPIVersion = "2024-02-01"
+
+// Endpoint says where API calls go. The zero value is api.openai.com.
+type Endpoint struct {
+	// Type is TypeOpenAI, TypeAzure or TypeOllama; empty means TypeOpenAI
+	Type string
+	// BaseURL replaces https://api.openai.com/v1, e.g.
+	// http://localhost:8000/v1 for vLLM. For Azure it is the resource's
+	// endpoint, https://<resource>.openai.azure.com.
+	BaseURL string
+	// APIVersion is Azure's api-version
+	APIVersion string
+	// Deployments maps model names to Azure deployment names. The "" entry
+	// serves every model not listed; without one, a model's deployment is
+	// its name without dots, as Azure's defaults are named.
+	Deployments map[string]string
+
+	// ChatModel and EmbedModel are the Ollama models that serve chat and
+	// embedding requests, whatever OpenAI model the code asks for
+	ChatModel  string
+	EmbedModel string
+	// Timeout bounds each call to Ollama, including loading the model on
+	// the first one
+	Timeout time.Duration
+}
+
+// FromEnv reads OPENAI_API_TYPE, OPENAI_BASE_URL, OPENAI_API_VERSION and
+// OPENAI_DEPLOYMENT, or with LLM_PROVIDER=ollama, the Ollama settings (see
+// OllamaFromEnv)
+func FromEnv() (Endpoint, error) {
+	switch provider := os.Getenv("LLM_PROVIDER"); provider {
+	case "", TypeOpenAI:
+	case TypeOllama:
+		return OllamaFromEnv()
+	default:
+		return Endpoint{}, fmt.Errorf("unknown LLM_PROVIDER %q: use %s or %s", provider, TypeOpenAI, TypeOllama)
+	}
+	return Parse(os.Getenv("OPENAI_API_TYPE"), os.Getenv("OPENAI_BASE_URL"),
+		os.Getenv("OPENAI_API_VERSION"), os.Getenv("OPENAI_DEPLOYMENT"))
+}
+
+// Parse checks an endpoint's settings. deployment is a single deployment
+// name for every model, or a comma-separated list of model=deployment.
+func Parse(apiType, baseURL, apiVersion, deployment string) (Endpoint, error) {
+	e := Endpoint{Type: strings.ToLower(strings.TrimSpace(apiType)), BaseURL: strings.TrimSpace(baseURL), APIVersion: strings.TrimSpace(apiVersion)}
+	switch e.Type {
+	case "", TypeOpenAI:
+		e.Type = TypeOpenAI
+		if e.APIVersion != "" || deployment != "" {
+			return e, fmt.Errorf("OPENAI_API_VERSION and OPENAI_DEPLOYMENT only apply to Azure: set OPENAI_API_TYPE=azure")
+		}
+	case TypeAzure:
+		if e.BaseURL == "" {
+			return e, fmt.Errorf("OPENAI_API_TYPE=azure needs OPENAI_BASE_URL, e.g. https://<resource>.openai.azure.com")
+		}
+		if e.APIVersion == "" {
+			e.APIVersion = DefaultAzureAPIVersion
+		}
+	default:
+		return e, fmt.Errorf("unknown OPENAI_API_TYPE %q: use %s or %s", apiType, TypeOpenAI, TypeAzure)
+	}
+
+	if e.BaseURL != "" {
+		u, err := url.Parse(e.BaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return e, fmt.Errorf("invalid OPENAI_BASE_URL %q: expected http(s)://host[/path]", baseURL)
+		}
+		e.BaseURL = strings.TrimSuffix(e.BaseURL, "/")
+	}
+
+	deployments, err := parseDeployments(deployment)
+	if err != nil {
+		return e, err
+	}
+	e.Deployments = deployments
+	return e, nil
+}
+
+// parseDeployments reads "name" or "model=name,model=name"
+func parseDeployments(text string) (map[string]string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, nil
+	}
+	if !strings.Contains(text, "=") {
+		return map[string]string{"": text}, nil
+	}
+	deployments := make(map[string]string)
+	for _, pair := range strings.Split(text, ",") {
+		model, name, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		model, name = strings.TrimSpace(model), strings.TrimSpace(name)
+		if !ok || model == "" || name == "" {
+			return nil, fmt.Errorf("invalid OPENAI_DEPLOYMENT entry %q: expected model=deployment", pair)
+		}
+		deployments[model] = name
+	}
+	return deployments, nil
+}
+
+// IsAzure reports whether calls go to Azure OpenAI
+func (e Endpoint) IsAzure() bool {
+	return e.Type == TypeAzure
+}
+
+// IsOllama reports whether calls go to Ollama
+func (e Endpoint) IsOllama() bool {
+	return e.Type == TypeOllama
+}
+
+// RequiresKey reports whether an API key is needed. Local OpenAI-compatible
+// servers usually accept any key, or none.
+func (e Endpoint) RequiresKey() bool {
+	return e.BaseURL == "" || e.IsAzure()
+}
+
+// Deployment returns the Azure deployment serving model
+func (e Endpoint) Deployment(model string) string {
+	if name, ok := e.Deployments[model]; ok {
+		return name
+	}
+	if name, ok := e.Deployments[""]; ok {
+		return name
+	}
+	return strings.NewReplacer(".", "", ":", "").Replace(model)
+}
+
+// Host names the server calls go to, for logs and diagnostics
+func (e Endpoint) Host() string {
+	if e.BaseURL == "" {
+		return "api.openai.com"
+	}
+	if u, err := url.Parse(e.BaseURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return e.BaseURL
+}
+
+// String describes the endpoint, e.g. "azure my-resource.openai.azure.com
+// (api-version 2024-02-01)"
+func (e Endpoint) String() string {
+	if e.IsOllama() {
+		return fmt.Sprintf("ollama %s (chat %s, embeddings %s)", e.Host(), e.ChatModel, e.EmbedModel)
+	}
+	if !e.IsAzure() {
+		return e.Host()
+	}
+	text := fmt.Sprintf("azure %s (api-version %s)", e.Host(), e.APIVersion)
+	if len(e.Deployments) > 0 {
+		models := make([]string, 0, len(e.Deployments))
+		for model := range e.Deployments {
+			models = append(models, model)
+		}
+		sort.Strings(models)
+		for i, model := range models {
+			if model == "" {
+				models[i] = e.Deployments[model]
+			} else {
+				models[i] = model + "=" + e.Deployments[model]
+			}
+		}
+		text += ", deployments " + strings.Join(models, ",")
+	}
+	return text
+}
+
+// Config returns an OpenAI client config for apiKey that calls the
+// endpoint. To send the calls through another HTTP client, such as a cost
+// limiter's, set HTTPClient to e.HTTPClient(client).
+func (e Endpoint) Config(apiKey string) openai.ClientConfig {
+	if e.IsOllama() {
+		config := openai.DefaultConfig(apiKey)
+		config.BaseURL = e.BaseURL + "/v1"
+		config.HTTPClient = e.HTTPClient(&http.Client{})
+		return config
+	}
+	if e.IsAzure() {
+		config := openai.DefaultAzureConfig(apiKey, e.BaseURL)
+		config.APIVersion = e.APIVersion
+		config.AzureModelMapperFunc = e.Deployment
+		return config
+	}
+	config := openai.DefaultConfig(apiKey)
+	if e.BaseURL != "" {
+		config.BaseURL = e.BaseURL
+	}
+	return config
+}
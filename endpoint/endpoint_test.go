@@ -0,0 +1,138 @@
+package endpoint
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestParse(t *testing.T) {
+	e, err := Parse("", "", "", "")
+	if err != nil || e.Type != TypeOpenAI || !e.RequiresKey() || e.String() != "api.openai.com" {
+		t.Errorf("Expected OpenAI by default, got %+v, %v", e, err)
+	}
+
+	e, err = Parse("Azure", "https://res.openai.azure.com/", "", "gpt-3.5-turbo=chat, text-embedding-ada-002=embed")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if e.BaseURL != "https://res.openai.azure.com" || e.APIVersion != DefaultAzureAPIVersion {
+		t.Errorf("Expected the trimmed URL and default version, got %+v", e)
+	}
+	for model, want := range map[string]string{"gpt-3.5-turbo": "chat", "text-embedding-ada-002": "embed", "gpt-4.1": "gpt-41"} {
+		if got := e.Deployment(model); got != want {
+			t.Errorf("Expected %s served by %s, got %s", model, want, got)
+		}
+	}
+	if e, _ := Parse("azure", "https://res.openai.azure.com", "", "everything"); e.Deployment("gpt-4o") != "everything" {
+		t.Errorf("Expected a single deployment to serve every model, got %s", e.Deployment("gpt-4o"))
+	}
+
+	if e, _ := Parse("", "http://localhost:1234/v1", "", ""); e.RequiresKey() || e.Host() != "localhost:1234" {
+		t.Errorf("Expected a local server to need no key, got %+v", e)
+	}
+
+	for _, bad := range [][4]string{
+		{"anthropic", "", "", ""},
+		{"azure", "", "", ""},
+		{"openai", "", "2024-02-01", ""},
+		{"", "localhost:8000", "", ""},
+		{"azure", "https://res.openai.azure.com", "", "gpt-4o="},
+	} {
+		if _, err := Parse(bad[0], bad[1], bad[2], bad[3]); err == nil {
+			t.Errorf("Expected %q rejected", bad)
+		}
+	}
+}
+
+func TestConfig(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, fmt.Sprintf("%s?%s key=%s auth=%s", r.URL.Path, r.URL.RawQuery, r.Header.Get("api-key"), r.Header.Get("Authorization")))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"hi"}}]}`)
+	}))
+	defer server.Close()
+
+	request := openai.ChatCompletionRequest{Model: "gpt-3.5-turbo", Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hello"}}}
+	for _, e := range []Endpoint{
+		{Type: TypeAzure, BaseURL: server.URL, APIVersion: "2024-02-01", Deployments: map[string]string{"gpt-3.5-turbo": "chat"}},
+		{BaseURL: server.URL + "/v1"},
+	} {
+		client := openai.NewClientWithConfig(e.Config("secret"))
+		if _, err := client.CreateChatCompletion(context.Background(), request); err != nil {
+			t.Fatalf("Chat through %s failed: %v", e, err)
+		}
+	}
+
+	want := []string{
+		"/openai/deployments/chat/chat/completions?api-version=2024-02-01 key=secret auth=",
+		"/v1/chat/completions? key= auth=Bearer secret",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected requests:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestOllama(t *testing.T) {
+	var models []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/api/tags" {
+			fmt.Fprint(w, `{"models":[{"name":"llama3.1:latest"},{"name":"qwen2:7b"}]}`)
+			return
+		}
+		var request struct{ Model string }
+		json.NewDecoder(r.Body).Decode(&request)
+		models = append(models, r.URL.Path+" "+request.Model)
+		if strings.HasSuffix(r.URL.Path, "/embeddings") {
+			fmt.Fprint(w, `{"data":[{"embedding":[0.5]}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"hi"}}]}`)
+	}))
+	defer server.Close()
+
+	e, err := Ollama(server.URL+"/", "", "", 0)
+	if err != nil {
+		t.Fatalf("Ollama failed: %v", err)
+	}
+	if e.RequiresKey() || e.ChatModel != DefaultOllamaModel || e.Timeout != DefaultOllamaTimeout {
+		t.Errorf("Expected defaults and no key, got %+v", e)
+	}
+
+	client := openai.NewClientWithConfig(e.Config(""))
+	ctx := context.Background()
+	if _, err := client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{Model: "gpt-4", Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hello"}}}); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if _, err := client.CreateEmbeddings(ctx, openai.EmbeddingRequest{Model: openai.AdaEmbeddingV2, Input: []string{"hello"}}); err != nil {
+		t.Fatalf("Embeddings failed: %v", err)
+	}
+	want := "/v1/chat/completions llama3.1\n/v1/embeddings nomic-embed-text"
+	if strings.Join(models, "\n") != want {
+		t.Errorf("Expected local models:\n%s\ngot:\n%s", want, strings.Join(models, "\n"))
+	}
+
+	if names, err := e.OllamaModels(ctx); err != nil || strings.Join(names, ",") != "llama3.1:latest,qwen2:7b" {
+		t.Errorf("Expected the pulled models, got %v, %v", names, err)
+	}
+	err = e.CheckModels(ctx)
+	if err == nil || !strings.Contains(err.Error(), "ollama pull nomic-embed-text") {
+		t.Errorf("Expected the missing embedding model reported, got %v", err)
+	}
+	e.EmbedModel = "qwen2:7b"
+	if err := e.CheckModels(ctx); err != nil {
+		t.Errorf("Expected pulled models accepted, got %v", err)
+	}
+
+	if _, err := Ollama("localhost:11434", "", "", 0); err == nil {
+		t.Error("Expected a URL without scheme rejected")
+	}
+}
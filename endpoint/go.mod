@@ -0,0 +1,5 @@
+module github.com/sakibmulla/agentic-ai/endpoint
+
+go 1.21
+
+require github.com/sashabaranov/go-openai v1.17.9
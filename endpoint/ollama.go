@@ -0,0 +1,200 @@
+package endpoint
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ollama defaults
+const (
+	DefaultOllamaURL        = "http://localhost:11434"
+	DefaultOllamaModel      = "llama3.1"
+	DefaultOllamaEmbedModel = "nomic-embed-text"
+	// DefaultOllamaTimeout leaves room for a model to load on first use,
+	// which can take minutes on a laptop
+	DefaultOllamaTimeout = 5 * time.Minute
+)
+
+// ollamaCheckTimeout bounds CheckModels, which only lists the models
+const ollamaCheckTimeout = 10 * time.Second
+
+// Ollama returns an endpoint for the Ollama server at baseURL, serving chat
+// with chatModel and embeddings with embedModel. Empty values take the
+// defaults. Calls go through Ollama's OpenAI-compatible API, so the course's
+// OpenAI clients work unchanged and need no API key.
+func Ollama(baseURL, chatModel, embedModel string, timeout time.Duration) (Endpoint, error) {
+	e := Endpoint{
+		Type:       TypeOllama,
+		BaseURL:    strings.TrimSuffix(strings.TrimSpace(baseURL), "/"),
+		ChatModel:  strings.TrimSpace(chatModel),
+		EmbedModel: strings.TrimSpace(embedModel),
+		Timeout:    timeout,
+	}
+	if e.BaseURL == "" {
+		e.BaseURL = DefaultOllamaURL
+	}
+	if u, err := url.Parse(e.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return e, fmt.Errorf("invalid OLLAMA_URL %q: expected e.g. %s", baseURL, DefaultOllamaURL)
+	}
+	if e.ChatModel == "" {
+		e.ChatModel = DefaultOllamaModel
+	}
+	if e.EmbedModel == "" {
+		e.EmbedModel = DefaultOllamaEmbedModel
+	}
+	if e.Timeout <= 0 {
+		e.Timeout = DefaultOllamaTimeout
+	}
+	return e, nil
+}
+
+// OllamaFromEnv reads OLLAMA_URL, OLLAMA_MODEL, OLLAMA_EMBED_MODEL and
+// OLLAMA_TIMEOUT_SECONDS
+func OllamaFromEnv() (Endpoint, error) {
+	var timeout time.Duration
+	if text := os.Getenv("OLLAMA_TIMEOUT_SECONDS"); text != "" {
+		seconds, err := strconv.Atoi(text)
+		if err != nil || seconds <= 0 {
+			return Endpoint{}, fmt.Errorf("OLLAMA_TIMEOUT_SECONDS must be a positive number of seconds, got %q", text)
+		}
+		timeout = time.Duration(seconds) * time.Second
+	}
+	return Ollama(os.Getenv("OLLAMA_URL"), os.Getenv("OLLAMA_MODEL"), os.Getenv("OLLAMA_EMBED_MODEL"), timeout)
+}
+
+// HTTPClient returns client for calls to the endpoint. For Ollama it
+// swaps the requested model for the local one before client sees the
+// request, so a cost limiter prices the local model, at nothing, and bounds
+// calls by the endpoint's Timeout. Other endpoints get client unchanged.
+func (e Endpoint) HTTPClient(client *http.Client) *http.Client {
+	if !e.IsOllama() {
+		return client
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	timeout := client.Timeout
+	if timeout == 0 || timeout > e.Timeout {
+		timeout = e.Timeout
+	}
+	return &http.Client{Transport: &localModels{endpoint: e, base: base}, Timeout: timeout}
+}
+
+// localModels rewrites the model of chat and embedding requests to the
+// endpoint's local ones
+type localModels struct {
+	endpoint Endpoint
+	base     http.RoundTripper
+}
+
+// RoundTrip sends req with the local model
+func (t *localModels) RoundTrip(req *http.Request) (*http.Response, error) {
+	model := ""
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/chat/completions"):
+		model = t.endpoint.ChatModel
+	case strings.HasSuffix(req.URL.Path, "/embeddings"):
+		model = t.endpoint.EmbedModel
+	}
+	if model == "" || req.Body == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err == nil {
+		fields["model"], _ = json.Marshal(model)
+		if rewritten, err := json.Marshal(fields); err == nil {
+			body = rewritten
+		}
+	}
+
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return t.base.RoundTrip(req)
+}
+
+// CheckModels confirms an Ollama server answers and has pulled the chat
+// and embedding models, so a missing model fails at startup with the
+// command that fixes it. It does nothing for other endpoints.
+func (e Endpoint) CheckModels(ctx context.Context) error {
+	if !e.IsOllama() {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, ollamaCheckTimeout)
+	defer cancel()
+
+	models, err := e.OllamaModels(ctx)
+	if err != nil {
+		return err
+	}
+	pulled := make(map[string]bool, len(models))
+	for _, model := range models {
+		pulled[model] = true
+	}
+
+	var missing []string
+	for _, model := range []string{e.ChatModel, e.EmbedModel} {
+		// A model without a tag is read as its latest tag
+		if !pulled[model] && !(!strings.Contains(model, ":") && pulled[model+":latest"]) {
+			missing = append(missing, model)
+		}
+	}
+	switch len(missing) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("ollama model %s is not pulled: run `ollama pull %s`", missing[0], missing[0])
+	default:
+		return fmt.Errorf("ollama models %s are not pulled: run `ollama pull %s`", strings.Join(missing, " and "), strings.Join(missing, "` and `ollama pull "))
+	}
+}
+
+// OllamaModels returns the names of the models pulled into the Ollama
+// server, e.g. llama3.1:latest
+func (e Endpoint) OllamaModels(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.BaseURL+"/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot reach Ollama at %s: %w (start it with `ollama serve` or set OLLAMA_URL)", e.BaseURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ollama at %s returned %s", e.BaseURL, resp.Status)
+	}
+
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, fmt.Errorf("failed to list Ollama models: %w", err)
+	}
+	names := make([]string, len(tags.Models))
+	for i, model := range tags.Models {
+		names[i] = model.Name
+	}
+	return names, nil
+}
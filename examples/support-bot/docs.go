@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sakibmulla/agentic-ai/day-08-vector-embeddings/chunker"
+	"github.com/sakibmulla/agentic-ai/persist"
+)
+
+// docExtensions are the files ingested from the docs directory
+var docExtensions = map[string]bool{".md": true, ".markdown": true, ".txt": true}
+
+// Passage is one embedded chunk of a document
+type Passage struct {
+	ID      string    `json:"id"`
+	Source  string    `json:"source"` // path relative to the docs directory
+	Heading string    `json:"heading,omitempty"`
+	Text    string    `json:"text"`
+	Vector  []float32 `json:"vector"`
+}
+
+// SearchResult is a passage and how similar it is to the query
+type SearchResult struct {
+	Passage
+	Score float64 `json:"score"`
+}
+
+// IngestReport counts what one pass over the docs directory changed
+type IngestReport struct {
+	Added     int           `json:"added"`
+	Updated   int           `json:"updated"`
+	Removed   int           `json:"removed"`
+	Unchanged int           `json:"unchanged"`
+	Passages  int           `json:"passages"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// IndexStats describes the index
+type IndexStats struct {
+	Model      string    `json:"model"`
+	Documents  int       `json:"documents"`
+	Passages   int       `json:"passages"`
+	IngestedAt time.Time `json:"ingested_at"`
+}
+
+// indexFile is how the index is saved between runs
+type indexFile struct {
+	Model      string            `json:"model"`
+	Hashes     map[string]string `json:"hashes"` // source -> content hash
+	Passages   []Passage         `json:"passages"`
+	IngestedAt time.Time         `json:"ingested_at"`
+}
+
+// indexFormat versions the saved index
+var indexFormat = persist.NewFormat("support-bot-index", 1)
+
+// DocIndex is an in-memory vector index over a docs directory. It is saved
+// after each ingest so restarts only embed the files that changed.
+type DocIndex struct {
+	embedder Embedder
+	path     string
+	config   chunker.Config
+	data     indexFile
+	mu       sync.RWMutex
+	// ingestMu serializes ingests, which embed outside mu
+	ingestMu sync.Mutex
+}
+
+// NewDocIndex loads the index saved at path, if any. A saved index built
+// with another embedding model is discarded.
+func NewDocIndex(embedder Embedder, path string, config chunker.Config) (*DocIndex, error) {
+	ix := &DocIndex{
+		embedder: embedder,
+		path:     path,
+		config:   config,
+		data:     indexFile{Model: embedder.Model(), Hashes: make(map[string]string)},
+	}
+	if path == "" {
+		return ix, nil
+	}
+
+	var saved indexFile
+	err := indexFormat.ReadFile(path, &saved)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to load index: %w", err)
+	case saved.Model == embedder.Model() && saved.Hashes != nil:
+		ix.data = saved
+	}
+	return ix, nil
+}
+
+// IngestDir brings the index up to date with the docs under dir: new and
+// changed files are chunked and embedded, and removed files are dropped
+func (ix *DocIndex) IngestDir(ctx context.Context, dir string) (*IngestReport, error) {
+	ix.ingestMu.Lock()
+	defer ix.ingestMu.Unlock()
+
+	started := time.Now()
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !docExtensions[strings.ToLower(filepath.Ext(path))] {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = string(content)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read docs: %w", err)
+	}
+
+	ix.mu.RLock()
+	hashes := ix.data.Hashes
+	ix.mu.RUnlock()
+
+	report := &IngestReport{}
+	fresh := make(map[string][]Passage)
+	newHashes := make(map[string]string, len(files))
+	for source, content := range files {
+		hash := contentHash(content)
+		newHashes[source] = hash
+		old, known := hashes[source]
+		switch {
+		case known && old == hash:
+			report.Unchanged++
+			continue
+		case known:
+			report.Updated++
+		default:
+			report.Added++
+		}
+
+		passages, err := ix.embedDocument(ctx, source, content)
+		if err != nil {
+			return nil, err
+		}
+		fresh[source] = passages
+	}
+	for source := range hashes {
+		if _, ok := files[source]; !ok {
+			report.Removed++
+		}
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	passages := make([]Passage, 0, len(ix.data.Passages))
+	for _, passage := range ix.data.Passages {
+		if _, ok := files[passage.Source]; ok && fresh[passage.Source] == nil {
+			passages = append(passages, passage)
+		}
+	}
+	for _, batch := range fresh {
+		passages = append(passages, batch...)
+	}
+	sort.Slice(passages, func(i, j int) bool { return passages[i].ID < passages[j].ID })
+
+	ix.data.Passages = passages
+	ix.data.Hashes = newHashes
+	ix.data.IngestedAt = time.Now()
+	report.Passages = len(passages)
+	report.Duration = time.Since(started)
+
+	if ix.path != "" {
+		if err := os.MkdirAll(filepath.Dir(ix.path), 0755); err != nil {
+			return nil, fmt.Errorf("failed to save index: %w", err)
+		}
+		if err := indexFormat.WriteFile(ix.path, ix.data, 0644); err != nil {
+			return nil, fmt.Errorf("failed to save index: %w", err)
+		}
+	}
+	return report, nil
+}
+
+// embedDocument chunks one document and embeds its chunks
+func (ix *DocIndex) embedDocument(ctx context.Context, source, content string) ([]Passage, error) {
+	config := ix.config
+	config.Format = chunker.DetectFormat(source, content)
+	chunks := chunker.Split(content, config)
+	if len(chunks) == 0 {
+		return []Passage{}, nil
+	}
+
+	texts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		// The heading gives a chunk cut from the middle of a section its topic
+		texts[i] = chunk.Text
+		if chunk.Heading != "" {
+			texts[i] = chunk.Heading + "\n\n" + chunk.Text
+		}
+	}
+	vectors, err := ix.embedder.Embed(ctx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed %s: %w", source, err)
+	}
+
+	passages := make([]Passage, len(chunks))
+	for i, chunk := range chunks {
+		passages[i] = Passage{
+			ID:      fmt.Sprintf("%s#%03d", source, chunk.Index),
+			Source:  source,
+			Heading: chunk.Heading,
+			Text:    chunk.Text,
+			Vector:  normalize(vectors[i]),
+		}
+	}
+	return passages, nil
+}
+
+// Search returns the k passages most similar to query
+func (ix *DocIndex) Search(ctx context.Context, query string, k int) ([]SearchResult, error) {
+	vectors, err := ix.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	q := normalize(vectors[0])
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	results := make([]SearchResult, 0, len(ix.data.Passages))
+	for _, passage := range ix.data.Passages {
+		results = append(results, SearchResult{Passage: passage, Score: dot(q, passage.Vector)})
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results, nil
+}
+
+// Stats describes the index
+func (ix *DocIndex) Stats() IndexStats {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	return IndexStats{
+		Model:      ix.data.Model,
+		Documents:  len(ix.data.Hashes),
+		Passages:   len(ix.data.Passages),
+		IngestedAt: ix.data.IngestedAt,
+	}
+}
+
+// contentHash identifies a version of a document
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalize scales v to unit length, so similarity is a dot product
+func normalize(v []float32) []float32 {
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	if norm == 0 {
+		return v
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = x * scale
+	}
+	return out
+}
+
+// dot returns the dot product of two vectors of the same length
+func dot(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// embedBatchSize is how many texts go into one embedding request
+const embedBatchSize = 64
+
+// Embedder turns texts into vectors. The provider behind it must match the
+// chat model's, so a local setup never calls out to OpenAI.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	// Model names the embedding model; vectors from different models can't
+	// be compared
+	Model() string
+}
+
+// openAIEmbedder embeds with OpenAI's embeddings API
+type openAIEmbedder struct {
+	client *openai.Client
+	model  openai.EmbeddingModel
+}
+
+// NewOpenAIEmbedder creates an embedder for an OpenAI embedding model
+func NewOpenAIEmbedder(client *openai.Client, model string) Embedder {
+	return &openAIEmbedder{client: client, model: openai.EmbeddingModel(model)}
+}
+
+func (e *openAIEmbedder) Model() string {
+	return string(e.model)
+}
+
+func (e *openAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += embedBatchSize {
+		end := min(start+embedBatchSize, len(texts))
+		resp, err := e.client.CreateEmbeddings(ctx, openai.EmbeddingRequest{Input: texts[start:end], Model: e.model})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create embeddings: %w", err)
+		}
+		if len(resp.Data) != end-start {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", end-start, len(resp.Data))
+		}
+		for _, data := range resp.Data {
+			vectors = append(vectors, data.Embedding)
+		}
+	}
+	return vectors, nil
+}
+
+// ollamaEmbedder embeds with a model served by Ollama
+type ollamaEmbedder struct {
+	baseURL string
+	model   string
+	http    *http.Client
+}
+
+// NewOllamaEmbedder creates an embedder for a model pulled into the Ollama
+// server at baseURL, such as nomic-embed-text
+func NewOllamaEmbedder(baseURL, model string) Embedder {
+	return &ollamaEmbedder{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		model:   model,
+		http:    &http.Client{Timeout: 2 * time.Minute},
+	}
+}
+
+func (e *ollamaEmbedder) Model() string {
+	return e.model
+}
+
+func (e *ollamaEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += embedBatchSize {
+		end := min(start+embedBatchSize, len(texts))
+		batch, err := e.embedBatch(ctx, texts[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("failed to create embeddings: %w", err)
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+// embedBatch sends one POST /api/embed request
+func (e *ollamaEmbedder) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(map[string]interface{}{"model": e.model, "input": texts})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.baseURL+"/api/embed", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var reply struct {
+		Embeddings [][]float32 `json:"embeddings"`
+		Error      string      `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return nil, fmt.Errorf("ollama returned %d: %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ollama returned %d: %s", resp.StatusCode, reply.Error)
+	}
+	if len(reply.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(reply.Embeddings))
+	}
+	return reply.Embeddings, nil
+}
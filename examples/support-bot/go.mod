@@ -0,0 +1,41 @@
+module github.com/sakibmulla/agentic-ai/examples/support-bot
+
+go 1.21
+
+require (
+	chatbot v0.0.0
+	github.com/sakibmulla/agentic-ai v0.0.0
+	github.com/sakibmulla/agentic-ai/costs v0.0.0
+	github.com/sakibmulla/agentic-ai/logging v0.0.0
+	github.com/sakibmulla/agentic-ai/persist v0.0.0
+	github.com/sakibmulla/agentic-ai/tools v0.0.0
+	github.com/sashabaranov/go-openai v1.40.5
+)
+
+require (
+	github.com/joho/godotenv v1.5.1 // indirect
+	github.com/sakibmulla/agentic-ai/endpoint v0.0.0 // indirect
+	github.com/sakibmulla/agentic-ai/guardrails v0.0.0 // indirect
+	github.com/sakibmulla/agentic-ai/settings v0.0.0 // indirect
+	github.com/sakibmulla/agentic-ai/tokenizer v0.0.0 // indirect
+)
+
+replace chatbot => ../../day-07-chatbot-project
+
+replace github.com/sakibmulla/agentic-ai => ../..
+
+replace github.com/sakibmulla/agentic-ai/costs => ../../costs
+
+replace github.com/sakibmulla/agentic-ai/endpoint => ../../endpoint
+
+replace github.com/sakibmulla/agentic-ai/guardrails => ../../guardrails
+
+replace github.com/sakibmulla/agentic-ai/logging => ../../logging
+
+replace github.com/sakibmulla/agentic-ai/persist => ../../persist
+
+replace github.com/sakibmulla/agentic-ai/settings => ../../settings
+
+replace github.com/sakibmulla/agentic-ai/tokenizer => ../../tokenizer
+
+replace github.com/sakibmulla/agentic-ai/tools => ../../tools
@@ -0,0 +1,257 @@
+// Command support-bot answers questions about a folder of documentation
+// over HTTP. It ties the course's pieces together: the day-07 chatbot
+// (personas, memory, tools, retries, spend limits, analytics and the event
+// bus), the day-08 chunker and an embedding index, behind either provider.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sakibmulla/agentic-ai/costs"
+	"github.com/sakibmulla/agentic-ai/day-08-vector-embeddings/chunker"
+	"github.com/sakibmulla/agentic-ai/logging"
+	"github.com/sashabaranov/go-openai"
+
+	"chatbot/chatbot"
+	"chatbot/config"
+	"chatbot/llm"
+)
+
+// supportPrompt is the persona installed when the tenant has none named
+// SUPPORT_PERSONA
+const supportPrompt = `You are a friendly support agent for the product described in the reference material.
+Answer from that material and cite passages by their number, like [1].
+If the material doesn't cover a question, say so plainly and suggest contacting support rather than guessing.
+Keep answers short and give steps as numbered lists.`
+
+// Settings configures the example on top of the chatbot's config
+type Settings struct {
+	Addr       string
+	DocsDir    string
+	IndexPath  string
+	Persona    string
+	TopK       int
+	MinScore   float64
+	EmbedModel string
+	// HTTPAllow lists the hosts the http_get tool may fetch; empty disables it
+	HTTPAllow []string
+	Server    ServerConfig
+}
+
+// loadSettings reads the example's settings from the environment
+func loadSettings(provider string) Settings {
+	embedModel := getEnv("EMBEDDING_MODEL", "text-embedding-3-small")
+	if provider == llm.ProviderOllama {
+		embedModel = getEnv("OLLAMA_EMBED_MODEL", "nomic-embed-text")
+	}
+	var allow []string
+	for _, host := range strings.Split(os.Getenv("SUPPORT_HTTP_ALLOW"), ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			allow = append(allow, host)
+		}
+	}
+	docsDir := getEnv("DOCS_DIR", "./docs")
+
+	return Settings{
+		Addr:       getEnv("SUPPORT_ADDR", "localhost:8090"),
+		DocsDir:    docsDir,
+		IndexPath:  getEnv("INDEX_PATH", "./data/index.json"),
+		Persona:    getEnv("SUPPORT_PERSONA", "support"),
+		TopK:       getEnvInt("RETRIEVAL_TOP_K", 4),
+		MinScore:   getEnvFloat("RETRIEVAL_MIN_SCORE", 0.3),
+		EmbedModel: embedModel,
+		HTTPAllow:  allow,
+		Server: ServerConfig{
+			MaxInFlight:    getEnvInt("MAX_IN_FLIGHT", 8),
+			RequestTimeout: time.Duration(getEnvInt("REQUEST_TIMEOUT_SECONDS", 60)) * time.Second,
+			DocsDir:        docsDir,
+		},
+	}
+}
+
+func main() {
+	cfg, err := config.Load()
+	logging.Setup(slog.LevelInfo)
+	if err != nil {
+		logging.Fatal("failed to load configuration", "error", err)
+	}
+	settings := loadSettings(cfg.Provider)
+
+	llmClient, err := newLLMClient(cfg)
+	if err != nil {
+		logging.Fatal("failed to initialize LLM client", "error", err)
+	}
+	limiter, err := newCostLimiter(cfg)
+	if err != nil {
+		logging.Fatal("failed to initialize cost tracking", "error", err)
+	}
+	llmClient.SetCostLimiter(limiter)
+	server, err := setup(context.Background(), llmClient, newEmbedder(cfg, settings, limiter), cfg, settings)
+	if err != nil {
+		logging.Fatal("failed to start", "error", err)
+	}
+
+	httpServer := &http.Server{Addr: settings.Addr, Handler: server.Handler()}
+	go func() {
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+		<-sigChan
+		slog.Info("shutting down gracefully")
+		ctx, cancel := context.WithTimeout(context.Background(), settings.Server.RequestTimeout)
+		defer cancel()
+		httpServer.Shutdown(ctx)
+	}()
+
+	slog.Info("support bot listening", "url", "http://"+settings.Addr, "routes", "POST /chat, POST /ingest, GET /health, GET /metrics")
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logging.Fatal("server error", "error", err)
+	}
+}
+
+// setup builds the bot, ingests the docs and returns the server around them
+func setup(ctx context.Context, llmClient *llm.Client, embedder Embedder, cfg *config.Config, settings Settings) (*Server, error) {
+	if cfg.MonthlySpendLimit > 0 {
+		guard, err := llm.NewSpendGuard(cfg.SpendLedgerPath, cfg.MonthlySpendLimit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load spend ledger: %w", err)
+		}
+		llmClient.SetSpendGuard(guard)
+	}
+
+	bot, err := chatbot.New(llmClient, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize chatbot: %w", err)
+	}
+	if err := usePersona(bot, settings.Persona); err != nil {
+		return nil, err
+	}
+
+	index, err := NewDocIndex(embedder, settings.IndexPath, chunker.DefaultConfig())
+	if err != nil {
+		return nil, err
+	}
+	report, err := index.IngestDir(ctx, settings.DocsDir)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("indexed docs", "dir", settings.DocsDir, "added", report.Added, "updated", report.Updated,
+		"removed", report.Removed, "unchanged", report.Unchanged, "passages", report.Passages)
+
+	retriever := newDocsRetriever(index, settings.TopK, settings.MinScore)
+	bot.SetRetriever(retriever)
+
+	// Ollama rejects function definitions, so tools are an OpenAI feature
+	if cfg.Provider == llm.ProviderOpenAI {
+		bot.Tools().MustRegister(searchDocsTool(retriever))
+		if len(settings.HTTPAllow) > 0 {
+			bot.Tools().MustRegister(httpGetTool(settings.HTTPAllow))
+		}
+	}
+
+	return NewServer(bot, index, retriever, settings.Server), nil
+}
+
+// usePersona switches the bot to persona, installing the built-in support
+// prompt for the tenant when the persona doesn't exist yet
+func usePersona(bot *chatbot.Bot, persona string) error {
+	known := false
+	for _, mode := range bot.Modes() {
+		known = known || mode == persona
+	}
+	if !known {
+		if err := bot.SetPersonaOverride(persona, supportPrompt); err != nil {
+			return fmt.Errorf("failed to install persona '%s': %w", persona, err)
+		}
+	}
+	return bot.SetMode(persona)
+}
+
+// newLLMClient creates the client for the configured provider with the
+// configured sampling options
+func newLLMClient(cfg *config.Config) (*llm.Client, error) {
+	var client *llm.Client
+	switch {
+	case cfg.Provider == llm.ProviderOllama:
+		api, err := cfg.OllamaEndpoint()
+		if err != nil {
+			return nil, err
+		}
+		client = llm.NewOllamaClient(api, cfg.Model)
+	case cfg.APIKeysFile == "":
+		var err error
+		if client, err = llm.NewClient(cfg.OpenAIAPIKey, cfg.Endpoint, cfg.Model); err != nil {
+			return nil, err
+		}
+	default:
+		keys, err := llm.LoadKeyPool(cfg.APIKeysFile, cfg.KeyUsagePath)
+		if err != nil {
+			return nil, err
+		}
+		client = llm.NewPooledClient(keys, cfg.Model)
+	}
+
+	sampling, err := llm.ParseSampling(cfg.Sampling)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SAMPLING: %w", err)
+	}
+	return client.WithSampling(sampling)
+}
+
+// newCostLimiter prices calls against the configured budget, keeping spend
+// in the cost ledger
+func newCostLimiter(cfg *config.Config) (*costs.Limiter, error) {
+	budget, err := costs.ParseBudget(cfg.Budget)
+	if err != nil {
+		return nil, fmt.Errorf("invalid BUDGET: %w", err)
+	}
+	if budget.Downgrades, err = costs.ParseDowngrades(cfg.BudgetDowngrades); err != nil {
+		return nil, fmt.Errorf("invalid BUDGET_DOWNGRADES: %w", err)
+	}
+	tracker, err := costs.NewTracker(cfg.CostLedgerPath)
+	if err != nil {
+		return nil, err
+	}
+	return costs.NewLimiter(tracker, budget), nil
+}
+
+// newEmbedder embeds with the same provider that serves the chat model,
+// budgeting OpenAI embeddings with limiter
+func newEmbedder(cfg *config.Config, settings Settings, limiter *costs.Limiter) Embedder {
+	if cfg.Provider == llm.ProviderOllama {
+		return NewOllamaEmbedder(cfg.OllamaURL, settings.EmbedModel)
+	}
+	openaiConfig := cfg.Endpoint.Config(cfg.OpenAIAPIKey)
+	openaiConfig.HTTPClient = limiter.HTTPClient()
+	return NewOpenAIEmbedder(openai.NewClientWithConfig(openaiConfig), settings.EmbedModel)
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
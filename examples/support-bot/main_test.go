@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+
+	"chatbot/config"
+	"chatbot/llm"
+)
+
+// fakeOpenAI serves embeddings that count a few keywords, and a chat model
+// that only knows the refund policy when the bot hands it over
+type fakeOpenAI struct {
+	mu         sync.Mutex
+	embedded   int
+	chats      []openai.ChatCompletionRequest
+	vocabulary []string
+}
+
+func (f *fakeOpenAI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+
+	if strings.HasSuffix(r.URL.Path, "/embeddings") {
+		var req struct {
+			Input []string `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		f.embedded += len(req.Input)
+
+		var resp openai.EmbeddingResponse
+		for i, text := range req.Input {
+			vector := []float32{0.05}
+			for _, word := range f.vocabulary {
+				vector = append(vector, float32(strings.Count(strings.ToLower(text), word)))
+			}
+			resp.Data = append(resp.Data, openai.Embedding{Embedding: vector, Index: i})
+		}
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	var req openai.ChatCompletionRequest
+	json.NewDecoder(r.Body).Decode(&req)
+	f.chats = append(f.chats, req)
+	reply := "I couldn't find that in the docs."
+	if last := req.Messages[len(req.Messages)-1]; strings.Contains(last.Content, "30 days") {
+		reply = "Annual plans can be refunded within 30 days [1]."
+	}
+	json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+		Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Role: "assistant", Content: reply}}},
+		Usage:   openai.Usage{TotalTokens: 20},
+	})
+}
+
+// newTestServer sets the support bot up against fake, with its state in dir
+func newTestServer(t *testing.T, fake *fakeOpenAI, url, dir string) *Server {
+	t.Helper()
+	keysPath := filepath.Join(dir, "keys.json")
+	os.WriteFile(keysPath, []byte(fmt.Sprintf(`{"keys":[{"name":"a","key":"sk-a","base_url":"%s/v1"}]}`, url)), 0600)
+	keys, err := llm.LoadKeyPool(keysPath, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Provider:      llm.ProviderOpenAI,
+		MaxTokens:     100,
+		MaxHistory:    10,
+		RetryAttempts: 1,
+		SaveDirectory: filepath.Join(dir, "conversations"),
+		TenantID:      "default",
+		TenantDir:     filepath.Join(dir, "tenants"),
+	}
+	clientConfig := openai.DefaultConfig("sk-a")
+	clientConfig.BaseURL = url + "/v1"
+	embedder := NewOpenAIEmbedder(openai.NewClientWithConfig(clientConfig), "test-embedding")
+
+	settings := Settings{
+		DocsDir:   "docs",
+		IndexPath: filepath.Join(dir, "index.json"),
+		Persona:   "support",
+		TopK:      2,
+		MinScore:  0.3,
+		Server:    ServerConfig{DocsDir: "docs"},
+	}
+	server, err := setup(context.Background(), llm.NewPooledClient(keys, "gpt-3.5-turbo"), embedder, cfg, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server
+}
+
+// chat posts one message and decodes the reply
+func chat(t *testing.T, handler http.Handler, conversation, message string) chatResponse {
+	t.Helper()
+	body, _ := json.Marshal(chatRequest{Message: message, Conversation: conversation})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/chat", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 from /chat, got %d: %s", rec.Code, rec.Body)
+	}
+	var resp chatResponse
+	json.NewDecoder(rec.Body).Decode(&resp)
+	return resp
+}
+
+// TestSupportBot ingests the sample docs, answers from them over HTTP with
+// per-conversation memory, and re-uses the saved index on restart
+func TestSupportBot(t *testing.T) {
+	fake := &fakeOpenAI{vocabulary: []string{"refund", "password", "invite", "sync", "account", "project"}}
+	api := httptest.NewServer(fake)
+	defer api.Close()
+	dir := t.TempDir()
+
+	server := newTestServer(t, fake, api.URL, dir)
+	if stats := server.index.Stats(); stats.Documents != 3 || stats.Passages == 0 {
+		t.Fatalf("Expected the three sample docs indexed, got %+v", stats)
+	}
+	handler := server.Handler()
+
+	resp := chat(t, handler, "alice", "How do I get a refund?")
+	if !strings.Contains(resp.Reply, "30 days") {
+		t.Errorf("Expected an answer from the billing docs, got %q", resp.Reply)
+	}
+	if len(resp.Sources) != 1 || resp.Sources[0].Source != "billing.md" {
+		t.Errorf("Expected billing.md as the only source, got %+v", resp.Sources)
+	}
+
+	request := fake.chats[0]
+	if system := request.Messages[0].Content; !strings.Contains(system, "support agent") {
+		t.Errorf("Expected the support persona's prompt, got %q", system)
+	}
+	if len(request.Functions) != 1 || request.Functions[0].Name != "search_docs" {
+		t.Errorf("Expected search_docs to be declared, got %+v", request.Functions)
+	}
+
+	// Another conversation starts afresh, and the first one resumes
+	if resp := chat(t, handler, "bob", "What's the weather like?"); len(resp.Sources) != 0 {
+		t.Errorf("Expected no sources for an off-topic question, got %+v", resp.Sources)
+	}
+	chat(t, handler, "alice", "And for monthly plans?")
+	last := fake.chats[len(fake.chats)-1].Messages
+	if len(last) != 4 || last[1].Content != "How do I get a refund?" {
+		t.Errorf("Expected alice's earlier turn in memory without bob's, got %d messages", len(last))
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	var metrics struct {
+		Server serverMetrics `json:"server"`
+	}
+	json.NewDecoder(rec.Body).Decode(&metrics)
+	if m := metrics.Server; m.Requests != 3 || m.Errors != 0 || m.RetrievalHits != 1 || m.RetrievalMiss != 2 {
+		t.Errorf("Unexpected metrics: %+v", m)
+	}
+
+	// A restart only embeds what changed: nothing
+	embedded := fake.embedded
+	restarted := newTestServer(t, fake, api.URL, dir)
+	if fake.embedded != embedded || restarted.index.Stats().Passages != server.index.Stats().Passages {
+		t.Errorf("Expected the saved index to be reused, but %d texts were embedded", fake.embedded-embedded)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sakibmulla/agentic-ai/tools"
+)
+
+// docsRetriever gives the bot the passages closest to each message
+type docsRetriever struct {
+	index *DocIndex
+	// topK is how many passages are retrieved per message
+	topK int
+	// minScore drops passages too dissimilar to help
+	minScore float64
+
+	mu   sync.Mutex
+	last []SearchResult
+}
+
+// newDocsRetriever creates a retriever over index
+func newDocsRetriever(index *DocIndex, topK int, minScore float64) *docsRetriever {
+	return &docsRetriever{index: index, topK: topK, minScore: minScore}
+}
+
+// Retrieve implements chatbot.Retriever
+func (r *docsRetriever) Retrieve(ctx context.Context, message string) (string, error) {
+	results, err := r.search(ctx, message, r.topK)
+	if err != nil {
+		return "", err
+	}
+
+	r.mu.Lock()
+	r.last = results
+	r.mu.Unlock()
+	return formatPassages(results), nil
+}
+
+// LastResults returns the passages retrieved for the latest message
+func (r *docsRetriever) LastResults() []SearchResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.last
+}
+
+// Reset forgets the latest results, before a message that may skip retrieval
+func (r *docsRetriever) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.last = nil
+}
+
+// search returns up to k passages scoring at least minScore
+func (r *docsRetriever) search(ctx context.Context, query string, k int) ([]SearchResult, error) {
+	results, err := r.index.Search(ctx, query, k)
+	if err != nil {
+		return nil, err
+	}
+	kept := results[:0]
+	for _, result := range results {
+		if result.Score >= r.minScore {
+			kept = append(kept, result)
+		}
+	}
+	return kept, nil
+}
+
+// formatPassages numbers passages with their source so the model can cite them
+func formatPassages(results []SearchResult) string {
+	var sb strings.Builder
+	for i, result := range results {
+		fmt.Fprintf(&sb, "[%d] %s", i+1, result.Source)
+		if result.Heading != "" {
+			fmt.Fprintf(&sb, " › %s", result.Heading)
+		}
+		fmt.Fprintf(&sb, "\n%s\n\n", result.Text)
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// searchDocsTool lets the model search the docs again with its own query,
+// for follow-ups the automatic lookup missed
+func searchDocsTool(retriever *docsRetriever) tools.Tool {
+	return tools.Tool{
+		Name:        "search_docs",
+		Description: "Search the product documentation and return the most relevant passages",
+		Parameters: tools.Schema{
+			Type: tools.Object,
+			Properties: map[string]tools.Schema{
+				"query": {Type: tools.String, Description: "What to look for"},
+				"limit": {Type: tools.Integer, Description: "How many passages to return (default 3)"},
+			},
+			Required: []string{"query"},
+		},
+		Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			limit := 3
+			if value, ok := args["limit"].(float64); ok && value > 0 {
+				limit = int(value)
+			}
+			results, err := retriever.search(ctx, args["query"].(string), limit)
+			if err != nil {
+				return "", err
+			}
+			if len(results) == 0 {
+				return "No matching documentation found.", nil
+			}
+			return formatPassages(results), nil
+		},
+	}
+}
+
+// maxFetchBytes caps how much of a page http_get returns to the model
+const maxFetchBytes = 16 * 1024
+
+// httpGetTool fetches pages from an allow-list of hosts, such as a status
+// page, so the model can't be steered into fetching arbitrary URLs
+func httpGetTool(allowedHosts []string) tools.Tool {
+	allowed := make(map[string]bool, len(allowedHosts))
+	for _, host := range allowedHosts {
+		allowed[strings.ToLower(host)] = true
+	}
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		// Redirects must stay on the allow-list too
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if !allowed[strings.ToLower(req.URL.Hostname())] {
+				return fmt.Errorf("redirect to %s is not allowed", req.URL.Hostname())
+			}
+			if len(via) >= 5 {
+				return fmt.Errorf("too many redirects")
+			}
+			return nil
+		},
+	}
+
+	return tools.Tool{
+		Name:        "http_get",
+		Description: fmt.Sprintf("Fetch a web page over HTTP GET. Only these hosts are allowed: %s", strings.Join(allowedHosts, ", ")),
+		Parameters: tools.Schema{
+			Type: tools.Object,
+			Properties: map[string]tools.Schema{
+				"url": {Type: tools.String, Description: "The http or https URL to fetch"},
+			},
+			Required: []string{"url"},
+		},
+		Timeout: 15 * time.Second,
+		Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			target, err := url.Parse(args["url"].(string))
+			if err != nil || (target.Scheme != "http" && target.Scheme != "https") {
+				return "", fmt.Errorf("not an http(s) URL: %v", args["url"])
+			}
+			if !allowed[strings.ToLower(target.Hostname())] {
+				return "", fmt.Errorf("host %s is not allowed", target.Hostname())
+			}
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+			if err != nil {
+				return "", err
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				return "", err
+			}
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchBytes))
+			if err != nil {
+				return "", fmt.Errorf("failed to read response: %w", err)
+			}
+			return fmt.Sprintf("HTTP %d\n\n%s", resp.StatusCode, body), nil
+		},
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"chatbot/chatbot"
+	"chatbot/llm"
+
+	"github.com/sakibmulla/agentic-ai/costs"
+	"github.com/sakibmulla/agentic-ai/logging"
+)
+
+// conversationPattern is what the server accepts as a conversation ID
+var conversationPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// ServerConfig bounds the work the server takes on
+type ServerConfig struct {
+	// MaxInFlight is how many chat requests may wait for the bot at once;
+	// more are turned away with 503
+	MaxInFlight int
+	// RequestTimeout bounds one chat request, retrieval and tools included
+	RequestTimeout time.Duration
+	// DocsDir is re-ingested by POST /ingest
+	DocsDir string
+}
+
+// Server answers support questions over HTTP. The bot holds one
+// conversation in memory at a time, so requests are served one by one and
+// each conversation is saved after its turn and loaded when it comes back.
+type Server struct {
+	bot       *chatbot.Bot
+	index     *DocIndex
+	retriever *docsRetriever
+	config    ServerConfig
+	inFlight  chan struct{}
+
+	// mu serializes use of the bot
+	mu      sync.Mutex
+	current string
+
+	metricsMu sync.Mutex
+	metrics   serverMetrics
+}
+
+// serverMetrics counts the server's traffic
+type serverMetrics struct {
+	Requests       int           `json:"requests"`
+	Errors         int           `json:"errors"`
+	Rejected       int           `json:"rejected"`
+	TimedOut       int           `json:"timed_out"`
+	RetrievalHits  int           `json:"retrieval_hits"`
+	RetrievalMiss  int           `json:"retrieval_misses"`
+	TotalLatency   time.Duration `json:"total_latency"`
+	MaxLatency     time.Duration `json:"max_latency"`
+	AverageLatency time.Duration `json:"average_latency"`
+}
+
+// chatRequest is the body of POST /chat
+type chatRequest struct {
+	Message      string `json:"message"`
+	Conversation string `json:"conversation,omitempty"`
+}
+
+// chatResponse is the reply to POST /chat
+type chatResponse struct {
+	Reply        string   `json:"reply"`
+	Conversation string   `json:"conversation"`
+	Sources      []source `json:"sources"`
+}
+
+// source is a passage an answer was grounded in
+type source struct {
+	Source  string  `json:"source"`
+	Heading string  `json:"heading,omitempty"`
+	Score   float64 `json:"score"`
+}
+
+// answerEvent is published on the bot's bus after every answer
+type answerEvent struct {
+	Conversation string        `json:"conversation"`
+	Sources      int           `json:"sources"`
+	Latency      time.Duration `json:"latency"`
+	Error        string        `json:"error,omitempty"`
+}
+
+// NewServer creates a server for bot, answering from index
+func NewServer(bot *chatbot.Bot, index *DocIndex, retriever *docsRetriever, config ServerConfig) *Server {
+	if config.MaxInFlight <= 0 {
+		config.MaxInFlight = 8
+	}
+	if config.RequestTimeout <= 0 {
+		config.RequestTimeout = time.Minute
+	}
+	return &Server{
+		bot:       bot,
+		index:     index,
+		retriever: retriever,
+		config:    config,
+		inFlight:  make(chan struct{}, config.MaxInFlight),
+	}
+}
+
+// Handler returns the server's routes
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/chat", s.handleChat)
+	mux.HandleFunc("/ingest", s.handleIngest)
+	mux.HandleFunc("/health", s.handleHealth)
+	mux.HandleFunc("/metrics", s.handleMetrics)
+	return logging.Middleware(mux)
+}
+
+// handleChat answers one message
+func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	var req chatRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&req); err != nil || req.Message == "" {
+		writeError(w, http.StatusBadRequest, `expected {"message": "..."}`)
+		return
+	}
+	if req.Conversation == "" {
+		req.Conversation = "default"
+	}
+	if !conversationPattern.MatchString(req.Conversation) {
+		writeError(w, http.StatusBadRequest, "conversation must be 1-64 letters, digits, '_' or '-'")
+		return
+	}
+
+	select {
+	case s.inFlight <- struct{}{}:
+		defer func() { <-s.inFlight }()
+	default:
+		s.count(func(m *serverMetrics) { m.Rejected++ })
+		writeError(w, http.StatusServiceUnavailable, "too many requests in flight, try again shortly")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.config.RequestTimeout)
+	defer cancel()
+
+	started := time.Now()
+	reply, sources, err := s.answer(ctx, req.Conversation, req.Message)
+	latency := time.Since(started)
+
+	event := answerEvent{Conversation: req.Conversation, Sources: len(sources), Latency: latency}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	if err := s.bot.Bus().Publish("support.answer", event); err != nil {
+		slog.WarnContext(ctx, "failed to publish answer event", "error", err)
+	}
+	s.count(func(m *serverMetrics) {
+		m.Requests++
+		m.TotalLatency += latency
+		if latency > m.MaxLatency {
+			m.MaxLatency = latency
+		}
+		switch {
+		case err != nil:
+			m.Errors++
+		case len(sources) > 0:
+			m.RetrievalHits++
+		default:
+			m.RetrievalMiss++
+		}
+	})
+	slog.InfoContext(ctx, "chat", "conversation", req.Conversation, "sources", len(sources), "latency_ms", latency.Milliseconds(), "error", err)
+
+	switch {
+	case errors.Is(err, llm.ErrSpendLimitExceeded):
+		writeError(w, http.StatusTooManyRequests, "the monthly spend limit has been reached")
+	case errors.Is(err, costs.ErrBudgetExceeded):
+		writeError(w, http.StatusTooManyRequests, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		s.count(func(m *serverMetrics) { m.TimedOut++ })
+		writeError(w, http.StatusGatewayTimeout, "the answer took too long")
+	case err != nil:
+		writeError(w, http.StatusBadGateway, err.Error())
+	default:
+		writeJSON(w, http.StatusOK, chatResponse{Reply: reply, Conversation: req.Conversation, Sources: sources})
+	}
+}
+
+// answer switches the bot to the conversation, answers and saves the turn
+func (s *Server) answer(ctx context.Context, conversation, message string) (string, []source, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The request may have waited out its deadline for the lock
+	if err := ctx.Err(); err != nil {
+		return "", nil, err
+	}
+	if err := s.switchTo(conversation); err != nil {
+		return "", nil, err
+	}
+
+	s.retriever.Reset()
+	reply, err := s.bot.ProcessMessage(ctx, message)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := s.bot.SaveConversation(conversation); err != nil {
+		return "", nil, fmt.Errorf("failed to save conversation: %w", err)
+	}
+
+	sources := []source{}
+	for _, result := range s.retriever.LastResults() {
+		sources = append(sources, source{Source: result.Source, Heading: result.Heading, Score: result.Score})
+	}
+	return reply, sources, nil
+}
+
+// switchTo loads a conversation into the bot's memory, or starts it afresh
+func (s *Server) switchTo(conversation string) error {
+	if conversation == s.current {
+		return nil
+	}
+	for _, name := range s.bot.ListConversations() {
+		if name == conversation {
+			if err := s.bot.LoadConversation(conversation); err != nil {
+				return fmt.Errorf("failed to load conversation: %w", err)
+			}
+			s.current = conversation
+			return nil
+		}
+	}
+	s.bot.ClearMemory()
+	s.current = conversation
+	return nil
+}
+
+// handleIngest re-reads the docs directory
+func (s *Server) handleIngest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	report, err := s.index.IngestDir(r.Context(), s.config.DocsDir)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	slog.InfoContext(r.Context(), "ingest", "added", report.Added, "updated", report.Updated, "removed", report.Removed, "passages", report.Passages)
+	writeJSON(w, http.StatusOK, report)
+}
+
+// handleHealth reports whether the bot can answer
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	stats := s.index.Stats()
+	health := map[string]interface{}{
+		"status":   "ok",
+		"provider": s.bot.Provider(),
+		"index":    stats,
+	}
+	status := http.StatusOK
+	if stats.Passages == 0 {
+		health["status"] = "degraded"
+		health["reason"] = "no documents indexed"
+	}
+	if guard := s.bot.SpendGuard(); guard != nil && guard.Stopped() {
+		health["status"] = "degraded"
+		health["reason"] = "monthly spend limit reached"
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, health)
+}
+
+// handleMetrics reports the server's traffic with the bot's usage
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	s.metricsMu.Lock()
+	metrics := s.metrics
+	s.metricsMu.Unlock()
+	if answered := metrics.Requests; answered > 0 {
+		metrics.AverageLatency = metrics.TotalLatency / time.Duration(answered)
+	}
+
+	report := map[string]interface{}{
+		"server":    metrics,
+		"in_flight": len(s.inFlight),
+		"index":     s.index.Stats(),
+		"usage":     s.bot.Analytics().Report(),
+		"bus":       s.bot.Bus().Stats(),
+	}
+	if guard := s.bot.SpendGuard(); guard != nil {
+		report["spend"] = map[string]interface{}{
+			"month_to_date": guard.MonthToDate(),
+			"limit_usd":     guard.Limit(),
+		}
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// count updates the metrics
+func (s *Server) count(update func(*serverMetrics)) {
+	s.metricsMu.Lock()
+	defer s.metricsMu.Unlock()
+	update(&s.metrics)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn("failed to write response", "error", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
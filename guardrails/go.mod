@@ -0,0 +1,3 @@
+module github.com/sakibmulla/agentic-ai/guardrails
+
+go 1.21
@@ -0,0 +1,265 @@
+// Package guardrails checks what goes into and comes out of a model. A
+// Pipeline runs pre-processors over the user's message and post-processors
+// over the model's reply; each may let the text through, rewrite it or
+// block it, and every rewrite or block is kept as an audit record.
+package guardrails
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrBlocked is returned for a message or reply a processor blocked
+var ErrBlocked = errors.New("blocked by guardrails")
+
+// Stage is where in a call a processor runs
+type Stage string
+
+const (
+	StageInput  Stage = "input"
+	StageOutput Stage = "output"
+)
+
+// Action is what a processor did to the text
+type Action string
+
+const (
+	Allow     Action = "allow"
+	Transform Action = "transform"
+	Block     Action = "block"
+)
+
+// Result is a processor's verdict on one text
+type Result struct {
+	Action Action
+	// Text replaces the original when Action is Transform
+	Text string
+	// Reason explains a transform or block, e.g. "redacted 2 emails"
+	Reason string
+	// Findings name what was detected, e.g. "email" or "violence"
+	Findings []string
+}
+
+// Processor inspects text on its way into or out of a model
+type Processor interface {
+	Name() string
+	Process(ctx context.Context, text string) (Result, error)
+}
+
+// Decision records a processor transforming or blocking text. It holds
+// hashes rather than the text itself, so the log doesn't keep what a
+// redaction removed.
+type Decision struct {
+	Time       time.Time `json:"time"`
+	Stage      Stage     `json:"stage"`
+	Processor  string    `json:"processor"`
+	Action     Action    `json:"action"`
+	Reason     string    `json:"reason,omitempty"`
+	Findings   []string  `json:"findings,omitempty"`
+	InputHash  string    `json:"input_sha256"`
+	OutputHash string    `json:"output_sha256,omitempty"`
+	InputChars int       `json:"input_chars"`
+}
+
+// BlockedError is returned when a processor blocks; it matches ErrBlocked
+type BlockedError struct {
+	Decision Decision
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("%s %s blocked by %s: %s", ErrBlocked, e.Decision.Stage, e.Decision.Processor, e.Decision.Reason)
+}
+
+func (e *BlockedError) Is(target error) bool {
+	return target == ErrBlocked
+}
+
+// recentDecisions is how many decisions a pipeline keeps in memory
+const recentDecisions = 100
+
+// Pipeline runs its Input processors over messages and its Output
+// processors over replies, in order, each seeing the previous one's text.
+// A processor's error fails the check, so a moderation outage doesn't let
+// content through unchecked.
+type Pipeline struct {
+	Input  []Processor
+	Output []Processor
+	// LogPath, when set, gets each decision appended as a JSON line
+	LogPath string
+
+	recent []Decision
+	mu     sync.Mutex
+}
+
+// ChatFunc is an agent's chat call: a message in, a reply out
+type ChatFunc func(ctx context.Context, message string) (string, error)
+
+// Wrap returns chat with the pipeline's checks around it. A blocked message
+// never reaches chat, and a blocked reply is returned as an error.
+func (p *Pipeline) Wrap(chat ChatFunc) ChatFunc {
+	return func(ctx context.Context, message string) (string, error) {
+		message, err := p.CheckInput(ctx, message)
+		if err != nil {
+			return "", err
+		}
+		reply, err := chat(ctx, message)
+		if err != nil {
+			return "", err
+		}
+		return p.CheckOutput(ctx, reply)
+	}
+}
+
+// CheckInput runs the input processors over a user's message and returns
+// it as transformed, or a *BlockedError
+func (p *Pipeline) CheckInput(ctx context.Context, message string) (string, error) {
+	return p.run(ctx, StageInput, p.Input, message)
+}
+
+// CheckOutput runs the output processors over a model's reply and returns
+// it as transformed, or a *BlockedError
+func (p *Pipeline) CheckOutput(ctx context.Context, reply string) (string, error) {
+	return p.run(ctx, StageOutput, p.Output, reply)
+}
+
+func (p *Pipeline) run(ctx context.Context, stage Stage, processors []Processor, text string) (string, error) {
+	for _, processor := range processors {
+		result, err := processor.Process(ctx, text)
+		if err != nil {
+			return "", fmt.Errorf("guardrail %s failed: %w", processor.Name(), err)
+		}
+		if result.Action == Allow || result.Action == "" {
+			continue
+		}
+
+		decision := Decision{
+			Time:       time.Now(),
+			Stage:      stage,
+			Processor:  processor.Name(),
+			Action:     result.Action,
+			Reason:     result.Reason,
+			Findings:   result.Findings,
+			InputHash:  hash(text),
+			InputChars: len([]rune(text)),
+		}
+		if result.Action == Transform {
+			decision.OutputHash = hash(result.Text)
+		}
+		if err := p.record(decision); err != nil {
+			return "", err
+		}
+
+		if result.Action == Block {
+			return "", &BlockedError{Decision: decision}
+		}
+		text = result.Text
+	}
+	return text, nil
+}
+
+// record keeps a decision in memory and appends it to the log file
+func (p *Pipeline) record(decision Decision) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.recent = append(p.recent, decision)
+	if len(p.recent) > recentDecisions {
+		p.recent = p.recent[len(p.recent)-recentDecisions:]
+	}
+	if p.LogPath == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(decision)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(p.LogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open guardrail log: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write guardrail log: %w", err)
+	}
+	return nil
+}
+
+// Decisions returns the latest decisions, oldest first
+func (p *Pipeline) Decisions() []Decision {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Decision(nil), p.recent...)
+}
+
+// Empty reports whether the pipeline has no processors
+func (p *Pipeline) Empty() bool {
+	return len(p.Input) == 0 && len(p.Output) == 0
+}
+
+func hash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// FromEnv builds a pipeline from GUARDRAILS_* variables:
+//
+//   - GUARDRAILS_PII=true redacts the personal data FindPII finds from
+//     messages
+//   - GUARDRAILS_INJECTION=true blocks messages that look like prompt injection
+//   - GUARDRAILS_MAX_INPUT_CHARS blocks longer messages
+//   - GUARDRAILS_MODERATION=true sends replies to OpenAI's moderation API
+//     with OPENAI_API_KEY
+//   - GUARDRAILS_BANNED_TOPICS is a comma-separated list of phrases whose
+//     replies are blocked
+//   - GUARDRAILS_PROFANITY=true masks profanity in replies
+//   - GUARDRAILS_LOG is the decision log's path
+//
+// With none set the pipeline is empty.
+func FromEnv() (*Pipeline, error) {
+	p := &Pipeline{LogPath: os.Getenv("GUARDRAILS_LOG")}
+
+	if value := os.Getenv("GUARDRAILS_MAX_INPUT_CHARS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid GUARDRAILS_MAX_INPUT_CHARS %q", value)
+		}
+		p.Input = append(p.Input, MaxLength{Chars: n})
+	}
+	if os.Getenv("GUARDRAILS_INJECTION") == "true" {
+		p.Input = append(p.Input, NewInjectionDetector())
+	}
+	if os.Getenv("GUARDRAILS_PII") == "true" {
+		p.Input = append(p.Input, PIIRedactor{})
+	}
+
+	if os.Getenv("GUARDRAILS_MODERATION") == "true" {
+		key := os.Getenv("OPENAI_API_KEY")
+		if key == "" {
+			return nil, fmt.Errorf("GUARDRAILS_MODERATION needs OPENAI_API_KEY")
+		}
+		p.Output = append(p.Output, &Moderation{APIKey: key})
+	}
+	if topics := os.Getenv("GUARDRAILS_BANNED_TOPICS"); topics != "" {
+		filter := TopicFilter{Topics: map[string][]string{}}
+		for _, topic := range strings.Split(topics, ",") {
+			if topic = strings.TrimSpace(topic); topic != "" {
+				filter.Topics[topic] = []string{topic}
+			}
+		}
+		p.Output = append(p.Output, filter)
+	}
+	if os.Getenv("GUARDRAILS_PROFANITY") == "true" {
+		p.Output = append(p.Output, NewProfanityMask())
+	}
+	return p, nil
+}
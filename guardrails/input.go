@@ -0,0 +1,230 @@
+package guardrails
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxLength blocks text longer than Chars characters, or cuts it short
+// when Truncate is set
+type MaxLength struct {
+	Chars    int
+	Truncate bool
+}
+
+func (m MaxLength) Name() string { return "max_length" }
+
+func (m MaxLength) Process(ctx context.Context, text string) (Result, error) {
+	length := utf8.RuneCountInString(text)
+	if length <= m.Chars {
+		return Result{Action: Allow}, nil
+	}
+	reason := fmt.Sprintf("%d characters is over the limit of %d", length, m.Chars)
+	if !m.Truncate {
+		return Result{Action: Block, Reason: reason, Findings: []string{"too_long"}}, nil
+	}
+	return Result{
+		Action:   Transform,
+		Text:     string([]rune(text)[:m.Chars]),
+		Reason:   "truncated: " + reason,
+		Findings: []string{"too_long"},
+	}, nil
+}
+
+// piiPatterns find each kind of personal data. Card numbers are also
+// Luhn-checked, so order numbers and the like survive. Names are only
+// recognised where the user introduces themselves.
+var piiPatterns = []struct {
+	kind    string
+	pattern *regexp.Regexp
+	// group is the submatch holding the data; zero is the whole match
+	group int
+}{
+	{"email", regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), 0},
+	{"card", regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), 0},
+	{"ssn", regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`), 0},
+	{"phone", regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?(?:\(\d{3}\)|\b\d{3})[ .-]?\d{3}[ .-]?\d{4}\b`), 0},
+	{"ip", regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`), 0},
+	{"address", regexp.MustCompile(`(?i)\b\d{1,5}(?: [A-Za-z][A-Za-z.]*){1,4} (?:street|st|avenue|ave|road|rd|lane|ln|drive|dr|boulevard|blvd|court|ct|way|place|pl)\b`), 0},
+	{"name", regexp.MustCompile(`(?i:\bmy name is|\bi'm called|\bcall me) ([A-Z][a-z]+(?: [A-Z][a-z]+)?)`), 1},
+}
+
+// PIIMatch is one piece of personal data found in a text
+type PIIMatch struct {
+	Kind  string `json:"kind"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Text  string `json:"text"`
+}
+
+// FindPII returns the personal data in text, in order and without overlaps:
+// emails, card numbers, SSNs, phone numbers, IP addresses, street addresses
+// and self-introduced names
+func FindPII(text string) []PIIMatch {
+	var matches []PIIMatch
+	for _, pii := range piiPatterns {
+		for _, loc := range pii.pattern.FindAllStringSubmatchIndex(text, -1) {
+			start, end := loc[2*pii.group], loc[2*pii.group+1]
+			if pii.kind == "card" && !luhn(text[start:end]) {
+				continue
+			}
+			matches = append(matches, PIIMatch{Kind: pii.kind, Start: start, End: end, Text: text[start:end]})
+		}
+	}
+
+	// Earlier patterns win an overlap, so a card number isn't also a phone
+	kept := matches[:0]
+	for _, match := range matches {
+		overlaps := false
+		for _, k := range kept {
+			if match.Start < k.End && k.Start < match.End {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			kept = append(kept, match)
+		}
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i].Start < kept[j].Start })
+	return kept
+}
+
+// RedactPII replaces each match in text, as returned by FindPII, with a
+// placeholder such as [EMAIL]
+func RedactPII(text string, matches []PIIMatch) string {
+	var b strings.Builder
+	last := 0
+	for _, match := range matches {
+		if match.Start < last {
+			continue
+		}
+		b.WriteString(text[last:match.Start])
+		b.WriteString("[" + strings.ToUpper(match.Kind) + "]")
+		last = match.End
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// PIIRedactor replaces the personal data FindPII finds with placeholders,
+// so it never reaches the model or its provider
+type PIIRedactor struct{}
+
+func (PIIRedactor) Name() string { return "pii_redactor" }
+
+func (PIIRedactor) Process(ctx context.Context, text string) (Result, error) {
+	matches := FindPII(text)
+	if len(matches) == 0 {
+		return Result{Action: Allow}, nil
+	}
+
+	counts := make(map[string]int)
+	for _, match := range matches {
+		counts[match.Kind]++
+	}
+	var findings, redacted []string
+	for kind, n := range counts {
+		findings = append(findings, kind)
+		redacted = append(redacted, fmt.Sprintf("%d %s", n, kind))
+	}
+	sort.Strings(findings)
+	sort.Strings(redacted)
+	return Result{
+		Action:   Transform,
+		Text:     RedactPII(text, matches),
+		Reason:   "redacted " + strings.Join(redacted, ", "),
+		Findings: findings,
+	}, nil
+}
+
+// luhn reports whether the digits in number pass the Luhn checksum
+func luhn(number string) bool {
+	sum, double := 0, false
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		digit := int(c - '0')
+		if double {
+			if digit *= 2; digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// injectionPhrases are the phrasings prompt injection attempts commonly
+// use, each with a weight towards the detector's threshold
+var injectionPhrases = map[string]int{
+	"ignore previous instructions":  3,
+	"ignore all previous":           3,
+	"ignore the above":              3,
+	"disregard your instructions":   3,
+	"disregard all prior":           3,
+	"forget your instructions":      3,
+	"reveal your system prompt":     3,
+	"print your system prompt":      3,
+	"developer mode":                2,
+	"jailbreak":                     2,
+	"do anything now":               2,
+	"you are now":                   1,
+	"pretend you are":               1,
+	"act as if you have no":         2,
+	"without any restrictions":      1,
+	"system prompt":                 1,
+	"new instructions:":             2,
+	"<|im_start|>":                  3,
+	"### system":                    2,
+	"override your safety":          3,
+	"you have no rules":             2,
+	"respond without filtering":     2,
+	"from now on you will only say": 1,
+}
+
+// InjectionDetector blocks messages whose injection phrases add up to
+// Threshold. The heuristics catch copy-pasted attacks, not determined ones;
+// pair them with output checks.
+type InjectionDetector struct {
+	Phrases   map[string]int
+	Threshold int
+}
+
+// NewInjectionDetector creates a detector with the built-in phrases, which
+// blocks on one strong phrase or a few weak ones
+func NewInjectionDetector() InjectionDetector {
+	return InjectionDetector{Phrases: injectionPhrases, Threshold: 3}
+}
+
+func (d InjectionDetector) Name() string { return "injection_detector" }
+
+func (d InjectionDetector) Process(ctx context.Context, text string) (Result, error) {
+	// Collapse whitespace so line breaks and padding don't split a phrase
+	normalized := strings.Join(strings.Fields(strings.ToLower(text)), " ")
+
+	score := 0
+	var findings []string
+	for phrase, weight := range d.Phrases {
+		if strings.Contains(normalized, phrase) {
+			score += weight
+			findings = append(findings, phrase)
+		}
+	}
+	if score < d.Threshold {
+		return Result{Action: Allow}, nil
+	}
+	sort.Strings(findings)
+	return Result{
+		Action:   Block,
+		Reason:   fmt.Sprintf("likely prompt injection (score %d)", score),
+		Findings: findings,
+	}, nil
+}
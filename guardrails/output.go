@@ -0,0 +1,182 @@
+package guardrails
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultModerationURL is OpenAI's moderation endpoint
+const DefaultModerationURL = "https://api.openai.com/v1/moderations"
+
+// Moderation blocks text OpenAI's moderation API flags. The call is free,
+// but adds a round trip to every check.
+type Moderation struct {
+	APIKey string
+	// URL defaults to DefaultModerationURL
+	URL string
+	// Model defaults to the API's own default
+	Model  string
+	Client *http.Client
+	// Categories, when set, blocks only on these categories, e.g.
+	// "violence" or "self-harm"; otherwise anything flagged is blocked
+	Categories []string
+}
+
+func (m *Moderation) Name() string { return "moderation" }
+
+func (m *Moderation) Process(ctx context.Context, text string) (Result, error) {
+	body, err := json.Marshal(struct {
+		Input string `json:"input"`
+		Model string `json:"model,omitempty"`
+	}{text, m.Model})
+	if err != nil {
+		return Result{}, err
+	}
+	url := m.URL
+	if url == "" {
+		url = DefaultModerationURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+m.APIKey)
+
+	client := m.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Result{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Result{}, fmt.Errorf("moderation API returned %s", resp.Status)
+	}
+
+	var moderation struct {
+		Results []struct {
+			Flagged    bool            `json:"flagged"`
+			Categories map[string]bool `json:"categories"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&moderation); err != nil {
+		return Result{}, fmt.Errorf("failed to decode moderation result: %w", err)
+	}
+
+	var findings []string
+	for _, result := range moderation.Results {
+		if !result.Flagged {
+			continue
+		}
+		for category, flagged := range result.Categories {
+			if flagged && (len(m.Categories) == 0 || contains(m.Categories, category)) {
+				findings = append(findings, category)
+			}
+		}
+	}
+	if len(findings) == 0 {
+		return Result{Action: Allow}, nil
+	}
+	sort.Strings(findings)
+	return Result{
+		Action:   Block,
+		Reason:   "flagged by moderation: " + strings.Join(findings, ", "),
+		Findings: findings,
+	}, nil
+}
+
+// TopicFilter blocks text mentioning a banned topic. Topics maps each
+// topic's name to the phrases that mention it, matched as whole words
+// without regard to case.
+type TopicFilter struct {
+	Topics map[string][]string
+}
+
+func (f TopicFilter) Name() string { return "topic_filter" }
+
+func (f TopicFilter) Process(ctx context.Context, text string) (Result, error) {
+	var findings []string
+	for topic, phrases := range f.Topics {
+		for _, phrase := range phrases {
+			if wordPattern(phrase).MatchString(text) {
+				findings = append(findings, topic)
+				break
+			}
+		}
+	}
+	if len(findings) == 0 {
+		return Result{Action: Allow}, nil
+	}
+	sort.Strings(findings)
+	return Result{
+		Action:   Block,
+		Reason:   "banned topic: " + strings.Join(findings, ", "),
+		Findings: findings,
+	}, nil
+}
+
+// defaultProfanity is a deliberately short list; pass your own to
+// ProfanityMask for anything serious
+var defaultProfanity = []string{"damn", "hell", "crap", "shit", "fuck", "bastard", "bitch", "asshole"}
+
+// ProfanityMask replaces profane words with asterisks, keeping their first
+// letter. Words match whole and with common suffixes, so "class" and
+// "shell" are left alone while "fucking" is masked.
+type ProfanityMask struct {
+	pattern *regexp.Regexp
+}
+
+// NewProfanityMask masks words, or a short built-in list when none are given
+func NewProfanityMask(words ...string) ProfanityMask {
+	if len(words) == 0 {
+		words = defaultProfanity
+	}
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = regexp.QuoteMeta(strings.ToLower(word))
+	}
+	return ProfanityMask{pattern: regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)(?:s|es|ed|er|ers|ing|y)?\b`)}
+}
+
+func (m ProfanityMask) Name() string { return "profanity_mask" }
+
+func (m ProfanityMask) Process(ctx context.Context, text string) (Result, error) {
+	count := 0
+	masked := m.pattern.ReplaceAllStringFunc(text, func(word string) string {
+		count++
+		return word[:1] + strings.Repeat("*", len(word)-1)
+	})
+	if count == 0 {
+		return Result{Action: Allow}, nil
+	}
+	return Result{
+		Action:   Transform,
+		Text:     masked,
+		Reason:   fmt.Sprintf("masked %d words", count),
+		Findings: []string{"profanity"},
+	}, nil
+}
+
+// wordPattern matches phrase as whole words, ignoring case
+func wordPattern(phrase string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(phrase) + `\b`)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+)
+
+// Header carries a request's correlation ID in and out of HTTP services
+const Header = "X-Correlation-ID"
+
+// validID is what an incoming correlation ID must look like to be kept
+var validID = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,64}$`)
+
+type correlationKey struct{}
+
+// NewCorrelationID returns a random 16 character ID
+func NewCorrelationID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// WithCorrelationID returns a context whose records carry id
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// CorrelationID returns the correlation ID in ctx, if any
+func CorrelationID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// EnsureCorrelationID returns ctx with a new correlation ID unless it
+// already has one
+func EnsureCorrelationID(ctx context.Context) context.Context {
+	if CorrelationID(ctx) != "" {
+		return ctx
+	}
+	return WithCorrelationID(ctx, NewCorrelationID())
+}
+
+// Middleware gives each request a correlation ID: the caller's from the
+// X-Correlation-ID header when it is well-formed, otherwise a new one. The
+// ID is echoed in the response's header.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(Header)
+		if !validID.MatchString(id) {
+			id = NewCorrelationID()
+		}
+		w.Header().Set(Header, id)
+		next.ServeHTTP(w, r.WithContext(WithCorrelationID(r.Context(), id)))
+	})
+}
@@ -0,0 +1,121 @@
+// Package logging is the structured logger the agents in this course share.
+// It logs through log/slog, as JSON by default, and adds the correlation ID
+// carried by a call's context to every record logged with it:
+//
+//	logging.Setup(slog.LevelInfo)
+//	ctx = logging.WithCorrelationID(ctx, logging.NewCorrelationID())
+//	slog.InfoContext(ctx, "answered", "tokens", 512)
+//	// {"time":"...","level":"INFO","msg":"answered","tokens":512,"correlation_id":"3f9c..."}
+//
+// Once Setup has run, the log package's output goes through the same
+// handler.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Config chooses how records are logged
+type Config struct {
+	Level slog.Level
+	// Format is "json" or "text"
+	Format string
+	// Output defaults to stderr, leaving stdout to the programs' own output
+	Output io.Writer
+}
+
+// ParseLevel parses debug, info, warn or error; empty is info
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if strings.TrimSpace(name) == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(name))); err != nil {
+		return 0, fmt.Errorf("invalid log level %q: expected debug, info, warn or error", name)
+	}
+	return level, nil
+}
+
+// New creates a logger for cfg
+func New(cfg Config) (*slog.Logger, error) {
+	output := cfg.Output
+	if output == nil {
+		output = os.Stderr
+	}
+	options := &slog.HandlerOptions{Level: cfg.Level}
+
+	var handler slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "", "json":
+		handler = slog.NewJSONHandler(output, options)
+	case "text":
+		handler = slog.NewTextHandler(output, options)
+	default:
+		return nil, fmt.Errorf("invalid log format %q: expected json or text", cfg.Format)
+	}
+	return slog.New(contextHandler{handler}), nil
+}
+
+// ConfigFromEnv reads LOG_LEVEL and LOG_FORMAT, logging at defaultLevel
+// when LOG_LEVEL isn't set
+func ConfigFromEnv(defaultLevel slog.Level) (Config, error) {
+	cfg := Config{Level: defaultLevel, Format: os.Getenv("LOG_FORMAT")}
+	if name := os.Getenv("LOG_LEVEL"); name != "" {
+		level, err := ParseLevel(name)
+		if err != nil {
+			return Config{}, err
+		}
+		cfg.Level = level
+	}
+	return cfg, nil
+}
+
+// Setup makes the logger configured by the environment the default one.
+// Interactive programs pass slog.LevelWarn so per-call records don't
+// interleave with the conversation; services pass slog.LevelInfo. An
+// invalid setting is reported and otherwise ignored, so a typo doesn't stop
+// a program from starting.
+func Setup(defaultLevel slog.Level) *slog.Logger {
+	cfg, err := ConfigFromEnv(defaultLevel)
+	var logger *slog.Logger
+	if err == nil {
+		logger, err = New(cfg)
+	}
+	if err != nil {
+		logger, _ = New(Config{Level: defaultLevel})
+		logger.Warn("using the default log settings", "error", err)
+	}
+	slog.SetDefault(logger)
+	return logger
+}
+
+// Fatal logs msg as an error and exits
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// contextHandler adds the context's correlation ID to each record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := CorrelationID(ctx); id != "" {
+		record.AddAttrs(slog.String("correlation_id", id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,78 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(Config{Level: slog.LevelWarn, Output: &out})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+
+	ctx := WithCorrelationID(context.Background(), "abc123")
+	logger.InfoContext(ctx, "hidden")
+	logger.With("component", "test").WarnContext(ctx, "shown", "tokens", 42)
+	logger.Error("no context")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 records at warn and above, got %q", out.String())
+	}
+	var record map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("Expected JSON, got %q", lines[0])
+	}
+	if record["msg"] != "shown" || record["correlation_id"] != "abc123" || record["component"] != "test" || record["tokens"] != 42.0 {
+		t.Errorf("Unexpected record %v", record)
+	}
+	if strings.Contains(lines[1], "correlation_id") {
+		t.Errorf("Expected no correlation ID without one in the context, got %q", lines[1])
+	}
+
+	if _, err := New(Config{Format: "xml"}); err == nil {
+		t.Error("Expected an unknown format to be rejected")
+	}
+	for name, want := range map[string]slog.Level{"": slog.LevelInfo, "debug": slog.LevelDebug, "WARN": slog.LevelWarn} {
+		if level, err := ParseLevel(name); err != nil || level != want {
+			t.Errorf("ParseLevel(%q) = %v, %v", name, level, err)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("Expected an unknown level to be rejected")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var seen string
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = CorrelationID(r.Context())
+	}))
+
+	for incoming, keep := range map[string]bool{"req-42": true, "": false, "bad id\n": false} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(Header, incoming)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+
+		if seen == "" || recorder.Header().Get(Header) != seen {
+			t.Errorf("Expected the ID %q to be echoed, got %q", seen, recorder.Header().Get(Header))
+		}
+		if (seen == incoming) != keep {
+			t.Errorf("Incoming ID %q: expected kept=%v, got %q", incoming, keep, seen)
+		}
+	}
+
+	ctx := EnsureCorrelationID(context.Background())
+	if id := CorrelationID(ctx); len(id) != 16 || CorrelationID(EnsureCorrelationID(ctx)) != id {
+		t.Errorf("Expected EnsureCorrelationID to add one ID and keep it, got %q", id)
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/sakibmulla/agentic-ai/persist
+
+go 1.21
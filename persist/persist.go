@@ -0,0 +1,212 @@
+// Package persist versions the files the agents in this course write. Every
+// document is stored in an envelope naming its format and version:
+//
+//	{"format": "conversation", "version": 2, "data": {...}}
+//
+// Loading runs the format's migrations from the stored version up to the
+// current one, so a format can change without stranding existing files.
+// Files written before envelopes existed are read as version 1.
+package persist
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+var (
+	// ErrWrongFormat is returned when a document is an envelope for another format
+	ErrWrongFormat = errors.New("wrong document format")
+	// ErrNewerVersion is returned when a document was written by a newer
+	// release; it is refused rather than risk overwriting it with less data
+	ErrNewerVersion = errors.New("document version is newer than supported")
+	// ErrNoMigration is returned when no migration upgrades a stored version
+	ErrNoMigration = errors.New("no migration")
+)
+
+// Envelope is how every document is stored
+type Envelope struct {
+	Format  string          `json:"format"`
+	Version int             `json:"version"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// Migration upgrades a document by one version
+type Migration func(data json.RawMessage) (json.RawMessage, error)
+
+// Format is a versioned document type
+type Format struct {
+	Name string
+	// Version is the version documents are written at
+	Version    int
+	migrations map[int]Migration
+}
+
+// NewFormat declares a format whose documents are written at version
+func NewFormat(name string, version int) *Format {
+	return &Format{Name: name, Version: version, migrations: make(map[int]Migration)}
+}
+
+// Migrate registers the migration from version from to from+1 and returns
+// the format, so migrations can be chained onto NewFormat
+func (f *Format) Migrate(from int, migration Migration) *Format {
+	if from < 1 || from >= f.Version {
+		panic(fmt.Sprintf("persist: %s migration from version %d is outside 1..%d", f.Name, from, f.Version-1))
+	}
+	f.migrations[from] = migration
+	return f
+}
+
+// Marshal encodes v in an envelope on a single line
+func (f *Format) Marshal(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Envelope{Format: f.Name, Version: f.Version, Data: data})
+}
+
+// MarshalIndent encodes v in an indented envelope
+func (f *Format) MarshalIndent(v interface{}) ([]byte, error) {
+	data, err := f.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, data, "", "  "); err != nil {
+		return nil, err
+	}
+	return indented.Bytes(), nil
+}
+
+// Unmarshal decodes a document into v, migrating it to the current version.
+// upgraded reports whether the stored document was not already current, in
+// which case the caller should write it back.
+func (f *Format) Unmarshal(data []byte, v interface{}) (upgraded bool, err error) {
+	doc, stored, enveloped, err := f.decode(data)
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(doc, v); err != nil {
+		return false, err
+	}
+	return !enveloped || stored != f.Version, nil
+}
+
+// ReadFile decodes the document at path into v. An outdated file is
+// rewritten at the current version; if migrations changed its data, the
+// original is kept next to it as path.v<version>.
+func (f *Format) ReadFile(path string, v interface{}) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	doc, stored, enveloped, err := f.decode(raw)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	if err := json.Unmarshal(doc, v); err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	if enveloped && stored == f.Version {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if stored < f.Version {
+		backup := fmt.Sprintf("%s.v%d", path, stored)
+		if err := writeFileAtomic(backup, raw, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to back up %s: %w", filepath.Base(path), err)
+		}
+	}
+	return f.WriteFile(path, v, info.Mode().Perm())
+}
+
+// WriteFile writes v to path in an indented envelope. A crash leaves either
+// the old or the new file, never a partial one.
+func (f *Format) WriteFile(path string, v interface{}, perm os.FileMode) error {
+	data, err := f.MarshalIndent(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", f.Name, err)
+	}
+	return writeFileAtomic(path, data, perm)
+}
+
+// decode unwraps data and migrates it to the current version. It returns
+// the version data was stored at, and whether it had an envelope.
+func (f *Format) decode(data []byte) (doc json.RawMessage, stored int, enveloped bool, err error) {
+	doc, version := json.RawMessage(data), 1
+
+	var envelope struct {
+		Format  *string         `json:"format"`
+		Version *int            `json:"version"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, &envelope); err != nil {
+			return nil, 0, false, err
+		}
+	}
+	if envelope.Format != nil && envelope.Version != nil && envelope.Data != nil {
+		if *envelope.Format != f.Name {
+			return nil, 0, false, fmt.Errorf("%w: %s, expected %s", ErrWrongFormat, *envelope.Format, f.Name)
+		}
+		doc, version, enveloped = envelope.Data, *envelope.Version, true
+	}
+	stored = version
+
+	if version > f.Version {
+		return nil, 0, false, fmt.Errorf("%w: %s version %d, this release reads up to %d", ErrNewerVersion, f.Name, version, f.Version)
+	}
+	for ; version < f.Version; version++ {
+		migration, ok := f.migrations[version]
+		if !ok {
+			return nil, 0, false, fmt.Errorf("%w for %s from version %d", ErrNoMigration, f.Name, version)
+		}
+		upgraded, err := migration(doc)
+		if err != nil {
+			return nil, 0, false, fmt.Errorf("failed to migrate %s from version %d: %w", f.Name, version, err)
+		}
+		doc = upgraded
+	}
+	return doc, stored, enveloped, nil
+}
+
+// writeFileAtomic writes data to a temporary file in path's directory,
+// syncs it and renames it over path
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to set file mode: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
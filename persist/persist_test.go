@@ -0,0 +1,100 @@
+package persist
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type profile struct {
+	Name  string   `json:"name"`
+	Langs []string `json:"langs"`
+}
+
+// profileFormat's version 1 stored a single "lang"
+func profileFormat() *Format {
+	return NewFormat("profile", 2).Migrate(1, func(data json.RawMessage) (json.RawMessage, error) {
+		var old struct {
+			Name string `json:"name"`
+			Lang string `json:"lang"`
+		}
+		if err := json.Unmarshal(data, &old); err != nil {
+			return nil, err
+		}
+		return json.Marshal(profile{Name: old.Name, Langs: []string{old.Lang}})
+	})
+}
+
+func TestUnmarshal(t *testing.T) {
+	format := profileFormat()
+	cases := []struct {
+		data     string
+		upgraded bool
+		want     string
+	}{
+		{`{"name":"ada","lang":"go"}`, true, ""},
+		{`{"format":"profile","version":1,"data":{"name":"ada","lang":"go"}}`, true, ""},
+		{`{"format":"profile","version":2,"data":{"name":"ada","langs":["go"]}}`, false, ""},
+		{`{"format":"profile","version":3,"data":{}}`, false, "newer than supported"},
+		{`{"format":"report","version":1,"data":{}}`, false, "wrong document format"},
+	}
+	for _, c := range cases {
+		var got profile
+		upgraded, err := format.Unmarshal([]byte(c.data), &got)
+		if c.want != "" {
+			if err == nil || !strings.Contains(err.Error(), c.want) {
+				t.Errorf("Unmarshal(%s) = %v, want %q", c.data, err, c.want)
+			}
+			continue
+		}
+		if err != nil || upgraded != c.upgraded || got.Name != "ada" || len(got.Langs) != 1 || got.Langs[0] != "go" {
+			t.Errorf("Unmarshal(%s) = %+v, %v, %v", c.data, got, upgraded, err)
+		}
+	}
+
+	gap := NewFormat("profile", 3).Migrate(1, func(data json.RawMessage) (json.RawMessage, error) { return data, nil })
+	if _, err := gap.Unmarshal([]byte(`{}`), &profile{}); !errors.Is(err, ErrNoMigration) {
+		t.Errorf("Expected ErrNoMigration, got %v", err)
+	}
+}
+
+func TestReadFileUpgrades(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ada.json")
+	legacy := `{"name":"ada","lang":"go"}`
+	if err := os.WriteFile(path, []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	format := profileFormat()
+	var got profile
+	if err := format.ReadFile(path, &got); err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+
+	upgraded, _ := os.ReadFile(path)
+	if !strings.Contains(string(upgraded), `"version": 2`) {
+		t.Errorf("Expected the file to be rewritten at version 2, got %s", upgraded)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the file mode to be kept, got %v", info.Mode().Perm())
+	}
+	if backup, err := os.ReadFile(path + ".v1"); err != nil || string(backup) != legacy {
+		t.Errorf("Expected the original kept as .v1, got %q (%v)", backup, err)
+	}
+
+	// A current file is left alone
+	if err := format.ReadFile(path, &got); err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if again, _ := os.ReadFile(path); string(again) != string(upgraded) {
+		t.Error("Expected a current file not to be rewritten")
+	}
+
+	if err := format.ReadFile(filepath.Join(dir, "missing.json"), &got); !os.IsNotExist(err) {
+		t.Errorf("Expected a not-exist error, got %v", err)
+	}
+}
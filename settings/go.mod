@@ -0,0 +1,3 @@
+module github.com/sakibmulla/agentic-ai/settings
+
+go 1.21
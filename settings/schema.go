@@ -0,0 +1,265 @@
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Kind is the type of value a setting takes
+type Kind string
+
+// Setting kinds
+const (
+	String Kind = "string"
+	Int    Kind = "integer"
+	Float  Kind = "number"
+	Bool   Kind = "bool"
+)
+
+// Key declares one setting a program reads
+type Key struct {
+	Name string
+	Kind Kind
+	// Secret values are masked by Show
+	Secret bool
+}
+
+// Schema lists the settings a program reads, so they can be checked and
+// shown
+type Schema []Key
+
+// Common lists the settings every day reads through the shared packages:
+// the API key and endpoint, logging and the cost budget
+var Common = Schema{
+	{Name: "LLM_PROVIDER", Kind: String},
+	{Name: "OPENAI_API_KEY", Kind: String, Secret: true},
+	{Name: "OPENAI_API_TYPE", Kind: String},
+	{Name: "OPENAI_BASE_URL", Kind: String},
+	{Name: "OPENAI_API_VERSION", Kind: String},
+	{Name: "OPENAI_DEPLOYMENT", Kind: String},
+	{Name: "OLLAMA_URL", Kind: String},
+	{Name: "OLLAMA_MODEL", Kind: String},
+	{Name: "OLLAMA_EMBED_MODEL", Kind: String},
+	{Name: "OLLAMA_TIMEOUT_SECONDS", Kind: Int},
+	{Name: "LOG_LEVEL", Kind: String},
+	{Name: "LOG_FORMAT", Kind: String},
+	{Name: "BUDGET", Kind: String},
+	{Name: "BUDGET_DOWNGRADES", Kind: String},
+	{Name: "COST_LEDGER_PATH", Kind: String},
+	{Name: "PRICING_PATH", Kind: String},
+}
+
+// With returns a copy of s with keys added
+func (s Schema) With(keys ...Key) Schema {
+	return append(append(Schema{}, s...), keys...)
+}
+
+// Problem is something wrong with the settings. Warnings don't stop a
+// program from starting.
+type Problem struct {
+	Key     string
+	Source  string
+	Message string
+	Warning bool
+}
+
+func (p Problem) String() string {
+	text := p.Key + ": " + p.Message
+	if p.Source != "" {
+		text += " (" + p.Source + ")"
+	}
+	return text
+}
+
+// Validate checks that every setting in the environment parses as its
+// kind, and that the config file Load read sets only known keys. Unknown
+// keys are warnings, since the file may be shared with other programs,
+// unless they look like a typo of a known one.
+func (s Schema) Validate() []Problem {
+	var problems []Problem
+	for _, key := range s {
+		value, source := Lookup(key.Name)
+		if source == "" {
+			continue
+		}
+		if message := checkKind(key.Kind, value); message != "" {
+			problems = append(problems, Problem{Key: key.Name, Source: source, Message: message})
+		}
+	}
+
+	file, name := Loaded()
+	if file == nil {
+		return problems
+	}
+	known := make(map[string]bool, len(s))
+	for _, key := range s {
+		known[key.Name] = true
+	}
+	resolved, _ := file.Resolve(name)
+	keys := make([]string, 0, len(resolved))
+	for key := range resolved {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if known[key] {
+			continue
+		}
+		problem := Problem{Key: key, Source: resolved[key].Source, Message: "unknown setting, not read by this program", Warning: true}
+		if suggestion := s.closest(key); suggestion != "" {
+			problem.Message = fmt.Sprintf("unknown setting; did you mean %s?", strings.ToLower(suggestion))
+			problem.Warning = false
+		}
+		problems = append(problems, problem)
+	}
+	return problems
+}
+
+// Err returns the problems that aren't warnings as one error, or nil
+func Err(problems []Problem) error {
+	var lines []string
+	for _, problem := range problems {
+		if !problem.Warning {
+			lines = append(lines, problem.String())
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid configuration:\n  %s", strings.Join(lines, "\n  "))
+}
+
+// checkKind returns what is wrong with value for a setting of kind, or ""
+func checkKind(kind Kind, value string) string {
+	var err error
+	switch kind {
+	case Int:
+		_, err = strconv.Atoi(value)
+	case Float:
+		_, err = strconv.ParseFloat(value, 64)
+	case Bool:
+		_, err = strconv.ParseBool(value)
+	}
+	if err != nil {
+		return fmt.Sprintf("expected %s %s, got %q", article(kind), kind, value)
+	}
+	return ""
+}
+
+func article(kind Kind) string {
+	if kind == Int {
+		return "an"
+	}
+	return "a"
+}
+
+// closest returns the known key within two edits of name, if there is one
+func (s Schema) closest(name string) string {
+	best, bestDistance := "", 3
+	for _, key := range s {
+		if d := editDistance(name, key.Name); d < bestDistance {
+			best, bestDistance = key.Name, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// Show writes each setting's value and where it came from, with secrets
+// masked. Settings left at the program's default show as such.
+func (s Schema) Show(w io.Writer) {
+	if file, name := Loaded(); file != nil {
+		if name == "" {
+			name = "(none)"
+		}
+		fmt.Fprintf(w, "Config file: %s, profile: %s\n\n", file.Path, name)
+	} else {
+		fmt.Fprintf(w, "Config file: none\n\n")
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tVALUE\tSOURCE")
+	for _, key := range s {
+		value, source := Lookup(key.Name)
+		switch {
+		case source == "":
+			value, source = "-", "default"
+		case key.Secret && value != "":
+			value = mask(value)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", strings.ToLower(key.Name), value, source)
+	}
+	tw.Flush()
+}
+
+// mask hides all but the end of a secret
+func mask(value string) string {
+	if len(value) <= 8 {
+		return "****"
+	}
+	return "****" + value[len(value)-4:]
+}
+
+// Start is called with a program's arguments after Load. For `config show`
+// or `config validate` it runs that command and reports that the program
+// should stop; otherwise it checks the settings so the program can go on.
+func (s Schema) Start(args []string, w io.Writer) (bool, error) {
+	if len(args) > 0 && args[0] == "config" {
+		return false, s.Command(args[1:], w)
+	}
+	return true, Err(s.Validate())
+}
+
+// Command runs `config show` or `config validate` with args after
+// "config". Validate prints every problem and fails if any isn't a
+// warning; checks beyond the schema are left to the program.
+func (s Schema) Command(args []string, w io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("usage: config show | config validate")
+	}
+	switch args[0] {
+	case "show":
+		s.Show(w)
+		return nil
+	case "validate":
+		problems := s.Validate()
+		for _, problem := range problems {
+			level := "error"
+			if problem.Warning {
+				level = "warning"
+			}
+			fmt.Fprintf(w, "%s: %s\n", level, problem)
+		}
+		if err := Err(problems); err != nil {
+			return errors.New("configuration is invalid")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown config command %q: use show or validate", args[0])
+	}
+}
@@ -0,0 +1,302 @@
+// Package settings reads the config file the agents in this course share.
+// The file holds the same settings as the environment, in TOML, with named
+// profiles layered over the top-level values:
+//
+//	openai_model = "gpt-3.5-turbo"
+//	max_history = 10
+//
+//	[profiles.prod]
+//	openai_model = "gpt-4o-mini"
+//	budget = "daily=5,monthly=100"
+//
+// Load applies the file like a .env file: each value becomes an
+// environment variable unless one is already set, so the environment (and
+// .env) overrides the profile, which overrides the top level.
+package settings
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultFile is read when CONFIG_FILE isn't set, if it exists
+const DefaultFile = "config.toml"
+
+// profilesTable holds the named profiles: [profiles.<name>]
+const profilesTable = "profiles"
+
+// Setting is one value in a config file
+type Setting struct {
+	// Key is the environment variable it sets, e.g. MAX_HISTORY
+	Key   string
+	Value string
+	// Source is where it was set, e.g. "config.toml:12 [profiles.prod]"
+	Source string
+}
+
+// File is a parsed config file
+type File struct {
+	Path     string
+	Base     map[string]Setting
+	Profiles map[string]map[string]Setting
+}
+
+// ReadFile parses the config file at path
+func ReadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return Parse(path, data)
+}
+
+// Parse parses a config file's contents. It reads the subset of TOML
+// settings need: comments, top-level keys, [profiles.<name>] tables and
+// string, integer, float and boolean values. Keys are case-insensitive.
+func Parse(path string, data []byte) (*File, error) {
+	file := &File{Path: path, Base: make(map[string]Setting), Profiles: make(map[string]map[string]Setting)}
+	table, tableName := file.Base, ""
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+		where := fmt.Sprintf("%s:%d", path, lineNo)
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") || strings.HasPrefix(line, "[[") {
+				return nil, fmt.Errorf("%s: invalid table header %q: use [profiles.<name>]", where, line)
+			}
+			parts := strings.Split(strings.TrimSpace(line[1:len(line)-1]), ".")
+			if len(parts) != 2 || parts[0] != profilesTable || !isBareKey(parts[1]) {
+				return nil, fmt.Errorf("%s: unsupported table %s: only [profiles.<name>] tables are allowed", where, line)
+			}
+			tableName = parts[1]
+			if _, exists := file.Profiles[tableName]; exists {
+				return nil, fmt.Errorf("%s: profile %q is defined twice", where, tableName)
+			}
+			table = make(map[string]Setting)
+			file.Profiles[tableName] = table
+			continue
+		}
+
+		name, raw, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !isBareKey(name) {
+			return nil, fmt.Errorf("%s: expected key = value, got %q", where, line)
+		}
+		value, err := parseValue(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", where, name, err)
+		}
+
+		key := strings.ToUpper(name)
+		if _, exists := table[key]; exists {
+			return nil, fmt.Errorf("%s: %s is set twice", where, name)
+		}
+		source := where
+		if tableName != "" {
+			source += fmt.Sprintf(" [%s.%s]", profilesTable, tableName)
+		}
+		table[key] = Setting{Key: key, Value: value, Source: source}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return file, nil
+}
+
+// stripComment removes a # comment that isn't inside a string
+func stripComment(line string) string {
+	var quote rune
+	escaped := false
+	for i, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '"' && r == '\\':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// isBareKey reports whether name is a TOML bare key
+func isBareKey(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r == '_' || r == '-' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// parseValue returns a TOML value as the text an environment variable
+// would hold
+func parseValue(raw string) (string, error) {
+	switch {
+	case raw == "":
+		return "", errors.New("missing value")
+	case strings.HasPrefix(raw, `"`):
+		value, err := strconv.Unquote(raw)
+		if err != nil || !strings.HasSuffix(raw, `"`) {
+			return "", fmt.Errorf("invalid string %s", raw)
+		}
+		return value, nil
+	case strings.HasPrefix(raw, "'"):
+		if len(raw) < 2 || !strings.HasSuffix(raw, "'") || strings.Contains(raw[1:len(raw)-1], "'") {
+			return "", fmt.Errorf("invalid string %s", raw)
+		}
+		return raw[1 : len(raw)-1], nil
+	case raw == "true" || raw == "false":
+		return raw, nil
+	case strings.HasPrefix(raw, "["), strings.HasPrefix(raw, "{"):
+		return "", errors.New("arrays and inline tables aren't supported: use a string, e.g. \"a,b\"")
+	}
+	number := strings.ReplaceAll(raw, "_", "")
+	if _, err := strconv.ParseInt(number, 10, 64); err == nil {
+		return number, nil
+	}
+	if _, err := strconv.ParseFloat(number, 64); err == nil {
+		return number, nil
+	}
+	return "", fmt.Errorf("invalid value %s: quote strings", raw)
+}
+
+// ProfileNames returns the file's profiles, sorted
+func (f *File) ProfileNames() []string {
+	names := make([]string, 0, len(f.Profiles))
+	for name := range f.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolve returns the top-level settings with profile's laid over them.
+// An empty profile is the top level alone.
+func (f *File) Resolve(profile string) (map[string]Setting, error) {
+	resolved := make(map[string]Setting, len(f.Base))
+	for key, setting := range f.Base {
+		resolved[key] = setting
+	}
+	if profile == "" {
+		return resolved, nil
+	}
+	overrides, ok := f.Profiles[profile]
+	if !ok {
+		if len(f.Profiles) == 0 {
+			return nil, fmt.Errorf("profile %q not found: %s has no profiles", profile, f.Path)
+		}
+		return nil, fmt.Errorf("profile %q not found in %s: use %s", profile, f.Path, strings.Join(f.ProfileNames(), ", "))
+	}
+	for key, setting := range overrides {
+		resolved[key] = setting
+	}
+	return resolved, nil
+}
+
+var (
+	mu sync.Mutex
+	// loaded is the file Load read, if any, and loadedProfile the profile used
+	loaded        *File
+	loadedProfile string
+	// applied holds the settings Load put in the environment
+	applied = make(map[string]Setting)
+)
+
+// Load reads the config file named by CONFIG_FILE, or config.toml if it
+// exists, and sets the environment variables that CONFIG_PROFILE's settings
+// name and that aren't set already. Run it after loading .env, so .env
+// overrides the file too. Without a file it does nothing. Calling
+// it again is harmless.
+func Load() error {
+	path := os.Getenv("CONFIG_FILE")
+	if path == "" {
+		if _, err := os.Stat(DefaultFile); errors.Is(err, fs.ErrNotExist) {
+			if name := os.Getenv("CONFIG_PROFILE"); name != "" {
+				return fmt.Errorf("CONFIG_PROFILE is %q but there is no %s (set CONFIG_FILE to use another file)", name, DefaultFile)
+			}
+			return nil
+		}
+		path = DefaultFile
+	}
+
+	file, err := ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return Apply(file, os.Getenv("CONFIG_PROFILE"))
+}
+
+// Apply sets the environment variables that file's settings for name set,
+// where they aren't set already. Empty variables count as unset, as they do
+// for the programs reading them.
+func Apply(file *File, name string) error {
+	resolved, err := file.Resolve(name)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for key, setting := range resolved {
+		if previous, ok := applied[key]; ok && os.Getenv(key) == previous.Value {
+			// Set by an earlier Load; let this file's value replace it
+			os.Unsetenv(key)
+			delete(applied, key)
+		}
+		if os.Getenv(key) != "" {
+			continue
+		}
+		if err := os.Setenv(key, setting.Value); err != nil {
+			return fmt.Errorf("failed to set %s: %w", key, err)
+		}
+		applied[key] = setting
+	}
+	loaded, loadedProfile = file, name
+	return nil
+}
+
+// Loaded returns the file and profile Load applied, if any
+func Loaded() (*File, string) {
+	mu.Lock()
+	defer mu.Unlock()
+	return loaded, loadedProfile
+}
+
+// Lookup returns key's value and where it came from: the Source of the
+// config file setting, "environment", or "" when it isn't set or is empty
+func Lookup(key string) (string, string) {
+	value := os.Getenv(key)
+	if value == "" {
+		return "", ""
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if setting, ok := applied[key]; ok && setting.Value == value {
+		return value, setting.Source
+	}
+	return value, "environment"
+}
@@ -0,0 +1,163 @@
+package settings
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const sample = `# shared by every profile
+openai_model = "gpt-3.5-turbo"   # the cheap one
+max_history = 10
+enable_tools = false
+note = 'a # in a literal string'
+
+[profiles.dev]
+log_level = "debug"
+
+[profiles.prod]
+openai_model = "gpt-4o-mini"
+max_history = 1_000
+budget = "daily=5,monthly=100"
+`
+
+func TestParse(t *testing.T) {
+	file, err := Parse("config.toml", []byte(sample))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if got := file.ProfileNames(); strings.Join(got, ",") != "dev,prod" {
+		t.Errorf("Expected profiles dev and prod, got %v", got)
+	}
+
+	prod, err := file.Resolve("prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{"OPENAI_MODEL": "gpt-4o-mini", "MAX_HISTORY": "1000", "ENABLE_TOOLS": "false", "NOTE": "a # in a literal string"} {
+		if prod[key].Value != want {
+			t.Errorf("Expected %s = %q, got %q", key, want, prod[key].Value)
+		}
+	}
+	if source := prod["OPENAI_MODEL"].Source; source != "config.toml:11 [profiles.prod]" {
+		t.Errorf("Expected the profile line as the source, got %q", source)
+	}
+	if base, _ := file.Resolve(""); base["OPENAI_MODEL"].Value != "gpt-3.5-turbo" || base["LOG_LEVEL"].Value != "" {
+		t.Errorf("Expected the top level alone without a profile, got %v", base)
+	}
+	if _, err := file.Resolve("staging"); err == nil || !strings.Contains(err.Error(), "use dev, prod") {
+		t.Errorf("Expected the profiles listed for an unknown one, got %v", err)
+	}
+
+	for _, bad := range []string{
+		"max_history",
+		"[tools]\nx = 1",
+		"[profiles.dev]\n[profiles.dev]",
+		"model = gpt-4o",
+		"keys = [1, 2]",
+		"a = 1\nA = 2",
+	} {
+		if _, err := Parse("bad.toml", []byte(bad)); err == nil || !strings.HasPrefix(err.Error(), "bad.toml:") {
+			t.Errorf("Expected %q rejected with its line, got %v", bad, err)
+		}
+	}
+}
+
+var testSchema = Schema{
+	{Name: "OPENAI_API_KEY", Kind: String, Secret: true},
+	{Name: "OPENAI_MODEL", Kind: String},
+	{Name: "MAX_HISTORY", Kind: Int},
+	{Name: "TEMPERATURE", Kind: Float},
+	{Name: "ENABLE_TOOLS", Kind: Bool},
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "agents.toml")
+	config := "openai_model = \"gpt-3.5-turbo\"\nmax_histroy = 5\nlog_level = \"debug\"\n\n[profiles.prod]\nopenai_model = \"gpt-4o-mini\"\nenable_tools = \"yes\"\n"
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("CONFIG_PROFILE", "prod")
+	t.Setenv("OPENAI_API_KEY", "sk-test-1234567890")
+	// Empty counts as unset
+	for _, key := range []string{"MAX_HISTORY", "OPENAI_MODEL", "ENABLE_TOOLS", "LOG_LEVEL", "MAX_HISTROY"} {
+		t.Setenv(key, "")
+	}
+
+	if err := Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if value, source := Lookup("OPENAI_MODEL"); value != "gpt-4o-mini" || !strings.HasSuffix(source, "[profiles.prod]") {
+		t.Errorf("Expected the profile's model, got %q from %q", value, source)
+	}
+	if _, source := Lookup("OPENAI_API_KEY"); source != "environment" {
+		t.Errorf("Expected the environment to win, got %q", source)
+	}
+
+	problems := testSchema.Validate()
+	var messages []string
+	for _, problem := range problems {
+		messages = append(messages, problem.String())
+	}
+	text := strings.Join(messages, "\n")
+	for _, want := range []string{
+		`ENABLE_TOOLS: expected a bool, got "yes" (` + path + `:7 [profiles.prod])`,
+		"MAX_HISTROY: unknown setting; did you mean max_history?",
+		"LOG_LEVEL: unknown setting, not read by this program",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %q among the problems, got:\n%s", want, text)
+		}
+	}
+	if err := Err(problems); err == nil || strings.Contains(err.Error(), "LOG_LEVEL") {
+		t.Errorf("Expected errors without the warning, got %v", err)
+	}
+
+	var out bytes.Buffer
+	testSchema.Show(&out)
+	shown := out.String()
+	if !strings.Contains(shown, "profile: prod") || !strings.Contains(shown, "****7890") || strings.Contains(shown, "sk-test") {
+		t.Errorf("Expected the profile and a masked key, got:\n%s", shown)
+	}
+	if !strings.Contains(shown, "temperature     -") {
+		t.Errorf("Expected unset settings shown as defaults, got:\n%s", shown)
+	}
+	if err := testSchema.Command([]string{"validate"}, &out); err == nil {
+		t.Error("Expected validate to fail")
+	}
+
+	// Without the profile, the top level is used
+	t.Setenv("CONFIG_PROFILE", "")
+	if err := Load(); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := Lookup("OPENAI_MODEL"); value != "gpt-3.5-turbo" {
+		t.Errorf("Expected reloading to replace the profile's model, got %q", value)
+	}
+
+	t.Setenv("CONFIG_PROFILE", "qa")
+	if err := Load(); err == nil || !strings.Contains(err.Error(), "use prod") {
+		t.Errorf("Expected an unknown profile rejected, got %v", err)
+	}
+}
+
+func TestStart(t *testing.T) {
+	t.Setenv("MAX_HISTORY", "ten")
+
+	schema := Common.With(Key{Name: "MAX_HISTORY", Kind: Int})
+	if len(schema) != len(Common)+1 || schema[len(Common)].Name != "MAX_HISTORY" {
+		t.Fatalf("Expected With to add MAX_HISTORY after Common, got %d keys", len(schema))
+	}
+	if run, err := schema.Start([]string{"chat"}, &bytes.Buffer{}); !run || err == nil || !strings.Contains(err.Error(), "MAX_HISTORY") {
+		t.Errorf("Expected the program to go on with a bad setting reported, got %v, %v", run, err)
+	}
+
+	var out bytes.Buffer
+	if run, err := schema.Start([]string{"config", "show"}, &out); run || err != nil || !strings.Contains(out.String(), "max_history") {
+		t.Errorf("Expected config show to stop the program, got %v, %v:\n%s", run, err, out.String())
+	}
+}
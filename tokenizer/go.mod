@@ -0,0 +1,3 @@
+module github.com/sakibmulla/agentic-ai/tokenizer
+
+go 1.21
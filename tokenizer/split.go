@@ -0,0 +1,221 @@
+package tokenizer
+
+import "unicode"
+
+// A splitter breaks text into the pieces BPE merges within. Each is a
+// hand-written matcher for one of tiktoken's patterns, because Go's regexp
+// has no lookahead for their \s+(?!\S) alternative.
+type splitter func(text string) []string
+
+// splitText cuts text with match, which returns the length of the piece
+// starting at runes[i]
+func splitText(text string, match func(runes []rune, i int) int) []string {
+	runes := []rune(text)
+	pieces := make([]string, 0, len(runes)/3+1)
+	for i := 0; i < len(runes); {
+		n := match(runes, i)
+		pieces = append(pieces, string(runes[i:i+n]))
+		i += n
+	}
+	return pieces
+}
+
+// splitGPT2 follows the r50k_base and p50k_base pattern:
+//
+//	's|'t|'re|'ve|'m|'ll|'d| ?\p{L}+| ?\p{N}+| ?[^\s\p{L}\p{N}]+|\s+(?!\S)|\s+
+func splitGPT2(text string) []string {
+	return splitText(text, func(r []rune, i int) int {
+		if n := contraction(r, i, false); n > 0 {
+			return n
+		}
+		start := i
+		if r[i] == ' ' && i+1 < len(r) {
+			start = i + 1
+		}
+		for _, class := range []func(rune) bool{unicode.IsLetter, unicode.IsNumber, isSymbol} {
+			if class(r[start]) {
+				return run(r, start, class) - i
+			}
+		}
+		return whitespace(r, i, false)
+	})
+}
+
+// splitCL100K follows the cl100k_base pattern:
+//
+//	(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}|
+//	 ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+
+func splitCL100K(text string) []string {
+	return splitText(text, func(r []rune, i int) int {
+		if n := contraction(r, i, true); n > 0 {
+			return n
+		}
+		if isWordPrefix(r[i]) && i+1 < len(r) && unicode.IsLetter(r[i+1]) {
+			return run(r, i+1, unicode.IsLetter) - i
+		}
+		if unicode.IsLetter(r[i]) {
+			return run(r, i, unicode.IsLetter) - i
+		}
+		if unicode.IsNumber(r[i]) {
+			return digits(r, i)
+		}
+		if n := punctuation(r, i, isNewline); n > 0 {
+			return n
+		}
+		return whitespace(r, i, true)
+	})
+}
+
+// splitO200K follows the o200k_base pattern, which splits words at case
+// changes and keeps a trailing contraction with its word:
+//
+//	[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?|
+//	[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?|
+//	\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|\s+(?!\S)|\s+
+func splitO200K(text string) []string {
+	return splitText(text, func(r []rune, i int) int {
+		for _, word := range []func([]rune, int) int{lowerWord, upperWord} {
+			for _, start := range []int{i + 1, i} {
+				if start == i+1 && !isWordPrefix(r[i]) {
+					continue
+				}
+				if end := word(r, start); end > start {
+					return end + contraction(r, end, true) - i
+				}
+			}
+		}
+		if unicode.IsNumber(r[i]) {
+			return digits(r, i)
+		}
+		if n := punctuation(r, i, func(c rune) bool { return isNewline(c) || c == '/' }); n > 0 {
+			return n
+		}
+		return whitespace(r, i, true)
+	})
+}
+
+// lowerWord matches o200k_base's [upper]*[lower]+ at r[i] and returns where
+// it ends, or i. The classes overlap, so like the regex it backtracks into
+// the greedy upper run until a lower-class rune follows.
+func lowerWord(r []rune, i int) int {
+	for k := run(r, i, isUpperClass); k >= i; k-- {
+		if k < len(r) && isLowerClass(r[k]) {
+			return run(r, k, isLowerClass)
+		}
+	}
+	return i
+}
+
+// upperWord matches o200k_base's [upper]+[lower]* at r[i] and returns where
+// it ends, or i
+func upperWord(r []rune, i int) int {
+	end := run(r, i, isUpperClass)
+	if end == i {
+		return i
+	}
+	return run(r, end, isLowerClass)
+}
+
+// contraction matches 's, 't, 're, 've, 'm, 'll or 'd at r[i], returning
+// its length or 0
+func contraction(r []rune, i int, foldCase bool) int {
+	if i >= len(r) || r[i] != '\'' {
+		return 0
+	}
+	for _, suffix := range []string{"s", "t", "re", "ve", "m", "ll", "d"} {
+		n := 1
+		for _, want := range suffix {
+			if i+n >= len(r) {
+				n = 0
+				break
+			}
+			got := r[i+n]
+			if foldCase {
+				got = unicode.ToLower(got)
+			}
+			if got != want {
+				n = 0
+				break
+			}
+			n++
+		}
+		if n > 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+// digits matches \p{N}{1,3} at r[i]
+func digits(r []rune, i int) int {
+	n := 0
+	for n < 3 && i+n < len(r) && unicode.IsNumber(r[i+n]) {
+		n++
+	}
+	return n
+}
+
+// punctuation matches " ?[^\s\p{L}\p{N}]+" and then any runes trailing
+// allows at r[i], returning its length or 0
+func punctuation(r []rune, i int, trailing func(rune) bool) int {
+	start := i
+	if r[i] == ' ' && i+1 < len(r) && isSymbol(r[i+1]) {
+		start = i + 1
+	}
+	if !isSymbol(r[start]) {
+		return 0
+	}
+	return run(r, run(r, start, isSymbol), trailing) - i
+}
+
+// whitespace matches \s+(?!\S)|\s+ at r[i], preceded by \s*[\r\n]+ when
+// newlines is set, or a single rune that nothing else matched
+func whitespace(r []rune, i int, newlines bool) int {
+	end := run(r, i, unicode.IsSpace)
+	if end == i {
+		return 1
+	}
+	// \s*[\r\n]+ ends with the run's last newline
+	for k := end - 1; newlines && k >= i; k-- {
+		if isNewline(r[k]) {
+			return k + 1 - i
+		}
+	}
+	// \s+(?!\S) leaves the last space to lead the next word
+	if end < len(r) && end-i > 1 {
+		return end - 1 - i
+	}
+	return end - i
+}
+
+// run returns where the run of runes in class starting at r[i] ends
+func run(r []rune, i int, class func(rune) bool) int {
+	for i < len(r) && class(r[i]) {
+		i++
+	}
+	return i
+}
+
+// isWordPrefix reports whether c may lead a word: [^\r\n\p{L}\p{N}]
+func isWordPrefix(c rune) bool {
+	return !isNewline(c) && !unicode.IsLetter(c) && !unicode.IsNumber(c)
+}
+
+// isSymbol reports whether c is [^\s\p{L}\p{N}]
+func isSymbol(c rune) bool {
+	return !unicode.IsSpace(c) && !unicode.IsLetter(c) && !unicode.IsNumber(c)
+}
+
+func isNewline(c rune) bool {
+	return c == '\r' || c == '\n'
+}
+
+// isUpperClass reports whether c is [\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]
+func isUpperClass(c rune) bool {
+	return unicode.In(c, unicode.Lu, unicode.Lt, unicode.Lm, unicode.Lo, unicode.M)
+}
+
+// isLowerClass reports whether c is [\p{Ll}\p{Lm}\p{Lo}\p{M}]
+func isLowerClass(c rune) bool {
+	return unicode.In(c, unicode.Ll, unicode.Lm, unicode.Lo, unicode.M)
+}
@@ -0,0 +1,271 @@
+// Package tokenizer counts tokens the way OpenAI's models do, with the
+// byte-pair encodings tiktoken uses. Counting characters (len/4) under-counts
+// code, numbers and non-English text badly enough to overflow a context
+// window; these counts match tiktoken's.
+//
+// The merge ranks of each encoding are read from a tiktoken rank file,
+// <name>.tiktoken, in DataDir. Those are the files tiktoken downloads from
+// https://openaipublic.blob.core.windows.net/encodings/. Without one, an
+// encoding still splits text exactly as tiktoken does but estimates each
+// piece, erring towards too many tokens rather than too few.
+package tokenizer
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Encoding names
+const (
+	R50KBase   = "r50k_base"
+	P50KBase   = "p50k_base"
+	CL100KBase = "cl100k_base"
+	O200KBase  = "o200k_base"
+)
+
+// MessageOverhead is how many tokens chat models add around each message
+// for its role and delimiters
+const MessageOverhead = 3
+
+// ErrUnknownEncoding is returned for an encoding name that doesn't exist
+var ErrUnknownEncoding = errors.New("unknown encoding")
+
+// TokenCounter counts the tokens text takes up
+type TokenCounter interface {
+	Count(text string) int
+}
+
+// splitters holds each encoding's pre-tokenizer
+var splitters = map[string]splitter{
+	R50KBase:   splitGPT2,
+	P50KBase:   splitGPT2,
+	CL100KBase: splitCL100K,
+	O200KBase:  splitO200K,
+}
+
+// modelPrefixes maps model names, by prefix, to their encoding. Longer
+// prefixes come first so "gpt-4o" isn't taken for "gpt-4".
+var modelPrefixes = []struct {
+	prefix   string
+	encoding string
+}{
+	{"gpt-4o", O200KBase},
+	{"gpt-4.1", O200KBase},
+	{"gpt-4.5", O200KBase},
+	{"gpt-5", O200KBase},
+	{"chatgpt-4o", O200KBase},
+	{"o1", O200KBase},
+	{"o3", O200KBase},
+	{"o4", O200KBase},
+	{"gpt-4", CL100KBase},
+	{"gpt-3.5", CL100KBase},
+	{"text-embedding-", CL100KBase},
+	{"text-davinci-00", P50KBase},
+	{"code-", P50KBase},
+	{"davinci", R50KBase},
+	{"curie", R50KBase},
+	{"babbage", R50KBase},
+	{"ada", R50KBase},
+}
+
+// Encoding is one of tiktoken's byte-pair encodings
+type Encoding struct {
+	name  string
+	split splitter
+	// ranks maps each token's bytes to its merge priority; nil when the
+	// rank file couldn't be loaded and pieces are estimated
+	ranks map[string]int
+}
+
+var (
+	encodingsMu sync.Mutex
+	encodings   = make(map[string]*Encoding)
+)
+
+// DataDir is where rank files are looked for: $TIKTOKEN_DATA_DIR, or
+// tiktoken under the user's cache directory
+func DataDir() string {
+	if dir := os.Getenv("TIKTOKEN_DATA_DIR"); dir != "" {
+		return dir
+	}
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "tiktoken"
+	}
+	return filepath.Join(cache, "tiktoken")
+}
+
+// GetEncoding returns the named encoding, loading its rank file once. If the
+// file can't be loaded it returns an estimating encoding with the error.
+func GetEncoding(name string) (*Encoding, error) {
+	split, ok := splitters[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEncoding, name)
+	}
+
+	encodingsMu.Lock()
+	defer encodingsMu.Unlock()
+	if enc, ok := encodings[name]; ok {
+		return enc, nil
+	}
+
+	enc := &Encoding{name: name, split: split}
+	path := filepath.Join(DataDir(), name+".tiktoken")
+	file, err := os.Open(path)
+	if err != nil {
+		encodings[name] = enc
+		return enc, fmt.Errorf("failed to open rank file: %w", err)
+	}
+	defer file.Close()
+
+	ranks, err := LoadRanks(file)
+	if err != nil {
+		encodings[name] = enc
+		return enc, fmt.Errorf("failed to load %s: %w", path, err)
+	}
+	enc.ranks = ranks
+	encodings[name] = enc
+	return enc, nil
+}
+
+// EncodingForModel returns the name of the encoding model uses. Unknown
+// models get cl100k_base, which current chat models share or resemble.
+func EncodingForModel(model string) string {
+	for _, entry := range modelPrefixes {
+		if strings.HasPrefix(model, entry.prefix) {
+			return entry.encoding
+		}
+	}
+	return CL100KBase
+}
+
+// ForModel returns model's encoding, estimating if its rank file is missing
+func ForModel(model string) *Encoding {
+	enc, _ := GetEncoding(EncodingForModel(model))
+	return enc
+}
+
+// NewEncoding creates an encoding named like one of tiktoken's from ranks,
+// for callers that load rank files themselves
+func NewEncoding(name string, ranks map[string]int) (*Encoding, error) {
+	split, ok := splitters[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEncoding, name)
+	}
+	return &Encoding{name: name, split: split, ranks: ranks}, nil
+}
+
+// LoadRanks reads a tiktoken rank file: one base64 token and its rank per line
+func LoadRanks(r io.Reader) (map[string]int, error) {
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		encoded, rankText, ok := strings.Cut(text, " ")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected a token and a rank", line)
+		}
+		token, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rank, err := strconv.Atoi(rankText)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ranks, nil
+}
+
+// Name returns the encoding's name, such as cl100k_base
+func (e *Encoding) Name() string {
+	return e.name
+}
+
+// Exact reports whether counts come from the real merge ranks rather than
+// estimates
+func (e *Encoding) Exact() bool {
+	return e.ranks != nil
+}
+
+// Count returns how many tokens text encodes to
+func (e *Encoding) Count(text string) int {
+	count := 0
+	for _, piece := range e.split(text) {
+		switch {
+		case e.ranks == nil:
+			count += estimatePiece(piece)
+		case e.hasToken(piece):
+			count++
+		default:
+			count += e.mergeCount(piece)
+		}
+	}
+	return count
+}
+
+// hasToken reports whether piece is a single token
+func (e *Encoding) hasToken(piece string) bool {
+	_, ok := e.ranks[piece]
+	return ok
+}
+
+// mergeCount byte-pair encodes piece and returns the number of tokens. Like
+// tiktoken it repeatedly merges the adjacent pair with the lowest rank.
+func (e *Encoding) mergeCount(piece string) int {
+	// bounds[i] is where the i'th part starts; the last entry is the end
+	bounds := make([]int, len(piece)+1)
+	for i := range bounds {
+		bounds[i] = i
+	}
+	for len(bounds) > 2 {
+		best, at := -1, -1
+		for i := 0; i+2 < len(bounds); i++ {
+			if rank, ok := e.ranks[piece[bounds[i]:bounds[i+2]]]; ok && (at < 0 || rank < best) {
+				best, at = rank, i
+			}
+		}
+		if at < 0 {
+			break
+		}
+		bounds = append(bounds[:at+1], bounds[at+2:]...)
+	}
+	return len(bounds) - 1
+}
+
+// estimatePiece guesses the tokens in one piece without merge ranks. Common
+// English words, with their leading space, are one token; longer runs of
+// letters or symbols split every few bytes, and non-ASCII text takes about
+// a token per character.
+func estimatePiece(piece string) int {
+	letters := strings.TrimLeft(piece, " ")
+	if letters == "" {
+		return 1
+	}
+	ascii := true
+	for i := 0; i < len(letters); i++ {
+		if letters[i] >= 0x80 {
+			ascii = false
+			break
+		}
+	}
+	if !ascii {
+		return (len(letters) + 2) / 3
+	}
+	return (len(letters) + 4) / 5
+}
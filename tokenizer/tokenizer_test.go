@@ -0,0 +1,128 @@
+package tokenizer
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// resetEncodings forgets loaded encodings and points DataDir at dir
+func resetEncodings(t *testing.T, dir string) {
+	t.Setenv("TIKTOKEN_DATA_DIR", dir)
+	encodingsMu.Lock()
+	encodings = make(map[string]*Encoding)
+	encodingsMu.Unlock()
+}
+
+func TestSplit(t *testing.T) {
+	cases := []struct {
+		split splitter
+		text  string
+		want  []string
+	}{
+		{splitCL100K, "Hello world", []string{"Hello", " world"}},
+		{splitCL100K, "I'm 12345 years", []string{"I", "'m", " ", "123", "45", " years"}},
+		{splitCL100K, "a   b", []string{"a", "  ", " b"}},
+		{splitCL100K, "x\n\n  y", []string{"x", "\n\n", " ", " y"}},
+		{splitCL100K, `say "hi"!`, []string{"say", ` "`, "hi", `"!`}},
+		{splitCL100K, `("hi")`, []string{`("`, "hi", `")`}},
+		{splitCL100K, `x("hi`, []string{"x", `("`, "hi"}},
+		{splitCL100K, `"hi`, []string{`"hi`}},
+		{splitCL100K, "end.\n", []string{"end", ".\n"}},
+		{splitGPT2, "I'M 12345", []string{"I", "'", "M", " 12345"}},
+		{splitGPT2, "x\n\n  y", []string{"x", "\n\n ", " y"}},
+		{splitO200K, "HelloWorld's", []string{"Hello", "World's"}},
+		{splitO200K, "ABC def", []string{"ABC", " def"}},
+		{splitO200K, "a/b//\n", []string{"a", "/b", "//\n"}},
+		{splitO200K, "日本語", []string{"日本語"}},
+	}
+	for _, c := range cases {
+		if got := c.split(c.text); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("split(%q) = %q, want %q", c.text, got, c.want)
+		}
+	}
+}
+
+func TestSplitKeepsText(t *testing.T) {
+	text := "Tokens: 1,234,567 in café — naïve 's\r\n\tfunc main() {}\n"
+	for name, split := range splitters {
+		if got := strings.Join(split(text), ""); got != text {
+			t.Errorf("%s lost text: %q", name, got)
+		}
+	}
+}
+
+func TestCountMergesByRank(t *testing.T) {
+	ranks := map[string]int{"a": 0, "b": 1, "c": 2, "ab": 3, "bc": 4, "abc": 5, " ": 6}
+	enc, err := NewEncoding(CL100KBase, ranks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// ab+c+ab merges to abc+ab; the space is its own piece
+	if got := enc.Count("abcab abc"); got != 4 {
+		t.Errorf("Count = %d, want 4", got)
+	}
+	if _, err := NewEncoding("gpt9_base", ranks); !errors.Is(err, ErrUnknownEncoding) {
+		t.Errorf("Expected ErrUnknownEncoding, got %v", err)
+	}
+}
+
+func TestGetEncodingLoadsRankFile(t *testing.T) {
+	dir := t.TempDir()
+	resetEncodings(t, dir)
+
+	var file strings.Builder
+	for rank, token := range []string{"h", "i", " ", "hi", " hi"} {
+		fmt.Fprintf(&file, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(token)), rank)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "p50k_base.tiktoken"), []byte(file.String()), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	enc, err := GetEncoding(P50KBase)
+	if err != nil || !enc.Exact() {
+		t.Fatalf("GetEncoding = %v, %v", enc, err)
+	}
+	if got := enc.Count("hi hi"); got != 2 {
+		t.Errorf("Count = %d, want 2", got)
+	}
+
+	estimate, err := GetEncoding(CL100KBase)
+	if err == nil || estimate == nil || estimate.Exact() {
+		t.Fatalf("Expected an estimating encoding and an error, got %v, %v", estimate, err)
+	}
+	if got := estimate.Count("Hello world, how are you?"); got < 6 {
+		t.Errorf("Estimate %d is below the true count of 7", got)
+	}
+}
+
+func TestLoadRanksRejectsBadLines(t *testing.T) {
+	for _, text := range []string{"aGk=\n", "!!! 1\n", "aGk= one\n"} {
+		if _, err := LoadRanks(strings.NewReader(text)); err == nil {
+			t.Errorf("LoadRanks(%q) succeeded", text)
+		}
+	}
+}
+
+func TestEncodingForModel(t *testing.T) {
+	cases := map[string]string{
+		"gpt-4o-mini":            O200KBase,
+		"gpt-4-turbo-preview":    CL100KBase,
+		"gpt-3.5-turbo":          CL100KBase,
+		"text-embedding-ada-002": CL100KBase,
+		"text-davinci-003":       P50KBase,
+		"davinci":                R50KBase,
+		"o3-mini":                O200KBase,
+		"some-new-model":         CL100KBase,
+	}
+	for model, want := range cases {
+		if got := EncodingForModel(model); got != want {
+			t.Errorf("EncodingForModel(%q) = %s, want %s", model, got, want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Calculator returns the calculator tool: arithmetic, powers, roots,
+// trigonometry and logarithms
+func Calculator() Tool {
+	return Tool{
+		Name:        "calculator",
+		Description: "Perform mathematical calculations including basic arithmetic, trigonometry, and advanced math functions",
+		Parameters: Schema{
+			Type: Object,
+			Properties: map[string]Schema{
+				"operation": {
+					Type:        String,
+					Description: "The mathematical operation to perform (add, subtract, multiply, divide, power, sqrt, sin, cos, tan, log)",
+					Enum:        []string{"add", "subtract", "multiply", "divide", "power", "sqrt", "sin", "cos", "tan", "log"},
+				},
+				"a": {
+					Type:        Number,
+					Description: "First number",
+				},
+				"b": {
+					Type:        Number,
+					Description: "Second number (optional for single-operand operations)",
+				},
+			},
+			Required: []string{"operation", "a"},
+		},
+		Handler: calculate,
+	}
+}
+
+// calculate implements the calculator tool
+func calculate(ctx context.Context, args map[string]interface{}) (string, error) {
+	operation, ok := args["operation"].(string)
+	if !ok {
+		return "", fmt.Errorf("operation must be a string")
+	}
+
+	aVal, ok := args["a"].(float64)
+	if !ok {
+		return "", fmt.Errorf("parameter 'a' must be a number")
+	}
+
+	var result float64
+
+	switch operation {
+	case "add":
+		bVal, ok := args["b"].(float64)
+		if !ok {
+			return "", fmt.Errorf("parameter 'b' required for addition")
+		}
+		result = aVal + bVal
+	case "subtract":
+		bVal, ok := args["b"].(float64)
+		if !ok {
+			return "", fmt.Errorf("parameter 'b' required for subtraction")
+		}
+		result = aVal - bVal
+	case "multiply":
+		bVal, ok := args["b"].(float64)
+		if !ok {
+			return "", fmt.Errorf("parameter 'b' required for multiplication")
+		}
+		result = aVal * bVal
+	case "divide":
+		bVal, ok := args["b"].(float64)
+		if !ok {
+			return "", fmt.Errorf("parameter 'b' required for division")
+		}
+		if bVal == 0 {
+			return "", fmt.Errorf("division by zero")
+		}
+		result = aVal / bVal
+	case "power":
+		bVal, ok := args["b"].(float64)
+		if !ok {
+			return "", fmt.Errorf("parameter 'b' required for power operation")
+		}
+		result = math.Pow(aVal, bVal)
+	case "sqrt":
+		if aVal < 0 {
+			return "", fmt.Errorf("cannot take square root of negative number")
+		}
+		result = math.Sqrt(aVal)
+	case "sin":
+		result = math.Sin(aVal)
+	case "cos":
+		result = math.Cos(aVal)
+	case "tan":
+		result = math.Tan(aVal)
+	case "log":
+		if aVal <= 0 {
+			return "", fmt.Errorf("logarithm requires positive number")
+		}
+		result = math.Log(aVal)
+	default:
+		return "", fmt.Errorf("unknown operation: %s", operation)
+	}
+
+	return fmt.Sprintf("%.6f", result), nil
+}
+
+// TextAnalysis returns the analyze_text tool: word, character and line
+// counts and an estimated reading time
+func TextAnalysis() Tool {
+	return Tool{
+		Name:        "analyze_text",
+		Description: "Analyze text and provide statistics like word count, character count, and reading time",
+		Parameters: Schema{
+			Type: Object,
+			Properties: map[string]Schema{
+				"text": {
+					Type:        String,
+					Description: "The text to analyze",
+				},
+			},
+			Required: []string{"text"},
+		},
+		Handler: analyzeText,
+	}
+}
+
+// analyzeText implements the text analysis tool
+func analyzeText(ctx context.Context, args map[string]interface{}) (string, error) {
+	text, ok := args["text"].(string)
+	if !ok {
+		return "", fmt.Errorf("text parameter must be a string")
+	}
+
+	words := strings.Fields(text)
+	chars := len(text)
+	charsNoSpaces := len(strings.ReplaceAll(text, " ", ""))
+	lines := len(strings.Split(text, "\n"))
+
+	// Estimate reading time (average 200 words per minute)
+	readingTime := float64(len(words)) / 200.0
+
+	analysis := fmt.Sprintf(`Text Analysis Results:
+- Characters: %d (including spaces), %d (excluding spaces)
+- Words: %d
+- Lines: %d
+- Estimated reading time: %.1f minutes`,
+		chars, charsNoSpaces, len(words), lines, readingTime)
+
+	return analysis, nil
+}
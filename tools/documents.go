@@ -0,0 +1,167 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTopK is how many documents search_documents returns unless asked
+const DefaultTopK = 3
+
+// MaxTopK bounds how many documents one search returns
+const MaxTopK = 10
+
+// Document is a search result: a stored text, its similarity to the query
+// and its metadata
+type Document struct {
+	ID       string                 `json:"id"`
+	Score    float64                `json:"score"`
+	Text     string                 `json:"text"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// DocumentSearcher is a store search_documents can query, such as a
+// vector store
+type DocumentSearcher interface {
+	SearchDocuments(ctx context.Context, query string, topK int) ([]Document, error)
+}
+
+// SearchDocuments returns the search_documents tool: a semantic search of
+// searcher, so the model can pull in knowledge when a question needs it
+func SearchDocuments(searcher DocumentSearcher) Tool {
+	return Tool{
+		Name:        "search_documents",
+		Description: "Search the knowledge base for passages relevant to a query. Returns the best matches with their similarity scores (0 to 1) and metadata such as their source; cite the ids of the passages you use.",
+		Parameters: Schema{
+			Type: Object,
+			Properties: map[string]Schema{
+				"query": {
+					Type:        String,
+					Description: "What to look for, phrased as a question or keywords",
+				},
+				"top_k": {
+					Type:        Integer,
+					Description: fmt.Sprintf("How many passages to return (default %d, at most %d)", DefaultTopK, MaxTopK),
+				},
+			},
+			Required: []string{"query"},
+		},
+		Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			query, _ := args["query"].(string)
+			if strings.TrimSpace(query) == "" {
+				return "", fmt.Errorf("query must not be empty")
+			}
+			topK := DefaultTopK
+			if k, ok := args["top_k"].(float64); ok {
+				topK = clampTopK(int(k))
+			}
+
+			documents, err := searcher.SearchDocuments(ctx, query, topK)
+			if err != nil {
+				return "", fmt.Errorf("search failed: %w", err)
+			}
+			if len(documents) == 0 {
+				return "No matching documents found.", nil
+			}
+			data, err := json.Marshal(documents)
+			if err != nil {
+				return "", err
+			}
+			return string(data), nil
+		},
+	}
+}
+
+// clampTopK keeps a requested result count between 1 and MaxTopK
+func clampTopK(k int) int {
+	switch {
+	case k < 1:
+		return 1
+	case k > MaxTopK:
+		return MaxTopK
+	default:
+		return k
+	}
+}
+
+// DocumentSearchHandler serves searcher over HTTP, so an agent in another
+// process can search it with RemoteDocuments:
+//
+//	GET /search?query=...&top_k=3  ->  [{"id", "score", "text", "metadata"}, ...]
+func DocumentSearchHandler(searcher DocumentSearcher) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("query")
+		if strings.TrimSpace(query) == "" {
+			http.Error(w, "query is required", http.StatusBadRequest)
+			return
+		}
+		topK := DefaultTopK
+		if value := r.URL.Query().Get("top_k"); value != "" {
+			k, err := strconv.Atoi(value)
+			if err != nil {
+				http.Error(w, "top_k must be an integer", http.StatusBadRequest)
+				return
+			}
+			topK = clampTopK(k)
+		}
+
+		documents, err := searcher.SearchDocuments(r.Context(), query, topK)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		if documents == nil {
+			documents = []Document{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(documents)
+	})
+}
+
+// RemoteDocuments searches the DocumentSearchHandler served at endpoint
+func RemoteDocuments(endpoint string) DocumentSearcher {
+	return &remoteDocuments{endpoint: endpoint, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+type remoteDocuments struct {
+	endpoint string
+	client   *http.Client
+}
+
+func (r *remoteDocuments) SearchDocuments(ctx context.Context, query string, topK int) ([]Document, error) {
+	target, err := url.Parse(r.endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid search endpoint: %w", err)
+	}
+	params := target.Query()
+	params.Set("query", query)
+	params.Set("top_k", strconv.Itoa(topK))
+	target.RawQuery = params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("search endpoint returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var documents []Document
+	if err := json.NewDecoder(resp.Body).Decode(&documents); err != nil {
+		return nil, fmt.Errorf("invalid search response: %w", err)
+	}
+	return documents, nil
+}
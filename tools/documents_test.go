@@ -0,0 +1,62 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeDocuments returns the first topK of its documents whose text mentions the query
+type fakeDocuments []Document
+
+func (f fakeDocuments) SearchDocuments(ctx context.Context, query string, topK int) ([]Document, error) {
+	var found []Document
+	for _, doc := range f {
+		if strings.Contains(doc.Text, query) && len(found) < topK {
+			found = append(found, doc)
+		}
+	}
+	return found, nil
+}
+
+func TestSearchDocuments(t *testing.T) {
+	store := fakeDocuments{
+		{ID: "go-1", Score: 0.9, Text: "Go has goroutines", Metadata: map[string]interface{}{"source": "faq.md"}},
+		{ID: "go-2", Score: 0.8, Text: "Go has channels"},
+		{ID: "rust", Score: 0.7, Text: "Rust has ownership"},
+	}
+
+	// Served over HTTP and searched remotely, as the agents in different lessons do
+	server := httptest.NewServer(DocumentSearchHandler(store))
+	defer server.Close()
+	registry := NewRegistry()
+	registry.MustRegister(SearchDocuments(RemoteDocuments(server.URL + "/search")))
+
+	ctx := context.Background()
+	result, err := registry.Call(ctx, "search_documents", `{"query":"Go","top_k":1}`)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	var documents []Document
+	if err := json.Unmarshal([]byte(result), &documents); err != nil {
+		t.Fatalf("Expected JSON results, got %q", result)
+	}
+	if len(documents) != 1 || documents[0].ID != "go-1" || documents[0].Score != 0.9 || documents[0].Metadata["source"] != "faq.md" {
+		t.Errorf("Unexpected results %+v", documents)
+	}
+
+	if result, err := registry.Call(ctx, "search_documents", `{"query":"Python"}`); err != nil || !strings.Contains(result, "No matching") {
+		t.Errorf("Expected no matches, got %q (%v)", result, err)
+	}
+	if _, err := registry.Call(ctx, "search_documents", `{"query":"  "}`); err == nil {
+		t.Error("Expected an empty query to be rejected")
+	}
+	if result, _ := registry.Call(ctx, "search_documents", `{"query":"has","top_k":50}`); strings.Count(result, `"id"`) != 3 {
+		t.Errorf("Expected top_k clamped to the store's 3 matches, got %q", result)
+	}
+	if _, err := RemoteDocuments(server.URL+"/search?").SearchDocuments(ctx, "", 3); err == nil || !strings.Contains(err.Error(), "query is required") {
+		t.Errorf("Expected the server's error to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,309 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Runtimes execute_code can run snippets in
+const (
+	// RuntimeSubprocess runs snippets as local processes with CPU time and
+	// file size limits. They can still use the network and read the files
+	// the user can.
+	RuntimeSubprocess = "subprocess"
+	// RuntimeDocker runs snippets in a throwaway container without network
+	// access, with memory, CPU and process limits and a read-only filesystem
+	RuntimeDocker = "docker"
+	// RuntimeAuto uses Docker when it is installed and subprocesses otherwise
+	RuntimeAuto = "auto"
+)
+
+// ExecPolicy is the sandbox execute_code runs snippets in
+type ExecPolicy struct {
+	// Runtime is RuntimeSubprocess, RuntimeDocker or RuntimeAuto
+	Runtime string
+	// Timeout bounds a run, compilation included
+	Timeout time.Duration
+	// MemoryMB caps a container's memory, and a Python subprocess's
+	// address space (Go reserves more address space than it uses, so Go
+	// subprocesses get only the CPU limit)
+	MemoryMB int
+	// MaxOutput caps the bytes of stdout and of stderr returned
+	MaxOutput int
+	// GoImage and PythonImage are the Docker images snippets run in
+	GoImage     string
+	PythonImage string
+}
+
+// DefaultExecPolicy runs snippets for up to 15 seconds with 256 MB of
+// memory, in Docker when it is available
+func DefaultExecPolicy() ExecPolicy {
+	return ExecPolicy{
+		Runtime:     RuntimeAuto,
+		Timeout:     15 * time.Second,
+		MemoryMB:    256,
+		MaxOutput:   16 << 10,
+		GoImage:     "golang:1.21-alpine",
+		PythonImage: "python:3.12-alpine",
+	}
+}
+
+// ExecResult is what a run returns to the model
+type ExecResult struct {
+	Language   string `json:"language"`
+	Runtime    string `json:"runtime"`
+	ExitCode   int    `json:"exit_code"`
+	Stdout     string `json:"stdout"`
+	Stderr     string `json:"stderr"`
+	TimedOut   bool   `json:"timed_out,omitempty"`
+	Truncated  bool   `json:"truncated,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// snippet describes how to run one language
+type snippet struct {
+	file string
+	// local is the command for a subprocess; image and container the image
+	// and command for Docker
+	local     []string
+	container []string
+}
+
+func (p ExecPolicy) snippet(language string) (snippet, string, error) {
+	switch language {
+	case "go":
+		return snippet{file: "main.go", local: []string{"go", "run", "main.go"}, container: []string{"go", "run", "main.go"}}, p.GoImage, nil
+	case "python":
+		return snippet{file: "main.py", local: []string{"python3", "-I", "main.py"}, container: []string{"python", "-I", "main.py"}}, p.PythonImage, nil
+	default:
+		return snippet{}, "", fmt.Errorf("unsupported language %q: use go or python", language)
+	}
+}
+
+// runtime resolves RuntimeAuto
+func (p ExecPolicy) runtime() string {
+	if p.Runtime != RuntimeAuto && p.Runtime != "" {
+		return p.Runtime
+	}
+	if _, err := exec.LookPath("docker"); err == nil {
+		return RuntimeDocker
+	}
+	return RuntimeSubprocess
+}
+
+// Run executes code in the sandbox. A snippet that fails, or runs out of
+// time, is a result rather than an error; errors mean it couldn't be run.
+func (p ExecPolicy) Run(ctx context.Context, language, code string) (*ExecResult, error) {
+	lang, image, err := p.snippet(language)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "execute-code-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	if err := os.WriteFile(filepath.Join(dir, lang.file), []byte(code), 0644); err != nil {
+		return nil, err
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	result := &ExecResult{Language: language, Runtime: p.runtime()}
+	var cmd *exec.Cmd
+	var cleanup func()
+	switch result.Runtime {
+	case RuntimeDocker:
+		cmd, cleanup = p.dockerCommand(runCtx, dir, image, lang.container)
+	case RuntimeSubprocess:
+		cmd, err = p.localCommand(runCtx, dir, language, lang.local)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown runtime %q: use subprocess, docker or auto", result.Runtime)
+	}
+
+	stdout := &cappedBuffer{max: p.MaxOutput}
+	stderr := &cappedBuffer{max: p.MaxOutput}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	started := time.Now()
+	err = cmd.Run()
+	result.DurationMS = time.Since(started).Milliseconds()
+	if cleanup != nil {
+		cleanup()
+	}
+
+	result.Stdout, result.Stderr = stdout.String(), stderr.String()
+	result.Truncated = stdout.truncated || stderr.truncated
+	var exitErr *exec.ExitError
+	switch {
+	case runCtx.Err() == context.DeadlineExceeded:
+		result.TimedOut, result.ExitCode = true, -1
+	case ctx.Err() != nil:
+		return nil, ctx.Err()
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		return nil, fmt.Errorf("failed to run %s: %w", language, err)
+	}
+	return result, nil
+}
+
+// localCommand runs the snippet in dir with CPU and file size limits set by
+// the shell, in its own process group so a timeout kills everything it
+// started, and with only the environment it needs
+func (p ExecPolicy) localCommand(ctx context.Context, dir, language string, command []string) (*exec.Cmd, error) {
+	if _, err := exec.LookPath(command[0]); err != nil {
+		return nil, fmt.Errorf("%s is not installed", command[0])
+	}
+	cpuSeconds := int(p.Timeout/time.Second) + 1
+	limits := fmt.Sprintf("ulimit -t %d; ulimit -f %d;", cpuSeconds, 10<<10)
+	if language == "python" && p.MemoryMB > 0 {
+		limits += fmt.Sprintf(" ulimit -v %d;", p.MemoryMB<<10)
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", limits+` exec "$@"`, "sh")
+	cmd.Args = append(cmd.Args, command...)
+	cmd.Dir = dir
+	cmd.Env = []string{"PATH=" + os.Getenv("PATH"), "HOME=" + dir, "TMPDIR=" + dir}
+	if language == "go" {
+		// Reuse the build cache, or every run recompiles the standard library
+		if cache, err := exec.Command("go", "env", "GOCACHE").Output(); err == nil {
+			cmd.Env = append(cmd.Env, "GOCACHE="+strings.TrimSpace(string(cache)), "GOTOOLCHAIN=local")
+		}
+	}
+	isolate(cmd)
+	// Don't wait for output from anything that escaped the kill
+	cmd.WaitDelay = time.Second
+	return cmd, nil
+}
+
+// dockerCommand runs the snippet in a container. The container is removed
+// by name afterwards, since killing the docker client on a timeout would
+// leave it running.
+func (p ExecPolicy) dockerCommand(ctx context.Context, dir, image string, command []string) (*exec.Cmd, func()) {
+	name := fmt.Sprintf("execute-code-%d", time.Now().UnixNano())
+	args := []string{
+		"run", "--rm", "--name", name,
+		"--network", "none",
+		"--memory", fmt.Sprintf("%dm", p.MemoryMB),
+		"--cpus", "1",
+		"--pids-limit", "64",
+		"--read-only",
+		"--tmpfs", "/tmp:rw,exec,size=256m",
+		"--env", "HOME=/tmp", "--env", "GOCACHE=/tmp/gocache", "--env", "GOTOOLCHAIN=local",
+		"--volume", dir + ":/code:ro",
+		"--workdir", "/code",
+		image,
+	}
+	cmd := exec.CommandContext(ctx, "docker", append(args, command...)...)
+	return cmd, func() {
+		exec.Command("docker", "rm", "-f", name).Run()
+	}
+}
+
+// cappedBuffer keeps the first max bytes written to it. The buffer isn't
+// embedded, or io.Copy would bypass Write through its ReadFrom.
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(data []byte) (int, error) {
+	if room := b.max - b.buf.Len(); b.max > 0 && len(data) > room {
+		b.truncated = true
+		if room > 0 {
+			b.buf.Write(data[:room])
+		}
+		return len(data), nil
+	}
+	return b.buf.Write(data)
+}
+
+func (b *cappedBuffer) String() string {
+	return b.buf.String()
+}
+
+// description tells the model what the runtime the policy resolves to can
+// reach, since only containers are cut off from the network
+func (p ExecPolicy) description() string {
+	description := "Run a complete Go (package main) or Python program and return its stdout, stderr and exit code. Use it to test code or compute results. There is no input."
+	if p.runtime() == RuntimeDocker {
+		return description + " It runs in a container with no network access."
+	}
+	return description + " It runs as a local process that can use the network and read local files, so never send secrets or private data from it."
+}
+
+// ExecuteCode returns the execute_code tool, which runs Go and Python
+// snippets in policy's sandbox
+func ExecuteCode(policy ExecPolicy) Tool {
+	return Tool{
+		Name:        "execute_code",
+		Description: policy.description(),
+		Parameters: Schema{
+			Type: Object,
+			Properties: map[string]Schema{
+				"language": {Type: String, Enum: []string{"go", "python"}},
+				"code":     {Type: String, Description: "The complete program"},
+			},
+			Required: []string{"language", "code"},
+		},
+		// Leave time to pull an image and clean up after a timed out run
+		Timeout: policy.Timeout + 30*time.Second,
+		Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			language, _ := args["language"].(string)
+			code, _ := args["code"].(string)
+			result, err := policy.Run(ctx, language, code)
+			if err != nil {
+				return "", err
+			}
+			data, err := json.Marshal(result)
+			if err != nil {
+				return "", err
+			}
+			return string(data), nil
+		},
+	}
+}
+
+// ExecFromEnv returns execute_code when EXECUTE_CODE is subprocess, docker
+// or auto, or nil when it isn't set. EXECUTE_CODE_TIMEOUT_SECONDS and
+// EXECUTE_CODE_MEMORY_MB adjust the limits.
+func ExecFromEnv() ([]Tool, error) {
+	policy := DefaultExecPolicy()
+	switch runtime := strings.ToLower(os.Getenv("EXECUTE_CODE")); runtime {
+	case "", "off", "false":
+		return nil, nil
+	case RuntimeSubprocess, RuntimeDocker, RuntimeAuto:
+		policy.Runtime = runtime
+	default:
+		return nil, fmt.Errorf("invalid EXECUTE_CODE %q: use subprocess, docker or auto", runtime)
+	}
+	if value := os.Getenv("EXECUTE_CODE_TIMEOUT_SECONDS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid EXECUTE_CODE_TIMEOUT_SECONDS %q", value)
+		}
+		policy.Timeout = time.Duration(n) * time.Second
+	}
+	if value := os.Getenv("EXECUTE_CODE_MEMORY_MB"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid EXECUTE_CODE_MEMORY_MB %q", value)
+		}
+		policy.MemoryMB = n
+	}
+	return []Tool{ExecuteCode(policy)}, nil
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package tools
+
+import "os/exec"
+
+// isolate is not implemented on this platform: only the process itself is
+// killed when its context ends
+func isolate(cmd *exec.Cmd) {}
@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecuteCode(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 is not installed")
+	}
+	policy := DefaultExecPolicy()
+	policy.Runtime = RuntimeSubprocess
+	policy.Timeout = 2 * time.Second
+	policy.MaxOutput = 64
+	registry := NewRegistry()
+	registry.MustRegister(ExecuteCode(policy))
+	run := func(code string) ExecResult {
+		t.Helper()
+		out, err := registry.Invoke(context.Background(), "execute_code", map[string]interface{}{"language": "python", "code": code})
+		if err != nil {
+			t.Fatalf("Failed to run %q: %v", code, err)
+		}
+		var result ExecResult
+		if err := json.Unmarshal([]byte(out), &result); err != nil {
+			t.Fatalf("Invalid result %q: %v", out, err)
+		}
+		return result
+	}
+
+	result := run("import sys\nprint('hello')\nprint('oops', file=sys.stderr)\nsys.exit(3)\n")
+	if result.Stdout != "hello\n" || result.Stderr != "oops\n" || result.ExitCode != 3 || result.Runtime != RuntimeSubprocess {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if result := run("import time\ntime.sleep(30)\n"); !result.TimedOut || result.DurationMS > 10000 {
+		t.Errorf("Expected a timeout, got %+v", result)
+	}
+	if result := run("print('x' * 1000)\n"); !result.Truncated || len(result.Stdout) != 64 {
+		t.Errorf("Expected truncated output, got %+v", result)
+	}
+	if _, err := registry.Invoke(context.Background(), "execute_code", map[string]interface{}{"language": "ruby", "code": "puts 1"}); err == nil {
+		t.Error("Expected an unsupported language to fail")
+	}
+}
+
+func TestExecuteCodeDescription(t *testing.T) {
+	policy := DefaultExecPolicy()
+	policy.Runtime = RuntimeSubprocess
+	if description := ExecuteCode(policy).Description; !strings.Contains(description, "can use the network") {
+		t.Errorf("Expected subprocesses described as networked, got %q", description)
+	}
+	policy.Runtime = RuntimeDocker
+	if description := ExecuteCode(policy).Description; !strings.Contains(description, "no network access") {
+		t.Errorf("Expected containers described as offline, got %q", description)
+	}
+}
+
+func TestExecuteCodeGo(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles a program")
+	}
+	policy := DefaultExecPolicy()
+	policy.Runtime = RuntimeSubprocess
+	policy.Timeout = time.Minute
+	result, err := policy.Run(context.Background(), "go", "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println(6 * 7) }\n")
+	if err != nil {
+		t.Fatalf("Failed to run: %v", err)
+	}
+	if result.Stdout != "42\n" || result.ExitCode != 0 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+}
+
+func TestExecFromEnv(t *testing.T) {
+	t.Setenv("EXECUTE_CODE", "")
+	if execTools, err := ExecFromEnv(); err != nil || execTools != nil {
+		t.Errorf("Expected no tools when unset, got %v (%v)", execTools, err)
+	}
+	t.Setenv("EXECUTE_CODE", "subprocess")
+	if execTools, err := ExecFromEnv(); err != nil || len(execTools) != 1 || execTools[0].Name != "execute_code" {
+		t.Errorf("Expected execute_code, got %v (%v)", execTools, err)
+	}
+	for key, value := range map[string]string{"EXECUTE_CODE": "vm", "EXECUTE_CODE_TIMEOUT_SECONDS": "-1", "EXECUTE_CODE_MEMORY_MB": "lots"} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, value)
+			if _, err := ExecFromEnv(); err == nil || !strings.Contains(err.Error(), key) {
+				t.Errorf("Expected an error naming %s, got %v", key, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrBlockedURL is returned for a URL the fetch policy doesn't allow
+var ErrBlockedURL = errors.New("URL not allowed")
+
+// FetchPolicy bounds what fetch_url may download
+type FetchPolicy struct {
+	// AllowDomains, when set, are the only domains (and their subdomains)
+	// that may be fetched
+	AllowDomains []string
+	// DenyDomains are never fetched, even when allowed
+	DenyDomains []string
+	// MaxBytes caps the bytes read from a response
+	MaxBytes int64
+	// MaxChars caps the text returned to the model
+	MaxChars int
+	// Timeout bounds a fetch, redirects included
+	Timeout time.Duration
+	// AllowPrivate permits loopback and private network addresses, which
+	// are otherwise refused so a model can't reach internal services
+	AllowPrivate bool
+}
+
+// DefaultFetchPolicy reads at most 2 MB for 10 seconds and returns at most
+// 8000 characters
+func DefaultFetchPolicy() FetchPolicy {
+	return FetchPolicy{MaxBytes: 2 << 20, MaxChars: 8000, Timeout: 10 * time.Second}
+}
+
+// FetchPolicyFromEnv is DefaultFetchPolicy adjusted by FETCH_ALLOW_DOMAINS
+// and FETCH_DENY_DOMAINS (comma-separated), FETCH_MAX_BYTES and
+// FETCH_TIMEOUT_SECONDS
+func FetchPolicyFromEnv() (FetchPolicy, error) {
+	policy := DefaultFetchPolicy()
+	policy.AllowDomains = splitDomains(os.Getenv("FETCH_ALLOW_DOMAINS"))
+	policy.DenyDomains = splitDomains(os.Getenv("FETCH_DENY_DOMAINS"))
+	if value := os.Getenv("FETCH_MAX_BYTES"); value != "" {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n <= 0 {
+			return policy, fmt.Errorf("invalid FETCH_MAX_BYTES %q", value)
+		}
+		policy.MaxBytes = n
+	}
+	if value := os.Getenv("FETCH_TIMEOUT_SECONDS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return policy, fmt.Errorf("invalid FETCH_TIMEOUT_SECONDS %q", value)
+		}
+		policy.Timeout = time.Duration(n) * time.Second
+	}
+	return policy, nil
+}
+
+func splitDomains(list string) []string {
+	var domains []string
+	for _, domain := range strings.Split(list, ",") {
+		if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+			domains = append(domains, strings.TrimPrefix(domain, "."))
+		}
+	}
+	return domains
+}
+
+// Check reports whether the policy allows fetching target
+func (p FetchPolicy) Check(target *url.URL) error {
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return fmt.Errorf("%w: only http and https are fetched", ErrBlockedURL)
+	}
+	host := strings.ToLower(target.Hostname())
+	if host == "" {
+		return fmt.Errorf("%w: no host", ErrBlockedURL)
+	}
+	for _, domain := range p.DenyDomains {
+		if inDomain(host, domain) {
+			return fmt.Errorf("%w: %s is denied", ErrBlockedURL, host)
+		}
+	}
+	if len(p.AllowDomains) == 0 {
+		return nil
+	}
+	for _, domain := range p.AllowDomains {
+		if inDomain(host, domain) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s is not in the allowed domains", ErrBlockedURL, host)
+}
+
+// inDomain reports whether host is domain or one of its subdomains
+func inDomain(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// Client returns the sandboxed HTTP client fetch_url uses: every redirect
+// is checked against the policy, and unless AllowPrivate is set,
+// connections to loopback, private and link-local addresses are refused
+// after DNS resolution, so a hostname can't be pointed at them.
+func (p FetchPolicy) Client() *http.Client {
+	dialer := &net.Dialer{Timeout: p.Timeout}
+	if !p.AllowPrivate {
+		dialer.Control = func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return fmt.Errorf("%w: %s is not a public address", ErrBlockedURL, host)
+			}
+			return nil
+		}
+	}
+
+	return &http.Client{
+		Timeout: p.Timeout,
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   p.Timeout,
+			ResponseHeaderTimeout: p.Timeout,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("stopped after 5 redirects")
+			}
+			return p.Check(req.URL)
+		},
+	}
+}
+
+// publicIP reports whether ip is routable on the internet
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
+
+// FetchURL returns the fetch_url tool: it downloads a web page within
+// policy's limits and returns its title and text
+func FetchURL(policy FetchPolicy) Tool {
+	client := policy.Client()
+	return Tool{
+		Name:        "fetch_url",
+		Description: "Fetch a web page and return its title and readable text. Use it to read a page found with web_search or given by the user.",
+		Parameters: Schema{
+			Type: Object,
+			Properties: map[string]Schema{
+				"url": {
+					Type:        String,
+					Description: "The http or https URL to fetch",
+				},
+			},
+			Required: []string{"url"},
+		},
+		Timeout: policy.Timeout + time.Second,
+		Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			raw, _ := args["url"].(string)
+			return fetch(ctx, client, policy, raw)
+		},
+	}
+}
+
+func fetch(ctx context.Context, client *http.Client, policy FetchPolicy, raw string) (string, error) {
+	target, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+	if err := policy.Check(target); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "agentic-ai-fetch/1.0")
+	req.Header.Set("Accept", "text/html, text/plain;q=0.9, application/json;q=0.8")
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetch failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetch failed: %s", resp.Status)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	isHTML := mediaType == "" || mediaType == "text/html" || mediaType == "application/xhtml+xml"
+	if !isHTML && !strings.HasPrefix(mediaType, "text/") && mediaType != "application/json" {
+		return "", fmt.Errorf("can't read %s content", mediaType)
+	}
+
+	maxBytes := policy.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultFetchPolicy().MaxBytes
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes))
+	if err != nil {
+		return "", fmt.Errorf("fetch failed: %w", err)
+	}
+
+	title, text := "", string(body)
+	if isHTML {
+		title, text = htmlToText(text)
+	}
+	if policy.MaxChars > 0 && len([]rune(text)) > policy.MaxChars {
+		text = strings.TrimSpace(string([]rune(text)[:policy.MaxChars])) + "\n[truncated]"
+	}
+
+	var out strings.Builder
+	if title != "" {
+		fmt.Fprintf(&out, "Title: %s\n", title)
+	}
+	fmt.Fprintf(&out, "URL: %s\n\n%s", resp.Request.URL, text)
+	return out.String(), nil
+}
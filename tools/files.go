@@ -0,0 +1,254 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrOutsideSandbox is returned for a path the file policy doesn't allow
+var ErrOutsideSandbox = errors.New("path outside the sandbox")
+
+// FilePolicy is the sandbox the file tools work in
+type FilePolicy struct {
+	// Roots are the directories the tools may touch, with everything below
+	// them. Relative paths are resolved against the first.
+	Roots []string
+	// MaxFileSize caps the bytes read or written in one call
+	MaxFileSize int64
+	// ReadOnly leaves out write_file
+	ReadOnly bool
+}
+
+// DefaultFilePolicy allows roots, with files of up to 1 MB
+func DefaultFilePolicy(roots ...string) FilePolicy {
+	return FilePolicy{Roots: roots, MaxFileSize: 1 << 20}
+}
+
+// Resolve returns the absolute path path refers to, or ErrOutsideSandbox
+// when it isn't under a root. Symlinks are followed before checking, so a
+// link can't lead out of the sandbox; for a file that doesn't exist yet,
+// its nearest existing parent is checked.
+func (p FilePolicy) Resolve(path string) (string, error) {
+	if len(p.Roots) == 0 {
+		return "", fmt.Errorf("%w: no root directories are configured", ErrOutsideSandbox)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(p.Roots[0], path)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := evalExisting(abs)
+	if err != nil {
+		return "", err
+	}
+
+	for _, root := range p.Roots {
+		rootAbs, err := filepath.Abs(root)
+		if err != nil {
+			continue
+		}
+		if resolvedRoot, err := filepath.EvalSymlinks(rootAbs); err == nil {
+			rootAbs = resolvedRoot
+		}
+		if rel, err := filepath.Rel(rootAbs, resolved); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return resolved, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrOutsideSandbox, path)
+}
+
+// evalExisting resolves the symlinks in the longest existing prefix of
+// path and appends the rest
+func evalExisting(path string) (string, error) {
+	var missing []string
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, missing...)...), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", err
+		}
+		missing = append([]string{filepath.Base(path)}, missing...)
+		path = parent
+	}
+}
+
+// FileTools returns read_file, list_dir and, unless the policy is
+// read-only, write_file, all confined to policy
+func FileTools(policy FilePolicy) []Tool {
+	if policy.MaxFileSize <= 0 {
+		policy.MaxFileSize = DefaultFilePolicy().MaxFileSize
+	}
+	pathParam := func(description string) Schema {
+		return Schema{Type: String, Description: description}
+	}
+
+	files := []Tool{
+		{
+			Name:        "read_file",
+			Description: "Read a text file in the project",
+			Parameters: Schema{
+				Type:       Object,
+				Properties: map[string]Schema{"path": pathParam("The file's path, relative to the project root")},
+				Required:   []string{"path"},
+			},
+			Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				path, _ := args["path"].(string)
+				return readFile(policy, path)
+			},
+		},
+		{
+			Name:        "list_dir",
+			Description: "List a directory in the project: subdirectories end with /, files show their size in bytes",
+			Parameters: Schema{
+				Type:       Object,
+				Properties: map[string]Schema{"path": pathParam("The directory's path, relative to the project root (default: the root)")},
+			},
+			Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				path, _ := args["path"].(string)
+				if path == "" {
+					path = "."
+				}
+				return listDir(policy, path)
+			},
+		},
+	}
+	if !policy.ReadOnly {
+		files = append(files, Tool{
+			Name:        "write_file",
+			Description: "Create or overwrite a text file in the project, creating its directory if needed",
+			Parameters: Schema{
+				Type: Object,
+				Properties: map[string]Schema{
+					"path":    pathParam("The file's path, relative to the project root"),
+					"content": {Type: String, Description: "The complete new content of the file"},
+				},
+				Required: []string{"path", "content"},
+			},
+			Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				path, _ := args["path"].(string)
+				content, _ := args["content"].(string)
+				return writeFile(policy, path, content)
+			},
+		})
+	}
+	return files
+}
+
+func readFile(policy FilePolicy, path string) (string, error) {
+	resolved, err := policy.Resolve(path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory; use list_dir", path)
+	}
+	if info.Size() > policy.MaxFileSize {
+		return "", fmt.Errorf("%s is %d bytes, over the %d byte limit", path, info.Size(), policy.MaxFileSize)
+	}
+	data, err := os.ReadFile(resolved)
+	if err != nil {
+		return "", err
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return "", fmt.Errorf("%s is a binary file", path)
+	}
+	return string(data), nil
+}
+
+func listDir(policy FilePolicy, path string) (string, error) {
+	resolved, err := policy.Resolve(path)
+	if err != nil {
+		return "", err
+	}
+	entries, err := os.ReadDir(resolved)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "(empty directory)", nil
+	}
+
+	var out strings.Builder
+	for _, entry := range entries {
+		switch info, err := entry.Info(); {
+		case entry.IsDir():
+			fmt.Fprintf(&out, "%s/\n", entry.Name())
+		case err == nil:
+			fmt.Fprintf(&out, "%s\t%d\n", entry.Name(), info.Size())
+		default:
+			fmt.Fprintf(&out, "%s\n", entry.Name())
+		}
+	}
+	return out.String(), nil
+}
+
+func writeFile(policy FilePolicy, path, content string) (string, error) {
+	if int64(len(content)) > policy.MaxFileSize {
+		return "", fmt.Errorf("content is %d bytes, over the %d byte limit", len(content), policy.MaxFileSize)
+	}
+	resolved, err := policy.Resolve(path)
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(resolved); err == nil && info.IsDir() {
+		return "", fmt.Errorf("%s is a directory", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(resolved), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(resolved, []byte(content), 0644); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Wrote %d bytes to %s", len(content), path), nil
+}
+
+// FilesFromEnv returns the file tools for the directories in
+// FILE_TOOLS_ROOTS (separated like PATH), or nil when it isn't set.
+// FILE_TOOLS_READ_ONLY=true leaves out write_file and FILE_TOOLS_MAX_BYTES
+// changes the 1 MB size limit.
+func FilesFromEnv() ([]Tool, error) {
+	roots := filepath.SplitList(os.Getenv("FILE_TOOLS_ROOTS"))
+	if len(roots) == 0 {
+		return nil, nil
+	}
+	policy := DefaultFilePolicy(roots...)
+	for _, root := range roots {
+		if info, err := os.Stat(root); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("FILE_TOOLS_ROOTS: %s is not a directory", root)
+		}
+	}
+	if value := os.Getenv("FILE_TOOLS_READ_ONLY"); value != "" {
+		readOnly, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid FILE_TOOLS_READ_ONLY %q", value)
+		}
+		policy.ReadOnly = readOnly
+	}
+	if value := os.Getenv("FILE_TOOLS_MAX_BYTES"); value != "" {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid FILE_TOOLS_MAX_BYTES %q", value)
+		}
+		policy.MaxFileSize = n
+	}
+	return FileTools(policy), nil
+}
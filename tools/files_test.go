@@ -0,0 +1,59 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileTools(t *testing.T) {
+	root, outside := t.TempDir(), t.TempDir()
+	os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n"), 0644)
+	os.WriteFile(filepath.Join(root, "logo.png"), []byte{0x89, 'P', 'N', 'G', 0}, 0644)
+	os.WriteFile(filepath.Join(outside, "secret"), []byte("password"), 0644)
+	os.Symlink(outside, filepath.Join(root, "escape"))
+
+	policy := DefaultFilePolicy(root)
+	policy.MaxFileSize = 64
+	registry := NewRegistry()
+	registry.MustRegister(FileTools(policy)...)
+	ctx := context.Background()
+	call := func(name string, args map[string]interface{}) (string, error) {
+		return registry.Invoke(ctx, name, args)
+	}
+
+	if content, err := call("read_file", map[string]interface{}{"path": "main.go"}); err != nil || content != "package main\n" {
+		t.Errorf("Expected the file, got %q (%v)", content, err)
+	}
+	if _, err := call("write_file", map[string]interface{}{"path": "pkg/util.go", "content": "package pkg\n"}); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	if listing, err := call("list_dir", map[string]interface{}{}); err != nil || !strings.Contains(listing, "main.go\t13\n") || !strings.Contains(listing, "pkg/\n") {
+		t.Errorf("Unexpected listing %q (%v)", listing, err)
+	}
+
+	for _, path := range []string{"../" + filepath.Base(outside) + "/secret", filepath.Join(outside, "secret"), "escape/secret", "escape/new.txt"} {
+		if _, err := call("read_file", map[string]interface{}{"path": path}); !errors.Is(err, ErrOutsideSandbox) {
+			t.Errorf("Expected %s to be outside the sandbox, got %v", path, err)
+		}
+	}
+	if _, err := call("write_file", map[string]interface{}{"path": "escape/new.txt", "content": "x"}); !errors.Is(err, ErrOutsideSandbox) {
+		t.Errorf("Expected a write through a symlink to be refused, got %v", err)
+	}
+	if _, err := call("read_file", map[string]interface{}{"path": "logo.png"}); err == nil || !strings.Contains(err.Error(), "binary") {
+		t.Errorf("Expected a binary file to be refused, got %v", err)
+	}
+	if _, err := call("write_file", map[string]interface{}{"path": "big.txt", "content": strings.Repeat("x", 65)}); err == nil {
+		t.Error("Expected content over the size limit to be refused")
+	}
+
+	policy.ReadOnly = true
+	for _, tool := range FileTools(policy) {
+		if tool.Name == "write_file" {
+			t.Error("Expected no write_file in read-only mode")
+		}
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/sakibmulla/agentic-ai/tools
+
+go 1.21
@@ -0,0 +1,54 @@
+package tools
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	// htmlDrop matches comments and the elements whose content is never
+	// text a reader sees
+	htmlDrop = dropPatterns("script", "style", "noscript", "template", "svg", "head", "title")
+	// htmlTitle captures the document title
+	htmlTitle = regexp.MustCompile(`(?is)<title\b[^>]*>(.*?)</title\s*>`)
+	// htmlBreak matches tags that start a new line of text
+	htmlBreak = regexp.MustCompile(`(?i)</?(p|div|br|li|ul|ol|h[1-6]|tr|table|section|article|header|footer|nav|blockquote|pre|hr)\b[^>]*>`)
+	htmlTag   = regexp.MustCompile(`(?s)<[^>]*>`)
+	// htmlSpaces collapses runs of spaces and tabs within a line
+	htmlSpaces = regexp.MustCompile(`[ \t\r\f\v\x{00a0}]+`)
+)
+
+// htmlToText extracts a page's title and readable text: scripts, styles
+// and markup are dropped, block elements become line breaks and entities
+// are decoded. It is a heuristic, not a parser, but enough for a model to
+// read a page.
+func htmlToText(page string) (title, text string) {
+	if match := htmlTitle.FindStringSubmatch(page); match != nil {
+		title = strings.TrimSpace(htmlSpaces.ReplaceAllString(html.UnescapeString(htmlTag.ReplaceAllString(match[1], "")), " "))
+	}
+
+	for _, drop := range htmlDrop {
+		page = drop.ReplaceAllString(page, " ")
+	}
+	page = htmlBreak.ReplaceAllString(page, "\n")
+	page = html.UnescapeString(htmlTag.ReplaceAllString(page, " "))
+
+	var lines []string
+	for _, line := range strings.Split(page, "\n") {
+		if line = strings.TrimSpace(htmlSpaces.ReplaceAllString(line, " ")); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return title, strings.Join(lines, "\n")
+}
+
+// dropPatterns returns a pattern for comments and one per element, since Go
+// regexps can't match a closing tag to its opening one
+func dropPatterns(elements ...string) []*regexp.Regexp {
+	patterns := []*regexp.Regexp{regexp.MustCompile(`(?s)<!--.*?-->`)}
+	for _, element := range elements {
+		patterns = append(patterns, regexp.MustCompile(`(?is)<`+element+`\b.*?</`+element+`\s*>`))
+	}
+	return patterns
+}
@@ -0,0 +1,196 @@
+// Package tools is a registry of function-calling tools shared by the agents
+// in this course. A tool is declared once, with a JSON schema for its
+// arguments, and can be registered into any agent: arguments are validated
+// against the schema before the handler runs, and each call is bounded by a
+// timeout.
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout bounds a call to a tool that sets no Timeout of its own
+const DefaultTimeout = 30 * time.Second
+
+var (
+	// ErrUnknownTool is returned when calling a tool that is not registered
+	ErrUnknownTool = errors.New("unknown tool")
+	// ErrInvalidArguments wraps a ValidationError or a JSON decoding error
+	ErrInvalidArguments = errors.New("invalid arguments")
+	// ErrTimeout is returned when a tool runs past its timeout
+	ErrTimeout = errors.New("tool timed out")
+)
+
+// Handler runs a tool with its validated arguments
+type Handler func(ctx context.Context, args map[string]interface{}) (string, error)
+
+// Tool is a function the model can call
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  Schema
+	// Timeout bounds each call; zero uses the registry's default
+	Timeout time.Duration
+	Handler Handler
+}
+
+// Definition is a tool as declared to the model. Its fields line up with
+// openai.FunctionDefinition.
+type Definition struct {
+	Name        string
+	Description string
+	Parameters  Schema
+}
+
+// namePattern is what the OpenAI API accepts as a function name
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// Registry holds the tools available to an agent. It is safe for concurrent use.
+type Registry struct {
+	mu    sync.RWMutex
+	tools map[string]Tool
+	// DefaultTimeout applies to tools without a Timeout
+	DefaultTimeout time.Duration
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		tools:          make(map[string]Tool),
+		DefaultTimeout: DefaultTimeout,
+	}
+}
+
+// Register adds a tool. Names must be unique and valid OpenAI function names.
+func (r *Registry) Register(tool Tool) error {
+	if !namePattern.MatchString(tool.Name) {
+		return fmt.Errorf("invalid tool name %q", tool.Name)
+	}
+	if tool.Handler == nil {
+		return fmt.Errorf("tool %s has no handler", tool.Name)
+	}
+	if tool.Parameters.Type == "" {
+		tool.Parameters.Type = Object
+	}
+	if tool.Parameters.Type != Object {
+		return fmt.Errorf("tool %s parameters must be an object schema", tool.Name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.tools[tool.Name]; exists {
+		return fmt.Errorf("tool %s is already registered", tool.Name)
+	}
+	r.tools[tool.Name] = tool
+	return nil
+}
+
+// MustRegister adds tools, panicking if any cannot be registered. It suits
+// built-in tools, whose declarations are fixed.
+func (r *Registry) MustRegister(tools ...Tool) {
+	for _, tool := range tools {
+		if err := r.Register(tool); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Get returns a registered tool
+func (r *Registry) Get(name string) (Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tool, ok := r.tools[name]
+	return tool, ok
+}
+
+// Len returns how many tools are registered
+func (r *Registry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.tools)
+}
+
+// Tools returns the registered tools sorted by name
+func (r *Registry) Tools() []Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tools := make([]Tool, 0, len(r.tools))
+	for _, tool := range r.tools {
+		tools = append(tools, tool)
+	}
+	sort.Slice(tools, func(i, j int) bool {
+		return tools[i].Name < tools[j].Name
+	})
+	return tools
+}
+
+// Definitions returns the tools as declared to the model, sorted by name
+func (r *Registry) Definitions() []Definition {
+	tools := r.Tools()
+	definitions := make([]Definition, len(tools))
+	for i, tool := range tools {
+		definitions[i] = Definition{Name: tool.Name, Description: tool.Description, Parameters: tool.Parameters}
+	}
+	return definitions
+}
+
+// Call runs a tool with the JSON arguments the model sent
+func (r *Registry) Call(ctx context.Context, name, arguments string) (string, error) {
+	var args map[string]interface{}
+	if arguments != "" {
+		if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+			return "", fmt.Errorf("%w for %s: %v", ErrInvalidArguments, name, err)
+		}
+	}
+	return r.Invoke(ctx, name, args)
+}
+
+// Invoke validates args against the tool's schema and runs it within its
+// timeout. A handler that ignores its context is abandoned when the timeout
+// passes; its result is discarded.
+func (r *Registry) Invoke(ctx context.Context, name string, args map[string]interface{}) (string, error) {
+	tool, ok := r.Get(name)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownTool, name)
+	}
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	if err := tool.Parameters.Validate(args); err != nil {
+		return "", fmt.Errorf("%w for %s: %w", ErrInvalidArguments, name, err)
+	}
+
+	timeout := tool.Timeout
+	if timeout <= 0 {
+		timeout = r.DefaultTimeout
+	}
+	callCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type outcome struct {
+		result string
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := tool.Handler(callCtx, args)
+		done <- outcome{result, err}
+	}()
+
+	select {
+	case out := <-done:
+		return out.result, out.err
+	case <-callCtx.Done():
+		if errors.Is(callCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			return "", fmt.Errorf("%w: %s after %v", ErrTimeout, name, timeout)
+		}
+		return "", callCtx.Err()
+	}
+}
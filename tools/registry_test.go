@@ -0,0 +1,135 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSchemaValidate(t *testing.T) {
+	schema := Schema{
+		Type: Object,
+		Properties: map[string]Schema{
+			"kind":   {Type: String, Enum: []string{"line", "bar"}},
+			"bins":   {Type: Integer},
+			"values": {Type: Array, Items: &Schema{Type: Number}},
+		},
+		Required: []string{"kind"},
+	}
+
+	cases := []struct {
+		args map[string]interface{}
+		want string
+	}{
+		{map[string]interface{}{"kind": "bar", "bins": 3.0, "values": []interface{}{1.0, 2.5}, "extra": true}, ""},
+		{map[string]interface{}{}, "kind: is required"},
+		{map[string]interface{}{"kind": "pie"}, "kind: must be one of line, bar"},
+		{map[string]interface{}{"kind": "bar", "bins": 2.5}, "bins: must be an integer"},
+		{map[string]interface{}{"kind": "bar", "values": []interface{}{1.0, "two"}}, "values[1]: must be a number"},
+	}
+	for _, c := range cases {
+		err := schema.Validate(c.args)
+		if c.want == "" && err != nil {
+			t.Errorf("Validate(%v) = %v, want nil", c.args, err)
+		}
+		if c.want != "" && (err == nil || err.Error() != c.want) {
+			t.Errorf("Validate(%v) = %v, want %q", c.args, err, c.want)
+		}
+	}
+}
+
+func TestSchemaOf(t *testing.T) {
+	type Base struct {
+		ID int `json:"id"`
+	}
+	type Review struct {
+		Base
+		Sentiment string    `json:"sentiment" description:"overall tone" enum:"positive,negative"`
+		Score     float64   `json:"score"`
+		Tags      []string  `json:"tags,omitempty"`
+		Reviewed  time.Time `json:"reviewed"`
+		Reviewer  *string   `json:"reviewer"`
+		internal  string
+		Ignored   string `json:"-"`
+	}
+
+	schema, err := SchemaOf(&Review{})
+	if err != nil {
+		t.Fatalf("SchemaOf failed: %v", err)
+	}
+	if got := strings.Join(schema.Required, ","); got != "id,sentiment,score,reviewed" {
+		t.Errorf("Required = %s", got)
+	}
+	if len(schema.Properties) != 6 {
+		t.Errorf("Expected 6 properties, got %v", schema.Properties)
+	}
+	if s := schema.Properties["sentiment"]; s.Description != "overall tone" || len(s.Enum) != 2 {
+		t.Errorf("Expected the description and enum tags applied, got %+v", s)
+	}
+	if s := schema.Properties["tags"]; s.Type != Array || s.Items == nil || s.Items.Type != String {
+		t.Errorf("Expected an array of strings, got %+v", s)
+	}
+	if err := schema.Validate(map[string]interface{}{"id": 1.0, "sentiment": "meh", "score": 1.0, "reviewed": "2024-01-01T00:00:00Z"}); err == nil || err.Error() != "sentiment: must be one of positive, negative" {
+		t.Errorf("Expected the enum enforced, got %v", err)
+	}
+
+	type Node struct {
+		Children []Node `json:"children"`
+	}
+	if _, err := SchemaOf(Node{}); err == nil {
+		t.Error("Expected an error for a recursive type")
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	registry.MustRegister(Calculator(), TextAnalysis())
+	if err := registry.Register(Calculator()); err == nil {
+		t.Error("Expected a duplicate tool to be rejected")
+	}
+	if err := registry.Register(Tool{Name: "bad name", Handler: analyzeText}); err == nil {
+		t.Error("Expected an invalid name to be rejected")
+	}
+
+	ctx := context.Background()
+	if result, err := registry.Call(ctx, "calculator", `{"operation":"power","a":2,"b":10}`); err != nil || result != "1024.000000" {
+		t.Errorf("Expected 1024, got %q (%v)", result, err)
+	}
+	if _, err := registry.Call(ctx, "calculator", `{"operation":"add"}`); !errors.Is(err, ErrInvalidArguments) {
+		t.Errorf("Expected ErrInvalidArguments, got %v", err)
+	}
+	if _, err := registry.Call(ctx, "calculator", `not json`); !errors.Is(err, ErrInvalidArguments) {
+		t.Errorf("Expected ErrInvalidArguments for malformed JSON, got %v", err)
+	}
+	if _, err := registry.Call(ctx, "weather", `{}`); !errors.Is(err, ErrUnknownTool) {
+		t.Errorf("Expected ErrUnknownTool, got %v", err)
+	}
+
+	definitions := registry.Definitions()
+	if len(definitions) != 2 || definitions[0].Name != "analyze_text" {
+		t.Errorf("Expected definitions sorted by name, got %+v", definitions)
+	}
+}
+
+func TestRegistryTimeout(t *testing.T) {
+	registry := NewRegistry()
+	registry.MustRegister(Tool{
+		Name:    "stuck",
+		Timeout: 20 * time.Millisecond,
+		Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			time.Sleep(time.Second)
+			return "too late", nil
+		},
+	})
+
+	start := time.Now()
+	_, err := registry.Invoke(context.Background(), "stuck", nil)
+	if !errors.Is(err, ErrTimeout) || !strings.Contains(err.Error(), "stuck") {
+		t.Errorf("Expected ErrTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the call to be abandoned at its timeout, took %v", elapsed)
+	}
+}
@@ -0,0 +1,144 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// DataType is a JSON schema type
+type DataType string
+
+const (
+	Object  DataType = "object"
+	String  DataType = "string"
+	Number  DataType = "number"
+	Integer DataType = "integer"
+	Boolean DataType = "boolean"
+	Array   DataType = "array"
+)
+
+// Schema describes a tool's arguments. It marshals to the same JSON as
+// go-openai's jsonschema.Definition, so it can be passed as a function's
+// Parameters in any version of the client.
+type Schema struct {
+	Type        DataType          `json:"type,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Enum        []string          `json:"enum,omitempty"`
+	Properties  map[string]Schema `json:"properties,omitempty"`
+	Required    []string          `json:"required,omitempty"`
+	// Items is the schema of an array's elements
+	Items *Schema `json:"items,omitempty"`
+}
+
+// ValidationError reports the first argument that does not match a schema
+type ValidationError struct {
+	// Path locates the argument, e.g. "values[2]"; empty for the top level
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// Validate checks a decoded JSON value against the schema. Numbers are
+// float64 and objects map[string]interface{}, as encoding/json decodes them.
+// Properties not in the schema are allowed.
+func (s Schema) Validate(value interface{}) error {
+	return s.validate("", value)
+}
+
+func (s Schema) validate(path string, value interface{}) error {
+	fail := func(format string, args ...interface{}) error {
+		return &ValidationError{Path: path, Message: fmt.Sprintf(format, args...)}
+	}
+
+	switch s.Type {
+	case Object:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fail("must be an object")
+		}
+		for _, name := range s.Required {
+			if _, ok := object[name]; !ok {
+				return &ValidationError{Path: join(path, name), Message: "is required"}
+			}
+		}
+		// Sorted so the reported error is deterministic
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property, ok := s.Properties[name]
+			if !ok {
+				continue
+			}
+			if err := property.validate(join(path, name), object[name]); err != nil {
+				return err
+			}
+		}
+
+	case String:
+		str, ok := value.(string)
+		if !ok {
+			return fail("must be a string")
+		}
+		if len(s.Enum) > 0 && !contains(s.Enum, str) {
+			return fail("must be one of %s", strings.Join(s.Enum, ", "))
+		}
+
+	case Number:
+		if _, ok := value.(float64); !ok {
+			return fail("must be a number")
+		}
+
+	case Integer:
+		number, ok := value.(float64)
+		if !ok || number != math.Trunc(number) {
+			return fail("must be an integer")
+		}
+
+	case Boolean:
+		if _, ok := value.(bool); !ok {
+			return fail("must be a boolean")
+		}
+
+	case Array:
+		items, ok := value.([]interface{})
+		if !ok {
+			return fail("must be an array")
+		}
+		if s.Items != nil {
+			for i, item := range items {
+				if err := s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// join appends a property name to a path
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
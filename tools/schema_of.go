@@ -0,0 +1,113 @@
+package tools
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// SchemaOf derives a schema from a Go value's type, usually a pointer to
+// the struct a model's JSON is decoded into. Fields are named by their json
+// tags and required unless tagged omitempty or a pointer; a description tag
+// describes a field and an enum tag lists its allowed values:
+//
+//	Sentiment string `json:"sentiment" description:"overall tone" enum:"positive,neutral,negative"`
+func SchemaOf(v interface{}) (Schema, error) {
+	if v == nil {
+		return Schema{}, fmt.Errorf("cannot derive a schema from nil")
+	}
+	return schemaOf(reflect.TypeOf(v), map[reflect.Type]bool{})
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func schemaOf(t reflect.Type, visiting map[reflect.Type]bool) (Schema, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return Schema{Type: String, Description: "RFC 3339 timestamp"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return Schema{Type: String}, nil
+	case reflect.Bool:
+		return Schema{Type: Boolean}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{Type: Integer}, nil
+	case reflect.Float32, reflect.Float64:
+		return Schema{Type: Number}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Schema{Type: String}, nil // base64, as encoding/json writes []byte
+		}
+		items, err := schemaOf(t.Elem(), visiting)
+		if err != nil {
+			return Schema{}, err
+		}
+		return Schema{Type: Array, Items: &items}, nil
+	case reflect.Map:
+		return Schema{Type: Object}, nil
+	case reflect.Interface:
+		return Schema{}, nil // anything
+	case reflect.Struct:
+		if visiting[t] {
+			return Schema{}, fmt.Errorf("cannot derive a schema for recursive type %s", t)
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		schema := Schema{Type: Object, Properties: map[string]Schema{}}
+		if err := addFields(&schema, t, visiting); err != nil {
+			return Schema{}, err
+		}
+		return schema, nil
+	default:
+		return Schema{}, fmt.Errorf("cannot derive a schema for %s", t)
+	}
+}
+
+// addFields adds t's fields to schema, flattening embedded structs as
+// encoding/json does
+func addFields(schema *Schema, t reflect.Type, visiting map[reflect.Type]bool) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || !field.IsExported() && !field.Anonymous {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if err := addFields(schema, embedded, visiting); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property, err := schemaOf(field.Type, visiting)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		property.Description = field.Tag.Get("description")
+		if enum := field.Tag.Get("enum"); enum != "" {
+			property.Enum = strings.Split(enum, ",")
+		}
+		schema.Properties[name] = property
+		if !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Pointer {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return nil
+}
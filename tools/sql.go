@@ -0,0 +1,391 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrWriteQuery is returned for a statement that could change data when
+// the SQL policy is read-only
+var ErrWriteQuery = errors.New("only read-only queries are allowed")
+
+// SQL dialects, which decide how the schema is read
+const (
+	DialectPostgres = "postgres"
+	DialectMySQL    = "mysql"
+	DialectSQLite   = "sqlite"
+)
+
+// SQLPolicy bounds what run_query may do
+type SQLPolicy struct {
+	// ReadOnly allows only queries, and runs them in a read-only
+	// transaction that is always rolled back
+	ReadOnly bool
+	// MaxRows caps the rows returned to the model
+	MaxRows int
+	// Timeout bounds a query
+	Timeout time.Duration
+}
+
+// DefaultSQLPolicy is read-only and returns at most 100 rows from a query
+// of at most 10 seconds
+func DefaultSQLPolicy() SQLPolicy {
+	return SQLPolicy{ReadOnly: true, MaxRows: 100, Timeout: 10 * time.Second}
+}
+
+// DialectForDriver returns the dialect of a database/sql driver name
+func DialectForDriver(driver string) (string, error) {
+	switch strings.ToLower(driver) {
+	case "postgres", "pgx":
+		return DialectPostgres, nil
+	case "mysql":
+		return DialectMySQL, nil
+	case "sqlite", "sqlite3":
+		return DialectSQLite, nil
+	default:
+		return "", fmt.Errorf("unknown SQL driver %q: use postgres, mysql or sqlite", driver)
+	}
+}
+
+// QueryResult is what run_query returns to the model
+type QueryResult struct {
+	Columns      []string        `json:"columns,omitempty"`
+	Rows         [][]interface{} `json:"rows,omitempty"`
+	RowsAffected *int64          `json:"rows_affected,omitempty"`
+	Truncated    bool            `json:"truncated,omitempty"`
+}
+
+// SQLTools returns describe_schema and run_query for db, whose schema is
+// read the way dialect does it
+func SQLTools(db *sql.DB, dialect string, policy SQLPolicy) []Tool {
+	if policy.MaxRows <= 0 {
+		policy.MaxRows = DefaultSQLPolicy().MaxRows
+	}
+	if policy.Timeout <= 0 {
+		policy.Timeout = DefaultSQLPolicy().Timeout
+	}
+	access := "read-only "
+	if !policy.ReadOnly {
+		access = ""
+	}
+
+	return []Tool{
+		{
+			Name:        "describe_schema",
+			Description: fmt.Sprintf("List the tables in the %s database with their columns and types. Call it before writing a query.", dialect),
+			Parameters: Schema{
+				Type: Object,
+				Properties: map[string]Schema{
+					"table": {Type: String, Description: "Only describe this table (default: all tables)"},
+				},
+			},
+			Timeout: policy.Timeout + time.Second,
+			Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				table, _ := args["table"].(string)
+				ctx, cancel := context.WithTimeout(ctx, policy.Timeout)
+				defer cancel()
+				return describeSchema(ctx, db, dialect, table)
+			},
+		},
+		{
+			Name: "run_query",
+			Description: fmt.Sprintf("Run one %sSQL statement against the %s database. Returns the columns and at most %d rows as JSON; aggregate in SQL rather than fetching raw rows.",
+				access, dialect, policy.MaxRows),
+			Parameters: Schema{
+				Type: Object,
+				Properties: map[string]Schema{
+					"query": {Type: String, Description: "A single SQL statement in the " + dialect + " dialect"},
+				},
+				Required: []string{"query"},
+			},
+			Timeout: policy.Timeout + time.Second,
+			Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				query, _ := args["query"].(string)
+				result, err := policy.Run(ctx, db, query)
+				if err != nil {
+					return "", err
+				}
+				data, err := json.Marshal(result)
+				if err != nil {
+					return "", err
+				}
+				return string(data), nil
+			},
+		},
+	}
+}
+
+// Run executes a single statement within the policy. In read-only mode
+// it must be a query, and it runs in a read-only transaction that is
+// rolled back, so even a query with side effects changes nothing.
+func (p SQLPolicy) Run(ctx context.Context, db *sql.DB, query string) (*QueryResult, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, fmt.Errorf("query must not be empty")
+	}
+	if multipleStatements(query) {
+		return nil, fmt.Errorf("run one statement at a time")
+	}
+	isRead := readStatement(query)
+	if p.ReadOnly && !isRead {
+		return nil, fmt.Errorf("%w: %s", ErrWriteQuery, firstKeyword(query))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: p.ReadOnly})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if !isRead {
+		res, err := tx.ExecContext(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return &QueryResult{RowsAffected: &affected}, nil
+	}
+
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result, err := readRows(rows, p.MaxRows)
+	if err != nil {
+		return nil, err
+	}
+	if !p.ReadOnly {
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// readRows reads up to limit rows, converting the values to JSON-friendly
+// types
+func readRows(rows *sql.Rows, limit int) (*QueryResult, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	result := &QueryResult{Columns: columns, Rows: [][]interface{}{}}
+	for rows.Next() {
+		if len(result.Rows) == limit {
+			result.Truncated = true
+			break
+		}
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		for i, value := range values {
+			if b, ok := value.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	return result, rows.Err()
+}
+
+// readKeywords start the statements a read-only policy allows
+var readKeywords = map[string]bool{
+	"SELECT": true, "WITH": true, "EXPLAIN": true, "SHOW": true,
+	"DESCRIBE": true, "DESC": true, "VALUES": true, "TABLE": true,
+}
+
+func readStatement(query string) bool {
+	return readKeywords[firstKeyword(query)]
+}
+
+// firstKeyword returns the statement's first word, upper-cased, after any
+// leading comments and parentheses
+func firstKeyword(query string) string {
+	for {
+		query = strings.TrimLeft(query, " \t\r\n(")
+		switch {
+		case strings.HasPrefix(query, "--"):
+			_, query, _ = strings.Cut(query, "\n")
+		case strings.HasPrefix(query, "/*"):
+			_, query, _ = strings.Cut(query, "*/")
+		default:
+			end := strings.IndexFunc(query, func(r rune) bool {
+				return !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+			})
+			if end < 0 {
+				end = len(query)
+			}
+			return strings.ToUpper(query[:end])
+		}
+	}
+}
+
+// multipleStatements reports whether query has a semicolon followed by
+// more SQL, outside quotes and comments
+func multipleStatements(query string) bool {
+	var quote byte
+	ended := false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			if ended {
+				return true
+			}
+			quote = c
+		case strings.HasPrefix(query[i:], "--"):
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return false
+			}
+			i += end + 3
+		case c == ';':
+			ended = true
+		case ended && !strings.ContainsRune(" \t\r\n", rune(c)):
+			return true
+		}
+	}
+	return false
+}
+
+// schemaQueries list every column as table, column, type, nullable
+var schemaQueries = map[string]string{
+	DialectPostgres: `SELECT CASE WHEN table_schema = 'public' THEN table_name ELSE table_schema || '.' || table_name END,
+		column_name, data_type, is_nullable = 'YES'
+		FROM information_schema.columns
+		WHERE table_schema NOT IN ('pg_catalog', 'information_schema')
+		ORDER BY table_schema, table_name, ordinal_position`,
+	DialectMySQL: `SELECT table_name, column_name, column_type, is_nullable = 'YES'
+		FROM information_schema.columns
+		WHERE table_schema = DATABASE()
+		ORDER BY table_name, ordinal_position`,
+	DialectSQLite: `SELECT m.name, p.name, p.type, p."notnull" = 0
+		FROM sqlite_master m JOIN pragma_table_info(m.name) p
+		WHERE m.type IN ('table', 'view') AND m.name NOT LIKE 'sqlite_%'
+		ORDER BY m.name, p.cid`,
+}
+
+// describeSchema lists the columns of every table, or of only table
+func describeSchema(ctx context.Context, db *sql.DB, dialect, table string) (string, error) {
+	query, ok := schemaQueries[dialect]
+	if !ok {
+		return "", fmt.Errorf("unknown SQL dialect %q", dialect)
+	}
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var out strings.Builder
+	current := ""
+	for rows.Next() {
+		var name, column, columnType string
+		var nullable bool
+		if err := rows.Scan(&name, &column, &columnType, &nullable); err != nil {
+			return "", err
+		}
+		if table != "" && !strings.EqualFold(name, table) {
+			continue
+		}
+		if name != current {
+			fmt.Fprintf(&out, "%s\n", name)
+			current = name
+		}
+		fmt.Fprintf(&out, "  %s %s", column, columnType)
+		if !nullable {
+			out.WriteString(" NOT NULL")
+		}
+		out.WriteString("\n")
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	if out.Len() == 0 {
+		if table != "" {
+			return "", fmt.Errorf("no table named %s", table)
+		}
+		return "The database has no tables.", nil
+	}
+	return out.String(), nil
+}
+
+// SQLFromEnv opens SQL_TOOLS_DSN with the SQL_TOOLS_DRIVER driver (postgres,
+// mysql or sqlite, which the program must import) and returns the SQL
+// tools, or nil when no DSN is set. SQL_TOOLS_READ_ONLY=false allows
+// writes; SQL_TOOLS_MAX_ROWS and SQL_TOOLS_TIMEOUT_SECONDS adjust the limits.
+func SQLFromEnv() ([]Tool, error) {
+	dsn := os.Getenv("SQL_TOOLS_DSN")
+	if dsn == "" {
+		return nil, nil
+	}
+	driver := os.Getenv("SQL_TOOLS_DRIVER")
+	dialect, err := DialectForDriver(driver)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := DefaultSQLPolicy()
+	if value := os.Getenv("SQL_TOOLS_READ_ONLY"); value != "" {
+		readOnly, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SQL_TOOLS_READ_ONLY %q", value)
+		}
+		policy.ReadOnly = readOnly
+	}
+	if value := os.Getenv("SQL_TOOLS_MAX_ROWS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid SQL_TOOLS_MAX_ROWS %q", value)
+		}
+		policy.MaxRows = n
+	}
+	if value := os.Getenv("SQL_TOOLS_TIMEOUT_SECONDS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid SQL_TOOLS_TIMEOUT_SECONDS %q", value)
+		}
+		policy.Timeout = time.Duration(n) * time.Second
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), policy.Timeout)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to %s: %w", dialect, err)
+	}
+	return SQLTools(db, dialect, policy), nil
+}
@@ -0,0 +1,111 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+)
+
+// fakeDriver serves "SELECT" queries with n rows of (id, name) and records
+// how each transaction ended
+type fakeDriver struct{ rows, commits, rollbacks int }
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{d}, nil }
+
+type fakeConn struct{ d *fakeDriver }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.d}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                 { return fakeTx{c.d}, nil }
+func (c fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return fakeTx{c.d}, nil
+}
+
+type fakeTx struct{ d *fakeDriver }
+
+func (t fakeTx) Commit() error   { t.d.commits++; return nil }
+func (t fakeTx) Rollback() error { t.d.rollbacks++; return nil }
+
+type fakeStmt struct{ d *fakeDriver }
+
+func (s fakeStmt) Close() error                               { return nil }
+func (s fakeStmt) NumInput() int                              { return 0 }
+func (s fakeStmt) Exec([]driver.Value) (driver.Result, error) { return driver.RowsAffected(2), nil }
+func (s fakeStmt) Query([]driver.Value) (driver.Rows, error)  { return &fakeRows{left: s.d.rows}, nil }
+
+type fakeRows struct{ left int }
+
+func (r *fakeRows) Columns() []string { return []string{"id", "name"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.left == 0 {
+		return io.EOF
+	}
+	dest[0], dest[1] = int64(r.left), []byte("row")
+	r.left--
+	return nil
+}
+
+func TestSQLTools(t *testing.T) {
+	fake := &fakeDriver{rows: 5}
+	sql.Register("fake", fake)
+	db, err := sql.Open("fake", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	policy := DefaultSQLPolicy()
+	policy.MaxRows = 3
+	ctx := context.Background()
+
+	result, err := policy.Run(ctx, db, "-- top rows\nSELECT id, name FROM t;")
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	data, _ := json.Marshal(result)
+	if string(data) != `{"columns":["id","name"],"rows":[[5,"row"],[4,"row"],[3,"row"]],"truncated":true}` {
+		t.Errorf("Unexpected result %s", data)
+	}
+	if fake.commits != 0 || fake.rollbacks != 1 {
+		t.Errorf("Expected a read-only query to be rolled back, got %d commits and %d rollbacks", fake.commits, fake.rollbacks)
+	}
+
+	for _, query := range []string{"DELETE FROM t", "/* tidy */ DROP TABLE t", "select 1; delete from t"} {
+		if _, err := policy.Run(ctx, db, query); err == nil {
+			t.Errorf("Expected %q to be refused", query)
+		}
+	}
+	if _, err := policy.Run(ctx, db, "SELECT 'a;b' -- done;"); err != nil {
+		t.Errorf("Expected semicolons in strings and comments to be allowed, got %v", err)
+	}
+	if _, err := policy.Run(ctx, db, "DELETE FROM t"); !errors.Is(err, ErrWriteQuery) {
+		t.Errorf("Expected ErrWriteQuery, got %v", err)
+	}
+
+	policy.ReadOnly = false
+	result, err = policy.Run(ctx, db, "UPDATE t SET name = 'x'")
+	if err != nil || result.RowsAffected == nil || *result.RowsAffected != 2 || fake.commits != 1 {
+		t.Errorf("Expected the update to be committed, got %+v (%v)", result, err)
+	}
+}
+
+func TestSQLFromEnv(t *testing.T) {
+	t.Setenv("SQL_TOOLS_DSN", "")
+	if sqlTools, err := SQLFromEnv(); err != nil || sqlTools != nil {
+		t.Errorf("Expected no tools when unset, got %v (%v)", sqlTools, err)
+	}
+	t.Setenv("SQL_TOOLS_DSN", "file.db")
+	t.Setenv("SQL_TOOLS_DRIVER", "oracle")
+	if _, err := SQLFromEnv(); err == nil {
+		t.Error("Expected an unknown driver to fail")
+	}
+	t.Setenv("SQL_TOOLS_DRIVER", "sqlite")
+	t.Setenv("SQL_TOOLS_MAX_ROWS", "0")
+	if _, err := SQLFromEnv(); err == nil {
+		t.Error("Expected an invalid row limit to fail")
+	}
+}
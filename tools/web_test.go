@@ -0,0 +1,113 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestHTMLToText(t *testing.T) {
+	title, text := htmlToText(`<html><head><title>Go &amp; You</title><style>p{color:red}</style></head>
+<body><script>alert("x")</script><h1>Hello</h1><p>First   paragraph with <b>bold</b> text.</p><!-- hidden --><ul><li>one</li><li>two &lt;3</li></ul></body></html>`)
+	if title != "Go & You" {
+		t.Errorf("Expected the title, got %q", title)
+	}
+	if want := "Hello\nFirst paragraph with bold text.\none\ntwo <3"; text != want {
+		t.Errorf("Expected %q, got %q", want, text)
+	}
+}
+
+func TestFetchURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte("<title>News</title><p>" + strings.Repeat("word ", 100) + "</p>"))
+		case "/image":
+			w.Header().Set("Content-Type", "image/png")
+		case "/away":
+			http.Redirect(w, r, "http://blocked.example/", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	policy := DefaultFetchPolicy()
+	policy.AllowPrivate = true
+	policy.MaxChars = 20
+	policy.DenyDomains = []string{"blocked.example"}
+	registry := NewRegistry()
+	registry.MustRegister(FetchURL(policy))
+	ctx := context.Background()
+
+	result, err := registry.Invoke(ctx, "fetch_url", map[string]interface{}{"url": server.URL + "/page"})
+	if err != nil || !strings.HasPrefix(result, "Title: News\nURL: ") || !strings.HasSuffix(result, "word word word word\n[truncated]") {
+		t.Errorf("Unexpected page %q (%v)", result, err)
+	}
+	if _, err := registry.Invoke(ctx, "fetch_url", map[string]interface{}{"url": server.URL + "/image"}); err == nil {
+		t.Error("Expected binary content to be refused")
+	}
+	if _, err := registry.Invoke(ctx, "fetch_url", map[string]interface{}{"url": server.URL + "/away"}); !errors.Is(err, ErrBlockedURL) {
+		t.Errorf("Expected a redirect to a denied domain to be blocked, got %v", err)
+	}
+
+	// Loopback is refused unless allowed, however the host is spelled
+	sandboxed := NewRegistry()
+	sandboxed.MustRegister(FetchURL(DefaultFetchPolicy()))
+	if _, err := sandboxed.Invoke(ctx, "fetch_url", map[string]interface{}{"url": server.URL + "/page"}); !errors.Is(err, ErrBlockedURL) {
+		t.Errorf("Expected loopback to be blocked, got %v", err)
+	}
+	if _, err := sandboxed.Invoke(ctx, "fetch_url", map[string]interface{}{"url": "file:///etc/passwd"}); !errors.Is(err, ErrBlockedURL) {
+		t.Errorf("Expected a file URL to be blocked, got %v", err)
+	}
+
+	allowOnly := FetchPolicy{AllowDomains: []string{"example.com"}}
+	for target, allowed := range map[string]bool{
+		"https://example.com/a":      true,
+		"https://docs.example.com/b": true,
+		"https://badexample.com/":    false,
+		"https://example.org/":       false,
+	} {
+		u, _ := url.Parse(target)
+		if err := allowOnly.Check(u); (err == nil) != allowed {
+			t.Errorf("Check(%s) = %v, expected allowed=%v", target, err, allowed)
+		}
+	}
+}
+
+func TestWebSearch(t *testing.T) {
+	var gotQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.Query().Get("q")
+		w.Write([]byte(`{"Heading":"Go","AbstractText":"Go is a language.","AbstractURL":"https://go.dev",
+			"RelatedTopics":[{"Text":"Gopher - the mascot","FirstURL":"https://go.dev/gopher"},
+			{"Name":"Tools","Topics":[{"Text":"gofmt - formatter","FirstURL":"https://go.dev/gofmt"}]}]}`))
+	}))
+	defer server.Close()
+
+	provider := DuckDuckGo()
+	provider.Endpoint = server.URL
+	registry := NewRegistry()
+	registry.MustRegister(WebSearch(provider))
+
+	result, err := registry.Invoke(context.Background(), "web_search", map[string]interface{}{"query": "golang", "max_results": 2.0})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if gotQuery != "golang" || !strings.Contains(result, `"url":"https://go.dev"`) || !strings.Contains(result, `"title":"Gopher"`) || strings.Contains(result, "gofmt") {
+		t.Errorf("Unexpected results %q for query %q", result, gotQuery)
+	}
+
+	t.Setenv("WEB_SEARCH_PROVIDER", "bing")
+	t.Setenv("BING_API_KEY", "")
+	if _, err := SearchProviderFromEnv(); err == nil {
+		t.Error("Expected bing without a key to be rejected")
+	}
+	t.Setenv("WEB_SEARCH_PROVIDER", "")
+	if web, err := WebFromEnv(); err != nil || len(web) != 1 || web[0].Name != "fetch_url" {
+		t.Errorf("Expected only fetch_url without a provider, got %v (%v)", web, err)
+	}
+}
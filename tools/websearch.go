@@ -0,0 +1,290 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultSearchResults is how many results web_search returns unless asked
+const DefaultSearchResults = 5
+
+// WebResult is one web search hit
+type WebResult struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// SearchProvider is a web search engine web_search can use
+type SearchProvider interface {
+	Name() string
+	Search(ctx context.Context, query string, limit int) ([]WebResult, error)
+}
+
+// WebSearch returns the web_search tool, backed by provider
+func WebSearch(provider SearchProvider) Tool {
+	return Tool{
+		Name:        "web_search",
+		Description: "Search the web for current information. Returns result titles, URLs and snippets; use fetch_url to read a result in full.",
+		Parameters: Schema{
+			Type: Object,
+			Properties: map[string]Schema{
+				"query": {
+					Type:        String,
+					Description: "The search query",
+				},
+				"max_results": {
+					Type:        Integer,
+					Description: fmt.Sprintf("How many results to return (default %d, at most %d)", DefaultSearchResults, MaxTopK),
+				},
+			},
+			Required: []string{"query"},
+		},
+		Timeout: 15 * time.Second,
+		Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			query, _ := args["query"].(string)
+			if strings.TrimSpace(query) == "" {
+				return "", fmt.Errorf("query must not be empty")
+			}
+			limit := DefaultSearchResults
+			if n, ok := args["max_results"].(float64); ok {
+				limit = clampTopK(int(n))
+			}
+
+			results, err := provider.Search(ctx, query, limit)
+			if err != nil {
+				return "", fmt.Errorf("%s search failed: %w", provider.Name(), err)
+			}
+			if len(results) > limit {
+				results = results[:limit]
+			}
+			if len(results) == 0 {
+				return "No results found.", nil
+			}
+			data, err := json.Marshal(results)
+			if err != nil {
+				return "", err
+			}
+			return string(data), nil
+		},
+	}
+}
+
+// SearchProviderFromEnv returns the provider WEB_SEARCH_PROVIDER names:
+// bing (with BING_API_KEY), serpapi (with SERPAPI_API_KEY) or duckduckgo.
+// It returns nil when none is configured.
+func SearchProviderFromEnv() (SearchProvider, error) {
+	switch name := strings.ToLower(os.Getenv("WEB_SEARCH_PROVIDER")); name {
+	case "":
+		return nil, nil
+	case "bing":
+		if os.Getenv("BING_API_KEY") == "" {
+			return nil, fmt.Errorf("WEB_SEARCH_PROVIDER=bing requires BING_API_KEY")
+		}
+		return Bing(os.Getenv("BING_API_KEY")), nil
+	case "serpapi":
+		if os.Getenv("SERPAPI_API_KEY") == "" {
+			return nil, fmt.Errorf("WEB_SEARCH_PROVIDER=serpapi requires SERPAPI_API_KEY")
+		}
+		return SerpAPI(os.Getenv("SERPAPI_API_KEY")), nil
+	case "duckduckgo":
+		return DuckDuckGo(), nil
+	default:
+		return nil, fmt.Errorf("unknown WEB_SEARCH_PROVIDER %q: use bing, serpapi or duckduckgo", name)
+	}
+}
+
+// WebFromEnv returns fetch_url, configured by FetchPolicyFromEnv, and
+// web_search when SearchProviderFromEnv names a provider
+func WebFromEnv() ([]Tool, error) {
+	policy, err := FetchPolicyFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	web := []Tool{FetchURL(policy)}
+
+	provider, err := SearchProviderFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if provider != nil {
+		web = append(web, WebSearch(provider))
+	}
+	return web, nil
+}
+
+// HTTPSearchProvider queries a search API over HTTP. Endpoint can be
+// pointed elsewhere, e.g. at a test server.
+type HTTPSearchProvider struct {
+	name     string
+	Endpoint string
+	Client   *http.Client
+	// prepare adds the query to a request; parse reads the response
+	prepare func(req *http.Request, query string, limit int)
+	parse   func(body []byte) ([]WebResult, error)
+}
+
+// Name returns the provider's name
+func (p *HTTPSearchProvider) Name() string {
+	return p.name
+}
+
+// Search runs query against the provider's API
+func (p *HTTPSearchProvider) Search(ctx context.Context, query string, limit int) ([]WebResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.Endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	p.prepare(req, query, limit)
+
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body[:min(len(body), 200)])))
+	}
+	results, err := p.parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// setQuery adds params to req's URL
+func setQuery(req *http.Request, params map[string]string) {
+	query := req.URL.Query()
+	for key, value := range params {
+		query.Set(key, value)
+	}
+	req.URL.RawQuery = query.Encode()
+}
+
+// Bing searches with the Bing Web Search API
+func Bing(apiKey string) *HTTPSearchProvider {
+	return &HTTPSearchProvider{
+		name:     "bing",
+		Endpoint: "https://api.bing.microsoft.com/v7.0/search",
+		prepare: func(req *http.Request, query string, limit int) {
+			setQuery(req, map[string]string{"q": query, "count": strconv.Itoa(limit), "textDecorations": "false"})
+			req.Header.Set("Ocp-Apim-Subscription-Key", apiKey)
+		},
+		parse: func(body []byte) ([]WebResult, error) {
+			var resp struct {
+				WebPages struct {
+					Value []struct {
+						Name    string `json:"name"`
+						URL     string `json:"url"`
+						Snippet string `json:"snippet"`
+					} `json:"value"`
+				} `json:"webPages"`
+			}
+			if err := json.Unmarshal(body, &resp); err != nil {
+				return nil, err
+			}
+			var results []WebResult
+			for _, page := range resp.WebPages.Value {
+				results = append(results, WebResult{Title: page.Name, URL: page.URL, Snippet: page.Snippet})
+			}
+			return results, nil
+		},
+	}
+}
+
+// SerpAPI searches Google through SerpAPI
+func SerpAPI(apiKey string) *HTTPSearchProvider {
+	return &HTTPSearchProvider{
+		name:     "serpapi",
+		Endpoint: "https://serpapi.com/search.json",
+		prepare: func(req *http.Request, query string, limit int) {
+			setQuery(req, map[string]string{"engine": "google", "q": query, "num": strconv.Itoa(limit), "api_key": apiKey})
+		},
+		parse: func(body []byte) ([]WebResult, error) {
+			var resp struct {
+				Error   string `json:"error"`
+				Organic []struct {
+					Title   string `json:"title"`
+					Link    string `json:"link"`
+					Snippet string `json:"snippet"`
+				} `json:"organic_results"`
+			}
+			if err := json.Unmarshal(body, &resp); err != nil {
+				return nil, err
+			}
+			if resp.Error != "" {
+				return nil, fmt.Errorf("%s", resp.Error)
+			}
+			var results []WebResult
+			for _, hit := range resp.Organic {
+				results = append(results, WebResult{Title: hit.Title, URL: hit.Link, Snippet: hit.Snippet})
+			}
+			return results, nil
+		},
+	}
+}
+
+// DuckDuckGo searches DuckDuckGo's Instant Answer API, which needs no key.
+// It returns the topic summary and related topics rather than full web
+// results, so it suits factual lookups best.
+func DuckDuckGo() *HTTPSearchProvider {
+	return &HTTPSearchProvider{
+		name:     "duckduckgo",
+		Endpoint: "https://api.duckduckgo.com/",
+		prepare: func(req *http.Request, query string, limit int) {
+			setQuery(req, map[string]string{"q": query, "format": "json", "no_html": "1", "skip_disambig": "1"})
+		},
+		parse: func(body []byte) ([]WebResult, error) {
+			type topic struct {
+				Text     string  `json:"Text"`
+				FirstURL string  `json:"FirstURL"`
+				Topics   []topic `json:"Topics"`
+			}
+			var resp struct {
+				Heading       string  `json:"Heading"`
+				AbstractText  string  `json:"AbstractText"`
+				AbstractURL   string  `json:"AbstractURL"`
+				RelatedTopics []topic `json:"RelatedTopics"`
+			}
+			if err := json.Unmarshal(body, &resp); err != nil {
+				return nil, err
+			}
+
+			var results []WebResult
+			if resp.AbstractURL != "" {
+				results = append(results, WebResult{Title: resp.Heading, URL: resp.AbstractURL, Snippet: resp.AbstractText})
+			}
+			var add func(topics []topic)
+			add = func(topics []topic) {
+				for _, t := range topics {
+					if t.FirstURL != "" {
+						title, _, _ := strings.Cut(t.Text, " - ")
+						results = append(results, WebResult{Title: title, URL: t.FirstURL, Snippet: t.Text})
+					}
+					add(t.Topics)
+				}
+			}
+			add(resp.RelatedTopics)
+			return results, nil
+		},
+	}
+}